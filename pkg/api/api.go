@@ -11,6 +11,8 @@ import (
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/middleware"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rbac"
+	macaron "gopkg.in/macaron.v1"
 )
 
 var plog = log.New("api")
@@ -23,6 +25,18 @@ func (hs *HTTPServer) registerRoutes() {
 	reqOrgAdmin := middleware.ReqOrgAdmin
 	reqCanAccessTeams := middleware.AdminOrFeatureEnabled(hs.Cfg.EditorsCanAdmin)
 	reqSnapshotPublicModeOrSignedIn := middleware.SnapshotPublicModeOrSignedIn(hs.Cfg)
+	authorize := func(fallback macaron.Handler, evaluator rbac.Evaluator) macaron.Handler {
+		return middleware.Authorize(hs.RBACService, fallback, evaluator)
+	}
+	usersRead := rbac.Perm(rbac.ActionUsersRead, "users:*")
+	usersWrite := rbac.Perm(rbac.ActionUsersWrite, "users:*")
+	policiesRead := rbac.Perm(rbac.ActionPoliciesRead, "policies:*")
+	// policyPerm requires the action on the policy of the :policyUID parameter.
+	policyPerm := func(action string) macaron.Handler {
+		return middleware.AuthorizeFunc(hs.RBACService, reqOrgAdmin, func(c *models.ReqContext) rbac.Evaluator {
+			return rbac.Perm(action, rbac.ScopePolicyUID(c.Params(":policyUID")))
+		})
+	}
 	redirectFromLegacyDashboardURL := middleware.RedirectFromLegacyDashboardURL()
 	redirectFromLegacyDashboardSoloURL := middleware.RedirectFromLegacyDashboardSoloURL(hs.Cfg)
 	redirectFromLegacyPanelEditURL := middleware.RedirectFromLegacyPanelEditURL(hs.Cfg)
@@ -148,17 +162,18 @@ func (hs *HTTPServer) registerRoutes() {
 			userRoute.Post("/revoke-auth-token", bind(models.RevokeAuthTokenCmd{}), routing.Wrap(hs.RevokeUserAuthToken))
 		})
 
-		// users (admin permission required)
+		// users (admin permission required, and the users permissions too when RBAC is enabled). The users
+		// are those of the server, so RBAC, whose policies belong to organizations, only restricts Grafana admins.
 		apiRoute.Group("/users", func(usersRoute routing.RouteRegister) {
-			usersRoute.Get("/", routing.Wrap(SearchUsers))
-			usersRoute.Get("/search", routing.Wrap(SearchUsersWithPaging))
-			usersRoute.Get("/:id", routing.Wrap(GetUserByID))
-			usersRoute.Get("/:id/teams", routing.Wrap(GetUserTeams))
-			usersRoute.Get("/:id/orgs", routing.Wrap(GetUserOrgList))
+			usersRoute.Get("/", authorize(reqGrafanaAdmin, usersRead), routing.Wrap(SearchUsers))
+			usersRoute.Get("/search", authorize(reqGrafanaAdmin, usersRead), routing.Wrap(SearchUsersWithPaging))
+			usersRoute.Get("/:id", authorize(reqGrafanaAdmin, usersRead), routing.Wrap(GetUserByID))
+			usersRoute.Get("/:id/teams", authorize(reqGrafanaAdmin, usersRead), routing.Wrap(GetUserTeams))
+			usersRoute.Get("/:id/orgs", authorize(reqGrafanaAdmin, usersRead), routing.Wrap(GetUserOrgList))
 			// query parameters /users/lookup?loginOrEmail=admin@example.com
-			usersRoute.Get("/lookup", routing.Wrap(GetUserByLoginOrEmail))
-			usersRoute.Put("/:id", bind(models.UpdateUserCommand{}), routing.Wrap(UpdateUser))
-			usersRoute.Post("/:id/using/:orgId", routing.Wrap(UpdateUserActiveOrg))
+			usersRoute.Get("/lookup", authorize(reqGrafanaAdmin, usersRead), routing.Wrap(GetUserByLoginOrEmail))
+			usersRoute.Put("/:id", authorize(reqGrafanaAdmin, usersWrite), bind(models.UpdateUserCommand{}), routing.Wrap(UpdateUser))
+			usersRoute.Post("/:id/using/:orgId", authorize(reqGrafanaAdmin, usersWrite), routing.Wrap(UpdateUserActiveOrg))
 		}, reqGrafanaAdmin)
 
 		// team (admin permission required)
@@ -216,7 +231,7 @@ func (hs *HTTPServer) registerRoutes() {
 		// search all orgs
 		apiRoute.Get("/orgs", reqGrafanaAdmin, routing.Wrap(SearchOrgs))
 
-		// orgs (admin routes)
+		// orgs (admin routes), identified by id or by external identifier
 		apiRoute.Group("/orgs/:orgId", func(orgsRoute routing.RouteRegister) {
 			orgsRoute.Get("/", routing.Wrap(GetOrgByID))
 			orgsRoute.Put("/", bind(dtos.UpdateOrgForm{}), routing.Wrap(UpdateOrg))
@@ -228,7 +243,7 @@ func (hs *HTTPServer) registerRoutes() {
 			orgsRoute.Delete("/users/:userId", routing.Wrap(RemoveOrgUser))
 			orgsRoute.Get("/quotas", routing.Wrap(GetOrgQuotas))
 			orgsRoute.Put("/quotas/:target", bind(models.UpdateOrgQuotaCmd{}), routing.Wrap(UpdateOrgQuota))
-		}, reqGrafanaAdmin)
+		}, reqGrafanaAdmin, middleware.ResolveOrgIDParam())
 
 		// orgs (admin routes)
 		apiRoute.Group("/orgs/name/:name", func(orgsRoute routing.RouteRegister) {
@@ -304,6 +319,8 @@ func (hs *HTTPServer) registerRoutes() {
 		// Dashboard
 		apiRoute.Group("/dashboards", func(dashboardRoute routing.RouteRegister) {
 			dashboardRoute.Get("/uid/:uid", routing.Wrap(hs.GetDashboard))
+			// The folder of the dashboard is only known to the handler, which checks dashboards:export.
+			dashboardRoute.Get("/uid/:uid/export", authorize(reqSignedIn, rbac.All()), routing.Wrap(hs.ExportDashboard))
 			dashboardRoute.Delete("/uid/:uid", routing.Wrap(hs.DeleteDashboardByUID))
 
 			dashboardRoute.Get("/db/:slug", routing.Wrap(hs.GetDashboard))
@@ -314,7 +331,7 @@ func (hs *HTTPServer) registerRoutes() {
 			dashboardRoute.Post("/db", bind(models.SaveDashboardCommand{}), routing.Wrap(hs.PostDashboard))
 			dashboardRoute.Get("/home", routing.Wrap(hs.GetHomeDashboard))
 			dashboardRoute.Get("/tags", GetDashboardTags)
-			dashboardRoute.Post("/import", bind(dtos.ImportDashboardCommand{}), routing.Wrap(ImportDashboard))
+			dashboardRoute.Post("/import", bind(dtos.ImportDashboardCommand{}), routing.Wrap(hs.ImportDashboard))
 
 			dashboardRoute.Group("/id/:dashboardId", func(dashIdRoute routing.RouteRegister) {
 				dashIdRoute.Get("/versions", routing.Wrap(GetDashboardVersions))
@@ -344,6 +361,34 @@ func (hs *HTTPServer) registerRoutes() {
 			playlistRoute.Post("/", reqEditorRole, bind(models.CreatePlaylistCommand{}), routing.Wrap(CreatePlaylist))
 		})
 
+		// RBAC reference, every signed in user may read it
+		apiRoute.Get("/access-control/reference", authorize(reqSignedIn, rbac.All()), routing.Wrap(GetAccessControlReference))
+		// Every signed in user may read the actions they may perform on resources
+		apiRoute.Get("/access-control/user/actions", authorize(reqSignedIn, rbac.All()), routing.Wrap(hs.GetResourceActions))
+		apiRoute.Get("/access-control/denials", authorize(reqOrgAdmin, rbac.Perm(rbac.ActionDenialsRead, "")), routing.Wrap(hs.SearchAccessDenials))
+		// Reading the break-glass elevations requires the audit:read action on the organization, checked by the handler
+		apiRoute.Get("/access-control/break-glass", authorize(reqOrgAdmin, rbac.All()), routing.Wrap(hs.GetBreakGlassGrants))
+		apiRoute.Post("/access-control/break-glass", authorize(reqSignedIn, rbac.Perm(rbac.ActionBreakGlassUse, "")), bind(dtos.BreakGlassForm{}), routing.Wrap(hs.BreakGlass))
+		apiRoute.Group("/access-control/policies", func(policiesRoute routing.RouteRegister) {
+			// Reading the orphaned policies requires the audit:read action on the organization, checked by the handler.
+			// It's registered before /:policyUID, which would match it otherwise.
+			policiesRoute.Get("/orphaned", authorize(reqOrgAdmin, rbac.All()), routing.Wrap(hs.GetOrphanedPolicies))
+			policiesRoute.Get("/", authorize(reqOrgAdmin, policiesRead), routing.Wrap(hs.GetPolicies))
+			policiesRoute.Get("/:policyUID", policyPerm(rbac.ActionPoliciesRead), routing.Wrap(hs.GetPolicy))
+			policiesRoute.Post("/", authorize(reqOrgAdmin, rbac.Perm(rbac.ActionPoliciesCreate, "")), bind(rbac.CreatePolicyCommand{}), routing.Wrap(hs.CreatePolicy))
+			policiesRoute.Put("/:policyUID", policyPerm(rbac.ActionPoliciesWrite), bind(rbac.UpdatePolicyCommand{}), routing.Wrap(hs.UpdatePolicy))
+			policiesRoute.Delete("/:policyUID", policyPerm(rbac.ActionPoliciesDelete), routing.Wrap(hs.DeletePolicy))
+		})
+		// Simulating access checks requires the audit:read action on the organization, checked by the handler
+		apiRoute.Post("/access-control/simulate", authorize(reqOrgAdmin, rbac.All()), bind(dtos.SimulateAccessForm{}), routing.Wrap(hs.SimulateAccess))
+		// Every signed in user may request policies, reviewing requires accessrequests:approve on the policy, checked by the handlers
+		apiRoute.Group("/access-control/requests", func(requestsRoute routing.RouteRegister) {
+			requestsRoute.Get("/", authorize(reqSignedIn, rbac.All()), routing.Wrap(hs.GetAccessRequests))
+			requestsRoute.Post("/", authorize(reqSignedIn, rbac.All()), bind(dtos.CreateAccessRequestForm{}), routing.Wrap(hs.CreateAccessRequest))
+			requestsRoute.Post("/:requestId/approve", authorize(reqSignedIn, rbac.All()), bind(dtos.ReviewAccessRequestForm{}), routing.Wrap(hs.ApproveAccessRequest))
+			requestsRoute.Post("/:requestId/deny", authorize(reqSignedIn, rbac.All()), bind(dtos.ReviewAccessRequestForm{}), routing.Wrap(hs.DenyAccessRequest))
+		})
+
 		// Search
 		apiRoute.Get("/search/sorting", routing.Wrap(hs.ListSortOptions))
 		apiRoute.Get("/search/", routing.Wrap(Search))
@@ -433,6 +478,9 @@ func (hs *HTTPServer) registerRoutes() {
 	// rendering
 	r.Get("/render/*", reqSignedIn, hs.RenderToPng)
 
+	// ext_authz of Envoy, authenticated with the token of the proxy
+	r.Any(extAuthzPrefix+"/*", routing.Wrap(hs.ExtAuthz))
+
 	// grafana.net proxy
 	r.Any("/api/gnet/*", reqSignedIn, ProxyGnetRequest)
 
@@ -19,6 +19,7 @@ import (
 	"github.com/grafana/grafana/pkg/infra/metrics"
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/services/guardian"
+	"github.com/grafana/grafana/pkg/services/rbac"
 	"github.com/grafana/grafana/pkg/util"
 )
 
@@ -47,6 +48,33 @@ func dashboardGuardianResponse(err error) response.Response {
 	return response.Error(403, "Access denied to this dashboard", nil)
 }
 
+// ExportDashboard returns the JSON model of a dashboard for export, provisioned dashboards included.
+// Viewing the dashboard is required and, when RBAC is enabled, dashboards:export on the dashboard or
+// its folder.
+func (hs *HTTPServer) ExportDashboard(c *models.ReqContext) response.Response {
+	dash, rsp := getDashboardHelper(c.OrgId, "", 0, c.Params(":uid"))
+	if rsp != nil {
+		return rsp
+	}
+
+	guardian := guardian.New(dash.Id, c.OrgId, c.SignedInUser)
+	if canView, err := guardian.CanView(); err != nil || !canView {
+		return dashboardGuardianResponse(err)
+	}
+	if hs.RBACService != nil && hs.RBACService.IsEnabled() {
+		// Legacy access control lets viewers export.
+		canExport, err := hs.RBACService.CanExportDashboard(hs.RBACService.RequestContext(c), c.SignedInUser, dash.Uid, dash.FolderId, true)
+		if err != nil {
+			return response.Error(500, "Failed to check dashboard export permission", err)
+		}
+		if !canExport {
+			return response.Error(403, "Access denied to export this dashboard", nil)
+		}
+	}
+
+	return response.JSON(200, dash.Data)
+}
+
 func (hs *HTTPServer) GetDashboard(c *models.ReqContext) response.Response {
 	slug := c.Params(":slug")
 	uid := c.Params(":uid")
@@ -144,6 +172,12 @@ func (hs *HTTPServer) GetDashboard(c *models.ReqContext) response.Response {
 		}
 	}
 
+	metadata, err := hs.getAccessControlMetadata(c, dashboardMetadataActions, rbac.ScopeDashboardUID(dash.Uid))
+	if err != nil {
+		return response.Error(500, "Failed to get access control metadata", err)
+	}
+	meta.AccessControl = metadata[rbac.ScopeDashboardUID(dash.Uid)]
+
 	// make sure db version is in sync with json model version
 	dash.Data.Set("version", dash.Version)
 
@@ -237,6 +271,7 @@ func (hs *HTTPServer) deleteDashboard(c *models.ReqContext) response.Response {
 
 		return response.Error(500, "Failed to delete dashboard", err)
 	}
+	hs.invalidateRBACScopeHierarchy()
 
 	return response.JSON(200, util.DynMap{
 		"title":   dash.Title,
@@ -292,6 +327,7 @@ func (hs *HTTPServer) PostDashboard(c *models.ReqContext, cmd models.SaveDashboa
 	if err != nil {
 		return dashboardSaveErrorToApiResponse(err)
 	}
+	hs.invalidateRBACScopeHierarchy()
 
 	if hs.Cfg.EditorsCanAdmin && newDashboard {
 		inFolder := cmd.FolderId > 0
@@ -18,6 +18,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/live"
 	"github.com/grafana/grafana/pkg/services/provisioning"
 	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/rbac"
 	"github.com/grafana/grafana/pkg/setting"
 	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
@@ -85,6 +86,7 @@ func TestDashboardAPIEndpoint(t *testing.T) {
 		setUp := func() *testState {
 			fakeDash := models.NewDashboard("Child dash")
 			fakeDash.Id = 1
+			fakeDash.Uid = "abcdefghi"
 			fakeDash.FolderId = 1
 			fakeDash.HasAcl = false
 
@@ -198,6 +200,40 @@ func TestDashboardAPIEndpoint(t *testing.T) {
 				})
 		})
 
+		t.Run("When RBAC is enabled", func(t *testing.T) {
+			ac := setupRBACTestService(t)
+			hs := &HTTPServer{Cfg: setting.NewCfg(), ProvisioningService: provisioning.NewProvisioningServiceMock(), RBACService: ac}
+
+			loggedInUserScenarioWithRole(t, "When calling GET without the export permission on", "GET", "/api/dashboards/uid/abcdefghi",
+				"/api/dashboards/uid/:uid", models.ROLE_VIEWER, func(sc *scenarioContext) {
+					setUp()
+
+					callGetDashboard(sc, hs)
+					assert.Equal(t, 200, sc.resp.Code)
+				})
+
+			loggedInUserScenarioWithRole(t, "When exporting without the export permission on", "GET", "/api/dashboards/uid/abcdefghi/export",
+				"/api/dashboards/uid/:uid/export", models.ROLE_VIEWER, func(sc *scenarioContext) {
+					setUp()
+
+					callExportDashboard(sc, hs)
+					assert.Equal(t, 403, sc.resp.Code)
+				})
+
+			for _, scope := range []string{rbac.ScopeDashboardUID("abcdefghi"), rbac.ScopeFolderID(1)} {
+				scope := scope
+				loggedInUserScenarioWithRole(t, "When exporting with the export permission on "+scope+" on", "GET", "/api/dashboards/uid/abcdefghi/export",
+					"/api/dashboards/uid/:uid/export", models.ROLE_VIEWER, func(sc *scenarioContext) {
+						setUp()
+						// The scenario resets the test database.
+						grantTestUser(t, ac, testOrgID, testUserID, rbac.CreatePermissionCommand{Action: rbac.ActionDashboardsExport, Scope: scope})
+
+						callExportDashboard(sc, hs)
+						assert.Equal(t, 200, sc.resp.Code)
+					})
+			}
+		})
+
 		t.Run("When user is an Org Editor", func(t *testing.T) {
 			role := models.ROLE_EDITOR
 
@@ -1121,6 +1157,11 @@ func callGetDashboard(sc *scenarioContext, hs *HTTPServer) {
 	sc.fakeReqWithParams("GET", sc.url, map[string]string{}).exec()
 }
 
+func callExportDashboard(sc *scenarioContext, hs *HTTPServer) {
+	sc.handlerFunc = hs.ExportDashboard
+	sc.fakeReqWithParams("GET", sc.url, map[string]string{}).exec()
+}
+
 func callGetDashboardVersion(sc *scenarioContext) {
 	bus.AddHandler("test", func(query *models.GetDashboardVersionQuery) error {
 		query.Result = &models.DashboardVersion{}
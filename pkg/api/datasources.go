@@ -15,6 +15,7 @@ import (
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/plugins/datasource/wrapper"
+	"github.com/grafana/grafana/pkg/services/rbac"
 	"github.com/grafana/grafana/pkg/util"
 )
 
@@ -57,6 +58,18 @@ func (hs *HTTPServer) GetDataSources(c *models.ReqContext) response.Response {
 
 	sort.Sort(result)
 
+	scopes := make([]string, 0, len(result))
+	for _, ds := range result {
+		scopes = append(scopes, rbac.ScopeDatasourceID(ds.Id))
+	}
+	metadata, err := hs.getAccessControlMetadata(c, datasourceMetadataActions, scopes...)
+	if err != nil {
+		return response.Error(500, "Failed to get access control metadata", err)
+	}
+	for i := range result {
+		result[i].AccessControl = metadata[rbac.ScopeDatasourceID(result[i].Id)]
+	}
+
 	return response.JSON(200, &result)
 }
 
@@ -0,0 +1,14 @@
+package dtos
+
+// CreateAccessRequestForm is the request for being bound to a policy.
+type CreateAccessRequestForm struct {
+	PolicyUID     string `json:"policyUid" binding:"Required"`
+	Justification string `json:"justification"`
+	// Duration makes the binding temporary once approved, e.g. 8h, permanent when empty.
+	Duration string `json:"duration"`
+}
+
+// ReviewAccessRequestForm is the request for approving or denying an access request.
+type ReviewAccessRequestForm struct {
+	Comment string `json:"comment"`
+}
@@ -0,0 +1,8 @@
+package dtos
+
+// BreakGlassForm is the request for elevating oneself to the break-glass policy.
+type BreakGlassForm struct {
+	// Duration is how long the elevation lasts, e.g. 30m, the maximum duration when empty.
+	Duration      string `json:"duration"`
+	Justification string `json:"justification"`
+}
@@ -31,21 +31,22 @@ type DataSource struct {
 }
 
 type DataSourceListItemDTO struct {
-	Id          int64            `json:"id"`
-	UID         string           `json:"uid"`
-	OrgId       int64            `json:"orgId"`
-	Name        string           `json:"name"`
-	Type        string           `json:"type"`
-	TypeLogoUrl string           `json:"typeLogoUrl"`
-	Access      models.DsAccess  `json:"access"`
-	Url         string           `json:"url"`
-	Password    string           `json:"password"`
-	User        string           `json:"user"`
-	Database    string           `json:"database"`
-	BasicAuth   bool             `json:"basicAuth"`
-	IsDefault   bool             `json:"isDefault"`
-	JsonData    *simplejson.Json `json:"jsonData,omitempty"`
-	ReadOnly    bool             `json:"readOnly"`
+	Id            int64            `json:"id"`
+	UID           string           `json:"uid"`
+	OrgId         int64            `json:"orgId"`
+	Name          string           `json:"name"`
+	Type          string           `json:"type"`
+	TypeLogoUrl   string           `json:"typeLogoUrl"`
+	Access        models.DsAccess  `json:"access"`
+	Url           string           `json:"url"`
+	Password      string           `json:"password"`
+	User          string           `json:"user"`
+	Database      string           `json:"database"`
+	BasicAuth     bool             `json:"basicAuth"`
+	IsDefault     bool             `json:"isDefault"`
+	JsonData      *simplejson.Json `json:"jsonData,omitempty"`
+	ReadOnly      bool             `json:"readOnly"`
+	AccessControl map[string]bool  `json:"accessControl,omitempty"`
 }
 
 type DataSourceList []DataSourceListItemDTO
@@ -0,0 +1,12 @@
+package dtos
+
+import "github.com/grafana/grafana/pkg/services/rbac"
+
+// SimulateAccessForm is the request for evaluating an access check of a user of the organization
+// against hypothetical changes to its policies.
+type SimulateAccessForm struct {
+	UserID  int64                 `json:"userId" binding:"Required"`
+	Action  string                `json:"action" binding:"Required"`
+	Scope   string                `json:"scope"`
+	Changes rbac.SimulatedChanges `json:"changes"`
+}
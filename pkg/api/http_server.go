@@ -37,6 +37,7 @@ import (
 	"github.com/grafana/grafana/pkg/services/login"
 	"github.com/grafana/grafana/pkg/services/provisioning"
 	"github.com/grafana/grafana/pkg/services/quota"
+	"github.com/grafana/grafana/pkg/services/rbac"
 	"github.com/grafana/grafana/pkg/services/rendering"
 	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/util/errutil"
@@ -81,6 +82,7 @@ type HTTPServer struct {
 	ContextHandler       *contexthandler.ContextHandler     `inject:""`
 	SQLStore             *sqlstore.SQLStore                 `inject:""`
 	LibraryPanelService  *librarypanels.LibraryPanelService `inject:""`
+	RBACService          *rbac.RBACService                  `inject:""`
 	Listener             net.Listener
 }
 
@@ -404,6 +406,9 @@ func (hs *HTTPServer) apiHealthHandler(ctx *macaron.Context) {
 		data.Set("version", setting.BuildVersion)
 		data.Set("commit", setting.BuildCommit)
 	}
+	if hs.RBACService != nil && hs.RBACService.IsDegraded() {
+		data.Set("rbac", "degraded")
+	}
 
 	if !hs.databaseHealthy() {
 		data.Set("database", "failing")
@@ -17,6 +17,7 @@ import (
 	"github.com/grafana/grafana/pkg/login"
 	"github.com/grafana/grafana/pkg/middleware/cookies"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rbac"
 	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/util"
 	"github.com/grafana/grafana/pkg/util/errutil"
@@ -269,6 +270,11 @@ func (hs *HTTPServer) loginUserWithUser(user *models.User, c *models.ReqContext)
 
 	hs.log.Info("Successful Login", "User", user.Email)
 	cookies.WriteSessionCookie(c, hs.Cfg, userToken.UnhashedToken, hs.Cfg.LoginMaxLifetime)
+	if hs.RBACService != nil && hs.RBACService.IsEnabled() {
+		hs.RBACService.IssueSessionPermissions(c, user.Id, user.OrgId)
+		// The request is done before the permissions are resolved, so they're resolved in the background.
+		go hs.RBACService.WarmUpUserPermissions(context.Background(), user.Id, user.OrgId)
+	}
 	return nil
 }
 
@@ -284,6 +290,9 @@ func (hs *HTTPServer) Logout(c *models.ReqContext) {
 	}
 
 	cookies.WriteSessionCookie(c, hs.Cfg, "", -1)
+	if c.GetCookie(rbac.SessionPermissionsCookieName) != "" {
+		cookies.DeleteCookie(c.Resp, rbac.SessionPermissionsCookieName, hs.CookieOptionsFromCfg)
+	}
 
 	if setting.SignoutRedirectUrl != "" {
 		c.Redirect(setting.SignoutRedirectUrl)
@@ -217,17 +217,19 @@ func buildExternalUserInfo(token *oauth2.Token, userInfo *social.BasicUserInfo,
 		Groups:     userInfo.Groups,
 	}
 
+	// The user will be assigned a role in either the auto-assigned organization or in the default one
+	orgID := int64(1)
+	if setting.AutoAssignOrg && setting.AutoAssignOrgId > 0 {
+		orgID = int64(setting.AutoAssignOrgId)
+	}
+
 	if userInfo.Role != "" {
 		rt := models.RoleType(userInfo.Role)
 		if rt.IsValid() {
-			// The user will be assigned a role in either the auto-assigned organization or in the default one
-			var orgID int64
 			if setting.AutoAssignOrg && setting.AutoAssignOrgId > 0 {
-				orgID = int64(setting.AutoAssignOrgId)
 				plog.Debug("The user has a role assignment and organization membership is auto-assigned",
 					"role", userInfo.Role, "orgId", orgID)
 			} else {
-				orgID = int64(1)
 				plog.Debug("The user has a role assignment and organization membership is not auto-assigned",
 					"role", userInfo.Role, "orgId", orgID)
 			}
@@ -235,6 +237,10 @@ func buildExternalUserInfo(token *oauth2.Token, userInfo *social.BasicUserInfo,
 		}
 	}
 
+	if info, ok := setting.OAuthService.OAuthInfos[name]; ok && len(info.PolicyMappings) > 0 {
+		extUser.PolicyUIDs = social.ResolvePolicyMappings(info.PolicyMappings, userInfo, orgID)
+	}
+
 	return extUser
 }
 
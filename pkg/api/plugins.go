@@ -243,11 +243,22 @@ func GetPluginMarkdown(c *models.ReqContext) response.Response {
 	return resp
 }
 
-func ImportDashboard(c *models.ReqContext, apiCmd dtos.ImportDashboardCommand) response.Response {
+func (hs *HTTPServer) ImportDashboard(c *models.ReqContext, apiCmd dtos.ImportDashboardCommand) response.Response {
 	if apiCmd.PluginId == "" && apiCmd.Dashboard == nil {
 		return response.Error(422, "Dashboard must be set", nil)
 	}
 
+	if hs.RBACService != nil && hs.RBACService.IsEnabled() {
+		// Legacy access control lets the folder permissions checked when saving the dashboard decide.
+		canImport, err := hs.RBACService.CanImportDashboard(hs.RBACService.RequestContext(c), c.SignedInUser, apiCmd.FolderId, true)
+		if err != nil {
+			return response.Error(500, "Failed to check dashboard import permission", err)
+		}
+		if !canImport {
+			return response.Error(403, "Access denied to import dashboards into this folder", nil)
+		}
+	}
+
 	cmd := plugins.ImportDashboardCommand{
 		OrgId:     c.OrgId,
 		User:      c.SignedInUser,
@@ -262,6 +273,7 @@ func ImportDashboard(c *models.ReqContext, apiCmd dtos.ImportDashboardCommand) r
 	if err := bus.Dispatch(&cmd); err != nil {
 		return dashboardSaveErrorToApiResponse(err)
 	}
+	hs.invalidateRBACScopeHierarchy()
 
 	return response.JSON(200, cmd.Result)
 }
@@ -0,0 +1,475 @@
+package api
+
+import (
+	"errors"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/api/dtos"
+	"github.com/grafana/grafana/pkg/api/response"
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rbac"
+)
+
+// Actions included in the access control metadata of each resource kind.
+var (
+	dashboardMetadataActions  = []string{rbac.ActionDashboardsRead, rbac.ActionDashboardsWrite, rbac.ActionDashboardsDelete}
+	datasourceMetadataActions = []string{rbac.ActionDatasourcesRead, rbac.ActionDatasourcesQuery, rbac.ActionDatasourcesWrite, rbac.ActionDatasourcesDelete}
+	teamMetadataActions       = []string{rbac.ActionTeamsRead, rbac.ActionTeamsWrite, rbac.ActionTeamsDelete}
+)
+
+// getAccessControlMetadata returns the access control metadata of the resources, keyed by scope.
+// It returns nil unless RBAC is enabled and the request has the accesscontrol query parameter set.
+func (hs *HTTPServer) getAccessControlMetadata(c *models.ReqContext, actions []string, scopes ...string) (map[string]rbac.Metadata, error) {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() || !c.QueryBool("accesscontrol") {
+		return nil, nil
+	}
+
+	return hs.RBACService.GetMetadata(hs.RBACService.RequestContext(c), c.SignedInUser, actions, scopes...)
+}
+
+// invalidateRBACScopeHierarchy must be called after dashboards are created, moved, retagged or
+// deleted so that folder and tag permissions apply to the current dashboards.
+func (hs *HTTPServer) invalidateRBACScopeHierarchy() {
+	if hs.RBACService != nil {
+		hs.RBACService.InvalidateScopeHierarchy()
+	}
+}
+
+// GetAccessControlReference returns the registered RBAC actions, scopes and fixed policies,
+// shown in the UI's help panel.
+func GetAccessControlReference(c *models.ReqContext) response.Response {
+	return response.JSON(200, rbac.GetReference())
+}
+
+// GetResourceActions returns the actions the signed in user may perform on each of the resources
+// of a type, keyed by uid, so that the UI can hide what the user can't use. The resource query
+// parameter is the resource type, e.g. dashboards, and the uid query parameter is repeated for
+// each resource.
+func (hs *HTTPServer) GetResourceActions(c *models.ReqContext) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	result, err := hs.RBACService.GetResourceActions(hs.RBACService.RequestContext(c), rbac.GetResourceActionsQuery{
+		User:     c.SignedInUser,
+		Resource: c.Query("resource"),
+		UIDs:     c.QueryStrings("uid"),
+	})
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to get resource actions")
+	}
+
+	return response.JSON(200, result)
+}
+
+// SearchAccessDenials returns the recent access denials of the current organization, most recent first.
+// The userId, action, scope, from and to query parameters filter the denials, from and to are epoch
+// milliseconds. At most limit denials are returned, 100 by default.
+func (hs *HTTPServer) SearchAccessDenials(c *models.ReqContext) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	query := rbac.SearchDenialsQuery{
+		OrgID:  c.OrgId,
+		UserID: c.QueryInt64("userId"),
+		Action: c.Query("action"),
+		Scope:  c.Query("scope"),
+		Limit:  c.QueryInt("limit"),
+	}
+	if from := c.QueryInt64("from"); from > 0 {
+		query.From = time.Unix(0, from*int64(time.Millisecond))
+	}
+	if to := c.QueryInt64("to"); to > 0 {
+		query.To = time.Unix(0, to*int64(time.Millisecond))
+	}
+	if query.Limit <= 0 {
+		query.Limit = 100
+	}
+
+	return response.JSON(200, hs.RBACService.SearchDenials(query))
+}
+
+// BreakGlass elevates the signed in user to the break-glass policy of the current organization for
+// the requested duration, e.g. 30m.
+func (hs *HTTPServer) BreakGlass(c *models.ReqContext, form dtos.BreakGlassForm) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	var duration time.Duration
+	if form.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(form.Duration); err != nil {
+			return response.Error(400, "Invalid duration", err)
+		}
+	}
+
+	grant, err := hs.RBACService.BreakGlass(c.Req.Context(), rbac.BreakGlassCommand{
+		OrgID:         c.OrgId,
+		UserID:        c.UserId,
+		Duration:      duration,
+		Justification: form.Justification,
+	})
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to elevate access")
+	}
+
+	return response.JSON(200, grant)
+}
+
+// GetBreakGlassGrants returns the break-glass elevations of the current organization, most recent first.
+// The userId query parameter filters them, at most limit elevations are returned, 100 by default.
+func (hs *HTTPServer) GetBreakGlassGrants(c *models.ReqContext) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	ctx := hs.RBACService.RequestContext(c)
+	allowed, err := hs.RBACService.CanReadAudit(ctx, c.SignedInUser)
+	if err != nil {
+		return response.Error(500, "Failed to authorize request", err)
+	}
+	if !allowed {
+		return response.Error(403, "Permission denied", nil)
+	}
+
+	query := rbac.GetBreakGlassGrantsQuery{OrgID: c.OrgId, UserID: c.QueryInt64("userId"), Limit: c.QueryInt("limit")}
+	if query.Limit <= 0 {
+		query.Limit = 100
+	}
+	grants, err := hs.RBACService.GetBreakGlassGrants(c.Req.Context(), query)
+	if err != nil {
+		return response.Error(500, "Failed to get break-glass elevations", err)
+	}
+
+	return response.JSON(200, grants)
+}
+
+// GetOrphanedPolicies returns the policies of the current organization that have no assignments or
+// no permissions. The unchangedForDays query parameter only reports the policies that haven't been
+// updated for that many days.
+func (hs *HTTPServer) GetOrphanedPolicies(c *models.ReqContext) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	ctx := hs.RBACService.RequestContext(c)
+	allowed, err := hs.RBACService.CanReadAudit(ctx, c.SignedInUser)
+	if err != nil {
+		return response.Error(500, "Failed to authorize request", err)
+	}
+	if !allowed {
+		return response.Error(403, "Permission denied", nil)
+	}
+
+	query := rbac.GetOrphanedPoliciesQuery{OrgID: c.OrgId}
+	if days := c.QueryInt("unchangedForDays"); days > 0 {
+		query.UnchangedFor = time.Duration(days) * 24 * time.Hour
+	}
+	result, err := hs.RBACService.GetOrphanedPolicies(c.Req.Context(), query)
+	if err != nil {
+		return response.Error(500, "Failed to get orphaned policies", err)
+	}
+
+	return response.JSON(200, result)
+}
+
+// GetPolicies returns the policies of the current organization, sorted by name.
+func (hs *HTTPServer) GetPolicies(c *models.ReqContext) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	policies, err := hs.RBACService.GetPolicies(c.Req.Context(), c.OrgId)
+	if err != nil {
+		return response.Error(500, "Failed to get policies", err)
+	}
+	if policies == nil {
+		policies = []*rbac.PolicyDTO{}
+	}
+
+	return response.JSON(200, policies)
+}
+
+// GetPolicy returns a policy of the current organization together with its permissions.
+func (hs *HTTPServer) GetPolicy(c *models.ReqContext) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	policy, err := hs.RBACService.GetPolicy(c.Req.Context(), rbac.GetPolicyQuery{OrgID: c.OrgId, UID: c.Params(":policyUID")})
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to get policy")
+	}
+
+	return response.JSON(200, policy)
+}
+
+// CreatePolicy adds a policy to the current organization, a uid is generated when the command has none.
+func (hs *HTTPServer) CreatePolicy(c *models.ReqContext, cmd rbac.CreatePolicyCommand) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	cmd.OrgID = c.OrgId
+	policy, err := hs.RBACService.CreatePolicy(c.Req.Context(), cmd)
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to create policy")
+	}
+
+	return response.JSON(200, policy)
+}
+
+// UpdatePolicy updates the name, uid, description and precedence of a policy of the current organization.
+func (hs *HTTPServer) UpdatePolicy(c *models.ReqContext, cmd rbac.UpdatePolicyCommand) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	existing, err := hs.RBACService.GetPolicy(c.Req.Context(), rbac.GetPolicyQuery{OrgID: c.OrgId, UID: c.Params(":policyUID")})
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to get policy")
+	}
+	cmd.ID = existing.ID
+	cmd.OrgID = c.OrgId
+	policy, err := hs.RBACService.UpdatePolicy(c.Req.Context(), cmd)
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to update policy")
+	}
+
+	return response.JSON(200, policy)
+}
+
+// DeletePolicy deletes a policy of the current organization together with its permissions and bindings.
+func (hs *HTTPServer) DeletePolicy(c *models.ReqContext) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	policy, err := hs.RBACService.GetPolicy(c.Req.Context(), rbac.GetPolicyQuery{OrgID: c.OrgId, UID: c.Params(":policyUID")})
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to get policy")
+	}
+	if err := hs.RBACService.DeletePolicy(c.Req.Context(), rbac.DeletePolicyCommand{ID: policy.ID, OrgID: c.OrgId}); err != nil {
+		return rbacErrorResponse(err, "Failed to delete policy")
+	}
+
+	return response.Success("Policy deleted")
+}
+
+// SimulateAccess evaluates an access check of a user of the current organization before and after
+// hypothetical changes to its policies and their bindings, without persisting anything.
+func (hs *HTTPServer) SimulateAccess(c *models.ReqContext, form dtos.SimulateAccessForm) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	ctx := hs.RBACService.RequestContext(c)
+	allowed, err := hs.RBACService.CanReadAudit(ctx, c.SignedInUser)
+	if err != nil {
+		return response.Error(500, "Failed to authorize request", err)
+	}
+	if !allowed {
+		return response.Error(403, "Permission denied", nil)
+	}
+
+	query := models.GetSignedInUserQuery{UserId: form.UserID, OrgId: c.OrgId}
+	if err := bus.Dispatch(&query); err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return response.Error(404, "User not found", err)
+		}
+		return response.Error(500, "Failed to get user", err)
+	}
+	if query.Result.OrgId != c.OrgId {
+		return response.Error(404, "User not found", nil)
+	}
+
+	result, err := hs.RBACService.SimulateAccess(c.Req.Context(), rbac.SimulateAccessCommand{
+		User:    query.Result,
+		Action:  form.Action,
+		Scope:   form.Scope,
+		Changes: form.Changes,
+	})
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to simulate access")
+	}
+
+	return response.JSON(200, result)
+}
+
+// CreateAccessRequest requests the policy of the form for the signed in user, the duration, e.g. 8h,
+// makes the binding temporary once approved.
+func (hs *HTTPServer) CreateAccessRequest(c *models.ReqContext, form dtos.CreateAccessRequestForm) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	var duration time.Duration
+	if form.Duration != "" {
+		var err error
+		if duration, err = time.ParseDuration(form.Duration); err != nil {
+			return response.Error(400, "Invalid duration", err)
+		}
+	}
+
+	policy, err := hs.RBACService.GetPolicy(c.Req.Context(), rbac.GetPolicyQuery{OrgID: c.OrgId, UID: form.PolicyUID})
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to get policy")
+	}
+	request, err := hs.RBACService.CreateAccessRequest(c.Req.Context(), rbac.CreateAccessRequestCommand{
+		OrgID:         c.OrgId,
+		UserID:        c.UserId,
+		PolicyID:      policy.ID,
+		Justification: form.Justification,
+		Duration:      duration,
+	})
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to request access")
+	}
+
+	return response.JSON(200, request)
+}
+
+// GetAccessRequests returns the access requests of the signed in user along with the ones they may
+// review, most recent first. The state query parameter filters them, e.g. pending.
+func (hs *HTTPServer) GetAccessRequests(c *models.ReqContext) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	requests, err := hs.RBACService.GetAccessRequests(c.Req.Context(), rbac.GetAccessRequestsQuery{OrgID: c.OrgId, State: c.Query("state")})
+	if err != nil {
+		return response.Error(500, "Failed to get access requests", err)
+	}
+
+	reviewable, err := hs.RBACService.ReviewableAccessRequests(hs.RBACService.RequestContext(c), c.SignedInUser, requests)
+	if err != nil {
+		return response.Error(500, "Failed to authorize request", err)
+	}
+	canReview := make(map[int64]bool, len(reviewable))
+	for _, request := range reviewable {
+		canReview[request.ID] = true
+	}
+
+	visible := make([]*rbac.AccessRequest, 0, len(requests))
+	for _, request := range requests {
+		if request.UserID == c.UserId || canReview[request.ID] {
+			visible = append(visible, request)
+		}
+	}
+
+	return response.JSON(200, visible)
+}
+
+// ApproveAccessRequest approves an access request, binding its policy to the requester.
+func (hs *HTTPServer) ApproveAccessRequest(c *models.ReqContext, form dtos.ReviewAccessRequestForm) response.Response {
+	return hs.reviewAccessRequest(c, form, true)
+}
+
+// DenyAccessRequest denies an access request.
+func (hs *HTTPServer) DenyAccessRequest(c *models.ReqContext, form dtos.ReviewAccessRequestForm) response.Response {
+	return hs.reviewAccessRequest(c, form, false)
+}
+
+func (hs *HTTPServer) reviewAccessRequest(c *models.ReqContext, form dtos.ReviewAccessRequestForm, approve bool) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+
+	request, err := hs.RBACService.GetAccessRequest(c.Req.Context(), c.OrgId, c.ParamsInt64(":requestId"))
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to get access request")
+	}
+	allowed, err := hs.RBACService.CanReviewAccessRequest(hs.RBACService.RequestContext(c), c.SignedInUser, request)
+	if err != nil {
+		return response.Error(500, "Failed to authorize request", err)
+	}
+	if !allowed {
+		return response.Error(403, "Permission denied", nil)
+	}
+
+	request, err = hs.RBACService.ReviewAccessRequest(c.Req.Context(), rbac.ReviewAccessRequestCommand{
+		OrgID:      c.OrgId,
+		ID:         request.ID,
+		ReviewedBy: c.UserId,
+		Approve:    approve,
+		Comment:    form.Comment,
+	})
+	if err != nil {
+		return rbacErrorResponse(err, "Failed to review access request")
+	}
+
+	return response.JSON(200, request)
+}
+
+// rbacErrorStatus are the HTTP status codes of the kinds of errors returned by the RBAC service.
+var rbacErrorStatus = map[rbac.ErrorKind]int{
+	rbac.ErrorKindValidation:     400,
+	rbac.ErrorKindNotFound:       404,
+	rbac.ErrorKindConflict:       409,
+	rbac.ErrorKindQuota:          400,
+	rbac.ErrorKindSchemaOutdated: 503,
+}
+
+// rbacErrorResponse returns the response of an error returned by the RBAC service, the error is
+// shown to the user unless it's internal.
+func rbacErrorResponse(err error, message string) response.Response {
+	status, ok := rbacErrorStatus[rbac.ErrorKindOf(err)]
+	if !ok {
+		return response.Error(500, message, err)
+	}
+	return response.Error(status, err.Error(), err)
+}
+
+// extAuthzPrefix is the prefix of the ext_authz endpoint, the rest of the path is the one of the
+// request to authorize, as set by the path_prefix of the ext_authz HTTP service of Envoy.
+const extAuthzPrefix = "/api/access-control/ext-authz"
+
+// ExtAuthz answers the authorization requests of the ext_authz HTTP filter of Envoy with the policies
+// of Grafana: 200 when the request is allowed and 403 otherwise, which the proxy returns to the client.
+// The proxy authenticates with the rbac.ext_authz_token setting, and sends the login of the user it
+// authenticated and optionally the organization in the headers of the rbac.ext_authz_user_header and
+// rbac.ext_authz_org_header settings. Since Grafana authenticates the Authorization header of the
+// requests, the proxy must not forward credentials that Grafana doesn't accept. Source network
+// conditions are evaluated with the client address of the X-Forwarded-For header the proxy forwards.
+func (hs *HTTPServer) ExtAuthz(c *models.ReqContext) response.Response {
+	if hs.RBACService == nil || !hs.RBACService.IsEnabled() {
+		return response.Error(404, "RBAC is not enabled", nil)
+	}
+	userHeader, orgHeader, err := hs.RBACService.ExtAuthzHeaders()
+	if err != nil {
+		return response.Error(404, "ext_authz is not enabled", nil)
+	}
+
+	req := rbac.ExtAuthzRequest{
+		Token:        c.Req.Header.Get(rbac.ExtAuthzTokenHeader),
+		Method:       c.Req.Method,
+		Path:         "/" + strings.TrimPrefix(strings.TrimPrefix(c.Req.URL.Path, extAuthzPrefix), "/"),
+		Login:        c.Req.Header.Get(userHeader),
+		ForwardedFor: c.Req.Header.Values("X-Forwarded-For"),
+	}
+	if org := c.Req.Header.Get(orgHeader); org != "" {
+		if req.OrgID, err = strconv.ParseInt(org, 10, 64); err != nil || req.OrgID <= 0 {
+			return response.Error(400, "Invalid organization header", nil)
+		}
+	}
+
+	decision, err := hs.RBACService.AuthorizeExtAuthz(hs.RBACService.RequestContext(c), req)
+	switch {
+	case errors.Is(err, rbac.ErrExtAuthzDisabled):
+		return response.Error(404, "ext_authz is not enabled", nil)
+	case errors.Is(err, rbac.ErrExtAuthzUnauthenticated):
+		return response.Error(401, err.Error(), nil)
+	case err != nil:
+		return response.Error(500, "Failed to authorize request", err)
+	}
+	if !decision.Allowed {
+		return response.Error(403, "Permission denied: "+decision.Reason, nil)
+	}
+
+	return response.Empty(200)
+}
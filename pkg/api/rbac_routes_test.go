@@ -0,0 +1,145 @@
+package api
+
+import (
+	"bufio"
+	"net/http/httptest"
+	"os"
+	"path/filepath"
+	"sort"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	macaron "gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/middleware"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// routesWithoutRBACFile lists the API routes that don't declare an RBAC requirement yet, one
+// "<METHOD> <pattern>" per line. New API routes must use middleware.Authorize instead of being added to it.
+const routesWithoutRBACFile = "testdata/routes_without_rbac.txt"
+
+// recordingRouter records whether each route declares an RBAC requirement, and its handlers.
+type recordingRouter struct {
+	routes   map[string]bool
+	handlers map[string][]macaron.Handler
+}
+
+func (r *recordingRouter) Handle(method, pattern string, handlers []macaron.Handler) *macaron.Route {
+	authorized := false
+	for _, h := range handlers {
+		if _, ok := h.(middleware.AuthorizeHandler); ok {
+			authorized = true
+		}
+	}
+	r.routes[method+" "+pattern] = authorized
+	if r.handlers != nil {
+		r.handlers[method+" "+pattern] = handlers
+	}
+	return nil
+}
+
+func (r *recordingRouter) Get(pattern string, handlers ...macaron.Handler) *macaron.Route {
+	return r.Handle("GET", pattern, handlers)
+}
+
+func TestAPIRoutesDeclareRBACRequirement(t *testing.T) {
+	hs := &HTTPServer{Cfg: setting.NewCfg(), RouteRegister: routing.NewRouteRegister()}
+	hs.registerRoutes()
+	router := &recordingRouter{routes: map[string]bool{}}
+	hs.RouteRegister.Register(router)
+
+	allowed := readRoutesWithoutRBAC(t)
+
+	var missing, obsolete []string
+	for route, authorized := range router.routes {
+		if !strings.Contains(route, " /api/") {
+			continue
+		}
+		if !authorized && !allowed[route] {
+			missing = append(missing, route)
+		}
+	}
+	for route := range allowed {
+		if authorized, ok := router.routes[route]; !ok || authorized {
+			obsolete = append(obsolete, route)
+		}
+	}
+	sort.Strings(missing)
+	sort.Strings(obsolete)
+
+	assert.Empty(t, missing, "API routes must declare an RBAC requirement with middleware.Authorize")
+	assert.Empty(t, obsolete, "routes that were removed or now declare an RBAC requirement must be removed from %s", routesWithoutRBACFile)
+}
+
+// The server users aren't part of any organization, so the policies of an organization must not grant
+// access to them without the Grafana admin role. The routes are run as an organization admin whom RBAC
+// allows, i.e. without their RBAC requirement, and must still be forbidden.
+func TestServerUserRoutesRequireGrafanaAdmin(t *testing.T) {
+	hs := &HTTPServer{Cfg: setting.NewCfg(), RouteRegister: routing.NewRouteRegister()}
+	hs.registerRoutes()
+	router := &recordingRouter{routes: map[string]bool{}, handlers: map[string][]macaron.Handler{}}
+	hs.RouteRegister.Register(router)
+
+	checked := 0
+	for route, handlers := range router.handlers {
+		method, pattern := route[:strings.Index(route, " ")], route[strings.Index(route, " ")+1:]
+		if !strings.HasPrefix(pattern, "/api/users") {
+			continue
+		}
+		checked++
+
+		m := macaron.New()
+		m.Use(macaron.Renderer())
+		m.Use(func(c *macaron.Context) {
+			c.Map(&models.ReqContext{
+				Context:      c,
+				IsSignedIn:   true,
+				SignedInUser: &models.SignedInUser{UserId: 2, OrgId: 1, OrgRole: models.ROLE_ADMIN},
+				Logger:       log.New("test"),
+			})
+		})
+		var middlewares []macaron.Handler
+		for _, h := range handlers[:len(handlers)-1] {
+			if _, ok := h.(middleware.AuthorizeHandler); ok {
+				continue
+			}
+			if _, ok := h.(func(*models.ReqContext)); ok {
+				middlewares = append(middlewares, h)
+			}
+		}
+		m.Handle(method, pattern, append(middlewares, func(c *macaron.Context) { c.Resp.WriteHeader(200) }))
+
+		path := strings.NewReplacer(":id", "1", ":orgId", "1").Replace(pattern)
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, httptest.NewRequest(method, path, nil))
+		assert.Equal(t, 403, recorder.Code, "%s must require the Grafana admin role", route)
+	}
+	assert.NotZero(t, checked)
+}
+
+func readRoutesWithoutRBAC(t *testing.T) map[string]bool {
+	t.Helper()
+
+	f, err := os.Open(filepath.Clean(routesWithoutRBACFile))
+	require.NoError(t, err)
+	defer func() { require.NoError(t, f.Close()) }()
+
+	routes := map[string]bool{}
+	scanner := bufio.NewScanner(f)
+	for scanner.Scan() {
+		line := strings.TrimSpace(scanner.Text())
+		if line == "" || strings.HasPrefix(line, "#") {
+			continue
+		}
+		routes[line] = true
+	}
+	require.NoError(t, scanner.Err())
+
+	return routes
+}
@@ -0,0 +1,183 @@
+package api
+
+import (
+	"context"
+	"fmt"
+	"math"
+	"net/http/httptest"
+	"os"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	macaron "gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/api/routing"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/rbac"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestMain(m *testing.M) {
+	// The test database is created once for all the tests of the package, by whichever test needs it
+	// first, and the RBAC tables are only migrated when the registered service has the feature
+	// toggle enabled.
+	registry.RegisterOverride(func(d registry.Descriptor) (*registry.Descriptor, bool) {
+		if d.Name != "RBACService" {
+			return nil, false
+		}
+		return &registry.Descriptor{Name: "RBACService", Instance: &rbac.RBACService{Cfg: rbacTestCfg()}}, true
+	})
+
+	os.Exit(m.Run())
+}
+
+func rbacTestCfg() *setting.Cfg {
+	cfg := setting.NewCfg()
+	cfg.FeatureToggles = map[string]bool{"rbac": true}
+	return cfg
+}
+
+// setupRBACTestService returns an enabled RBACService using the test database.
+func setupRBACTestService(t *testing.T) *rbac.RBACService {
+	t.Helper()
+
+	ac := &rbac.RBACService{Cfg: rbacTestCfg(), SQLStore: sqlstore.InitTestDB(t)}
+	require.NoError(t, ac.Init())
+	require.True(t, ac.IsEnabled())
+
+	return ac
+}
+
+// grantTestUser creates a policy with the permissions in the organization and binds it to the user.
+func grantTestUser(t *testing.T, ac *rbac.RBACService, orgID, userID int64, permissions ...rbac.CreatePermissionCommand) *rbac.Policy {
+	t.Helper()
+
+	policy, err := ac.CreatePolicy(context.Background(), rbac.CreatePolicyCommand{OrgID: orgID, Name: fmt.Sprintf("%s user %d", t.Name(), userID)})
+	require.NoError(t, err)
+	for _, cmd := range permissions {
+		cmd.OrgID = orgID
+		cmd.PolicyID = policy.ID
+		_, err := ac.CreatePermission(context.Background(), cmd)
+		require.NoError(t, err)
+	}
+	require.NoError(t, ac.AddUserPolicy(context.Background(), rbac.AddUserPolicyCommand{OrgID: orgID, UserID: userID, PolicyID: policy.ID}))
+
+	return policy
+}
+
+func TestPolicyRoutes(t *testing.T) {
+	ac := setupRBACTestService(t)
+	hs := &HTTPServer{Cfg: setting.NewCfg(), RouteRegister: routing.NewRouteRegister(), RBACService: ac}
+	hs.registerRoutes()
+	router := &recordingRouter{routes: map[string]bool{}, handlers: map[string][]macaron.Handler{}}
+	hs.RouteRegister.Register(router)
+
+	var user *models.SignedInUser
+	m := macaron.New()
+	m.Use(macaron.Renderer())
+	m.Use(func(c *macaron.Context) {
+		c.Map(&models.ReqContext{Context: c, IsSignedIn: true, SignedInUser: user, Logger: log.New("test")})
+	})
+	for route, handlers := range router.handlers {
+		method, pattern := route[:strings.Index(route, " ")], route[strings.Index(route, " ")+1:]
+		if strings.HasPrefix(pattern, "/api/access-control/policies") {
+			m.Handle(method, pattern, handlers)
+		}
+	}
+	request := func(t *testing.T, u *models.SignedInUser, method, path, body string) *httptest.ResponseRecorder {
+		t.Helper()
+		user = u
+		req := httptest.NewRequest(method, "/api/access-control/policies"+path, strings.NewReader(body))
+		req.Header.Set("Content-Type", "application/json")
+		recorder := httptest.NewRecorder()
+		m.ServeHTTP(recorder, req)
+		return recorder
+	}
+
+	all := func(scope string) []rbac.CreatePermissionCommand {
+		var permissions []rbac.CreatePermissionCommand
+		for _, action := range []string{rbac.ActionPoliciesRead, rbac.ActionPoliciesWrite, rbac.ActionPoliciesDelete} {
+			permissions = append(permissions, rbac.CreatePermissionCommand{Action: action, Scope: scope})
+		}
+		return permissions
+	}
+	admin := &models.SignedInUser{UserId: 2, OrgId: 1, OrgRole: models.ROLE_VIEWER}
+	grantTestUser(t, ac, 1, admin.UserId, append(all("policies:*"), rbac.CreatePermissionCommand{Action: rbac.ActionPoliciesCreate})...)
+	otherOrgAdmin := &models.SignedInUser{UserId: 3, OrgId: 2, OrgRole: models.ROLE_VIEWER}
+	grantTestUser(t, ac, 2, otherOrgAdmin.UserId, append(all("policies:*"), rbac.CreatePermissionCommand{Action: rbac.ActionPoliciesCreate})...)
+	owner := &models.SignedInUser{UserId: 4, OrgId: 1, OrgRole: models.ROLE_VIEWER}
+	grantTestUser(t, ac, 1, owner.UserId, all(rbac.ScopePolicyUID("mine"))...)
+	nobody := &models.SignedInUser{UserId: 5, OrgId: 1, OrgRole: models.ROLE_VIEWER}
+
+	for _, uid := range []string{"mine", "other"} {
+		res := request(t, admin, "POST", "/", `{"uid": "`+uid+`", "name": "`+uid+`"}`)
+		require.Equal(t, 200, res.Code, res.Body.String())
+	}
+
+	t.Run("Each route should require its action", func(t *testing.T) {
+		for _, r := range []struct{ method, path, body string }{
+			{"GET", "/", ""},
+			{"GET", "/mine", ""},
+			{"POST", "/", `{"name": "denied"}`},
+			{"PUT", "/mine", `{"name": "denied"}`},
+			{"DELETE", "/mine", ""},
+		} {
+			assert.Equal(t, 403, request(t, nobody, r.method, r.path, r.body).Code, "%s %s", r.method, r.path)
+		}
+	})
+
+	t.Run("The routes of a policy should be scoped to its uid", func(t *testing.T) {
+		assert.Equal(t, 200, request(t, owner, "GET", "/mine", "").Code)
+		assert.Equal(t, 200, request(t, owner, "PUT", "/mine", `{"uid": "mine", "name": "renamed"}`).Code)
+		assert.Equal(t, 403, request(t, owner, "GET", "/", "").Code)
+		assert.Equal(t, 403, request(t, owner, "GET", "/other", "").Code)
+		assert.Equal(t, 403, request(t, owner, "PUT", "/other", `{"uid": "other", "name": "taken"}`).Code)
+		assert.Equal(t, 403, request(t, owner, "DELETE", "/other", "").Code)
+	})
+
+	t.Run("Unknown policies should not be found", func(t *testing.T) {
+		assert.Equal(t, 404, request(t, admin, "GET", "/missing", "").Code)
+		assert.Equal(t, 404, request(t, admin, "PUT", "/missing", `{"name": "missing"}`).Code)
+		assert.Equal(t, 404, request(t, admin, "DELETE", "/missing", "").Code)
+	})
+
+	t.Run("Duplicate policies should conflict", func(t *testing.T) {
+		assert.Equal(t, 409, request(t, admin, "POST", "/", `{"uid": "other", "name": "duplicate"}`).Code)
+	})
+
+	t.Run("Policies of other organizations should not be found", func(t *testing.T) {
+		assert.Equal(t, 404, request(t, otherOrgAdmin, "GET", "/other", "").Code)
+		assert.Equal(t, 404, request(t, otherOrgAdmin, "PUT", "/other", `{"uid": "other", "name": "hijacked"}`).Code)
+		assert.Equal(t, 404, request(t, otherOrgAdmin, "DELETE", "/other", "").Code)
+
+		res := request(t, otherOrgAdmin, "GET", "/", "")
+		require.Equal(t, 200, res.Code)
+		assert.NotContains(t, res.Body.String(), `"uid":"other"`)
+		assert.Equal(t, 200, request(t, admin, "GET", "/other", "").Code)
+	})
+
+	t.Run("Managed policies should be refused", func(t *testing.T) {
+		_, err := ac.SuspendUserAccess(context.Background(), rbac.SuspendUserAccessCommand{OrgID: 1, UserID: nobody.UserId})
+		require.NoError(t, err)
+
+		assert.Equal(t, 400, request(t, admin, "POST", "/", `{"uid": "managed-fake", "name": "fake"}`).Code)
+		assert.Equal(t, 400, request(t, admin, "PUT", "/other", `{"uid": "managed-fake", "name": "other"}`).Code)
+		assert.Equal(t, 400, request(t, admin, "PUT", "/managed-suspended", `{"name": "renamed", "precedence": null}`).Code)
+		assert.Equal(t, 400, request(t, admin, "DELETE", "/managed-suspended", "").Code)
+
+		res := request(t, admin, "GET", "/managed-suspended", "")
+		require.Equal(t, 200, res.Code)
+		assert.Contains(t, res.Body.String(), fmt.Sprintf(`"precedence":%d`, math.MaxInt32))
+		assert.Equal(t, 200, request(t, admin, "GET", "/other", "").Code)
+	})
+
+	t.Run("Policies should be deleted", func(t *testing.T) {
+		assert.Equal(t, 200, request(t, owner, "DELETE", "/mine", "").Code)
+		assert.Equal(t, 404, request(t, admin, "GET", "/mine", "").Code)
+	})
+}
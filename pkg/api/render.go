@@ -10,6 +10,7 @@ import (
 	"time"
 
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rbac"
 	"github.com/grafana/grafana/pkg/services/rendering"
 	"github.com/grafana/grafana/pkg/util"
 )
@@ -59,7 +60,9 @@ func (hs *HTTPServer) RenderToPng(c *models.ReqContext) {
 		Timeout:           time.Duration(timeout) * time.Second,
 		OrgId:             c.OrgId,
 		UserId:            c.UserId,
+		ApiKeyId:          c.ApiKeyId,
 		OrgRole:           c.OrgRole,
+		IsGrafanaAdmin:    c.IsGrafanaAdmin,
 		Path:              c.Params("*") + queryParams,
 		Timezone:          queryReader.Get("tz", ""),
 		Encoding:          queryReader.Get("encoding", ""),
@@ -68,6 +71,10 @@ func (hs *HTTPServer) RenderToPng(c *models.ReqContext) {
 		Headers:           headers,
 	})
 	if err != nil {
+		if errors.Is(err, rbac.ErrAPIKeyDelegationRefused) {
+			c.Handle(hs.Cfg, 403, "Rendering is not available for API keys with policies", err)
+			return
+		}
 		if errors.Is(err, rendering.ErrTimeout) {
 			c.Handle(hs.Cfg, 500, err.Error(), err)
 			return
@@ -7,6 +7,7 @@ import (
 	"github.com/grafana/grafana/pkg/api/response"
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rbac"
 	"github.com/grafana/grafana/pkg/services/teamguardian"
 	"github.com/grafana/grafana/pkg/util"
 )
@@ -19,7 +20,24 @@ func (hs *HTTPServer) CreateTeam(c *models.ReqContext, cmd models.CreateTeamComm
 		return response.Error(403, "Not allowed to create team.", nil)
 	}
 
-	if err := hs.Bus.Dispatch(&cmd); err != nil {
+	var folder *models.Dashboard
+	if hs.RBACService != nil && hs.RBACService.ProvisionsTeamFolders() {
+		result, err := hs.RBACService.CreateTeamWithFolder(c.Req.Context(), rbac.CreateTeamWithFolderCommand{
+			OrgID: cmd.OrgId, Name: cmd.Name, Email: cmd.Email, UserID: c.UserId,
+		})
+		if err != nil {
+			if errors.Is(err, models.ErrTeamNameTaken) {
+				return response.Error(409, "Team name taken", err)
+			}
+			if errors.Is(err, models.ErrFolderSameNameExists) {
+				return response.Error(409, "A folder or dashboard with the team name already exists", err)
+			}
+			return response.Error(500, "Failed to create Team", err)
+		}
+		cmd.Result = result.Team
+		folder = result.Folder
+		hs.invalidateRBACScopeHierarchy()
+	} else if err := hs.Bus.Dispatch(&cmd); err != nil {
 		if errors.Is(err, models.ErrTeamNameTaken) {
 			return response.Error(409, "Team name taken", err)
 		}
@@ -46,10 +64,14 @@ func (hs *HTTPServer) CreateTeam(c *models.ReqContext, cmd models.CreateTeamComm
 		}
 	}
 
-	return response.JSON(200, &util.DynMap{
+	body := util.DynMap{
 		"teamId":  cmd.Result.Id,
 		"message": "Team created",
-	})
+	}
+	if folder != nil {
+		body["folderUid"] = folder.Uid
+	}
+	return response.JSON(200, &body)
 }
 
 // PUT /api/teams/:teamId
@@ -121,8 +143,18 @@ func (hs *HTTPServer) SearchTeams(c *models.ReqContext) response.Response {
 		return response.Error(500, "Failed to search Teams", err)
 	}
 
+	scopes := make([]string, 0, len(query.Result.Teams))
 	for _, team := range query.Result.Teams {
 		team.AvatarUrl = dtos.GetGravatarUrlWithDefault(team.Email, team.Name)
+		scopes = append(scopes, rbac.ScopeTeamID(team.Id))
+	}
+
+	metadata, err := hs.getAccessControlMetadata(c, teamMetadataActions, scopes...)
+	if err != nil {
+		return response.Error(500, "Failed to get access control metadata", err)
+	}
+	for _, team := range query.Result.Teams {
+		team.AccessControl = metadata[rbac.ScopeTeamID(team.Id)]
 	}
 
 	query.Result.Page = page
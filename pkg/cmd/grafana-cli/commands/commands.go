@@ -106,6 +106,12 @@ var pluginCommands = []*cli.Command{
 	},
 }
 
+// rbacJSONFlag makes the rbac commands write their report, or their error, as JSON.
+var rbacJSONFlag = &cli.BoolFlag{
+	Name:  "json",
+	Usage: "Write the report, or the error along with its kind and exit code, as JSON",
+}
+
 var adminCommands = []*cli.Command{
 	{
 		Name:   "reset-admin-password",
@@ -130,6 +136,84 @@ var adminCommands = []*cli.Command{
 			},
 		},
 	},
+	{
+		Name:  "rbac",
+		Usage: "Role based access control commands",
+		Subcommands: []*cli.Command{
+			{
+				Name:   "check-compatibility",
+				Usage:  "Reports whether the RBAC database schema is compatible with this version of Grafana. Safe to execute multiple times.",
+				Action: runRBACCommand(rbacCheckCompatibilityCommand),
+				Flags:  []cli.Flag{rbacJSONFlag},
+			},
+			{
+				Name:   "replay-decisions",
+				Usage:  "replay-decisions <decision log> replays a decision log against the current policies, or a draft of them, and reports the decisions that change.",
+				Action: runRBACCommand(rbacReplayDecisionsCommand),
+				Flags: []cli.Flag{
+					&cli.StringFlag{
+						Name:  "draft",
+						Usage: "JSON file holding the draft policies, replacing the permissions of the policies with the same uid",
+					},
+					rbacJSONFlag,
+				},
+			},
+			{
+				Name:   "export",
+				Usage:  "export <file> writes a point-in-time export of the RBAC state, or with --verify compares the RBAC state with an export table by table.",
+				Action: runRBACCommand(rbacExportCommand),
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "verify",
+						Usage: "Compare the RBAC state with the export instead of writing it, e.g. after restoring it onto a standby instance",
+					},
+					rbacJSONFlag,
+				},
+			},
+			{
+				Name:   "export-opa-bundle",
+				Usage:  "export-opa-bundle <file> writes the policies and bindings as an Open Policy Agent bundle, along with a reference Rego module.",
+				Action: runRBACCommand(rbacExportOPABundleCommand),
+				Flags: []cli.Flag{
+					&cli.IntFlag{
+						Name:  "org-id",
+						Usage: "Only export the policies and bindings of this organization",
+					},
+					rbacJSONFlag,
+				},
+			},
+			{
+				Name:   "restore",
+				Usage:  "restore <file> replaces the RBAC state with an export taken at the same schema version.",
+				Action: runRBACCommand(rbacRestoreCommand),
+				Flags:  []cli.Flag{rbacJSONFlag},
+			},
+			{
+				Name:   "enforcement-mode",
+				Usage:  "enforcement-mode [<action> <disabled|log|enforce>] lists the enforcement modes of the actions, or sets the mode of an action or wildcard pattern such as dashboards:*.",
+				Action: runRBACCommand(rbacEnforcementModeCommand),
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "remove",
+						Usage: "Remove the mode of the action, which then takes the mode of the next most specific pattern",
+					},
+					rbacJSONFlag,
+				},
+			},
+			{
+				Name:   "repair",
+				Usage:  "Deletes the RBAC bindings and policies left behind by deleted users, teams and organizations. Safe to execute multiple times.",
+				Action: runRBACCommand(rbacRepairCommand),
+				Flags: []cli.Flag{
+					&cli.BoolFlag{
+						Name:  "dry-run",
+						Usage: "Report the rows to delete without deleting them",
+					},
+					rbacJSONFlag,
+				},
+			},
+		},
+	},
 }
 
 var Commands = []*cli.Command{
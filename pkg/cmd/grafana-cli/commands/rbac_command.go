@@ -0,0 +1,435 @@
+package commands
+
+import (
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+	"time"
+
+	"github.com/fatih/color"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/logger"
+	"github.com/grafana/grafana/pkg/cmd/grafana-cli/utils"
+	"github.com/grafana/grafana/pkg/services/rbac"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/util/errutil"
+	"github.com/urfave/cli/v2"
+)
+
+// rbacExitCodes are the exit codes of the rbac commands for each kind of error, so that scripts
+// can branch on the failure reason.
+var rbacExitCodes = map[rbac.ErrorKind]int{
+	rbac.ErrorKindInternal:       1,
+	rbac.ErrorKindValidation:     2,
+	rbac.ErrorKindNotFound:       3,
+	rbac.ErrorKindConflict:       4,
+	rbac.ErrorKindQuota:          5,
+	rbac.ErrorKindSchemaOutdated: 6,
+}
+
+// rbacInputError is an error for invalid arguments or input files of an rbac command.
+type rbacInputError struct {
+	err error
+}
+
+func (e rbacInputError) Error() string {
+	return e.err.Error()
+}
+
+func (e rbacInputError) Unwrap() error {
+	return e.err
+}
+
+// rbacErrorOutput is the error written by the rbac commands when run with --json.
+type rbacErrorOutput struct {
+	Error struct {
+		Kind     rbac.ErrorKind `json:"kind"`
+		Message  string         `json:"message"`
+		ExitCode int            `json:"exitCode"`
+	} `json:"error"`
+}
+
+// runRBACCommand runs an rbac command and exits with the code of the kind of error it fails with,
+// writing the error as JSON when the --json flag is set.
+func runRBACCommand(command func(commandLine utils.CommandLine, sqlStore *sqlstore.SQLStore) error) func(context *cli.Context) error {
+	run := runDbCommand(command)
+	return func(context *cli.Context) error {
+		return rbacExit(os.Stdout, context.Bool("json"), run(context))
+	}
+}
+
+func rbacExit(w io.Writer, asJSON bool, err error) error {
+	if err == nil {
+		return nil
+	}
+
+	kind := rbac.ErrorKindOf(err)
+	var inputErr rbacInputError
+	if errors.As(err, &inputErr) {
+		kind = rbac.ErrorKindValidation
+	}
+	code := rbacExitCodes[kind]
+
+	if asJSON {
+		var output rbacErrorOutput
+		output.Error.Kind = kind
+		output.Error.Message = err.Error()
+		output.Error.ExitCode = code
+		if err := writeRBACJSON(w, output); err != nil {
+			return err
+		}
+	} else {
+		_, _ = fmt.Fprintf(w, "%s: %s %s\n", color.RedString("Error"), color.RedString("✗"), err)
+	}
+
+	// The error has been written already, only the exit code is left to the CLI.
+	return cli.Exit("", code)
+}
+
+func writeRBACJSON(w io.Writer, v interface{}) error {
+	encoder := json.NewEncoder(w)
+	encoder.SetIndent("", "  ")
+	return encoder.Encode(v)
+}
+
+// rbacCheckCompatibilityCommand reports whether the RBAC schema of the database matches the
+// schema supported by this version of Grafana.
+func rbacCheckCompatibilityCommand(c utils.CommandLine, sqlStore *sqlstore.SQLStore) error {
+	ac := rbac.NewStandaloneService(sqlStore.Cfg, sqlStore)
+	report, err := ac.CheckCompatibility(context.Background())
+	if err != nil {
+		return err
+	}
+	if c.Bool("json") {
+		return writeRBACJSON(os.Stdout, struct {
+			*rbac.CompatibilityReport
+			IsUpToDate bool `json:"isUpToDate"`
+		}{report, report.IsUpToDate()})
+	}
+
+	logger.Infof("\n")
+	logger.Infof("RBAC schema version of the database: %d\n", report.SchemaVersion)
+	logger.Infof("RBAC schema version supported by this Grafana: %d\n", report.SupportedSchemaVersion)
+
+	switch {
+	case report.SchemaVersion == 0:
+		logger.Infof("%s RBAC tables have not been migrated, enable the rbac feature toggle and restart Grafana\n", color.YellowString("!"))
+	case !report.IsUpToDate():
+		logger.Infof("%s Missing migrations: %s\n", color.YellowString("!"), strings.Join(report.MissingMigrations, ", "))
+		logger.Infof("Writes requiring a newer schema will be refused until Grafana has been restarted with migrations enabled\n")
+	default:
+		logger.Infof("%s RBAC schema is up to date\n", color.GreenString("✔"))
+	}
+
+	return nil
+}
+
+// rbacReplayDecisionsCommand replays a decision log against the current policies, or against the
+// draft policies of the --draft file, and reports the decisions that change. Nothing is written.
+func rbacReplayDecisionsCommand(c utils.CommandLine, sqlStore *sqlstore.SQLStore) error {
+	logPath := c.Args().First()
+	if logPath == "" {
+		return rbacInputError{errors.New("please specify the decision log to replay")}
+	}
+
+	f, err := os.Open(logPath)
+	if err != nil {
+		return rbacInputError{err}
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			logger.Warnf("Failed to close decision log: %s\n", err)
+		}
+	}()
+	cmd := rbac.ReplayDecisionLogCommand{}
+	if cmd.Entries, err = rbac.ReadDecisionLog(f); err != nil {
+		return err
+	}
+
+	if draftPath := c.String("draft"); draftPath != "" {
+		data, err := ioutil.ReadFile(draftPath)
+		if err != nil {
+			return rbacInputError{err}
+		}
+		if err := json.Unmarshal(data, &cmd.Draft); err != nil {
+			return rbacInputError{errutil.Wrap("invalid draft policies", err)}
+		}
+	}
+
+	ac := rbac.NewStandaloneService(sqlStore.Cfg, sqlStore)
+	report, err := ac.ReplayDecisionLog(context.Background(), cmd)
+	if err != nil {
+		return err
+	}
+	if c.Bool("json") {
+		return writeRBACJSON(os.Stdout, report)
+	}
+
+	logger.Infof("\n")
+	for _, change := range report.Changes {
+		e := change.Entry
+		logger.Infof("%s user %d in org %d, %s on %s: %s\n", changeSymbol(change.After), e.UserID, e.OrgID, e.Action, e.Scope,
+			decisionName(change.Before)+" -> "+decisionName(change.After))
+	}
+	logger.Infof("\n")
+	logger.Infof("Replayed decisions: %d\n", report.Total)
+	logger.Infof("Changed: %d (%.2f%%)\n", len(report.Changes), report.ChangedPercent())
+	logger.Infof("Granted: %d (%.2f%%)\n", report.Granted, report.GrantedPercent())
+	logger.Infof("Revoked: %d (%.2f%%)\n", report.Revoked, report.RevokedPercent())
+
+	return nil
+}
+
+// rbacExportCommand writes an export of the RBAC state to a file, or with --verify compares the RBAC
+// state with the export of the file checksum by checksum.
+func rbacExportCommand(c utils.CommandLine, sqlStore *sqlstore.SQLStore) error {
+	path := c.Args().First()
+	if path == "" {
+		return rbacInputError{errors.New("please specify the export file")}
+	}
+	ac := rbac.NewStandaloneService(sqlStore.Cfg, sqlStore)
+
+	if c.Bool("verify") {
+		export, err := readRBACStateExport(path)
+		if err != nil {
+			return err
+		}
+		verification, err := ac.VerifyState(context.Background(), export)
+		if err != nil {
+			return err
+		}
+		if c.Bool("json") {
+			if err := writeRBACJSON(os.Stdout, verification); err != nil {
+				return err
+			}
+		} else {
+			logger.Infof("\n")
+			for _, table := range verification.Tables {
+				symbol := color.GreenString("✔")
+				if !table.Matches() {
+					symbol = color.RedString("✗")
+				}
+				logger.Infof("%s %s (%d rows)\n", symbol, table.Name, table.Rows)
+			}
+		}
+		if !verification.Matches() {
+			return rbac.ErrStateMismatch
+		}
+		return nil
+	}
+
+	export, err := ac.ExportState(context.Background())
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(export)
+	if err != nil {
+		return err
+	}
+	// The export holds who has access to what, it's only readable by its owner.
+	if err := ioutil.WriteFile(path, data, 0600); err != nil {
+		return rbacInputError{err}
+	}
+	if c.Bool("json") {
+		return writeRBACJSON(os.Stdout, rbacExportSummary(export))
+	}
+
+	logger.Infof("\n")
+	logger.Infof("Exported RBAC state at schema version %d to %s\n", export.SchemaVersion, path)
+	for _, table := range export.Tables {
+		logger.Infof("%s: %d rows, checksum %s\n", table.Name, len(table.Rows), table.Checksum)
+	}
+
+	return nil
+}
+
+// rbacExportOPABundleCommand writes the policies and bindings of every organization, or of the
+// organization of --org-id, to a file as an OPA bundle.
+func rbacExportOPABundleCommand(c utils.CommandLine, sqlStore *sqlstore.SQLStore) error {
+	path := c.Args().First()
+	if path == "" {
+		return rbacInputError{errors.New("please specify the bundle file")}
+	}
+
+	ac := rbac.NewStandaloneService(sqlStore.Cfg, sqlStore)
+	bundle, err := ac.ExportOPABundle(context.Background(), int64(c.Int("org-id")))
+	if err != nil {
+		return err
+	}
+	f, err := os.OpenFile(path, os.O_WRONLY|os.O_CREATE|os.O_TRUNC, 0600)
+	if err != nil {
+		return rbacInputError{err}
+	}
+	if err := bundle.Write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+
+	summary := struct {
+		Revision string `json:"revision"`
+		Orgs     int    `json:"orgs"`
+		Policies int    `json:"policies"`
+	}{Revision: bundle.Revision, Orgs: len(bundle.Data.Orgs)}
+	for _, org := range bundle.Data.Orgs {
+		summary.Policies += len(org.Policies)
+	}
+	if c.Bool("json") {
+		return writeRBACJSON(os.Stdout, summary)
+	}
+
+	logger.Infof("\n")
+	logger.Infof("%s Exported %d policies of %d organizations to %s, revision %s\n", color.GreenString("✔"),
+		summary.Policies, summary.Orgs, path, summary.Revision)
+
+	return nil
+}
+
+// rbacRestoreCommand replaces the RBAC state with the export of a file.
+func rbacRestoreCommand(c utils.CommandLine, sqlStore *sqlstore.SQLStore) error {
+	path := c.Args().First()
+	if path == "" {
+		return rbacInputError{errors.New("please specify the export file")}
+	}
+	export, err := readRBACStateExport(path)
+	if err != nil {
+		return err
+	}
+
+	ac := rbac.NewStandaloneService(sqlStore.Cfg, sqlStore)
+	if err := ac.RestoreState(context.Background(), export); err != nil {
+		return err
+	}
+	if c.Bool("json") {
+		return writeRBACJSON(os.Stdout, rbacExportSummary(export))
+	}
+
+	logger.Infof("\n")
+	logger.Infof("%s Restored RBAC state exported at %s\n", color.GreenString("✔"), export.Exported.Format(time.RFC3339))
+	logger.Infof("Run the export command with --verify to compare it with the source\n")
+
+	return nil
+}
+
+// rbacRepairCommand deletes the RBAC rows of deleted users, teams and organizations, or only reports
+// them with --dry-run.
+func rbacRepairCommand(c utils.CommandLine, sqlStore *sqlstore.SQLStore) error {
+	dryRun := c.Bool("dry-run")
+	ac := rbac.NewStandaloneService(sqlStore.Cfg, sqlStore)
+	result, err := ac.RepairBindings(context.Background(), rbac.RepairBindingsCommand{DryRun: dryRun})
+	if err != nil {
+		return err
+	}
+	if c.Bool("json") {
+		return writeRBACJSON(os.Stdout, result)
+	}
+
+	logger.Infof("\n")
+	if result.Total == 0 {
+		logger.Infof("%s RBAC state is consistent, nothing to repair\n", color.GreenString("✔"))
+		return nil
+	}
+	for _, t := range result.Tables {
+		logger.Infof("%s: %d rows\n", t.Table, t.Rows)
+	}
+	if dryRun {
+		logger.Infof("%s %d rows would be deleted, run without --dry-run to delete them\n", color.YellowString("!"), result.Total)
+		return nil
+	}
+	logger.Infof("%s Deleted %d rows\n", color.GreenString("✔"), result.Total)
+
+	return nil
+}
+
+// rbacEnforcementModeCommand lists the enforcement modes of the actions, or with an action and a mode
+// sets the enforcement mode of the action, or of the actions matching a wildcard pattern. With --remove
+// the action's mode is removed.
+func rbacEnforcementModeCommand(c utils.CommandLine, sqlStore *sqlstore.SQLStore) error {
+	ac := rbac.NewStandaloneService(sqlStore.Cfg, sqlStore)
+	action, mode := c.Args().Get(0), c.Args().Get(1)
+	switch {
+	case action == "":
+	case c.Bool("remove"):
+		if err := ac.RemoveEnforcementMode(context.Background(), action); err != nil {
+			return err
+		}
+	case mode == "":
+		return rbacInputError{errors.New("please specify the enforcement mode: disabled, log or enforce")}
+	default:
+		if err := ac.SetEnforcementMode(context.Background(), rbac.SetEnforcementModeCommand{Action: action, Mode: mode}); err != nil {
+			return err
+		}
+	}
+
+	modes, err := ac.GetEnforcementModes(context.Background())
+	if err != nil {
+		return err
+	}
+	if c.Bool("json") {
+		return writeRBACJSON(os.Stdout, modes)
+	}
+
+	logger.Infof("\n")
+	if len(modes) == 0 {
+		logger.Infof("Every action is enforced\n")
+		return nil
+	}
+	for _, m := range modes {
+		logger.Infof("%s: %s\n", m.Action, m.Mode)
+	}
+	logger.Infof("Other actions are enforced, running servers see changes within 30 seconds\n")
+
+	return nil
+}
+
+// rbacExportSummary returns an export without its rows, for the JSON output of the commands.
+func rbacExportSummary(export *rbac.StateExport) interface{} {
+	type table struct {
+		Name     string `json:"name"`
+		Checksum string `json:"checksum"`
+		Rows     int    `json:"rows"`
+	}
+	summary := struct {
+		Exported      time.Time `json:"exported"`
+		SchemaVersion int       `json:"schemaVersion"`
+		Tables        []table   `json:"tables"`
+	}{Exported: export.Exported, SchemaVersion: export.SchemaVersion, Tables: []table{}}
+	for _, t := range export.Tables {
+		summary.Tables = append(summary.Tables, table{Name: t.Name, Checksum: t.Checksum, Rows: len(t.Rows)})
+	}
+	return summary
+}
+
+func readRBACStateExport(path string) (*rbac.StateExport, error) {
+	f, err := os.Open(path)
+	if err != nil {
+		return nil, rbacInputError{err}
+	}
+	defer func() {
+		if err := f.Close(); err != nil {
+			logger.Warnf("Failed to close export file: %s\n", err)
+		}
+	}()
+
+	return rbac.ReadStateExport(f)
+}
+
+func changeSymbol(allowed bool) string {
+	if allowed {
+		return color.GreenString("+")
+	}
+	return color.RedString("-")
+}
+
+func decisionName(allowed bool) string {
+	if allowed {
+		return "allowed"
+	}
+	return "denied"
+}
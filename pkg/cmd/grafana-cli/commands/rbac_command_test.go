@@ -0,0 +1,60 @@
+package commands
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"github.com/urfave/cli/v2"
+
+	"github.com/grafana/grafana/pkg/services/rbac"
+)
+
+func TestRBACExit(t *testing.T) {
+	tests := []struct {
+		description string
+		err         error
+		kind        rbac.ErrorKind
+		code        int
+	}{
+		{description: "quota", err: rbac.ErrPermissionLimitExceeded, kind: rbac.ErrorKindQuota, code: 5},
+		{description: "conflict", err: rbac.ErrPolicyAlreadyExists, kind: rbac.ErrorKindConflict, code: 4},
+		{description: "not found", err: rbac.ErrPolicyNotFound, kind: rbac.ErrorKindNotFound, code: 3},
+		{description: "validation", err: fmt.Errorf("%w entry on line 2: unexpected end of JSON input", rbac.ErrInvalidDecisionLog), kind: rbac.ErrorKindValidation, code: 2},
+		{description: "invalid input", err: rbacInputError{errors.New("please specify the decision log to replay")}, kind: rbac.ErrorKindValidation, code: 2},
+		{description: "schema outdated", err: fmt.Errorf("%w: version 18 is required", rbac.ErrSchemaOutdated), kind: rbac.ErrorKindSchemaOutdated, code: 6},
+		{description: "internal", err: errors.New("failed to initialize SQL engine"), kind: rbac.ErrorKindInternal, code: 1},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.description, func(t *testing.T) {
+			var out bytes.Buffer
+			err := rbacExit(&out, true, tc.err)
+			var exitErr cli.ExitCoder
+			require.True(t, errors.As(err, &exitErr))
+			assert.Equal(t, tc.code, exitErr.ExitCode())
+
+			var output rbacErrorOutput
+			require.NoError(t, json.Unmarshal(out.Bytes(), &output))
+			assert.Equal(t, tc.kind, output.Error.Kind)
+			assert.Equal(t, tc.err.Error(), output.Error.Message)
+			assert.Equal(t, tc.code, output.Error.ExitCode)
+
+			out.Reset()
+			err = rbacExit(&out, false, tc.err)
+			require.True(t, errors.As(err, &exitErr))
+			assert.Equal(t, tc.code, exitErr.ExitCode())
+			assert.Contains(t, out.String(), tc.err.Error())
+		})
+	}
+
+	t.Run("success", func(t *testing.T) {
+		var out bytes.Buffer
+		assert.NoError(t, rbacExit(&out, true, nil))
+		assert.Empty(t, out.String())
+	})
+}
@@ -46,3 +46,170 @@ type UserUpdated struct {
 	Login     string    `json:"login"`
 	Email     string    `json:"email"`
 }
+
+// OrgUserAdded is published when a user is added to an organization, including when a new
+// user is added to the main organization.
+type OrgUserAdded struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgID     int64     `json:"orgId"`
+	UserID    int64     `json:"userId"`
+	Role      string    `json:"role"`
+}
+
+// OrgRolesMigrated is published when the access of an organization's users is moved from their
+// organization role to RBAC policies, or when such a migration is rolled back.
+type OrgRolesMigrated struct {
+	Timestamp   time.Time `json:"timestamp"`
+	OrgID       int64     `json:"orgId"`
+	MigrationID int64     `json:"migrationId"`
+	PerformedBy int64     `json:"performedBy"`
+	Users       int       `json:"users"`
+	RolledBack  bool      `json:"rolledBack"`
+}
+
+// PermissionExpired is published when an expired RBAC permission is deleted.
+type PermissionExpired struct {
+	Timestamp    time.Time `json:"timestamp"`
+	PermissionID int64     `json:"permissionId"`
+	PolicyID     int64     `json:"policyId"`
+	Action       string    `json:"action"`
+	Scope        string    `json:"scope"`
+	ExpiresAt    time.Time `json:"expiresAt"`
+}
+
+// UserPolicyBindingExpiring is published once when a binding of an RBAC policy to a user is about to expire,
+// so that it can be renewed in time.
+type UserPolicyBindingExpiring struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgID     int64     `json:"orgId"`
+	PolicyID  int64     `json:"policyId"`
+	UserID    int64     `json:"userId"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PolicyBindingExpired is published when an expired binding of an RBAC policy to a team or a user is deleted.
+type PolicyBindingExpired struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgID     int64     `json:"orgId"`
+	PolicyID  int64     `json:"policyId"`
+	TeamID    int64     `json:"teamId,omitempty"`
+	UserID    int64     `json:"userId,omitempty"`
+	ExpiresAt time.Time `json:"expiresAt"`
+}
+
+// PolicyBound is published when an RBAC policy is bound to a team or a user, so that notification
+// integrations can tell them or the team owners that their access changed. PerformedBy is the user
+// who made the change, 0 when Grafana made it by itself, e.g. when syncing a user at login.
+type PolicyBound struct {
+	Timestamp   time.Time `json:"timestamp"`
+	OrgID       int64     `json:"orgId"`
+	PolicyID    int64     `json:"policyId"`
+	TeamID      int64     `json:"teamId,omitempty"`
+	UserID      int64     `json:"userId,omitempty"`
+	PerformedBy int64     `json:"performedBy"`
+	Reason      string    `json:"reason"`
+}
+
+// PolicyUnbound is published when an RBAC policy is unbound from a team or a user, expired bindings
+// are published as PolicyBindingExpired instead.
+type PolicyUnbound struct {
+	Timestamp   time.Time `json:"timestamp"`
+	OrgID       int64     `json:"orgId"`
+	PolicyID    int64     `json:"policyId"`
+	TeamID      int64     `json:"teamId,omitempty"`
+	UserID      int64     `json:"userId,omitempty"`
+	PerformedBy int64     `json:"performedBy"`
+	Reason      string    `json:"reason"`
+}
+
+// BreakGlassUsed is published when a user elevates themselves to the break-glass policy of an
+// organization, so that security teams can be alerted.
+type BreakGlassUsed struct {
+	Timestamp     time.Time `json:"timestamp"`
+	OrgID         int64     `json:"orgId"`
+	UserID        int64     `json:"userId"`
+	PolicyID      int64     `json:"policyId"`
+	ExpiresAt     time.Time `json:"expiresAt"`
+	Justification string    `json:"justification"`
+}
+
+// AccessRequestCreated is published when a user requests an RBAC policy, so that its approvers can
+// be notified.
+type AccessRequestCreated struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgID     int64     `json:"orgId"`
+	RequestID int64     `json:"requestId"`
+	UserID    int64     `json:"userId"`
+	PolicyID  int64     `json:"policyId"`
+	PolicyUID string    `json:"policyUid"`
+}
+
+// AccessRequestReviewed is published when a request for an RBAC policy is approved or denied.
+type AccessRequestReviewed struct {
+	Timestamp  time.Time `json:"timestamp"`
+	OrgID      int64     `json:"orgId"`
+	RequestID  int64     `json:"requestId"`
+	UserID     int64     `json:"userId"`
+	PolicyID   int64     `json:"policyId"`
+	ReviewedBy int64     `json:"reviewedBy"`
+	Approved   bool      `json:"approved"`
+	Comment    string    `json:"comment"`
+}
+
+// TeamMemberAdded is published when a user is added to a team, External is set when the team
+// membership is synced from an external group.
+type TeamMemberAdded struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgID     int64     `json:"orgId"`
+	TeamID    int64     `json:"teamId"`
+	UserID    int64     `json:"userId"`
+	External  bool      `json:"external"`
+}
+
+// TeamMemberRemoved is published when a user is removed from a team.
+type TeamMemberRemoved struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgID     int64     `json:"orgId"`
+	TeamID    int64     `json:"teamId"`
+	UserID    int64     `json:"userId"`
+	External  bool      `json:"external"`
+}
+
+// TeamDeleted is published when a team is deleted.
+type TeamDeleted struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgID     int64     `json:"orgId"`
+	TeamID    int64     `json:"teamId"`
+}
+
+// UserDeleted is published when a user is deleted.
+type UserDeleted struct {
+	Timestamp time.Time `json:"timestamp"`
+	UserID    int64     `json:"userId"`
+}
+
+// OrgDeleted is published when an organization is deleted.
+type OrgDeleted struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgID     int64     `json:"orgId"`
+}
+
+// UserPermissionsChanged is published when the RBAC permissions of a user in an organization
+// may have changed, so that caches of their permissions can be invalidated.
+type UserPermissionsChanged struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgID     int64     `json:"orgId"`
+	UserID    int64     `json:"userId"`
+	Reason    string    `json:"reason"`
+}
+
+// PolicyChanged is published when the permissions of an RBAC policy or settings that affect them change,
+// or when the policy is bound to or unbound from a builtin role or an API key, so that caches of the
+// permissions it grants can be invalidated. OrgID is 0 when the policies of every organization may have
+// changed, and PolicyID is 0 when the policy isn't known.
+type PolicyChanged struct {
+	Timestamp time.Time `json:"timestamp"`
+	OrgID     int64     `json:"orgId"`
+	PolicyID  int64     `json:"policyId"`
+	Reason    string    `json:"reason"`
+}
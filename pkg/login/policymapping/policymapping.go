@@ -0,0 +1,115 @@
+// Package policymapping maps the attributes an identity provider asserts about a user, such as
+// OAuth claims or SAML assertion attributes, to the RBAC policies bound to the user at login.
+package policymapping
+
+import (
+	"fmt"
+	"strconv"
+	"strings"
+)
+
+// Mapping maps a value of a user attribute to the RBAC policy bound to the users having it.
+type Mapping struct {
+	// Attribute is the claim or assertion attribute holding the value, e.g. groups.
+	Attribute string
+	// Value is the value to match, * matches every user.
+	Value     string
+	PolicyUID string
+	// OrgID is the organization of the policy, 0 for the organization the user is assigned a role in.
+	OrgID int64
+}
+
+// Parse parses comma separated policy mapping rules, each of them written
+// <attribute>:<value>=<policy_uid>[@<org_id>], e.g. groups:Platform Team=platform-admin@2.
+func Parse(value string) ([]Mapping, error) {
+	mappings := []Mapping{}
+	for _, rule := range strings.Split(value, ",") {
+		rule = strings.TrimSpace(rule)
+		if rule == "" {
+			continue
+		}
+
+		attributeAndValue, target, ok := cutLast(rule, "=")
+		if !ok {
+			return nil, fmt.Errorf("invalid policy mapping %q, expected <attribute>:<value>=<policy_uid>[@<org_id>]", rule)
+		}
+		parts := strings.SplitN(attributeAndValue, ":", 2)
+		if len(parts) != 2 || strings.TrimSpace(parts[0]) == "" || strings.TrimSpace(parts[1]) == "" {
+			return nil, fmt.Errorf("invalid policy mapping %q, expected <attribute>:<value>=<policy_uid>[@<org_id>]", rule)
+		}
+		mapping := Mapping{
+			Attribute: strings.TrimSpace(parts[0]),
+			Value:     strings.TrimSpace(parts[1]),
+			PolicyUID: strings.TrimSpace(target),
+		}
+		if policyUID, orgID, ok := cutLast(mapping.PolicyUID, "@"); ok {
+			id, err := strconv.ParseInt(strings.TrimSpace(orgID), 10, 64)
+			if err != nil || id <= 0 {
+				return nil, fmt.Errorf("invalid organization id in policy mapping %q", rule)
+			}
+			mapping.PolicyUID = strings.TrimSpace(policyUID)
+			mapping.OrgID = id
+		}
+		if mapping.PolicyUID == "" {
+			return nil, fmt.Errorf("missing policy uid in policy mapping %q", rule)
+		}
+
+		mappings = append(mappings, mapping)
+	}
+
+	return mappings, nil
+}
+
+func cutLast(s, sep string) (string, string, bool) {
+	i := strings.LastIndex(s, sep)
+	if i < 0 {
+		return s, "", false
+	}
+	return s[:i], s[i+len(sep):], true
+}
+
+// Resolve returns the uids of the policies the mappings bind to a user with the given attribute
+// values in each organization. Every mapped organization is listed, so that the policies of values
+// the user lost since the previous login get unbound. Mappings without an organization apply to
+// defaultOrgID.
+func Resolve(mappings []Mapping, attributes map[string][]string, defaultOrgID int64) map[int64][]string {
+	policyUIDs := map[int64][]string{}
+	for _, mapping := range mappings {
+		orgID := mapping.OrgID
+		if orgID == 0 {
+			orgID = defaultOrgID
+		}
+		uids, ok := policyUIDs[orgID]
+		if !ok {
+			uids = []string{}
+		}
+
+		if matches(attributes[mapping.Attribute], mapping.Value) && !contains(uids, mapping.PolicyUID) {
+			uids = append(uids, mapping.PolicyUID)
+		}
+		policyUIDs[orgID] = uids
+	}
+
+	return policyUIDs
+}
+
+func matches(values []string, value string) bool {
+	if value == "*" {
+		return true
+	}
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
+
+func contains(uids []string, uid string) bool {
+	for _, u := range uids {
+		if u == uid {
+			return true
+		}
+	}
+	return false
+}
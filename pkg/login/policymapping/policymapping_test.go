@@ -0,0 +1,67 @@
+package policymapping
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	t.Run("parses rules", func(t *testing.T) {
+		mappings, err := Parse("groups:Platform Team=platform-admin@2, Role:Dashboards.Write=dashboard-writer,,groups:*=viewer")
+		require.NoError(t, err)
+		assert.Equal(t, []Mapping{
+			{Attribute: "groups", Value: "Platform Team", PolicyUID: "platform-admin", OrgID: 2},
+			{Attribute: "Role", Value: "Dashboards.Write", PolicyUID: "dashboard-writer"},
+			{Attribute: "groups", Value: "*", PolicyUID: "viewer"},
+		}, mappings)
+	})
+
+	t.Run("keeps colons in values", func(t *testing.T) {
+		mappings, err := Parse("groups:team:infra=infra-admin")
+		require.NoError(t, err)
+		assert.Equal(t, []Mapping{{Attribute: "groups", Value: "team:infra", PolicyUID: "infra-admin"}}, mappings)
+	})
+
+	t.Run("returns no mappings when unset", func(t *testing.T) {
+		mappings, err := Parse("")
+		require.NoError(t, err)
+		assert.Empty(t, mappings)
+	})
+
+	for _, rule := range []string{"admins=admin", "groups:admins", ":admins=admin", "groups:admins=", "groups:admins=admin@two", "groups:admins=admin@0"} {
+		t.Run("rejects "+rule, func(t *testing.T) {
+			_, err := Parse(rule)
+			require.Error(t, err)
+		})
+	}
+}
+
+func TestResolve(t *testing.T) {
+	mappings := []Mapping{
+		{Attribute: "groups", Value: "admins", PolicyUID: "admin"},
+		{Attribute: "groups", Value: "editors", PolicyUID: "editor"},
+		{Attribute: "roles", Value: "Dashboards.Write", PolicyUID: "editor"},
+		{Attribute: "roles", Value: "Reports.Read", PolicyUID: "reporter", OrgID: 2},
+		{Attribute: "groups", Value: "*", PolicyUID: "viewer", OrgID: 3},
+	}
+
+	t.Run("binds the policies of matching attribute values", func(t *testing.T) {
+		attributes := map[string][]string{"groups": {"Editors"}, "roles": {"dashboards.write"}}
+		assert.Equal(t, map[int64][]string{
+			1: {"editor"},
+			2: {},
+			3: {"viewer"},
+		}, Resolve(mappings, attributes, 1))
+	})
+
+	t.Run("applies mappings without organization to the default one", func(t *testing.T) {
+		attributes := map[string][]string{"groups": {"admins"}, "roles": {"Reports.Read"}}
+		assert.Equal(t, map[int64][]string{
+			2: {"reporter"},
+			3: {"viewer"},
+			5: {"admin"},
+		}, Resolve(mappings, attributes, 5))
+	})
+}
@@ -66,6 +66,7 @@ func (s *SocialAzureAD) UserInfo(_ *http.Client, token *oauth2.Token) (*BasicUse
 		Login:  email,
 		Role:   string(role),
 		Groups: groups,
+		Roles:  extractRoles(claims),
 	}, nil
 }
 
@@ -129,3 +130,9 @@ func extractGroups(claims azureClaims) []string {
 	groups = append(groups, claims.Groups...)
 	return groups
 }
+
+func extractRoles(claims azureClaims) []string {
+	roles := make([]string, 0)
+	roles = append(roles, claims.Roles...)
+	return roles
+}
@@ -45,6 +45,7 @@ func TestSocialAzureAD_UserInfo(t *testing.T) {
 				Company: "",
 				Role:    "Viewer",
 				Groups:  []string{},
+				Roles:   []string{},
 			},
 		},
 		{
@@ -82,6 +83,7 @@ func TestSocialAzureAD_UserInfo(t *testing.T) {
 				Company: "",
 				Role:    "Viewer",
 				Groups:  []string{},
+				Roles:   []string{},
 			},
 		},
 		{
@@ -101,6 +103,7 @@ func TestSocialAzureAD_UserInfo(t *testing.T) {
 				Company: "",
 				Role:    "Admin",
 				Groups:  []string{},
+				Roles:   []string{"Admin"},
 			},
 		},
 		{
@@ -120,6 +123,7 @@ func TestSocialAzureAD_UserInfo(t *testing.T) {
 				Company: "",
 				Role:    "Admin",
 				Groups:  []string{},
+				Roles:   []string{"admin"},
 			},
 		},
 		{
@@ -139,6 +143,7 @@ func TestSocialAzureAD_UserInfo(t *testing.T) {
 				Company: "",
 				Role:    "Viewer",
 				Groups:  []string{},
+				Roles:   []string{"AppAdmin"},
 			},
 		},
 
@@ -159,6 +164,7 @@ func TestSocialAzureAD_UserInfo(t *testing.T) {
 				Company: "",
 				Role:    "Editor",
 				Groups:  []string{},
+				Roles:   []string{"Editor"},
 			},
 		},
 		{
@@ -178,6 +184,7 @@ func TestSocialAzureAD_UserInfo(t *testing.T) {
 				Company: "",
 				Role:    "Admin",
 				Groups:  []string{},
+				Roles:   []string{"Admin", "Editor"},
 			},
 		},
 		{
@@ -217,6 +224,7 @@ func TestSocialAzureAD_UserInfo(t *testing.T) {
 				Company: "",
 				Role:    "Viewer",
 				Groups:  []string{"foo"},
+				Roles:   []string{},
 			},
 		},
 	}
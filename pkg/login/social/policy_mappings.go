@@ -0,0 +1,39 @@
+package social
+
+import (
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/login/policymapping"
+)
+
+// Claims policy mappings can match on.
+const (
+	policyMappingClaimGroups = "groups"
+	policyMappingClaimRoles  = "roles"
+)
+
+// parsePolicyMappings parses the policy_mappings rules of an OAuth provider, see policymapping.Parse.
+// Only the groups and roles claims are supported.
+func parsePolicyMappings(value string) ([]policymapping.Mapping, error) {
+	mappings, err := policymapping.Parse(value)
+	if err != nil {
+		return nil, err
+	}
+	for _, mapping := range mappings {
+		if mapping.Attribute != policyMappingClaimGroups && mapping.Attribute != policyMappingClaimRoles {
+			return nil, fmt.Errorf("invalid claim %q in policy mapping, expected %s or %s", mapping.Attribute,
+				policyMappingClaimGroups, policyMappingClaimRoles)
+		}
+	}
+
+	return mappings, nil
+}
+
+// ResolvePolicyMappings returns the uids of the policies the mappings bind to the user in each
+// organization, see policymapping.Resolve.
+func ResolvePolicyMappings(mappings []policymapping.Mapping, userInfo *BasicUserInfo, defaultOrgID int64) map[int64][]string {
+	return policymapping.Resolve(mappings, map[string][]string{
+		policyMappingClaimGroups: userInfo.Groups,
+		policyMappingClaimRoles:  userInfo.Roles,
+	}, defaultOrgID)
+}
@@ -0,0 +1,35 @@
+package social
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/login/policymapping"
+)
+
+func TestParsePolicyMappings(t *testing.T) {
+	mappings, err := parsePolicyMappings("groups:Platform Team=platform-admin@2, roles:Dashboards.Write=dashboard-writer")
+	require.NoError(t, err)
+	assert.Equal(t, []policymapping.Mapping{
+		{Attribute: "groups", Value: "Platform Team", PolicyUID: "platform-admin", OrgID: 2},
+		{Attribute: "roles", Value: "Dashboards.Write", PolicyUID: "dashboard-writer"},
+	}, mappings)
+
+	_, err = parsePolicyMappings("email:me@example.com=admin")
+	require.Error(t, err)
+}
+
+func TestResolvePolicyMappings(t *testing.T) {
+	mappings := []policymapping.Mapping{
+		{Attribute: "groups", Value: "admins", PolicyUID: "admin"},
+		{Attribute: "roles", Value: "Dashboards.Write", PolicyUID: "editor", OrgID: 2},
+	}
+
+	userInfo := &BasicUserInfo{Groups: []string{"admins"}, Roles: []string{"Dashboards.Write"}}
+	assert.Equal(t, map[int64][]string{
+		1: {"admin"},
+		2: {"editor"},
+	}, ResolvePolicyMappings(mappings, userInfo, 1))
+}
@@ -29,6 +29,8 @@ type BasicUserInfo struct {
 	Company string
 	Role    string
 	Groups  []string
+	// Roles are the application roles of the user, e.g. the roles claim of Azure AD.
+	Roles []string
 }
 
 type SocialConnector interface {
@@ -110,6 +112,13 @@ func NewOAuthService() {
 			continue
 		}
 
+		policyMappings, err := parsePolicyMappings(sec.Key("policy_mappings").String())
+		if err != nil {
+			// Without mappings the policies of the users are left as they are rather than unbound.
+			logger.Error("Ignoring invalid OAuth policy mappings", "oauth", name, "error", err)
+		}
+		info.PolicyMappings = policyMappings
+
 		if name == "grafananet" {
 			name = grafanaCom
 		}
@@ -0,0 +1,27 @@
+package middleware
+
+import (
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/orgmapping"
+	"gopkg.in/macaron.v1"
+)
+
+// ResolveOrgIDParam translates an external organization identifier in the :orgId route parameter,
+// e.g. /api/orgs/stack:prod-eu/users, into the id of the organization, see orgmapping.
+func ResolveOrgIDParam() macaron.Handler {
+	return func(c *models.ReqContext) {
+		ref := c.Params(":orgId")
+		if !orgmapping.IsExternal(ref) {
+			return
+		}
+
+		orgID, err := orgmapping.Resolve(c.Req.Context(), ref)
+		if err != nil {
+			c.JsonApiErr(404, "Organization not found", err)
+			return
+		}
+		c.SetParams(":orgId", strconv.FormatInt(orgID, 10))
+	}
+}
@@ -0,0 +1,53 @@
+package middleware
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/orgmapping"
+)
+
+func TestMiddlewareResolveOrgIDParam(t *testing.T) {
+	orgmapping.Register("test-stack", orgmapping.MapperFunc(func(ctx context.Context, externalID string) (int64, error) {
+		if externalID == "prod-eu" {
+			return 4, nil
+		}
+		return 0, errors.New("unknown stack")
+	}))
+
+	var orgID int64
+	handler := func(c *models.ReqContext) {
+		orgID = c.ParamsInt64(":orgId")
+		c.JSON(200, nil)
+	}
+
+	middlewareScenario(t, "External identifier should be resolved to the organization id", func(t *testing.T, sc *scenarioContext) {
+		sc.m.Get("/api/orgs/:orgId", ResolveOrgIDParam(), handler)
+
+		sc.fakeReq("GET", "/api/orgs/test-stack:prod-eu").exec()
+
+		assert.Equal(t, 200, sc.resp.Code)
+		assert.Equal(t, int64(4), orgID)
+	})
+
+	middlewareScenario(t, "Numeric id should be kept", func(t *testing.T, sc *scenarioContext) {
+		sc.m.Get("/api/orgs/:orgId", ResolveOrgIDParam(), handler)
+
+		sc.fakeReq("GET", "/api/orgs/2").exec()
+
+		assert.Equal(t, 200, sc.resp.Code)
+		assert.Equal(t, int64(2), orgID)
+	})
+
+	middlewareScenario(t, "Unmapped identifier should not be found", func(t *testing.T, sc *scenarioContext) {
+		sc.m.Get("/api/orgs/:orgId", ResolveOrgIDParam(), handler)
+
+		sc.fakeReq("GET", "/api/orgs/test-stack:prod-us").exec()
+
+		assert.Equal(t, 404, sc.resp.Code)
+	})
+}
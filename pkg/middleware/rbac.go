@@ -0,0 +1,78 @@
+package middleware
+
+import (
+	macaron "gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rbac"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// AuthorizeHandler is the type of the middlewares created by Authorize, it lets tests tell the
+// routes declaring an RBAC requirement apart.
+type AuthorizeHandler func(c *models.ReqContext)
+
+// Authorize creates a middleware that requires the signed in user's RBAC permissions to
+// satisfy the evaluator when RBAC is enabled, and otherwise defers to the fallback handler,
+// e.g. ReqGrafanaAdmin, so routes keep their role based access until RBAC is turned on. The
+// fallback also decides when none of the user's permissions matches a resource type in compat mode,
+// and always decides on resource types in shadow mode, the RBAC decision being only compared with it.
+func Authorize(ac *rbac.RBACService, fallback macaron.Handler, evaluator rbac.Evaluator) macaron.Handler {
+	return AuthorizeFunc(ac, fallback, func(*models.ReqContext) rbac.Evaluator { return evaluator })
+}
+
+// AuthorizeFunc is Authorize for evaluators depending on the request, e.g. scoped to a resource
+// identified by a route parameter.
+func AuthorizeFunc(ac *rbac.RBACService, fallback macaron.Handler, evaluatorFunc func(c *models.ReqContext) rbac.Evaluator) macaron.Handler {
+	return AuthorizeHandler(func(c *models.ReqContext) {
+		invokeFallback := func() {
+			if _, err := c.Invoke(fallback); err != nil {
+				c.JsonApiErr(500, "Failed to authorize request", err)
+			}
+		}
+		if ac == nil || !ac.IsEnabled() {
+			invokeFallback()
+			return
+		}
+
+		if !c.IsSignedIn {
+			notAuthorized(c)
+			return
+		}
+
+		evaluator := evaluatorFunc(c)
+		ctx := ac.RequestContext(c)
+		decision, err := ac.Decide(ctx, c.SignedInUser, evaluator)
+		if err != nil {
+			c.JsonApiErr(500, "Failed to authorize request", err)
+			return
+		}
+		if decision.Shadow {
+			invokeFallback()
+			ac.CompareDecision(c.SignedInUser, evaluator, decision, !c.Resp.Written())
+			return
+		}
+		if decision.Allowed {
+			return
+		}
+		if decision.LegacyFallback {
+			invokeFallback()
+			return
+		}
+
+		c.Logger.Debug("Access denied", "userId", c.UserId, "requirement", evaluator.String())
+		if c.UserToken != nil && c.IsApiRequest() {
+			reauthenticate, err := ac.RequiresReauthentication(ctx, c.SignedInUser, evaluator)
+			if err != nil {
+				c.JsonApiErr(500, "Failed to authorize request", err)
+				return
+			}
+			if reauthenticate {
+				// Lets the frontend prompt the user to log in again instead of showing a dead end.
+				c.JSON(403, util.DynMap{"status": "reauthentication-required", "message": "Recent authentication required"})
+				return
+			}
+		}
+		accessForbidden(c)
+	})
+}
@@ -0,0 +1,28 @@
+package middleware
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+
+	"github.com/grafana/grafana/pkg/services/rbac"
+)
+
+func TestMiddlewareAuthorize(t *testing.T) {
+	middlewareScenario(t, "Authorize without RBAC should use the fallback handler", func(t *testing.T, sc *scenarioContext) {
+		sc.m.Get("/api/secure", Authorize(nil, ReqGrafanaAdmin, rbac.Perm(rbac.ActionUsersRead, "users:*")), sc.defaultHandler)
+
+		sc.fakeReq("GET", "/api/secure").exec()
+
+		assert.Equal(t, 401, sc.resp.Code)
+	})
+
+	middlewareScenario(t, "Authorize without RBAC should allow requests the fallback allows", func(t *testing.T, sc *scenarioContext) {
+		allow := func() {}
+		sc.m.Get("/api/secure", Authorize(nil, allow, rbac.Perm(rbac.ActionUsersRead, "users:*")), sc.defaultHandler)
+
+		sc.fakeReq("GET", "/api/secure").exec()
+
+		assert.Equal(t, 200, sc.resp.Code)
+	})
+}
@@ -35,6 +35,8 @@ type ExternalUserInfo struct {
 	OrgRoles       map[int64]RoleType
 	IsGrafanaAdmin *bool // This is a pointer to know if we should sync this or not (nil = ignore sync)
 	IsDisabled     bool
+	// PolicyUIDs are the uids of the RBAC policies to bind to the user in each org (nil = ignore sync)
+	PolicyUIDs map[int64][]string
 }
 
 type LoginInfo struct {
@@ -79,6 +81,16 @@ type DeleteAuthInfoCommand struct {
 	UserAuth *UserAuth
 }
 
+// SyncUserPoliciesCommand binds the RBAC policies an auth module maps to the user and unbinds
+// the ones it no longer maps.
+type SyncUserPoliciesCommand struct {
+	UserId     int64
+	AuthModule string
+	PolicyUIDs map[int64][]string
+	// Groups are the external groups of the user, the policies mapped to them are bound too.
+	Groups []string
+}
+
 // ----------------------
 // QUERIES
 
@@ -18,6 +18,7 @@ import (
 	"github.com/grafana/grafana/pkg/registry"
 	"github.com/grafana/grafana/pkg/services/contexthandler/authproxy"
 	"github.com/grafana/grafana/pkg/services/login"
+	"github.com/grafana/grafana/pkg/services/rbac"
 	"github.com/grafana/grafana/pkg/services/rendering"
 	"github.com/grafana/grafana/pkg/services/sqlstore"
 	"github.com/grafana/grafana/pkg/setting"
@@ -330,6 +331,10 @@ func (h *ContextHandler) initContextWithRenderAuth(ctx *models.ReqContext) bool
 		UserId:  renderUser.UserID,
 		OrgRole: models.RoleType(renderUser.OrgRole),
 	}
+	if renderUser.Delegated {
+		// The renderer may only do what the user it renders for is allowed to.
+		ctx.Req.Request = ctx.Req.WithContext(rbac.WithDelegatedPermissions(ctx.Req.Context(), renderUser.Permissions))
+	}
 	ctx.IsRenderCall = true
 	ctx.LastSeenAt = time.Now()
 	return true
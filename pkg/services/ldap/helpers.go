@@ -19,6 +19,15 @@ func isMemberOf(memberOf []string, group string) bool {
 	return false
 }
 
+func containsString(values []string, value string) bool {
+	for _, v := range values {
+		if v == value {
+			return true
+		}
+	}
+	return false
+}
+
 func appendIfNotEmpty(slice []string, values ...string) []string {
 	for _, v := range values {
 		if v != "" {
@@ -430,6 +430,21 @@ func (server *Server) buildGrafanaUser(user *ldap.Entry) (*models.ExternalUserIn
 		}
 	}
 
+	if len(server.Config.PolicyMappings) > 0 {
+		extUser.PolicyUIDs = map[int64][]string{}
+		for _, mapping := range server.Config.PolicyMappings {
+			// list every mapped org so that policies of groups the user left get unbound
+			policyUIDs, ok := extUser.PolicyUIDs[mapping.OrgId]
+			if !ok {
+				policyUIDs = []string{}
+			}
+			if isMemberOf(memberOf, mapping.GroupDN) && !containsString(policyUIDs, mapping.PolicyUID) {
+				policyUIDs = append(policyUIDs, mapping.PolicyUID)
+			}
+			extUser.PolicyUIDs[mapping.OrgId] = policyUIDs
+		}
+	}
+
 	// If there are group org mappings configured, but no matching mappings,
 	// the user will not be able to login and will be disabled
 	if len(server.Config.Groups) > 0 && len(extUser.OrgRoles) == 0 {
@@ -144,6 +144,38 @@ func TestLDAPPrivateMethods(t *testing.T) {
 			So(len(result), ShouldEqual, 1)
 			So(result[0].IsDisabled, ShouldBeTrue)
 		})
+
+		Convey("policy mappings should list the policies of the user's groups in every mapped org", func() {
+			server := &Server{
+				Config: &ServerConfig{
+					Attr: AttributeMap{MemberOf: "memberof"},
+					PolicyMappings: []*GroupToPolicy{
+						{GroupDN: "cn=admins", OrgId: 1, PolicyUID: "admin-tools"},
+						{GroupDN: "*", OrgId: 1, PolicyUID: "baseline"},
+						{GroupDN: "cn=ADMINS", OrgId: 1, PolicyUID: "admin-tools"},
+						{GroupDN: "cn=editors", OrgId: 2, PolicyUID: "editing"},
+					},
+				},
+				Connection: &MockConnection{},
+				log:        log.New("test-logger"),
+			}
+
+			entry := ldap.Entry{
+				DN: "dn",
+				Attributes: []*ldap.EntryAttribute{
+					{Name: "memberof", Values: []string{"cn=admins"}},
+				},
+			}
+			users := []*ldap.Entry{&entry}
+
+			result, err := server.serializeUsers(users)
+
+			So(err, ShouldBeNil)
+			So(result[0].PolicyUIDs, ShouldResemble, map[int64][]string{
+				1: {"admin-tools", "baseline"},
+				2: {},
+			})
+		})
 	})
 
 	Convey("validateGrafanaUser()", t, func() {
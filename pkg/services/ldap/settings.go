@@ -39,7 +39,8 @@ type ServerConfig struct {
 	GroupSearchFilterUserAttribute string   `toml:"group_search_filter_user_attribute"`
 	GroupSearchBaseDNs             []string `toml:"group_search_base_dns"`
 
-	Groups []*GroupToOrgRole `toml:"group_mappings"`
+	Groups         []*GroupToOrgRole `toml:"group_mappings"`
+	PolicyMappings []*GroupToPolicy  `toml:"policy_mappings"`
 }
 
 // AttributeMap is a struct representation for LDAP "attributes" setting
@@ -63,6 +64,14 @@ type GroupToOrgRole struct {
 	OrgRole models.RoleType `toml:"org_role"`
 }
 
+// GroupToPolicy is a struct representation of LDAP
+// config "policy_mappings" setting, binding an RBAC policy to the members of a group
+type GroupToPolicy struct {
+	GroupDN   string `toml:"group_dn"`
+	OrgId     int64  `toml:"org_id"`
+	PolicyUID string `toml:"policy_uid"`
+}
+
 // logger for all LDAP stuff
 var logger = log.New("ldap")
 
@@ -157,6 +166,11 @@ func readConfig(configFile string) (*Config, error) {
 				groupMap.OrgId = 1
 			}
 		}
+		for _, policyMap := range server.PolicyMappings {
+			if policyMap.OrgId == 0 {
+				policyMap.OrgId = 1
+			}
+		}
 	}
 
 	return result, nil
@@ -112,6 +112,19 @@ func (ls *LoginService) UpsertUser(cmd *models.UpsertUserCommand) error {
 		}
 	}
 
+	if extUser.PolicyUIDs != nil || extUser.Groups != nil {
+		syncCmd := &models.SyncUserPoliciesCommand{
+			UserId:     cmd.Result.Id,
+			AuthModule: extUser.AuthModule,
+			PolicyUIDs: extUser.PolicyUIDs,
+			Groups:     extUser.Groups,
+		}
+		// The handler is only registered when RBAC is enabled
+		if err := ls.Bus.Dispatch(syncCmd); err != nil && !errors.Is(err, bus.ErrHandlerNotFound) {
+			return err
+		}
+	}
+
 	if ls.TeamSync != nil {
 		err := ls.TeamSync(cmd.Result, extUser)
 		if err != nil {
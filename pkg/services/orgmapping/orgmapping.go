@@ -0,0 +1,95 @@
+// Package orgmapping resolves external organization identifiers, such as Grafana Cloud stack slugs
+// or tenant ids, into the numeric ids of Grafana organizations. External identifiers are written
+// "<kind>:<id>", e.g. "stack:prod-eu", and resolved by the mapper registered for the kind, so that
+// API calls and provisioning files don't need to know the internal ids.
+package orgmapping
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/localcache"
+)
+
+// cacheTTL bounds how long a remapped external identifier keeps resolving to its previous organization.
+const cacheTTL = time.Minute
+
+var (
+	// ErrUnknownKind is an error for when no mapper is registered for the kind of an external identifier.
+	ErrUnknownKind = errors.New("no organization mapper registered for this kind of identifier")
+	// ErrInvalidReference is an error for when an organization reference is neither a numeric id
+	// nor an external identifier.
+	ErrInvalidReference = errors.New("organization reference must be a numeric id or <kind>:<id>")
+)
+
+// Mapper translates an external identifier, without its kind prefix, into an organization id.
+type Mapper interface {
+	MapOrgID(ctx context.Context, externalID string) (int64, error)
+}
+
+// MapperFunc adapts a function to a Mapper.
+type MapperFunc func(ctx context.Context, externalID string) (int64, error)
+
+// MapOrgID calls f(ctx, externalID).
+func (f MapperFunc) MapOrgID(ctx context.Context, externalID string) (int64, error) {
+	return f(ctx, externalID)
+}
+
+var (
+	mu      sync.RWMutex
+	mappers = map[string]Mapper{}
+	cache   = localcache.New(cacheTTL, 2*cacheTTL)
+)
+
+// Register registers the mapper for the external identifiers of a kind, e.g. "stack". Registering
+// the same kind twice replaces the mapper.
+func Register(kind string, mapper Mapper) {
+	mu.Lock()
+	defer mu.Unlock()
+
+	mappers[kind] = mapper
+	cache.Flush()
+}
+
+// IsExternal returns true if the reference is an external identifier rather than a numeric id.
+func IsExternal(ref string) bool {
+	return strings.Contains(ref, ":")
+}
+
+// Resolve returns the organization id a reference stands for. Numeric references are ids and are
+// returned as is, external identifiers are resolved through the mapper of their kind and cached
+// for a short while.
+func Resolve(ctx context.Context, ref string) (int64, error) {
+	if !IsExternal(ref) {
+		orgID, err := strconv.ParseInt(ref, 10, 64)
+		if err != nil {
+			return 0, ErrInvalidReference
+		}
+		return orgID, nil
+	}
+
+	if cached, found := cache.Get(ref); found {
+		return cached.(int64), nil
+	}
+
+	parts := strings.SplitN(ref, ":", 2)
+	mu.RLock()
+	mapper, ok := mappers[parts[0]]
+	mu.RUnlock()
+	if !ok {
+		return 0, fmt.Errorf("%w: %q", ErrUnknownKind, parts[0])
+	}
+
+	orgID, err := mapper.MapOrgID(ctx, parts[1])
+	if err != nil {
+		return 0, fmt.Errorf("failed to resolve organization %q: %w", ref, err)
+	}
+	cache.Set(ref, orgID, 0)
+
+	return orgID, nil
+}
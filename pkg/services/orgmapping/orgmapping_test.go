@@ -0,0 +1,50 @@
+package orgmapping
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestResolve(t *testing.T) {
+	calls := 0
+	Register("stack", MapperFunc(func(ctx context.Context, externalID string) (int64, error) {
+		calls++
+		if externalID == "prod-eu" {
+			return 4, nil
+		}
+		return 0, errors.New("unknown stack")
+	}))
+
+	orgID, err := Resolve(context.Background(), "2")
+	require.NoError(t, err)
+	assert.Equal(t, int64(2), orgID)
+
+	orgID, err = Resolve(context.Background(), "stack:prod-eu")
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), orgID)
+
+	t.Run("Resolved identifiers should be cached", func(t *testing.T) {
+		_, err := Resolve(context.Background(), "stack:prod-eu")
+		require.NoError(t, err)
+		assert.Equal(t, 1, calls)
+	})
+
+	t.Run("Unmapped identifier should fail", func(t *testing.T) {
+		_, err := Resolve(context.Background(), "stack:prod-us")
+		require.Error(t, err)
+	})
+
+	t.Run("Unknown kind should fail", func(t *testing.T) {
+		_, err := Resolve(context.Background(), "tenant:abc")
+		require.ErrorIs(t, err, ErrUnknownKind)
+	})
+
+	t.Run("Invalid reference should fail", func(t *testing.T) {
+		_, err := Resolve(context.Background(), "main")
+		require.ErrorIs(t, err, ErrInvalidReference)
+	})
+}
@@ -88,6 +88,11 @@ func (cr *configReader) readConfig() ([]*config, error) {
 
 	uidUsage := map[string]uint8{}
 	for _, dashboard := range dashboards {
+		orgID, err := utils.ResolveOrgRef(dashboard.OrgID, dashboard.OrgRef)
+		if err != nil {
+			return nil, fmt.Errorf("failed to provision dashboards with %q reader: %w", dashboard.Name, err)
+		}
+		dashboard.OrgID = orgID
 		if dashboard.OrgID == 0 {
 			dashboard.OrgID = 1
 		}
@@ -14,6 +14,7 @@ type config struct {
 	Name                  string
 	Type                  string
 	OrgID                 int64
+	OrgRef                string
 	Folder                string
 	FolderUID             string
 	Editable              bool
@@ -48,6 +49,7 @@ type configs struct {
 	Name                  values.StringValue `json:"name" yaml:"name"`
 	Type                  values.StringValue `json:"type" yaml:"type"`
 	OrgID                 values.Int64Value  `json:"orgId" yaml:"orgId"`
+	Org                   values.StringValue `json:"org" yaml:"org"`
 	Folder                values.StringValue `json:"folder" yaml:"folder"`
 	FolderUID             values.StringValue `json:"folderUid" yaml:"folderUid"`
 	Editable              values.BoolValue   `json:"editable" yaml:"editable"`
@@ -114,6 +116,7 @@ func (dc *configV1) mapToDashboardsAsConfig() ([]*config, error) {
 			Name:                  v.Name.Value(),
 			Type:                  v.Type.Value(),
 			OrgID:                 v.OrgID.Value(),
+			OrgRef:                v.Org.Value(),
 			Folder:                v.Folder.Value(),
 			FolderUID:             v.FolderUID.Value(),
 			Editable:              v.Editable.Value(),
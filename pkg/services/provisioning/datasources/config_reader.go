@@ -96,6 +96,11 @@ func (cr *configReader) validateDefaultUniqueness(datasources []*configs) error
 		}
 
 		for _, ds := range datasources[i].Datasources {
+			orgID, err := utils.ResolveOrgRef(ds.OrgID, ds.OrgRef)
+			if err != nil {
+				return fmt.Errorf("failed to provision %q data source: %w", ds.Name, err)
+			}
+			ds.OrgID = orgID
 			if ds.OrgID == 0 {
 				ds.OrgID = 1
 			}
@@ -113,6 +118,11 @@ func (cr *configReader) validateDefaultUniqueness(datasources []*configs) error
 		}
 
 		for _, ds := range datasources[i].DeleteDatasources {
+			orgID, err := utils.ResolveOrgRef(ds.OrgID, ds.OrgRef)
+			if err != nil {
+				return fmt.Errorf("failed to delete %q data source: %w", ds.Name, err)
+			}
+			ds.OrgID = orgID
 			if ds.OrgID == 0 {
 				ds.OrgID = 1
 			}
@@ -1,12 +1,14 @@
 package datasources
 
 import (
+	"context"
 	"os"
 	"testing"
 
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/infra/log"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/orgmapping"
 
 	. "github.com/smartystreets/goconvey/convey"
 )
@@ -23,6 +25,7 @@ var (
 	multipleOrgsWithDefault         = "testdata/multiple-org-default"
 	withoutDefaults                 = "testdata/appliedDefaults"
 	invalidAccess                   = "testdata/invalid-access"
+	externalOrg                     = "testdata/external-org"
 
 	fakeRepo *fakeRepository
 )
@@ -102,6 +105,19 @@ func TestDatasourceAsConfig(t *testing.T) {
 			})
 		})
 
+		Convey("Datasource in an organization identified by an external identifier", func() {
+			orgmapping.Register("test-stack", orgmapping.MapperFunc(func(ctx context.Context, externalID string) (int64, error) {
+				return 2, nil
+			}))
+			dc := newDatasourceProvisioner(logger)
+			err := dc.applyChanges(externalOrg)
+			Convey("should be inserted in the mapped organization", func() {
+				So(err, ShouldBeNil)
+				So(len(fakeRepo.inserted), ShouldEqual, 1)
+				So(fakeRepo.inserted[0].OrgId, ShouldEqual, 2)
+			})
+		})
+
 		Convey("Two configured datasource and purge others ", func() {
 			Convey("two other datasources in database", func() {
 				fakeRepo.loadAll = []*models.DataSource{
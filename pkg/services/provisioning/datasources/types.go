@@ -20,12 +20,14 @@ type configs struct {
 }
 
 type deleteDatasourceConfig struct {
-	OrgID int64
-	Name  string
+	OrgID  int64
+	OrgRef string
+	Name   string
 }
 
 type upsertDataSourceFromConfig struct {
 	OrgID   int64
+	OrgRef  string
 	Version int
 
 	Name              string
@@ -68,6 +70,7 @@ type deleteDatasourceConfigV0 struct {
 
 type deleteDatasourceConfigV1 struct {
 	OrgID values.Int64Value  `json:"orgId" yaml:"orgId"`
+	Org   values.StringValue `json:"org" yaml:"org"`
 	Name  values.StringValue `json:"name" yaml:"name"`
 }
 
@@ -93,6 +96,7 @@ type upsertDataSourceFromConfigV0 struct {
 
 type upsertDataSourceFromConfigV1 struct {
 	OrgID             values.Int64Value     `json:"orgId" yaml:"orgId"`
+	Org               values.StringValue    `json:"org" yaml:"org"`
 	Version           values.IntValue       `json:"version" yaml:"version"`
 	Name              values.StringValue    `json:"name" yaml:"name"`
 	Type              values.StringValue    `json:"type" yaml:"type"`
@@ -124,6 +128,7 @@ func (cfg *configsV1) mapToDatasourceFromConfig(apiVersion int64) *configs {
 	for _, ds := range cfg.Datasources {
 		r.Datasources = append(r.Datasources, &upsertDataSourceFromConfig{
 			OrgID:             ds.OrgID.Value(),
+			OrgRef:            ds.Org.Value(),
 			Name:              ds.Name.Value(),
 			Type:              ds.Type.Value(),
 			Access:            ds.Access.Value(),
@@ -163,8 +168,9 @@ func (cfg *configsV1) mapToDatasourceFromConfig(apiVersion int64) *configs {
 
 	for _, ds := range cfg.DeleteDatasources {
 		r.DeleteDatasources = append(r.DeleteDatasources, &deleteDatasourceConfig{
-			OrgID: ds.OrgID.Value(),
-			Name:  ds.Name.Value(),
+			OrgID:  ds.OrgID.Value(),
+			OrgRef: ds.Org.Value(),
+			Name:   ds.Name.Value(),
 		})
 	}
 
@@ -1,11 +1,13 @@
 package utils
 
 import (
+	"context"
 	"errors"
 	"fmt"
 
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/orgmapping"
 )
 
 func CheckOrgExists(orgID int64) error {
@@ -18,3 +20,13 @@ func CheckOrgExists(orgID int64) error {
 	}
 	return nil
 }
+
+// ResolveOrgRef returns the id of the organization referenced by orgRef, a numeric id or an external
+// identifier resolved through orgmapping, or orgID when orgRef is empty.
+func ResolveOrgRef(orgID int64, orgRef string) (int64, error) {
+	if orgRef == "" {
+		return orgID, nil
+	}
+
+	return orgmapping.Resolve(context.Background(), orgRef)
+}
@@ -0,0 +1,279 @@
+package rbac
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// ActionAccessRequestsApprove is the action for approving or denying the requests for a policy, scoped
+// by the policy, e.g. policies:uid:prod-datasource-query.
+const ActionAccessRequestsApprove = "accessrequests:approve"
+
+// BindingReasonAccessRequest is the reason of the PolicyBound events of approved access requests.
+const BindingReasonAccessRequest = "accessRequest"
+
+func init() {
+	RegisterActions(ActionDefinition{Action: ActionAccessRequestsApprove, Description: "Approve or deny the requests for a policy"})
+}
+
+// ScopePolicyUID returns the scope of a policy identified by its uid.
+func ScopePolicyUID(uid string) string {
+	return "policies:uid:" + uid
+}
+
+// CanReviewAccessRequest returns true if the user may approve or deny the access request.
+func (ac *RBACService) CanReviewAccessRequest(ctx context.Context, user *models.SignedInUser, request *AccessRequest) (bool, error) {
+	return ac.evaluate(ctx, user, accessRequest{Action: ActionAccessRequestsApprove, Scope: ScopePolicyUID(request.PolicyUID)})
+}
+
+// ReviewableAccessRequests returns the access requests the user may approve or deny, with the user's
+// permissions resolved once for all of them.
+func (ac *RBACService) ReviewableAccessRequests(ctx context.Context, user *models.SignedInUser, requests []*AccessRequest) ([]*AccessRequest, error) {
+	scopes := make([]string, 0, len(requests))
+	for _, request := range requests {
+		scopes = append(scopes, ScopePolicyUID(request.PolicyUID))
+	}
+	allowed, err := ac.EvaluateAll(ctx, user, ActionAccessRequestsApprove, scopes)
+	if err != nil {
+		return nil, err
+	}
+
+	reviewable := make([]*AccessRequest, 0, len(requests))
+	for _, request := range requests {
+		if allowed[ScopePolicyUID(request.PolicyUID)] {
+			reviewable = append(reviewable, request)
+		}
+	}
+	return reviewable, nil
+}
+
+// CreateAccessRequest records the request of a user to be bound to a policy, and publishes an
+// AccessRequestCreated event so that the approvers of the policy can be notified. Policies managed
+// by Grafana can't be requested.
+func (ac *RBACService) CreateAccessRequest(ctx context.Context, cmd CreateAccessRequestCommand) (*AccessRequest, error) {
+	if err := ac.checkSchemaVersion(schemaVersionAccessRequest); err != nil {
+		return nil, err
+	}
+	if cmd.Duration < 0 {
+		return nil, ErrInvalidAccessRequest
+	}
+
+	request := &AccessRequest{
+		OrgID:           cmd.OrgID,
+		UserID:          cmd.UserID,
+		PolicyID:        cmd.PolicyID,
+		Justification:   strings.TrimSpace(cmd.Justification),
+		DurationSeconds: int64(cmd.Duration / time.Second),
+		State:           AccessRequestPending,
+		Created:         time.Now(),
+	}
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		policy, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID})
+		if err != nil {
+			return err
+		}
+		if isManagedPolicy(policy.UID) {
+			return ErrInvalidAccessRequest
+		}
+		request.PolicyUID = policy.UID
+
+		bound, err := sess.Table("user_policy").Where("org_id = ? AND user_id = ? AND policy_id = ?", cmd.OrgID, cmd.UserID, cmd.PolicyID).Exist()
+		if err != nil {
+			return err
+		}
+		if bound {
+			return ErrUserPolicyAlreadyAdded
+		}
+		pending, err := sess.Table("access_request").Where("org_id = ? AND user_id = ? AND policy_id = ? AND state = ?",
+			cmd.OrgID, cmd.UserID, cmd.PolicyID, AccessRequestPending).Exist()
+		if err != nil {
+			return err
+		}
+		if pending {
+			return ErrAccessRequestPending
+		}
+
+		_, err = sess.Table("access_request").Insert(request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.log.Info("Requested access", "orgId", cmd.OrgID, "userId", cmd.UserID, "policyId", cmd.PolicyID, "requestId", request.ID)
+	ac.publishAccessRequest(&events.AccessRequestCreated{
+		Timestamp: request.Created,
+		OrgID:     request.OrgID,
+		RequestID: request.ID,
+		UserID:    request.UserID,
+		PolicyID:  request.PolicyID,
+		PolicyUID: request.PolicyUID,
+	})
+
+	return request, nil
+}
+
+// GetAccessRequest returns an access request of an organization.
+func (ac *RBACService) GetAccessRequest(ctx context.Context, orgID, id int64) (*AccessRequest, error) {
+	var request *AccessRequest
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		request, err = getAccessRequest(sess, orgID, id)
+		return err
+	})
+
+	return request, err
+}
+
+// GetAccessRequests returns the access requests of an organization, most recent first.
+func (ac *RBACService) GetAccessRequests(ctx context.Context, query GetAccessRequestsQuery) ([]*AccessRequest, error) {
+	requests := make([]*AccessRequest, 0)
+	if ac.schemaVersion < schemaVersionAccessRequest {
+		return requests, nil
+	}
+
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := `SELECT access_request.*, policy.uid AS policy_uid FROM access_request
+			INNER JOIN policy ON policy.id = access_request.policy_id
+			WHERE access_request.org_id = ?`
+		args := []interface{}{query.OrgID}
+		if query.UserID != 0 {
+			q += " AND access_request.user_id = ?"
+			args = append(args, query.UserID)
+		}
+		if query.State != "" {
+			q += " AND access_request.state = ?"
+			args = append(args, query.State)
+		}
+		q += " ORDER BY access_request.created DESC, access_request.id DESC"
+
+		var err error
+		requests, err = findAccessRequests(sess, q, args...)
+		return err
+	})
+
+	return requests, err
+}
+
+// ReviewAccessRequest approves or denies a pending access request. Approved requests bind the policy
+// to the requester, until the requested duration has passed when one was requested. Approving fails
+// with ErrBindingExpiryBeyondLifetime when the requested duration outlives the binding lifetime of
+// the organization. An AccessRequestReviewed event is published so that the requester can be notified.
+func (ac *RBACService) ReviewAccessRequest(ctx context.Context, cmd ReviewAccessRequestCommand) (*AccessRequest, error) {
+	var request *AccessRequest
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		request, err = getAccessRequest(sess, cmd.OrgID, cmd.ID)
+		if err != nil {
+			return err
+		}
+		if request.State != AccessRequestPending {
+			return ErrAccessRequestReviewed
+		}
+		if request.UserID == cmd.ReviewedBy {
+			return ErrAccessRequestSelfReview
+		}
+
+		now := time.Now()
+		request.State = AccessRequestDenied
+		if cmd.Approve {
+			request.State = AccessRequestApproved
+
+			var requested *time.Time
+			if request.DurationSeconds > 0 {
+				expiresAt := now.Add(time.Duration(request.DurationSeconds) * time.Second)
+				requested = &expiresAt
+			}
+			expiresAt, err := ac.userPolicyExpiry(sess, cmd.OrgID, requested)
+			if err != nil {
+				return err
+			}
+			if err := ac.addUserPolicy(sess, &UserPolicy{
+				OrgID:     cmd.OrgID,
+				PolicyID:  request.PolicyID,
+				UserID:    request.UserID,
+				ExpiresAt: expiresAt,
+				GrantedBy: cmd.ReviewedBy,
+				Reason:    request.Justification,
+			}); err != nil {
+				return err
+			}
+		}
+		request.ReviewedBy = cmd.ReviewedBy
+		request.ReviewComment = strings.TrimSpace(cmd.Comment)
+		request.Reviewed = &now
+
+		_, err = sess.Table("access_request").ID(request.ID).Cols("state", "reviewed_by", "review_comment", "reviewed").Update(request)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.log.Info("Reviewed access request", "orgId", cmd.OrgID, "requestId", request.ID, "userId", request.UserID,
+		"policyId", request.PolicyID, "reviewedBy", cmd.ReviewedBy, "state", request.State)
+	ac.publishAccessRequest(&events.AccessRequestReviewed{
+		Timestamp:  *request.Reviewed,
+		OrgID:      request.OrgID,
+		RequestID:  request.ID,
+		UserID:     request.UserID,
+		PolicyID:   request.PolicyID,
+		ReviewedBy: request.ReviewedBy,
+		Approved:   cmd.Approve,
+		Comment:    request.ReviewComment,
+	})
+	if cmd.Approve {
+		changes := newBindingChanges(cmd.ReviewedBy, BindingReasonAccessRequest)
+		changes.userBound(request.OrgID, request.PolicyID, request.UserID)
+		ac.publishBindingChanges(changes)
+	}
+
+	return request, nil
+}
+
+func (ac *RBACService) publishAccessRequest(e interface{}) {
+	if err := bus.Publish(e); err != nil {
+		ac.log.Error("Failed to publish access request event", "error", err)
+	}
+}
+
+func getAccessRequest(sess *sqlstore.DBSession, orgID, id int64) (*AccessRequest, error) {
+	q := `SELECT access_request.*, policy.uid AS policy_uid FROM access_request
+		INNER JOIN policy ON policy.id = access_request.policy_id
+		WHERE access_request.org_id = ? AND access_request.id = ?`
+	requests, err := findAccessRequests(sess, q, orgID, id)
+	if err != nil {
+		return nil, err
+	}
+	if len(requests) == 0 {
+		return nil, ErrAccessRequestNotFound
+	}
+
+	return requests[0], nil
+}
+
+// findAccessRequests runs a query selecting access_request.* and the uid of the policy as policy_uid.
+func findAccessRequests(sess *sqlstore.DBSession, q string, args ...interface{}) ([]*AccessRequest, error) {
+	var rows []struct {
+		AccessRequest `xorm:"extends"`
+		PolicyUID     string `xorm:"policy_uid"`
+	}
+	if err := sess.SQL(q, args...).Find(&rows); err != nil {
+		return nil, err
+	}
+
+	requests := make([]*AccessRequest, 0, len(rows))
+	for _, row := range rows {
+		request := row.AccessRequest
+		request.PolicyUID = row.PolicyUID
+		requests = append(requests, &request)
+	}
+
+	return requests, nil
+}
@@ -0,0 +1,106 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestAccessRequests(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	const requesterID, approverID = 381, 382
+	policy := createPolicy(t, ac, 1, "Prod Datasource Query", CreatePermissionCommand{Action: ActionDatasourcesQuery, Scope: "datasources:*"})
+	approverPolicy := createPolicy(t, ac, 1, "prod approver", CreatePermissionCommand{Action: ActionAccessRequestsApprove, Scope: ScopePolicyUID(policy.UID)})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: approverID, PolicyID: approverPolicy.ID}))
+
+	request, err := ac.CreateAccessRequest(context.Background(), CreateAccessRequestCommand{
+		OrgID: 1, UserID: requesterID, PolicyID: policy.ID, Justification: "on call this week", Duration: 8 * time.Hour,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, AccessRequestPending, request.State)
+	assert.Equal(t, policy.UID, request.PolicyUID)
+
+	t.Run("Only approvers of the policy should review", func(t *testing.T) {
+		approver := &models.SignedInUser{OrgId: 1, UserId: approverID}
+		allowed, err := ac.CanReviewAccessRequest(context.Background(), approver, request)
+		require.NoError(t, err)
+		assert.True(t, allowed)
+
+		requester := &models.SignedInUser{OrgId: 1, UserId: requesterID}
+		allowed, err = ac.CanReviewAccessRequest(context.Background(), requester, request)
+		require.NoError(t, err)
+		assert.False(t, allowed)
+
+		_, err = ac.ReviewAccessRequest(context.Background(), ReviewAccessRequestCommand{OrgID: 1, ID: request.ID, ReviewedBy: requesterID, Approve: true})
+		require.ErrorIs(t, err, ErrAccessRequestSelfReview)
+
+		reviewable, err := ac.ReviewableAccessRequests(context.Background(), approver, []*AccessRequest{request})
+		require.NoError(t, err)
+		assert.Len(t, reviewable, 1)
+		reviewable, err = ac.ReviewableAccessRequests(context.Background(), requester, []*AccessRequest{request})
+		require.NoError(t, err)
+		assert.Empty(t, reviewable)
+	})
+
+	t.Run("Requesting a policy twice should fail", func(t *testing.T) {
+		_, err := ac.CreateAccessRequest(context.Background(), CreateAccessRequestCommand{OrgID: 1, UserID: requesterID, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrAccessRequestPending)
+	})
+
+	t.Run("Approved requests should bind the policy until the requested duration has passed", func(t *testing.T) {
+		reviewed, err := ac.ReviewAccessRequest(context.Background(), ReviewAccessRequestCommand{
+			OrgID: 1, ID: request.ID, ReviewedBy: approverID, Approve: true, Comment: "ok",
+		})
+		require.NoError(t, err)
+		assert.Equal(t, AccessRequestApproved, reviewed.State)
+		assert.Equal(t, int64(approverID), reviewed.ReviewedBy)
+
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: requesterID})
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+		assert.Equal(t, policy.ID, policies[0].ID)
+
+		var binding UserPolicy
+		err = ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Table("user_policy").Where("user_id = ?", requesterID).Get(&binding)
+			return err
+		})
+		require.NoError(t, err)
+		require.NotNil(t, binding.ExpiresAt)
+		assert.WithinDuration(t, time.Now().Add(8*time.Hour), *binding.ExpiresAt, time.Minute)
+
+		_, err = ac.ReviewAccessRequest(context.Background(), ReviewAccessRequestCommand{OrgID: 1, ID: request.ID, ReviewedBy: approverID})
+		require.ErrorIs(t, err, ErrAccessRequestReviewed)
+	})
+
+	t.Run("Denied requests shouldn't bind the policy", func(t *testing.T) {
+		other := createPolicy(t, ac, 1, "Prod Datasource Write")
+		denied, err := ac.CreateAccessRequest(context.Background(), CreateAccessRequestCommand{OrgID: 1, UserID: requesterID, PolicyID: other.ID})
+		require.NoError(t, err)
+		_, err = ac.ReviewAccessRequest(context.Background(), ReviewAccessRequestCommand{OrgID: 1, ID: denied.ID, ReviewedBy: approverID})
+		require.NoError(t, err)
+
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: requesterID})
+		require.NoError(t, err)
+		assert.Len(t, policies, 1)
+
+		requests, err := ac.GetAccessRequests(context.Background(), GetAccessRequestsQuery{OrgID: 1, UserID: requesterID})
+		require.NoError(t, err)
+		require.Len(t, requests, 2)
+		assert.Equal(t, AccessRequestDenied, requests[0].State)
+		assert.Equal(t, AccessRequestApproved, requests[1].State)
+	})
+
+	t.Run("Managed policies shouldn't be requested", func(t *testing.T) {
+		managed := createManagedPolicy(t, ac, 1, "managed-requested")
+		_, err := ac.CreateAccessRequest(context.Background(), CreateAccessRequestCommand{OrgID: 1, UserID: requesterID, PolicyID: managed.ID})
+		require.ErrorIs(t, err, ErrInvalidAccessRequest)
+	})
+}
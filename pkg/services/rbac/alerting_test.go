@@ -0,0 +1,41 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestAlertingPermissions(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "on-call")
+	addTeamMember(t, 1, team.Id, 7)
+	policy := createPolicy(t, ac, 1, "silencer",
+		CreatePermissionCommand{Action: ActionSilencesCreate, Scope: ScopeAlertNamespace("payments")},
+		CreatePermissionCommand{Action: ActionSilencesRead, Scope: "alerts:namespace:*"},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 7, OrgRole: models.ROLE_VIEWER}
+
+	ok, err := ac.CanCreateSilence(context.Background(), user, "payments")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ac.CanCreateSilence(context.Background(), user, "search")
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = ac.CanReadSilences(context.Background(), user, "search")
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ac.CanWriteNotificationPolicies(context.Background(), user, "payments")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
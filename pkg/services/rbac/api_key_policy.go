@@ -0,0 +1,201 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// GetAPIKeyPolicies returns the policies attached to an API key.
+func (ac *RBACService) GetAPIKeyPolicies(ctx context.Context, query GetAPIKeyPoliciesQuery) ([]*PolicyDTO, error) {
+	var policies []*PolicyDTO
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := `SELECT policy.* FROM policy
+			INNER JOIN api_key_policy ON policy.id = api_key_policy.policy_id
+			WHERE api_key_policy.org_id = ? AND api_key_policy.api_key_id = ?
+			ORDER BY policy.name ASC`
+		return sess.SQL(q, query.OrgID, query.APIKeyID).Find(&policies)
+	})
+
+	return policies, err
+}
+
+// AddAPIKeyPolicy attaches a policy to an API key. Once a key has policies, its permissions are
+// those of its policies instead of those of its role, restricted to what every user who attached
+// them holds, so that a key can be limited to e.g. reading the dashboards of one folder.
+func (ac *RBACService) AddAPIKeyPolicy(ctx context.Context, cmd AddAPIKeyPolicyCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionAPIKeyPolicy); err != nil {
+		return err
+	}
+
+	keyQuery := models.GetApiKeyByIdQuery{ApiKeyId: cmd.APIKeyID}
+	if err := bus.Dispatch(&keyQuery); err != nil {
+		return err
+	}
+	if keyQuery.Result.OrgId != cmd.OrgID {
+		return models.ErrInvalidApiKey
+	}
+
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID}); err != nil {
+			return err
+		}
+
+		apiKeyPolicy := &APIKeyPolicy{
+			OrgID:     cmd.OrgID,
+			APIKeyID:  cmd.APIKeyID,
+			PolicyID:  cmd.PolicyID,
+			CreatedBy: cmd.CreatedBy,
+			Created:   time.Now(),
+		}
+		if _, err := sess.Table("api_key_policy").Insert(apiKeyPolicy); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrAPIKeyPolicyAlreadyAdded
+			}
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	// The denials of API keys aren't cached per user, so the whole organization is invalidated.
+	ac.publishPolicyChanged(cmd.OrgID, cmd.PolicyID, PolicyChangedAPIKeyAttached)
+
+	return nil
+}
+
+// RemoveAPIKeyPolicy detaches a policy from an API key. A key without policies gets the
+// permissions of its role again, so removing its last policy is refused unless AllowRoleAccess is set.
+func (ac *RBACService) RemoveAPIKeyPolicy(ctx context.Context, cmd RemoveAPIKeyPolicyCommand) error {
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := "DELETE FROM api_key_policy WHERE org_id = ? AND api_key_id = ? AND policy_id = ?"
+		result, err := sess.Exec(q, cmd.OrgID, cmd.APIKeyID, cmd.PolicyID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return ErrAPIKeyPolicyNotFound
+		}
+
+		if !cmd.AllowRoleAccess {
+			remaining, err := sess.Table("api_key_policy").Where("org_id = ? AND api_key_id = ?", cmd.OrgID, cmd.APIKeyID).Exist()
+			if err != nil {
+				return err
+			}
+			if !remaining {
+				return ErrAPIKeyLastPolicy
+			}
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishPolicyChanged(cmd.OrgID, cmd.PolicyID, PolicyChangedAPIKeyDetached)
+
+	return nil
+}
+
+// decideAPIKey evaluates a request made with an API key that has policies attached. The request
+// must be granted both by the key's policies and by the permissions of each user who attached
+// them. It returns a nil decision when the key has no policies.
+func (ac *RBACService) decideAPIKey(ctx context.Context, req DecisionRequest) (*Decision, error) {
+	permissions, creators, err := ac.apiKeyPermissions(ctx, req.User)
+	if err != nil || len(creators) == 0 {
+		return nil, err
+	}
+
+	decision := &Decision{}
+	decision.Annotate("apiKeyPolicies", "true")
+	if !req.Evaluator.Evaluate(permissions, req.Environment) {
+		return decision, nil
+	}
+
+	for _, creatorID := range creators {
+		creator, err := ac.apiKeyCreator(req.User.OrgId, creatorID)
+		if err != nil {
+			return nil, err
+		}
+		if creator == nil {
+			// The policies of deleted users grant nothing.
+			decision.Annotate("deniedByCreator", strconv.FormatInt(creatorID, 10))
+			return decision, nil
+		}
+
+		creatorPermissions, err := ac.resolveUserPermissions(ctx, creator)
+		if err != nil {
+			return nil, err
+		}
+		if !req.Evaluator.Evaluate(creatorPermissions, req.Environment) {
+			decision.Annotate("deniedByCreator", strconv.FormatInt(creatorID, 10))
+			return decision, nil
+		}
+	}
+
+	decision.Allowed = true
+	return decision, nil
+}
+
+// apiKeyPermissions returns the resolved permissions of the policies attached to the API key of a user
+// and the users who attached them, no users when the key has no policies.
+func (ac *RBACService) apiKeyPermissions(ctx context.Context, user *models.SignedInUser) ([]Permission, []int64, error) {
+	var permissions []Permission
+	var creators []int64
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if err := sess.Table("api_key_policy").Where("org_id = ? AND api_key_id = ?", user.OrgId, user.ApiKeyId).
+			Distinct("created_by").Cols("created_by").Find(&creators); err != nil {
+			return err
+		}
+		if len(creators) == 0 {
+			return nil
+		}
+
+		q := `SELECT permission.*, policy.precedence FROM permission
+			INNER JOIN policy ON permission.policy_id = policy.id
+			INNER JOIN api_key_policy ON permission.policy_id = api_key_policy.policy_id
+			WHERE api_key_policy.org_id = ? AND api_key_policy.api_key_id = ? AND policy.enabled = ?
+			AND (permission.expires_at IS NULL OR permission.expires_at > ?)`
+		var err error
+		start := time.Now()
+		permissions, err = findPermissionsWithPrecedence(sess, q, user.OrgId, user.ApiKeyId, true, start)
+		decisionTimingsFromContext(ctx).since(PhaseDBResolution, start)
+		return err
+	})
+	if err != nil || len(creators) == 0 {
+		return nil, nil, err
+	}
+
+	permissions, err = ac.resolvePermissions(ctx, user, permissions)
+	if err != nil {
+		return nil, nil, err
+	}
+	return permissions, creators, nil
+}
+
+// apiKeyCreator returns the user who attached policies to an API key, in the organization of the key,
+// nil when the user was deleted.
+func (ac *RBACService) apiKeyCreator(orgID, creatorID int64) (*models.SignedInUser, error) {
+	query := models.GetSignedInUserQuery{UserId: creatorID, OrgId: orgID}
+	if err := ac.SQLStore.GetSignedInUserWithCache(&query); err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return nil, nil
+		}
+		return nil, err
+	}
+
+	creator := query.Result
+	creator.OrgId = orgID
+	return creator, nil
+}
@@ -0,0 +1,82 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAPIKeyPolicies(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	creator := &models.CreateUserCommand{Login: "key-creator", SkipOrgSetup: true}
+	require.NoError(t, sqlstore.CreateUser(context.Background(), creator))
+	creatorPolicy := createPolicy(t, ac, 1, "creator",
+		CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"},
+		CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:uid:abc"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: creator.Result.Id, PolicyID: creatorPolicy.ID}))
+
+	roleKeys := createPolicy(t, ac, 1, "editors", CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:*"})
+	require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Editor", PolicyID: roleKeys.ID}))
+
+	key := &models.AddApiKeyCommand{OrgId: 1, Name: "deploy", Role: models.ROLE_EDITOR, Key: "secret"}
+	require.NoError(t, sqlstore.AddApiKey(key))
+	keyUser := &models.SignedInUser{OrgId: 1, ApiKeyId: key.Result.Id, OrgRole: models.ROLE_EDITOR}
+
+	// Without policies the key gets the permissions of its role.
+	ok, err := ac.evaluate(context.Background(), keyUser, accessRequest{Action: "datasources:read", Scope: "datasources:id:1"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	keyPolicy := createPolicy(t, ac, 1, "key",
+		CreatePermissionCommand{Action: "dashboards:read", Scope: "folders:uid:ops"},
+		CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddAPIKeyPolicy(context.Background(), AddAPIKeyPolicyCommand{
+		OrgID: 1, APIKeyID: key.Result.Id, PolicyID: keyPolicy.ID, CreatedBy: creator.Result.Id,
+	}))
+	err = ac.AddAPIKeyPolicy(context.Background(), AddAPIKeyPolicyCommand{
+		OrgID: 1, APIKeyID: key.Result.Id, PolicyID: keyPolicy.ID, CreatedBy: creator.Result.Id,
+	})
+	require.ErrorIs(t, err, ErrAPIKeyPolicyAlreadyAdded)
+	err = ac.AddAPIKeyPolicy(context.Background(), AddAPIKeyPolicyCommand{OrgID: 2, APIKeyID: key.Result.Id, PolicyID: keyPolicy.ID})
+	require.ErrorIs(t, err, models.ErrInvalidApiKey)
+
+	policies, err := ac.GetAPIKeyPolicies(context.Background(), GetAPIKeyPoliciesQuery{OrgID: 1, APIKeyID: key.Result.Id})
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+
+	for _, tc := range []struct {
+		desc    string
+		req     accessRequest
+		allowed bool
+	}{
+		{desc: "role permissions no longer apply", req: accessRequest{Action: "datasources:read", Scope: "datasources:id:1"}},
+		{desc: "granted by the key but not the creator", req: accessRequest{Action: "dashboards:write", Scope: "dashboards:uid:def"}},
+		{desc: "granted by the key and the creator", req: accessRequest{Action: "dashboards:write", Scope: "dashboards:uid:abc"}, allowed: true},
+		{desc: "granted by the creator but not the key", req: accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:abc"}},
+	} {
+		ok, err := ac.evaluate(context.Background(), keyUser, tc.req)
+		require.NoError(t, err)
+		assert.Equal(t, tc.allowed, ok, tc.desc)
+	}
+
+	// Removing the last policy would give the key the permissions of its role again.
+	err = ac.RemoveAPIKeyPolicy(context.Background(), RemoveAPIKeyPolicyCommand{OrgID: 1, APIKeyID: key.Result.Id, PolicyID: keyPolicy.ID})
+	require.ErrorIs(t, err, ErrAPIKeyLastPolicy)
+	policies, err = ac.GetAPIKeyPolicies(context.Background(), GetAPIKeyPoliciesQuery{OrgID: 1, APIKeyID: key.Result.Id})
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+
+	removeLast := RemoveAPIKeyPolicyCommand{OrgID: 1, APIKeyID: key.Result.Id, PolicyID: keyPolicy.ID, AllowRoleAccess: true}
+	require.NoError(t, ac.RemoveAPIKeyPolicy(context.Background(), removeLast))
+	err = ac.RemoveAPIKeyPolicy(context.Background(), removeLast)
+	require.ErrorIs(t, err, ErrAPIKeyPolicyNotFound)
+
+	ok, err = ac.evaluate(context.Background(), keyUser, accessRequest{Action: "datasources:read", Scope: "datasources:id:1"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,76 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// GetPolicyAssignments returns the teams, users, service accounts, builtin roles and API keys a policy
+// is bound to, i.e. everything that loses access when the policy is deleted, in a single query.
+// Users whose account was deleted are listed with an empty login.
+func (ac *RBACService) GetPolicyAssignments(ctx context.Context, query GetPolicyAssignmentsQuery) (*PolicyAssignments, error) {
+	var assignments []*PolicyAssignment
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: query.OrgID, PolicyID: query.PolicyID}); err != nil {
+			return err
+		}
+
+		userTable := ac.SQLStore.Dialect.Quote("user")
+		q := `SELECT 'team' AS kind, team.id AS id, team.name AS name, team_policy.expires_at AS expires_at,
+				team_policy.granted_by AS granted_by, team_policy.reason AS reason
+			FROM team_policy
+			INNER JOIN team ON team.id = team_policy.team_id
+			WHERE team_policy.org_id = ? AND team_policy.policy_id = ?
+			UNION ALL
+			SELECT CASE WHEN service_account.id IS NULL THEN 'user' ELSE 'serviceAccount' END AS kind,
+				user_policy.user_id AS id, COALESCE(` + userTable + `.login, '') AS name, user_policy.expires_at AS expires_at,
+				user_policy.granted_by AS granted_by, user_policy.reason AS reason
+			FROM user_policy
+			LEFT JOIN ` + userTable + ` ON ` + userTable + `.id = user_policy.user_id
+			LEFT JOIN service_account ON service_account.org_id = user_policy.org_id AND service_account.user_id = user_policy.user_id
+			WHERE user_policy.org_id = ? AND user_policy.policy_id = ?
+			UNION ALL
+			SELECT 'builtinRole' AS kind, 0 AS id, builtin_role_policy.role AS name, NULL AS expires_at,
+				NULL AS granted_by, NULL AS reason
+			FROM builtin_role_policy
+			WHERE builtin_role_policy.org_id = ? AND builtin_role_policy.policy_id = ?
+			UNION ALL
+			SELECT 'apiKey' AS kind, api_key.id AS id, api_key.name AS name, NULL AS expires_at,
+				NULL AS granted_by, NULL AS reason
+			FROM api_key_policy
+			INNER JOIN api_key ON api_key.id = api_key_policy.api_key_id
+			WHERE api_key_policy.org_id = ? AND api_key_policy.policy_id = ?
+			ORDER BY kind, name`
+		return sess.SQL(q, query.OrgID, query.PolicyID, query.OrgID, query.PolicyID, query.OrgID, query.PolicyID,
+			query.OrgID, query.PolicyID).Find(&assignments)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &PolicyAssignments{
+		Teams:           []*PolicyAssignment{},
+		Users:           []*PolicyAssignment{},
+		ServiceAccounts: []*PolicyAssignment{},
+		BuiltinRoles:    []*PolicyAssignment{},
+		APIKeys:         []*PolicyAssignment{},
+		Total:           len(assignments),
+	}
+	for _, a := range assignments {
+		switch a.Kind {
+		case AssignmentKindTeam:
+			result.Teams = append(result.Teams, a)
+		case AssignmentKindUser:
+			result.Users = append(result.Users, a)
+		case AssignmentKindServiceAccount:
+			result.ServiceAccounts = append(result.ServiceAccounts, a)
+		case AssignmentKindBuiltinRole:
+			result.BuiltinRoles = append(result.BuiltinRoles, a)
+		case AssignmentKindAPIKey:
+			result.APIKeys = append(result.APIKeys, a)
+		}
+	}
+
+	return result, nil
+}
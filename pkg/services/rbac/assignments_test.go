@@ -0,0 +1,62 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestGetPolicyAssignments(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "shared", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+
+	t.Run("Unassigned policy should have no assignments", func(t *testing.T) {
+		assignments, err := ac.GetPolicyAssignments(context.Background(), GetPolicyAssignmentsQuery{OrgID: 1, PolicyID: policy.ID})
+		require.NoError(t, err)
+		assert.Equal(t, 0, assignments.Total)
+		assert.Empty(t, assignments.Teams)
+	})
+
+	team := createTeam(t, 1, "analysts")
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID,
+		PerformedBy: 1, Reason: " quarterly reporting "}))
+
+	user := &models.CreateUserCommand{Login: "alice", SkipOrgSetup: true}
+	require.NoError(t, sqlstore.CreateUser(context.Background(), user))
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: user.Result.Id, PolicyID: policy.ID,
+		PerformedBy: 1, Reason: "OPS-123"}))
+
+	bot := &models.CreateUserCommand{Login: "exporter-bot", SkipOrgSetup: true}
+	require.NoError(t, sqlstore.CreateUser(context.Background(), bot))
+	_, err := ac.CreateServiceAccount(context.Background(), CreateServiceAccountCommand{OrgID: 1, UserID: bot.Result.Id})
+	require.NoError(t, err)
+	require.NoError(t, ac.AddServiceAccountPolicy(context.Background(), AddServiceAccountPolicyCommand{OrgID: 1, UserID: bot.Result.Id, PolicyID: policy.ID}))
+
+	require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: policy.ID}))
+
+	key := &models.AddApiKeyCommand{OrgId: 1, Name: "reporting", Role: models.ROLE_VIEWER, Key: "secret"}
+	require.NoError(t, sqlstore.AddApiKey(key))
+	require.NoError(t, ac.AddAPIKeyPolicy(context.Background(), AddAPIKeyPolicyCommand{OrgID: 1, APIKeyID: key.Result.Id, PolicyID: policy.ID, CreatedBy: user.Result.Id}))
+
+	assignments, err := ac.GetPolicyAssignments(context.Background(), GetPolicyAssignmentsQuery{OrgID: 1, PolicyID: policy.ID})
+	require.NoError(t, err)
+	assert.Equal(t, 5, assignments.Total)
+	assert.Equal(t, []*PolicyAssignment{{Kind: AssignmentKindTeam, ID: team.Id, Name: "analysts",
+		GrantedBy: 1, Reason: "quarterly reporting"}}, assignments.Teams)
+	assert.Equal(t, []*PolicyAssignment{{Kind: AssignmentKindUser, ID: user.Result.Id, Name: "alice",
+		GrantedBy: 1, Reason: "OPS-123"}}, assignments.Users)
+	assert.Equal(t, []*PolicyAssignment{{Kind: AssignmentKindServiceAccount, ID: bot.Result.Id, Name: "exporter-bot"}}, assignments.ServiceAccounts)
+	assert.Equal(t, []*PolicyAssignment{{Kind: AssignmentKindBuiltinRole, Name: "Viewer"}}, assignments.BuiltinRoles)
+	assert.Equal(t, []*PolicyAssignment{{Kind: AssignmentKindAPIKey, ID: key.Result.Id, Name: "reporting"}}, assignments.APIKeys)
+
+	t.Run("Policy of another organization should not be found", func(t *testing.T) {
+		_, err := ac.GetPolicyAssignments(context.Background(), GetPolicyAssignmentsQuery{OrgID: 2, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+	})
+}
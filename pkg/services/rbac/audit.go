@@ -0,0 +1,41 @@
+package rbac
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// Actions for reading and exporting the audit trail of an organization, so that compliance
+// roles can be granted access to it without broader admin rights.
+const (
+	ActionAuditRead   = "audit:read"
+	ActionAuditExport = "audit:export"
+)
+
+func init() {
+	RegisterActions(
+		ActionDefinition{Action: ActionAuditRead, Description: "Read the audit trail of an organization"},
+		ActionDefinition{Action: ActionAuditExport, Description: "Export the audit trail of an organization"},
+	)
+}
+
+// ScopeOrgID returns the scope of an organization identified by its id.
+func ScopeOrgID(id int64) string {
+	return "orgs:id:" + strconv.FormatInt(id, 10)
+}
+
+// CanReadAudit returns true if the user may read the audit trail of the user's current organization.
+func (ac *RBACService) CanReadAudit(ctx context.Context, user *models.SignedInUser) (bool, error) {
+	return ac.evaluate(ctx, user, accessRequest{Action: ActionAuditRead, Scope: ScopeOrgID(user.OrgId)})
+}
+
+// CanExportAudit returns true if the user may export the audit trail of the user's current organization.
+// Exporting implies reading, so both actions are required.
+func (ac *RBACService) CanExportAudit(ctx context.Context, user *models.SignedInUser) (bool, error) {
+	return ac.evaluateRequests(ctx, user,
+		accessRequest{Action: ActionAuditRead, Scope: ScopeOrgID(user.OrgId)},
+		accessRequest{Action: ActionAuditExport, Scope: ScopeOrgID(user.OrgId)},
+	)
+}
@@ -0,0 +1,33 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestAuditPermissions(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "compliance")
+	addTeamMember(t, 1, team.Id, 81)
+	policy := createPolicy(t, ac, 1, "auditor",
+		CreatePermissionCommand{Action: ActionAuditRead, Scope: ScopeOrgID(1)},
+		CreatePermissionCommand{Action: ActionAuditExport, Scope: ScopeOrgID(2)},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 81}
+
+	ok, err := ac.CanReadAudit(context.Background(), user)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ac.CanExportAudit(context.Background(), user)
+	require.NoError(t, err)
+	assert.False(t, ok, "exporting should be scoped to the organization")
+}
@@ -0,0 +1,49 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type authTimeKey struct{}
+
+// WithAuthTime returns a copy of the context carrying when the user of the request being authorized
+// logged in, so that authentication age conditions can be evaluated.
+func WithAuthTime(ctx context.Context, t time.Time) context.Context {
+	return context.WithValue(ctx, authTimeKey{}, t)
+}
+
+func authTimeFromContext(ctx context.Context) time.Time {
+	t, _ := ctx.Value(authTimeKey{}).(time.Time)
+	return t
+}
+
+// RequiresReauthentication returns true if the user would satisfy the evaluator after logging in again.
+// It's meant to be called when access is denied, to tell denials caused by authentication age
+// conditions only apart from the others.
+func (ac *RBACService) RequiresReauthentication(ctx context.Context, user *models.SignedInUser, evaluator Evaluator) (bool, error) {
+	return ac.Evaluate(WithAuthTime(ctx, time.Now()), user, evaluator)
+}
+
+// matchAuthAge returns true if the user logged in within the maximum age before the request.
+// Maximum ages that can't be parsed never match.
+func matchAuthAge(maxAge string, req accessRequest) bool {
+	d, err := time.ParseDuration(maxAge)
+	if err != nil || req.AuthTime.IsZero() {
+		return false
+	}
+
+	return req.Time.Sub(req.AuthTime) <= d
+}
+
+func validateAuthAge(maxAge string) error {
+	d, err := time.ParseDuration(maxAge)
+	if err != nil || d <= 0 {
+		return fmt.Errorf("invalid maximum authentication age %q, expected a positive duration", maxAge)
+	}
+
+	return nil
+}
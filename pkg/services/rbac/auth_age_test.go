@@ -0,0 +1,61 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestAuthAgeCondition(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "user admins")
+	addTeamMember(t, 1, team.Id, 141)
+	policy := createPolicy(t, ac, 1, "user admin",
+		CreatePermissionCommand{Action: ActionUsersRead, Scope: "users:*"},
+		CreatePermissionCommand{Action: ActionUsersDelete, Scope: "users:*", Conditions: []Condition{{MaxAuthAge: "15m"}}},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 141}
+	check := func(ctx context.Context, evaluator Evaluator) bool {
+		ok, err := ac.Evaluate(ctx, user, evaluator)
+		require.NoError(t, err)
+		return ok
+	}
+	recent := WithAuthTime(context.Background(), time.Now().Add(-5*time.Minute))
+	stale := WithAuthTime(context.Background(), time.Now().Add(-time.Hour))
+
+	assert.True(t, check(recent, Perm(ActionUsersDelete, "users:id:1")))
+	assert.False(t, check(stale, Perm(ActionUsersDelete, "users:id:1")))
+	assert.False(t, check(context.Background(), Perm(ActionUsersDelete, "users:id:1")), "requests without login session should never match")
+	assert.True(t, check(stale, Perm(ActionUsersRead, "users:id:1")))
+
+	t.Run("Denials caused by the authentication age should require reauthentication", func(t *testing.T) {
+		reauthenticate, err := ac.RequiresReauthentication(stale, user, Perm(ActionUsersDelete, "users:id:1"))
+		require.NoError(t, err)
+		assert.True(t, reauthenticate)
+
+		reauthenticate, err = ac.RequiresReauthentication(stale, user, Perm(ActionUsersCreate, ""))
+		require.NoError(t, err)
+		assert.False(t, reauthenticate)
+	})
+
+	t.Run("Invalid maximum authentication age should be rejected", func(t *testing.T) {
+		for _, c := range []Condition{
+			{MaxAuthAge: "soon"},
+			{MaxAuthAge: "-5m"},
+			{MaxAuthAge: "5m", Attribute: AttributeUserLogin, Values: []string{"admin"}},
+		} {
+			_, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{
+				OrgID: policy.OrgID, PolicyID: policy.ID, Action: ActionUsersWrite, Scope: "users:*", Conditions: []Condition{c},
+			})
+			require.ErrorIs(t, err, ErrInvalidCondition)
+		}
+	})
+}
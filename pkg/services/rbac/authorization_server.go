@@ -0,0 +1,235 @@
+package rbac
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"net"
+	"strings"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rbac/authzv1"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// authorizationServerSettings are the settings of the gRPC authorization service, see
+// loadAuthorizationServerSettings.
+type authorizationServerSettings struct {
+	address  string
+	token    string
+	certFile string
+	certKey  string
+}
+
+// loadAuthorizationServerSettings reads the address the gRPC authorization service listens on from the
+// rbac.grpc_address setting, the service is disabled when it's empty. Clients authenticate with the
+// token of the rbac.grpc_token setting, which is required, and TLS is used when both rbac.grpc_cert_file
+// and rbac.grpc_cert_key are set.
+func (ac *RBACService) loadAuthorizationServerSettings() error {
+	section := ac.Cfg.Raw.Section("rbac")
+	settings := authorizationServerSettings{
+		address:  strings.TrimSpace(section.Key("grpc_address").MustString("")),
+		token:    section.Key("grpc_token").MustString(""),
+		certFile: section.Key("grpc_cert_file").MustString(""),
+		certKey:  section.Key("grpc_cert_key").MustString(""),
+	}
+	if settings.address != "" && settings.token == "" {
+		return errors.New("rbac.grpc_token is required when rbac.grpc_address is set")
+	}
+	if (settings.certFile == "") != (settings.certKey == "") {
+		return errors.New("rbac.grpc_cert_file and rbac.grpc_cert_key must be set together")
+	}
+
+	ac.authorizationServer = settings
+	return nil
+}
+
+// runAuthorizationServer serves the gRPC authorization service until the context is done, when it's
+// enabled, see loadAuthorizationServerSettings.
+func (ac *RBACService) runAuthorizationServer(ctx context.Context) {
+	settings := ac.authorizationServer
+	if settings.address == "" {
+		return
+	}
+
+	server, err := ac.newAuthorizationServer()
+	if err != nil {
+		ac.log.Error("Failed to start the gRPC authorization service", "error", err)
+		return
+	}
+	listener, err := net.Listen("tcp", settings.address)
+	if err != nil {
+		ac.log.Error("Failed to listen for the gRPC authorization service", "address", settings.address, "error", err)
+		return
+	}
+
+	go func() {
+		<-ctx.Done()
+		server.GracefulStop()
+	}()
+	ac.log.Info("Serving the gRPC authorization service", "address", listener.Addr().String())
+	if err := server.Serve(listener); err != nil {
+		ac.log.Error("The gRPC authorization service stopped", "error", err)
+	}
+}
+
+// newAuthorizationServer returns the gRPC server of the authorization service, authenticating its
+// clients with the token of the settings.
+func (ac *RBACService) newAuthorizationServer() (*grpc.Server, error) {
+	settings := ac.authorizationServer
+	opts := []grpc.ServerOption{grpc.UnaryInterceptor(authenticateToken(settings.token))}
+	if settings.certFile != "" {
+		creds, err := credentials.NewServerTLSFromFile(settings.certFile, settings.certKey)
+		if err != nil {
+			return nil, fmt.Errorf("failed to load the gRPC authorization service certificate: %w", err)
+		}
+		opts = append(opts, grpc.Creds(creds))
+	}
+
+	server := grpc.NewServer(opts...)
+	authzv1.RegisterAuthorizationServer(server, authorizationServer{ac: ac})
+	return server, nil
+}
+
+// authenticateToken rejects the calls without the token in their "authorization: Bearer <token>" metadata.
+func authenticateToken(token string) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req interface{}, _ *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (interface{}, error) {
+		md, _ := metadata.FromIncomingContext(ctx)
+		for _, value := range md.Get("authorization") {
+			if !strings.HasPrefix(value, "Bearer ") {
+				continue
+			}
+			if subtle.ConstantTimeCompare([]byte(strings.TrimPrefix(value, "Bearer ")), []byte(token)) == 1 {
+				return handler(ctx, req)
+			}
+		}
+		return nil, status.Error(codes.Unauthenticated, "missing or invalid token")
+	}
+}
+
+// authorizationServer answers the access checks of external services about Grafana users. The access
+// checks are decided like those of Grafana itself, through Evaluate. The calls are Unavailable while
+// RBAC is disabled or degraded, since Grafana doesn't decide with its permissions then.
+type authorizationServer struct {
+	authzv1.UnimplementedAuthorizationServer
+	ac *RBACService
+}
+
+func (s authorizationServer) CheckAccess(ctx context.Context, req *authzv1.CheckAccessRequest) (*authzv1.CheckAccessResponse, error) {
+	if err := s.available(); err != nil {
+		return nil, err
+	}
+	if req.Action == "" {
+		return nil, status.Error(codes.InvalidArgument, "action is required")
+	}
+	user, err := s.user(req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	allowed, err := s.ac.HasPermission(ctx, user, req.Action, req.Scope)
+	if err != nil {
+		s.ac.log.Error("Failed to check access", "userId", user.UserId, "orgId", user.OrgId, "error", err)
+		return nil, status.Error(codes.Internal, "failed to check access")
+	}
+	return &authzv1.CheckAccessResponse{Allowed: allowed}, nil
+}
+
+func (s authorizationServer) ListPermissions(ctx context.Context, req *authzv1.ListPermissionsRequest) (*authzv1.ListPermissionsResponse, error) {
+	if err := s.available(); err != nil {
+		return nil, err
+	}
+	user, err := s.user(req.User)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := s.ac.resolveUserPermissions(ctx, user)
+	if err != nil {
+		s.ac.log.Error("Failed to list permissions", "userId", user.UserId, "orgId", user.OrgId, "error", err)
+		return nil, status.Error(codes.Internal, "failed to list permissions")
+	}
+
+	res := &authzv1.ListPermissionsResponse{Permissions: []*authzv1.Permission{}}
+	for _, p := range permissions {
+		if req.Action != "" && !matchPattern(p.Action, req.Action) {
+			continue
+		}
+		kind := PermissionKindAllow
+		if p.IsDeny() {
+			kind = PermissionKindDeny
+		}
+		res.Permissions = append(res.Permissions, &authzv1.Permission{Action: p.Action, Scope: p.Scope, Kind: kind})
+	}
+	return res, nil
+}
+
+// available returns an Unavailable error while RBAC isn't enforced.
+func (s authorizationServer) available() error {
+	if s.ac.IsDegraded() {
+		return status.Error(codes.Unavailable, "RBAC is degraded until its database migrations have run")
+	}
+	if !s.ac.IsEnabled() {
+		return status.Error(codes.Unavailable, "RBAC is disabled")
+	}
+	return nil
+}
+
+// user returns the signed in user of an access check, member of the organization of the request.
+func (s authorizationServer) user(u *authzv1.User) (*models.SignedInUser, error) {
+	if u == nil || u.OrgId <= 0 || (u.UserId <= 0 && u.Login == "") {
+		return nil, status.Error(codes.InvalidArgument, "an organization and a user id or login are required")
+	}
+	if u.ApiKeyId != 0 {
+		return nil, status.Error(codes.InvalidArgument, "access checks of API keys aren't supported")
+	}
+
+	user, err := s.ac.externalUser(u.OrgId, u.UserId, u.Login)
+	if err != nil {
+		if errors.Is(err, models.ErrUserNotFound) {
+			return nil, status.Error(codes.NotFound, "user not found")
+		}
+		s.ac.log.Error("Failed to get user", "userId", u.UserId, "login", u.Login, "orgId", u.OrgId, "error", err)
+		return nil, status.Error(codes.Internal, "failed to get user")
+	}
+	return user, nil
+}
+
+// externalUser returns the signed in user identified by id, or else by login, for the access checks of
+// other services. The user must be a member of the organization, its current organization is used when
+// the organization is zero. Users that aren't found, aren't members or are disabled are
+// models.ErrUserNotFound.
+func (ac *RBACService) externalUser(orgID, userID int64, login string) (*models.SignedInUser, error) {
+	query := models.GetSignedInUserQuery{OrgId: orgID, UserId: userID}
+	var err error
+	if userID > 0 {
+		err = ac.SQLStore.GetSignedInUserWithCache(&query)
+	} else {
+		// The cache of signed in users is keyed by id.
+		query.Login = login
+		err = sqlstore.GetSignedInUser(&query)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if query.Result.OrgId <= 0 || (orgID > 0 && query.Result.OrgId != orgID) {
+		return nil, models.ErrUserNotFound
+	}
+	// The signed in user doesn't tell whether the user is disabled, and may come from the cache.
+	userQuery := models.GetUserByIdQuery{Id: query.Result.UserId}
+	if err := sqlstore.GetUserById(&userQuery); err != nil {
+		return nil, err
+	}
+	if userQuery.Result.IsDisabled {
+		return nil, models.ErrUserNotFound
+	}
+
+	return query.Result, nil
+}
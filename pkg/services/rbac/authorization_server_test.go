@@ -0,0 +1,144 @@
+package rbac
+
+import (
+	"context"
+	"net"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/metadata"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rbac/authzv1"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestAuthorizationServer(t *testing.T) {
+	ac := setupTestEnv(t)
+	for key, value := range map[string]string{"grpc_address": "127.0.0.1:0", "grpc_token": "secret"} {
+		_, err := ac.Cfg.Raw.Section("rbac").NewKey(key, value)
+		require.NoError(t, err)
+	}
+	require.NoError(t, ac.loadAuthorizationServerSettings())
+
+	cmd := &models.CreateUserCommand{Login: "sidecar-user"}
+	require.NoError(t, sqlstore.CreateUser(context.Background(), cmd))
+	user := cmd.Result
+	policy := createPolicy(t, ac, user.OrgId, "dashboard reader",
+		CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"},
+		CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:uid:secret", Kind: PermissionKindDeny},
+		CreatePermissionCommand{Action: ActionTeamsRead, Scope: "teams:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: user.OrgId, UserID: user.Id, PolicyID: policy.ID}))
+
+	server, err := ac.newAuthorizationServer()
+	require.NoError(t, err)
+	listener := bufconn.Listen(1024 * 1024)
+	go func() { _ = server.Serve(listener) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+		return listener.Dial()
+	}))
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = conn.Close() })
+	client := authzv1.NewAuthorizationClient(conn)
+	ctx := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer secret")
+
+	t.Run("Calls without the token should be rejected", func(t *testing.T) {
+		_, err := client.CheckAccess(context.Background(), &authzv1.CheckAccessRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+
+		wrong := metadata.AppendToOutgoingContext(context.Background(), "authorization", "Bearer guess")
+		_, err = client.CheckAccess(wrong, &authzv1.CheckAccessRequest{})
+		assert.Equal(t, codes.Unauthenticated, status.Code(err))
+	})
+
+	t.Run("Access checks should be decided with the user's permissions", func(t *testing.T) {
+		for scope, allowed := range map[string]bool{"dashboards:uid:abc": true, "dashboards:uid:secret": false} {
+			res, err := client.CheckAccess(ctx, &authzv1.CheckAccessRequest{
+				User:   &authzv1.User{OrgId: user.OrgId, Login: user.Login},
+				Action: ActionDashboardsRead,
+				Scope:  scope,
+			})
+			require.NoError(t, err)
+			assert.Equal(t, allowed, res.Allowed, scope)
+		}
+	})
+
+	t.Run("Permissions should be listed by action", func(t *testing.T) {
+		res, err := client.ListPermissions(ctx, &authzv1.ListPermissionsRequest{
+			User:   &authzv1.User{OrgId: user.OrgId, UserId: user.Id},
+			Action: ActionDashboardsRead,
+		})
+		require.NoError(t, err)
+
+		var permissions []string
+		for _, p := range res.Permissions {
+			permissions = append(permissions, p.Kind+" "+p.Scope)
+		}
+		assert.ElementsMatch(t, []string{"allow dashboards:*", "deny dashboards:uid:secret"}, permissions)
+	})
+
+	t.Run("Unknown users and users of other organizations should not be found", func(t *testing.T) {
+		_, err := client.CheckAccess(ctx, &authzv1.CheckAccessRequest{
+			User: &authzv1.User{OrgId: user.OrgId, Login: "nobody"}, Action: ActionTeamsRead,
+		})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+
+		_, err = client.CheckAccess(ctx, &authzv1.CheckAccessRequest{
+			User: &authzv1.User{OrgId: user.OrgId + 100, UserId: user.Id}, Action: ActionTeamsRead,
+		})
+		assert.Equal(t, codes.NotFound, status.Code(err))
+	})
+
+	t.Run("Access checks of API keys should be rejected", func(t *testing.T) {
+		_, err := client.CheckAccess(ctx, &authzv1.CheckAccessRequest{
+			User: &authzv1.User{OrgId: user.OrgId, UserId: user.Id, ApiKeyId: 1}, Action: ActionTeamsRead,
+		})
+		assert.Equal(t, codes.InvalidArgument, status.Code(err))
+	})
+
+	t.Run("Disabled users should not be found", func(t *testing.T) {
+		require.NoError(t, sqlstore.DisableUser(&models.DisableUserCommand{UserId: user.Id, IsDisabled: true}))
+		t.Cleanup(func() {
+			require.NoError(t, sqlstore.DisableUser(&models.DisableUserCommand{UserId: user.Id, IsDisabled: false}))
+		})
+
+		for _, u := range []*authzv1.User{{OrgId: user.OrgId, UserId: user.Id}, {OrgId: user.OrgId, Login: user.Login}} {
+			_, err := client.CheckAccess(ctx, &authzv1.CheckAccessRequest{User: u, Action: ActionTeamsRead, Scope: "teams:id:1"})
+			assert.Equal(t, codes.NotFound, status.Code(err))
+			_, err = client.ListPermissions(ctx, &authzv1.ListPermissionsRequest{User: u, Action: ActionTeamsRead})
+			assert.Equal(t, codes.NotFound, status.Code(err))
+		}
+	})
+
+	t.Run("Calls should be unavailable while RBAC is disabled or degraded", func(t *testing.T) {
+		u := &authzv1.User{OrgId: user.OrgId, UserId: user.Id}
+		check := func(t *testing.T) {
+			t.Helper()
+			_, err := client.CheckAccess(ctx, &authzv1.CheckAccessRequest{User: u, Action: ActionTeamsRead, Scope: "teams:id:1"})
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+			_, err = client.ListPermissions(ctx, &authzv1.ListPermissionsRequest{User: u})
+			assert.Equal(t, codes.Unavailable, status.Code(err))
+		}
+
+		ac.degraded = true
+		check(t)
+		ac.degraded = false
+
+		toggles := ac.Cfg.FeatureToggles
+		ac.Cfg.FeatureToggles = map[string]bool{}
+		t.Cleanup(func() { ac.Cfg.FeatureToggles = toggles })
+		check(t)
+	})
+
+	t.Run("A token should be required to enable the service", func(t *testing.T) {
+		ac.Cfg.Raw.Section("rbac").DeleteKey("grpc_token")
+		require.Error(t, ac.loadAuthorizationServerSettings())
+	})
+}
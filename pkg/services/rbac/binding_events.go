@@ -0,0 +1,69 @@
+package rbac
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+)
+
+// Reasons of the PolicyBound and PolicyUnbound events published by the RBAC service.
+const (
+	BindingReasonManual                = "manual"
+	BindingReasonSync                  = "sync"
+	BindingReasonDefaultPolicy         = "defaultPolicy"
+	BindingReasonRoleMigration         = "roleMigration"
+	BindingReasonRoleMigrationRollback = "roleMigrationRollback"
+	BindingReasonAccessRevoked         = "accessRevoked"
+	BindingReasonTeamDeleted           = "teamDeleted"
+	BindingReasonServiceAccountDeleted = "serviceAccountDeleted"
+)
+
+// bindingChanges collects the policy bindings changed within a transaction, so that their events
+// are only published once it's committed.
+type bindingChanges struct {
+	performedBy int64
+	reason      string
+	events      []interface{}
+}
+
+func newBindingChanges(performedBy int64, reason string) *bindingChanges {
+	return &bindingChanges{performedBy: performedBy, reason: reason}
+}
+
+func (c *bindingChanges) userBound(orgID, policyID, userID int64) {
+	c.events = append(c.events, &events.PolicyBound{OrgID: orgID, PolicyID: policyID, UserID: userID,
+		PerformedBy: c.performedBy, Reason: c.reason})
+}
+
+func (c *bindingChanges) userUnbound(orgID, policyID, userID int64) {
+	c.events = append(c.events, &events.PolicyUnbound{OrgID: orgID, PolicyID: policyID, UserID: userID,
+		PerformedBy: c.performedBy, Reason: c.reason})
+}
+
+func (c *bindingChanges) teamBound(orgID, policyID, teamID int64) {
+	c.events = append(c.events, &events.PolicyBound{OrgID: orgID, PolicyID: policyID, TeamID: teamID,
+		PerformedBy: c.performedBy, Reason: c.reason})
+}
+
+func (c *bindingChanges) teamUnbound(orgID, policyID, teamID int64) {
+	c.events = append(c.events, &events.PolicyUnbound{OrgID: orgID, PolicyID: policyID, TeamID: teamID,
+		PerformedBy: c.performedBy, Reason: c.reason})
+}
+
+// publishBindingChanges publishes the events of committed binding changes. Failures are logged since
+// the bindings changed regardless.
+func (ac *RBACService) publishBindingChanges(c *bindingChanges) {
+	now := time.Now()
+	for _, e := range c.events {
+		switch e := e.(type) {
+		case *events.PolicyBound:
+			e.Timestamp = now
+		case *events.PolicyUnbound:
+			e.Timestamp = now
+		}
+		if err := bus.Publish(e); err != nil {
+			ac.log.Error("Failed to publish policy binding event", "reason", c.reason, "error", err)
+		}
+	}
+}
@@ -0,0 +1,89 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestBindingEvents(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	var bound []events.PolicyBound
+	var unbound []events.PolicyUnbound
+	bus.AddEventListener(func(e *events.PolicyBound) error {
+		bound = append(bound, *e)
+		return nil
+	})
+	bus.AddEventListener(func(e *events.PolicyUnbound) error {
+		unbound = append(unbound, *e)
+		return nil
+	})
+	lastBound := func() events.PolicyBound {
+		t.Helper()
+		require.NotEmpty(t, bound)
+		e := bound[len(bound)-1]
+		assert.False(t, e.Timestamp.IsZero())
+		return e
+	}
+	lastUnbound := func() events.PolicyUnbound {
+		t.Helper()
+		require.NotEmpty(t, unbound)
+		e := unbound[len(unbound)-1]
+		assert.False(t, e.Timestamp.IsZero())
+		return e
+	}
+
+	const userID, adminID = 361, 1
+	policy := createPolicy(t, ac, 1, "notified", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: userID, PerformedBy: adminID}))
+	e := lastBound()
+	assert.Equal(t, events.PolicyBound{Timestamp: e.Timestamp, OrgID: 1, PolicyID: policy.ID, UserID: userID,
+		PerformedBy: adminID, Reason: BindingReasonManual}, e)
+
+	require.NoError(t, ac.RemoveUserPolicy(context.Background(), RemoveUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: userID, PerformedBy: adminID}))
+	u := lastUnbound()
+	assert.Equal(t, events.PolicyUnbound{Timestamp: u.Timestamp, OrgID: 1, PolicyID: policy.ID, UserID: userID,
+		PerformedBy: adminID, Reason: BindingReasonManual}, u)
+
+	t.Run("Failed changes shouldn't be published", func(t *testing.T) {
+		count := len(unbound)
+		err := ac.RemoveUserPolicy(context.Background(), RemoveUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: userID})
+		require.ErrorIs(t, err, ErrUserPolicyNotFound)
+		assert.Len(t, unbound, count)
+	})
+
+	t.Run("Team bindings should be published", func(t *testing.T) {
+		team := createTeam(t, 1, "notified team")
+		require.NoError(t, ac.SetTeamPolicies(context.Background(), SetTeamPoliciesCommand{OrgID: 1, TeamID: team.Id, PolicyIDs: []int64{policy.ID}, PerformedBy: adminID}))
+		e := lastBound()
+		assert.Equal(t, team.Id, e.TeamID)
+		assert.Zero(t, e.UserID)
+
+		require.NoError(t, ac.RemoveAllTeamPolicies(context.Background(), RemoveAllTeamPoliciesCommand{OrgID: 1, TeamID: team.Id, PerformedBy: adminID}))
+		u := lastUnbound()
+		assert.Equal(t, team.Id, u.TeamID)
+		assert.Equal(t, BindingReasonTeamDeleted, u.Reason)
+	})
+
+	t.Run("Bindings synced at login should be published without actor", func(t *testing.T) {
+		require.NoError(t, bus.Dispatch(&models.SyncUserPoliciesCommand{UserId: userID, AuthModule: models.AuthModuleLDAP,
+			PolicyUIDs: map[int64][]string{1: {policy.UID}}}))
+		e := lastBound()
+		assert.Equal(t, int64(userID), e.UserID)
+		assert.Zero(t, e.PerformedBy)
+		assert.Equal(t, BindingReasonSync, e.Reason)
+
+		require.NoError(t, bus.Dispatch(&models.SyncUserPoliciesCommand{UserId: userID, AuthModule: models.AuthModuleLDAP,
+			PolicyUIDs: map[int64][]string{}}))
+		u := lastUnbound()
+		assert.Equal(t, BindingReasonSync, u.Reason)
+	})
+}
@@ -0,0 +1,214 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// defaultBindingExpiryNoticeDays is how many days before expiring a user policy binding is notified
+// when rbac.binding_expiry_notice_days isn't set.
+const defaultBindingExpiryNoticeDays = 7
+
+// loadBindingLifetimeSettings reads how long before expiring user policy bindings are notified, the
+// notifications are disabled when it's 0.
+func (ac *RBACService) loadBindingLifetimeSettings() {
+	days := ac.Cfg.Raw.Section("rbac").Key("binding_expiry_notice_days").MustInt(defaultBindingExpiryNoticeDays)
+	ac.bindingExpiryNotice = time.Duration(days) * 24 * time.Hour
+}
+
+// GetBindingLifetime returns the maximum lifetime of the direct user policy bindings of an organization,
+// with MaxDays set to 0 when it doesn't have one.
+func (ac *RBACService) GetBindingLifetime(ctx context.Context, orgID int64) (*BindingLifetime, error) {
+	lifetime := &BindingLifetime{OrgID: orgID}
+	if ac.schemaVersion < schemaVersionBindingLifetime {
+		return lifetime, nil
+	}
+
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		_, err := sess.Table("binding_lifetime").Where("org_id = ?", orgID).Get(lifetime)
+		return err
+	})
+
+	return lifetime, err
+}
+
+// SetBindingLifetime caps the lifetime of the direct user policy bindings of an organization, so that
+// access is moved to teams rather than piling up on users. Bindings made from then on expire after
+// the lifetime unless renewed, existing ones that would outlive it are shortened to expire after it.
+// Bindings synced from an identity provider, of service accounts and of managed policies aren't capped.
+func (ac *RBACService) SetBindingLifetime(ctx context.Context, cmd SetBindingLifetimeCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionBindingLifetime); err != nil {
+		return err
+	}
+	if cmd.MaxDays < 0 {
+		return ErrInvalidBindingLifetime
+	}
+
+	var capped int
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := sess.Exec("DELETE FROM binding_lifetime WHERE org_id = ?", cmd.OrgID); err != nil {
+			return err
+		}
+		if cmd.MaxDays == 0 {
+			return nil
+		}
+
+		lifetime := &BindingLifetime{OrgID: cmd.OrgID, MaxDays: cmd.MaxDays, Updated: time.Now()}
+		if _, err := sess.Table("binding_lifetime").Insert(lifetime); err != nil {
+			return err
+		}
+
+		maxExpiry := time.Now().Add(bindingLifetimeDuration(cmd.MaxDays))
+		q := `SELECT user_policy.id FROM user_policy
+			LEFT JOIN user_policy_sync ON user_policy_sync.org_id = user_policy.org_id
+			AND user_policy_sync.user_id = user_policy.user_id AND user_policy_sync.policy_id = user_policy.policy_id
+			LEFT JOIN service_account ON service_account.org_id = user_policy.org_id AND service_account.user_id = user_policy.user_id
+			INNER JOIN policy ON policy.id = user_policy.policy_id
+			WHERE user_policy.org_id = ? AND (user_policy.expires_at IS NULL OR user_policy.expires_at > ?)
+			AND user_policy_sync.id IS NULL AND service_account.id IS NULL AND policy.uid NOT LIKE ?`
+		var ids []int64
+		if err := sess.SQL(q, cmd.OrgID, maxExpiry, managedPolicyUIDPrefix+"%").Find(&ids); err != nil {
+			return err
+		}
+		for _, id := range ids {
+			if _, err := sess.Exec("UPDATE user_policy SET expires_at = ? WHERE id = ?", maxExpiry, id); err != nil {
+				return err
+			}
+			if _, err := sess.Exec("DELETE FROM user_policy_expiry_notice WHERE user_policy_id = ?", id); err != nil {
+				return err
+			}
+		}
+		capped = len(ids)
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.log.Info("Set binding lifetime", "orgId", cmd.OrgID, "maxDays", cmd.MaxDays, "capped", capped)
+	return nil
+}
+
+// RenewUserPolicy extends a policy binding of a user, up to the binding lifetime of the organization.
+func (ac *RBACService) RenewUserPolicy(ctx context.Context, cmd RenewUserPolicyCommand) (*UserPolicy, error) {
+	if err := ac.checkSchemaVersion(schemaVersionBindingLifetime); err != nil {
+		return nil, err
+	}
+	if cmd.ExpiresAt != nil && !cmd.ExpiresAt.After(time.Now()) {
+		return nil, ErrBindingExpiryInPast
+	}
+
+	userPolicy := &UserPolicy{}
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		exists, err := sess.Table("user_policy").Where("org_id = ? AND user_id = ? AND policy_id = ?",
+			cmd.OrgID, cmd.UserID, cmd.PolicyID).Get(userPolicy)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrUserPolicyNotFound
+		}
+
+		expiresAt, err := ac.userPolicyExpiry(sess, cmd.OrgID, cmd.ExpiresAt)
+		if err != nil {
+			return err
+		}
+		userPolicy.ExpiresAt = expiresAt
+		if _, err := sess.Table("user_policy").ID(userPolicy.ID).Cols("expires_at").Update(userPolicy); err != nil {
+			return err
+		}
+		_, err = sess.Exec("DELETE FROM user_policy_expiry_notice WHERE user_policy_id = ?", userPolicy.ID)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.log.Info("Renewed user policy binding", "orgId", cmd.OrgID, "policyId", cmd.PolicyID, "userId", cmd.UserID,
+		"expiresAt", userPolicy.ExpiresAt)
+	return userPolicy, nil
+}
+
+// userPolicyExpiry returns the expiry of a direct user policy binding in an organization: the requested
+// one, or the end of the binding lifetime of the organization when it has one and none was requested.
+func (ac *RBACService) userPolicyExpiry(sess *sqlstore.DBSession, orgID int64, expiresAt *time.Time) (*time.Time, error) {
+	if ac.schemaVersion < schemaVersionBindingLifetime {
+		return expiresAt, nil
+	}
+
+	lifetime := &BindingLifetime{}
+	exists, err := sess.Table("binding_lifetime").Where("org_id = ?", orgID).Get(lifetime)
+	if err != nil {
+		return nil, err
+	}
+	if !exists || lifetime.MaxDays == 0 {
+		return expiresAt, nil
+	}
+
+	maxExpiry := time.Now().Add(bindingLifetimeDuration(lifetime.MaxDays))
+	if expiresAt == nil {
+		return &maxExpiry, nil
+	}
+	if expiresAt.After(maxExpiry) {
+		return nil, ErrBindingExpiryBeyondLifetime
+	}
+	return expiresAt, nil
+}
+
+func bindingLifetimeDuration(days int) time.Duration {
+	return time.Duration(days) * 24 * time.Hour
+}
+
+// notifyExpiringUserPolicies publishes an event for each user policy binding expiring within the
+// notice period, once per binding until it's renewed.
+func (ac *RBACService) notifyExpiringUserPolicies(ctx context.Context) (int, error) {
+	if ac.bindingExpiryNotice <= 0 || ac.schemaVersion < schemaVersionBindingLifetime {
+		return 0, nil
+	}
+
+	var expiring []UserPolicy
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		// Notices of the bindings deleted since are useless.
+		if _, err := sess.Exec(`DELETE FROM user_policy_expiry_notice
+			WHERE NOT EXISTS (SELECT 1 FROM user_policy WHERE user_policy.id = user_policy_expiry_notice.user_policy_id)`); err != nil {
+			return err
+		}
+
+		now := time.Now()
+		q := `SELECT user_policy.* FROM user_policy
+			LEFT JOIN user_policy_expiry_notice ON user_policy_expiry_notice.user_policy_id = user_policy.id
+			WHERE user_policy.expires_at > ? AND user_policy.expires_at <= ? AND user_policy_expiry_notice.id IS NULL`
+		if err := sess.SQL(q, now, now.Add(ac.bindingExpiryNotice)).Find(&expiring); err != nil {
+			return err
+		}
+		for _, up := range expiring {
+			if _, err := sess.Table("user_policy_expiry_notice").Insert(&UserPolicyExpiryNotice{UserPolicyID: up.ID, Created: now}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, up := range expiring {
+		ac.log.Info("User policy binding is about to expire", "orgId", up.OrgID, "policyId", up.PolicyID, "userId", up.UserID,
+			"expiresAt", up.ExpiresAt)
+		if err := bus.Publish(&events.UserPolicyBindingExpiring{
+			Timestamp: time.Now(),
+			OrgID:     up.OrgID,
+			PolicyID:  up.PolicyID,
+			UserID:    up.UserID,
+			ExpiresAt: *up.ExpiresAt,
+		}); err != nil {
+			ac.log.Error("Failed to publish expiring user policy binding event", "policyId", up.PolicyID, "error", err)
+		}
+	}
+
+	return len(expiring), nil
+}
@@ -0,0 +1,122 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestBindingLifetime(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	var notified []*events.UserPolicyBindingExpiring
+	bus.AddEventListener(func(e *events.UserPolicyBindingExpiring) error {
+		notified = append(notified, e)
+		return nil
+	})
+
+	policy := createPolicy(t, ac, 1, "contractors", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	getExpiry := func(userID int64) *time.Time {
+		userPolicy := &UserPolicy{}
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			exists, err := sess.Table("user_policy").Where("org_id = ? AND user_id = ? AND policy_id = ?", 1, userID, policy.ID).Get(userPolicy)
+			require.True(t, exists)
+			return err
+		})
+		require.NoError(t, err)
+		return userPolicy.ExpiresAt
+	}
+	assertExpiresInDays := func(t *testing.T, userID int64, days int) {
+		expiresAt := getExpiry(userID)
+		require.NotNil(t, expiresAt)
+		assert.WithinDuration(t, time.Now().Add(time.Duration(days)*24*time.Hour), *expiresAt, time.Minute)
+	}
+
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: 301}))
+	require.NoError(t, ac.SyncUserPolicies(context.Background(), &models.SyncUserPoliciesCommand{
+		UserId: 302, AuthModule: "ldap", PolicyUIDs: map[int64][]string{1: {policy.UID}},
+	}))
+
+	lifetime, err := ac.GetBindingLifetime(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 0, lifetime.MaxDays)
+
+	err = ac.SetBindingLifetime(context.Background(), SetBindingLifetimeCommand{OrgID: 1, MaxDays: -1})
+	require.ErrorIs(t, err, ErrInvalidBindingLifetime)
+	require.NoError(t, ac.SetBindingLifetime(context.Background(), SetBindingLifetimeCommand{OrgID: 1, MaxDays: 90}))
+	lifetime, err = ac.GetBindingLifetime(context.Background(), 1)
+	require.NoError(t, err)
+	assert.Equal(t, 90, lifetime.MaxDays)
+
+	t.Run("Existing direct bindings should be capped, synced ones left as they are", func(t *testing.T) {
+		assertExpiresInDays(t, 301, 90)
+		assert.Nil(t, getExpiry(302))
+	})
+
+	t.Run("New bindings should expire at the end of the lifetime", func(t *testing.T) {
+		tooLate := time.Now().Add(100 * 24 * time.Hour)
+		err := ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: 303, ExpiresAt: &tooLate})
+		require.ErrorIs(t, err, ErrBindingExpiryBeyondLifetime)
+
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: 303}))
+		assertExpiresInDays(t, 303, 90)
+	})
+
+	t.Run("Bindings about to expire should be notified once until renewed", func(t *testing.T) {
+		soon := time.Now().Add(24 * time.Hour)
+		renewed, err := ac.RenewUserPolicy(context.Background(), RenewUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: 301, ExpiresAt: &soon})
+		require.NoError(t, err)
+		assert.WithinDuration(t, soon, *renewed.ExpiresAt, time.Second)
+
+		count, err := ac.notifyExpiringUserPolicies(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+		require.Len(t, notified, 1)
+		assert.Equal(t, int64(301), notified[0].UserID)
+		assert.Equal(t, policy.ID, notified[0].PolicyID)
+
+		count, err = ac.notifyExpiringUserPolicies(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 0, count)
+
+		_, err = ac.RenewUserPolicy(context.Background(), RenewUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: 301})
+		require.NoError(t, err)
+		assertExpiresInDays(t, 301, 90)
+
+		_, err = ac.RenewUserPolicy(context.Background(), RenewUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: 301, ExpiresAt: &soon})
+		require.NoError(t, err)
+		count, err = ac.notifyExpiringUserPolicies(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, 1, count)
+	})
+
+	t.Run("Renewals should be limited to the lifetime", func(t *testing.T) {
+		tooLate := time.Now().Add(100 * 24 * time.Hour)
+		_, err := ac.RenewUserPolicy(context.Background(), RenewUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: 303, ExpiresAt: &tooLate})
+		require.ErrorIs(t, err, ErrBindingExpiryBeyondLifetime)
+
+		past := time.Now().Add(-time.Minute)
+		_, err = ac.RenewUserPolicy(context.Background(), RenewUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: 303, ExpiresAt: &past})
+		require.ErrorIs(t, err, ErrBindingExpiryInPast)
+
+		_, err = ac.RenewUserPolicy(context.Background(), RenewUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: 304})
+		require.ErrorIs(t, err, ErrUserPolicyNotFound)
+	})
+
+	t.Run("Renewals without lifetime should make bindings permanent", func(t *testing.T) {
+		require.NoError(t, ac.SetBindingLifetime(context.Background(), SetBindingLifetimeCommand{OrgID: 1, MaxDays: 0}))
+
+		renewed, err := ac.RenewUserPolicy(context.Background(), RenewUserPolicyCommand{OrgID: 1, PolicyID: policy.ID, UserID: 303})
+		require.NoError(t, err)
+		assert.Nil(t, renewed.ExpiresAt)
+		assert.Nil(t, getExpiry(303))
+	})
+}
@@ -0,0 +1,151 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// ActionBreakGlassUse is the unscoped action for elevating oneself to the break-glass policy of an
+// organization during an incident, see BreakGlass.
+const ActionBreakGlassUse = "rbac.breakglass:use"
+
+// BindingReasonBreakGlass is the reason of the PolicyBound events of break-glass elevations.
+const BindingReasonBreakGlass = "breakGlass"
+
+// defaultBreakGlassMaxDuration is the longest break-glass elevation when rbac.break_glass_max_duration isn't set.
+const defaultBreakGlassMaxDuration = time.Hour
+
+func init() {
+	RegisterActions(ActionDefinition{Action: ActionBreakGlassUse, Description: "Elevate oneself to the break-glass policy for a limited time"})
+}
+
+// breakGlassSettings are the break-glass settings of the rbac section.
+type breakGlassSettings struct {
+	// policyUID is the uid of the policy users elevate themselves to, break-glass is disabled when empty.
+	policyUID            string
+	maxDuration          time.Duration
+	requireJustification bool
+}
+
+func (ac *RBACService) loadBreakGlassSettings() {
+	section := ac.Cfg.Raw.Section("rbac")
+	ac.breakGlass = breakGlassSettings{
+		policyUID:            strings.TrimSpace(section.Key("break_glass_policy").String()),
+		maxDuration:          section.Key("break_glass_max_duration").MustDuration(defaultBreakGlassMaxDuration),
+		requireJustification: section.Key("break_glass_require_justification").MustBool(true),
+	}
+}
+
+// BreakGlass binds the configured break-glass policy to the user of the command until the
+// elevation expires, for users holding ActionBreakGlassUse to get elevated access during an
+// incident without waiting for an administrator. Every elevation is logged as a warning, published
+// as a BreakGlassUsed event and kept in the break_glass_grant table once it has expired.
+func (ac *RBACService) BreakGlass(ctx context.Context, cmd BreakGlassCommand) (*BreakGlassGrant, error) {
+	if err := ac.checkSchemaVersion(schemaVersionBreakGlass); err != nil {
+		return nil, err
+	}
+	if ac.breakGlass.policyUID == "" {
+		return nil, ErrBreakGlassNotConfigured
+	}
+	if cmd.Duration == 0 {
+		cmd.Duration = ac.breakGlass.maxDuration
+	}
+	if cmd.Duration < 0 || cmd.Duration > ac.breakGlass.maxDuration {
+		return nil, ErrInvalidBreakGlassDuration
+	}
+	cmd.Justification = strings.TrimSpace(cmd.Justification)
+	if ac.breakGlass.requireJustification && cmd.Justification == "" {
+		return nil, ErrBreakGlassJustificationRequired
+	}
+
+	now := time.Now()
+	grant := &BreakGlassGrant{
+		OrgID:         cmd.OrgID,
+		UserID:        cmd.UserID,
+		Justification: cmd.Justification,
+		Created:       now,
+		ExpiresAt:     now.Add(cmd.Duration),
+	}
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		policy, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, UID: ac.breakGlass.policyUID})
+		if errors.Is(err, ErrPolicyNotFound) {
+			return ErrBreakGlassNotConfigured
+		}
+		if err != nil {
+			return err
+		}
+		grant.PolicyID = policy.ID
+
+		// An expired elevation the janitor hasn't deleted yet is replaced rather than reported as a conflict.
+		if _, err := sess.Exec("DELETE FROM user_policy WHERE org_id = ? AND user_id = ? AND policy_id = ? AND expires_at <= ?",
+			cmd.OrgID, cmd.UserID, policy.ID, now); err != nil {
+			return err
+		}
+		// The elevation is bounded by its own maximum duration, the binding lifetime doesn't apply.
+		if err := ac.addUserPolicy(sess, &UserPolicy{
+			OrgID:     cmd.OrgID,
+			PolicyID:  policy.ID,
+			UserID:    cmd.UserID,
+			ExpiresAt: &grant.ExpiresAt,
+			GrantedBy: cmd.UserID,
+			Reason:    cmd.Justification,
+		}); err != nil {
+			if errors.Is(err, ErrUserPolicyAlreadyAdded) {
+				return ErrBreakGlassActive
+			}
+			return err
+		}
+
+		_, err = sess.Table("break_glass_grant").Insert(grant)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.log.Warn("Break-glass access granted", "orgId", cmd.OrgID, "userId", cmd.UserID, "policyUid", ac.breakGlass.policyUID,
+		"expiresAt", grant.ExpiresAt, "justification", grant.Justification)
+	e := &events.BreakGlassUsed{
+		Timestamp:     now,
+		OrgID:         cmd.OrgID,
+		UserID:        cmd.UserID,
+		PolicyID:      grant.PolicyID,
+		ExpiresAt:     grant.ExpiresAt,
+		Justification: grant.Justification,
+	}
+	if err := bus.Publish(e); err != nil {
+		ac.log.Error("Failed to publish break-glass event", "orgId", cmd.OrgID, "userId", cmd.UserID, "error", err)
+	}
+	changes := newBindingChanges(cmd.UserID, BindingReasonBreakGlass)
+	changes.userBound(cmd.OrgID, grant.PolicyID, cmd.UserID)
+	ac.publishBindingChanges(changes)
+
+	return grant, nil
+}
+
+// GetBreakGlassGrants returns the break-glass elevations of an organization, most recent first.
+func (ac *RBACService) GetBreakGlassGrants(ctx context.Context, query GetBreakGlassGrantsQuery) ([]*BreakGlassGrant, error) {
+	grants := make([]*BreakGlassGrant, 0)
+	if ac.schemaVersion < schemaVersionBreakGlass {
+		return grants, nil
+	}
+
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := sess.Table("break_glass_grant").Where("org_id = ?", query.OrgID)
+		if query.UserID != 0 {
+			q = q.And("user_id = ?", query.UserID)
+		}
+		if query.Limit > 0 {
+			q = q.Limit(query.Limit)
+		}
+		return q.Desc("created", "id").Find(&grants)
+	})
+
+	return grants, err
+}
@@ -0,0 +1,81 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestBreakGlass(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	const userID = 371
+	breakGlass := func(duration time.Duration, justification string) (*BreakGlassGrant, error) {
+		return ac.BreakGlass(context.Background(), BreakGlassCommand{OrgID: 1, UserID: userID, Duration: duration, Justification: justification})
+	}
+
+	_, err := breakGlass(0, "incident")
+	require.ErrorIs(t, err, ErrBreakGlassNotConfigured)
+
+	policy, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, UID: "break-glass", Name: "break glass"})
+	require.NoError(t, err)
+	_, err = ac.Cfg.Raw.Section("rbac").NewKey("break_glass_policy", "break-glass")
+	require.NoError(t, err)
+	_, err = ac.Cfg.Raw.Section("rbac").NewKey("break_glass_max_duration", "2h")
+	require.NoError(t, err)
+	ac.loadBreakGlassSettings()
+
+	var used []events.BreakGlassUsed
+	bus.AddEventListener(func(e *events.BreakGlassUsed) error {
+		used = append(used, *e)
+		return nil
+	})
+
+	t.Run("Elevations should be bounded and justified", func(t *testing.T) {
+		_, err := breakGlass(3*time.Hour, "incident")
+		require.ErrorIs(t, err, ErrInvalidBreakGlassDuration)
+		_, err = breakGlass(time.Hour, " ")
+		require.ErrorIs(t, err, ErrBreakGlassJustificationRequired)
+	})
+
+	grant, err := breakGlass(0, "database outage")
+	require.NoError(t, err)
+	assert.Equal(t, policy.ID, grant.PolicyID)
+	assert.WithinDuration(t, time.Now().Add(2*time.Hour), grant.ExpiresAt, time.Minute)
+	require.NotEmpty(t, used)
+	assert.Equal(t, "database outage", used[len(used)-1].Justification)
+
+	policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: userID})
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+	assert.Equal(t, "break glass", policies[0].Name)
+
+	t.Run("Active elevations shouldn't be extended", func(t *testing.T) {
+		_, err := breakGlass(time.Hour, "again")
+		require.ErrorIs(t, err, ErrBreakGlassActive)
+	})
+
+	t.Run("Expired elevations should be kept as audit trail", func(t *testing.T) {
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Exec("UPDATE user_policy SET expires_at = ? WHERE user_id = ?", time.Now().Add(-time.Minute), userID)
+			return err
+		})
+		require.NoError(t, err)
+
+		_, err = breakGlass(30*time.Minute, "second outage")
+		require.NoError(t, err)
+
+		grants, err := ac.GetBreakGlassGrants(context.Background(), GetBreakGlassGrantsQuery{OrgID: 1, UserID: userID})
+		require.NoError(t, err)
+		require.Len(t, grants, 2)
+		assert.Equal(t, "second outage", grants[0].Justification)
+		assert.Equal(t, "database outage", grants[1].Justification)
+	})
+}
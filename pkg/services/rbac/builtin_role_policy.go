@@ -0,0 +1,122 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// BuiltinRoleGrafanaAdmin is the builtin role of server administrators, the other builtin roles
+// are the organization roles Viewer, Editor and Admin.
+const BuiltinRoleGrafanaAdmin = "Grafana Admin"
+
+// BuiltinRoleAnonymous is the builtin role of anonymous users, for granting them permissions beyond
+// the organization role of anonymous access. Anonymous users hold it in addition to that role.
+const BuiltinRoleAnonymous = "Anonymous"
+
+// BuiltinRoles returns the builtin roles of a user. Organization roles include the roles below
+// them, so an Editor holds the policies bound to Viewer too.
+func BuiltinRoles(user *models.SignedInUser) []string {
+	var roles []string
+	for _, role := range []models.RoleType{models.ROLE_VIEWER, models.ROLE_EDITOR, models.ROLE_ADMIN} {
+		if user.OrgRole.Includes(role) {
+			roles = append(roles, string(role))
+		}
+	}
+	if user.IsGrafanaAdmin {
+		roles = append(roles, BuiltinRoleGrafanaAdmin)
+	}
+	if user.IsAnonymous {
+		roles = append(roles, BuiltinRoleAnonymous)
+	}
+
+	return roles
+}
+
+func validateBuiltinRole(role string) error {
+	if role != BuiltinRoleGrafanaAdmin && role != BuiltinRoleAnonymous && !models.RoleType(role).IsValid() {
+		return ErrInvalidBuiltinRole
+	}
+
+	return nil
+}
+
+// GetBuiltinRolePolicies returns the policies bound to a builtin role.
+func (ac *RBACService) GetBuiltinRolePolicies(ctx context.Context, query GetBuiltinRolePoliciesQuery) ([]*PolicyDTO, error) {
+	var policies []*PolicyDTO
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := `SELECT policy.* FROM policy
+			INNER JOIN builtin_role_policy ON policy.id = builtin_role_policy.policy_id
+			WHERE builtin_role_policy.org_id = ? AND builtin_role_policy.role = ?
+			ORDER BY policy.name ASC`
+		return sess.SQL(q, query.OrgID, query.Role).Find(&policies)
+	})
+
+	return policies, err
+}
+
+// AddBuiltinRolePolicy binds a policy to a builtin role, granting its permissions to every user
+// holding the role in addition to what the role allows by itself.
+func (ac *RBACService) AddBuiltinRolePolicy(ctx context.Context, cmd AddBuiltinRolePolicyCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionBuiltinRolePolicy); err != nil {
+		return err
+	}
+	if err := validateBuiltinRole(cmd.Role); err != nil {
+		return err
+	}
+
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID}); err != nil {
+			return err
+		}
+
+		builtinRolePolicy := &BuiltinRolePolicy{
+			OrgID:    cmd.OrgID,
+			PolicyID: cmd.PolicyID,
+			Role:     cmd.Role,
+			Created:  time.Now(),
+		}
+		if _, err := sess.Table("builtin_role_policy").Insert(builtinRolePolicy); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrBuiltinRolePolicyAlreadyAdded
+			}
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishPolicyChanged(cmd.OrgID, cmd.PolicyID, PolicyChangedBuiltinRoleBound)
+
+	return nil
+}
+
+// RemoveBuiltinRolePolicy unbinds a policy from a builtin role.
+func (ac *RBACService) RemoveBuiltinRolePolicy(ctx context.Context, cmd RemoveBuiltinRolePolicyCommand) error {
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := "DELETE FROM builtin_role_policy WHERE org_id = ? AND role = ? AND policy_id = ?"
+		result, err := sess.Exec(q, cmd.OrgID, cmd.Role, cmd.PolicyID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return ErrBuiltinRolePolicyNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishPolicyChanged(cmd.OrgID, cmd.PolicyID, PolicyChangedBuiltinRoleUnbound)
+
+	return nil
+}
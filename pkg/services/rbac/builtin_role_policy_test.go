@@ -0,0 +1,111 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestBuiltinRoles(t *testing.T) {
+	assert.Equal(t, []string{"Viewer"}, BuiltinRoles(&models.SignedInUser{OrgRole: models.ROLE_VIEWER}))
+	assert.Equal(t, []string{"Viewer", "Editor", "Admin"}, BuiltinRoles(&models.SignedInUser{OrgRole: models.ROLE_ADMIN}))
+	assert.Equal(t, []string{"Viewer", "Grafana Admin"},
+		BuiltinRoles(&models.SignedInUser{OrgRole: models.ROLE_VIEWER, IsGrafanaAdmin: true}))
+	assert.Equal(t, []string{"Viewer", "Anonymous"}, BuiltinRoles(&models.SignedInUser{OrgRole: models.ROLE_VIEWER, IsAnonymous: true}))
+}
+
+func TestBuiltinRolePolicies(t *testing.T) {
+	t.Run("Binding a policy to a builtin role should grant it to the users holding the role", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		viewers := createPolicy(t, ac, 1, "viewers", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+		editors := createPolicy(t, ac, 1, "editors", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+		admins := createPolicy(t, ac, 1, "server admins", CreatePermissionCommand{Action: "users:write", Scope: "users:*"})
+		require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: viewers.ID}))
+		require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Editor", PolicyID: editors.ID}))
+		require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: BuiltinRoleGrafanaAdmin, PolicyID: admins.ID}))
+
+		err := ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: viewers.ID})
+		require.ErrorIs(t, err, ErrBuiltinRolePolicyAlreadyAdded)
+
+		policies, err := ac.GetBuiltinRolePolicies(context.Background(), GetBuiltinRolePoliciesQuery{OrgID: 1, Role: "Editor"})
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+		assert.Equal(t, editors.ID, policies[0].ID)
+
+		viewer := &models.SignedInUser{OrgId: 1, UserId: 171, OrgRole: models.ROLE_VIEWER}
+		admin := &models.SignedInUser{OrgId: 1, UserId: 172, OrgRole: models.ROLE_ADMIN}
+		serverAdmin := &models.SignedInUser{OrgId: 1, UserId: 173, OrgRole: models.ROLE_VIEWER, IsGrafanaAdmin: true}
+
+		for _, tc := range []struct {
+			user    *models.SignedInUser
+			action  string
+			scope   string
+			allowed bool
+		}{
+			{user: viewer, action: "dashboards:read", scope: "dashboards:uid:abc", allowed: true},
+			{user: viewer, action: "dashboards:write", scope: "dashboards:uid:abc", allowed: false},
+			{user: admin, action: "dashboards:read", scope: "dashboards:uid:abc", allowed: true},
+			{user: admin, action: "dashboards:write", scope: "dashboards:uid:abc", allowed: true},
+			{user: admin, action: "users:write", scope: "users:id:1", allowed: false},
+			{user: serverAdmin, action: "users:write", scope: "users:id:1", allowed: true},
+		} {
+			ok, err := ac.evaluate(context.Background(), tc.user, accessRequest{Action: tc.action, Scope: tc.scope})
+			require.NoError(t, err)
+			assert.Equal(t, tc.allowed, ok, "%s %s", tc.user.OrgRole, tc.action)
+		}
+
+		require.NoError(t, ac.RemoveBuiltinRolePolicy(context.Background(), RemoveBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: viewers.ID}))
+		err = ac.RemoveBuiltinRolePolicy(context.Background(), RemoveBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: viewers.ID})
+		require.ErrorIs(t, err, ErrBuiltinRolePolicyNotFound)
+
+		ok, err := ac.evaluate(context.Background(), viewer, accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:abc"})
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Policies bound to the anonymous role should only apply to anonymous users", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		public := createPolicy(t, ac, 1, "public dashboards", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:uid:public"})
+		require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: BuiltinRoleAnonymous, PolicyID: public.ID}))
+
+		anonymous := &models.SignedInUser{OrgId: 1, OrgRole: models.ROLE_VIEWER, IsAnonymous: true}
+		ok, err := ac.evaluate(context.Background(), anonymous, accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:public"})
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		viewer := &models.SignedInUser{OrgId: 1, UserId: 174, OrgRole: models.ROLE_VIEWER}
+		ok, err = ac.evaluate(context.Background(), viewer, accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:public"})
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		otherOrg := &models.SignedInUser{OrgId: 2, OrgRole: models.ROLE_VIEWER, IsAnonymous: true}
+		ok, err = ac.evaluate(context.Background(), otherOrg, accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:public"})
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Binding a policy to an unknown role should fail", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "viewers")
+		err := ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Owner", PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrInvalidBuiltinRole)
+	})
+
+	t.Run("Deleting a policy should remove its builtin role bindings", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "viewers", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+		require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: policy.ID}))
+		require.NoError(t, ac.DeletePolicy(context.Background(), DeletePolicyCommand{OrgID: 1, ID: policy.ID}))
+
+		policies, err := ac.GetBuiltinRolePolicies(context.Background(), GetBuiltinRolePoliciesQuery{OrgID: 1, Role: "Viewer"})
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+}
@@ -0,0 +1,238 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// Reasons of the PolicyUnbound events of the bindings purged when their user or organization is deleted,
+// or by RepairBindings.
+const (
+	BindingReasonUserDeleted = "userDeleted"
+	BindingReasonOrgDeleted  = "orgDeleted"
+	BindingReasonRepair      = "repair"
+)
+
+// userTables are the tables holding the bindings and memberships of users, purged when a user is
+// deleted. Access requests and break-glass elevations are kept as audit trail.
+var userTables = []struct {
+	name    string
+	version int
+}{
+	{"user_policy", schemaVersionUserPolicy},
+	{"user_suspension", schemaVersionUserSuspension},
+	{"service_account", schemaVersionServiceAccount},
+	{"user_policy_sync", schemaVersionUserPolicySync},
+	{"external_group_member", schemaVersionExternalGroups},
+}
+
+// purge is a set of rows of a table to delete.
+type purge struct {
+	table string
+	where string
+	args  []interface{}
+}
+
+// PurgedRows is the number of rows deleted, or to delete, from a table.
+type PurgedRows struct {
+	Table string `json:"table"`
+	Rows  int64  `json:"rows"`
+}
+
+// RepairBindingsCommand is the command for deleting the RBAC rows of deleted users, teams and organizations.
+type RepairBindingsCommand struct {
+	// DryRun counts the rows without deleting them.
+	DryRun bool
+}
+
+// RepairBindingsResult is what RepairBindings deleted, or would delete on a dry run, by table.
+type RepairBindingsResult struct {
+	Tables []PurgedRows `json:"tables"`
+	Total  int64        `json:"total"`
+}
+
+// onUserDeleted purges the bindings and memberships of a deleted user in every organization.
+func (ac *RBACService) onUserDeleted(e *events.UserDeleted) error {
+	if !ac.IsEnabled() {
+		return nil
+	}
+
+	var purges []purge
+	for _, t := range userTables {
+		if t.version <= ac.schemaVersion {
+			purges = append(purges, purge{table: t.name, where: t.name + ".user_id = ?", args: []interface{}{e.UserID}})
+		}
+	}
+	if _, err := ac.purge(context.Background(), purges, newBindingChanges(0, BindingReasonUserDeleted), false); err != nil {
+		// Deleting the user succeeded regardless, and other listeners shouldn't be skipped.
+		ac.log.Error("Failed to purge the bindings of a deleted user", "userId", e.UserID, "error", err)
+	}
+	return nil
+}
+
+// onTeamDeleted unbinds the policies of a deleted team.
+func (ac *RBACService) onTeamDeleted(e *events.TeamDeleted) error {
+	if !ac.IsEnabled() {
+		return nil
+	}
+
+	if err := ac.RemoveAllTeamPolicies(context.Background(), RemoveAllTeamPoliciesCommand{OrgID: e.OrgID, TeamID: e.TeamID}); err != nil {
+		ac.log.Error("Failed to remove the policies of a deleted team", "orgId", e.OrgID, "teamId", e.TeamID, "error", err)
+	}
+	return nil
+}
+
+// onOrgDeleted purges the policies, bindings and settings of a deleted organization.
+func (ac *RBACService) onOrgDeleted(e *events.OrgDeleted) error {
+	if !ac.IsEnabled() {
+		return nil
+	}
+
+	purges := []purge{{
+		table: "permission",
+		where: "permission.policy_id IN (SELECT policy.id FROM policy WHERE policy.org_id = ?)",
+		args:  []interface{}{e.OrgID},
+	}}
+	for _, table := range orgTables(ac.schemaVersion) {
+		purges = append(purges, purge{table: table, where: table + ".org_id = ?", args: []interface{}{e.OrgID}})
+	}
+	if _, err := ac.purge(context.Background(), purges, newBindingChanges(0, BindingReasonOrgDeleted), false); err != nil {
+		ac.log.Error("Failed to purge the RBAC state of a deleted organization", "orgId", e.OrgID, "error", err)
+	}
+	return nil
+}
+
+// RepairBindings deletes the RBAC rows left behind by users, teams and organizations deleted before
+// Grafana purged them on deletion, or while RBAC was disabled. Only the tables of the schema version
+// of the database are repaired, so that it can run from the CLI.
+func (ac *RBACService) RepairBindings(ctx context.Context, cmd RepairBindingsCommand) (*RepairBindingsResult, error) {
+	var version int
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		applied, err := appliedMigrations(sess)
+		version = currentSchemaVersion(applied)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	userTable := ac.SQLStore.Dialect.Quote("user")
+	purges := []purge{{
+		table: "permission",
+		where: `NOT EXISTS (SELECT 1 FROM policy INNER JOIN org ON org.id = policy.org_id
+			WHERE policy.id = permission.policy_id)`,
+	}}
+	for _, table := range orgTables(version) {
+		purges = append(purges, purge{table: table, where: "NOT EXISTS (SELECT 1 FROM org WHERE org.id = " + table + ".org_id)"})
+	}
+	for _, t := range userTables {
+		if t.version <= version {
+			purges = append(purges, purge{
+				table: t.name,
+				where: "NOT EXISTS (SELECT 1 FROM " + userTable + " WHERE " + userTable + ".id = " + t.name + ".user_id)",
+			})
+		}
+	}
+	purges = append(purges, purge{table: "team_policy", where: "NOT EXISTS (SELECT 1 FROM team WHERE team.id = team_policy.team_id)"})
+
+	purged, err := ac.purge(ctx, purges, newBindingChanges(0, BindingReasonRepair), cmd.DryRun)
+	if err != nil {
+		return nil, err
+	}
+
+	result := &RepairBindingsResult{Tables: []PurgedRows{}}
+	for _, p := range purged {
+		if p.Rows > 0 {
+			result.Tables = append(result.Tables, p)
+			result.Total += p.Rows
+		}
+	}
+	if !cmd.DryRun && result.Total > 0 {
+		ac.log.Info("Repaired RBAC bindings", "rows", result.Total)
+	}
+
+	return result, nil
+}
+
+// orgTables returns the tables of a schema version holding rows of an organization, with the policy
+// table last. The permission table has no org_id column, see onOrgDeleted, and the enforcement modes
+// apply to every organization.
+func orgTables(version int) []string {
+	var tables []string
+	for i := len(stateTables) - 1; i >= 0; i-- {
+		t := stateTables[i]
+		if t.version > version || t.name == "permission" || t.name == "user_policy_expiry_notice" ||
+			t.name == "enforcement_mode" {
+			continue
+		}
+		tables = append(tables, t.name)
+	}
+	return tables
+}
+
+// purge deletes the rows of each purge in a single transaction and returns the number of rows of each
+// table, summed over the purges. The unbinding events of the deleted user and team bindings are
+// published once it's committed. Nothing is deleted on a dry run.
+func (ac *RBACService) purge(ctx context.Context, purges []purge, changes *bindingChanges, dryRun bool) ([]PurgedRows, error) {
+	var purged []PurgedRows
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		counts := map[string]int64{}
+		for _, p := range purges {
+			var count int64
+			switch p.table {
+			case "user_policy":
+				var removed []UserPolicy
+				if err := sess.Table("user_policy").Where(p.where, p.args...).Find(&removed); err != nil {
+					return err
+				}
+				for _, up := range removed {
+					changes.userUnbound(up.OrgID, up.PolicyID, up.UserID)
+				}
+				count = int64(len(removed))
+			case "team_policy":
+				var removed []TeamPolicy
+				if err := sess.Table("team_policy").Where(p.where, p.args...).Find(&removed); err != nil {
+					return err
+				}
+				for _, tp := range removed {
+					changes.teamUnbound(tp.OrgID, tp.PolicyID, tp.TeamID)
+				}
+				count = int64(len(removed))
+			default:
+				var err error
+				if count, err = sess.Table(p.table).Where(p.where, p.args...).Count(); err != nil {
+					return err
+				}
+			}
+			if count == 0 {
+				continue
+			}
+
+			if _, ok := counts[p.table]; !ok {
+				purged = append(purged, PurgedRows{Table: p.table})
+			}
+			counts[p.table] += count
+			if dryRun {
+				continue
+			}
+			if _, err := sess.Exec(append([]interface{}{"DELETE FROM " + p.table + " WHERE " + p.where}, p.args...)...); err != nil {
+				return err
+			}
+		}
+		for i := range purged {
+			purged[i].Rows = counts[purged[i].Table]
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if !dryRun {
+		ac.publishBindingChanges(changes)
+	}
+
+	return purged, nil
+}
@@ -0,0 +1,106 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestCleanupOnDeletion(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "cleaned up", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+
+	t.Run("Deleting a user should purge their bindings", func(t *testing.T) {
+		user := &models.CreateUserCommand{Login: "leaver", SkipOrgSetup: true}
+		require.NoError(t, sqlstore.CreateUser(context.Background(), user))
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: user.Result.Id, PolicyID: policy.ID}))
+
+		require.NoError(t, sqlstore.DeleteUser(&models.DeleteUserCommand{UserId: user.Result.Id}))
+
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: user.Result.Id})
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+
+	t.Run("Deleting a team should purge its bindings", func(t *testing.T) {
+		team := createTeam(t, 1, "disbanded")
+		require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+		require.NoError(t, sqlstore.DeleteTeam(&models.DeleteTeamCommand{OrgId: 1, Id: team.Id}))
+
+		policies, err := ac.GetTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: 1, TeamID: team.Id})
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+
+	t.Run("Deleting an organization should purge its policies", func(t *testing.T) {
+		org := &models.CreateOrgCommand{Name: "closed down"}
+		require.NoError(t, sqlstore.CreateOrg(org))
+		orgPolicy := createPolicy(t, ac, org.Result.Id, "closed", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+		require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: org.Result.Id, Role: "Viewer", PolicyID: orgPolicy.ID}))
+
+		require.NoError(t, sqlstore.DeleteOrg(&models.DeleteOrgCommand{Id: org.Result.Id}))
+
+		policies, err := ac.GetPolicies(context.Background(), org.Result.Id)
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+		err = ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			count, err := sess.Table("permission").Where("policy_id = ?", orgPolicy.ID).Count()
+			assert.Zero(t, count)
+			return err
+		})
+		require.NoError(t, err)
+	})
+}
+
+func TestRepairBindings(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	_, err := ac.RepairBindings(context.Background(), RepairBindingsCommand{})
+	require.NoError(t, err)
+
+	org := &models.CreateOrgCommand{Name: "repaired"}
+	require.NoError(t, sqlstore.CreateOrg(org))
+	policy := createPolicy(t, ac, org.Result.Id, "kept", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	team := createTeam(t, org.Result.Id, "kept")
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: org.Result.Id, TeamID: team.Id, PolicyID: policy.ID}))
+
+	// Rows of a user, a team and an organization deleted before they were purged on deletion.
+	err = ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		now := time.Now()
+		if _, err := sess.Table("user_policy").Insert(&UserPolicy{OrgID: org.Result.Id, PolicyID: policy.ID, UserID: 399, Created: now}); err != nil {
+			return err
+		}
+		if _, err := sess.Table("team_policy").Insert(&TeamPolicy{OrgID: org.Result.Id, PolicyID: policy.ID, TeamID: 9999, Created: now}); err != nil {
+			return err
+		}
+		_, err := sess.Table("policy").Insert(&Policy{OrgID: 9999, UID: "deleted-org", Name: "deleted org", Enabled: true, Created: now, Updated: now})
+		return err
+	})
+	require.NoError(t, err)
+
+	result, err := ac.RepairBindings(context.Background(), RepairBindingsCommand{DryRun: true})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+	assert.ElementsMatch(t, []PurgedRows{{Table: "policy", Rows: 1}, {Table: "user_policy", Rows: 1}, {Table: "team_policy", Rows: 1}}, result.Tables)
+
+	result, err = ac.RepairBindings(context.Background(), RepairBindingsCommand{})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.Total)
+
+	result, err = ac.RepairBindings(context.Background(), RepairBindingsCommand{})
+	require.NoError(t, err)
+	assert.Zero(t, result.Total)
+
+	policies, err := ac.GetTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: org.Result.Id, TeamID: team.Id})
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+	assert.Equal(t, policy.ID, policies[0].ID)
+}
@@ -0,0 +1,104 @@
+package rbac
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Combining algorithms, deciding an access check when both allow and deny permissions of the highest
+// precedence apply to it.
+const (
+	// CombiningDenyOverrides denies access when any of the applicable permissions denies it, it's the
+	// algorithm of every action by default.
+	CombiningDenyOverrides = "deny_overrides"
+	// CombiningAllowOverrides allows access when any of the applicable permissions allows it.
+	CombiningAllowOverrides = "allow_overrides"
+	// CombiningFirstApplicable decides by the applicable permission created first.
+	CombiningFirstApplicable = "first_applicable"
+)
+
+// combiningAlgorithms holds the combining algorithm of every action and those of the actions or
+// wildcard patterns set in the rbac.conflict_resolution section.
+type combiningAlgorithms struct {
+	defaultAlgorithm string
+	actions          map[string]string
+}
+
+// loadCombiningAlgorithms reads the combining algorithm of every action from the
+// rbac.conflict_resolution setting and those of actions or action groups from the
+// rbac.conflict_resolution section, e.g. dashboards:* = allow_overrides.
+func (ac *RBACService) loadCombiningAlgorithms() error {
+	validate := func(name, algorithm string) error {
+		switch algorithm {
+		case CombiningDenyOverrides, CombiningAllowOverrides, CombiningFirstApplicable:
+			return nil
+		default:
+			return fmt.Errorf("invalid %s combining algorithm %q, expected %s, %s or %s", name, algorithm,
+				CombiningDenyOverrides, CombiningAllowOverrides, CombiningFirstApplicable)
+		}
+	}
+
+	algorithms := &combiningAlgorithms{
+		defaultAlgorithm: strings.TrimSpace(ac.Cfg.Raw.Section("rbac").Key("conflict_resolution").MustString(CombiningDenyOverrides)),
+		actions:          map[string]string{},
+	}
+	if err := validate("rbac.conflict_resolution", algorithms.defaultAlgorithm); err != nil {
+		return err
+	}
+	for _, key := range ac.Cfg.Raw.Section("rbac.conflict_resolution").Keys() {
+		algorithm := strings.TrimSpace(key.String())
+		if err := validate("rbac.conflict_resolution "+key.Name(), algorithm); err != nil {
+			return err
+		}
+		if err := validatePermission(key.Name(), ""); err != nil {
+			return fmt.Errorf("invalid rbac.conflict_resolution action %q: %w", key.Name(), err)
+		}
+		algorithms.actions[key.Name()] = algorithm
+	}
+
+	ac.combiningAlgorithms = algorithms
+	return nil
+}
+
+// forAction returns the combining algorithm of the most specific action or pattern matching an
+// action, or the default one.
+func (c *combiningAlgorithms) forAction(action string) string {
+	if c == nil {
+		return CombiningDenyOverrides
+	}
+
+	if algorithm, ok := mostSpecificPattern(c.actions, action); ok {
+		return algorithm
+	}
+	return c.defaultAlgorithm
+}
+
+// mostSpecificPattern returns the value of an action in a map keyed by actions and wildcard patterns,
+// or else that of the matching pattern with the most segments.
+func mostSpecificPattern(values map[string]string, action string) (string, bool) {
+	if value, ok := values[action]; ok {
+		return value, true
+	}
+
+	value, segments := "", -1
+	for pattern, v := range values {
+		if n := strings.Count(pattern, segmentSeparator); n > segments && matchPattern(pattern, action) {
+			value, segments = v, n
+		}
+	}
+
+	return value, segments >= 0
+}
+
+// createdBefore returns true if a permission was created before another. Permissions without an ID,
+// e.g. those of a simulation, come last.
+func createdBefore(a, b Permission) bool {
+	switch {
+	case a.ID == 0:
+		return false
+	case b.ID == 0:
+		return true
+	default:
+		return a.ID < b.ID
+	}
+}
@@ -0,0 +1,75 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestEvaluatePermissions_CombiningAlgorithms(t *testing.T) {
+	permissions := []Permission{
+		{ID: 2, Action: "dashboards:delete", Scope: "dashboards:uid:prod", Kind: PermissionKindDeny},
+		{ID: 1, Action: "dashboards:*", Scope: "dashboards:*", Kind: PermissionKindAllow},
+	}
+	evaluate := func(algorithm string, permissions []Permission) bool {
+		return evaluatePermissions(permissions, accessRequest{
+			Action: "dashboards:delete", Scope: "dashboards:uid:prod",
+			combining: &combiningAlgorithms{defaultAlgorithm: algorithm},
+		})
+	}
+
+	assert.False(t, evaluate(CombiningDenyOverrides, permissions))
+	assert.True(t, evaluate(CombiningAllowOverrides, permissions))
+	assert.True(t, evaluate(CombiningFirstApplicable, permissions))
+
+	t.Run("First applicable should follow the creation order, not the order of the permissions", func(t *testing.T) {
+		permissions := []Permission{permissions[1], permissions[0]}
+		permissions[0].ID, permissions[1].ID = 3, 1
+		assert.False(t, evaluate(CombiningFirstApplicable, permissions))
+	})
+
+	t.Run("Precedence should apply before the combining algorithm", func(t *testing.T) {
+		high := 10
+		permissions := []Permission{permissions[0], permissions[1]}
+		permissions[0].Precedence = &high
+		assert.False(t, evaluate(CombiningAllowOverrides, permissions))
+	})
+}
+
+func TestCombiningAlgorithmSettings(t *testing.T) {
+	ac := setupTestEnv(t)
+	_, err := ac.Cfg.Raw.Section("rbac.conflict_resolution").NewKey("dashboards:*", CombiningAllowOverrides)
+	require.NoError(t, err)
+	_, err = ac.Cfg.Raw.Section("rbac.conflict_resolution").NewKey(ActionDashboardsDelete, CombiningDenyOverrides)
+	require.NoError(t, err)
+	require.NoError(t, ac.loadCombiningAlgorithms())
+
+	assert.Equal(t, CombiningAllowOverrides, ac.combiningAlgorithms.forAction(ActionDashboardsWrite))
+	assert.Equal(t, CombiningDenyOverrides, ac.combiningAlgorithms.forAction(ActionDashboardsDelete))
+	assert.Equal(t, CombiningDenyOverrides, ac.combiningAlgorithms.forAction(ActionTeamsWrite))
+
+	t.Run("Access checks should use the algorithm of their action", func(t *testing.T) {
+		policy := createPolicy(t, ac, 1, "conflicting",
+			CreatePermissionCommand{Action: "dashboards:*", Scope: "dashboards:*"},
+			CreatePermissionCommand{Action: "dashboards:*", Scope: "dashboards:uid:prod", Kind: PermissionKindDeny})
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 495, PolicyID: policy.ID}))
+		user := &models.SignedInUser{OrgId: 1, UserId: 495}
+
+		ok, err := ac.HasPermission(context.Background(), user, ActionDashboardsWrite, "dashboards:uid:prod")
+		require.NoError(t, err)
+		assert.True(t, ok)
+		ok, err = ac.HasPermission(context.Background(), user, ActionDashboardsDelete, "dashboards:uid:prod")
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Unknown algorithms should be rejected", func(t *testing.T) {
+		_, err := ac.Cfg.Raw.Section("rbac").NewKey("conflict_resolution", "majority")
+		require.NoError(t, err)
+		require.Error(t, ac.loadCombiningAlgorithms())
+	})
+}
@@ -0,0 +1,115 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+)
+
+// ErrSchemaOutdated is an error for when a write requires a more recent RBAC schema than the database has.
+var ErrSchemaOutdated = errors.New("RBAC database schema is outdated")
+
+// CompatibilityReport describes how the RBAC schema of the database relates to the schema
+// supported by this version of Grafana.
+type CompatibilityReport struct {
+	// SchemaVersion is the RBAC schema version the database has been migrated to.
+	SchemaVersion int `json:"schemaVersion"`
+	// SupportedSchemaVersion is the most recent RBAC schema version known to this version of Grafana.
+	SupportedSchemaVersion int `json:"supportedSchemaVersion"`
+	// MissingMigrations lists the migrations needed to bring the database to SupportedSchemaVersion.
+	MissingMigrations []string `json:"missingMigrations"`
+}
+
+// IsUpToDate returns true if the database has every migration this version of Grafana knows about.
+func (r CompatibilityReport) IsUpToDate() bool {
+	return r.SchemaVersion >= r.SupportedSchemaVersion
+}
+
+// CheckCompatibility inspects the migration log and reports the RBAC schema version of the database.
+func (ac *RBACService) CheckCompatibility(ctx context.Context) (*CompatibilityReport, error) {
+	var applied map[string]bool
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		applied, err = appliedMigrations(sess)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	report := &CompatibilityReport{
+		SchemaVersion:          currentSchemaVersion(applied),
+		SupportedSchemaVersion: supportedSchemaVersion,
+		MissingMigrations:      []string{},
+	}
+	for _, v := range schemaVersions {
+		if !applied[v.MigrationID] {
+			report.MissingMigrations = append(report.MissingMigrations, v.MigrationID)
+		}
+	}
+
+	return report, nil
+}
+
+// checkSchemaVersion returns ErrSchemaOutdated if the database schema is older than required.
+// It guards writes to columns that older schema versions don't have.
+func (ac *RBACService) checkSchemaVersion(required int) error {
+	if ac.schemaVersion < required {
+		return fmt.Errorf("%w: version %d is required but the database is at version %d", ErrSchemaOutdated, required, ac.schemaVersion)
+	}
+
+	return nil
+}
+
+// loadSchemaVersion reads the RBAC schema version of the database.
+func (ac *RBACService) loadSchemaVersion(ctx context.Context) error {
+	return ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		applied, err := appliedMigrations(sess)
+		if err != nil {
+			return err
+		}
+
+		ac.schemaVersion = currentSchemaVersion(applied)
+		return nil
+	})
+}
+
+// currentSchemaVersion returns the highest schema version for which every migration has been applied.
+func currentSchemaVersion(applied map[string]bool) int {
+	version := 0
+	for _, v := range schemaVersions {
+		if !applied[v.MigrationID] {
+			break
+		}
+		version = v.Version
+	}
+
+	return version
+}
+
+func appliedMigrations(sess *sqlstore.DBSession) (map[string]bool, error) {
+	exists, err := sess.IsTableExist("migration_log")
+	if err != nil {
+		return nil, err
+	}
+
+	applied := make(map[string]bool)
+	if !exists {
+		return applied, nil
+	}
+
+	var logs []migrator.MigrationLog
+	if err := sess.Table("migration_log").Find(&logs); err != nil {
+		return nil, err
+	}
+	for _, l := range logs {
+		if l.Success {
+			applied[l.MigrationID] = true
+		}
+	}
+
+	return applied, nil
+}
@@ -0,0 +1,34 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheckCompatibility(t *testing.T) {
+	t.Run("A fully migrated database should be up to date", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		report, err := ac.CheckCompatibility(context.Background())
+		require.NoError(t, err)
+		assert.Equal(t, supportedSchemaVersion, report.SchemaVersion)
+		assert.Empty(t, report.MissingMigrations)
+		assert.True(t, report.IsUpToDate())
+	})
+
+	t.Run("Writes should be refused when the schema is older than required", func(t *testing.T) {
+		ac := setupTestEnv(t)
+		ac.schemaVersion = 0
+
+		_, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "editor"})
+		require.ErrorIs(t, err, ErrSchemaOutdated)
+	})
+}
+
+func TestCurrentSchemaVersion(t *testing.T) {
+	assert.Equal(t, 0, currentSchemaVersion(map[string]bool{}))
+	assert.Equal(t, schemaVersionInitial, currentSchemaVersion(map[string]bool{schemaVersions[0].MigrationID: true}))
+}
@@ -0,0 +1,126 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// Well-known attribute names that conditions can match on.
+const (
+	AttributeDashboardTags  = "dashboard.tags"
+	AttributeDatasourceType = "datasource.type"
+	AttributeUserLogin      = "user.login"
+	AttributeUserEmail      = "user.email"
+	AttributeUserOrgRole    = "user.orgRole"
+)
+
+// ErrInvalidCondition is an error for when a permission condition is malformed.
+var ErrInvalidCondition = errors.New("invalid condition")
+
+// Attributes are the properties of the user and resource of an access check that
+// conditions are evaluated against, keyed by attribute name.
+type Attributes map[string][]string
+
+// Condition restricts a permission to access checks where the attribute has at least
+// one of the values, to the time windows of a schedule, to requests originating from
+// one of the source networks, or to users who authenticated recently. A permission with
+// several conditions applies only when all of them match.
+type Condition struct {
+	Attribute string    `json:"attribute,omitempty"`
+	Values    []string  `json:"values,omitempty"`
+	Schedule  *Schedule `json:"schedule,omitempty"`
+	// SourceNetworks are CIDR ranges, e.g. 10.0.0.0/8, the request's remote address must belong to.
+	SourceNetworks []string `json:"sourceNetworks,omitempty"`
+	// MaxAuthAge is a duration, e.g. 15m, the user must have logged in within. Requests that
+	// aren't authenticated by a login session, such as API key requests, never match.
+	MaxAuthAge string `json:"maxAuthAge,omitempty"`
+}
+
+// UserAttributes returns the attributes describing a signed in user.
+func UserAttributes(user *models.SignedInUser) Attributes {
+	return Attributes{
+		AttributeUserLogin:   {user.Login},
+		AttributeUserEmail:   {user.Email},
+		AttributeUserOrgRole: {string(user.OrgRole)},
+	}
+}
+
+// matches returns true if the request matches the condition.
+// A missing attribute never matches.
+func (c Condition) matches(req accessRequest) bool {
+	if c.Schedule != nil {
+		return c.Schedule.isActive(req.Time)
+	}
+	if len(c.SourceNetworks) > 0 {
+		return matchSourceNetworks(c.SourceNetworks, req.RemoteAddr)
+	}
+	if c.MaxAuthAge != "" {
+		return matchAuthAge(c.MaxAuthAge, req)
+	}
+
+	for _, actual := range req.Attributes[c.Attribute] {
+		for _, expected := range c.Values {
+			if actual == expected {
+				return true
+			}
+		}
+	}
+
+	return false
+}
+
+// matchConditions returns true if every condition matches the request.
+func matchConditions(conditions []Condition, req accessRequest) bool {
+	for _, c := range conditions {
+		if !c.matches(req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// validateConditions checks that every condition either has a valid schedule, valid source
+// networks, a valid maximum authentication age, or names an attribute and at least one value.
+func validateConditions(conditions []Condition) error {
+	for _, c := range conditions {
+		hasAttribute := c.Attribute != "" || len(c.Values) > 0
+		if c.MaxAuthAge != "" {
+			if hasAttribute || c.Schedule != nil || len(c.SourceNetworks) > 0 {
+				return fmt.Errorf("%w: an authentication age condition can't have an attribute, schedule or source networks", ErrInvalidCondition)
+			}
+			if err := validateAuthAge(c.MaxAuthAge); err != nil {
+				return fmt.Errorf("%w: %s", ErrInvalidCondition, err)
+			}
+			continue
+		}
+		if c.Schedule != nil {
+			if hasAttribute || len(c.SourceNetworks) > 0 {
+				return fmt.Errorf("%w: a schedule condition can't have an attribute or source networks", ErrInvalidCondition)
+			}
+			if err := c.Schedule.validate(); err != nil {
+				return fmt.Errorf("%w: %s", ErrInvalidCondition, err)
+			}
+			continue
+		}
+		if len(c.SourceNetworks) > 0 {
+			if hasAttribute {
+				return fmt.Errorf("%w: a source network condition can't have an attribute", ErrInvalidCondition)
+			}
+			if err := validateSourceNetworks(c.SourceNetworks); err != nil {
+				return fmt.Errorf("%w: %s", ErrInvalidCondition, err)
+			}
+			continue
+		}
+		if c.Attribute == "" {
+			return fmt.Errorf("%w: attribute is required", ErrInvalidCondition)
+		}
+		if len(c.Values) == 0 {
+			return fmt.Errorf("%w: attribute %q needs at least one value", ErrInvalidCondition, c.Attribute)
+		}
+	}
+
+	return nil
+}
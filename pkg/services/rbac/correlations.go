@@ -0,0 +1,51 @@
+package rbac
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// ActionDatasourcesQuery allows querying a datasource.
+const ActionDatasourcesQuery = "datasources:query"
+
+// Actions for correlations and data links between datasources, scoped by the datasource the link starts from.
+const (
+	ActionCorrelationsCreate = "correlations:create"
+	ActionCorrelationsUse    = "correlations:use"
+)
+
+func init() {
+	RegisterActions(
+		ActionDefinition{Action: ActionDatasourcesQuery, Description: "Query datasources"},
+		ActionDefinition{Action: ActionCorrelationsCreate, Description: "Create correlations between datasources"},
+		ActionDefinition{Action: ActionCorrelationsUse, Description: "Resolve correlations between datasources"},
+	)
+}
+
+// ScopeDatasourceID returns the scope of a datasource identified by its id.
+func ScopeDatasourceID(id int64) string {
+	return "datasources:id:" + strconv.FormatInt(id, 10)
+}
+
+// CanCreateCorrelation returns true if the user may link the source datasource to the target datasource.
+// Creating a link requires the create action on the source and query access to both datasources.
+func (ac *RBACService) CanCreateCorrelation(ctx context.Context, user *models.SignedInUser, sourceID, targetID int64) (bool, error) {
+	return ac.evaluateRequests(ctx, user,
+		accessRequest{Action: ActionCorrelationsCreate, Scope: ScopeDatasourceID(sourceID)},
+		accessRequest{Action: ActionDatasourcesQuery, Scope: ScopeDatasourceID(sourceID)},
+		accessRequest{Action: ActionDatasourcesQuery, Scope: ScopeDatasourceID(targetID)},
+	)
+}
+
+// CanResolveCorrelation returns true if the user may follow a link from the source datasource to the
+// target datasource. It must be checked every time a link is resolved, since access to the target may
+// have been revoked after the link was created, and being allowed to query the source never implies
+// being allowed to query the target.
+func (ac *RBACService) CanResolveCorrelation(ctx context.Context, user *models.SignedInUser, sourceID, targetID int64) (bool, error) {
+	return ac.evaluateRequests(ctx, user,
+		accessRequest{Action: ActionCorrelationsUse, Scope: ScopeDatasourceID(sourceID)},
+		accessRequest{Action: ActionDatasourcesQuery, Scope: ScopeDatasourceID(targetID)},
+	)
+}
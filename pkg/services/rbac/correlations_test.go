@@ -0,0 +1,50 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestCorrelationPermissions(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "sre")
+	addTeamMember(t, 1, team.Id, 7)
+	policy := createPolicy(t, ac, 1, "linker",
+		CreatePermissionCommand{Action: "correlations:*", Scope: ScopeDatasourceID(1)},
+		CreatePermissionCommand{Action: ActionDatasourcesQuery, Scope: ScopeDatasourceID(1)},
+		CreatePermissionCommand{Action: ActionDatasourcesQuery, Scope: ScopeDatasourceID(2)},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 7}
+
+	ok, err := ac.CanCreateCorrelation(context.Background(), user, 1, 2)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	t.Run("Linking to a restricted datasource should be refused", func(t *testing.T) {
+		ok, err := ac.CanCreateCorrelation(context.Background(), user, 1, 3)
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		ok, err = ac.CanResolveCorrelation(context.Background(), user, 1, 3)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Links can only be used from datasources the user may use them from", func(t *testing.T) {
+		ok, err := ac.CanResolveCorrelation(context.Background(), user, 1, 2)
+		require.NoError(t, err)
+		assert.True(t, ok)
+
+		ok, err = ac.CanResolveCorrelation(context.Background(), user, 2, 1)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
@@ -0,0 +1,50 @@
+package rbac
+
+import (
+	"context"
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// Actions gating the extraction and loading of dashboard definitions, scoped by folder.
+const (
+	ActionDashboardsExport = "dashboards:export"
+	ActionDashboardsImport = "dashboards:import"
+)
+
+// Actions on the folders themselves, scoped by folders:uid:<uid>. Access to the dashboards of a folder
+// is granted by the dashboards actions on the folder's scope.
+const (
+	ActionFoldersRead  = "folders:read"
+	ActionFoldersWrite = "folders:write"
+)
+
+func init() {
+	RegisterActions(
+		ActionDefinition{Action: ActionDashboardsExport, Description: "Export dashboard JSON models"},
+		ActionDefinition{Action: ActionDashboardsImport, Description: "Import dashboard JSON models"},
+		ActionDefinition{Action: ActionFoldersRead, Description: "Read folders"},
+		ActionDefinition{Action: ActionFoldersWrite, Description: "Update folders"},
+	)
+}
+
+// ScopeFolderID returns the scope of a folder identified by its id. The General folder has id 0.
+func ScopeFolderID(id int64) string {
+	return "folders:id:" + strconv.FormatInt(id, 10)
+}
+
+// CanExportDashboard returns true if the user may export the JSON model of a dashboard, provisioned
+// dashboards included. Permissions on the dashboard's uid, on its folder's uid, which cover the dashboards
+// of the folder, and on its folder's id apply. legacyAllowed is the decision of legacy access control,
+// see EvaluateWithLegacy.
+func (ac *RBACService) CanExportDashboard(ctx context.Context, user *models.SignedInUser, dashboardUID string, folderID int64, legacyAllowed bool) (bool, error) {
+	evaluator := Any(Perm(ActionDashboardsExport, ScopeDashboardUID(dashboardUID)), Perm(ActionDashboardsExport, ScopeFolderID(folderID)))
+	return ac.EvaluateWithLegacy(ctx, user, evaluator, legacyAllowed)
+}
+
+// CanImportDashboard returns true if the user may import dashboard JSON models into the folder.
+// legacyAllowed is the decision of legacy access control, see EvaluateWithLegacy.
+func (ac *RBACService) CanImportDashboard(ctx context.Context, user *models.SignedInUser, folderID int64, legacyAllowed bool) (bool, error) {
+	return ac.EvaluateWithLegacy(ctx, user, requestsEvaluator{{Action: ActionDashboardsImport, Scope: ScopeFolderID(folderID)}}, legacyAllowed)
+}
@@ -0,0 +1,82 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestDashboardExportImportPermissions(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "governance")
+	addTeamMember(t, 1, team.Id, 7)
+	policy := createPolicy(t, ac, 1, "exporter",
+		CreatePermissionCommand{Action: ActionDashboardsExport, Scope: "folders:*"},
+		CreatePermissionCommand{Action: ActionDashboardsImport, Scope: ScopeFolderID(3)},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 7}
+
+	ok, err := ac.CanExportDashboard(context.Background(), user, "abcdefghi", 0, false)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	dashboardPolicy := createPolicy(t, ac, 1, "dashboard exporter",
+		CreatePermissionCommand{Action: ActionDashboardsExport, Scope: ScopeDashboardUID("abcdefghi")},
+	)
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 8, PolicyID: dashboardPolicy.ID}))
+	other := &models.SignedInUser{OrgId: 1, UserId: 8}
+
+	ok, err = ac.CanExportDashboard(context.Background(), other, "abcdefghi", 3, false)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ac.CanExportDashboard(context.Background(), other, "jklmnopqr", 3, false)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = ac.CanImportDashboard(context.Background(), user, 3, false)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ac.CanImportDashboard(context.Background(), user, 0, true)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestDashboardExportImportPermissions_LegacyFallback(t *testing.T) {
+	ac := setupTestEnv(t)
+	_, err := ac.Cfg.Raw.Section("rbac.resource_modes").NewKey("dashboards", ResourceModeCompat)
+	require.NoError(t, err)
+	require.NoError(t, ac.loadResourceModes())
+	user := &models.SignedInUser{OrgId: 1, UserId: 8}
+
+	for _, legacyAllowed := range []bool{true, false} {
+		ok, err := ac.CanImportDashboard(context.Background(), user, 3, legacyAllowed)
+		require.NoError(t, err)
+		assert.Equal(t, legacyAllowed, ok)
+	}
+
+	ok, err := ac.Evaluate(context.Background(), user, Perm(ActionDashboardsImport, ScopeFolderID(3)))
+	require.NoError(t, err)
+	assert.False(t, ok, "access checks deferring to legacy access control should be denied")
+
+	ac.Cfg.Raw.Section("rbac.resource_modes").Key("dashboards").SetValue(ResourceModeShadow)
+	require.NoError(t, ac.loadResourceModes())
+	policy := createPolicy(t, ac, 1, "importer", CreatePermissionCommand{Action: ActionDashboardsImport, Scope: "folders:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: user.UserId, PolicyID: policy.ID}))
+
+	ok, err = ac.CanImportDashboard(context.Background(), user, 3, false)
+	require.NoError(t, err)
+	assert.False(t, ok, "legacy access control should decide in shadow mode")
+
+	ok, err = ac.Evaluate(context.Background(), user, Perm(ActionDashboardsImport, ScopeFolderID(3)))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
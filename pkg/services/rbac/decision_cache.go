@@ -0,0 +1,58 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type decisionCacheKey struct{}
+
+// decisionCache memoizes the access decisions taken while handling a request, so that repeated
+// checks of the same requirement don't resolve the user's permissions again.
+type decisionCache struct {
+	mu        sync.Mutex
+	decisions map[string]*Decision
+}
+
+// WithDecisionCache returns a copy of the context carrying a cache of the access decisions taken
+// with it, see Decide. RequestContext attaches one to the request, so that it lives as long as the
+// request does. Changes to the user's permissions aren't seen by the checks hitting the cache.
+func WithDecisionCache(ctx context.Context) context.Context {
+	return context.WithValue(ctx, decisionCacheKey{}, &decisionCache{decisions: map[string]*Decision{}})
+}
+
+func decisionCacheFromContext(ctx context.Context) *decisionCache {
+	cache, _ := ctx.Value(decisionCacheKey{}).(*decisionCache)
+	return cache
+}
+
+func (c *decisionCache) get(key string) (*Decision, bool) {
+	if c == nil {
+		return nil, false
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	decision, ok := c.decisions[key]
+	return decision, ok
+}
+
+func (c *decisionCache) set(key string, decision *Decision) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.decisions[key] = decision
+}
+
+// decisionKey identifies an access check by the user, the requirement and the parts of the
+// environment that callers may override within a request, e.g. the authentication time.
+func decisionKey(user *models.SignedInUser, evaluator Evaluator, env Environment) string {
+	return fmt.Sprintf("%d:%d:%d:%s:%t:%d:%s:%s", user.OrgId, user.UserId, user.ApiKeyId, user.OrgRole,
+		user.IsAnonymous, env.AuthTime.UnixNano(), env.RemoteAddr, evaluator.String())
+}
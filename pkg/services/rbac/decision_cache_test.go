@@ -0,0 +1,59 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	macaron "gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestDecisionCache(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "cached", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 431, PolicyID: policy.ID}))
+	user := &models.SignedInUser{OrgId: 1, UserId: 431}
+
+	ctx := WithDecisionCache(context.Background())
+	ok, err := ac.HasPermission(ctx, user, "dashboards:read", "dashboards:uid:home")
+	require.NoError(t, err)
+	require.True(t, ok)
+
+	require.NoError(t, ac.RemoveUserPolicy(context.Background(), RemoveUserPolicyCommand{OrgID: 1, UserID: 431, PolicyID: policy.ID}))
+
+	ok, err = ac.HasPermission(ctx, user, "dashboards:read", "dashboards:uid:home")
+	require.NoError(t, err)
+	assert.True(t, ok, "repeated checks should be taken from the cache")
+
+	ok, err = ac.HasPermission(ctx, user, "dashboards:read", "dashboards:uid:other")
+	require.NoError(t, err)
+	assert.False(t, ok, "other scopes should be decided")
+
+	ok, err = ac.HasPermission(ctx, &models.SignedInUser{OrgId: 1, UserId: 432}, "dashboards:read", "dashboards:uid:home")
+	require.NoError(t, err)
+	assert.False(t, ok, "other users should be decided")
+
+	ok, err = ac.HasPermission(context.Background(), user, "dashboards:read", "dashboards:uid:home")
+	require.NoError(t, err)
+	assert.False(t, ok, "checks without a cache should be decided")
+}
+
+func TestRequestContextDecisionCache(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	req, err := http.NewRequest("GET", "/api/dashboards/uid/abc", nil)
+	require.NoError(t, err)
+	c := &models.ReqContext{
+		Context:      &macaron.Context{Req: macaron.Request{Request: req}},
+		SignedInUser: &models.SignedInUser{OrgId: 1, UserId: 433},
+	}
+
+	cache := decisionCacheFromContext(ac.RequestContext(c))
+	require.NotNil(t, cache)
+	assert.Same(t, cache, decisionCacheFromContext(ac.RequestContext(c)), "the checks of a request should share its cache")
+}
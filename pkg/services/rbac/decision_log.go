@@ -0,0 +1,142 @@
+package rbac
+
+import (
+	"context"
+	"math/rand"
+	"sort"
+	"strconv"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// decisionLog logs access decisions for forensics: every denial and a sample of the allowed
+// access checks, so that the log stays manageable.
+type decisionLog struct {
+	log log.Logger
+	// allowSampleRate is the percentage of the allowed access checks that are logged.
+	allowSampleRate float64
+	// random returns a number in [0, 100) for sampling.
+	random func() float64
+}
+
+// loadDecisionLogSettings enables the decision log when the rbac.decision_log setting is set, logging the
+// percentage of allowed access checks of the rbac.decision_log_allow_sample_rate setting.
+func (ac *RBACService) loadDecisionLogSettings() {
+	section := ac.Cfg.Raw.Section("rbac")
+	if !section.Key("decision_log").MustBool(false) {
+		ac.decisions = nil
+		return
+	}
+
+	rate := section.Key("decision_log_allow_sample_rate").MustFloat64(1)
+	if rate < 0 {
+		rate = 0
+	} else if rate > 100 {
+		rate = 100
+	}
+	ac.decisions = &decisionLog{
+		log:             log.New("rbac.decisions"),
+		allowSampleRate: rate,
+		random:          func() float64 { return rand.Float64() * 100 },
+	}
+}
+
+// sampled returns true if a decision should be logged, denials are always logged, including those
+// allowed because their actions aren't enforced.
+func (l *decisionLog) sampled(decision *Decision) bool {
+	if l == nil {
+		return false
+	}
+	if !decision.Allowed || decision.Enforcement != "" {
+		return true
+	}
+	return l.allowSampleRate > 0 && l.random() < l.allowSampleRate
+}
+
+// logDecision logs the decision of an access check if it's sampled, with the policies of the
+// permissions it was taken on and the ID of the request it was taken for, see RequestContext.
+func (ac *RBACService) logDecision(ctx context.Context, req DecisionRequest, decision *Decision) {
+	if !ac.decisions.sampled(decision) {
+		return
+	}
+
+	var actions, scopes []string
+	for _, p := range evaluatorPermissions(req.Evaluator) {
+		actions = append(actions, p.Action)
+		scopes = append(scopes, p.Scope)
+	}
+	var policies []string
+	for _, id := range decisivePolicies(req, decision) {
+		policies = append(policies, strconv.FormatInt(id, 10))
+	}
+
+	logCtx := []interface{}{
+		"requestId", requestIDFromContext(ctx), "orgId", req.User.OrgId, "userId", req.User.UserId, "login", req.User.Login,
+		"action", strings.Join(actions, ","), "scope", strings.Join(scopes, ","), "policyIds", strings.Join(policies, ","),
+		"allowed", decision.Allowed,
+	}
+	for k, v := range decision.Annotations {
+		logCtx = append(logCtx, k, v)
+	}
+	ac.decisions.log.Info("Access decision", logCtx...)
+}
+
+// decisivePolicies returns the IDs of the policies of the permissions a decision was taken on: for each
+// action and scope of the access check, the applicable permissions of the highest precedence that
+// agree with it. Decisions not taken on the user's permissions, e.g. cached denials, have none.
+func decisivePolicies(req DecisionRequest, decision *Decision) []int64 {
+	env := req.Environment
+	seen := map[int64]bool{}
+	var ids []int64
+	for _, check := range evaluatorPermissions(req.Evaluator) {
+		r := accessRequest{
+			Action: check.Action, Scope: check.Scope, Attributes: env.Attributes, Time: env.Time,
+			RemoteAddr: env.RemoteAddr, AuthTime: env.AuthTime, combining: env.combining,
+		}
+		allowed := evaluatePermissions(decision.permissions, r)
+
+		var highest *int
+		var decisive []Permission
+		for _, p := range decision.permissions {
+			if !permissionApplies(p, r) {
+				continue
+			}
+			switch comparePrecedence(p.Precedence, highest) {
+			case -1:
+				continue
+			case 1:
+				highest, decisive = p.Precedence, nil
+			}
+			decisive = append(decisive, p)
+		}
+		for _, p := range decisive {
+			if p.IsDeny() != allowed && p.PolicyID != 0 && !seen[p.PolicyID] {
+				seen[p.PolicyID] = true
+				ids = append(ids, p.PolicyID)
+			}
+		}
+	}
+
+	sort.Slice(ids, func(i, j int) bool { return ids[i] < ids[j] })
+	return ids
+}
+
+type requestIDKey struct{}
+
+// withRequestID returns a copy of the context carrying the ID of the request, taken from its
+// X-Request-Id header when set by a proxy, so that the logged decisions of a request can be told apart.
+func withRequestID(ctx context.Context, c *models.ReqContext) context.Context {
+	id := c.Req.Header.Get("X-Request-Id")
+	if id == "" {
+		id = util.GenerateShortUID()
+	}
+	return context.WithValue(ctx, requestIDKey{}, id)
+}
+
+func requestIDFromContext(ctx context.Context) string {
+	id, _ := ctx.Value(requestIDKey{}).(string)
+	return id
+}
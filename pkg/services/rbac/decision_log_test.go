@@ -0,0 +1,86 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"testing"
+
+	"github.com/inconshreveable/log15"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	macaron "gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestDecisionLog(t *testing.T) {
+	ac := setupTestEnv(t)
+	policy := createPolicy(t, ac, 1, "dashboard reader", CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 500, PolicyID: policy.ID}))
+	user := &models.SignedInUser{OrgId: 1, UserId: 500, Login: "auditor"}
+
+	_, err := ac.Cfg.Raw.Section("rbac").NewKey("decision_log", "true")
+	require.NoError(t, err)
+	ac.loadDecisionLogSettings()
+	require.NotNil(t, ac.decisions)
+	assert.Equal(t, float64(1), ac.decisions.allowSampleRate)
+
+	var records []map[string]interface{}
+	logger := log.New("rbac.decisions.test")
+	logger.SetHandler(log15.FuncHandler(func(r *log15.Record) error {
+		fields := map[string]interface{}{}
+		for i := 0; i+1 < len(r.Ctx); i += 2 {
+			fields[fmt.Sprint(r.Ctx[i])] = r.Ctx[i+1]
+		}
+		records = append(records, fields)
+		return nil
+	}))
+	ac.decisions.log = logger
+
+	req, err := http.NewRequest("GET", "/api/dashboards/uid/abc", nil)
+	require.NoError(t, err)
+	req.Header.Set("X-Request-Id", "req-42")
+	c := &models.ReqContext{Context: &macaron.Context{Req: macaron.Request{Request: req}}, SignedInUser: user}
+	ctx := ac.RequestContext(c)
+
+	t.Run("Denials should always be logged", func(t *testing.T) {
+		records = nil
+		ac.decisions.random = func() float64 { return 99 }
+
+		ok, err := ac.HasPermission(ctx, user, ActionDashboardsRead, "dashboards:uid:abc")
+		require.NoError(t, err)
+		require.True(t, ok)
+		ok, err = ac.HasPermission(ctx, user, ActionDashboardsWrite, "dashboards:uid:abc")
+		require.NoError(t, err)
+		require.False(t, ok)
+
+		require.Len(t, records, 1)
+		assert.Equal(t, "req-42", records[0]["requestId"])
+		assert.Equal(t, int64(500), records[0]["userId"])
+		assert.Equal(t, ActionDashboardsWrite, records[0]["action"])
+		assert.Equal(t, "dashboards:uid:abc", records[0]["scope"])
+		assert.Equal(t, false, records[0]["allowed"])
+	})
+
+	t.Run("Sampled allowed decisions should name the policies they were taken on", func(t *testing.T) {
+		records = nil
+		ac.decisions.random = func() float64 { return 0.5 }
+
+		ok, err := ac.HasPermission(context.Background(), user, ActionDashboardsRead, "dashboards:uid:xyz")
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		require.Len(t, records, 1)
+		assert.Equal(t, true, records[0]["allowed"])
+		assert.Equal(t, fmt.Sprint(policy.ID), records[0]["policyIds"])
+		assert.Equal(t, "", records[0]["requestId"])
+	})
+
+	t.Run("The decision log should be disabled by default", func(t *testing.T) {
+		ac.Cfg.Raw.Section("rbac").DeleteKey("decision_log")
+		ac.loadDecisionLogSettings()
+		assert.Nil(t, ac.decisions)
+	})
+}
@@ -0,0 +1,234 @@
+package rbac
+
+import (
+	"context"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// DecisionRequest is an access check passed through the decision middlewares.
+type DecisionRequest struct {
+	User        *models.SignedInUser
+	Evaluator   Evaluator
+	Environment Environment
+}
+
+// Decision is the outcome of an access check.
+type Decision struct {
+	Allowed bool
+	// LegacyFallback is set when access is denied because none of the user's permissions matches
+	// an access check on resource types in compat mode, legacy access control decides instead.
+	LegacyFallback bool
+	// Shadow is set when the access check is on resource types in shadow mode, legacy access control
+	// decides and Allowed is only compared with its decision, see CompareDecision.
+	Shadow bool
+	// Enforcement is set to the enforcement mode of the actions of a denied access check that was
+	// allowed because they're not enforced, see SetEnforcementMode.
+	Enforcement string
+	// Annotations are added by decision middlewares and logged together with the decision.
+	Annotations map[string]string
+
+	// permissions are the permissions the decision was taken on, for the decision log.
+	permissions []Permission
+}
+
+// Annotate adds a key and value to the decision log entry.
+func (d *Decision) Annotate(key, value string) {
+	if d.Annotations == nil {
+		d.Annotations = map[string]string{}
+	}
+	d.Annotations[key] = value
+}
+
+// DecisionFunc decides an access check.
+type DecisionFunc func(ctx context.Context, req DecisionRequest) (*Decision, error)
+
+// DecisionMiddleware wraps the decision of an access check. A middleware can deny the access
+// without calling next, e.g. when a rate limit is exceeded, or annotate the decision of next.
+// Middlewares are meant to restrict access and shouldn't allow what next denies.
+type DecisionMiddleware func(next DecisionFunc) DecisionFunc
+
+type decisionMiddlewares struct {
+	mu          sync.RWMutex
+	names       []string
+	middlewares map[string]DecisionMiddleware
+	// order is the configured order of the middlewares, all of them run in registration order when empty.
+	order []string
+}
+
+// RegisterDecisionMiddleware adds a middleware to the chain every access check goes through.
+// The chain runs the middlewares in the order of the rbac.decision_middlewares setting, or in
+// registration order when the setting is empty. Registering a name again replaces the middleware.
+func (ac *RBACService) RegisterDecisionMiddleware(name string, middleware DecisionMiddleware) {
+	ac.decisionMiddlewares.mu.Lock()
+	defer ac.decisionMiddlewares.mu.Unlock()
+
+	if ac.decisionMiddlewares.middlewares == nil {
+		ac.decisionMiddlewares.middlewares = map[string]DecisionMiddleware{}
+	}
+	if _, exists := ac.decisionMiddlewares.middlewares[name]; !exists {
+		ac.decisionMiddlewares.names = append(ac.decisionMiddlewares.names, name)
+	}
+	ac.decisionMiddlewares.middlewares[name] = middleware
+}
+
+// loadDecisionMiddlewaresOrder reads the order of the decision middlewares from the settings.
+func (ac *RBACService) loadDecisionMiddlewaresOrder() {
+	var order []string
+	for _, name := range strings.Split(ac.Cfg.Raw.Section("rbac").Key("decision_middlewares").String(), ",") {
+		if name = strings.TrimSpace(name); name != "" {
+			order = append(order, name)
+		}
+	}
+
+	ac.decisionMiddlewares.mu.Lock()
+	defer ac.decisionMiddlewares.mu.Unlock()
+	ac.decisionMiddlewares.order = order
+}
+
+// decisionChain wraps the decision with the middlewares, the first one being the outermost.
+// Configured middlewares that aren't registered are skipped.
+func (ac *RBACService) decisionChain(decide DecisionFunc) DecisionFunc {
+	ac.decisionMiddlewares.mu.RLock()
+	defer ac.decisionMiddlewares.mu.RUnlock()
+
+	names := ac.decisionMiddlewares.order
+	if len(names) == 0 {
+		names = ac.decisionMiddlewares.names
+	}
+	for i := len(names) - 1; i >= 0; i-- {
+		if middleware, ok := ac.decisionMiddlewares.middlewares[names[i]]; ok {
+			decide = middleware(decide)
+		}
+	}
+
+	return decide
+}
+
+// decide resolves the permissions of the user and evaluates the request against them. The permissions
+// delegated to a background worker acting on behalf of the user are used as they are instead.
+func (ac *RBACService) decide(ctx context.Context, req DecisionRequest) (*Decision, error) {
+	permissions, delegated := delegatedPermissionsFromContext(ctx)
+	if !delegated {
+		if req.User.ApiKeyId != 0 {
+			decision, err := ac.decideAPIKey(ctx, req)
+			if decision != nil || err != nil {
+				return decision, err
+			}
+		}
+
+		var err error
+		permissions, err = ac.resolveUserPermissions(ctx, req.User)
+		if err != nil {
+			return nil, err
+		}
+	}
+
+	decision := &Decision{
+		Allowed:     req.Evaluator.Evaluate(permissions, req.Environment.withPermissions(permissions)),
+		permissions: permissions,
+	}
+	if delegated {
+		decision.Annotate("delegated", "true")
+	}
+	if !decision.Allowed && ac.fallsBackToLegacy(req.Evaluator, permissions) {
+		decision.LegacyFallback = true
+		decision.Annotate("legacyFallback", "true")
+	}
+	if ac.isShadowed(req.Evaluator) {
+		decision.Shadow = true
+		decision.Annotate("shadow", "true")
+	}
+
+	return decision, nil
+}
+
+// grantingPermissions are the resolved permission sets an access check must be granted by, all of them.
+// They're the permissions delegated to a background worker, the policies attached to an API key and
+// the permissions of every user who attached them, as in decideAPIKey, or else the user's permissions.
+type grantingPermissions struct {
+	sets [][]Permission
+	// user is set when the sets are the user's permissions only, which legacy access control may
+	// decide instead of, see fallsBackToLegacy.
+	user bool
+}
+
+// evaluate returns true if every set of permissions satisfies the evaluator, none when there are no sets.
+func (g grantingPermissions) evaluate(evaluator Evaluator, env Environment) bool {
+	if len(g.sets) == 0 {
+		return false
+	}
+	for _, permissions := range g.sets {
+		if !evaluator.Evaluate(permissions, env.withPermissions(permissions)) {
+			return false
+		}
+	}
+	return true
+}
+
+// resolveGrantingPermissions resolves the permission sets the access checks of a user are decided on,
+// for callers deciding many access checks on permissions resolved once, e.g. Filter and EvaluateAll.
+func (ac *RBACService) resolveGrantingPermissions(ctx context.Context, user *models.SignedInUser) (grantingPermissions, error) {
+	if permissions, ok := delegatedPermissionsFromContext(ctx); ok {
+		return grantingPermissions{sets: [][]Permission{permissions}}, nil
+	}
+
+	if user.ApiKeyId != 0 {
+		keyPermissions, creators, err := ac.apiKeyPermissions(ctx, user)
+		if err != nil {
+			return grantingPermissions{}, err
+		}
+		if len(creators) > 0 {
+			granting := grantingPermissions{sets: [][]Permission{keyPermissions}}
+			for _, creatorID := range creators {
+				creator, err := ac.apiKeyCreator(user.OrgId, creatorID)
+				if err != nil {
+					return grantingPermissions{}, err
+				}
+				if creator == nil {
+					// The policies of deleted users grant nothing.
+					return grantingPermissions{}, nil
+				}
+				creatorPermissions, err := ac.resolveUserPermissions(ctx, creator)
+				if err != nil {
+					return grantingPermissions{}, err
+				}
+				granting.sets = append(granting.sets, creatorPermissions)
+			}
+			return granting, nil
+		}
+	}
+
+	permissions, err := ac.resolveUserPermissions(ctx, user)
+	if err != nil {
+		return grantingPermissions{}, err
+	}
+	return grantingPermissions{sets: [][]Permission{permissions}, user: true}, nil
+}
+
+// decideGranting returns the decision of access checks on permissions resolved once, through the
+// decision middlewares and with the enforcement modes applied, as Decide does.
+func (ac *RBACService) decideGranting(granting grantingPermissions) DecisionFunc {
+	decide := ac.decisionChain(func(ctx context.Context, req DecisionRequest) (*Decision, error) {
+		decision := &Decision{Allowed: granting.evaluate(req.Evaluator, req.Environment)}
+		if granting.user {
+			decision.permissions = granting.sets[0]
+			if !decision.Allowed && ac.fallsBackToLegacy(req.Evaluator, decision.permissions) {
+				decision.LegacyFallback = true
+			}
+		}
+		decision.Shadow = ac.isShadowed(req.Evaluator)
+		return decision, nil
+	})
+
+	return func(ctx context.Context, req DecisionRequest) (*Decision, error) {
+		decision, err := decide(ctx, req)
+		if err != nil {
+			return nil, err
+		}
+		ac.applyEnforcementMode(ctx, req, decision)
+		return decision, nil
+	}
+}
@@ -0,0 +1,78 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestDecisionMiddlewares(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "readers")
+	addTeamMember(t, 1, team.Id, 131)
+	policy := createPolicy(t, ac, 1, "reader", CreatePermissionCommand{Action: ActionUsersRead, Scope: "users:*"})
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	var calls []string
+	var decisions []*Decision
+	ac.RegisterDecisionMiddleware("log", func(next DecisionFunc) DecisionFunc {
+		return func(ctx context.Context, req DecisionRequest) (*Decision, error) {
+			calls = append(calls, "log")
+			decision, err := next(ctx, req)
+			if err == nil {
+				decisions = append(decisions, decision)
+			}
+			return decision, err
+		}
+	})
+	limited := false
+	ac.RegisterDecisionMiddleware("ratelimit", func(next DecisionFunc) DecisionFunc {
+		return func(ctx context.Context, req DecisionRequest) (*Decision, error) {
+			calls = append(calls, "ratelimit")
+			if limited {
+				decision := &Decision{Allowed: false}
+				decision.Annotate("ratelimit", "exceeded")
+				return decision, nil
+			}
+			return next(ctx, req)
+		}
+	})
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 131}
+	check := func() bool {
+		ok, err := ac.Evaluate(context.Background(), user, Perm(ActionUsersRead, "users:id:1"))
+		require.NoError(t, err)
+		return ok
+	}
+
+	assert.True(t, check())
+	assert.Equal(t, []string{"log", "ratelimit"}, calls)
+
+	limited = true
+	assert.False(t, check())
+	require.Len(t, decisions, 2)
+	assert.Equal(t, "exceeded", decisions[1].Annotations["ratelimit"])
+
+	t.Run("Internal checks should go through the chain", func(t *testing.T) {
+		calls = nil
+		ok, err := ac.evaluate(context.Background(), user, accessRequest{Action: ActionUsersRead, Scope: "users:id:1"})
+		require.NoError(t, err)
+		assert.False(t, ok)
+		assert.Equal(t, []string{"log", "ratelimit"}, calls)
+	})
+
+	t.Run("Configured order should be respected and unlisted middlewares skipped", func(t *testing.T) {
+		_, err := ac.Cfg.Raw.Section("rbac").NewKey("decision_middlewares", "ratelimit, unknown")
+		require.NoError(t, err)
+		ac.loadDecisionMiddlewaresOrder()
+
+		calls = nil
+		assert.False(t, check())
+		assert.Equal(t, []string{"ratelimit"}, calls)
+	})
+}
@@ -0,0 +1,112 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// GetDefaultPolicies returns the default policies of an organization, bound to every user added to it.
+func (ac *RBACService) GetDefaultPolicies(ctx context.Context, orgID int64) ([]*PolicyDTO, error) {
+	var policies []*PolicyDTO
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := `SELECT policy.* FROM policy
+			INNER JOIN default_policy ON policy.id = default_policy.policy_id
+			WHERE default_policy.org_id = ?
+			ORDER BY policy.name ASC`
+		return sess.SQL(q, orgID).Find(&policies)
+	})
+
+	return policies, err
+}
+
+// AddDefaultPolicy makes a policy a default policy of its organization. It's bound to the users
+// added to the organization from then on, existing members aren't affected.
+func (ac *RBACService) AddDefaultPolicy(ctx context.Context, cmd AddDefaultPolicyCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionDefaultPolicy); err != nil {
+		return err
+	}
+
+	return ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID}); err != nil {
+			return err
+		}
+
+		defaultPolicy := &DefaultPolicy{
+			OrgID:    cmd.OrgID,
+			PolicyID: cmd.PolicyID,
+			Created:  time.Now(),
+		}
+		if _, err := sess.Table("default_policy").Insert(defaultPolicy); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrDefaultPolicyAlreadyAdded
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// RemoveDefaultPolicy removes a policy from the default policies of an organization. The users it
+// was bound to keep it.
+func (ac *RBACService) RemoveDefaultPolicy(ctx context.Context, cmd RemoveDefaultPolicyCommand) error {
+	return ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		result, err := sess.Exec("DELETE FROM default_policy WHERE org_id = ? AND policy_id = ?", cmd.OrgID, cmd.PolicyID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return ErrDefaultPolicyNotFound
+		}
+
+		return nil
+	})
+}
+
+// onOrgUserAdded binds the default policies of the organization to a user added to it. Policies
+// already bound to the user are left as they are.
+func (ac *RBACService) onOrgUserAdded(e *events.OrgUserAdded) error {
+	if !ac.IsEnabled() || ac.schemaVersion < schemaVersionDefaultPolicy {
+		return nil
+	}
+
+	var policyIDs []int64
+	changes := newBindingChanges(0, BindingReasonDefaultPolicy)
+	err := ac.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		q := `SELECT default_policy.policy_id FROM default_policy
+			LEFT JOIN user_policy ON user_policy.policy_id = default_policy.policy_id
+			AND user_policy.org_id = default_policy.org_id AND user_policy.user_id = ?
+			WHERE default_policy.org_id = ? AND user_policy.id IS NULL`
+		if err := sess.SQL(q, e.UserID, e.OrgID).Find(&policyIDs); err != nil {
+			return err
+		}
+
+		expiresAt, err := ac.userPolicyExpiry(sess, e.OrgID, nil)
+		if err != nil {
+			return err
+		}
+		for _, policyID := range policyIDs {
+			if err := ac.addUserPolicy(sess, &UserPolicy{OrgID: e.OrgID, PolicyID: policyID, UserID: e.UserID, ExpiresAt: expiresAt}); err != nil {
+				return err
+			}
+			changes.userBound(e.OrgID, policyID, e.UserID)
+		}
+		return nil
+	})
+	if err != nil {
+		// Adding the user succeeded regardless, and other listeners shouldn't be skipped.
+		ac.log.Error("Failed to bind default policies", "orgId", e.OrgID, "userId", e.UserID, "error", err)
+		return nil
+	}
+
+	if len(policyIDs) > 0 {
+		ac.log.Debug("Bound default policies", "orgId", e.OrgID, "userId", e.UserID, "policyIds", policyIDs)
+	}
+	ac.publishBindingChanges(changes)
+	return nil
+}
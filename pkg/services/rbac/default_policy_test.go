@@ -0,0 +1,61 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestDefaultPolicies(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	admin := &models.CreateUserCommand{Login: "org-admin", SkipOrgSetup: true}
+	require.NoError(t, sqlstore.CreateUser(context.Background(), admin))
+	org, err := ac.SQLStore.CreateOrgWithMember("onboarding", admin.Result.Id)
+	require.NoError(t, err)
+
+	baseline := createPolicy(t, ac, org.Id, "baseline", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddDefaultPolicy(context.Background(), AddDefaultPolicyCommand{OrgID: org.Id, PolicyID: baseline.ID}))
+	err = ac.AddDefaultPolicy(context.Background(), AddDefaultPolicyCommand{OrgID: org.Id, PolicyID: baseline.ID})
+	require.ErrorIs(t, err, ErrDefaultPolicyAlreadyAdded)
+	err = ac.AddDefaultPolicy(context.Background(), AddDefaultPolicyCommand{OrgID: org.Id + 1, PolicyID: baseline.ID})
+	require.ErrorIs(t, err, ErrPolicyNotFound)
+
+	defaults, err := ac.GetDefaultPolicies(context.Background(), org.Id)
+	require.NoError(t, err)
+	require.Len(t, defaults, 1)
+	assert.Equal(t, baseline.ID, defaults[0].ID)
+
+	addMember := func(login string) int64 {
+		user := &models.CreateUserCommand{Login: login, SkipOrgSetup: true}
+		require.NoError(t, sqlstore.CreateUser(context.Background(), user))
+		require.NoError(t, sqlstore.AddOrgUser(&models.AddOrgUserCommand{OrgId: org.Id, UserId: user.Result.Id, Role: models.ROLE_VIEWER}))
+		return user.Result.Id
+	}
+
+	t.Run("Users added to the organization should get the default policies", func(t *testing.T) {
+		userID := addMember("newcomer")
+
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: org.Id, UserID: userID})
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+		assert.Equal(t, baseline.ID, policies[0].ID)
+	})
+
+	t.Run("Removed default policies should not be bound to new users", func(t *testing.T) {
+		require.NoError(t, ac.RemoveDefaultPolicy(context.Background(), RemoveDefaultPolicyCommand{OrgID: org.Id, PolicyID: baseline.ID}))
+		err := ac.RemoveDefaultPolicy(context.Background(), RemoveDefaultPolicyCommand{OrgID: org.Id, PolicyID: baseline.ID})
+		require.ErrorIs(t, err, ErrDefaultPolicyNotFound)
+
+		userID := addMember("latecomer")
+
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: org.Id, UserID: userID})
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+}
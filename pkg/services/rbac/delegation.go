@@ -0,0 +1,49 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+type delegatedPermissionsKey struct{}
+
+// WithDelegatedPermissions returns a copy of the context carrying the permissions a background worker,
+// e.g. the image renderer, was issued to act on behalf of a user. Access checks are then decided on
+// them instead of the permissions the signed in user holds.
+func WithDelegatedPermissions(ctx context.Context, permissions []Permission) context.Context {
+	return context.WithValue(ctx, delegatedPermissionsKey{}, permissions)
+}
+
+func delegatedPermissionsFromContext(ctx context.Context) ([]Permission, bool) {
+	permissions, ok := ctx.Value(delegatedPermissionsKey{}).([]Permission)
+	return permissions, ok
+}
+
+// DelegatedPermissions returns the resolved permissions of a user, to be carried by the internal
+// token of a background worker acting on their behalf so that the worker can't do more than the
+// user. When the context already carries delegated permissions, e.g. for a render call issuing another
+// render, those are returned. It returns nil when RBAC is disabled.
+//
+// An API key with policies is only granted what both its policies and the users who attached them
+// grant, see decideAPIKey, which a single set of permissions can't express: ErrAPIKeyDelegationRefused
+// is returned for them.
+func (ac *RBACService) DelegatedPermissions(ctx context.Context, user *models.SignedInUser) ([]Permission, error) {
+	if !ac.IsEnabled() {
+		return nil, nil
+	}
+	if permissions, ok := delegatedPermissionsFromContext(ctx); ok {
+		return permissions, nil
+	}
+	if user.ApiKeyId != 0 {
+		_, creators, err := ac.apiKeyPermissions(ctx, user)
+		if err != nil {
+			return nil, err
+		}
+		if len(creators) > 0 {
+			return nil, ErrAPIKeyDelegationRefused
+		}
+	}
+
+	return ac.resolveUserPermissions(ctx, user)
+}
@@ -0,0 +1,64 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestDelegatedPermissions(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	const userID = 321
+	policy := createPolicy(t, ac, 1, "delegated dashboards", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:uid:abc"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: userID, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: userID, OrgRole: models.ROLE_VIEWER}
+	permissions, err := ac.DelegatedPermissions(context.Background(), user)
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	assert.Equal(t, "dashboards:uid:abc", permissions[0].Scope)
+
+	check := func(ctx context.Context, user *models.SignedInUser, evaluator Evaluator) bool {
+		t.Helper()
+		ok, err := ac.Evaluate(ctx, user, evaluator)
+		require.NoError(t, err)
+		return ok
+	}
+
+	t.Run("Access checks should be decided on the delegated permissions only", func(t *testing.T) {
+		ctx := WithDelegatedPermissions(context.Background(), permissions)
+		assert.True(t, check(ctx, user, Perm("dashboards:read", "dashboards:uid:abc")))
+		assert.False(t, check(ctx, user, Perm("dashboards:read", "dashboards:uid:def")))
+
+		// A worker issued no permissions can't fall back on the ones of the user it acts for.
+		assert.False(t, check(WithDelegatedPermissions(context.Background(), nil), user, Perm("dashboards:read", "dashboards:uid:abc")))
+	})
+
+	t.Run("Delegated permissions should be passed on as they are", func(t *testing.T) {
+		ctx := WithDelegatedPermissions(context.Background(), nil)
+		delegated, err := ac.DelegatedPermissions(ctx, user)
+		require.NoError(t, err)
+		assert.Empty(t, delegated)
+	})
+
+	t.Run("API keys with policies should be refused delegation", func(t *testing.T) {
+		key := &models.AddApiKeyCommand{OrgId: 1, Name: "render", Role: models.ROLE_VIEWER, Key: "secret"}
+		require.NoError(t, sqlstore.AddApiKey(key))
+		keyUser := &models.SignedInUser{OrgId: 1, ApiKeyId: key.Result.Id, OrgRole: models.ROLE_VIEWER}
+
+		_, err := ac.DelegatedPermissions(context.Background(), keyUser)
+		require.NoError(t, err, "keys without policies get the permissions of their role")
+
+		require.NoError(t, ac.AddAPIKeyPolicy(context.Background(), AddAPIKeyPolicyCommand{
+			OrgID: 1, APIKeyID: key.Result.Id, PolicyID: policy.ID, CreatedBy: userID,
+		}))
+		_, err = ac.DelegatedPermissions(context.Background(), keyUser)
+		require.ErrorIs(t, err, ErrAPIKeyDelegationRefused)
+	})
+}
@@ -0,0 +1,183 @@
+package rbac
+
+import (
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// ActionDenialsRead is the unscoped action for searching the recent access denials of the
+// signed in user's organization, so that support can find out why a request was denied.
+const ActionDenialsRead = "denials:read"
+
+func init() {
+	RegisterActions(ActionDefinition{Action: ActionDenialsRead, Description: "Search the recent access denials of the current organization"})
+}
+
+// defaultDenialLogSize is the number of denials kept when rbac.denial_log_size isn't set.
+const defaultDenialLogSize = 1000
+
+// DenialEvent is a denied access check kept in the denial log.
+type DenialEvent struct {
+	Time      time.Time `json:"time"`
+	OrgID     int64     `json:"orgId"`
+	UserID    int64     `json:"userId"`
+	Login     string    `json:"login"`
+	Evaluator string    `json:"evaluator"`
+	// Permissions are the action and scope pairs the evaluator requires or rules out.
+	Permissions []DeniedPermission `json:"permissions"`
+	// Annotations are the annotations the decision middlewares added to the decision.
+	Annotations map[string]string `json:"annotations,omitempty"`
+}
+
+// DeniedPermission is an action and scope pair of a denied access check.
+type DeniedPermission struct {
+	Action string `json:"action"`
+	Scope  string `json:"scope,omitempty"`
+}
+
+// SearchDenialsQuery is the query for searching the denial log of an organization. Zero fields
+// don't filter, Action and Scope match any permission of the denied access check.
+type SearchDenialsQuery struct {
+	OrgID  int64
+	UserID int64
+	Action string
+	// Scope matches scopes starting with it, e.g. dashboards:uid: matches every dashboard.
+	Scope string
+	From  time.Time
+	To    time.Time
+	Limit int
+}
+
+// denialLog keeps the most recent denials in memory, overwriting the oldest one when full.
+// Denials don't survive restarts and each Grafana instance only knows its own.
+type denialLog struct {
+	mu     sync.RWMutex
+	events []DenialEvent
+	next   int
+	full   bool
+}
+
+func newDenialLog(size int) *denialLog {
+	if size <= 0 {
+		return nil
+	}
+	return &denialLog{events: make([]DenialEvent, size)}
+}
+
+// loadDenialLogSettings creates the denial log with the size of the rbac.denial_log_size setting,
+// the log is disabled when the size is 0.
+func (ac *RBACService) loadDenialLogSettings() {
+	ac.denials = newDenialLog(ac.Cfg.Raw.Section("rbac").Key("denial_log_size").MustInt(defaultDenialLogSize))
+}
+
+func (l *denialLog) add(event DenialEvent) {
+	if l == nil {
+		return
+	}
+
+	l.mu.Lock()
+	defer l.mu.Unlock()
+	l.events[l.next] = event
+	l.next = (l.next + 1) % len(l.events)
+	if l.next == 0 {
+		l.full = true
+	}
+}
+
+// search returns the matching denials, most recent first.
+func (l *denialLog) search(query SearchDenialsQuery) []DenialEvent {
+	result := []DenialEvent{}
+	if l == nil {
+		return result
+	}
+
+	l.mu.RLock()
+	defer l.mu.RUnlock()
+
+	count := l.next
+	if l.full {
+		count = len(l.events)
+	}
+	for i := 1; i <= count; i++ {
+		event := l.events[(l.next-i+len(l.events))%len(l.events)]
+		if !query.matches(event) {
+			continue
+		}
+		result = append(result, event)
+		if query.Limit > 0 && len(result) == query.Limit {
+			break
+		}
+	}
+
+	return result
+}
+
+func (query SearchDenialsQuery) matches(event DenialEvent) bool {
+	if event.OrgID != query.OrgID || (query.UserID != 0 && event.UserID != query.UserID) {
+		return false
+	}
+	if (!query.From.IsZero() && event.Time.Before(query.From)) || (!query.To.IsZero() && event.Time.After(query.To)) {
+		return false
+	}
+	if query.Action == "" && query.Scope == "" {
+		return true
+	}
+	for _, p := range event.Permissions {
+		if (query.Action == "" || p.Action == query.Action) && strings.HasPrefix(p.Scope, query.Scope) {
+			return true
+		}
+	}
+	return false
+}
+
+// recordDenial adds a denied access check to the denial log.
+func (ac *RBACService) recordDenial(user *models.SignedInUser, req DecisionRequest, decision *Decision) {
+	ac.denials.add(DenialEvent{
+		Time:        req.Environment.Time,
+		OrgID:       user.OrgId,
+		UserID:      user.UserId,
+		Login:       user.Login,
+		Evaluator:   req.Evaluator.String(),
+		Permissions: evaluatorPermissions(req.Evaluator),
+		Annotations: decision.Annotations,
+	})
+}
+
+// evaluatorPermissions returns the action and scope pairs of the evaluator and the evaluators it combines.
+func evaluatorPermissions(evaluator Evaluator) []DeniedPermission {
+	switch e := evaluator.(type) {
+	case permEvaluator:
+		return []DeniedPermission{{Action: e.action, Scope: e.scope}}
+	case requestsEvaluator:
+		permissions := make([]DeniedPermission, 0, len(e))
+		for _, req := range e {
+			permissions = append(permissions, DeniedPermission{Action: req.Action, Scope: req.Scope})
+		}
+		return permissions
+	case allEvaluator:
+		return combinedEvaluatorPermissions(e)
+	case anyEvaluator:
+		return combinedEvaluatorPermissions(e)
+	case notEvaluator:
+		return evaluatorPermissions(e.evaluator)
+	default:
+		return nil
+	}
+}
+
+func combinedEvaluatorPermissions(evaluators []Evaluator) []DeniedPermission {
+	var permissions []DeniedPermission
+	for _, evaluator := range evaluators {
+		permissions = append(permissions, evaluatorPermissions(evaluator)...)
+	}
+	return permissions
+}
+
+// SearchDenials returns the recent denials of an organization matching the query, most recent first.
+// Only the denials of this Grafana instance since it started are kept, up to rbac.denial_log_size.
+func (ac *RBACService) SearchDenials(query SearchDenialsQuery) []DenialEvent {
+	return ac.denials.search(query)
+}
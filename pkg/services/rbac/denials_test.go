@@ -0,0 +1,73 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestSearchDenials(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "support")
+	addTeamMember(t, 1, team.Id, 261)
+	policy := createPolicy(t, ac, 1, "viewer", CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"})
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 261, Login: "support"}
+	other := &models.SignedInUser{OrgId: 1, UserId: 262, Login: "other"}
+
+	start := time.Now()
+	for _, check := range []struct {
+		user      *models.SignedInUser
+		evaluator Evaluator
+	}{
+		{user, Perm(ActionDashboardsRead, "dashboards:uid:abc")},
+		{user, Perm(ActionDashboardsWrite, "dashboards:uid:abc")},
+		{user, All(Perm(ActionDashboardsRead, "dashboards:uid:abc"), Perm(ActionDatasourcesRead, "datasources:uid:prom"))},
+		{other, Perm(ActionDashboardsRead, "dashboards:uid:abc")},
+	} {
+		_, err := ac.Evaluate(context.Background(), check.user, check.evaluator)
+		require.NoError(t, err)
+	}
+
+	t.Run("Denials of a user should be returned most recent first", func(t *testing.T) {
+		denials := ac.SearchDenials(SearchDenialsQuery{OrgID: 1, UserID: 261})
+		require.Len(t, denials, 2)
+		assert.Equal(t, "support", denials[0].Login)
+		assert.Equal(t, []DeniedPermission{
+			{Action: ActionDashboardsRead, Scope: "dashboards:uid:abc"},
+			{Action: ActionDatasourcesRead, Scope: "datasources:uid:prom"},
+		}, denials[0].Permissions)
+		assert.Equal(t, []DeniedPermission{{Action: ActionDashboardsWrite, Scope: "dashboards:uid:abc"}}, denials[1].Permissions)
+	})
+
+	t.Run("Denials should be filtered by action, scope and time", func(t *testing.T) {
+		assert.Len(t, ac.SearchDenials(SearchDenialsQuery{OrgID: 1, Action: ActionDashboardsRead}), 2)
+		assert.Len(t, ac.SearchDenials(SearchDenialsQuery{OrgID: 1, Scope: "datasources:"}), 1)
+		assert.Len(t, ac.SearchDenials(SearchDenialsQuery{OrgID: 1, Scope: "dashboards:uid:abc"}), 3)
+		assert.Len(t, ac.SearchDenials(SearchDenialsQuery{OrgID: 1, From: start, To: time.Now()}), 3)
+		assert.Empty(t, ac.SearchDenials(SearchDenialsQuery{OrgID: 1, To: start}))
+		assert.Empty(t, ac.SearchDenials(SearchDenialsQuery{OrgID: 2}))
+		assert.Len(t, ac.SearchDenials(SearchDenialsQuery{OrgID: 1, Limit: 1}), 1)
+	})
+}
+
+func TestDenialLogOverwritesOldestDenials(t *testing.T) {
+	log := newDenialLog(2)
+	for userID := int64(1); userID <= 3; userID++ {
+		log.add(DenialEvent{OrgID: 1, UserID: userID})
+	}
+
+	denials := log.search(SearchDenialsQuery{OrgID: 1})
+	require.Len(t, denials, 2)
+	assert.Equal(t, int64(3), denials[0].UserID)
+	assert.Equal(t, int64(2), denials[1].UserID)
+
+	assert.Empty(t, newDenialLog(0).search(SearchDenialsQuery{OrgID: 1}), "a disabled denial log should be empty")
+}
@@ -0,0 +1,130 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+
+	"github.com/grafana/grafana/pkg/infra/localcache"
+)
+
+// denyCache caches the denied access checks for the time set by the rbac.deny_cache_ttl setting, so
+// that clients repeating a denied request, e.g. a dashboard poller, don't resolve the user's
+// permissions every time. Allowed checks aren't cached by it. Unlike the permission cache, it's also
+// invalidated when the dashboards of folders and tags change and when enforcement modes change, since
+// those can allow a denied check without any change to the user's permissions. The other instances of
+// a HA setup see changes once their entries expire, so the lifetime should be kept short.
+type denyCache struct {
+	cache *localcache.CacheService
+	mu    sync.Mutex
+	// generation is increased by every invalidation, so that denials decided before an invalidation
+	// aren't cached after it.
+	generation uint64
+}
+
+// loadDenyCacheSettings reads the lifetime of the cached denials, a zero lifetime disables the cache.
+func (ac *RBACService) loadDenyCacheSettings() {
+	ttl := ac.Cfg.Raw.Section("rbac").Key("deny_cache_ttl").MustDuration(0)
+	if ttl <= 0 {
+		ac.denyCache = nil
+		return
+	}
+
+	ac.denyCache = &denyCache{cache: localcache.New(ttl, 2*ttl)}
+}
+
+// denyCacheKey identifies a denied access check like decisionKey does, along with the request
+// attributes its conditions may depend on.
+func denyCacheKey(req DecisionRequest) string {
+	names := make([]string, 0, len(req.Environment.Attributes))
+	for name := range req.Environment.Attributes {
+		names = append(names, name)
+	}
+	sort.Strings(names)
+
+	var attrs strings.Builder
+	for _, name := range names {
+		fmt.Fprintf(&attrs, "%s=%s;", name, strings.Join(req.Environment.Attributes[name], ","))
+	}
+
+	return decisionKey(req.User, req.Evaluator, req.Environment) + ":" + attrs.String()
+}
+
+// decideWithDenyCache returns the cached denial of an access check, or decides it and caches it when
+// it's denied by the user's permissions. Decisions made with delegated permissions and those left to
+// legacy access control aren't cached.
+func (ac *RBACService) decideWithDenyCache(ctx context.Context, req DecisionRequest) (*Decision, error) {
+	c := ac.denyCache
+	if _, delegated := delegatedPermissionsFromContext(ctx); c == nil || delegated {
+		return ac.decide(ctx, req)
+	}
+
+	key := denyCacheKey(req)
+	if cached, found := c.cache.Get(key); found {
+		decision := copyDecision(cached.(*Decision))
+		decision.Annotate("denyCache", "hit")
+		return decision, nil
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	decision, err := ac.decide(ctx, req)
+	if err != nil || decision.Allowed || decision.LegacyFallback || decision.Shadow {
+		return decision, err
+	}
+	if req.Environment.external.wasDeferred() {
+		// The decisions of the external policy decision point are cached by it, for their own lifetime.
+		return decision, nil
+	}
+
+	c.mu.Lock()
+	if generation == c.generation {
+		cached := copyDecision(decision)
+		cached.permissions = nil
+		c.cache.Set(key, cached, 0)
+	}
+	c.mu.Unlock()
+
+	return decision, nil
+}
+
+// copyDecision copies a decision, so that the annotations of the decision middlewares don't change
+// the cached one.
+func copyDecision(d *Decision) *Decision {
+	decision := *d
+	decision.Annotations = nil
+	for k, v := range d.Annotations {
+		decision.Annotate(k, v)
+	}
+	return &decision
+}
+
+// invalidate deletes the cached denials of a user in an organization. A zero user invalidates every
+// user of the organization, a zero organization every organization.
+func (c *denyCache) invalidate(orgID, userID int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+
+	if orgID == 0 {
+		c.cache.Flush()
+		return
+	}
+	prefix := fmt.Sprintf("%d:", orgID)
+	if userID != 0 {
+		prefix = fmt.Sprintf("%d:%d:", orgID, userID)
+	}
+	for key := range c.cache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			c.cache.Delete(key)
+		}
+	}
+}
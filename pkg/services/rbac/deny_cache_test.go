@@ -0,0 +1,119 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestDenyCache(t *testing.T) {
+	ac := setupTestEnv(t)
+	_, err := ac.Cfg.Raw.Section("rbac").NewKey("deny_cache_ttl", "1m")
+	require.NoError(t, err)
+	ac.loadDenyCacheSettings()
+	require.NotNil(t, ac.denyCache)
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 493, OrgRole: models.ROLE_VIEWER}
+	decide := func(t *testing.T, action string) *Decision {
+		t.Helper()
+		decision, err := ac.Decide(context.Background(), user, Perm(action, "dashboards:uid:home"))
+		require.NoError(t, err)
+		return decision
+	}
+	bindDirectly := func(t *testing.T, policyID int64) {
+		t.Helper()
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Table("user_policy").Insert(&UserPolicy{OrgID: 1, UserID: 493, PolicyID: policyID, Created: time.Now()})
+			return err
+		})
+		require.NoError(t, err)
+	}
+	unbindDirectly := func(t *testing.T) {
+		t.Helper()
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Exec("DELETE FROM user_policy WHERE user_id = ?", 493)
+			return err
+		})
+		require.NoError(t, err)
+	}
+
+	policy := createPolicy(t, ac, 1, "deny cache", CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"})
+
+	t.Run("Denials should be cached until the user's permissions change", func(t *testing.T) {
+		assert.False(t, decide(t, ActionDashboardsRead).Allowed)
+
+		bindDirectly(t, policy.ID)
+		decision := decide(t, ActionDashboardsRead)
+		assert.False(t, decision.Allowed)
+		assert.Equal(t, "hit", decision.Annotations["denyCache"])
+
+		ac.publishPermissionsChanged(1, 493, "test")
+		assert.True(t, decide(t, ActionDashboardsRead).Allowed)
+	})
+
+	t.Run("Allowed checks should not be cached", func(t *testing.T) {
+		unbindDirectly(t)
+		assert.False(t, decide(t, ActionDashboardsRead).Allowed)
+		t.Cleanup(func() { ac.denyCache.invalidate(0, 0) })
+	})
+
+	t.Run("Denials should be invalidated when the dashboard hierarchy changes", func(t *testing.T) {
+		assert.False(t, decide(t, ActionDashboardsRead).Allowed)
+		bindDirectly(t, policy.ID)
+		t.Cleanup(func() { unbindDirectly(t) })
+
+		ac.InvalidateScopeHierarchy()
+		assert.True(t, decide(t, ActionDashboardsRead).Allowed)
+	})
+
+	t.Run("Denials should be invalidated when enforcement modes change", func(t *testing.T) {
+		assert.False(t, decide(t, ActionDashboardsWrite).Allowed)
+
+		require.NoError(t, ac.SetEnforcementMode(context.Background(), SetEnforcementModeCommand{Action: ActionDashboardsWrite, Mode: EnforcementModeDisabled}))
+		t.Cleanup(func() { require.NoError(t, ac.RemoveEnforcementMode(context.Background(), ActionDashboardsWrite)) })
+		assert.True(t, decide(t, ActionDashboardsWrite).Allowed)
+	})
+
+	t.Run("Denials of API keys should be invalidated when their policies change", func(t *testing.T) {
+		creator := &models.CreateUserCommand{Login: "deny-cache-key-creator", SkipOrgSetup: true}
+		require.NoError(t, sqlstore.CreateUser(context.Background(), creator))
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: creator.Result.Id, PolicyID: policy.ID}))
+		key := &models.AddApiKeyCommand{OrgId: 1, Name: "deny cache", Role: models.ROLE_VIEWER, Key: "deny-cache"}
+		require.NoError(t, sqlstore.AddApiKey(key))
+		keyUser := &models.SignedInUser{OrgId: 1, ApiKeyId: key.Result.Id, OrgRole: models.ROLE_VIEWER}
+		decideKey := func(t *testing.T) *Decision {
+			t.Helper()
+			decision, err := ac.Decide(context.Background(), keyUser, Perm(ActionDashboardsRead, "dashboards:uid:home"))
+			require.NoError(t, err)
+			return decision
+		}
+
+		folders := createPolicy(t, ac, 1, "deny cache key", CreatePermissionCommand{Action: ActionFoldersRead, Scope: "folders:*"})
+		require.NoError(t, ac.AddAPIKeyPolicy(context.Background(), AddAPIKeyPolicyCommand{
+			OrgID: 1, APIKeyID: key.Result.Id, PolicyID: folders.ID, CreatedBy: creator.Result.Id,
+		}))
+		assert.False(t, decideKey(t).Allowed)
+
+		require.NoError(t, ac.AddAPIKeyPolicy(context.Background(), AddAPIKeyPolicyCommand{
+			OrgID: 1, APIKeyID: key.Result.Id, PolicyID: policy.ID, CreatedBy: creator.Result.Id,
+		}))
+		assert.True(t, decideKey(t).Allowed)
+
+		require.NoError(t, ac.RemoveAPIKeyPolicy(context.Background(), RemoveAPIKeyPolicyCommand{OrgID: 1, APIKeyID: key.Result.Id, PolicyID: policy.ID}))
+		assert.False(t, decideKey(t).Allowed)
+	})
+
+	t.Run("Annotations of cached denials should not leak between decisions", func(t *testing.T) {
+		first := decide(t, ActionDashboardsDelete)
+		first.Annotate("leaked", "true")
+		second := decide(t, ActionDashboardsDelete)
+		assert.Equal(t, "hit", second.Annotations["denyCache"])
+		assert.Empty(t, second.Annotations["leaked"])
+	})
+}
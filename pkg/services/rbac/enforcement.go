@@ -0,0 +1,228 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// Enforcement modes of actions, so that RBAC can be rolled out one action at a time.
+const (
+	// EnforcementModeDisabled allows the access checks RBAC denies without recording them.
+	EnforcementModeDisabled = "disabled"
+	// EnforcementModeLogOnly allows the access checks RBAC denies, recording them as denials, so that
+	// they can be reviewed before enforcement, see SearchDenials.
+	EnforcementModeLogOnly = "log"
+	// EnforcementModeEnforce denies the access checks RBAC denies, it's the mode of every action by default.
+	// Setting it on an action enforces it when a broader pattern isn't, e.g. dashboards:write while
+	// dashboards:* is disabled.
+	EnforcementModeEnforce = "enforce"
+)
+
+// enforcementModesTTL is how long the enforcement modes are cached, so that the changes made by the
+// CLI or by another instance are seen within it.
+const enforcementModesTTL = 30 * time.Second
+
+// enforcementModes caches the enforcement modes of the actions, keyed by action or wildcard pattern.
+type enforcementModes struct {
+	mu     sync.Mutex
+	modes  map[string]string
+	loaded time.Time
+}
+
+func (m *enforcementModes) invalidate() {
+	if m == nil {
+		return
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	m.modes = nil
+}
+
+// GetEnforcementModes returns the actions and wildcard patterns that have an enforcement mode, sorted
+// by action.
+func (ac *RBACService) GetEnforcementModes(ctx context.Context) ([]*EnforcementMode, error) {
+	modes := make([]*EnforcementMode, 0)
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		applied, err := appliedMigrations(sess)
+		if err != nil {
+			return err
+		}
+		if currentSchemaVersion(applied) < schemaVersionEnforcementMode {
+			return nil
+		}
+
+		return sess.Table("enforcement_mode").Asc("action").Find(&modes)
+	})
+
+	return modes, err
+}
+
+// SetEnforcementMode sets the enforcement mode of an action, or of the actions matching a wildcard
+// pattern such as dashboards:*, in every organization. The mode of the most specific pattern matching
+// an action applies, and the strictest mode of its actions applies to an access check. Other instances
+// see the change within 30 seconds.
+func (ac *RBACService) SetEnforcementMode(ctx context.Context, cmd SetEnforcementModeCommand) error {
+	switch cmd.Mode {
+	case EnforcementModeDisabled, EnforcementModeLogOnly, EnforcementModeEnforce:
+	default:
+		return fmt.Errorf("%w, got %q", ErrInvalidEnforcementMode, cmd.Mode)
+	}
+	if err := validatePermission(cmd.Action, ""); err != nil {
+		return err
+	}
+	if err := validateRegisteredAction(cmd.Action); err != nil {
+		return err
+	}
+
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		applied, err := appliedMigrations(sess)
+		if err != nil {
+			return err
+		}
+		if version := currentSchemaVersion(applied); version < schemaVersionEnforcementMode {
+			return fmt.Errorf("%w: version %d is required but the database is at version %d", ErrSchemaOutdated,
+				schemaVersionEnforcementMode, version)
+		}
+
+		if _, err := sess.Exec("DELETE FROM enforcement_mode WHERE action = ?", cmd.Action); err != nil {
+			return err
+		}
+		_, err = sess.Table("enforcement_mode").Insert(&EnforcementMode{Action: cmd.Action, Mode: cmd.Mode, Updated: time.Now()})
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.enforcementModes.invalidate()
+	ac.denyCache.invalidate(0, 0)
+	ac.log.Info("Set enforcement mode", "action", cmd.Action, "mode", cmd.Mode)
+	return nil
+}
+
+// RemoveEnforcementMode removes the enforcement mode of an action or wildcard pattern, the actions
+// it matched then take the mode of the next most specific pattern, enforce when there's none.
+func (ac *RBACService) RemoveEnforcementMode(ctx context.Context, action string) error {
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		applied, err := appliedMigrations(sess)
+		if err != nil {
+			return err
+		}
+		if currentSchemaVersion(applied) < schemaVersionEnforcementMode {
+			return ErrEnforcementModeNotFound
+		}
+
+		res, err := sess.Exec("DELETE FROM enforcement_mode WHERE action = ?", action)
+		if err != nil {
+			return err
+		}
+		if affected, err := res.RowsAffected(); err != nil {
+			return err
+		} else if affected == 0 {
+			return ErrEnforcementModeNotFound
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.enforcementModes.invalidate()
+	ac.denyCache.invalidate(0, 0)
+	ac.log.Info("Removed enforcement mode", "action", action)
+	return nil
+}
+
+// loadEnforcementModes returns the cached enforcement modes, loading them when they've expired.
+func (ac *RBACService) loadEnforcementModes(ctx context.Context) (map[string]string, error) {
+	m := ac.enforcementModes
+	if m == nil || ac.schemaVersion < schemaVersionEnforcementMode {
+		return nil, nil
+	}
+
+	m.mu.Lock()
+	defer m.mu.Unlock()
+	if m.modes != nil && time.Since(m.loaded) < enforcementModesTTL {
+		return m.modes, nil
+	}
+
+	var rows []EnforcementMode
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		return sess.Table("enforcement_mode").Find(&rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	m.modes = make(map[string]string, len(rows))
+	for _, row := range rows {
+		m.modes[row.Action] = row.Mode
+	}
+	m.loaded = time.Now()
+
+	return m.modes, nil
+}
+
+// actionEnforcementMode returns the mode of the most specific action or pattern matching an action,
+// the one with the most segments.
+func actionEnforcementMode(modes map[string]string, action string) string {
+	if mode, ok := mostSpecificPattern(modes, action); ok {
+		return mode
+	}
+	return EnforcementModeEnforce
+}
+
+// evaluatorEnforcementMode returns the strictest enforcement mode of the actions of an evaluator.
+func (ac *RBACService) evaluatorEnforcementMode(ctx context.Context, evaluator Evaluator) (string, error) {
+	modes, err := ac.loadEnforcementModes(ctx)
+	if err != nil || len(modes) == 0 {
+		return EnforcementModeEnforce, err
+	}
+
+	required := evaluatorPermissions(evaluator)
+	if len(required) == 0 {
+		return EnforcementModeEnforce, nil
+	}
+	result := EnforcementModeDisabled
+	for _, r := range required {
+		switch actionEnforcementMode(modes, r.Action) {
+		case EnforcementModeEnforce:
+			return EnforcementModeEnforce, nil
+		case EnforcementModeLogOnly:
+			result = EnforcementModeLogOnly
+		}
+	}
+
+	return result, nil
+}
+
+// applyEnforcementMode allows a denied access check whose actions aren't enforced, telling the mode
+// in the decision. Denials deferring to legacy access control are left as they are.
+func (ac *RBACService) applyEnforcementMode(ctx context.Context, req DecisionRequest, decision *Decision) {
+	if decision.Allowed || decision.LegacyFallback || decision.Shadow {
+		return
+	}
+
+	mode, err := ac.evaluatorEnforcementMode(ctx, req.Evaluator)
+	if err != nil {
+		// Access checks are enforced while the modes can't be loaded.
+		ac.log.Warn("Failed to load enforcement modes", "error", err)
+		return
+	}
+	if mode == EnforcementModeEnforce {
+		return
+	}
+
+	decision.Allowed = true
+	decision.Enforcement = mode
+	decision.Annotate("enforcement", mode)
+	if mode == EnforcementModeLogOnly {
+		ac.log.Info("Access would be denied", "userId", req.User.UserId, "orgId", req.User.OrgId,
+			"evaluator", req.Evaluator.String())
+	}
+}
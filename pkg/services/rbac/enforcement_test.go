@@ -0,0 +1,87 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestEnforcementModes(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "dashboard reader", CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 492, PolicyID: policy.ID}))
+	user := &models.SignedInUser{OrgId: 1, UserId: 492}
+
+	setMode := func(t *testing.T, action, mode string) {
+		t.Helper()
+		require.NoError(t, ac.SetEnforcementMode(context.Background(), SetEnforcementModeCommand{Action: action, Mode: mode}))
+		t.Cleanup(func() { require.NoError(t, ac.RemoveEnforcementMode(context.Background(), action)) })
+	}
+	decide := func(t *testing.T, evaluator Evaluator) *Decision {
+		t.Helper()
+		decision, err := ac.Decide(context.Background(), user, evaluator)
+		require.NoError(t, err)
+		return decision
+	}
+
+	t.Run("Actions should be enforced by default", func(t *testing.T) {
+		decision := decide(t, Perm(ActionDashboardsWrite, "dashboards:uid:a"))
+		assert.False(t, decision.Allowed)
+		assert.Empty(t, decision.Enforcement)
+	})
+
+	t.Run("Denials of disabled actions should be allowed without being recorded", func(t *testing.T) {
+		setMode(t, "dashboards:*", EnforcementModeDisabled)
+
+		decision := decide(t, Perm(ActionDashboardsDelete, "dashboards:uid:a"))
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, EnforcementModeDisabled, decision.Enforcement)
+		assert.Empty(t, ac.SearchDenials(SearchDenialsQuery{OrgID: 1, UserID: 492, Action: ActionDashboardsDelete}))
+	})
+
+	t.Run("Denials of log-only actions should be allowed and recorded", func(t *testing.T) {
+		setMode(t, ActionDashboardsCreate, EnforcementModeLogOnly)
+
+		decision := decide(t, Perm(ActionDashboardsCreate, ""))
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, EnforcementModeLogOnly, decision.Enforcement)
+		denials := ac.SearchDenials(SearchDenialsQuery{OrgID: 1, UserID: 492, Action: ActionDashboardsCreate})
+		require.Len(t, denials, 1)
+		assert.Equal(t, EnforcementModeLogOnly, denials[0].Annotations["enforcement"])
+	})
+
+	t.Run("The most specific pattern and the strictest action should apply", func(t *testing.T) {
+		setMode(t, "dashboards:*", EnforcementModeDisabled)
+		setMode(t, ActionDashboardsWrite, EnforcementModeEnforce)
+		setMode(t, ActionTeamsWrite, EnforcementModeLogOnly)
+
+		assert.False(t, decide(t, Perm(ActionDashboardsWrite, "dashboards:uid:b")).Allowed)
+		decision := decide(t, All(Perm(ActionDashboardsDelete, "dashboards:uid:b"), Perm(ActionTeamsWrite, ScopeTeamID(1))))
+		assert.True(t, decision.Allowed)
+		assert.Equal(t, EnforcementModeLogOnly, decision.Enforcement)
+		assert.False(t, decide(t, All(Perm(ActionDashboardsDelete, "dashboards:uid:b"), Perm(ActionUsersWrite, ScopeUserID(1)))).Allowed)
+	})
+
+	t.Run("Modes should be stored", func(t *testing.T) {
+		setMode(t, "datasources:*", EnforcementModeLogOnly)
+
+		modes, err := ac.GetEnforcementModes(context.Background())
+		require.NoError(t, err)
+		require.Len(t, modes, 1)
+		assert.Equal(t, "datasources:*", modes[0].Action)
+		assert.Equal(t, EnforcementModeLogOnly, modes[0].Mode)
+	})
+
+	t.Run("Invalid modes and actions should be rejected", func(t *testing.T) {
+		err := ac.SetEnforcementMode(context.Background(), SetEnforcementModeCommand{Action: ActionDashboardsRead, Mode: "lenient"})
+		require.ErrorIs(t, err, ErrInvalidEnforcementMode)
+		err = ac.SetEnforcementMode(context.Background(), SetEnforcementModeCommand{Action: "dashboards:unknown", Mode: EnforcementModeLogOnly})
+		require.ErrorIs(t, err, ErrUnknownAction)
+		require.ErrorIs(t, ac.RemoveEnforcementMode(context.Background(), ActionDashboardsRead), ErrEnforcementModeNotFound)
+	})
+}
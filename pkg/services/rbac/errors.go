@@ -0,0 +1,100 @@
+package rbac
+
+import "errors"
+
+// ErrorKind is the failure reason of an RBAC service error, so that callers such as the CLI can
+// handle whole classes of errors without listing every one of them.
+type ErrorKind string
+
+const (
+	// ErrorKindInternal is the kind of the errors that aren't caused by the request, e.g. database errors.
+	ErrorKindInternal ErrorKind = "internal"
+	// ErrorKindValidation is the kind of the errors for invalid requests.
+	ErrorKindValidation ErrorKind = "validation"
+	// ErrorKindNotFound is the kind of the errors for missing policies, permissions and bindings.
+	ErrorKindNotFound ErrorKind = "notFound"
+	// ErrorKindConflict is the kind of the errors for requests clashing with the current state.
+	ErrorKindConflict ErrorKind = "conflict"
+	// ErrorKindQuota is the kind of the errors for requests exceeding a limit.
+	ErrorKindQuota ErrorKind = "quota"
+	// ErrorKindSchemaOutdated is the kind of the errors for writes requiring a more recent schema.
+	ErrorKindSchemaOutdated ErrorKind = "schemaOutdated"
+)
+
+var errorKinds = []struct {
+	err  error
+	kind ErrorKind
+}{
+	{ErrInvalidPrecedence, ErrorKindValidation},
+	{ErrInvalidPermissionKind, ErrorKindValidation},
+	{ErrPermissionExpiryInPast, ErrorKindValidation},
+	{ErrBindingExpiryInPast, ErrorKindValidation},
+	{ErrInvalidBuiltinRole, ErrorKindValidation},
+	{ErrInvalidAssignee, ErrorKindValidation},
+	{ErrInvalidBindingLifetime, ErrorKindValidation},
+	{ErrBindingExpiryBeyondLifetime, ErrorKindValidation},
+	{ErrInvalidCondition, ErrorKindValidation},
+	{ErrUnknownAction, ErrorKindValidation},
+	{ErrInvalidAction, ErrorKindValidation},
+	{ErrInvalidScope, ErrorKindValidation},
+	{ErrInvalidDecisionLog, ErrorKindValidation},
+	{ErrPolicyUIDRequired, ErrorKindValidation},
+	{ErrManagedPolicy, ErrorKindValidation},
+	{ErrInvalidExternalGroup, ErrorKindValidation},
+	{ErrInvalidStateExport, ErrorKindValidation},
+	{ErrInvalidBreakGlassDuration, ErrorKindValidation},
+	{ErrBreakGlassJustificationRequired, ErrorKindValidation},
+	{ErrAccessRequestSelfReview, ErrorKindValidation},
+	{ErrInvalidAccessRequest, ErrorKindValidation},
+	{ErrUnknownResourceType, ErrorKindValidation},
+	{ErrInvalidEnforcementMode, ErrorKindValidation},
+	{ErrAPIKeyLastPolicy, ErrorKindValidation},
+
+	{ErrPolicyNotFound, ErrorKindNotFound},
+	{ErrPermissionNotFound, ErrorKindNotFound},
+	{ErrTeamPolicyNotFound, ErrorKindNotFound},
+	{ErrUserPolicyNotFound, ErrorKindNotFound},
+	{ErrServiceAccountNotFound, ErrorKindNotFound},
+	{ErrAPIKeyPolicyNotFound, ErrorKindNotFound},
+	{ErrBuiltinRolePolicyNotFound, ErrorKindNotFound},
+	{ErrDefaultPolicyNotFound, ErrorKindNotFound},
+	{ErrRoleMigrationNotFound, ErrorKindNotFound},
+	{ErrExternalGroupPolicyNotFound, ErrorKindNotFound},
+	{ErrPolicyGroupMappingNotFound, ErrorKindNotFound},
+	{ErrBreakGlassNotConfigured, ErrorKindNotFound},
+	{ErrAccessRequestNotFound, ErrorKindNotFound},
+	{ErrEnforcementModeNotFound, ErrorKindNotFound},
+
+	{ErrPolicyAlreadyExists, ErrorKindConflict},
+	{ErrPermissionAlreadyExists, ErrorKindConflict},
+	{ErrTeamPolicyAlreadyAdded, ErrorKindConflict},
+	{ErrUserPolicyAlreadyAdded, ErrorKindConflict},
+	{ErrServiceAccountAlreadyExists, ErrorKindConflict},
+	{ErrAPIKeyPolicyAlreadyAdded, ErrorKindConflict},
+	{ErrBuiltinRolePolicyAlreadyAdded, ErrorKindConflict},
+	{ErrDefaultPolicyAlreadyAdded, ErrorKindConflict},
+	{ErrExternalGroupPolicyAlreadyAdded, ErrorKindConflict},
+	{ErrPolicyGroupMappingAlreadyExists, ErrorKindConflict},
+	{ErrRoleMigrationRolledBack, ErrorKindConflict},
+	{ErrUserAlreadySuspended, ErrorKindConflict},
+	{ErrUserNotSuspended, ErrorKindConflict},
+	{ErrStateMismatch, ErrorKindConflict},
+	{ErrBreakGlassActive, ErrorKindConflict},
+	{ErrAccessRequestPending, ErrorKindConflict},
+	{ErrAccessRequestReviewed, ErrorKindConflict},
+
+	{ErrPermissionLimitExceeded, ErrorKindQuota},
+
+	{ErrSchemaOutdated, ErrorKindSchemaOutdated},
+}
+
+// ErrorKindOf returns the kind of an error returned by the RBAC service, ErrorKindInternal for
+// the errors it doesn't know about.
+func ErrorKindOf(err error) ErrorKind {
+	for _, k := range errorKinds {
+		if errors.Is(err, k.err) {
+			return k.kind
+		}
+	}
+	return ErrorKindInternal
+}
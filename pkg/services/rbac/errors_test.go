@@ -0,0 +1,28 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestErrorKindOf(t *testing.T) {
+	tests := []struct {
+		err  error
+		kind ErrorKind
+	}{
+		{ErrPermissionLimitExceeded, ErrorKindQuota},
+		{ErrPolicyAlreadyExists, ErrorKindConflict},
+		{ErrUserPolicyNotFound, ErrorKindNotFound},
+		{fmt.Errorf("%w %q", ErrUnknownAction, "dashboards:fly"), ErrorKindValidation},
+		{fmt.Errorf("%w: version 18 is required", ErrSchemaOutdated), ErrorKindSchemaOutdated},
+		{errors.New("database is locked"), ErrorKindInternal},
+	}
+	for _, tt := range tests {
+		t.Run(tt.err.Error(), func(t *testing.T) {
+			assert.Equal(t, tt.kind, ErrorKindOf(tt.err))
+		})
+	}
+}
@@ -0,0 +1,196 @@
+package rbac
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// accessRequest describes a single access check.
+type accessRequest struct {
+	Action string
+	Scope  string
+	// Attributes of the user and resource, used to evaluate permission conditions.
+	Attributes Attributes
+	// Time of the request, used to evaluate permission schedules.
+	Time time.Time
+	// RemoteAddr of the request, used to evaluate source network conditions.
+	RemoteAddr string
+	// AuthTime is when the user logged in, used to evaluate authentication age conditions.
+	AuthTime time.Time
+
+	// combining holds the combining algorithms of the actions, nil uses deny overrides.
+	combining *combiningAlgorithms
+	// timings records the time spent matching scopes and checking conditions, may be nil.
+	timings *decisionTimings
+	// grantsEverything is set when the permissions evaluated are known to grant every access check
+	// with an action and a scope, see grantsEverything.
+	grantsEverything bool
+}
+
+// evaluatePermissions returns true if the permissions grant the requested access.
+//
+// A permission applies when its action and scope match the request and all of its
+// conditions match the request attributes. Precedence rules:
+// - only the applicable permissions of the policies with the highest precedence count,
+// - policies without a precedence rank below all others,
+// - among those, with deny overrides, the default, an applicable deny permission always wins, regardless of how many allow permissions apply,
+// - with allow overrides an applicable allow permission wins instead, and with first applicable the one created first,
+// - when nothing applies, access is denied.
+//
+// The combining algorithm of each action is set in the settings, see loadCombiningAlgorithms.
+func evaluatePermissions(permissions []Permission, req accessRequest) bool {
+	if req.grantsEverything && req.Action != "" && req.Scope != "" {
+		return true
+	}
+
+	allowed, denied := false, false
+	var first *Permission
+	var highest *int
+	for i, p := range permissions {
+		if !permissionApplies(p, req) {
+			continue
+		}
+		switch comparePrecedence(p.Precedence, highest) {
+		case -1:
+			continue
+		case 1:
+			highest = p.Precedence
+			allowed, denied, first = false, false, nil
+		}
+		if p.IsDeny() {
+			denied = true
+		} else {
+			allowed = true
+		}
+		if first == nil || createdBefore(p, *first) {
+			first = &permissions[i]
+		}
+	}
+
+	switch req.combining.forAction(req.Action) {
+	case CombiningAllowOverrides:
+		return allowed
+	case CombiningFirstApplicable:
+		return first != nil && !first.IsDeny()
+	default:
+		return allowed && !denied
+	}
+}
+
+// grantsEverything returns true if the permissions grant every access check with an action and a
+// scope, whatever the combining algorithm: an unconditional allow of any action on any scope, such as
+// *:* on *, ranks at the highest precedence and no deny permission ranks as high. Wildcards don't
+// match empty values, so the checks without a scope still go through evaluatePermissions.
+func grantsEverything(permissions []Permission) bool {
+	var highest *int
+	granted, denied := false, false
+	for i, p := range permissions {
+		cmp := comparePrecedence(p.Precedence, highest)
+		if i > 0 && cmp < 0 {
+			continue
+		}
+		if i == 0 || cmp > 0 {
+			highest = p.Precedence
+			granted, denied = false, false
+		}
+		if p.IsDeny() {
+			denied = true
+		} else if len(p.Conditions) == 0 && strings.HasPrefix(p.Action, wildcard) && strings.HasPrefix(p.Scope, wildcard) {
+			granted = true
+		}
+	}
+
+	return granted && !denied
+}
+
+// comparePrecedence returns -1, 0 or 1 if a ranks below, the same as or above b. Nil ranks lowest.
+func comparePrecedence(a, b *int) int {
+	switch {
+	case a == nil && b == nil:
+		return 0
+	case a == nil:
+		return -1
+	case b == nil || *a > *b:
+		return 1
+	case *a < *b:
+		return -1
+	default:
+		return 0
+	}
+}
+
+// permissionApplies returns true if the permission matches the request's action, scope and attributes.
+func permissionApplies(p Permission, req accessRequest) bool {
+	start := time.Now()
+	matches := matchPattern(p.Action, req.Action) && matchPattern(p.Scope, req.Scope)
+	req.timings.since(PhaseScopeMatching, start)
+	if !matches || len(p.Conditions) == 0 {
+		return matches
+	}
+
+	start = time.Now()
+	defer req.timings.since(PhaseConditionChecks, start)
+	return matchConditions(p.Conditions, req)
+}
+
+// evaluate resolves the permissions of a user and returns true if they grant the requested access.
+// The user's attributes are added to the request attributes before evaluating conditions.
+func (ac *RBACService) evaluate(ctx context.Context, user *models.SignedInUser, req accessRequest) (bool, error) {
+	return ac.evaluateRequests(ctx, user, req)
+}
+
+// evaluateRequests resolves the permissions of a user once and returns true if they grant every request.
+// Requests without a remote address use the one carried by the context, see WithRemoteAddr.
+func (ac *RBACService) evaluateRequests(ctx context.Context, user *models.SignedInUser, reqs ...accessRequest) (bool, error) {
+	return ac.Evaluate(ctx, user, requestsEvaluator(reqs))
+}
+
+// requestsEvaluator requires the permissions to grant every request. Requests are completed
+// with the time, remote address and user attributes of the environment.
+type requestsEvaluator []accessRequest
+
+func (e requestsEvaluator) Evaluate(permissions []Permission, env Environment) bool {
+	for _, req := range e {
+		if req.Time.IsZero() {
+			req.Time = env.Time
+		}
+		if req.RemoteAddr == "" {
+			req.RemoteAddr = env.RemoteAddr
+		}
+		if req.AuthTime.IsZero() {
+			req.AuthTime = env.AuthTime
+		}
+		req.combining = env.combining
+		req.timings = env.timings
+		req.grantsEverything = env.grantsEverything
+
+		attrs := Attributes{}
+		for k, v := range env.Attributes {
+			attrs[k] = append(attrs[k], v...)
+		}
+		for k, v := range req.Attributes {
+			attrs[k] = append(attrs[k], v...)
+		}
+		req.Attributes = attrs
+
+		if !evaluatePermissions(permissions, req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+func (e requestsEvaluator) String() string {
+	evaluators := make([]Evaluator, 0, len(e))
+	for _, req := range e {
+		evaluators = append(evaluators, Perm(req.Action, req.Scope))
+	}
+	if len(evaluators) == 1 {
+		return evaluators[0].String()
+	}
+	return joinEvaluators("all", evaluators)
+}
@@ -0,0 +1,421 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"net/http"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestEvaluatePermissions_Deny(t *testing.T) {
+	permissions := []Permission{
+		{Action: "dashboards:*", Scope: "dashboards:*", Kind: PermissionKindAllow},
+		{Action: "dashboards:delete", Scope: "dashboards:uid:prod", Kind: PermissionKindDeny},
+	}
+
+	assert.True(t, evaluatePermissions(permissions, accessRequest{Action: "dashboards:delete", Scope: "dashboards:uid:dev"}))
+	assert.False(t, evaluatePermissions(permissions, accessRequest{Action: "dashboards:delete", Scope: "dashboards:uid:prod"}))
+	assert.True(t, evaluatePermissions(permissions, accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:prod"}))
+
+	t.Run("Deny should win regardless of order", func(t *testing.T) {
+		reversed := []Permission{permissions[1], permissions[0]}
+		assert.False(t, evaluatePermissions(reversed, accessRequest{Action: "dashboards:delete", Scope: "dashboards:uid:prod"}))
+	})
+
+	t.Run("A deny on its own should not grant anything", func(t *testing.T) {
+		assert.False(t, evaluatePermissions(permissions[1:], accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:dev"}))
+	})
+}
+
+func TestEvaluatePermissions_Precedence(t *testing.T) {
+	low, high := 1, 10
+	req := accessRequest{Action: "dashboards:delete", Scope: "dashboards:uid:prod"}
+
+	t.Run("The permissions of the highest precedence should win", func(t *testing.T) {
+		permissions := []Permission{
+			{Action: "dashboards:delete", Scope: "dashboards:*", Kind: PermissionKindDeny, Precedence: &low},
+			{Action: "dashboards:delete", Scope: "dashboards:uid:prod", Kind: PermissionKindAllow, Precedence: &high},
+		}
+		assert.True(t, evaluatePermissions(permissions, req))
+
+		permissions[0].Precedence, permissions[1].Precedence = &high, &low
+		assert.False(t, evaluatePermissions(permissions, req))
+	})
+
+	t.Run("Policies without a precedence should rank lowest", func(t *testing.T) {
+		permissions := []Permission{
+			{Action: "dashboards:delete", Scope: "dashboards:*", Kind: PermissionKindDeny},
+			{Action: "dashboards:delete", Scope: "dashboards:uid:prod", Kind: PermissionKindAllow, Precedence: &low},
+		}
+		assert.True(t, evaluatePermissions(permissions, req))
+	})
+
+	t.Run("Deny should win among permissions of the same precedence", func(t *testing.T) {
+		permissions := []Permission{
+			{Action: "dashboards:delete", Scope: "dashboards:uid:prod", Kind: PermissionKindAllow, Precedence: &high},
+			{Action: "dashboards:delete", Scope: "dashboards:*", Kind: PermissionKindDeny, Precedence: &high},
+		}
+		assert.False(t, evaluatePermissions(permissions, req))
+	})
+}
+
+func TestPolicyPrecedence(t *testing.T) {
+	ac := setupTestEnv(t)
+	user := &models.SignedInUser{OrgId: 1, UserId: 201}
+
+	deny := createPolicy(t, ac, 1, "no deletes", CreatePermissionCommand{Action: "dashboards:delete", Scope: "dashboards:*", Kind: PermissionKindDeny})
+	allow := createPolicy(t, ac, 1, "prod owners", CreatePermissionCommand{Action: "dashboards:delete", Scope: "dashboards:uid:prod"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 201, PolicyID: deny.ID}))
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 201, PolicyID: allow.ID}))
+
+	req := accessRequest{Action: "dashboards:delete", Scope: "dashboards:uid:prod"}
+	ok, err := ac.evaluate(context.Background(), user, req)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	precedence := 10
+	updated, err := ac.UpdatePolicy(context.Background(), UpdatePolicyCommand{OrgID: 1, ID: allow.ID, Name: allow.Name, Precedence: &precedence})
+	require.NoError(t, err)
+	require.NotNil(t, updated.Precedence)
+	assert.Equal(t, 10, *updated.Precedence)
+
+	ok, err = ac.evaluate(context.Background(), user, req)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	t.Run("A suspension should win over any precedence", func(t *testing.T) {
+		_, err := ac.SuspendUserAccess(context.Background(), SuspendUserAccessCommand{OrgID: 1, UserID: 201})
+		require.NoError(t, err)
+
+		ok, err := ac.evaluate(context.Background(), user, req)
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+
+	t.Run("Precedences reserved for managed policies should be refused", func(t *testing.T) {
+		reserved := suspendedPolicyPrecedence
+		_, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "top", Precedence: &reserved})
+		require.ErrorIs(t, err, ErrInvalidPrecedence)
+	})
+}
+
+func TestCreatePermission_Kind(t *testing.T) {
+	ac := setupTestEnv(t)
+	policy := createPolicy(t, ac, 1, "editor")
+
+	allow, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, err)
+	assert.Equal(t, PermissionKindAllow, allow.Kind)
+
+	deny, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "dashboards:delete", Scope: "dashboards:*", Kind: PermissionKindDeny})
+	require.NoError(t, err)
+
+	permissions, err := ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID})
+	require.NoError(t, err)
+	require.Len(t, permissions, 2)
+	assert.True(t, permissions[1].IsDeny())
+	assert.Equal(t, deny.ID, permissions[1].ID)
+
+	_, err = ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "dashboards:write", Scope: "dashboards:*", Kind: "maybe"})
+	require.ErrorIs(t, err, ErrInvalidPermissionKind)
+}
+
+func TestEvaluatePermissions_Conditions(t *testing.T) {
+	permissions := []Permission{
+		{
+			Action:     "datasources:query",
+			Scope:      "datasources:*",
+			Conditions: []Condition{{Attribute: AttributeDatasourceType, Values: []string{"prometheus", "loki"}}},
+		},
+		{
+			Action: "dashboards:write",
+			Scope:  "dashboards:*",
+			Kind:   PermissionKindDeny,
+			Conditions: []Condition{
+				{Attribute: AttributeDashboardTags, Values: []string{"locked"}},
+				{Attribute: AttributeUserOrgRole, Values: []string{"Viewer", "Editor"}},
+			},
+		},
+		{Action: "dashboards:write", Scope: "dashboards:*"},
+	}
+
+	query := func(datasourceType string) accessRequest {
+		return accessRequest{
+			Action:     "datasources:query",
+			Scope:      "datasources:id:1",
+			Attributes: Attributes{AttributeDatasourceType: {datasourceType}},
+		}
+	}
+	assert.True(t, evaluatePermissions(permissions, query("prometheus")))
+	assert.False(t, evaluatePermissions(permissions, query("mysql")))
+	assert.False(t, evaluatePermissions(permissions, accessRequest{Action: "datasources:query", Scope: "datasources:id:1"}))
+
+	write := func(role string, tags ...string) accessRequest {
+		return accessRequest{
+			Action:     "dashboards:write",
+			Scope:      "dashboards:uid:abc",
+			Attributes: Attributes{AttributeDashboardTags: tags, AttributeUserOrgRole: {role}},
+		}
+	}
+	assert.False(t, evaluatePermissions(permissions, write("Editor", "prod", "locked")))
+	assert.True(t, evaluatePermissions(permissions, write("Admin", "prod", "locked")))
+	assert.True(t, evaluatePermissions(permissions, write("Editor", "prod")))
+}
+
+func TestCreatePermission_Conditions(t *testing.T) {
+	ac := setupTestEnv(t)
+	policy := createPolicy(t, ac, 1, "prometheus-users")
+
+	conditions := []Condition{{Attribute: AttributeDatasourceType, Values: []string{"prometheus"}}}
+	_, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "datasources:query", Scope: "datasources:*", Conditions: conditions})
+	require.NoError(t, err)
+
+	permissions, err := ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID})
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	assert.Equal(t, conditions, permissions[0].Conditions)
+
+	_, err = ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "datasources:read", Scope: "datasources:*", Conditions: []Condition{{Attribute: AttributeDatasourceType}}})
+	require.ErrorIs(t, err, ErrInvalidCondition)
+}
+
+func TestEvaluatePermissions_SourceNetworks(t *testing.T) {
+	permissions := []Permission{
+		{
+			Action:     "users:write",
+			Scope:      "users:*",
+			Conditions: []Condition{{SourceNetworks: []string{"10.0.0.0/8", "2001:db8::/32"}}},
+		},
+	}
+
+	tests := []struct {
+		addr  string
+		match bool
+	}{
+		{addr: "10.1.2.3", match: true},
+		{addr: "10.1.2.3:51234", match: true},
+		{addr: "[2001:db8::1]:443", match: true},
+		{addr: "192.168.1.1", match: false},
+		{addr: "", match: false},
+		{addr: "not-an-ip", match: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.addr, func(t *testing.T) {
+			req := accessRequest{Action: "users:write", Scope: "users:id:1", RemoteAddr: tc.addr}
+			assert.Equal(t, tc.match, evaluatePermissions(permissions, req))
+		})
+	}
+}
+
+func TestEvaluate_RemoteAddrFromContext(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "security")
+	addTeamMember(t, 1, team.Id, 11)
+	policy := createPolicy(t, ac, 1, "corporate admin", CreatePermissionCommand{
+		Action:     "users:write",
+		Scope:      "users:*",
+		Conditions: []Condition{{SourceNetworks: []string{"10.0.0.0/8"}}},
+	})
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 11}
+	req := accessRequest{Action: "users:write", Scope: "users:id:2"}
+
+	ok, err := ac.evaluate(WithRemoteAddr(context.Background(), "10.0.0.5"), user, req)
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ac.evaluate(WithRemoteAddr(context.Background(), "203.0.113.9"), user, req)
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	ok, err = ac.evaluate(context.Background(), user, req)
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestClientAddr(t *testing.T) {
+	cfg := setting.NewCfg()
+	_, err := cfg.Raw.Section("rbac").NewKey("trusted_proxies", "10.1.0.0/16, 192.168.0.1/32")
+	require.NoError(t, err)
+	ac := &RBACService{Cfg: cfg}
+	require.NoError(t, ac.loadTrustedProxies())
+
+	tests := []struct {
+		desc         string
+		remoteAddr   string
+		forwardedFor []string
+		expectedAddr string
+	}{
+		{desc: "direct request", remoteAddr: "203.0.113.9:5000", expectedAddr: "203.0.113.9"},
+		{desc: "spoofed header from untrusted client", remoteAddr: "203.0.113.9:5000", forwardedFor: []string{"10.0.0.5"}, expectedAddr: "203.0.113.9"},
+		{desc: "request through a trusted proxy", remoteAddr: "10.1.0.2:5000", forwardedFor: []string{"10.0.0.5"}, expectedAddr: "10.0.0.5"},
+		{desc: "request through chained trusted proxies", remoteAddr: "10.1.0.2:5000", forwardedFor: []string{"10.0.0.5, 192.168.0.1"}, expectedAddr: "10.0.0.5"},
+		{desc: "spoofed hop before trusted proxies", remoteAddr: "10.1.0.2:5000", forwardedFor: []string{"10.0.0.5", "203.0.113.9"}, expectedAddr: "203.0.113.9"},
+		{desc: "trusted proxy without header", remoteAddr: "10.1.0.2:5000", expectedAddr: "10.1.0.2"},
+		{desc: "invalid hop", remoteAddr: "10.1.0.2:5000", forwardedFor: []string{"unknown"}, expectedAddr: "10.1.0.2"},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			req, err := http.NewRequest("GET", "/", nil)
+			require.NoError(t, err)
+			req.RemoteAddr = tc.remoteAddr
+			for _, v := range tc.forwardedFor {
+				req.Header.Add("X-Forwarded-For", v)
+			}
+			assert.Equal(t, tc.expectedAddr, ac.ClientAddr(req))
+		})
+	}
+
+	t.Run("Invalid trusted proxies should be rejected", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		_, err := cfg.Raw.Section("rbac").NewKey("trusted_proxies", "10.1.0.1")
+		require.NoError(t, err)
+		ac := &RBACService{Cfg: cfg}
+		require.Error(t, ac.loadTrustedProxies())
+	})
+}
+
+func TestCreatePermission_InvalidSourceNetworks(t *testing.T) {
+	ac := setupTestEnv(t)
+	policy := createPolicy(t, ac, 1, "editor")
+
+	_, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{
+		OrgID:      policy.OrgID,
+		PolicyID:   policy.ID,
+		Action:     "users:write",
+		Scope:      "users:*",
+		Conditions: []Condition{{SourceNetworks: []string{"10.0.0.1"}}},
+	})
+	require.ErrorIs(t, err, ErrInvalidCondition)
+
+	_, err = ac.CreatePermission(context.Background(), CreatePermissionCommand{
+		OrgID:      policy.OrgID,
+		PolicyID:   policy.ID,
+		Action:     "users:write",
+		Scope:      "users:*",
+		Conditions: []Condition{{Attribute: AttributeUserLogin, Values: []string{"admin"}, SourceNetworks: []string{"10.0.0.0/8"}}},
+	})
+	require.ErrorIs(t, err, ErrInvalidCondition)
+}
+
+func TestGrantsEverything(t *testing.T) {
+	high := 10
+	admin := Permission{Action: "*:*", Scope: "*"}
+
+	assert.True(t, grantsEverything([]Permission{{Action: ActionTeamsRead, Scope: "teams:*"}, admin}))
+	assert.True(t, grantsEverything([]Permission{{Action: "*", Scope: "*"}}))
+	assert.False(t, grantsEverything(nil))
+	assert.False(t, grantsEverything([]Permission{{Action: "dashboards:*", Scope: "*"}}))
+	assert.False(t, grantsEverything([]Permission{{Action: "*", Scope: "*", Conditions: []Condition{{Attribute: AttributeUserLogin, Values: []string{"admin"}}}}}))
+
+	t.Run("A deny of the same precedence should prevent the fast path", func(t *testing.T) {
+		assert.False(t, grantsEverything([]Permission{admin, {Action: ActionTeamsDelete, Scope: "teams:id:1", Kind: PermissionKindDeny}}))
+	})
+
+	t.Run("Only the highest precedence should count", func(t *testing.T) {
+		assert.False(t, grantsEverything([]Permission{admin, {Action: ActionTeamsRead, Scope: "teams:id:1", Precedence: &high}}))
+		ranked := admin
+		ranked.Precedence = &high
+		assert.True(t, grantsEverything([]Permission{{Action: ActionTeamsDelete, Scope: "teams:*", Kind: PermissionKindDeny}, ranked}))
+	})
+
+	t.Run("The fast path should agree with the evaluation", func(t *testing.T) {
+		permissions := []Permission{admin, {Action: "users:read", Scope: ""}}
+		env := Environment{}.withPermissions(permissions)
+		require.True(t, env.grantsEverything)
+
+		for _, evaluator := range []Evaluator{Perm(ActionTeamsRead, "teams:id:1"), Perm("users:read", ""), Perm("users:write", "")} {
+			assert.Equal(t, evaluator.Evaluate(permissions, Environment{}), evaluator.Evaluate(permissions, env), evaluator.String())
+		}
+	})
+}
+
+func benchmarkPermissions(count int) []Permission {
+	permissions := make([]Permission, 0, count)
+	for i := 0; i < count; i++ {
+		permissions = append(permissions, Permission{
+			ID: int64(i + 1), Action: ActionDashboardsRead, Scope: "dashboards:uid:" + strconv.Itoa(i),
+		})
+	}
+	return permissions
+}
+
+func BenchmarkEvaluatePermissions(b *testing.B) {
+	for _, count := range []int{100, 1000, 10000} {
+		permissions := benchmarkPermissions(count)
+		evaluator := Perm(ActionDashboardsRead, "dashboards:uid:"+strconv.Itoa(count-1))
+
+		b.Run(fmt.Sprintf("permissions=%d", count), func(b *testing.B) {
+			for i := 0; i < b.N; i++ {
+				evaluator.Evaluate(permissions, Environment{})
+			}
+		})
+
+		b.Run(fmt.Sprintf("permissions=%d/grants_everything", count), func(b *testing.B) {
+			permissions := append(benchmarkPermissions(count), Permission{Action: "*:*", Scope: "*"})
+			env := Environment{}.withPermissions(permissions)
+			b.ResetTimer()
+			for i := 0; i < b.N; i++ {
+				evaluator.Evaluate(permissions, env)
+			}
+		})
+	}
+}
+
+func BenchmarkEvaluateAll_Folders(b *testing.B) {
+	// Every dashboard scope is checked against the dashboards expanded from the folder scopes.
+	const folders, dashboardsPerFolder = 20, 50
+
+	ac := setupTestEnv(b)
+	policy, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "folder reader"})
+	require.NoError(b, err)
+
+	var scopes []string
+	err = ac.SQLStore.WithTransactionalDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		for f := 0; f < folders; f++ {
+			folder := models.NewDashboardFolder(fmt.Sprintf("folder %d", f))
+			folder.OrgId, folder.Uid = 1, fmt.Sprintf("folder-%d", f)
+			if _, err := sess.Insert(folder); err != nil {
+				return err
+			}
+			for d := 0; d < dashboardsPerFolder; d++ {
+				dashboard := models.NewDashboard(fmt.Sprintf("dashboard %d %d", f, d))
+				dashboard.OrgId, dashboard.FolderId, dashboard.Uid = 1, folder.Id, fmt.Sprintf("dashboard-%d-%d", f, d)
+				if _, err := sess.Insert(dashboard); err != nil {
+					return err
+				}
+				scopes = append(scopes, ScopeDashboardUID(dashboard.Uid))
+			}
+		}
+		return nil
+	})
+	require.NoError(b, err)
+	for f := 0; f < folders; f++ {
+		_, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{
+			OrgID: policy.OrgID, PolicyID: policy.ID, Action: ActionDashboardsRead, Scope: ScopeFolderUID(fmt.Sprintf("folder-%d", f)),
+		})
+		require.NoError(b, err)
+	}
+	require.NoError(b, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 499, PolicyID: policy.ID}))
+	user := &models.SignedInUser{OrgId: 1, UserId: 499}
+
+	b.Run(fmt.Sprintf("dashboards=%d", len(scopes)), func(b *testing.B) {
+		for i := 0; i < b.N; i++ {
+			result, err := ac.EvaluateAll(context.Background(), user, ActionDashboardsRead, scopes)
+			require.NoError(b, err)
+			require.True(b, result[scopes[len(scopes)-1]])
+		}
+	})
+}
@@ -0,0 +1,285 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// Environment holds the properties of an access check, besides the action and scope,
+// that permission conditions are evaluated against.
+type Environment struct {
+	Attributes Attributes
+	Time       time.Time
+	RemoteAddr string
+	// AuthTime is when the user logged in, zero when the request isn't authenticated by a login session.
+	AuthTime time.Time
+
+	// combining holds the combining algorithms of the actions, see loadCombiningAlgorithms.
+	combining *combiningAlgorithms
+	// timings records the time spent matching scopes and checking conditions, nil outside of Evaluate.
+	timings *decisionTimings
+	// grantsEverything short-circuits the evaluation of the permissions it was computed for, see
+	// withPermissions. It must be reset before evaluating other permissions in the environment.
+	grantsEverything bool
+	// external decides the access checks deferred to the external policy decision point, nil when
+	// they aren't, see loadExternalPDPSettings.
+	external *externalDecisions
+}
+
+// withPermissions returns the environment for evaluating the permissions of a user resolved once, so
+// that the permissions granting everything skip the scope matching, see grantsEverything.
+func (env Environment) withPermissions(permissions []Permission) Environment {
+	env.grantsEverything = grantsEverything(permissions)
+	return env
+}
+
+// Evaluator is a requirement that a set of permissions either satisfies or not. Evaluators
+// are built with Perm and combined with All, Any and Not, e.g.
+//
+//	rbac.Any(rbac.Perm("users:write", "users:*"), rbac.Perm("org.users:write", "users:*"))
+//
+// A combined evaluator is decided against the permissions of the user resolved once, so an access
+// check needing several permissions should combine them rather than make several checks.
+type Evaluator interface {
+	// Evaluate returns true if the permissions satisfy the requirement in the environment.
+	Evaluate(permissions []Permission, env Environment) bool
+	// String returns a description of the requirement, used when logging denied requests.
+	String() string
+}
+
+// Perm requires a permission granting the action on the scope.
+func Perm(action, scope string) Evaluator {
+	return permEvaluator{action: action, scope: scope}
+}
+
+// All requires every evaluator to be satisfied. All without evaluators is always satisfied.
+func All(evaluators ...Evaluator) Evaluator {
+	return allEvaluator(evaluators)
+}
+
+// Any requires at least one evaluator to be satisfied. Any without evaluators is never satisfied.
+func Any(evaluators ...Evaluator) Evaluator {
+	return anyEvaluator(evaluators)
+}
+
+// Not requires the evaluator not to be satisfied.
+func Not(evaluator Evaluator) Evaluator {
+	return notEvaluator{evaluator: evaluator}
+}
+
+type permEvaluator struct {
+	action string
+	scope  string
+}
+
+func (e permEvaluator) Evaluate(permissions []Permission, env Environment) bool {
+	if allowed, deferred := env.external.decide(e.action, e.scope); deferred {
+		return allowed
+	}
+	return evaluatePermissions(permissions, accessRequest{
+		Action:     e.action,
+		Scope:      e.scope,
+		Attributes: env.Attributes,
+		Time:       env.Time,
+		RemoteAddr: env.RemoteAddr,
+		AuthTime:   env.AuthTime,
+		combining:  env.combining,
+		timings:    env.timings,
+
+		grantsEverything: env.grantsEverything,
+	})
+}
+
+func (e permEvaluator) String() string {
+	if e.scope == "" {
+		return e.action
+	}
+	return fmt.Sprintf("%s on %s", e.action, e.scope)
+}
+
+type allEvaluator []Evaluator
+
+func (e allEvaluator) Evaluate(permissions []Permission, env Environment) bool {
+	for _, evaluator := range e {
+		if !evaluator.Evaluate(permissions, env) {
+			return false
+		}
+	}
+	return true
+}
+
+func (e allEvaluator) String() string {
+	return joinEvaluators("all", e)
+}
+
+type anyEvaluator []Evaluator
+
+func (e anyEvaluator) Evaluate(permissions []Permission, env Environment) bool {
+	for _, evaluator := range e {
+		if evaluator.Evaluate(permissions, env) {
+			return true
+		}
+	}
+	return false
+}
+
+func (e anyEvaluator) String() string {
+	return joinEvaluators("any", e)
+}
+
+type notEvaluator struct {
+	evaluator Evaluator
+}
+
+func (e notEvaluator) Evaluate(permissions []Permission, env Environment) bool {
+	return !e.evaluator.Evaluate(permissions, env)
+}
+
+func (e notEvaluator) String() string {
+	return fmt.Sprintf("not(%s)", e.evaluator)
+}
+
+func joinEvaluators(name string, evaluators []Evaluator) string {
+	parts := make([]string, 0, len(evaluators))
+	for _, evaluator := range evaluators {
+		parts = append(parts, evaluator.String())
+	}
+	return fmt.Sprintf("%s(%s)", name, strings.Join(parts, ", "))
+}
+
+// AccessEvaluator answers the access checks of signed in users, through the policies bound to them
+// directly, to their teams and to their builtin roles. It's implemented by RBACService, services
+// enforcing RBAC should depend on it rather than on the service.
+type AccessEvaluator interface {
+	// HasPermission returns true if the user is granted the action on the scope.
+	HasPermission(ctx context.Context, user *models.SignedInUser, action, scope string) (bool, error)
+	// Evaluate returns true if the permissions of the user satisfy the evaluator.
+	Evaluate(ctx context.Context, user *models.SignedInUser, evaluator Evaluator) (bool, error)
+	// EvaluateWithLegacy is Evaluate for access checks that legacy access control decided too, its
+	// decision is used when RBAC defers to it.
+	EvaluateWithLegacy(ctx context.Context, user *models.SignedInUser, evaluator Evaluator, legacyAllowed bool) (bool, error)
+	// EvaluateAll tells, for each scope, whether the user is granted the action on it.
+	EvaluateAll(ctx context.Context, user *models.SignedInUser, action string, scopes []string) (map[string]bool, error)
+}
+
+var _ AccessEvaluator = (*RBACService)(nil)
+
+// HasPermission resolves the permissions of a user and returns true if they grant the action on the scope.
+func (ac *RBACService) HasPermission(ctx context.Context, user *models.SignedInUser, action, scope string) (bool, error) {
+	return ac.Evaluate(ctx, user, Perm(action, scope))
+}
+
+// EvaluateAll resolves the permissions of a user once and tells, for each scope, whether they grant
+// the action on it, e.g. to filter the results of a search. The permissions are resolved as in Filter,
+// for delegated permissions and API keys with policies too, and each scope goes through the decision
+// middlewares and the enforcement modes. As in Evaluate, access checks deferring to legacy access
+// control are denied.
+func (ac *RBACService) EvaluateAll(ctx context.Context, user *models.SignedInUser, action string, scopes []string) (map[string]bool, error) {
+	result := make(map[string]bool, len(scopes))
+	if len(scopes) == 0 {
+		return result, nil
+	}
+
+	granting, err := ac.resolveGrantingPermissions(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	decide := ac.decideGranting(granting)
+	env := ac.environment(ctx, user)
+	env.external = ac.externalDecisions(ctx, user)
+	for _, scope := range scopes {
+		decision, err := decide(ctx, DecisionRequest{User: user, Evaluator: Perm(action, scope), Environment: env})
+		if err != nil {
+			return nil, err
+		}
+		result[scope] = decision.Allowed && !decision.Shadow
+	}
+
+	return result, nil
+}
+
+// Evaluate resolves the permissions of a user and returns true if they satisfy the evaluator.
+// The access check goes through the registered decision middlewares, see RegisterDecisionMiddleware.
+// Access checks deferring to legacy access control are denied, see Decide, callers enforcing legacy
+// access control must use EvaluateWithLegacy instead.
+func (ac *RBACService) Evaluate(ctx context.Context, user *models.SignedInUser, evaluator Evaluator) (bool, error) {
+	decision, err := ac.Decide(ctx, user, evaluator)
+	if err != nil {
+		return false, err
+	}
+
+	return decision.Allowed && !decision.Shadow, nil
+}
+
+// EvaluateWithLegacy resolves the permissions of a user and returns true if they satisfy the
+// evaluator, like the Authorize middleware: the decision of legacy access control is returned when
+// the access check falls back to it, and compared with RBAC's in shadow mode, see CompareDecision.
+func (ac *RBACService) EvaluateWithLegacy(ctx context.Context, user *models.SignedInUser, evaluator Evaluator, legacyAllowed bool) (bool, error) {
+	decision, err := ac.Decide(ctx, user, evaluator)
+	if err != nil {
+		return false, err
+	}
+
+	switch {
+	case decision.Shadow:
+		return ac.CompareDecision(user, evaluator, decision, legacyAllowed), nil
+	case decision.LegacyFallback:
+		return legacyAllowed, nil
+	}
+	return decision.Allowed, nil
+}
+
+// Decide resolves the permissions of a user and decides whether they satisfy the evaluator, or
+// whether legacy access control should decide because the resource types are in compat or shadow
+// mode. When the context carries a decision cache, the decisions of repeated checks are taken from it.
+func (ac *RBACService) Decide(ctx context.Context, user *models.SignedInUser, evaluator Evaluator) (*Decision, error) {
+	span, ctx := opentracing.StartSpanFromContext(ctx, "rbac - evaluate")
+	defer span.Finish()
+	span.SetTag("evaluator", evaluator.String())
+
+	start := time.Now()
+	timings := newDecisionTimings()
+	ctx = withDecisionTimings(ctx, timings)
+
+	req := DecisionRequest{User: user, Evaluator: evaluator, Environment: ac.environment(ctx, user)}
+	req.Environment.timings = timings
+	req.Environment.external = ac.externalDecisions(ctx, user)
+	cache := decisionCacheFromContext(ctx)
+	key := decisionKey(user, evaluator, req.Environment)
+	if decision, ok := cache.get(key); ok {
+		span.SetTag("cached", true)
+		span.SetTag("allowed", decision.Allowed)
+		return decision, nil
+	}
+
+	decision, err := ac.decisionChain(ac.decideWithDenyCache)(ctx, req)
+	if err != nil {
+		return nil, err
+	}
+	if req.Environment.external.wasDeferred() {
+		decision.Annotate("externalPdp", "true")
+	}
+	ac.applyEnforcementMode(ctx, req, decision)
+	cache.set(key, decision)
+	timings.observe(span, time.Since(start))
+	span.SetTag("allowed", decision.Allowed)
+
+	logCtx := []interface{}{"userId", user.UserId, "orgId", user.OrgId, "evaluator", evaluator.String(), "allowed", decision.Allowed}
+	for k, v := range decision.Annotations {
+		logCtx = append(logCtx, k, v)
+	}
+	ac.log.Debug("Access decision", logCtx...)
+	if (!decision.Allowed && !decision.LegacyFallback && !decision.Shadow) || decision.Enforcement == EnforcementModeLogOnly {
+		ac.recordDenial(user, req, decision)
+	}
+	ac.logDecision(ctx, req, decision)
+
+	return decision, nil
+}
@@ -0,0 +1,190 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestEvaluators(t *testing.T) {
+	permissions := []Permission{
+		{Action: "users:write", Scope: "users:*"},
+		{Action: "dashboards:read", Scope: "dashboards:*"},
+	}
+
+	tests := []struct {
+		desc      string
+		evaluator Evaluator
+		expected  bool
+	}{
+		{desc: "granted permission", evaluator: Perm("users:write", "users:*"), expected: true},
+		{desc: "missing permission", evaluator: Perm("org.users:write", "users:*"), expected: false},
+		{desc: "any with one match", evaluator: Any(Perm("users:write", "users:*"), Perm("org.users:write", "users:*")), expected: true},
+		{desc: "any without match", evaluator: Any(Perm("teams:write", "teams:*"), Perm("org.users:write", "users:*")), expected: false},
+		{desc: "empty any", evaluator: Any(), expected: false},
+		{desc: "all with every match", evaluator: All(Perm("users:write", "users:*"), Perm("dashboards:read", "dashboards:uid:1")), expected: true},
+		{desc: "all with one miss", evaluator: All(Perm("users:write", "users:*"), Perm("dashboards:write", "dashboards:uid:1")), expected: false},
+		{desc: "empty all", evaluator: All(), expected: true},
+		{desc: "not", evaluator: Not(Perm("teams:write", "teams:*")), expected: true},
+		{desc: "nested", evaluator: All(Perm("dashboards:read", "dashboards:uid:1"), Not(Any(Perm("teams:write", "teams:*")))), expected: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			assert.Equal(t, tc.expected, tc.evaluator.Evaluate(permissions, Environment{}))
+		})
+	}
+}
+
+func TestEvaluatorString(t *testing.T) {
+	evaluator := All(Perm("dashboards:read", "dashboards:*"), Not(Any(Perm("users:write", "users:*"), Perm("users:create", ""))))
+	assert.Equal(t, "all(dashboards:read on dashboards:*, not(any(users:write on users:*, users:create)))", evaluator.String())
+}
+
+func TestRBACService_Evaluate(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "user admins")
+	addTeamMember(t, 1, team.Id, 21)
+	policy := createPolicy(t, ac, 1, "user admin", CreatePermissionCommand{Action: "users:write", Scope: "users:*"})
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 21}
+
+	ok, err := ac.Evaluate(context.Background(), user, Any(Perm("users:write", "users:*"), Perm("org.users:write", "users:*")))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ac.Evaluate(context.Background(), user, All(Perm("users:write", "users:*"), Perm("users:delete", "users:*")))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRBACService_HasPermission(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "dashboard editors")
+	addTeamMember(t, 1, team.Id, 401)
+	direct := createPolicy(t, ac, 1, "datasource reader", CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:*"})
+	teamPolicy := createPolicy(t, ac, 1, "dashboard writer", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+	builtin := createPolicy(t, ac, 1, "dashboard reader", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 401, PolicyID: direct.ID}))
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: teamPolicy.ID}))
+	require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: builtin.ID}))
+
+	var evaluator AccessEvaluator = ac
+	user := &models.SignedInUser{OrgId: 1, UserId: 401, OrgRole: models.ROLE_VIEWER}
+
+	for _, tc := range []struct {
+		action, scope string
+		allowed       bool
+	}{
+		{"datasources:read", "datasources:uid:prometheus", true},
+		{"dashboards:write", "dashboards:uid:home", true},
+		{"dashboards:read", "dashboards:uid:home", true},
+		{"dashboards:delete", "dashboards:uid:home", false},
+	} {
+		ok, err := evaluator.HasPermission(context.Background(), user, tc.action, tc.scope)
+		require.NoError(t, err)
+		assert.Equal(t, tc.allowed, ok, "%s on %s", tc.action, tc.scope)
+	}
+
+	ok, err := evaluator.HasPermission(context.Background(), &models.SignedInUser{OrgId: 1, UserId: 402}, "datasources:read", "datasources:uid:prometheus")
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
+
+func TestRBACService_EvaluateAll(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "home reader",
+		CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:uid:home"},
+		CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:uid:ops"},
+	)
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 411, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 411}
+	result, err := ac.EvaluateAll(context.Background(), user, "dashboards:read", []string{
+		"dashboards:uid:home", "dashboards:uid:ops", "dashboards:uid:billing",
+	})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{
+		"dashboards:uid:home":    true,
+		"dashboards:uid:ops":     true,
+		"dashboards:uid:billing": false,
+	}, result)
+
+	result, err = ac.EvaluateAll(context.Background(), user, "dashboards:write", []string{"dashboards:uid:home"})
+	require.NoError(t, err)
+	assert.Equal(t, map[string]bool{"dashboards:uid:home": false}, result)
+
+	result, err = ac.EvaluateAll(context.Background(), user, "dashboards:read", nil)
+	require.NoError(t, err)
+	assert.Empty(t, result)
+
+	scopes := []string{"dashboards:uid:home", "dashboards:uid:ops"}
+
+	t.Run("Delegated permissions should be evaluated instead of the user's", func(t *testing.T) {
+		ctx := WithDelegatedPermissions(context.Background(), []Permission{{Action: "dashboards:read", Scope: "dashboards:uid:ops"}})
+		result, err := ac.EvaluateAll(ctx, user, "dashboards:read", scopes)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{"dashboards:uid:home": false, "dashboards:uid:ops": true}, result)
+	})
+
+	t.Run("API keys with policies should be granted what the key and its creators grant", func(t *testing.T) {
+		creator := &models.CreateUserCommand{Login: "evaluate-all-creator", SkipOrgSetup: true}
+		require.NoError(t, sqlstore.CreateUser(context.Background(), creator))
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: creator.Result.Id, PolicyID: policy.ID}))
+
+		key := &models.AddApiKeyCommand{OrgId: 1, Name: "evaluate-all", Role: models.ROLE_VIEWER, Key: "secret"}
+		require.NoError(t, sqlstore.AddApiKey(key))
+		keyPolicy := createPolicy(t, ac, 1, "key reader",
+			CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:uid:home"},
+			CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:uid:billing"},
+		)
+		require.NoError(t, ac.AddAPIKeyPolicy(context.Background(), AddAPIKeyPolicyCommand{
+			OrgID: 1, APIKeyID: key.Result.Id, PolicyID: keyPolicy.ID, CreatedBy: creator.Result.Id,
+		}))
+
+		keyUser := &models.SignedInUser{OrgId: 1, ApiKeyId: key.Result.Id, OrgRole: models.ROLE_VIEWER}
+		result, err := ac.EvaluateAll(context.Background(), keyUser, "dashboards:read", append(scopes, "dashboards:uid:billing"))
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{
+			"dashboards:uid:home":    true,
+			"dashboards:uid:ops":     false,
+			"dashboards:uid:billing": false,
+		}, result)
+	})
+
+	t.Run("Denials of actions that aren't enforced should be allowed", func(t *testing.T) {
+		require.NoError(t, ac.SetEnforcementMode(context.Background(), SetEnforcementModeCommand{Action: "dashboards:write", Mode: EnforcementModeDisabled}))
+		t.Cleanup(func() { require.NoError(t, ac.RemoveEnforcementMode(context.Background(), "dashboards:write")) })
+
+		result, err := ac.EvaluateAll(context.Background(), user, "dashboards:write", scopes)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{"dashboards:uid:home": true, "dashboards:uid:ops": true}, result)
+	})
+
+	t.Run("Each scope should go through the decision middlewares", func(t *testing.T) {
+		ac.RegisterDecisionMiddleware("deny-ops", func(next DecisionFunc) DecisionFunc {
+			return func(ctx context.Context, req DecisionRequest) (*Decision, error) {
+				if req.Evaluator.String() == "dashboards:read on dashboards:uid:ops" {
+					return &Decision{}, nil
+				}
+				return next(ctx, req)
+			}
+		})
+		t.Cleanup(func() {
+			ac.RegisterDecisionMiddleware("deny-ops", func(next DecisionFunc) DecisionFunc { return next })
+		})
+
+		result, err := ac.EvaluateAll(context.Background(), user, "dashboards:read", scopes)
+		require.NoError(t, err)
+		assert.Equal(t, map[string]bool{"dashboards:uid:home": true, "dashboards:uid:ops": false}, result)
+	})
+}
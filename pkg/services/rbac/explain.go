@@ -0,0 +1,96 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// AssignmentKindSuspension is the binding of the deny-all policy of a suspended user in access
+// explanations, see SuspendUserAccess.
+const AssignmentKindSuspension = "suspension"
+
+// ExplainAccess evaluates an access check of a user like Evaluate and tells which permissions match
+// it, through which policy and binding, and which of them the decision was taken on. Permissions
+// granted through several bindings are listed once per binding. Decision middlewares, API key
+// policies and delegated permissions are left out, the explanation is about the user's bindings.
+func (ac *RBACService) ExplainAccess(ctx context.Context, query ExplainAccessQuery) (*AccessExplanation, error) {
+	user := query.User
+	var rows []struct {
+		Permission `xorm:"extends"`
+		Precedence *int   `xorm:"precedence"`
+		PolicyUID  string `xorm:"policy_uid"`
+		PolicyName string `xorm:"policy_name"`
+		Binding    string `xorm:"binding"`
+		TeamID     int64  `xorm:"team_id"`
+		Role       string `xorm:"role"`
+	}
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q, args := userPermissionsQuery(GetUserPermissionsQuery{
+			OrgID: user.OrgId, UserID: user.UserId, Roles: BuiltinRoles(user),
+		}, time.Now(), true, false)
+		return sess.SQL(q, args...).Find(&rows)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	env := ac.environment(ctx, user)
+	req := accessRequest{
+		Action:     query.Action,
+		Scope:      query.Scope,
+		Attributes: env.Attributes,
+		Time:       env.Time,
+		RemoteAddr: env.RemoteAddr,
+		AuthTime:   env.AuthTime,
+		combining:  env.combining,
+	}
+
+	explanation := &AccessExplanation{Matches: []*PermissionMatch{}}
+	var permissions []Permission
+	var highest *int
+	for _, row := range rows {
+		p := row.Permission
+		p.Precedence = row.Precedence
+		// Scope keywords, folders and tags are resolved per permission to tell which one matches.
+		resolved, err := ac.resolvePermissions(ctx, user, []Permission{p})
+		if err != nil {
+			return nil, err
+		}
+		permissions = append(permissions, resolved...)
+
+		matches, applies := false, false
+		for _, r := range resolved {
+			if matchPattern(r.Action, req.Action) && matchPattern(r.Scope, req.Scope) {
+				matches = true
+				applies = applies || permissionApplies(r, req)
+			}
+		}
+		if !matches {
+			continue
+		}
+		if applies && comparePrecedence(p.Precedence, highest) > 0 {
+			highest = p.Precedence
+		}
+
+		explanation.Matches = append(explanation.Matches, &PermissionMatch{
+			PolicyID:        p.PolicyID,
+			PolicyUID:       row.PolicyUID,
+			PolicyName:      row.PolicyName,
+			Precedence:      p.Precedence,
+			Permission:      p,
+			Binding:         row.Binding,
+			TeamID:          row.TeamID,
+			Role:            row.Role,
+			ConditionsMatch: applies,
+		})
+	}
+
+	for _, m := range explanation.Matches {
+		m.Decisive = m.ConditionsMatch && comparePrecedence(m.Precedence, highest) == 0
+	}
+	explanation.Allowed = evaluatePermissions(permissions, req)
+
+	return explanation, nil
+}
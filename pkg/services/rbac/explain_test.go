@@ -0,0 +1,72 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestExplainAccess(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "contractors")
+	addTeamMember(t, 1, team.Id, 471)
+	reader := createPolicy(t, ac, 1, "dashboard reader", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 471, PolicyID: reader.ID}))
+	viewer := createPolicy(t, ac, 1, "secret viewer", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:uid:secret"})
+	require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: viewer.ID}))
+
+	precedence := 10
+	blocked, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "secret blocked", Precedence: &precedence})
+	require.NoError(t, err)
+	_, err = ac.CreatePermission(context.Background(), CreatePermissionCommand{
+		OrgID: blocked.OrgID, PolicyID: blocked.ID, Action: "dashboards:read", Scope: "dashboards:uid:secret", Kind: PermissionKindDeny,
+	})
+	require.NoError(t, err)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: blocked.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 471, OrgRole: models.ROLE_VIEWER}
+
+	t.Run("A denied check should be explained by the decisive deny", func(t *testing.T) {
+		explanation, err := ac.ExplainAccess(context.Background(), ExplainAccessQuery{User: user, Action: "dashboards:read", Scope: "dashboards:uid:secret"})
+		require.NoError(t, err)
+		assert.False(t, explanation.Allowed)
+		require.Len(t, explanation.Matches, 3)
+
+		byPolicy := map[int64]*PermissionMatch{}
+		for _, m := range explanation.Matches {
+			byPolicy[m.PolicyID] = m
+		}
+		assert.Equal(t, AssignmentKindUser, byPolicy[reader.ID].Binding)
+		assert.False(t, byPolicy[reader.ID].Decisive)
+		assert.Equal(t, AssignmentKindBuiltinRole, byPolicy[viewer.ID].Binding)
+		assert.Equal(t, "Viewer", byPolicy[viewer.ID].Role)
+		assert.False(t, byPolicy[viewer.ID].Decisive)
+		assert.Equal(t, AssignmentKindTeam, byPolicy[blocked.ID].Binding)
+		assert.Equal(t, team.Id, byPolicy[blocked.ID].TeamID)
+		assert.Equal(t, "secret blocked", byPolicy[blocked.ID].PolicyName)
+		assert.True(t, byPolicy[blocked.ID].Decisive)
+		assert.True(t, byPolicy[blocked.ID].Permission.IsDeny())
+	})
+
+	t.Run("An allowed check should be explained by the granting permissions", func(t *testing.T) {
+		explanation, err := ac.ExplainAccess(context.Background(), ExplainAccessQuery{User: user, Action: "dashboards:read", Scope: "dashboards:uid:home"})
+		require.NoError(t, err)
+		assert.True(t, explanation.Allowed)
+		require.Len(t, explanation.Matches, 1)
+		assert.Equal(t, reader.ID, explanation.Matches[0].PolicyID)
+		assert.Equal(t, "dashboards:*", explanation.Matches[0].Permission.Scope)
+		assert.True(t, explanation.Matches[0].Decisive)
+	})
+
+	t.Run("A check without matching permissions should be denied", func(t *testing.T) {
+		explanation, err := ac.ExplainAccess(context.Background(), ExplainAccessQuery{User: user, Action: "dashboards:write", Scope: "dashboards:uid:home"})
+		require.NoError(t, err)
+		assert.False(t, explanation.Allowed)
+		assert.Empty(t, explanation.Matches)
+	})
+}
@@ -0,0 +1,242 @@
+package rbac
+
+import (
+	"context"
+	"crypto/subtle"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"gopkg.in/yaml.v2"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// ExtAuthzTokenHeader is the header the proxy sends the ext_authz token in, the Authorization header
+// being the one of the request to authorize.
+const ExtAuthzTokenHeader = "X-Grafana-Ext-Authz-Token"
+
+var (
+	// ErrExtAuthzDisabled is returned when no ext_authz rules file is set.
+	ErrExtAuthzDisabled = errors.New("ext_authz is not enabled")
+	// ErrExtAuthzUnauthenticated is returned when the proxy doesn't send the ext_authz token.
+	ErrExtAuthzUnauthenticated = errors.New("missing or invalid ext_authz token")
+)
+
+// ExtAuthzRule maps the requests of a proxy with a method and path to an access check. Paths are
+// split in segments by /, a {name} segment matches any segment and a trailing * the rest of the
+// path. The scope may use the {name} segments of the path, e.g.
+//
+//	actions:
+//	  - action: orders:read
+//	    description: Read orders
+//	rules:
+//	  - methods: [GET, HEAD]
+//	    path: /api/orders/{id}
+//	    action: orders:read
+//	    scope: orders:id:{id}
+type ExtAuthzRule struct {
+	// Methods are the HTTP methods the rule matches, any when empty.
+	Methods []string `yaml:"methods"`
+	Path    string   `yaml:"path"`
+	Action  string   `yaml:"action"`
+	Scope   string   `yaml:"scope"`
+}
+
+// extAuthzRulesFile is the rules file of the ext_authz adapter, with the actions of the services behind
+// the proxy, registered so that policies can grant them.
+type extAuthzRulesFile struct {
+	Actions []ActionDefinition `yaml:"actions"`
+	Rules   []ExtAuthzRule     `yaml:"rules"`
+}
+
+// ExtAuthzRequest is a request forwarded by the ext_authz HTTP filter of Envoy for authorization.
+type ExtAuthzRequest struct {
+	// Token is the shared secret the proxy authenticates with.
+	Token  string
+	Method string
+	Path   string
+	// Login of the user, as authenticated by the proxy.
+	Login string
+	// OrgID of the request, the user's current organization when zero.
+	OrgID int64
+	// ForwardedFor are the X-Forwarded-For header values of the request, which the client address
+	// source network conditions are evaluated with is taken from.
+	ForwardedFor []string
+}
+
+// ExtAuthzDecision is the answer to a request forwarded by the proxy, with the reason of denials.
+type ExtAuthzDecision struct {
+	Allowed bool
+	Reason  string
+	// User is the user the access check was made for, nil when not found.
+	User *models.SignedInUser
+}
+
+// extAuthzSettings are the settings of the ext_authz adapter, see loadExtAuthzSettings.
+type extAuthzSettings struct {
+	token      string
+	userHeader string
+	orgHeader  string
+	rules      []ExtAuthzRule
+}
+
+// loadExtAuthzSettings reads the rules of the ext_authz adapter from the YAML file of the
+// rbac.ext_authz_rules_file setting and registers its actions, the adapter is disabled when it's empty. The proxy authenticates
+// with the token of the rbac.ext_authz_token setting, which is required, and sends the login of the
+// user and the organization in the headers of the rbac.ext_authz_user_header and
+// rbac.ext_authz_org_header settings.
+func (ac *RBACService) loadExtAuthzSettings() error {
+	section := ac.Cfg.Raw.Section("rbac")
+	ac.extAuthz = nil
+	path := strings.TrimSpace(section.Key("ext_authz_rules_file").MustString(""))
+	if path == "" {
+		return nil
+	}
+
+	settings := &extAuthzSettings{
+		token:      section.Key("ext_authz_token").MustString(""),
+		userHeader: section.Key("ext_authz_user_header").MustString("X-Forwarded-User"),
+		orgHeader:  section.Key("ext_authz_org_header").MustString("X-Grafana-Org-Id"),
+	}
+	if settings.token == "" {
+		return errors.New("rbac.ext_authz_token is required when rbac.ext_authz_rules_file is set")
+	}
+
+	data, err := ioutil.ReadFile(path)
+	if err != nil {
+		return fmt.Errorf("failed to read the ext_authz rules: %w", err)
+	}
+	var file extAuthzRulesFile
+	if err := yaml.Unmarshal(data, &file); err != nil {
+		return fmt.Errorf("failed to parse the ext_authz rules %s: %w", path, err)
+	}
+	for _, def := range file.Actions {
+		if err := validatePermission(def.Action, ""); err != nil {
+			return fmt.Errorf("invalid ext_authz action: %w", err)
+		}
+	}
+	RegisterActions(file.Actions...)
+	for i, rule := range file.Rules {
+		if !strings.HasPrefix(rule.Path, "/") {
+			return fmt.Errorf("invalid ext_authz rule %d: path %q must start with /", i, rule.Path)
+		}
+		if err := validatePermission(rule.Action, rule.Scope); err != nil {
+			return fmt.Errorf("invalid ext_authz rule %d: %w", i, err)
+		}
+		if err := validateRegisteredAction(rule.Action); err != nil {
+			return fmt.Errorf("invalid ext_authz rule %d: %w", i, err)
+		}
+	}
+	settings.rules = file.Rules
+
+	ac.extAuthz = settings
+	return nil
+}
+
+// ExtAuthzHeaders returns the headers the proxy sends the login of the user and the organization in,
+// see loadExtAuthzSettings. It returns ErrExtAuthzDisabled when the adapter isn't enabled.
+func (ac *RBACService) ExtAuthzHeaders() (string, string, error) {
+	if ac.extAuthz == nil {
+		return "", "", ErrExtAuthzDisabled
+	}
+	return ac.extAuthz.userHeader, ac.extAuthz.orgHeader, nil
+}
+
+// AuthorizeExtAuthz decides a request forwarded by the proxy with the access check of the first
+// rule matching its method and path. Requests without a matching rule or a known user are denied.
+func (ac *RBACService) AuthorizeExtAuthz(ctx context.Context, req ExtAuthzRequest) (*ExtAuthzDecision, error) {
+	settings := ac.extAuthz
+	if settings == nil {
+		return nil, ErrExtAuthzDisabled
+	}
+	if subtle.ConstantTimeCompare([]byte(req.Token), []byte(settings.token)) != 1 {
+		return nil, ErrExtAuthzUnauthenticated
+	}
+
+	if req.Login == "" {
+		return &ExtAuthzDecision{Reason: "unauthenticated user"}, nil
+	}
+	rule, params, ok := settings.match(req.Method, req.Path)
+	if !ok {
+		return &ExtAuthzDecision{Reason: "no rule matches the request"}, nil
+	}
+
+	user, err := ac.externalUser(req.OrgID, 0, req.Login)
+	if errors.Is(err, models.ErrUserNotFound) {
+		return &ExtAuthzDecision{Reason: "unknown user"}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	// The request comes from the proxy, which is trusted with the address of its client since it
+	// authenticated with the token. Source network conditions never match without one.
+	var clientAddr string
+	if ip := ac.forwardedClient(req.ForwardedFor, nil); ip != nil {
+		clientAddr = ip.String()
+	}
+	ctx = WithRemoteAddr(ctx, clientAddr)
+
+	scope := rule.Scope
+	for name, value := range params {
+		scope = strings.ReplaceAll(scope, "{"+name+"}", value)
+	}
+	allowed, err := ac.HasPermission(ctx, user, rule.Action, scope)
+	if err != nil {
+		return nil, err
+	}
+
+	decision := &ExtAuthzDecision{Allowed: allowed, User: user}
+	if !allowed {
+		decision.Reason = Perm(rule.Action, scope).String() + " is required"
+	}
+	return decision, nil
+}
+
+// match returns the first rule matching a method and path, with the values of its {name} segments.
+func (s *extAuthzSettings) match(method, path string) (ExtAuthzRule, map[string]string, bool) {
+	segments := strings.Split(strings.Trim(path, "/"), "/")
+	for _, rule := range s.rules {
+		if len(rule.Methods) > 0 && !containsFold(rule.Methods, method) {
+			continue
+		}
+		if params, ok := matchExtAuthzPath(strings.Split(strings.Trim(rule.Path, "/"), "/"), segments); ok {
+			return rule, params, true
+		}
+	}
+	return ExtAuthzRule{}, nil, false
+}
+
+func matchExtAuthzPath(pattern, segments []string) (map[string]string, bool) {
+	params := map[string]string{}
+	for i, p := range pattern {
+		if p == wildcard && i == len(pattern)-1 {
+			return params, len(segments) > i
+		}
+		if i >= len(segments) {
+			return nil, false
+		}
+		switch {
+		case strings.HasPrefix(p, "{") && strings.HasSuffix(p, "}"):
+			// The values of the path end up in scopes, they mustn't add segments or wildcards to them.
+			if segments[i] == "" || strings.ContainsAny(segments[i], segmentSeparator+wildcard) {
+				return nil, false
+			}
+			params[p[1:len(p)-1]] = segments[i]
+		case p != segments[i]:
+			return nil, false
+		}
+	}
+	return params, len(segments) == len(pattern)
+}
+
+func containsFold(values []string, value string) bool {
+	for _, v := range values {
+		if strings.EqualFold(v, value) {
+			return true
+		}
+	}
+	return false
+}
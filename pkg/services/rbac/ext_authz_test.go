@@ -0,0 +1,123 @@
+package rbac
+
+import (
+	"context"
+	"io/ioutil"
+	"path/filepath"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const testExtAuthzRules = `
+actions:
+  - action: orders:read
+    description: Read orders
+  - action: orders:create
+    description: Create orders
+  - action: reports:read
+    description: Read reports
+rules:
+  - methods: [GET, HEAD]
+    path: /api/orders/{id}
+    action: orders:read
+    scope: orders:id:{id}
+  - methods: [POST]
+    path: /api/orders
+    action: orders:create
+  - path: /api/reports/*
+    action: reports:read
+`
+
+func TestAuthorizeExtAuthz(t *testing.T) {
+	ac := setupTestEnv(t)
+	rulesFile := filepath.Join(t.TempDir(), "rules.yaml")
+	require.NoError(t, ioutil.WriteFile(rulesFile, []byte(testExtAuthzRules), 0600))
+	for key, value := range map[string]string{"ext_authz_rules_file": rulesFile, "ext_authz_token": "secret"} {
+		_, err := ac.Cfg.Raw.Section("rbac").NewKey(key, value)
+		require.NoError(t, err)
+	}
+	require.NoError(t, ac.loadExtAuthzSettings())
+
+	cmd := &models.CreateUserCommand{Login: "proxied-user"}
+	require.NoError(t, sqlstore.CreateUser(context.Background(), cmd))
+	user := cmd.Result
+	policy := createPolicy(t, ac, user.OrgId, "order reader",
+		CreatePermissionCommand{Action: "orders:read", Scope: "orders:id:1"},
+		CreatePermissionCommand{Action: "orders:read", Scope: "orders:id:2", Conditions: []Condition{{SourceNetworks: []string{"10.0.0.0/8"}}}},
+		CreatePermissionCommand{Action: "reports:read"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: user.OrgId, UserID: user.Id, PolicyID: policy.ID}))
+
+	authorize := func(t *testing.T, method, path string) *ExtAuthzDecision {
+		t.Helper()
+		decision, err := ac.AuthorizeExtAuthz(context.Background(), ExtAuthzRequest{Token: "secret", Method: method, Path: path, Login: user.Login})
+		require.NoError(t, err)
+		return decision
+	}
+
+	t.Run("Requests without the token should be rejected", func(t *testing.T) {
+		_, err := ac.AuthorizeExtAuthz(context.Background(), ExtAuthzRequest{Token: "guess", Method: "GET", Path: "/api/orders/1", Login: user.Login})
+		assert.ErrorIs(t, err, ErrExtAuthzUnauthenticated)
+	})
+
+	t.Run("Requests should be decided with the rule matching their method and path", func(t *testing.T) {
+		assert.True(t, authorize(t, "GET", "/api/orders/1").Allowed)
+		assert.True(t, authorize(t, "head", "/api/orders/1/").Allowed)
+		assert.True(t, authorize(t, "DELETE", "/api/reports/2021/q1").Allowed)
+
+		decision := authorize(t, "GET", "/api/orders/2")
+		assert.False(t, decision.Allowed)
+		assert.Contains(t, decision.Reason, "orders:id:2")
+		assert.False(t, authorize(t, "POST", "/api/orders").Allowed)
+	})
+
+	t.Run("Requests without a matching rule should be denied", func(t *testing.T) {
+		for _, req := range [][2]string{{"DELETE", "/api/orders/1"}, {"GET", "/api/orders/1/items"}, {"GET", "/api/reports"}, {"GET", "/api/orders/1:*"}} {
+			decision := authorize(t, req[0], req[1])
+			assert.False(t, decision.Allowed, req)
+			assert.Equal(t, "no rule matches the request", decision.Reason, req)
+		}
+	})
+
+	t.Run("Requests of unknown users or other organizations should be denied", func(t *testing.T) {
+		decision, err := ac.AuthorizeExtAuthz(context.Background(), ExtAuthzRequest{Token: "secret", Method: "GET", Path: "/api/orders/1", Login: "nobody"})
+		require.NoError(t, err)
+		assert.False(t, decision.Allowed)
+
+		decision, err = ac.AuthorizeExtAuthz(context.Background(), ExtAuthzRequest{Token: "secret", Method: "GET", Path: "/api/orders/1", Login: user.Login, OrgID: user.OrgId + 1})
+		require.NoError(t, err)
+		assert.False(t, decision.Allowed)
+	})
+
+	t.Run("Requests of disabled users should be denied", func(t *testing.T) {
+		require.NoError(t, sqlstore.DisableUser(&models.DisableUserCommand{UserId: user.Id, IsDisabled: true}))
+		t.Cleanup(func() {
+			require.NoError(t, sqlstore.DisableUser(&models.DisableUserCommand{UserId: user.Id, IsDisabled: false}))
+		})
+
+		decision := authorize(t, "GET", "/api/orders/1")
+		assert.False(t, decision.Allowed)
+		assert.Nil(t, decision.User)
+	})
+
+	t.Run("Source networks should be matched with the forwarded client address", func(t *testing.T) {
+		for forwardedFor, allowed := range map[string]bool{"": false, "10.1.2.3": true, "192.168.1.1": false, "10.1.2.3, 192.168.1.1": false} {
+			req := ExtAuthzRequest{Token: "secret", Method: "GET", Path: "/api/orders/2", Login: user.Login}
+			if forwardedFor != "" {
+				req.ForwardedFor = []string{forwardedFor}
+			}
+			decision, err := ac.AuthorizeExtAuthz(WithRemoteAddr(context.Background(), "10.0.0.1"), req)
+			require.NoError(t, err)
+			assert.Equal(t, allowed, decision.Allowed, forwardedFor)
+		}
+	})
+
+	t.Run("Invalid rules should fail to load", func(t *testing.T) {
+		require.NoError(t, ioutil.WriteFile(rulesFile, []byte("rules:\n  - path: /api/orders\n    action: orders:delete\n"), 0600))
+		assert.Error(t, ac.loadExtAuthzSettings())
+	})
+}
@@ -0,0 +1,247 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// AuthModuleSCIM is the auth module tracking the user policy bindings made through external groups,
+// so that they're unbound when the identity provider removes the group membership or mapping.
+const AuthModuleSCIM = "scim"
+
+// maxExternalGroupIDLength is the length of the group_id columns.
+const maxExternalGroupIDLength = 190
+
+// GetExternalGroupPolicies returns the policies bound to the members of an external group.
+func (ac *RBACService) GetExternalGroupPolicies(ctx context.Context, orgID int64, groupID string) ([]*PolicyDTO, error) {
+	var policies []*PolicyDTO
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := `SELECT policy.* FROM policy
+			INNER JOIN external_group_policy ON policy.id = external_group_policy.policy_id
+			WHERE external_group_policy.org_id = ? AND external_group_policy.group_id = ?
+			ORDER BY policy.name ASC`
+		return sess.SQL(q, orgID, groupID).Find(&policies)
+	})
+
+	return policies, err
+}
+
+// GetExternalGroupMembers returns the ids of the users the identity provider made members of an external group.
+func (ac *RBACService) GetExternalGroupMembers(ctx context.Context, orgID int64, groupID string) ([]int64, error) {
+	userIDs := []int64{}
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		return sess.Table("external_group_member").Where("org_id = ? AND group_id = ?", orgID, groupID).
+			Asc("user_id").Cols("user_id").Find(&userIDs)
+	})
+
+	return userIDs, err
+}
+
+// AddExternalGroupPolicy binds a policy to the current and future members of an external group.
+// Members already bound to the policy by other means keep that binding untracked.
+func (ac *RBACService) AddExternalGroupPolicy(ctx context.Context, cmd AddExternalGroupPolicyCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionExternalGroups); err != nil {
+		return err
+	}
+	if err := validateExternalGroupID(cmd.GroupID); err != nil {
+		return err
+	}
+
+	changes := newBindingChanges(0, BindingReasonSync)
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID}); err != nil {
+			return err
+		}
+
+		groupPolicy := &ExternalGroupPolicy{
+			OrgID:    cmd.OrgID,
+			GroupID:  cmd.GroupID,
+			PolicyID: cmd.PolicyID,
+			Created:  time.Now(),
+		}
+		if _, err := sess.Table("external_group_policy").Insert(groupPolicy); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrExternalGroupPolicyAlreadyAdded
+			}
+			return err
+		}
+
+		return ac.syncExternalGroupMembers(sess, cmd.OrgID, cmd.GroupID, nil, changes)
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// RemoveExternalGroupPolicy unbinds a policy from the members of an external group, unless it's
+// still bound to them through another of their groups.
+func (ac *RBACService) RemoveExternalGroupPolicy(ctx context.Context, cmd RemoveExternalGroupPolicyCommand) error {
+	changes := newBindingChanges(0, BindingReasonSync)
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		result, err := sess.Exec("DELETE FROM external_group_policy WHERE org_id = ? AND group_id = ? AND policy_id = ?",
+			cmd.OrgID, cmd.GroupID, cmd.PolicyID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return ErrExternalGroupPolicyNotFound
+		}
+
+		return ac.syncExternalGroupMembers(sess, cmd.OrgID, cmd.GroupID, nil, changes)
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// SetExternalGroupMembers replaces the members of an external group, it's meant to be called when
+// the identity provider pushes the group. The policies of the group are bound to the new members
+// and unbound from the removed ones.
+func (ac *RBACService) SetExternalGroupMembers(ctx context.Context, cmd SetExternalGroupMembersCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionExternalGroups); err != nil {
+		return err
+	}
+	if err := validateExternalGroupID(cmd.GroupID); err != nil {
+		return err
+	}
+
+	changes := newBindingChanges(0, BindingReasonSync)
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		previous, err := externalGroupMemberIDs(sess, cmd.OrgID, cmd.GroupID)
+		if err != nil {
+			return err
+		}
+		if _, err := sess.Exec("DELETE FROM external_group_member WHERE org_id = ? AND group_id = ?", cmd.OrgID, cmd.GroupID); err != nil {
+			return err
+		}
+
+		isMember := make(map[int64]bool, len(cmd.UserIDs))
+		for _, userID := range cmd.UserIDs {
+			if isMember[userID] {
+				continue
+			}
+			isMember[userID] = true
+			member := &ExternalGroupMember{OrgID: cmd.OrgID, GroupID: cmd.GroupID, UserID: userID, Created: time.Now()}
+			if _, err := sess.Table("external_group_member").Insert(member); err != nil {
+				return err
+			}
+		}
+
+		return ac.syncExternalGroupMembers(sess, cmd.OrgID, cmd.GroupID, previous, changes)
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// DeleteExternalGroup removes an external group, its policies are unbound from its members unless
+// they're still bound to them through another of their groups.
+func (ac *RBACService) DeleteExternalGroup(ctx context.Context, cmd DeleteExternalGroupCommand) error {
+	changes := newBindingChanges(0, BindingReasonSync)
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		members, err := externalGroupMemberIDs(sess, cmd.OrgID, cmd.GroupID)
+		if err != nil {
+			return err
+		}
+		if _, err := sess.Exec("DELETE FROM external_group_member WHERE org_id = ? AND group_id = ?", cmd.OrgID, cmd.GroupID); err != nil {
+			return err
+		}
+		if _, err := sess.Exec("DELETE FROM external_group_policy WHERE org_id = ? AND group_id = ?", cmd.OrgID, cmd.GroupID); err != nil {
+			return err
+		}
+
+		return ac.syncExternalGroupUsers(sess, members, changes)
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// syncExternalGroupMembers syncs the policies of the current members of an external group along
+// with the given former members.
+func (ac *RBACService) syncExternalGroupMembers(sess *sqlstore.DBSession, orgID int64, groupID string, formerUserIDs []int64, changes *bindingChanges) error {
+	members, err := externalGroupMemberIDs(sess, orgID, groupID)
+	if err != nil {
+		return err
+	}
+
+	return ac.syncExternalGroupUsers(sess, append(members, formerUserIDs...), changes)
+}
+
+// syncExternalGroupUsers binds to each user the policies of all the external groups they're a member
+// of, in every organization, and unbinds the ones those groups don't bind anymore.
+func (ac *RBACService) syncExternalGroupUsers(sess *sqlstore.DBSession, userIDs []int64, changes *bindingChanges) error {
+	if !ac.IsEnabled() {
+		return nil
+	}
+	sort.Slice(userIDs, func(i, j int) bool { return userIDs[i] < userIDs[j] })
+
+	for i, userID := range userIDs {
+		if i > 0 && userIDs[i-1] == userID {
+			continue
+		}
+
+		var rows []struct {
+			OrgID int64  `xorm:"org_id"`
+			UID   string `xorm:"uid"`
+		}
+		q := `SELECT DISTINCT external_group_member.org_id, policy.uid FROM external_group_member
+			INNER JOIN external_group_policy ON external_group_policy.org_id = external_group_member.org_id
+			AND external_group_policy.group_id = external_group_member.group_id
+			INNER JOIN policy ON policy.id = external_group_policy.policy_id
+			WHERE external_group_member.user_id = ?`
+		if err := sess.SQL(q, userID).Find(&rows); err != nil {
+			return err
+		}
+
+		policyUIDs := map[int64][]string{}
+		for _, row := range rows {
+			policyUIDs[row.OrgID] = append(policyUIDs[row.OrgID], row.UID)
+		}
+		cmd := &models.SyncUserPoliciesCommand{UserId: userID, AuthModule: AuthModuleSCIM, PolicyUIDs: policyUIDs}
+		added, removed, err := ac.syncUserPolicies(sess, cmd, changes)
+		if err != nil {
+			return err
+		}
+		if added > 0 || removed > 0 {
+			ac.log.Info("Synced external group policies", "userId", userID, "added", added, "removed", removed)
+		}
+	}
+
+	return nil
+}
+
+func externalGroupMemberIDs(sess *sqlstore.DBSession, orgID int64, groupID string) ([]int64, error) {
+	var userIDs []int64
+	err := sess.Table("external_group_member").Where("org_id = ? AND group_id = ?", orgID, groupID).Cols("user_id").Find(&userIDs)
+	return userIDs, err
+}
+
+func validateExternalGroupID(groupID string) error {
+	if groupID == "" || len(groupID) > maxExternalGroupIDLength {
+		return ErrInvalidExternalGroup
+	}
+	return nil
+}
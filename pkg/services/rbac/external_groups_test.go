@@ -0,0 +1,84 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestExternalGroups(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	const alice, bob = 311, 312
+	editors := createPolicy(t, ac, 1, "scim-editors", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+	viewers := createPolicy(t, ac, 1, "scim-viewers", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	manual := createPolicy(t, ac, 1, "scim-manual", CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: bob, PolicyID: manual.ID}))
+
+	userPolicyNames := func(userID int64) []string {
+		t.Helper()
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: userID})
+		require.NoError(t, err)
+		names := make([]string, 0, len(policies))
+		for _, p := range policies {
+			names = append(names, p.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+	setMembers := func(groupID string, userIDs ...int64) {
+		t.Helper()
+		require.NoError(t, ac.SetExternalGroupMembers(context.Background(), SetExternalGroupMembersCommand{OrgID: 1, GroupID: groupID, UserIDs: userIDs}))
+	}
+
+	require.NoError(t, ac.AddExternalGroupPolicy(context.Background(), AddExternalGroupPolicyCommand{OrgID: 1, GroupID: "editors", PolicyID: editors.ID}))
+	require.NoError(t, ac.AddExternalGroupPolicy(context.Background(), AddExternalGroupPolicyCommand{OrgID: 1, GroupID: "everyone", PolicyID: viewers.ID}))
+	require.NoError(t, ac.AddExternalGroupPolicy(context.Background(), AddExternalGroupPolicyCommand{OrgID: 1, GroupID: "everyone", PolicyID: manual.ID}))
+	setMembers("editors", alice)
+	setMembers("everyone", alice, bob, bob)
+
+	assert.Equal(t, []string{"scim-editors", "scim-manual", "scim-viewers"}, userPolicyNames(alice))
+	assert.Equal(t, []string{"scim-manual", "scim-viewers"}, userPolicyNames(bob))
+
+	members, err := ac.GetExternalGroupMembers(context.Background(), 1, "everyone")
+	require.NoError(t, err)
+	assert.Equal(t, []int64{alice, bob}, members)
+
+	t.Run("Adding a policy twice should fail", func(t *testing.T) {
+		err := ac.AddExternalGroupPolicy(context.Background(), AddExternalGroupPolicyCommand{OrgID: 1, GroupID: "editors", PolicyID: editors.ID})
+		require.ErrorIs(t, err, ErrExternalGroupPolicyAlreadyAdded)
+	})
+
+	t.Run("Invalid group ids should be rejected", func(t *testing.T) {
+		err := ac.SetExternalGroupMembers(context.Background(), SetExternalGroupMembersCommand{OrgID: 1, UserIDs: []int64{alice}})
+		require.ErrorIs(t, err, ErrInvalidExternalGroup)
+	})
+
+	t.Run("Members removed from a group should lose its policies", func(t *testing.T) {
+		setMembers("editors", bob)
+		assert.Equal(t, []string{"scim-manual", "scim-viewers"}, userPolicyNames(alice))
+		assert.Equal(t, []string{"scim-editors", "scim-manual", "scim-viewers"}, userPolicyNames(bob))
+	})
+
+	t.Run("Policies bound through another group should be kept", func(t *testing.T) {
+		require.NoError(t, ac.AddExternalGroupPolicy(context.Background(), AddExternalGroupPolicyCommand{OrgID: 1, GroupID: "editors", PolicyID: viewers.ID}))
+		require.NoError(t, ac.RemoveExternalGroupPolicy(context.Background(), RemoveExternalGroupPolicyCommand{OrgID: 1, GroupID: "editors", PolicyID: viewers.ID}))
+		assert.Equal(t, []string{"scim-editors", "scim-manual", "scim-viewers"}, userPolicyNames(bob))
+
+		err := ac.RemoveExternalGroupPolicy(context.Background(), RemoveExternalGroupPolicyCommand{OrgID: 1, GroupID: "editors", PolicyID: viewers.ID})
+		require.ErrorIs(t, err, ErrExternalGroupPolicyNotFound)
+	})
+
+	t.Run("Deleting a group should unbind its policies but not the ones bound by hand", func(t *testing.T) {
+		require.NoError(t, ac.DeleteExternalGroup(context.Background(), DeleteExternalGroupCommand{OrgID: 1, GroupID: "everyone"}))
+		assert.Equal(t, []string{}, userPolicyNames(alice))
+		assert.Equal(t, []string{"scim-editors", "scim-manual"}, userPolicyNames(bob))
+
+		policies, err := ac.GetExternalGroupPolicies(context.Background(), 1, "everyone")
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+}
@@ -0,0 +1,297 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/metadata"
+
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rbac/authzv1"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const (
+	defaultExternalPDPTimeout  = time.Second
+	defaultExternalPDPCacheTTL = 30 * time.Second
+)
+
+// policyDecisionPoint decides the access checks on the actions Grafana defers to an external
+// authorization service, see loadExternalPDPSettings.
+type policyDecisionPoint interface {
+	Authorize(ctx context.Context, req externalAccessCheck) (bool, error)
+}
+
+// externalAccessCheck is an access check deferred to an external policy decision point.
+type externalAccessCheck struct {
+	OrgID  int64  `json:"orgId"`
+	UserID int64  `json:"userId"`
+	Login  string `json:"login"`
+	// APIKeyID is set for the access checks of requests made with an API key, the user is then empty.
+	APIKeyID int64 `json:"apiKeyId,omitempty"`
+	// ServiceAccount is set when the user is a service account of the organization.
+	ServiceAccount bool   `json:"serviceAccount,omitempty"`
+	Action         string `json:"action"`
+	Scope          string `json:"scope"`
+}
+
+// externalPDP defers the access checks on the actions with one of its prefixes to a policy decision
+// point, the permissions of the users don't grant these actions then.
+type externalPDP struct {
+	pdp      policyDecisionPoint
+	prefixes []string
+	timeout  time.Duration
+	// failOpen allows the access checks the policy decision point fails to decide, they're denied otherwise.
+	failOpen bool
+	// cache caches the decisions of the policy decision point, nil when disabled.
+	cache *localcache.CacheService
+	log   log.Logger
+}
+
+// loadExternalPDPSettings defers the access checks on the actions with the prefixes of the
+// rbac.external_pdp_actions setting, e.g. "reports:,billing:", to the policy decision point at the URL of
+// the rbac.external_pdp_url setting. An http or https URL is sent the access checks as JSON and must
+// answer {"allowed": true} or {"allowed": false}, a grpc or grpcs URL must serve the Authorization
+// service of authzv1. The access checks it doesn't decide within rbac.external_pdp_timeout are denied,
+// or allowed when rbac.external_pdp_fail_open is set, and its decisions are cached for
+// rbac.external_pdp_cache_ttl. The SQL filters of Filter can't ask it about every resource, they're
+// built from the permissions of the users, so the actions of searches shouldn't be deferred.
+func (ac *RBACService) loadExternalPDPSettings() error {
+	section := ac.Cfg.Raw.Section("rbac")
+	ac.externalPDP = nil
+	rawURL := strings.TrimSpace(section.Key("external_pdp_url").MustString(""))
+	if rawURL == "" {
+		return nil
+	}
+
+	var prefixes []string
+	for _, prefix := range strings.Split(section.Key("external_pdp_actions").MustString(""), ",") {
+		if prefix = strings.TrimSpace(prefix); prefix != "" {
+			prefixes = append(prefixes, prefix)
+		}
+	}
+	if len(prefixes) == 0 {
+		return errors.New("rbac.external_pdp_actions is required when rbac.external_pdp_url is set")
+	}
+
+	pdp, err := newPolicyDecisionPoint(rawURL, section.Key("external_pdp_token").MustString(""))
+	if err != nil {
+		return err
+	}
+	external := &externalPDP{
+		pdp:      pdp,
+		prefixes: prefixes,
+		timeout:  section.Key("external_pdp_timeout").MustDuration(defaultExternalPDPTimeout),
+		failOpen: section.Key("external_pdp_fail_open").MustBool(false),
+		log:      log.New("rbac.pdp"),
+	}
+	if ttl := section.Key("external_pdp_cache_ttl").MustDuration(defaultExternalPDPCacheTTL); ttl > 0 {
+		external.cache = localcache.New(ttl, 2*ttl)
+	}
+
+	ac.externalPDP = external
+	return nil
+}
+
+// newPolicyDecisionPoint returns the client of the policy decision point at a URL, see loadExternalPDPSettings.
+func newPolicyDecisionPoint(rawURL, token string) (policyDecisionPoint, error) {
+	u, err := url.Parse(rawURL)
+	if err != nil {
+		return nil, fmt.Errorf("invalid rbac.external_pdp_url: %w", err)
+	}
+
+	switch u.Scheme {
+	case "http", "https":
+		return &httpPDP{url: rawURL, token: token, client: &http.Client{}}, nil
+	case "grpc", "grpcs":
+		creds := grpc.WithInsecure()
+		if u.Scheme == "grpcs" {
+			creds = grpc.WithTransportCredentials(credentials.NewTLS(&tls.Config{MinVersion: tls.VersionTLS12}))
+		}
+		// The connection is established lazily, by the first access check.
+		conn, err := grpc.Dial(u.Host, creds)
+		if err != nil {
+			return nil, fmt.Errorf("failed to connect to the external policy decision point: %w", err)
+		}
+		return &grpcPDP{client: authzv1.NewAuthorizationClient(conn), token: token}, nil
+	default:
+		return nil, fmt.Errorf("invalid rbac.external_pdp_url: unsupported scheme %q", u.Scheme)
+	}
+}
+
+// httpPDP is a policy decision point answering access checks posted as JSON.
+type httpPDP struct {
+	url    string
+	token  string
+	client *http.Client
+}
+
+func (p *httpPDP) Authorize(ctx context.Context, check externalAccessCheck) (bool, error) {
+	body, err := json.Marshal(check)
+	if err != nil {
+		return false, err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, p.url, bytes.NewReader(body))
+	if err != nil {
+		return false, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	if p.token != "" {
+		req.Header.Set("Authorization", "Bearer "+p.token)
+	}
+
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return false, err
+	}
+	defer func() { _ = resp.Body.Close() }()
+	if resp.StatusCode != http.StatusOK {
+		return false, fmt.Errorf("unexpected status %d", resp.StatusCode)
+	}
+
+	var result struct {
+		Allowed *bool `json:"allowed"`
+	}
+	if err := json.NewDecoder(resp.Body).Decode(&result); err != nil {
+		return false, err
+	}
+	if result.Allowed == nil {
+		return false, errors.New("the response has no decision")
+	}
+	return *result.Allowed, nil
+}
+
+// grpcPDP is a policy decision point serving the Authorization service of authzv1, like Grafana does.
+type grpcPDP struct {
+	client authzv1.AuthorizationClient
+	token  string
+}
+
+func (p *grpcPDP) Authorize(ctx context.Context, check externalAccessCheck) (bool, error) {
+	if p.token != "" {
+		ctx = metadata.AppendToOutgoingContext(ctx, "authorization", "Bearer "+p.token)
+	}
+	res, err := p.client.CheckAccess(ctx, &authzv1.CheckAccessRequest{
+		User: &authzv1.User{
+			OrgId: check.OrgID, UserId: check.UserID, Login: check.Login, ApiKeyId: check.APIKeyID, ServiceAccount: check.ServiceAccount,
+		},
+		Action: check.Action,
+		Scope:  check.Scope,
+	})
+	if err != nil {
+		return false, err
+	}
+	return res.Allowed, nil
+}
+
+// defers returns true if the access checks on the action are deferred to the policy decision point.
+func (e *externalPDP) defers(action string) bool {
+	for _, prefix := range e.prefixes {
+		if strings.HasPrefix(action, prefix) {
+			return true
+		}
+	}
+	return false
+}
+
+// decide returns the decision of the policy decision point on an access check, or whether it fails open
+// when it can't decide. The decisions are cached per user, API key and service account.
+func (e *externalPDP) decide(ctx context.Context, check externalAccessCheck) bool {
+	key := fmt.Sprintf("%d:%d:%d:%t:%s:%s", check.OrgID, check.UserID, check.APIKeyID, check.ServiceAccount, check.Action, check.Scope)
+	if e.cache != nil {
+		if allowed, ok := e.cache.Get(key); ok {
+			return allowed.(bool)
+		}
+	}
+
+	ctx, cancel := context.WithTimeout(ctx, e.timeout)
+	defer cancel()
+	allowed, err := e.pdp.Authorize(ctx, check)
+	if err != nil {
+		e.log.Warn("The external policy decision point failed to decide", "userId", check.UserID, "apiKeyId", check.APIKeyID,
+			"orgId", check.OrgID, "action", check.Action, "scope", check.Scope, "failOpen", e.failOpen, "error", err)
+		return e.failOpen
+	}
+
+	if e.cache != nil {
+		e.cache.Set(key, allowed, 0)
+	}
+	return allowed
+}
+
+// externalDecisions binds the policy decision point to the access checks of a user, for the environment
+// of their evaluation. It records whether any of them was deferred, so that their denials aren't cached
+// beyond the decisions of the policy decision point.
+type externalDecisions struct {
+	ctx      context.Context
+	user     *models.SignedInUser
+	pdp      *externalPDP
+	deferred bool
+	// identity is the user of the access checks sent to the policy decision point, looked up on the
+	// first deferred access check with lookupIdentity.
+	identity       *externalAccessCheck
+	lookupIdentity func(ctx context.Context, user *models.SignedInUser) (externalAccessCheck, error)
+}
+
+// externalDecisions returns the policy decision point of the access checks of a user, nil when disabled.
+func (ac *RBACService) externalDecisions(ctx context.Context, user *models.SignedInUser) *externalDecisions {
+	if ac.externalPDP == nil {
+		return nil
+	}
+	return &externalDecisions{ctx: ctx, user: user, pdp: ac.externalPDP, lookupIdentity: ac.externalIdentity}
+}
+
+// externalIdentity returns the user of the access checks sent to the policy decision point: the user,
+// or the API key of requests made with one, and whether the user is a service account.
+func (ac *RBACService) externalIdentity(ctx context.Context, user *models.SignedInUser) (externalAccessCheck, error) {
+	identity := externalAccessCheck{OrgID: user.OrgId, UserID: user.UserId, Login: user.Login, APIKeyID: user.ApiKeyId}
+	if user.UserId <= 0 {
+		return identity, nil
+	}
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		identity.ServiceAccount, err = isServiceAccount(sess, user.OrgId, user.UserId)
+		return err
+	})
+	return identity, err
+}
+
+// decide returns the decision of the policy decision point and true if the access check on the action
+// is deferred to it, false otherwise.
+func (d *externalDecisions) decide(action, scope string) (bool, bool) {
+	if d == nil || !d.pdp.defers(action) {
+		return false, false
+	}
+	d.deferred = true
+	if d.identity == nil {
+		identity, err := d.lookupIdentity(d.ctx, d.user)
+		if err != nil {
+			d.pdp.log.Warn("Failed to look up the user of an access check deferred to the external policy decision point",
+				"userId", d.user.UserId, "orgId", d.user.OrgId, "failOpen", d.pdp.failOpen, "error", err)
+			return d.pdp.failOpen, true
+		}
+		d.identity = &identity
+	}
+
+	check := *d.identity
+	check.Action = action
+	check.Scope = scope
+	return d.pdp.decide(d.ctx, check), true
+}
+
+// wasDeferred returns true if any of the access checks was deferred to the policy decision point.
+func (d *externalDecisions) wasDeferred() bool {
+	return d != nil && d.deferred
+}
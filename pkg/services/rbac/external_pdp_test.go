@@ -0,0 +1,158 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/test/bufconn"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/rbac/authzv1"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestExternalPDP(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	var calls int32
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		atomic.AddInt32(&calls, 1)
+		if r.Header.Get("Authorization") != "Bearer secret" {
+			w.WriteHeader(http.StatusUnauthorized)
+			return
+		}
+		var check externalAccessCheck
+		if err := json.NewDecoder(r.Body).Decode(&check); err != nil {
+			w.WriteHeader(http.StatusBadRequest)
+			return
+		}
+		switch check.Scope {
+		case "dashboards:uid:broken":
+			w.WriteHeader(http.StatusInternalServerError)
+			return
+		case "dashboards:uid:slow":
+			time.Sleep(200 * time.Millisecond)
+		}
+		allowed := check.Scope == "dashboards:uid:public"
+		switch check.Scope {
+		case "dashboards:uid:key":
+			allowed = check.APIKeyID == 1
+		case "dashboards:uid:service-accounts":
+			allowed = check.ServiceAccount
+		}
+		_ = json.NewEncoder(w).Encode(map[string]bool{"allowed": allowed})
+	}))
+	t.Cleanup(server.Close)
+
+	for key, value := range map[string]string{
+		"external_pdp_url": server.URL, "external_pdp_actions": "dashboards:", "external_pdp_token": "secret", "external_pdp_timeout": "50ms",
+	} {
+		_, err := ac.Cfg.Raw.Section("rbac").NewKey(key, value)
+		require.NoError(t, err)
+	}
+	require.NoError(t, ac.loadExternalPDPSettings())
+
+	cmd := &models.CreateUserCommand{Login: "pdp-user"}
+	require.NoError(t, sqlstore.CreateUser(context.Background(), cmd))
+	user := &models.SignedInUser{OrgId: cmd.Result.OrgId, UserId: cmd.Result.Id, Login: cmd.Result.Login, OrgRole: models.ROLE_VIEWER}
+	policy := createPolicy(t, ac, user.OrgId, "reader",
+		CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"},
+		CreatePermissionCommand{Action: ActionTeamsRead, Scope: "teams:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: user.OrgId, UserID: user.UserId, PolicyID: policy.ID}))
+
+	hasPermission := func(t *testing.T, action, scope string) bool {
+		t.Helper()
+		allowed, err := ac.HasPermission(context.Background(), user, action, scope)
+		require.NoError(t, err)
+		return allowed
+	}
+
+	t.Run("The external policy decision point should decide the deferred actions", func(t *testing.T) {
+		assert.True(t, hasPermission(t, ActionDashboardsRead, "dashboards:uid:public"))
+		assert.False(t, hasPermission(t, ActionDashboardsRead, "dashboards:uid:private"), "the permissions of the user shouldn't grant deferred actions")
+	})
+
+	t.Run("The decisions of the external policy decision point should be cached", func(t *testing.T) {
+		before := atomic.LoadInt32(&calls)
+		assert.True(t, hasPermission(t, ActionDashboardsRead, "dashboards:uid:public"))
+		assert.Equal(t, before, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("The other actions should be decided by the permissions of the user", func(t *testing.T) {
+		before := atomic.LoadInt32(&calls)
+		assert.True(t, hasPermission(t, ActionTeamsRead, "teams:id:1"))
+		assert.Equal(t, before, atomic.LoadInt32(&calls))
+	})
+
+	t.Run("Access checks the external policy decision point fails to decide should be denied", func(t *testing.T) {
+		assert.False(t, hasPermission(t, ActionDashboardsRead, "dashboards:uid:broken"))
+		assert.False(t, hasPermission(t, ActionDashboardsRead, "dashboards:uid:slow"))
+	})
+
+	t.Run("Access checks the external policy decision point fails to decide should be allowed when failing open", func(t *testing.T) {
+		ac.externalPDP.failOpen = true
+		t.Cleanup(func() { ac.externalPDP.failOpen = false })
+		assert.True(t, hasPermission(t, ActionDashboardsRead, "dashboards:uid:broken"))
+	})
+
+	t.Run("API keys should be decided and cached apart from each other", func(t *testing.T) {
+		for keyID, allowed := range map[int64]bool{1: true, 2: false} {
+			key := &models.SignedInUser{OrgId: user.OrgId, ApiKeyId: keyID, OrgRole: models.ROLE_VIEWER}
+			for i := 0; i < 2; i++ {
+				ok, err := ac.HasPermission(context.Background(), key, ActionDashboardsRead, "dashboards:uid:key")
+				require.NoError(t, err)
+				assert.Equal(t, allowed, ok, "API key %d", keyID)
+			}
+		}
+	})
+
+	t.Run("Service accounts should be told apart", func(t *testing.T) {
+		assert.False(t, hasPermission(t, ActionDashboardsRead, "dashboards:uid:service-accounts"))
+
+		cmd := &models.CreateUserCommand{Login: "pdp-service-account"}
+		require.NoError(t, sqlstore.CreateUser(context.Background(), cmd))
+		_, err := ac.CreateServiceAccount(context.Background(), CreateServiceAccountCommand{OrgID: cmd.Result.OrgId, UserID: cmd.Result.Id})
+		require.NoError(t, err)
+		serviceAccount := &models.SignedInUser{OrgId: cmd.Result.OrgId, UserId: cmd.Result.Id, Login: cmd.Result.Login}
+		ok, err := ac.HasPermission(context.Background(), serviceAccount, ActionDashboardsRead, "dashboards:uid:service-accounts")
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+
+	t.Run("A gRPC external policy decision point should decide the deferred actions", func(t *testing.T) {
+		listener := bufconn.Listen(1024 * 1024)
+		grpcServer := grpc.NewServer()
+		authzv1.RegisterAuthorizationServer(grpcServer, &fakeAuthorizationServer{allowed: "dashboards:uid:grpc"})
+		go func() { _ = grpcServer.Serve(listener) }()
+		t.Cleanup(grpcServer.Stop)
+		conn, err := grpc.Dial("bufnet", grpc.WithInsecure(), grpc.WithContextDialer(func(context.Context, string) (net.Conn, error) {
+			return listener.Dial()
+		}))
+		require.NoError(t, err)
+		t.Cleanup(func() { _ = conn.Close() })
+
+		pdp := ac.externalPDP.pdp
+		ac.externalPDP.pdp = &grpcPDP{client: authzv1.NewAuthorizationClient(conn)}
+		t.Cleanup(func() { ac.externalPDP.pdp = pdp })
+		assert.True(t, hasPermission(t, ActionDashboardsRead, "dashboards:uid:grpc"))
+		assert.False(t, hasPermission(t, ActionDashboardsRead, "dashboards:uid:other"))
+	})
+}
+
+type fakeAuthorizationServer struct {
+	authzv1.UnimplementedAuthorizationServer
+	allowed string
+}
+
+func (s fakeAuthorizationServer) CheckAccess(_ context.Context, req *authzv1.CheckAccessRequest) (*authzv1.CheckAccessResponse, error) {
+	return &authzv1.CheckAccessResponse{Allowed: req.Scope == s.allowed}, nil
+}
@@ -0,0 +1,284 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+)
+
+// SQLFilter is a condition to embed in the WHERE clause of a list query, with its arguments.
+type SQLFilter struct {
+	Where string
+	Args  []interface{}
+}
+
+var (
+	sqlTrue  = SQLFilter{Where: "1 = 1"}
+	sqlFalse = SQLFilter{Where: "1 = 0"}
+)
+
+// Filter returns the condition restricting a list query to the resources the user is granted an action
+// on, so that the rows don't have to be checked one by one. The resources are identified by a scope
+// prefix, e.g. dashboards:uid:, and the column holding the rest of their scope, e.g. dashboard.uid:
+//
+//	filter, err := ac.Filter(ctx, user, rbac.ActionDashboardsRead, "dashboards:uid:", "dashboard.uid")
+//	sess.Where(filter.Where, filter.Args...)
+//
+// The filter follows the precedence and the combining algorithm of the action, see evaluatePermissions.
+// The conditions of a permission are evaluated against the request, those naming attributes of the
+// resources can't be: such allow permissions are left out and such deny permissions apply to every
+// resource they match. Wildcard identifiers are matched with LIKE, or GLOB on SQLite, so the column must
+// be textual for them.
+func (ac *RBACService) Filter(ctx context.Context, user *models.SignedInUser, action, scopePrefix, column string) (SQLFilter, error) {
+	return ac.filter(ctx, user, action, scopeColumn{prefix: scopePrefix, column: column})
+}
+
+// scopeColumn is a scope prefix of the resources of a list query and the column holding the rest of
+// their scope.
+type scopeColumn struct {
+	prefix string
+	column string
+}
+
+// filter is Filter for resources with several scopes, e.g. folders:uid: and folders:id:. A permission
+// matches a resource when it matches any of its scopes.
+func (ac *RBACService) filter(ctx context.Context, user *models.SignedInUser, action string, columns ...scopeColumn) (SQLFilter, error) {
+	granting, err := ac.resolveGrantingPermissions(ctx, user)
+	if err != nil {
+		return SQLFilter{}, err
+	}
+	if len(granting.sets) == 0 {
+		return sqlFalse, nil
+	}
+
+	env := ac.environment(ctx, user)
+	filters := make([]SQLFilter, 0, len(granting.sets))
+	for _, permissions := range granting.sets {
+		filters = append(filters, ac.permissionsFilter(permissions, env, action, columns...))
+	}
+	if len(filters) == 1 {
+		return filters[0], nil
+	}
+	return sqlAnd(filters...), nil
+}
+
+// precedenceGroup holds the permissions of a precedence that apply to an action.
+type precedenceGroup struct {
+	precedence  *int
+	permissions []Permission
+}
+
+// permissionsFilter returns the condition matching the resources a set of permissions grants the
+// action on. Going from the highest precedence down, a resource is decided by the first precedence
+// whose permissions match it: d1 OR (NOT m1 AND (d2 OR (NOT m2 AND ...))).
+func (ac *RBACService) permissionsFilter(permissions []Permission, env Environment, action string, columns ...scopeColumn) SQLFilter {
+	req := accessRequest{
+		Action: action, Attributes: env.Attributes, Time: env.Time, RemoteAddr: env.RemoteAddr, AuthTime: env.AuthTime,
+	}
+
+	var groups []*precedenceGroup
+	for _, p := range permissions {
+		if !matchPattern(p.Action, action) || !filterConditionsApply(p, req) {
+			continue
+		}
+		var group *precedenceGroup
+		for _, g := range groups {
+			if comparePrecedence(g.precedence, p.Precedence) == 0 {
+				group = g
+				break
+			}
+		}
+		if group == nil {
+			group = &precedenceGroup{precedence: p.Precedence}
+			groups = append(groups, group)
+		}
+		group.permissions = append(group.permissions, p)
+	}
+	sort.Slice(groups, func(i, j int) bool {
+		return comparePrecedence(groups[i].precedence, groups[j].precedence) > 0
+	})
+
+	algorithm := env.combining.forAction(action)
+	filter := sqlFalse
+	for i := len(groups) - 1; i >= 0; i-- {
+		decided, matched := ac.groupFilter(groups[i].permissions, algorithm, columns)
+		filter = sqlOr(decided, sqlAnd(sqlNot(matched), filter))
+	}
+
+	return filter
+}
+
+// filterConditionsApply returns true if a permission applies to the request as far as its conditions
+// can tell without the attributes of the resources, see Filter.
+func filterConditionsApply(p Permission, req accessRequest) bool {
+	for _, c := range p.Conditions {
+		if c.Attribute != "" && len(req.Attributes[c.Attribute]) == 0 {
+			if !p.IsDeny() {
+				return false
+			}
+			continue
+		}
+		if !c.matches(req) {
+			return false
+		}
+	}
+
+	return true
+}
+
+// groupFilter returns the conditions matching the resources the permissions of a precedence grant
+// access to, and those they match at all.
+func (ac *RBACService) groupFilter(permissions []Permission, algorithm string, columns []scopeColumn) (SQLFilter, SQLFilter) {
+	var allows, denies []Permission
+	for _, p := range permissions {
+		if p.IsDeny() {
+			denies = append(denies, p)
+		} else {
+			allows = append(allows, p)
+		}
+	}
+	allowed := ac.scopesFilter(allows, columns)
+	denied := ac.scopesFilter(denies, columns)
+	matched := sqlOr(allowed, denied)
+
+	switch algorithm {
+	case CombiningAllowOverrides:
+		return allowed, matched
+	case CombiningFirstApplicable:
+		sorted := append([]Permission(nil), permissions...)
+		sort.SliceStable(sorted, func(i, j int) bool { return createdBefore(sorted[i], sorted[j]) })
+		decided, earlier := sqlFalse, sqlFalse
+		for _, p := range sorted {
+			match := ac.scopesFilter([]Permission{p}, columns)
+			if !p.IsDeny() {
+				decided = sqlOr(decided, sqlAnd(match, sqlNot(earlier)))
+			}
+			earlier = sqlOr(earlier, match)
+		}
+		return decided, matched
+	default:
+		return sqlAnd(allowed, sqlNot(denied)), matched
+	}
+}
+
+// scopesFilter returns the condition matching the resources any of the permissions' scopes matches
+// through any of their columns.
+func (ac *RBACService) scopesFilter(permissions []Permission, columns []scopeColumn) SQLFilter {
+	filters := make([]SQLFilter, 0, len(columns))
+	for _, c := range columns {
+		filters = append(filters, ac.columnScopesFilter(permissions, c.prefix, c.column))
+	}
+	return sqlOr(filters...)
+}
+
+// columnScopesFilter returns the condition matching the resources any of the permissions' scopes
+// matches, with the exact identifiers in a single IN list.
+func (ac *RBACService) columnScopesFilter(permissions []Permission, scopePrefix, column string) SQLFilter {
+	var values []interface{}
+	var filters []SQLFilter
+	for _, p := range permissions {
+		idx := strings.Index(p.Scope, wildcard)
+		if idx == -1 {
+			if strings.HasPrefix(p.Scope, scopePrefix) {
+				values = append(values, strings.TrimPrefix(p.Scope, scopePrefix))
+			}
+			continue
+		}
+
+		prefix := p.Scope[:idx]
+		switch {
+		case len(prefix) <= len(scopePrefix) && strings.HasPrefix(scopePrefix, prefix):
+			// e.g. dashboards:* matches every dashboard.
+			return sqlTrue
+		case len(prefix) > len(scopePrefix) && strings.HasPrefix(prefix, scopePrefix):
+			filters = append(filters, ac.prefixFilter(column, prefix[len(scopePrefix):]))
+		}
+	}
+
+	if len(values) > 0 {
+		filters = append(filters, SQLFilter{
+			Where: column + " IN (?" + strings.Repeat(",?", len(values)-1) + ")",
+			Args:  values,
+		})
+	}
+	return sqlOr(filters...)
+}
+
+// prefixFilter returns the condition matching the values of a column longer than a prefix and
+// starting with it, like matchPattern does. LIKE ignores case on SQLite and MySQL by default, so GLOB
+// and a binary comparison are used on them.
+func (ac *RBACService) prefixFilter(column, prefix string) SQLFilter {
+	switch ac.SQLStore.Dialect.DriverName() {
+	case migrator.SQLite:
+		escaped := strings.NewReplacer("[", "[[]", "*", "[*]", "?", "[?]").Replace(prefix)
+		return SQLFilter{Where: column + " GLOB ?", Args: []interface{}{escaped + "?*"}}
+	case migrator.MySQL:
+		return SQLFilter{Where: column + " LIKE BINARY ? ESCAPE '!'", Args: []interface{}{escapeLike(prefix) + "_%"}}
+	default:
+		return SQLFilter{Where: column + " LIKE ? ESCAPE '!'", Args: []interface{}{escapeLike(prefix) + "_%"}}
+	}
+}
+
+// textColumn returns the expression of a numeric column as text, e.g. to match it against the
+// identifiers of scopes.
+func (ac *RBACService) textColumn(column string) string {
+	if ac.SQLStore.Dialect.DriverName() == migrator.MySQL {
+		return "CAST(" + column + " AS CHAR)"
+	}
+	return "CAST(" + column + " AS TEXT)"
+}
+
+func escapeLike(value string) string {
+	return strings.NewReplacer("!", "!!", "%", "!%", "_", "!_").Replace(value)
+}
+
+func sqlOr(filters ...SQLFilter) SQLFilter {
+	return joinFilters(" OR ", sqlFalse, sqlTrue, filters)
+}
+
+func sqlAnd(filters ...SQLFilter) SQLFilter {
+	return joinFilters(" AND ", sqlTrue, sqlFalse, filters)
+}
+
+// joinFilters joins conditions with an operator, leaving out the neutral ones and short-circuiting
+// on an absorbing one, e.g. 1 = 0 and 1 = 1 for OR.
+func joinFilters(operator string, neutral, absorbing SQLFilter, filters []SQLFilter) SQLFilter {
+	var kept []SQLFilter
+	for _, f := range filters {
+		switch f.Where {
+		case neutral.Where:
+			continue
+		case absorbing.Where:
+			return absorbing
+		}
+		kept = append(kept, f)
+	}
+
+	switch len(kept) {
+	case 0:
+		return neutral
+	case 1:
+		return kept[0]
+	}
+	parts := make([]string, 0, len(kept))
+	var args []interface{}
+	for _, f := range kept {
+		parts = append(parts, "("+f.Where+")")
+		args = append(args, f.Args...)
+	}
+	return SQLFilter{Where: strings.Join(parts, operator), Args: args}
+}
+
+func sqlNot(filter SQLFilter) SQLFilter {
+	switch filter.Where {
+	case sqlTrue.Where:
+		return sqlFalse
+	case sqlFalse.Where:
+		return sqlTrue
+	default:
+		return SQLFilter{Where: "NOT (" + filter.Where + ")", Args: filter.Args}
+	}
+}
@@ -0,0 +1,80 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestFilter(t *testing.T) {
+	ac := setupTestEnv(t)
+	alpha := createTeam(t, 1, "alpha")
+	beta := createTeam(t, 1, "beta")
+	createTeam(t, 1, "gam_ma")
+	createTeam(t, 1, "gamma")
+
+	teams := func(t *testing.T, filter SQLFilter) []string {
+		t.Helper()
+		var names []string
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			return sess.Table("team").Where("org_id = ?", 1).And(filter.Where, filter.Args...).Asc("name").Cols("name").Find(&names)
+		})
+		require.NoError(t, err)
+		return names
+	}
+	byName := func(algorithm string, permissions ...Permission) SQLFilter {
+		env := Environment{combining: &combiningAlgorithms{defaultAlgorithm: algorithm}}
+		return ac.permissionsFilter(permissions, env, ActionTeamsRead, scopeColumn{prefix: "teams:name:", column: "team.name"})
+	}
+
+	t.Run("Only the teams the user is granted access to should be listed", func(t *testing.T) {
+		policy := createPolicy(t, ac, 1, "team reader",
+			CreatePermissionCommand{Action: ActionTeamsRead, Scope: "teams:*"},
+			CreatePermissionCommand{Action: ActionTeamsRead, Scope: ScopeTeamID(beta.Id), Kind: PermissionKindDeny})
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 496, PolicyID: policy.ID}))
+
+		filter, err := ac.Filter(context.Background(), &models.SignedInUser{OrgId: 1, UserId: 496}, ActionTeamsRead, "teams:id:", "team.id")
+		require.NoError(t, err)
+		assert.Equal(t, []string{"alpha", "gam_ma", "gamma"}, teams(t, filter))
+
+		filter, err = ac.Filter(context.Background(), &models.SignedInUser{OrgId: 1, UserId: 497}, ActionTeamsRead, "teams:id:", "team.id")
+		require.NoError(t, err)
+		assert.Empty(t, teams(t, filter))
+	})
+
+	t.Run("Wildcard identifiers should match literally", func(t *testing.T) {
+		assert.Equal(t, []string{"gam_ma"}, teams(t, byName(CombiningDenyOverrides, Permission{Action: ActionTeamsRead, Scope: "teams:name:gam_*"})))
+		assert.Empty(t, teams(t, byName(CombiningDenyOverrides, Permission{Action: ActionTeamsRead, Scope: "teams:name:gamma*"})))
+	})
+
+	t.Run("Precedence and combining algorithms should apply", func(t *testing.T) {
+		high := 10
+		permissions := []Permission{
+			{ID: 1, Action: ActionTeamsRead, Scope: "teams:name:alpha", Kind: PermissionKindDeny},
+			{ID: 2, Action: "teams:*", Scope: "teams:*"},
+			{ID: 3, Action: ActionTeamsRead, Scope: "teams:name:beta", Kind: PermissionKindDeny, Precedence: &high},
+		}
+
+		assert.Equal(t, []string{"gam_ma", "gamma"}, teams(t, byName(CombiningDenyOverrides, permissions...)))
+		assert.Equal(t, []string{"alpha", "gam_ma", "gamma"}, teams(t, byName(CombiningAllowOverrides, permissions...)))
+		assert.Equal(t, []string{"gam_ma", "gamma"}, teams(t, byName(CombiningFirstApplicable, permissions...)))
+	})
+
+	t.Run("Filters should agree with access checks", func(t *testing.T) {
+		permissions := []Permission{
+			{Action: ActionTeamsRead, Scope: "teams:name:a*"},
+			{Action: ActionTeamsRead, Scope: "teams:name:alpha", Kind: PermissionKindDeny,
+				Conditions: []Condition{{Attribute: AttributeSessionType, Values: []string{SessionTypeAPIKey}}}},
+		}
+		env := Environment{Attributes: Attributes{AttributeSessionType: {SessionTypeInteractive}}}
+		filter := ac.permissionsFilter(permissions, env, ActionTeamsRead, scopeColumn{prefix: "teams:name:", column: "team.name"})
+
+		assert.Equal(t, []string{alpha.Name}, teams(t, filter))
+		assert.True(t, Perm(ActionTeamsRead, "teams:name:alpha").Evaluate(permissions, env))
+	})
+}
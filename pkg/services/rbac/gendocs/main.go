@@ -0,0 +1,82 @@
+// Command gendocs generates the reference of the RBAC actions, scopes and fixed policies
+// registered by Grafana. Run it from the repository root after registering new actions:
+//
+//	go run ./pkg/services/rbac/gendocs -out docs/sources/permissions/rbac_reference.md
+package main
+
+import (
+	"bytes"
+	"flag"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/rbac"
+)
+
+const header = `+++
+title = "RBAC reference"
+description = "Reference of the RBAC actions, scopes and fixed policies"
+keywords = ["grafana", "rbac", "permissions", "actions", "scopes"]
+weight = 500
++++
+
+<!-- Generated by go run ./pkg/services/rbac/gendocs, do not edit. -->
+
+# RBAC reference
+`
+
+func main() {
+	out := flag.String("out", "", "file to write the reference to, defaults to stdout")
+	flag.Parse()
+
+	var buf bytes.Buffer
+	if err := render(&buf, rbac.GetReference()); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to render reference: %s\n", err)
+		os.Exit(1)
+	}
+
+	if *out == "" {
+		if _, err := os.Stdout.Write(buf.Bytes()); err != nil {
+			os.Exit(1)
+		}
+		return
+	}
+	if err := ioutil.WriteFile(*out, buf.Bytes(), 0644); err != nil {
+		fmt.Fprintf(os.Stderr, "failed to write reference: %s\n", err)
+		os.Exit(1)
+	}
+}
+
+// render writes the reference as markdown.
+func render(w io.Writer, ref rbac.Reference) error {
+	var b strings.Builder
+	b.WriteString(header)
+
+	b.WriteString("\n## Actions\n\n| Action | Description |\n| --- | --- |\n")
+	for _, def := range ref.Actions {
+		fmt.Fprintf(&b, "| `%s` | %s |\n", def.Action, def.Description)
+	}
+
+	b.WriteString("\n## Scopes\n\n| Scope | Description |\n| --- | --- |\n")
+	for _, def := range ref.Scopes {
+		fmt.Fprintf(&b, "| `%s` | %s |\n", def.Scope, def.Description)
+	}
+
+	b.WriteString("\n## Fixed policies\n")
+	for _, def := range ref.FixedPolicies {
+		fmt.Fprintf(&b, "\n### %s\n\n%s\n\n| Action | Scope | Kind |\n| --- | --- | --- |\n", def.Name, def.Description)
+		for _, p := range def.Permissions {
+			scope := "_none_"
+			if p.Scope != "" {
+				scope = "`" + p.Scope + "`"
+			}
+			fmt.Fprintf(&b, "| `%s` | %s | %s |\n", p.Action, scope, p.Kind)
+		}
+	}
+
+	_, err := io.WriteString(w, b.String())
+	return err
+}
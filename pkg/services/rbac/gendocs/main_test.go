@@ -0,0 +1,21 @@
+package main
+
+import (
+	"bytes"
+	"io/ioutil"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/services/rbac"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReferenceIsUpToDate(t *testing.T) {
+	var buf bytes.Buffer
+	require.NoError(t, render(&buf, rbac.GetReference()))
+
+	current, err := ioutil.ReadFile("../../../../docs/sources/permissions/rbac_reference.md")
+	require.NoError(t, err)
+	assert.Equal(t, string(current), buf.String(),
+		"the RBAC reference is outdated, run go run ./pkg/services/rbac/gendocs -out docs/sources/permissions/rbac_reference.md")
+}
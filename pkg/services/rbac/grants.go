@@ -0,0 +1,95 @@
+package rbac
+
+import (
+	"context"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// GetGrantingPolicies returns the policies of an organization with allow permissions granting the action
+// on the scope, wildcards included, e.g. to find who can delete the dashboards of a folder. Each policy
+// holds the matching permissions. Permission conditions aren't evaluated, so conditional grants are
+// included too.
+func (ac *RBACService) GetGrantingPolicies(ctx context.Context, query GetGrantingPoliciesQuery) ([]*PolicyDTO, error) {
+	scope, err := normalizeScope(query.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	policies := make([]*PolicyDTO, 0)
+	err = ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var permissions []Permission
+		err := sess.Table("permission").
+			Join("INNER", "policy", "permission.policy_id = policy.id").
+			Where("policy.org_id = ?", query.OrgID).
+			In("permission.action", matchingPatterns(query.Action)).
+			In("permission.scope", matchingPatterns(scope)).
+			And("(permission.kind IS NULL OR permission.kind <> ?)", PermissionKindDeny).
+			Asc("permission.id").
+			Select("permission.*").
+			Find(&permissions)
+		if err != nil {
+			return err
+		}
+
+		byID := map[int64]*PolicyDTO{}
+		for _, p := range permissions {
+			if !matchPattern(p.Action, query.Action) || !matchPattern(p.Scope, scope) {
+				continue
+			}
+			policy, ok := byID[p.PolicyID]
+			if !ok {
+				found, err := getPolicy(sess, GetPolicyQuery{OrgID: query.OrgID, PolicyID: p.PolicyID})
+				if err != nil {
+					return err
+				}
+				policy = &PolicyDTO{
+					ID:          found.ID,
+					OrgID:       found.OrgID,
+					UID:         found.UID,
+					Name:        found.Name,
+					Description: found.Description,
+					Precedence:  found.Precedence,
+					Enabled:     found.Enabled,
+					Created:     found.Created,
+					Updated:     found.Updated,
+				}
+				byID[p.PolicyID] = policy
+				policies = append(policies, policy)
+			}
+			policy.Permissions = append(policy.Permissions, p)
+		}
+
+		return nil
+	})
+
+	return policies, err
+}
+
+// matchingPatterns returns the value and every wildcard pattern that could match it, e.g. "*",
+// "dashboards:*", "dashboards:*:*", "dashboards:uid:*" and "dashboards:uid:abc" for "dashboards:uid:abc".
+func matchingPatterns(value string) []string {
+	if value == "" {
+		return []string{""}
+	}
+
+	segments := strings.Split(value, segmentSeparator)
+	patterns := []string{value}
+	for prefix := 0; prefix < len(segments); prefix++ {
+		for wildcards := 1; wildcards <= len(segments)-prefix; wildcards++ {
+			pattern := append(append([]string{}, segments[:prefix]...), repeatWildcard(wildcards)...)
+			patterns = append(patterns, strings.Join(pattern, segmentSeparator))
+		}
+	}
+
+	return patterns
+}
+
+func repeatWildcard(n int) []string {
+	wildcards := make([]string, n)
+	for i := range wildcards {
+		wildcards[i] = wildcard
+	}
+	return wildcards
+}
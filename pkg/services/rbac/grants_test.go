@@ -0,0 +1,40 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMatchingPatterns(t *testing.T) {
+	assert.ElementsMatch(t, []string{
+		"dashboards:uid:abc", "*", "*:*", "*:*:*", "dashboards:*", "dashboards:*:*", "dashboards:uid:*",
+	}, matchingPatterns("dashboards:uid:abc"))
+	assert.Equal(t, []string{""}, matchingPatterns(""))
+}
+
+func TestGetGrantingPolicies(t *testing.T) {
+	ac := setupTestEnv(t)
+	createPolicy(t, ac, 1, "admin", CreatePermissionCommand{Action: "*", Scope: "*"})
+	createPolicy(t, ac, 1, "dashboard writer",
+		CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"},
+		CreatePermissionCommand{Action: "dashboards:*", Scope: "dashboards:uid:abc"},
+	)
+	createPolicy(t, ac, 1, "other dashboard", CreatePermissionCommand{Action: ActionDashboardsDelete, Scope: "dashboards:uid:other"})
+	createPolicy(t, ac, 1, "no deletes", CreatePermissionCommand{Action: ActionDashboardsDelete, Scope: "dashboards:*", Kind: PermissionKindDeny})
+	createPolicy(t, ac, 2, "other org", CreatePermissionCommand{Action: ActionDashboardsDelete, Scope: "dashboards:*"})
+
+	policies, err := ac.GetGrantingPolicies(context.Background(), GetGrantingPoliciesQuery{OrgID: 1, Action: ActionDashboardsDelete, Scope: "dashboards:uid:abc"})
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	assert.Equal(t, "admin", policies[0].Name)
+	assert.Equal(t, "dashboard writer", policies[1].Name)
+	require.Len(t, policies[1].Permissions, 1)
+	assert.Equal(t, "dashboards:*", policies[1].Permissions[0].Action)
+
+	policies, err = ac.GetGrantingPolicies(context.Background(), GetGrantingPoliciesQuery{OrgID: 1, Action: ActionUsersCreate})
+	require.NoError(t, err)
+	assert.Empty(t, policies, "a lone wildcard scope doesn't grant unscoped actions")
+}
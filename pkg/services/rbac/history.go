@@ -0,0 +1,46 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// GetUserPermissionsAt reconstructs the permissions a user held at a past time, e.g. for
+// investigating what a user could do during an incident. They're resolved like GetUserPermissions,
+// through the bindings to the user, to the user's teams and to the user's builtin roles and with the
+// precedence of their policy, leaving out what was created after the time.
+//
+// RBAC doesn't keep a history of its rows, so the reconstruction relies on the creation and
+// expiry times of the rows that still exist. Permissions, bindings, team memberships and suspensions
+// deleted since are not taken into account, nor are the past builtin roles of the user and the past
+// state of policies, which apply when they're enabled now. Permissions updated since are reported
+// separately since their past values are unknown.
+func (ac *RBACService) GetUserPermissionsAt(ctx context.Context, query GetUserPermissionsAtQuery) (*UserPermissionsAtResult, error) {
+	var permissions []Permission
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q, args := userPermissionsQuery(GetUserPermissionsQuery{
+			OrgID: query.OrgID, UserID: query.UserID, Roles: query.Roles,
+		}, query.At, false, true)
+		var err error
+		permissions, err = findPermissionsWithPrecedence(sess, q, args...)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	result := &UserPermissionsAtResult{
+		Permissions: make([]Permission, 0, len(permissions)),
+		Modified:    make([]Permission, 0),
+	}
+	for _, p := range permissions {
+		if p.Updated.After(query.At) {
+			result.Modified = append(result.Modified, p)
+			continue
+		}
+		result.Permissions = append(result.Permissions, p)
+	}
+
+	return result, nil
+}
@@ -0,0 +1,109 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestGetUserPermissionsAt(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "incident")
+	addTeamMember(t, 1, team.Id, 111)
+	policy := createPolicy(t, ac, 1, "responder",
+		CreatePermissionCommand{Action: ActionUsersRead, Scope: "users:*"},
+		CreatePermissionCommand{Action: ActionDatasourcesRead, Scope: "datasources:*"},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	// Pretend everything was set up three days ago.
+	created := time.Now().Add(-72 * time.Hour)
+	err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		for _, q := range []string{
+			"UPDATE team_member SET created = ? WHERE team_id = ?",
+			"UPDATE team_policy SET created = ? WHERE team_id = ?",
+		} {
+			if _, err := sess.Exec(q, created, team.Id); err != nil {
+				return err
+			}
+		}
+		_, err := sess.Exec("UPDATE permission SET created = ?, updated = ? WHERE policy_id = ?", created, created, policy.ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	at := func(ago time.Duration) *UserPermissionsAtResult {
+		result, err := ac.GetUserPermissionsAt(context.Background(), GetUserPermissionsAtQuery{OrgID: 1, UserID: 111, At: time.Now().Add(-ago)})
+		require.NoError(t, err)
+		return result
+	}
+
+	assert.Empty(t, at(96*time.Hour).Permissions)
+	assert.Len(t, at(48*time.Hour).Permissions, 2)
+
+	t.Run("Permissions updated since should be reported as modified", func(t *testing.T) {
+		permissions, err := ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID, ActionPrefix: ActionUsersRead})
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		_, err = ac.UpdatePermission(context.Background(), UpdatePermissionCommand{OrgID: 1, ID: permissions[0].ID, Action: ActionUsersWrite, Scope: "users:*"})
+		require.NoError(t, err)
+
+		result := at(48 * time.Hour)
+		require.Len(t, result.Permissions, 1)
+		assert.Equal(t, ActionDatasourcesRead, result.Permissions[0].Action)
+		require.Len(t, result.Modified, 1)
+		assert.Equal(t, ActionUsersWrite, result.Modified[0].Action)
+
+		assert.Len(t, at(0).Permissions, 2)
+	})
+
+	t.Run("Builtin role bindings should apply with the precedence of their policy", func(t *testing.T) {
+		precedence := 5
+		rolePolicy, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "viewers", Precedence: &precedence})
+		require.NoError(t, err)
+		_, err = ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: 1, PolicyID: rolePolicy.ID, Action: ActionTeamsRead, Scope: "teams:*"})
+		require.NoError(t, err)
+		disabled := createPolicy(t, ac, 1, "disabled viewers", CreatePermissionCommand{Action: ActionUsersDelete, Scope: "users:*"})
+		require.NoError(t, ac.SetPolicyEnabled(context.Background(), SetPolicyEnabledCommand{ID: disabled.ID, OrgID: 1, Enabled: false}))
+		for _, id := range []int64{rolePolicy.ID, disabled.ID} {
+			require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: id}))
+		}
+		err = ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			if _, err := sess.Exec("UPDATE builtin_role_policy SET created = ?", created); err != nil {
+				return err
+			}
+			_, err := sess.Exec("UPDATE permission SET created = ?, updated = ? WHERE policy_id IN (?, ?)", created, created, rolePolicy.ID, disabled.ID)
+			return err
+		})
+		require.NoError(t, err)
+
+		atWithRoles := func(ago time.Duration, roles ...string) []Permission {
+			result, err := ac.GetUserPermissionsAt(context.Background(), GetUserPermissionsAtQuery{
+				OrgID: 1, UserID: 111, Roles: roles, At: time.Now().Add(-ago),
+			})
+			require.NoError(t, err)
+			return result.Permissions
+		}
+
+		var granted *Permission
+		for _, p := range atWithRoles(48*time.Hour, "Viewer") {
+			assert.NotEqual(t, ActionUsersDelete, p.Action, "disabled policies shouldn't apply")
+			if p.Action == ActionTeamsRead {
+				p := p
+				granted = &p
+			}
+		}
+		require.NotNil(t, granted)
+		require.NotNil(t, granted.Precedence)
+		assert.Equal(t, precedence, *granted.Precedence)
+
+		assert.Len(t, atWithRoles(48*time.Hour), 1, "without the role only the team's permission should apply")
+		assert.Empty(t, atWithRoles(96*time.Hour, "Viewer"))
+	})
+}
@@ -0,0 +1,188 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// janitorInterval is how often expired RBAC rows are deleted.
+const janitorInterval = 10 * time.Minute
+
+// Run periodically deletes expired permissions and policy bindings, notifies the user policy
+// bindings about to expire and listens or checks for permission cache invalidations made by other
+// instances, and keeps the instance registered while it issues session permission claims, until
+// Grafana shuts down. The permission cache is warmed up in the background first.
+func (ac *RBACService) Run(ctx context.Context) error {
+	if !ac.isFeatureEnabled() {
+		return nil
+	}
+
+	go ac.warmUpPermissionCache(ctx)
+	go ac.runAuthorizationServer(ctx)
+	go ac.runOPABundleExport(ctx)
+
+	ticker := time.NewTicker(janitorInterval)
+	defer ticker.Stop()
+	// The sync channel stays nil, and never fires, when the permission cache isn't synced through the remote cache.
+	var sync <-chan time.Time
+	if c := ac.permissionCache; c != nil && c.postgresNotify {
+		go ac.listenPermissionCache(ctx)
+	} else if c != nil && c.syncInterval > 0 && ac.RemoteCache != nil {
+		syncTicker := time.NewTicker(c.syncInterval)
+		defer syncTicker.Stop()
+		sync = syncTicker.C
+	}
+	// Likewise, the instance is only registered when it issues session permission claims.
+	var register <-chan time.Time
+	if ac.sessionPermissions != nil && ac.RemoteCache != nil {
+		ac.registerSessionPermissionsInstance()
+		registerTicker := time.NewTicker(sessionPermissionsInstanceInterval)
+		defer registerTicker.Stop()
+		register = registerTicker.C
+	}
+	for {
+		select {
+		case <-ticker.C:
+			ac.runJanitor(ctx)
+		case <-sync:
+			ac.syncPermissionCache()
+		case <-register:
+			ac.registerSessionPermissionsInstance()
+		case <-ctx.Done():
+			ac.unregisterSessionPermissionsInstance()
+			return ctx.Err()
+		}
+	}
+}
+
+func (ac *RBACService) runJanitor(ctx context.Context) {
+	if ac.IsDegraded() {
+		return
+	}
+
+	cleanUp := func() {
+		deleted, err := ac.deleteExpiredPermissions(ctx)
+		if err != nil {
+			ac.log.Error("Failed to delete expired permissions", "error", err)
+			return
+		}
+		ac.log.Debug("Deleted expired permissions", "count", deleted)
+
+		deleted, err = ac.deleteExpiredPolicyBindings(ctx)
+		if err != nil {
+			ac.log.Error("Failed to delete expired policy bindings", "error", err)
+			return
+		}
+		ac.log.Debug("Deleted expired policy bindings", "count", deleted)
+
+		notified, err := ac.notifyExpiringUserPolicies(ctx)
+		if err != nil {
+			ac.log.Error("Failed to notify expiring user policy bindings", "error", err)
+			return
+		}
+		ac.log.Debug("Notified expiring user policy bindings", "count", notified)
+	}
+
+	// Only one instance of a HA setup needs to clean up.
+	if ac.ServerLockService == nil {
+		cleanUp()
+		return
+	}
+	if err := ac.ServerLockService.LockAndExecute(ctx, "rbac delete expired permissions", janitorInterval, cleanUp); err != nil {
+		ac.log.Error("Failed to lock and execute deletion of expired permissions", "error", err)
+	}
+}
+
+// deleteExpiredPermissions deletes the permissions that expired and publishes an event for each of them.
+func (ac *RBACService) deleteExpiredPermissions(ctx context.Context) (int, error) {
+	var expired []Permission
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if err := sess.Table("permission").Where("expires_at <= ?", time.Now()).Find(&expired); err != nil {
+			return err
+		}
+		for _, p := range expired {
+			if _, err := sess.Exec("DELETE FROM permission WHERE id = ?", p.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	for _, p := range expired {
+		ac.log.Info("Deleted expired permission", "permissionId", p.ID, "policyId", p.PolicyID, "action", p.Action,
+			"scope", p.Scope, "expiresAt", p.ExpiresAt)
+		if err := bus.Publish(&events.PermissionExpired{
+			Timestamp:    time.Now(),
+			PermissionID: p.ID,
+			PolicyID:     p.PolicyID,
+			Action:       p.Action,
+			Scope:        p.Scope,
+			ExpiresAt:    *p.ExpiresAt,
+		}); err != nil {
+			ac.log.Error("Failed to publish expired permission event", "permissionId", p.ID, "error", err)
+		}
+	}
+
+	return len(expired), nil
+}
+
+// deleteExpiredPolicyBindings deletes the team and user policy bindings that expired and publishes an
+// event for each of them.
+func (ac *RBACService) deleteExpiredPolicyBindings(ctx context.Context) (int, error) {
+	var expiredTeamPolicies []TeamPolicy
+	var expiredUserPolicies []UserPolicy
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		now := time.Now()
+		if err := sess.Table("team_policy").Where("expires_at <= ?", now).Find(&expiredTeamPolicies); err != nil {
+			return err
+		}
+		for _, tp := range expiredTeamPolicies {
+			if _, err := sess.Exec("DELETE FROM team_policy WHERE id = ?", tp.ID); err != nil {
+				return err
+			}
+		}
+		if err := sess.Table("user_policy").Where("expires_at <= ?", now).Find(&expiredUserPolicies); err != nil {
+			return err
+		}
+		for _, up := range expiredUserPolicies {
+			if _, err := sess.Exec("DELETE FROM user_policy WHERE id = ?", up.ID); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return 0, err
+	}
+
+	published := make([]*events.PolicyBindingExpired, 0, len(expiredTeamPolicies)+len(expiredUserPolicies))
+	for _, tp := range expiredTeamPolicies {
+		ac.log.Info("Deleted expired team policy binding", "orgId", tp.OrgID, "policyId", tp.PolicyID, "teamId", tp.TeamID,
+			"expiresAt", tp.ExpiresAt)
+		published = append(published, &events.PolicyBindingExpired{
+			OrgID: tp.OrgID, PolicyID: tp.PolicyID, TeamID: tp.TeamID, ExpiresAt: *tp.ExpiresAt,
+		})
+	}
+	for _, up := range expiredUserPolicies {
+		ac.log.Info("Deleted expired user policy binding", "orgId", up.OrgID, "policyId", up.PolicyID, "userId", up.UserID,
+			"expiresAt", up.ExpiresAt)
+		published = append(published, &events.PolicyBindingExpired{
+			OrgID: up.OrgID, PolicyID: up.PolicyID, UserID: up.UserID, ExpiresAt: *up.ExpiresAt,
+		})
+	}
+	for _, e := range published {
+		e.Timestamp = time.Now()
+		if err := bus.Publish(e); err != nil {
+			ac.log.Error("Failed to publish expired policy binding event", "policyId", e.PolicyID, "error", err)
+		}
+	}
+
+	return len(published), nil
+}
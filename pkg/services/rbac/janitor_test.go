@@ -0,0 +1,150 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestExpiringPermissions(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	var published []*events.PermissionExpired
+	bus.AddEventListener(func(e *events.PermissionExpired) error {
+		published = append(published, e)
+		return nil
+	})
+
+	team := createTeam(t, 1, "on-call")
+	addTeamMember(t, 1, team.Id, 101)
+	policy := createPolicy(t, ac, 1, "break glass",
+		CreatePermissionCommand{Action: ActionUsersRead, Scope: "users:*"},
+		CreatePermissionCommand{Action: ActionUsersDelete, Scope: "users:*"},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 101}
+	check := func(evaluator Evaluator) bool {
+		ok, err := ac.Evaluate(context.Background(), user, evaluator)
+		require.NoError(t, err)
+		return ok
+	}
+	require.True(t, check(Perm(ActionUsersDelete, "users:id:1")))
+
+	t.Run("Expiry in the past should be rejected", func(t *testing.T) {
+		past := time.Now().Add(-time.Minute)
+		_, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: ActionUsersCreate, ExpiresAt: &past})
+		require.ErrorIs(t, err, ErrPermissionExpiryInPast)
+	})
+
+	expiresAt := time.Now().Add(time.Hour)
+	permissions, err := ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID, ActionPrefix: ActionUsersDelete})
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	deletePermission := permissions[0]
+	_, err = ac.UpdatePermission(context.Background(), UpdatePermissionCommand{
+		OrgID: 1, ID: deletePermission.ID, Action: deletePermission.Action, Scope: deletePermission.Scope, ExpiresAt: &expiresAt,
+	})
+	require.NoError(t, err)
+	assert.True(t, check(Perm(ActionUsersDelete, "users:id:1")))
+
+	// Expire the permission without waiting for it.
+	expired := time.Now().Add(-time.Second)
+	err = ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Exec("UPDATE permission SET expires_at = ? WHERE id = ?", expired, deletePermission.ID)
+		return err
+	})
+	require.NoError(t, err)
+	assert.False(t, check(Perm(ActionUsersDelete, "users:id:1")))
+	assert.True(t, check(Perm(ActionUsersRead, "users:id:1")))
+
+	deleted, err := ac.deleteExpiredPermissions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 1, deleted)
+	require.Len(t, published, 1)
+	assert.Equal(t, deletePermission.ID, published[0].PermissionID)
+	assert.Equal(t, policy.ID, published[0].PolicyID)
+
+	permissions, err = ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID})
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	assert.Equal(t, ActionUsersRead, permissions[0].Action)
+
+	deleted, err = ac.deleteExpiredPermissions(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+}
+
+func TestExpiringPolicyBindings(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	var published []*events.PolicyBindingExpired
+	bus.AddEventListener(func(e *events.PolicyBindingExpired) error {
+		published = append(published, e)
+		return nil
+	})
+
+	team := createTeam(t, 1, "incident responders")
+	addTeamMember(t, 1, team.Id, 221)
+	teamPolicy := createPolicy(t, ac, 1, "incident", CreatePermissionCommand{Action: ActionUsersRead, Scope: "users:*"})
+	userPolicy := createPolicy(t, ac, 1, "contractor", CreatePermissionCommand{Action: ActionUsersDelete, Scope: "users:*"})
+
+	t.Run("Expiry in the past should be rejected", func(t *testing.T) {
+		past := time.Now().Add(-time.Minute)
+		err := ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: teamPolicy.ID, ExpiresAt: &past})
+		require.ErrorIs(t, err, ErrBindingExpiryInPast)
+		err = ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 221, PolicyID: userPolicy.ID, ExpiresAt: &past})
+		require.ErrorIs(t, err, ErrBindingExpiryInPast)
+	})
+
+	expiresAt := time.Now().Add(time.Hour)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: teamPolicy.ID, ExpiresAt: &expiresAt}))
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 221, PolicyID: userPolicy.ID, ExpiresAt: &expiresAt}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 221}
+	check := func(evaluator Evaluator) bool {
+		ok, err := ac.Evaluate(context.Background(), user, evaluator)
+		require.NoError(t, err)
+		return ok
+	}
+	assert.True(t, check(Perm(ActionUsersRead, "users:id:1")))
+	assert.True(t, check(Perm(ActionUsersDelete, "users:id:1")))
+
+	// Expire the bindings without waiting for them.
+	expired := time.Now().Add(-time.Second)
+	err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		if _, err := sess.Exec("UPDATE team_policy SET expires_at = ?", expired); err != nil {
+			return err
+		}
+		_, err := sess.Exec("UPDATE user_policy SET expires_at = ?", expired)
+		return err
+	})
+	require.NoError(t, err)
+	assert.False(t, check(Perm(ActionUsersRead, "users:id:1")))
+	assert.False(t, check(Perm(ActionUsersDelete, "users:id:1")))
+
+	deleted, err := ac.deleteExpiredPolicyBindings(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 2, deleted)
+	require.Len(t, published, 2)
+	assert.Equal(t, team.Id, published[0].TeamID)
+	assert.Equal(t, teamPolicy.ID, published[0].PolicyID)
+	assert.Equal(t, int64(221), published[1].UserID)
+	assert.Equal(t, userPolicy.ID, published[1].PolicyID)
+
+	policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: 221})
+	require.NoError(t, err)
+	assert.Empty(t, policies)
+
+	deleted, err = ac.deleteExpiredPolicyBindings(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, 0, deleted)
+}
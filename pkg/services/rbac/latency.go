@@ -0,0 +1,101 @@
+package rbac
+
+import (
+	"context"
+	"sync"
+	"time"
+
+	"github.com/opentracing/opentracing-go"
+	"github.com/prometheus/client_golang/prometheus"
+)
+
+// Phases of an access decision, see decisionTimings.
+const (
+	// PhaseDBResolution is the loading of the user's permissions from the database.
+	PhaseDBResolution = "db_resolution"
+	// PhaseCacheLookup is the resolution of scopes through the scope resolver, folder and tag caches,
+	// including the database queries of cache misses.
+	PhaseCacheLookup = "cache_lookup"
+	// PhaseScopeMatching is the matching of actions and scopes against the request.
+	PhaseScopeMatching = "scope_matching"
+	// PhaseConditionChecks is the evaluation of the conditions of matching permissions.
+	PhaseConditionChecks = "condition_checks"
+)
+
+var decisionPhases = []string{PhaseDBResolution, PhaseCacheLookup, PhaseScopeMatching, PhaseConditionChecks}
+
+var (
+	decisionDuration = prometheus.NewHistogram(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Subsystem: "rbac",
+		Name:      "decision_duration_seconds",
+		Help:      "Duration of access decisions",
+		Buckets:   []float64{.0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	})
+	decisionPhaseDuration = prometheus.NewHistogramVec(prometheus.HistogramOpts{
+		Namespace: "grafana",
+		Subsystem: "rbac",
+		Name:      "decision_phase_duration_seconds",
+		Help:      "Duration of each phase of access decisions",
+		Buckets:   []float64{.00001, .0001, .0005, .001, .0025, .005, .01, .025, .05, .1, .25, .5, 1},
+	}, []string{"phase"})
+)
+
+func init() {
+	prometheus.MustRegister(decisionDuration, decisionPhaseDuration)
+}
+
+// decisionTimings accumulates the time an access decision spends in each phase. Scope matching and
+// condition checks run once per permission, so their durations are summed. A nil decisionTimings
+// records nothing.
+type decisionTimings struct {
+	mu     sync.Mutex
+	phases map[string]time.Duration
+}
+
+func newDecisionTimings() *decisionTimings {
+	return &decisionTimings{phases: map[string]time.Duration{}}
+}
+
+func (t *decisionTimings) add(phase string, d time.Duration) {
+	if t == nil {
+		return
+	}
+	t.mu.Lock()
+	t.phases[phase] += d
+	t.mu.Unlock()
+}
+
+// since records the time elapsed since start in the phase.
+func (t *decisionTimings) since(phase string, start time.Time) {
+	if t == nil {
+		return
+	}
+	t.add(phase, time.Since(start))
+}
+
+// observe records the total duration and the duration of each phase in the histograms and as tags of the span.
+func (t *decisionTimings) observe(span opentracing.Span, total time.Duration) {
+	decisionDuration.Observe(total.Seconds())
+	span.SetTag("duration_ms", float64(total)/float64(time.Millisecond))
+
+	t.mu.Lock()
+	defer t.mu.Unlock()
+	for _, phase := range decisionPhases {
+		d := t.phases[phase]
+		decisionPhaseDuration.WithLabelValues(phase).Observe(d.Seconds())
+		span.SetTag(phase+"_ms", float64(d)/float64(time.Millisecond))
+	}
+}
+
+type decisionTimingsKey struct{}
+
+func withDecisionTimings(ctx context.Context, t *decisionTimings) context.Context {
+	return context.WithValue(ctx, decisionTimingsKey{}, t)
+}
+
+// decisionTimingsFromContext returns the timings of the decision in progress, nil outside of one.
+func decisionTimingsFromContext(ctx context.Context) *decisionTimings {
+	t, _ := ctx.Value(decisionTimingsKey{}).(*decisionTimings)
+	return t
+}
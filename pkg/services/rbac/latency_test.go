@@ -0,0 +1,51 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	dto "github.com/prometheus/client_model/go"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestDecisionTimings(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "prometheus queriers", CreatePermissionCommand{
+		Action: ActionDatasourcesQuery, Scope: "datasources:*",
+		Conditions: []Condition{{Attribute: AttributeDatasourceType, Values: []string{"prometheus"}}},
+	})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 251, PolicyID: policy.ID}))
+	user := &models.SignedInUser{OrgId: 1, UserId: 251}
+
+	timings := newDecisionTimings()
+	ctx := withDecisionTimings(context.Background(), timings)
+	env := ac.environment(ctx, user)
+	env.timings = timings
+	env.Attributes[AttributeDatasourceType] = []string{"prometheus"}
+	decision, err := ac.decide(ctx, DecisionRequest{User: user, Evaluator: Perm(ActionDatasourcesQuery, "datasources:id:1"), Environment: env})
+	require.NoError(t, err)
+	assert.True(t, decision.Allowed)
+
+	for _, phase := range decisionPhases {
+		assert.Contains(t, timings.phases, phase)
+		assert.Positive(t, int64(timings.phases[phase]), phase)
+	}
+
+	t.Run("Evaluate should observe the decision duration", func(t *testing.T) {
+		count := func() uint64 {
+			m := &dto.Metric{}
+			require.NoError(t, decisionDuration.Write(m))
+			return m.GetHistogram().GetSampleCount()
+		}
+		before := count()
+		_, err := ac.Evaluate(context.Background(), user, Perm(ActionDatasourcesQuery, "datasources:id:1"))
+		require.NoError(t, err)
+		assert.Equal(t, before+1, count())
+		assert.Equal(t, len(decisionPhases), testutil.CollectAndCount(decisionPhaseDuration))
+	})
+}
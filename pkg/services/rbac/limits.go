@@ -0,0 +1,74 @@
+package rbac
+
+import (
+	"fmt"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// Default number of permissions a policy can hold, and number above which a warning is logged.
+const (
+	defaultMaxPermissionsPerPolicy  = 1000
+	defaultWarnPermissionsPerPolicy = 800
+)
+
+var policiesOverPermissionsWarning = prometheus.NewCounter(prometheus.CounterOpts{
+	Namespace: "grafana",
+	Subsystem: "rbac",
+	Name:      "policy_permissions_warning_total",
+	Help:      "Number of permissions added to policies holding more permissions than the warning threshold",
+})
+
+func init() {
+	prometheus.MustRegister(policiesOverPermissionsWarning)
+}
+
+// PermissionLimitError is an error for when adding permissions would exceed the maximum number
+// of permissions of a policy, it matches ErrPermissionLimitExceeded.
+type PermissionLimitError struct {
+	PolicyID int64
+	Limit    int
+}
+
+func (e *PermissionLimitError) Error() string {
+	return fmt.Sprintf("%s: policy %d can't hold more than %d permissions", ErrPermissionLimitExceeded, e.PolicyID, e.Limit)
+}
+
+// Is makes errors.Is(err, ErrPermissionLimitExceeded) true for permission limit errors.
+func (e *PermissionLimitError) Is(target error) bool {
+	return target == ErrPermissionLimitExceeded
+}
+
+// loadPermissionLimits reads the permissions per policy limits from the settings, zero disables a limit.
+func (ac *RBACService) loadPermissionLimits() {
+	section := ac.Cfg.Raw.Section("rbac")
+	ac.maxPermissionsPerPolicy = section.Key("max_permissions_per_policy").MustInt(defaultMaxPermissionsPerPolicy)
+	ac.warnPermissionsPerPolicy = section.Key("permissions_per_policy_warning").MustInt(defaultWarnPermissionsPerPolicy)
+}
+
+// checkPermissionLimit returns a PermissionLimitError if adding permissions to the policy would exceed
+// the maximum, and logs a warning when the policy grows past the warning threshold.
+func (ac *RBACService) checkPermissionLimit(sess *sqlstore.DBSession, policyID int64, adding int) error {
+	if ac.maxPermissionsPerPolicy <= 0 && ac.warnPermissionsPerPolicy <= 0 {
+		return nil
+	}
+
+	count, err := sess.Table("permission").Where("policy_id = ?", policyID).Count()
+	if err != nil {
+		return err
+	}
+	total := int(count) + adding
+
+	if ac.maxPermissionsPerPolicy > 0 && total > ac.maxPermissionsPerPolicy {
+		return &PermissionLimitError{PolicyID: policyID, Limit: ac.maxPermissionsPerPolicy}
+	}
+	if ac.warnPermissionsPerPolicy > 0 && total > ac.warnPermissionsPerPolicy {
+		policiesOverPermissionsWarning.Add(float64(adding))
+		ac.log.Warn("Policy holds many permissions, evaluation of its permissions gets slower", "policyId", policyID,
+			"permissions", total, "warningThreshold", ac.warnPermissionsPerPolicy, "limit", ac.maxPermissionsPerPolicy)
+	}
+
+	return nil
+}
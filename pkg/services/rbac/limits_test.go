@@ -0,0 +1,43 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPermissionLimit(t *testing.T) {
+	ac := setupTestEnv(t)
+	assert.Equal(t, defaultMaxPermissionsPerPolicy, ac.maxPermissionsPerPolicy)
+	ac.maxPermissionsPerPolicy = 2
+	ac.warnPermissionsPerPolicy = 1
+
+	policy := createPolicy(t, ac, 1, "small",
+		CreatePermissionCommand{Action: ActionUsersRead, Scope: "users:*"},
+		CreatePermissionCommand{Action: ActionUsersWrite, Scope: "users:*"},
+	)
+
+	_, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: ActionUsersDelete, Scope: "users:*"})
+	require.ErrorIs(t, err, ErrPermissionLimitExceeded)
+	var limitErr *PermissionLimitError
+	require.ErrorAs(t, err, &limitErr)
+	assert.Equal(t, policy.ID, limitErr.PolicyID)
+	assert.Equal(t, 2, limitErr.Limit)
+
+	t.Run("Resource permissions should be limited too", func(t *testing.T) {
+		team := createTeam(t, 1, "big")
+		err := ac.SetResourcePermission(context.Background(), SetResourcePermissionCommand{
+			OrgID: 1, Scope: "dashboards:uid:abc", TeamID: team.Id,
+			Actions: []string{ActionDashboardsRead, ActionDashboardsWrite, ActionDashboardsDelete},
+		})
+		require.ErrorIs(t, err, ErrPermissionLimitExceeded)
+	})
+
+	t.Run("Zero should disable the limit", func(t *testing.T) {
+		ac.maxPermissionsPerPolicy = 0
+		_, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: ActionUsersDelete, Scope: "users:*"})
+		require.NoError(t, err)
+	})
+}
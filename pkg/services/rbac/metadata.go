@@ -0,0 +1,108 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// Metadata tells, for each action, whether a user may perform it on a resource.
+type Metadata map[string]bool
+
+// ScopeDashboardUID returns the scope of a dashboard identified by its uid.
+func ScopeDashboardUID(uid string) string {
+	return "dashboards:uid:" + uid
+}
+
+// ScopeTeamID returns the scope of a team identified by its id.
+func ScopeTeamID(id int64) string {
+	return "teams:id:" + strconv.FormatInt(id, 10)
+}
+
+// GetMetadata resolves the permissions of a user once and returns the metadata of every
+// resource scope for the actions, keyed by scope. The permissions are resolved as in Filter and each
+// access check is decided as in EvaluateAll. The actions legacy access control decides on the resource,
+// in compat or shadow mode, are left out of its metadata since RBAC doesn't tell.
+func (ac *RBACService) GetMetadata(ctx context.Context, user *models.SignedInUser, actions []string, scopes ...string) (map[string]Metadata, error) {
+	granting, err := ac.resolveGrantingPermissions(ctx, user)
+	if err != nil {
+		return nil, err
+	}
+
+	decide := ac.decideGranting(granting)
+	env := ac.environment(ctx, user)
+	env.external = ac.externalDecisions(ctx, user)
+	result := make(map[string]Metadata, len(scopes))
+	for _, scope := range scopes {
+		metadata := make(Metadata, len(actions))
+		for _, action := range actions {
+			decision, err := decide(ctx, DecisionRequest{User: user, Evaluator: Perm(action, scope), Environment: env})
+			if err != nil {
+				return nil, err
+			}
+			if decision.Shadow || decision.LegacyFallback {
+				continue
+			}
+			metadata[action] = decision.Allowed
+		}
+		result[scope] = metadata
+	}
+
+	return result, nil
+}
+
+// GetResourceActions returns the registered actions of a resource type, e.g. dashboards, that a
+// user may perform on each of the resources, keyed by uid. The resources are identified by their uid
+// scope, e.g. dashboards:uid:abc, and the user's permissions are resolved once for all of them. The
+// actions legacy access control decides aren't listed, see GetMetadata.
+func (ac *RBACService) GetResourceActions(ctx context.Context, query GetResourceActionsQuery) (map[string][]string, error) {
+	defs := ac.GetActions(ctx, GetActionsQuery{Prefix: query.Resource + ":"})
+	if query.Resource == "" || len(defs) == 0 {
+		return nil, fmt.Errorf("%w %q", ErrUnknownResourceType, query.Resource)
+	}
+	actions := make([]string, 0, len(defs))
+	for _, def := range defs {
+		actions = append(actions, def.Action)
+	}
+
+	scopes := make([]string, 0, len(query.UIDs))
+	for _, uid := range query.UIDs {
+		scopes = append(scopes, query.Resource+":uid:"+uid)
+	}
+	metadata, err := ac.GetMetadata(ctx, query.User, actions, scopes...)
+	if err != nil {
+		return nil, err
+	}
+
+	result := make(map[string][]string, len(query.UIDs))
+	for _, uid := range query.UIDs {
+		allowed := []string{}
+		for _, action := range actions {
+			if metadata[query.Resource+":uid:"+uid][action] {
+				allowed = append(allowed, action)
+			}
+		}
+		result[uid] = allowed
+	}
+
+	return result, nil
+}
+
+// environment returns the environment of an access check by the user.
+func (ac *RBACService) environment(ctx context.Context, user *models.SignedInUser) Environment {
+	attrs := UserAttributes(user)
+	for k, v := range requestAttributesFromContext(ctx) {
+		attrs[k] = append(attrs[k], v...)
+	}
+
+	return Environment{
+		Attributes: attrs,
+		Time:       time.Now(),
+		RemoteAddr: remoteAddrFromContext(ctx),
+		AuthTime:   authTimeFromContext(ctx),
+		combining:  ac.combiningAlgorithms,
+	}
+}
@@ -0,0 +1,107 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestGetMetadata(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "dashboard editors")
+	addTeamMember(t, 1, team.Id, 31)
+	policy := createPolicy(t, ac, 1, "dashboard editor",
+		CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"},
+		CreatePermissionCommand{Action: ActionDashboardsWrite, Scope: ScopeDashboardUID("a")},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 31}
+	actions := []string{ActionDashboardsRead, ActionDashboardsWrite, ActionDashboardsDelete}
+
+	metadata, err := ac.GetMetadata(context.Background(), user, actions, ScopeDashboardUID("a"), ScopeDashboardUID("b"))
+	require.NoError(t, err)
+
+	assert.Equal(t, Metadata{ActionDashboardsRead: true, ActionDashboardsWrite: true, ActionDashboardsDelete: false}, metadata[ScopeDashboardUID("a")])
+	assert.Equal(t, Metadata{ActionDashboardsRead: true, ActionDashboardsWrite: false, ActionDashboardsDelete: false}, metadata[ScopeDashboardUID("b")])
+
+	t.Run("Delegated permissions should be decided on", func(t *testing.T) {
+		ctx := WithDelegatedPermissions(context.Background(), []Permission{{Action: ActionDashboardsRead, Scope: ScopeDashboardUID("b")}})
+		metadata, err := ac.GetMetadata(ctx, user, actions, ScopeDashboardUID("a"), ScopeDashboardUID("b"))
+		require.NoError(t, err)
+		assert.Equal(t, Metadata{ActionDashboardsRead: false, ActionDashboardsWrite: false, ActionDashboardsDelete: false}, metadata[ScopeDashboardUID("a")])
+		assert.Equal(t, Metadata{ActionDashboardsRead: true, ActionDashboardsWrite: false, ActionDashboardsDelete: false}, metadata[ScopeDashboardUID("b")])
+	})
+
+	t.Run("API keys with policies should get what the key and its creators grant", func(t *testing.T) {
+		creator := &models.CreateUserCommand{Login: "metadata-key-creator", SkipOrgSetup: true}
+		require.NoError(t, sqlstore.CreateUser(context.Background(), creator))
+		addTeamMember(t, 1, team.Id, creator.Result.Id)
+
+		key := &models.AddApiKeyCommand{OrgId: 1, Name: "metadata", Role: models.ROLE_ADMIN, Key: "secret"}
+		require.NoError(t, sqlstore.AddApiKey(key))
+		keyPolicy := createPolicy(t, ac, 1, "key dashboards", CreatePermissionCommand{Action: "dashboards:*", Scope: "dashboards:*"})
+		require.NoError(t, ac.AddAPIKeyPolicy(context.Background(), AddAPIKeyPolicyCommand{
+			OrgID: 1, APIKeyID: key.Result.Id, PolicyID: keyPolicy.ID, CreatedBy: creator.Result.Id,
+		}))
+
+		keyUser := &models.SignedInUser{OrgId: 1, ApiKeyId: key.Result.Id, OrgRole: models.ROLE_ADMIN}
+		metadata, err := ac.GetMetadata(context.Background(), keyUser, actions, ScopeDashboardUID("b"))
+		require.NoError(t, err)
+		assert.Equal(t, Metadata{ActionDashboardsRead: true, ActionDashboardsWrite: false, ActionDashboardsDelete: false}, metadata[ScopeDashboardUID("b")])
+	})
+
+	t.Run("Actions legacy access control decides should be left out", func(t *testing.T) {
+		for _, mode := range []string{ResourceModeCompat, ResourceModeShadow} {
+			ac.Cfg.Raw.Section("rbac.resource_modes").Key("dashboards").SetValue(mode)
+			require.NoError(t, ac.loadResourceModes())
+
+			metadata, err := ac.GetMetadata(context.Background(), user, actions, ScopeDashboardUID("a"), ScopeDashboardUID("b"))
+			require.NoError(t, err)
+			if mode == ResourceModeShadow {
+				assert.Empty(t, metadata[ScopeDashboardUID("a")])
+				continue
+			}
+			assert.Equal(t, Metadata{ActionDashboardsRead: true, ActionDashboardsWrite: true}, metadata[ScopeDashboardUID("a")])
+			assert.Equal(t, Metadata{ActionDashboardsRead: true}, metadata[ScopeDashboardUID("b")])
+		}
+	})
+}
+
+func TestGetResourceActions(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "dashboard editor",
+		CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"},
+		CreatePermissionCommand{Action: ActionDashboardsWrite, Scope: ScopeDashboardUID("a")},
+	)
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 481, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 481}
+
+	t.Run("Should return the allowed actions of each resource", func(t *testing.T) {
+		result, err := ac.GetResourceActions(context.Background(), GetResourceActionsQuery{User: user, Resource: "dashboards", UIDs: []string{"a", "b"}})
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{
+			"a": {ActionDashboardsRead, ActionDashboardsWrite},
+			"b": {ActionDashboardsRead},
+		}, result)
+	})
+
+	t.Run("Resources without allowed actions should be returned empty", func(t *testing.T) {
+		result, err := ac.GetResourceActions(context.Background(), GetResourceActionsQuery{User: user, Resource: "teams", UIDs: []string{"a"}})
+		require.NoError(t, err)
+		assert.Equal(t, map[string][]string{"a": {}}, result)
+	})
+
+	t.Run("Unknown resource types should be rejected", func(t *testing.T) {
+		_, err := ac.GetResourceActions(context.Background(), GetResourceActionsQuery{User: user, Resource: "unknown", UIDs: []string{"a"}})
+		require.ErrorIs(t, err, ErrUnknownResourceType)
+	})
+}
@@ -0,0 +1,578 @@
+package rbac
+
+import (
+	"fmt"
+
+	"xorm.io/xorm"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+)
+
+// schemaVersions maps each RBAC schema version to the last migration it requires.
+// Older Grafana versions may keep running against a database migrated by a newer
+// version, so schema changes must stay backwards compatible:
+// - new columns must be nullable or have a default value,
+// - existing columns and tables must never be renamed or dropped,
+// - writes to new columns must be guarded with checkSchemaVersion.
+// Every change to the schema must append a new version to this list.
+var schemaVersions = []schemaVersion{
+	{Version: schemaVersionInitial, MigrationID: "add index team_policy.team_id"},
+	{Version: schemaVersionPermissionKind, MigrationID: "add kind column to permission table"},
+	{Version: schemaVersionPermissionConditions, MigrationID: "add conditions column to permission table"},
+	{Version: schemaVersionPermissionScopePrefix, MigrationID: "add index permission.scope_prefix"},
+	{Version: schemaVersionUserSuspension, MigrationID: "add unique index user_suspension_org_id_user_id"},
+	{Version: schemaVersionPermissionExpiry, MigrationID: "add index permission.expires_at"},
+	{Version: schemaVersionPermissionScopeSegments, MigrationID: "add index permission.scope_kind_scope_attribute_scope_identifier"},
+	{Version: schemaVersionUserPolicy, MigrationID: "add index user_policy.user_id"},
+	{Version: schemaVersionBuiltinRolePolicy, MigrationID: "add unique index builtin_role_policy_org_id_role_policy_id"},
+	{Version: schemaVersionServiceAccount, MigrationID: "add unique index service_account_org_id_user_id"},
+	{Version: schemaVersionAPIKeyPolicy, MigrationID: "add unique index api_key_policy_org_id_api_key_id_policy_id"},
+	{Version: schemaVersionPolicyPrecedence, MigrationID: "set precedence of suspended policies"},
+	{Version: schemaVersionPolicyEnabled, MigrationID: "add enabled column to policy table"},
+	{Version: schemaVersionBindingExpiry, MigrationID: "add expires_at column to user_policy table"},
+	{Version: schemaVersionDefaultPolicy, MigrationID: "add unique index default_policy_org_id_policy_id"},
+	{Version: schemaVersionRoleMigration, MigrationID: "add index role_migration.org_id"},
+	{Version: schemaVersionUserPolicySync, MigrationID: "add unique index user_policy_sync_org_id_user_id_policy_id"},
+	{Version: schemaVersionBindingLifetime, MigrationID: "add unique index user_policy_expiry_notice_user_policy_id"},
+	{Version: schemaVersionExternalGroups, MigrationID: "add index external_group_member.user_id"},
+	{Version: schemaVersionPolicyGroupMapping, MigrationID: "add index policy_group_mapping.org_id_group_id"},
+	{Version: schemaVersionBreakGlass, MigrationID: "add index break_glass_grant.org_id_created"},
+	{Version: schemaVersionAccessRequest, MigrationID: "add index access_request.org_id_user_id"},
+	{Version: schemaVersionBindingGrant, MigrationID: "add reason column to user_policy table"},
+	{Version: schemaVersionEnforcementMode, MigrationID: "add unique index enforcement_mode.action"},
+}
+
+const (
+	// schemaVersionInitial adds the policy, permission and team_policy tables.
+	schemaVersionInitial = 1
+	// schemaVersionPermissionKind adds the kind column to the permission table.
+	schemaVersionPermissionKind = 2
+	// schemaVersionPermissionConditions adds the conditions column to the permission table.
+	schemaVersionPermissionConditions = 3
+	// schemaVersionPermissionScopePrefix adds the indexed scope_prefix column to the permission table.
+	schemaVersionPermissionScopePrefix = 4
+	// schemaVersionUserSuspension adds the user_suspension table.
+	schemaVersionUserSuspension = 5
+	// schemaVersionPermissionExpiry adds the expires_at column to the permission table.
+	schemaVersionPermissionExpiry = 6
+	// schemaVersionPermissionScopeSegments adds the indexed scope_kind, scope_attribute and scope_identifier
+	// columns to the permission table.
+	schemaVersionPermissionScopeSegments = 7
+	// schemaVersionUserPolicy adds the user_policy table.
+	schemaVersionUserPolicy = 8
+	// schemaVersionBuiltinRolePolicy adds the builtin_role_policy table.
+	schemaVersionBuiltinRolePolicy = 9
+	// schemaVersionServiceAccount adds the service_account table.
+	schemaVersionServiceAccount = 10
+	// schemaVersionAPIKeyPolicy adds the api_key_policy table.
+	schemaVersionAPIKeyPolicy = 11
+	// schemaVersionPolicyPrecedence adds the precedence column to the policy table.
+	schemaVersionPolicyPrecedence = 12
+	// schemaVersionPolicyEnabled adds the enabled column to the policy table.
+	schemaVersionPolicyEnabled = 13
+	// schemaVersionBindingExpiry adds the expires_at column to the team_policy and user_policy tables.
+	schemaVersionBindingExpiry = 14
+	// schemaVersionDefaultPolicy adds the default_policy table.
+	schemaVersionDefaultPolicy = 15
+	// schemaVersionRoleMigration adds the role_migration table.
+	schemaVersionRoleMigration = 16
+	// schemaVersionUserPolicySync adds the user_policy_sync table.
+	schemaVersionUserPolicySync = 17
+	// schemaVersionBindingLifetime adds the binding_lifetime and user_policy_expiry_notice tables.
+	schemaVersionBindingLifetime = 18
+	// schemaVersionExternalGroups adds the external_group_policy and external_group_member tables.
+	schemaVersionExternalGroups = 19
+	// schemaVersionPolicyGroupMapping adds the policy_group_mapping table.
+	schemaVersionPolicyGroupMapping = 20
+	// schemaVersionBreakGlass adds the break_glass_grant table.
+	schemaVersionBreakGlass = 21
+	// schemaVersionAccessRequest adds the access_request table.
+	schemaVersionAccessRequest = 22
+	// schemaVersionBindingGrant adds the granted_by and reason columns to the team_policy and user_policy tables.
+	schemaVersionBindingGrant = 23
+	// schemaVersionEnforcementMode adds the enforcement_mode table.
+	schemaVersionEnforcementMode = 24
+)
+
+type schemaVersion struct {
+	Version     int
+	MigrationID string
+}
+
+// supportedSchemaVersion is the most recent RBAC schema version known to this version of Grafana.
+var supportedSchemaVersion = schemaVersions[len(schemaVersions)-1].Version
+
+func addRBACMigrations(mg *migrator.Migrator) {
+	policyV1 := migrator.Table{
+		Name: "policy",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "uid", Type: migrator.DB_NVarchar, Length: 40, Nullable: false},
+			{Name: "name", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "description", Type: migrator.DB_Text, Nullable: true},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id"}},
+			{Cols: []string{"org_id", "name"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"org_id", "uid"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create policy table v1", migrator.NewAddTableMigration(policyV1))
+	mg.AddMigration("add index policy.org_id", migrator.NewAddIndexMigration(policyV1, policyV1.Indices[0]))
+	mg.AddMigration("add unique index policy_org_id_name", migrator.NewAddIndexMigration(policyV1, policyV1.Indices[1]))
+	mg.AddMigration("add unique index policy_org_id_uid", migrator.NewAddIndexMigration(policyV1, policyV1.Indices[2]))
+
+	permissionV1 := migrator.Table{
+		Name: "permission",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "action", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "scope", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"policy_id"}},
+			{Cols: []string{"policy_id", "action", "scope"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create permission table v1", migrator.NewAddTableMigration(permissionV1))
+	mg.AddMigration("add index permission.policy_id", migrator.NewAddIndexMigration(permissionV1, permissionV1.Indices[0]))
+	mg.AddMigration("add unique index permission_policy_id_action_scope", migrator.NewAddIndexMigration(permissionV1, permissionV1.Indices[1]))
+
+	teamPolicyV1 := migrator.Table{
+		Name: "team_policy",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "team_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id"}},
+			{Cols: []string{"org_id", "team_id", "policy_id"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"team_id"}},
+		},
+	}
+
+	mg.AddMigration("create team policy table v1", migrator.NewAddTableMigration(teamPolicyV1))
+	mg.AddMigration("add index team_policy.org_id", migrator.NewAddIndexMigration(teamPolicyV1, teamPolicyV1.Indices[0]))
+	mg.AddMigration("add unique index team_policy_org_id_team_id_policy_id", migrator.NewAddIndexMigration(teamPolicyV1, teamPolicyV1.Indices[1]))
+	mg.AddMigration("add index team_policy.team_id", migrator.NewAddIndexMigration(teamPolicyV1, teamPolicyV1.Indices[2]))
+
+	// A NULL kind is an allow permission, so rows written by older versions keep their meaning.
+	mg.AddMigration("add kind column to permission table", migrator.NewAddColumnMigration(permissionV1, &migrator.Column{
+		Name: "kind", Type: migrator.DB_NVarchar, Length: 10, Nullable: true,
+	}))
+
+	mg.AddMigration("add conditions column to permission table", migrator.NewAddColumnMigration(permissionV1, &migrator.Column{
+		Name: "conditions", Type: migrator.DB_Text, Nullable: true,
+	}))
+
+	scopePrefixColumn := &migrator.Column{Name: "scope_prefix", Type: migrator.DB_NVarchar, Length: 190, Nullable: true}
+	mg.AddMigration("add scope_prefix column to permission table", migrator.NewAddColumnMigration(permissionV1, scopePrefixColumn))
+	mg.AddMigration("populate permission.scope_prefix", &populateScopePrefixMigration{})
+	mg.AddMigration("add index permission.scope_prefix", migrator.NewAddIndexMigration(permissionV1, &migrator.Index{
+		Cols: []string{"scope_prefix"},
+	}))
+
+	userSuspensionV1 := migrator.Table{
+		Name: "user_suspension",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "user_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "suspended_by", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "reason", Type: migrator.DB_Text, Nullable: true},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "expires", Type: migrator.DB_DateTime, Nullable: true},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "user_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create user suspension table v1", migrator.NewAddTableMigration(userSuspensionV1))
+	mg.AddMigration("add unique index user_suspension_org_id_user_id", migrator.NewAddIndexMigration(userSuspensionV1, userSuspensionV1.Indices[0]))
+
+	mg.AddMigration("add expires_at column to permission table", migrator.NewAddColumnMigration(permissionV1, &migrator.Column{
+		Name: "expires_at", Type: migrator.DB_DateTime, Nullable: true,
+	}))
+	mg.AddMigration("add index permission.expires_at", migrator.NewAddIndexMigration(permissionV1, &migrator.Index{
+		Cols: []string{"expires_at"},
+	}))
+
+	for _, name := range []string{"scope_kind", "scope_attribute", "scope_identifier"} {
+		mg.AddMigration("add "+name+" column to permission table", migrator.NewAddColumnMigration(permissionV1, &migrator.Column{
+			Name: name, Type: migrator.DB_NVarchar, Length: 190, Nullable: true,
+		}))
+	}
+	mg.AddMigration("populate permission scope segments", &populateScopeSegmentsMigration{})
+	mg.AddMigration("add index permission.scope_kind_scope_attribute_scope_identifier", migrator.NewAddIndexMigration(permissionV1, &migrator.Index{
+		Cols: []string{"scope_kind", "scope_attribute", "scope_identifier"},
+	}))
+
+	userPolicyV1 := migrator.Table{
+		Name: "user_policy",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "user_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id"}},
+			{Cols: []string{"org_id", "user_id", "policy_id"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"user_id"}},
+		},
+	}
+
+	mg.AddMigration("create user policy table v1", migrator.NewAddTableMigration(userPolicyV1))
+	mg.AddMigration("add index user_policy.org_id", migrator.NewAddIndexMigration(userPolicyV1, userPolicyV1.Indices[0]))
+	mg.AddMigration("add unique index user_policy_org_id_user_id_policy_id", migrator.NewAddIndexMigration(userPolicyV1, userPolicyV1.Indices[1]))
+	mg.AddMigration("add index user_policy.user_id", migrator.NewAddIndexMigration(userPolicyV1, userPolicyV1.Indices[2]))
+
+	builtinRolePolicyV1 := migrator.Table{
+		Name: "builtin_role_policy",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "role", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "role"}},
+			{Cols: []string{"org_id", "role", "policy_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create builtin role policy table v1", migrator.NewAddTableMigration(builtinRolePolicyV1))
+	mg.AddMigration("add index builtin_role_policy.org_id_role", migrator.NewAddIndexMigration(builtinRolePolicyV1, builtinRolePolicyV1.Indices[0]))
+	mg.AddMigration("add unique index builtin_role_policy_org_id_role_policy_id", migrator.NewAddIndexMigration(builtinRolePolicyV1, builtinRolePolicyV1.Indices[1]))
+
+	serviceAccountV1 := migrator.Table{
+		Name: "service_account",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "user_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "user_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create service account table v1", migrator.NewAddTableMigration(serviceAccountV1))
+	mg.AddMigration("add unique index service_account_org_id_user_id", migrator.NewAddIndexMigration(serviceAccountV1, serviceAccountV1.Indices[0]))
+
+	apiKeyPolicyV1 := migrator.Table{
+		Name: "api_key_policy",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "api_key_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created_by", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "api_key_id", "policy_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create api key policy table v1", migrator.NewAddTableMigration(apiKeyPolicyV1))
+	mg.AddMigration("add unique index api_key_policy_org_id_api_key_id_policy_id", migrator.NewAddIndexMigration(apiKeyPolicyV1, apiKeyPolicyV1.Indices[0]))
+
+	mg.AddMigration("add precedence column to policy table", migrator.NewAddColumnMigration(policyV1, &migrator.Column{
+		Name: "precedence", Type: migrator.DB_Int, Nullable: true,
+	}))
+	mg.AddMigration("set precedence of suspended policies", migrator.NewRawSQLMigration(
+		fmt.Sprintf("UPDATE policy SET precedence = %d WHERE uid = '%s'", suspendedPolicyPrecedence, suspendedPolicy.UID)))
+
+	mg.AddMigration("add enabled column to policy table", migrator.NewAddColumnMigration(policyV1, &migrator.Column{
+		Name: "enabled", Type: migrator.DB_Bool, Nullable: false, Default: "1",
+	}))
+
+	mg.AddMigration("add expires_at column to team_policy table", migrator.NewAddColumnMigration(teamPolicyV1, &migrator.Column{
+		Name: "expires_at", Type: migrator.DB_DateTime, Nullable: true,
+	}))
+	mg.AddMigration("add expires_at column to user_policy table", migrator.NewAddColumnMigration(userPolicyV1, &migrator.Column{
+		Name: "expires_at", Type: migrator.DB_DateTime, Nullable: true,
+	}))
+
+	defaultPolicyV1 := migrator.Table{
+		Name: "default_policy",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "policy_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create default policy table v1", migrator.NewAddTableMigration(defaultPolicyV1))
+	mg.AddMigration("add unique index default_policy_org_id_policy_id", migrator.NewAddIndexMigration(defaultPolicyV1, defaultPolicyV1.Indices[0]))
+
+	roleMigrationV1 := migrator.Table{
+		Name: "role_migration",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "performed_by", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "snapshot", Type: migrator.DB_MediumText, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "rolled_back", Type: migrator.DB_DateTime, Nullable: true},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id"}},
+		},
+	}
+
+	mg.AddMigration("create role migration table v1", migrator.NewAddTableMigration(roleMigrationV1))
+	mg.AddMigration("add index role_migration.org_id", migrator.NewAddIndexMigration(roleMigrationV1, roleMigrationV1.Indices[0]))
+
+	userPolicySyncV1 := migrator.Table{
+		Name: "user_policy_sync",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "user_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "auth_module", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"user_id", "auth_module"}},
+			{Cols: []string{"org_id", "user_id", "policy_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create user policy sync table v1", migrator.NewAddTableMigration(userPolicySyncV1))
+	mg.AddMigration("add index user_policy_sync.user_id_auth_module", migrator.NewAddIndexMigration(userPolicySyncV1, userPolicySyncV1.Indices[0]))
+	mg.AddMigration("add unique index user_policy_sync_org_id_user_id_policy_id", migrator.NewAddIndexMigration(userPolicySyncV1, userPolicySyncV1.Indices[1]))
+
+	bindingLifetimeV1 := migrator.Table{
+		Name: "binding_lifetime",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "max_days", Type: migrator.DB_Int, Nullable: false},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create binding lifetime table v1", migrator.NewAddTableMigration(bindingLifetimeV1))
+	mg.AddMigration("add unique index binding_lifetime_org_id", migrator.NewAddIndexMigration(bindingLifetimeV1, bindingLifetimeV1.Indices[0]))
+
+	userPolicyExpiryNoticeV1 := migrator.Table{
+		Name: "user_policy_expiry_notice",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "user_policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"user_policy_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create user policy expiry notice table v1", migrator.NewAddTableMigration(userPolicyExpiryNoticeV1))
+	mg.AddMigration("add unique index user_policy_expiry_notice_user_policy_id", migrator.NewAddIndexMigration(userPolicyExpiryNoticeV1, userPolicyExpiryNoticeV1.Indices[0]))
+
+	externalGroupPolicyV1 := migrator.Table{
+		Name: "external_group_policy",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "group_id", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "group_id", "policy_id"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create external group policy table v1", migrator.NewAddTableMigration(externalGroupPolicyV1))
+	mg.AddMigration("add unique index external_group_policy_org_id_group_id_policy_id", migrator.NewAddIndexMigration(externalGroupPolicyV1, externalGroupPolicyV1.Indices[0]))
+
+	externalGroupMemberV1 := migrator.Table{
+		Name: "external_group_member",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "group_id", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "user_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "group_id", "user_id"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"user_id"}},
+		},
+	}
+
+	mg.AddMigration("create external group member table v1", migrator.NewAddTableMigration(externalGroupMemberV1))
+	mg.AddMigration("add unique index external_group_member_org_id_group_id_user_id", migrator.NewAddIndexMigration(externalGroupMemberV1, externalGroupMemberV1.Indices[0]))
+	mg.AddMigration("add index external_group_member.user_id", migrator.NewAddIndexMigration(externalGroupMemberV1, externalGroupMemberV1.Indices[1]))
+
+	policyGroupMappingV1 := migrator.Table{
+		Name: "policy_group_mapping",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "group_id", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "policy_id", "group_id"}, Type: migrator.UniqueIndex},
+			{Cols: []string{"org_id", "group_id"}},
+		},
+	}
+
+	mg.AddMigration("create policy group mapping table v1", migrator.NewAddTableMigration(policyGroupMappingV1))
+	mg.AddMigration("add unique index policy_group_mapping_org_id_policy_id_group_id", migrator.NewAddIndexMigration(policyGroupMappingV1, policyGroupMappingV1.Indices[0]))
+	mg.AddMigration("add index policy_group_mapping.org_id_group_id", migrator.NewAddIndexMigration(policyGroupMappingV1, policyGroupMappingV1.Indices[1]))
+
+	breakGlassGrantV1 := migrator.Table{
+		Name: "break_glass_grant",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "user_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "justification", Type: migrator.DB_Text, Nullable: true},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "expires_at", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "created"}},
+		},
+	}
+
+	mg.AddMigration("create break glass grant table v1", migrator.NewAddTableMigration(breakGlassGrantV1))
+	mg.AddMigration("add index break_glass_grant.org_id_created", migrator.NewAddIndexMigration(breakGlassGrantV1, breakGlassGrantV1.Indices[0]))
+
+	accessRequestV1 := migrator.Table{
+		Name: "access_request",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "org_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "user_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "policy_id", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "justification", Type: migrator.DB_Text, Nullable: true},
+			{Name: "duration_seconds", Type: migrator.DB_BigInt, Nullable: false},
+			{Name: "state", Type: migrator.DB_NVarchar, Length: 20, Nullable: false},
+			{Name: "reviewed_by", Type: migrator.DB_BigInt, Nullable: true},
+			{Name: "review_comment", Type: migrator.DB_Text, Nullable: true},
+			{Name: "created", Type: migrator.DB_DateTime, Nullable: false},
+			{Name: "reviewed", Type: migrator.DB_DateTime, Nullable: true},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"org_id", "state"}},
+			{Cols: []string{"org_id", "user_id"}},
+		},
+	}
+
+	mg.AddMigration("create access request table v1", migrator.NewAddTableMigration(accessRequestV1))
+	mg.AddMigration("add index access_request.org_id_state", migrator.NewAddIndexMigration(accessRequestV1, accessRequestV1.Indices[0]))
+	mg.AddMigration("add index access_request.org_id_user_id", migrator.NewAddIndexMigration(accessRequestV1, accessRequestV1.Indices[1]))
+
+	for _, table := range []migrator.Table{teamPolicyV1, userPolicyV1} {
+		mg.AddMigration("add granted_by column to "+table.Name+" table", migrator.NewAddColumnMigration(table, &migrator.Column{
+			Name: "granted_by", Type: migrator.DB_BigInt, Nullable: true,
+		}))
+		mg.AddMigration("add reason column to "+table.Name+" table", migrator.NewAddColumnMigration(table, &migrator.Column{
+			Name: "reason", Type: migrator.DB_Text, Nullable: true,
+		}))
+	}
+
+	enforcementModeV1 := migrator.Table{
+		Name: "enforcement_mode",
+		Columns: []*migrator.Column{
+			{Name: "id", Type: migrator.DB_BigInt, IsPrimaryKey: true, IsAutoIncrement: true},
+			{Name: "action", Type: migrator.DB_NVarchar, Length: 190, Nullable: false},
+			{Name: "mode", Type: migrator.DB_NVarchar, Length: 20, Nullable: false},
+			{Name: "updated", Type: migrator.DB_DateTime, Nullable: false},
+		},
+		Indices: []*migrator.Index{
+			{Cols: []string{"action"}, Type: migrator.UniqueIndex},
+		},
+	}
+
+	mg.AddMigration("create enforcement mode table v1", migrator.NewAddTableMigration(enforcementModeV1))
+	mg.AddMigration("add unique index enforcement_mode.action", migrator.NewAddIndexMigration(enforcementModeV1, enforcementModeV1.Indices[0]))
+}
+
+// populateScopePrefixMigration fills the scope prefix of permissions created before the column existed.
+type populateScopePrefixMigration struct {
+	migrator.MigrationBase
+}
+
+func (m *populateScopePrefixMigration) SQL(dialect migrator.Dialect) string {
+	return "code migration"
+}
+
+func (m *populateScopePrefixMigration) Exec(sess *xorm.Session, mg *migrator.Migrator) error {
+	var permissions []struct {
+		ID    int64 `xorm:"id"`
+		Scope string
+	}
+	if err := sess.SQL("SELECT id, scope FROM permission WHERE scope_prefix IS NULL").Find(&permissions); err != nil {
+		return err
+	}
+
+	for _, p := range permissions {
+		if _, err := sess.Exec("UPDATE permission SET scope_prefix = ? WHERE id = ?", scopePrefix(p.Scope), p.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
+
+// populateScopeSegmentsMigration fills the scope segments of permissions created before the columns existed.
+type populateScopeSegmentsMigration struct {
+	migrator.MigrationBase
+}
+
+func (m *populateScopeSegmentsMigration) SQL(dialect migrator.Dialect) string {
+	return "code migration"
+}
+
+func (m *populateScopeSegmentsMigration) Exec(sess *xorm.Session, mg *migrator.Migrator) error {
+	var permissions []struct {
+		ID    int64 `xorm:"id"`
+		Scope string
+	}
+	if err := sess.SQL("SELECT id, scope FROM permission WHERE scope_kind IS NULL").Find(&permissions); err != nil {
+		return err
+	}
+
+	for _, p := range permissions {
+		kind, attribute, identifier := splitScope(p.Scope)
+		if _, err := sess.Exec("UPDATE permission SET scope_kind = ?, scope_attribute = ?, scope_identifier = ? WHERE id = ?",
+			kind, attribute, identifier, p.ID); err != nil {
+			return err
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,1092 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// Policy is the model for a named set of permissions within an organization.
+type Policy struct {
+	ID          int64  `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID       int64  `json:"orgId" xorm:"org_id"`
+	UID         string `json:"uid" xorm:"uid"`
+	Name        string `json:"name"`
+	Description string `json:"description"`
+	// Precedence resolves conflicts with other policies, see evaluatePermissions. Nil ranks lowest.
+	Precedence *int `json:"precedence,omitempty"`
+	// Enabled is false when the policy is deactivated, its permissions are then ignored but its bindings are kept.
+	Enabled bool `json:"enabled"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// PolicyDTO is the model used to return a policy together with its permissions.
+type PolicyDTO struct {
+	ID          int64        `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID       int64        `json:"orgId" xorm:"org_id"`
+	UID         string       `json:"uid" xorm:"uid"`
+	Name        string       `json:"name"`
+	Description string       `json:"description"`
+	Precedence  *int         `json:"precedence,omitempty"`
+	Enabled     bool         `json:"enabled"`
+	Permissions []Permission `json:"permissions,omitempty" xorm:"-"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// Permission is the model for a single action allowed or denied on a scope by a policy.
+type Permission struct {
+	ID       int64  `json:"id" xorm:"pk autoincr 'id'"`
+	PolicyID int64  `json:"-" xorm:"policy_id"`
+	Action   string `json:"action"`
+	Scope    string `json:"scope"`
+	// ScopePrefix holds the first two segments of the scope, it's indexed for prefix lookups.
+	ScopePrefix string `json:"-" xorm:"scope_prefix"`
+	// ScopeKind, ScopeAttribute and ScopeIdentifier hold the segments of the scope, they're indexed
+	// for structured lookups. Scope stays the source of truth.
+	ScopeKind       string `json:"-" xorm:"scope_kind"`
+	ScopeAttribute  string `json:"-" xorm:"scope_attribute"`
+	ScopeIdentifier string `json:"-" xorm:"scope_identifier"`
+	Kind            string `json:"kind"`
+	// Conditions restrict the permission to access checks with matching attributes.
+	Conditions []Condition `json:"conditions,omitempty"`
+	// ExpiresAt is when the permission stops applying and gets deleted, nil means it never expires.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" xorm:"expires_at"`
+	// Precedence is the precedence of the permission's policy, it's only loaded for evaluation.
+	Precedence *int `json:"-" xorm:"-"`
+
+	Created time.Time `json:"created"`
+	Updated time.Time `json:"updated"`
+}
+
+// IsDeny returns true if the permission denies its action instead of allowing it.
+func (p Permission) IsDeny() bool {
+	return p.Kind == PermissionKindDeny
+}
+
+// Permission kinds. A permission without a kind allows its action.
+const (
+	PermissionKindAllow = "allow"
+	PermissionKindDeny  = "deny"
+)
+
+// TeamPolicy is the model for a binding between a team and a policy.
+type TeamPolicy struct {
+	ID       int64 `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID    int64 `json:"orgId" xorm:"org_id"`
+	PolicyID int64 `json:"policyId" xorm:"policy_id"`
+	TeamID   int64 `json:"teamId" xorm:"team_id"`
+	// ExpiresAt is when the binding stops applying and gets deleted, nil means it never expires.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" xorm:"expires_at"`
+	// GrantedBy is the user who created the binding, zero for bindings created by Grafana, e.g. at login.
+	GrantedBy int64 `json:"grantedBy,omitempty" xorm:"granted_by"`
+	// Reason is the justification given when creating the binding.
+	Reason string `json:"reason,omitempty" xorm:"reason"`
+
+	Created time.Time `json:"created"`
+}
+
+// UserPolicy is the model for a policy bound directly to a user.
+type UserPolicy struct {
+	ID       int64 `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID    int64 `json:"orgId" xorm:"org_id"`
+	PolicyID int64 `json:"policyId" xorm:"policy_id"`
+	UserID   int64 `json:"userId" xorm:"user_id"`
+	// ExpiresAt is when the binding stops applying and gets deleted, nil means it never expires.
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" xorm:"expires_at"`
+	// GrantedBy is the user who created the binding, zero for bindings created by Grafana, e.g. at login.
+	GrantedBy int64 `json:"grantedBy,omitempty" xorm:"granted_by"`
+	// Reason is the justification given when creating the binding.
+	Reason string `json:"reason,omitempty" xorm:"reason"`
+
+	Created time.Time `json:"created"`
+}
+
+// ServiceAccount is the model marking a user as a machine identity. A service account's permissions
+// come solely from the policies bound to it, it doesn't inherit those of its role or teams.
+type ServiceAccount struct {
+	ID     int64 `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID  int64 `json:"orgId" xorm:"org_id"`
+	UserID int64 `json:"userId" xorm:"user_id"`
+
+	Created time.Time `json:"created"`
+}
+
+// APIKeyPolicy is the model for a policy attached to an API key. CreatedBy is the user who attached
+// it, the key can't do more than what that user can.
+type APIKeyPolicy struct {
+	ID        int64 `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID     int64 `json:"orgId" xorm:"org_id"`
+	APIKeyID  int64 `json:"apiKeyId" xorm:"api_key_id"`
+	PolicyID  int64 `json:"policyId" xorm:"policy_id"`
+	CreatedBy int64 `json:"createdBy" xorm:"created_by"`
+
+	Created time.Time `json:"created"`
+}
+
+// UserPolicySync is the model for a user policy binding made by an auth module sync, e.g. from
+// LDAP group policy mappings. The sync only unbinds the policies it bound itself.
+type UserPolicySync struct {
+	ID         int64  `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID      int64  `json:"orgId" xorm:"org_id"`
+	UserID     int64  `json:"userId" xorm:"user_id"`
+	PolicyID   int64  `json:"policyId" xorm:"policy_id"`
+	AuthModule string `json:"authModule" xorm:"auth_module"`
+
+	Created time.Time `json:"created"`
+}
+
+// ExternalGroupPolicy is the model for a policy bound to the members of a group provisioned by an
+// identity provider, e.g. through SCIM.
+type ExternalGroupPolicy struct {
+	ID       int64  `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID    int64  `json:"orgId" xorm:"org_id"`
+	GroupID  string `json:"groupId" xorm:"group_id"`
+	PolicyID int64  `json:"policyId" xorm:"policy_id"`
+
+	Created time.Time `json:"created"`
+}
+
+// ExternalGroupMember is the model for a user the identity provider made a member of an external group.
+type ExternalGroupMember struct {
+	ID      int64  `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID   int64  `json:"orgId" xorm:"org_id"`
+	GroupID string `json:"groupId" xorm:"group_id"`
+	UserID  int64  `json:"userId" xorm:"user_id"`
+
+	Created time.Time `json:"created"`
+}
+
+// PolicyGroupMapping is the model for a policy bound at login to the users belonging to an external
+// group, e.g. an LDAP group DN or an OAuth group id.
+type PolicyGroupMapping struct {
+	ID       int64  `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID    int64  `json:"orgId" xorm:"org_id"`
+	PolicyID int64  `json:"policyId" xorm:"policy_id"`
+	GroupID  string `json:"groupId" xorm:"group_id"`
+
+	Created time.Time `json:"created"`
+}
+
+// BindingLifetime is the model for the maximum lifetime of direct user policy bindings in an organization.
+type BindingLifetime struct {
+	ID    int64 `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID int64 `json:"orgId" xorm:"org_id"`
+	// MaxDays is the number of days direct bindings last unless renewed, 0 means they don't expire.
+	MaxDays int `json:"maxDays" xorm:"max_days"`
+
+	Updated time.Time `json:"updated"`
+}
+
+// EnforcementMode is the model for the enforcement mode of an action, or of the actions matching a
+// wildcard pattern such as dashboards:*, in every organization.
+type EnforcementMode struct {
+	ID     int64  `json:"id" xorm:"pk autoincr 'id'"`
+	Action string `json:"action"`
+	Mode   string `json:"mode"`
+
+	Updated time.Time `json:"updated"`
+}
+
+// UserPolicyExpiryNotice is the model marking a user policy binding whose upcoming expiry has been notified.
+type UserPolicyExpiryNotice struct {
+	ID           int64 `json:"id" xorm:"pk autoincr 'id'"`
+	UserPolicyID int64 `json:"userPolicyId" xorm:"user_policy_id"`
+
+	Created time.Time `json:"created"`
+}
+
+// DefaultPolicy is the model for a policy bound to every user added to its organization.
+type DefaultPolicy struct {
+	ID       int64 `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID    int64 `json:"orgId" xorm:"org_id"`
+	PolicyID int64 `json:"policyId" xorm:"policy_id"`
+
+	Created time.Time `json:"created"`
+}
+
+// BuiltinRolePolicy is the model for a policy bound to a builtin role, e.g. Viewer or Grafana Admin.
+type BuiltinRolePolicy struct {
+	ID       int64  `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID    int64  `json:"orgId" xorm:"org_id"`
+	PolicyID int64  `json:"policyId" xorm:"policy_id"`
+	Role     string `json:"role"`
+
+	Created time.Time `json:"created"`
+}
+
+// UserSuspension is the model for a suspension binding the managed deny-all policy to a user.
+type UserSuspension struct {
+	ID          int64  `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID       int64  `json:"orgId" xorm:"org_id"`
+	UserID      int64  `json:"userId" xorm:"user_id"`
+	PolicyID    int64  `json:"-" xorm:"policy_id"`
+	SuspendedBy int64  `json:"suspendedBy" xorm:"suspended_by"`
+	Reason      string `json:"reason"`
+
+	Created time.Time `json:"created"`
+	// Expires is when the suspension lapses, nil means it lasts until the user is resumed.
+	Expires *time.Time `json:"expires,omitempty"`
+}
+
+var (
+	// ErrPolicyNotFound is an error for when a policy can't be found.
+	ErrPolicyNotFound = errors.New("policy not found")
+	// ErrPolicyAlreadyExists is an error for when a policy with the same name or uid already exists.
+	ErrPolicyAlreadyExists = errors.New("policy with that name or uid already exists")
+	// ErrPermissionNotFound is an error for when a permission can't be found.
+	ErrPermissionNotFound = errors.New("permission not found")
+	// ErrPermissionAlreadyExists is an error for when a policy already grants the same action on the same scope.
+	ErrPermissionAlreadyExists = errors.New("permission already exists in this policy")
+	// ErrInvalidPrecedence is an error for when a policy precedence is in the range reserved for managed policies.
+	ErrInvalidPrecedence = fmt.Errorf("policy precedence must be lower than %d", suspendedPolicyPrecedence)
+	// ErrInvalidPermissionKind is an error for when a permission kind is neither allow nor deny.
+	ErrInvalidPermissionKind = errors.New("permission kind must be either allow or deny")
+	// ErrPermissionExpiryInPast is an error for when a permission is written with an expiry that has already passed.
+	ErrPermissionExpiryInPast = errors.New("permission expiry must be in the future")
+	// ErrBindingExpiryInPast is an error for when a policy binding is created with an expiry that has already passed.
+	ErrBindingExpiryInPast = errors.New("policy binding expiry must be in the future")
+	// ErrTeamPolicyAlreadyAdded is an error for when a policy is already bound to a team.
+	ErrTeamPolicyAlreadyAdded = errors.New("policy is already added to this team")
+	// ErrTeamPolicyNotFound is an error for when a team policy binding can't be found.
+	ErrTeamPolicyNotFound = errors.New("team policy not found")
+	// ErrUserPolicyAlreadyAdded is an error for when a policy is already bound to a user.
+	ErrUserPolicyAlreadyAdded = errors.New("policy is already added to this user")
+	// ErrUserPolicyNotFound is an error for when a user policy binding can't be found.
+	ErrUserPolicyNotFound = errors.New("user policy not found")
+	// ErrServiceAccountAlreadyExists is an error for when a user already is a service account.
+	ErrServiceAccountAlreadyExists = errors.New("user is already a service account")
+	// ErrServiceAccountNotFound is an error for when a user isn't a service account.
+	ErrServiceAccountNotFound = errors.New("service account not found")
+	// ErrAPIKeyPolicyAlreadyAdded is an error for when a policy is already attached to an API key.
+	ErrAPIKeyPolicyAlreadyAdded = errors.New("policy is already attached to this API key")
+	// ErrAPIKeyPolicyNotFound is an error for when an API key policy binding can't be found.
+	ErrAPIKeyPolicyNotFound = errors.New("API key policy not found")
+	// ErrAPIKeyLastPolicy is an error for when the last policy of an API key is removed without allowing
+	// the key to get the permissions of its role again.
+	ErrAPIKeyLastPolicy = errors.New("removing the last policy of an API key gives it the permissions of its role again")
+	// ErrAPIKeyDelegationRefused is an error for when permissions are delegated for an API key with policies,
+	// whose access can't be carried by a single set of permissions.
+	ErrAPIKeyDelegationRefused = errors.New("permissions of API keys with policies can't be delegated")
+	// ErrBuiltinRolePolicyAlreadyAdded is an error for when a policy is already bound to a builtin role.
+	ErrBuiltinRolePolicyAlreadyAdded = errors.New("policy is already added to this builtin role")
+	// ErrBuiltinRolePolicyNotFound is an error for when a builtin role policy binding can't be found.
+	ErrBuiltinRolePolicyNotFound = errors.New("builtin role policy not found")
+	// ErrDefaultPolicyAlreadyAdded is an error for when a policy already is a default policy of its organization.
+	ErrDefaultPolicyAlreadyAdded = errors.New("policy is already a default policy")
+	// ErrDefaultPolicyNotFound is an error for when a policy isn't a default policy of the organization.
+	ErrDefaultPolicyNotFound = errors.New("default policy not found")
+	// ErrExternalGroupPolicyAlreadyAdded is an error for when a policy is already bound to an external group.
+	ErrExternalGroupPolicyAlreadyAdded = errors.New("policy is already added to this external group")
+	// ErrExternalGroupPolicyNotFound is an error for when a policy isn't bound to the external group.
+	ErrExternalGroupPolicyNotFound = errors.New("external group policy not found")
+	// ErrInvalidExternalGroup is an error for when an external group id is empty or too long.
+	ErrInvalidExternalGroup = errors.New("external group id must be between 1 and 190 characters")
+	// ErrPolicyGroupMappingAlreadyExists is an error for when a policy is already mapped to an external group.
+	ErrPolicyGroupMappingAlreadyExists = errors.New("policy is already mapped to this group")
+	// ErrPolicyGroupMappingNotFound is an error for when a policy isn't mapped to the external group.
+	ErrPolicyGroupMappingNotFound = errors.New("policy group mapping not found")
+	// ErrRoleMigrationNotFound is an error for when a role migration can't be found.
+	ErrRoleMigrationNotFound = errors.New("role migration not found")
+	// ErrRoleMigrationRolledBack is an error for when a role migration has already been rolled back.
+	ErrRoleMigrationRolledBack = errors.New("role migration has already been rolled back")
+	// ErrInvalidBuiltinRole is an error for when a role isn't one of the builtin roles.
+	ErrInvalidBuiltinRole = errors.New("role must be Viewer, Editor, Admin, Grafana Admin or Anonymous")
+	// ErrUserAlreadySuspended is an error for when a user's access is already suspended.
+	ErrUserAlreadySuspended = errors.New("user access is already suspended")
+	// ErrUserNotSuspended is an error for when a user's access isn't suspended.
+	ErrUserNotSuspended = errors.New("user access is not suspended")
+	// ErrPermissionLimitExceeded is an error for when a policy would hold too many permissions, see PermissionLimitError.
+	ErrPermissionLimitExceeded = errors.New("too many permissions in policy")
+	// ErrInvalidBindingLifetime is an error for when a binding lifetime is negative.
+	ErrInvalidBindingLifetime = errors.New("binding lifetime must be a positive number of days, or 0 to disable it")
+	// ErrBindingExpiryBeyondLifetime is an error for when a user policy binding would outlive the binding lifetime of its organization.
+	ErrBindingExpiryBeyondLifetime = errors.New("policy binding expiry exceeds the binding lifetime of the organization")
+	// ErrManagedPolicy is an error for when a policy managed by Grafana would be created, changed or deleted.
+	ErrManagedPolicy = errors.New("policies with a uid starting with managed- are managed by Grafana")
+	// ErrPolicyUIDRequired is an error for when a policy is created without uid while uids are supplied externally.
+	ErrPolicyUIDRequired = errors.New("policy uid is required")
+	// ErrInvalidAssignee is an error for when a resource permission isn't assigned to exactly one team or user.
+	ErrInvalidAssignee = errors.New("resource permissions must be assigned to either a team or a user")
+	// ErrBreakGlassNotConfigured is an error for when no break-glass policy is configured or it doesn't exist in the organization.
+	ErrBreakGlassNotConfigured = errors.New("break-glass policy not configured")
+	// ErrInvalidBreakGlassDuration is an error for when a break-glass elevation isn't positive or exceeds the maximum duration.
+	ErrInvalidBreakGlassDuration = errors.New("break-glass duration must be positive and within the maximum duration")
+	// ErrBreakGlassJustificationRequired is an error for when a break-glass elevation lacks the required justification.
+	ErrBreakGlassJustificationRequired = errors.New("break-glass justification is required")
+	// ErrBreakGlassActive is an error for when a user already holds the break-glass policy.
+	ErrBreakGlassActive = errors.New("break-glass policy is already bound to this user")
+	// ErrAccessRequestNotFound is an error for when an access request can't be found.
+	ErrAccessRequestNotFound = errors.New("access request not found")
+	// ErrAccessRequestPending is an error for when a user already has a pending request for the policy.
+	ErrAccessRequestPending = errors.New("an access request for this policy is already pending")
+	// ErrAccessRequestReviewed is an error for when an access request has already been approved or denied.
+	ErrAccessRequestReviewed = errors.New("access request has already been reviewed")
+	// ErrAccessRequestSelfReview is an error for when users review their own access request.
+	ErrAccessRequestSelfReview = errors.New("access requests can't be reviewed by their requester")
+	// ErrInvalidAccessRequest is an error for when the requested policy is managed by Grafana or the duration is negative.
+	ErrInvalidAccessRequest = errors.New("managed policies can't be requested and the duration mustn't be negative")
+	// ErrInvalidEnforcementMode is an error for when an enforcement mode is neither disabled, log nor enforce.
+	ErrInvalidEnforcementMode = errors.New("enforcement mode must be disabled, log or enforce")
+	// ErrEnforcementModeNotFound is an error for when an action has no enforcement mode.
+	ErrEnforcementModeNotFound = errors.New("enforcement mode not found")
+	// ErrUnknownResourceType is an error for when no registered action belongs to a resource type.
+	ErrUnknownResourceType = errors.New("unknown resource type")
+)
+
+// Commands and queries
+
+// GetPolicyQuery is the query for getting a single policy by id or uid.
+type GetPolicyQuery struct {
+	OrgID    int64
+	PolicyID int64
+	UID      string
+}
+
+// CreatePolicyCommand is the command for adding a policy.
+type CreatePolicyCommand struct {
+	OrgID       int64  `json:"-"`
+	UID         string `json:"uid"`
+	Name        string `json:"name" binding:"Required"`
+	Description string `json:"description"`
+	Precedence  *int   `json:"precedence"`
+}
+
+// UpdatePolicyCommand is the command for updating a policy.
+type UpdatePolicyCommand struct {
+	ID          int64  `json:"-"`
+	OrgID       int64  `json:"-"`
+	UID         string `json:"uid"`
+	Name        string `json:"name" binding:"Required"`
+	Description string `json:"description"`
+	Precedence  *int   `json:"precedence"`
+}
+
+// SetPolicyEnabledCommand is the command for activating or deactivating a policy.
+type SetPolicyEnabledCommand struct {
+	ID      int64 `json:"-"`
+	OrgID   int64 `json:"-"`
+	Enabled bool  `json:"enabled"`
+}
+
+// DeletePolicyCommand is the command for deleting a policy together with its permissions and bindings.
+type DeletePolicyCommand struct {
+	ID    int64
+	OrgID int64
+}
+
+// SetResourcePermissionCommand is the command for setting the actions a team or a user can perform on
+// a single resource. Exactly one of TeamID and UserID must be set.
+type SetResourcePermissionCommand struct {
+	OrgID int64
+	// Scope identifies the resource, e.g. dashboards:uid:abc.
+	Scope  string
+	TeamID int64
+	UserID int64
+	// Actions replace the ones previously granted, no actions removes the team's access to the resource.
+	Actions []string
+}
+
+// GetPolicyPermissionsQuery is the query for listing the permissions of a policy.
+type GetPolicyPermissionsQuery struct {
+	OrgID    int64
+	PolicyID int64
+	// ActionPrefix only returns permissions whose action starts with the prefix, e.g. "dashboards:".
+	ActionPrefix string
+	// ResourceType only returns permissions scoped to the resource type, e.g. "dashboards"
+	// matches "dashboards:uid:abc" and "dashboards:*".
+	ResourceType string
+}
+
+// GetScopePermissionsQuery is the query for listing the permissions of an organization on a resource,
+// e.g. every permission on datasource 4 with Kind "datasources", Attribute "id" and Identifier "4".
+// Attribute and Identifier are optional.
+type GetScopePermissionsQuery struct {
+	OrgID      int64
+	Kind       string
+	Attribute  string
+	Identifier string
+}
+
+// GetGrantingPoliciesQuery is the query for finding the policies granting an action on a scope.
+type GetGrantingPoliciesQuery struct {
+	OrgID  int64
+	Action string
+	Scope  string
+}
+
+// CreatePermissionCommand is the command for adding a permission to a policy.
+type CreatePermissionCommand struct {
+	OrgID      int64       `json:"-"`
+	PolicyID   int64       `json:"-"`
+	Action     string      `json:"action" binding:"Required"`
+	Scope      string      `json:"scope"`
+	Kind       string      `json:"kind"`
+	Conditions []Condition `json:"conditions"`
+	ExpiresAt  *time.Time  `json:"expiresAt"`
+}
+
+// UpdatePermissionCommand is the command for updating a permission.
+type UpdatePermissionCommand struct {
+	OrgID      int64       `json:"-"`
+	ID         int64       `json:"-"`
+	Action     string      `json:"action" binding:"Required"`
+	Scope      string      `json:"scope"`
+	Kind       string      `json:"kind"`
+	Conditions []Condition `json:"conditions"`
+	ExpiresAt  *time.Time  `json:"expiresAt"`
+}
+
+// DeletePermissionCommand is the command for removing a permission.
+type DeletePermissionCommand struct {
+	OrgID int64
+	ID    int64
+}
+
+// AddTeamPolicyCommand is the command for binding a policy to a team.
+type AddTeamPolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+	TeamID   int64
+	// ExpiresAt makes the binding temporary, e.g. for incident response.
+	ExpiresAt *time.Time
+	// PerformedBy is the user making the change, told in the binding events and stored as GrantedBy.
+	PerformedBy int64
+	// Reason is an optional justification of the binding, e.g. a ticket.
+	Reason string
+}
+
+// RemoveTeamPolicyCommand is the command for unbinding a policy from a team.
+type RemoveTeamPolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+	TeamID   int64
+	// PerformedBy is the user making the change, told in the binding events.
+	PerformedBy int64
+}
+
+// RemoveAllTeamPoliciesCommand is the command for unbinding every policy from a team.
+type RemoveAllTeamPoliciesCommand struct {
+	OrgID  int64
+	TeamID int64
+	// PerformedBy is the user making the change, told in the binding events.
+	PerformedBy int64
+}
+
+// CreateTeamWithFolderCommand is the command for creating a team along with its dedicated folder.
+type CreateTeamWithFolderCommand struct {
+	OrgID int64
+	Name  string
+	Email string
+	// UserID is the user creating the team, recorded as the folder's creator.
+	UserID int64
+}
+
+// TeamWithFolder is a team and the folder provisioned for it.
+type TeamWithFolder struct {
+	Team   models.Team       `json:"team"`
+	Folder *models.Dashboard `json:"folder"`
+}
+
+// SetTeamPoliciesCommand is the command for replacing the full set of policies bound to a team.
+type SetTeamPoliciesCommand struct {
+	OrgID     int64
+	TeamID    int64
+	PolicyIDs []int64
+	// PerformedBy is the user making the change, told in the binding events.
+	PerformedBy int64
+}
+
+// GetPolicyTeamsQuery is the query for listing the teams a policy is bound to, a page at a time.
+type GetPolicyTeamsQuery struct {
+	OrgID    int64
+	PolicyID int64
+	// Page starts at 1, Limit zero returns every team.
+	Page  int
+	Limit int
+}
+
+// PolicyTeam is a team a policy is bound to.
+type PolicyTeam struct {
+	TeamID    int64      `json:"teamId" xorm:"team_id"`
+	TeamName  string     `json:"teamName" xorm:"team_name"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" xorm:"expires_at"`
+	GrantedBy int64      `json:"grantedBy,omitempty" xorm:"granted_by"`
+	Reason    string     `json:"reason,omitempty" xorm:"reason"`
+	Created   time.Time  `json:"created" xorm:"created"`
+}
+
+// PolicyTeamsResult is a page of the teams a policy is bound to.
+type PolicyTeamsResult struct {
+	TotalCount int64         `json:"totalCount"`
+	Teams      []*PolicyTeam `json:"teams"`
+	Page       int           `json:"page"`
+	PerPage    int           `json:"perPage"`
+}
+
+// PoliciesResult is a page of the policies bound to a team or a user.
+type PoliciesResult struct {
+	TotalCount int64        `json:"totalCount"`
+	Policies   []*PolicyDTO `json:"policies"`
+	Page       int          `json:"page"`
+	PerPage    int          `json:"perPage"`
+}
+
+// GetPolicyAssignmentsQuery is the query for listing everything a policy is bound to.
+type GetPolicyAssignmentsQuery struct {
+	OrgID    int64
+	PolicyID int64
+}
+
+// Kinds of policy assignments.
+const (
+	AssignmentKindTeam           = "team"
+	AssignmentKindUser           = "user"
+	AssignmentKindServiceAccount = "serviceAccount"
+	AssignmentKindBuiltinRole    = "builtinRole"
+	AssignmentKindAPIKey         = "apiKey"
+)
+
+// PolicyAssignment is a team, user, service account, builtin role or API key a policy is bound to.
+type PolicyAssignment struct {
+	Kind string `json:"kind" xorm:"kind"`
+	// ID is the id of the team, user or API key. Builtin roles have no id.
+	ID int64 `json:"id,omitempty" xorm:"id"`
+	// Name is the name of the team, builtin role or API key, or the login of the user.
+	Name      string     `json:"name" xorm:"name"`
+	ExpiresAt *time.Time `json:"expiresAt,omitempty" xorm:"expires_at"`
+	// GrantedBy and Reason tell who bound the policy to a team, user or service account, and why.
+	GrantedBy int64  `json:"grantedBy,omitempty" xorm:"granted_by"`
+	Reason    string `json:"reason,omitempty" xorm:"reason"`
+}
+
+// PolicyAssignments lists who holds a policy, grouped by kind.
+type PolicyAssignments struct {
+	Teams           []*PolicyAssignment `json:"teams"`
+	Users           []*PolicyAssignment `json:"users"`
+	ServiceAccounts []*PolicyAssignment `json:"serviceAccounts"`
+	BuiltinRoles    []*PolicyAssignment `json:"builtinRoles"`
+	APIKeys         []*PolicyAssignment `json:"apiKeys"`
+	// Total is the number of assignments of every kind.
+	Total int `json:"total"`
+}
+
+// GetTeamPoliciesQuery is the query for listing the policies bound to a team.
+type GetTeamPoliciesQuery struct {
+	OrgID  int64
+	TeamID int64
+	// Page starts at 1, Limit zero returns every policy.
+	Page  int
+	Limit int
+}
+
+// AddUserPolicyCommand is the command for binding a policy to a user.
+type AddUserPolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+	UserID   int64
+	// ExpiresAt makes the binding temporary, e.g. for contractors.
+	ExpiresAt *time.Time
+	// PerformedBy is the user making the change, told in the binding events and stored as GrantedBy.
+	PerformedBy int64
+	// Reason is an optional justification of the binding, e.g. a ticket.
+	Reason string
+}
+
+// RenewUserPolicyCommand is the command for extending a policy binding of a user.
+type RenewUserPolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+	UserID   int64
+	// ExpiresAt is the new expiry, nil to extend the binding by the binding lifetime of the
+	// organization, or to make it permanent when the organization doesn't have one.
+	ExpiresAt *time.Time
+}
+
+// SetBindingLifetimeCommand is the command for setting the maximum lifetime of the direct user
+// policy bindings of an organization.
+type SetBindingLifetimeCommand struct {
+	OrgID int64
+	// MaxDays is the number of days bindings last unless renewed, 0 lifts the limit.
+	MaxDays int
+}
+
+// SetEnforcementModeCommand is the command for setting the enforcement mode of an action or of the
+// actions matching a wildcard pattern.
+type SetEnforcementModeCommand struct {
+	Action string
+	// Mode is disabled, log or enforce.
+	Mode string
+}
+
+// RemoveUserPolicyCommand is the command for unbinding a policy from a user.
+type RemoveUserPolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+	UserID   int64
+	// PerformedBy is the user making the change, told in the binding events.
+	PerformedBy int64
+}
+
+// GetUserPoliciesQuery is the query for listing the policies bound directly to a user.
+type GetUserPoliciesQuery struct {
+	OrgID  int64
+	UserID int64
+	// Page starts at 1, Limit zero returns every policy.
+	Page  int
+	Limit int
+}
+
+// GetPoliciesForUserQuery is the query for listing every policy that applies to a user.
+type GetPoliciesForUserQuery struct {
+	OrgID  int64
+	UserID int64
+	// Roles are the builtin roles of the user, see BuiltinRoles.
+	Roles []string
+}
+
+// CreateServiceAccountCommand is the command for turning a user into a service account.
+type CreateServiceAccountCommand struct {
+	OrgID  int64
+	UserID int64
+}
+
+// DeleteServiceAccountCommand is the command for turning a service account back into a regular user.
+type DeleteServiceAccountCommand struct {
+	OrgID  int64
+	UserID int64
+	// PerformedBy is the user making the change, told in the binding events.
+	PerformedBy int64
+}
+
+// AddServiceAccountPolicyCommand is the command for binding a policy to a service account.
+type AddServiceAccountPolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+	UserID   int64
+	// PerformedBy is the user making the change, told in the binding events.
+	PerformedBy int64
+}
+
+// RemoveServiceAccountPolicyCommand is the command for unbinding a policy from a service account.
+type RemoveServiceAccountPolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+	UserID   int64
+	// PerformedBy is the user making the change, told in the binding events.
+	PerformedBy int64
+}
+
+// GetServiceAccountPoliciesQuery is the query for listing the policies bound to a service account.
+type GetServiceAccountPoliciesQuery struct {
+	OrgID  int64
+	UserID int64
+}
+
+// AddAPIKeyPolicyCommand is the command for attaching a policy to an API key.
+type AddAPIKeyPolicyCommand struct {
+	OrgID    int64
+	APIKeyID int64
+	PolicyID int64
+	// CreatedBy is the user attaching the policy.
+	CreatedBy int64
+}
+
+// RemoveAPIKeyPolicyCommand is the command for detaching a policy from an API key.
+type RemoveAPIKeyPolicyCommand struct {
+	OrgID    int64
+	APIKeyID int64
+	PolicyID int64
+	// AllowRoleAccess must be set to remove the last policy of the key, which gives the key the
+	// permissions of its role again.
+	AllowRoleAccess bool
+}
+
+// GetAPIKeyPoliciesQuery is the query for listing the policies attached to an API key.
+type GetAPIKeyPoliciesQuery struct {
+	OrgID    int64
+	APIKeyID int64
+}
+
+// GetUserPermissionsQuery is the query for listing every permission a user holds directly or through
+// their teams and builtin roles.
+type GetUserPermissionsQuery struct {
+	OrgID  int64
+	UserID int64
+	// Roles are the builtin roles of the user, see BuiltinRoles.
+	Roles []string
+}
+
+// ExplainAccessQuery is the query for explaining why an access check of a user is allowed or denied.
+type ExplainAccessQuery struct {
+	User   *models.SignedInUser
+	Action string
+	Scope  string
+}
+
+// AccessExplanation is the decision of an access check along with the permissions of the user whose
+// action and scope match it.
+type AccessExplanation struct {
+	Allowed bool               `json:"allowed"`
+	Matches []*PermissionMatch `json:"matches"`
+}
+
+// PermissionMatch is a permission whose action and scope match an access check, with the policy and
+// the binding it's granted through, e.g. team 3 bound to policy 12.
+type PermissionMatch struct {
+	PolicyID   int64      `json:"policyId"`
+	PolicyUID  string     `json:"policyUid"`
+	PolicyName string     `json:"policyName"`
+	Precedence *int       `json:"precedence"`
+	Permission Permission `json:"permission"`
+	// Binding is the kind of assignment granting the policy: user, team, builtinRole or suspension.
+	Binding string `json:"binding"`
+	TeamID  int64  `json:"teamId,omitempty"`
+	Role    string `json:"role,omitempty"`
+	// ConditionsMatch is false when the permission's conditions don't match the access check.
+	ConditionsMatch bool `json:"conditionsMatch"`
+	// Decisive is set on the matches the decision was taken on, those whose conditions match in the
+	// policies with the highest precedence.
+	Decisive bool `json:"decisive"`
+}
+
+// GetResourceActionsQuery is the query for the actions a user may perform on resources of a type,
+// e.g. dashboards, identified by their uid.
+type GetResourceActionsQuery struct {
+	User     *models.SignedInUser
+	Resource string
+	UIDs     []string
+}
+
+// SimulatedPermission is a permission added to a policy of the user's organization by a simulation.
+type SimulatedPermission struct {
+	PolicyID   int64       `json:"policyId"`
+	Action     string      `json:"action"`
+	Scope      string      `json:"scope"`
+	Kind       string      `json:"kind"`
+	Conditions []Condition `json:"conditions"`
+}
+
+// SimulatedChanges are hypothetical changes to the policies of an organization and their bindings to a user.
+type SimulatedChanges struct {
+	AddPermissions []SimulatedPermission `json:"addPermissions"`
+	// RemovePermissions are the ids of the permissions to remove.
+	RemovePermissions []int64 `json:"removePermissions"`
+	// BindPolicies are the ids of the policies to bind to the user.
+	BindPolicies []int64 `json:"bindPolicies"`
+	// UnbindPolicies are the ids of the policies to unbind from the user, whether they're bound to the
+	// user, to one of the user's teams or to one of the user's builtin roles.
+	UnbindPolicies []int64 `json:"unbindPolicies"`
+}
+
+// SimulateAccessCommand is the command for evaluating an access check of a user against hypothetical changes.
+type SimulateAccessCommand struct {
+	User    *models.SignedInUser
+	Action  string
+	Scope   string
+	Changes SimulatedChanges
+}
+
+// SimulationResult is the decision of an access check before and after the simulated changes.
+type SimulationResult struct {
+	Before  bool `json:"before"`
+	After   bool `json:"after"`
+	Changed bool `json:"changed"`
+}
+
+// AddBuiltinRolePolicyCommand is the command for binding a policy to a builtin role.
+type AddBuiltinRolePolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+	Role     string
+}
+
+// RemoveBuiltinRolePolicyCommand is the command for unbinding a policy from a builtin role.
+type RemoveBuiltinRolePolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+	Role     string
+}
+
+// AddDefaultPolicyCommand is the command for making a policy a default policy of its organization.
+type AddDefaultPolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+}
+
+// RemoveDefaultPolicyCommand is the command for removing a policy from the default policies of an organization.
+type RemoveDefaultPolicyCommand struct {
+	OrgID    int64
+	PolicyID int64
+}
+
+// AddExternalGroupPolicyCommand is the command for binding a policy to the members of an external group.
+type AddExternalGroupPolicyCommand struct {
+	OrgID    int64
+	GroupID  string
+	PolicyID int64
+}
+
+// RemoveExternalGroupPolicyCommand is the command for unbinding a policy from the members of an external group.
+type RemoveExternalGroupPolicyCommand struct {
+	OrgID    int64
+	GroupID  string
+	PolicyID int64
+}
+
+// SetExternalGroupMembersCommand is the command for replacing the members of an external group,
+// e.g. when the identity provider pushes the group.
+type SetExternalGroupMembersCommand struct {
+	OrgID   int64
+	GroupID string
+	UserIDs []int64
+}
+
+// DeleteExternalGroupCommand is the command for removing an external group with its members and policies.
+type DeleteExternalGroupCommand struct {
+	OrgID   int64
+	GroupID string
+}
+
+// AddPolicyGroupMappingCommand is the command for mapping a policy to an external group.
+type AddPolicyGroupMappingCommand struct {
+	OrgID    int64
+	PolicyID int64
+	GroupID  string
+}
+
+// RemovePolicyGroupMappingCommand is the command for removing the mapping of a policy to an external group.
+type RemovePolicyGroupMappingCommand struct {
+	OrgID    int64
+	PolicyID int64
+	GroupID  string
+}
+
+// GetPolicyGroupMappingsQuery is the query for listing the external group mappings of an organization.
+// PolicyID, when set, restricts them to those of a policy.
+type GetPolicyGroupMappingsQuery struct {
+	OrgID    int64
+	PolicyID int64
+}
+
+// GetBuiltinRolePoliciesQuery is the query for listing the policies bound to a builtin role.
+type GetBuiltinRolePoliciesQuery struct {
+	OrgID int64
+	Role  string
+}
+
+// GetUserPermissionsAtQuery is the query for reconstructing the permissions a user held at a past time.
+type GetUserPermissionsAtQuery struct {
+	OrgID  int64
+	UserID int64
+	// Roles are the builtin roles of the user, see BuiltinRoles.
+	Roles []string
+	At    time.Time
+}
+
+// UserPermissionsAtResult is the reconstruction of the permissions a user held at a past time.
+type UserPermissionsAtResult struct {
+	// Permissions are the permissions that existed unchanged at that time.
+	Permissions []Permission `json:"permissions"`
+	// Modified are permissions the user held at that time which were updated since,
+	// their action, scope or conditions might have been different back then.
+	Modified []Permission `json:"modified"`
+}
+
+// MigrateOrgRolesCommand is the command for moving the access of an organization's users from
+// their organization role to policies.
+type MigrateOrgRolesCommand struct {
+	OrgID       int64
+	PerformedBy int64
+	// Policies maps organization roles to the ids of the policies bound to the users holding them.
+	// Users whose role isn't mapped are left as they are.
+	Policies map[models.RoleType][]int64
+	// TargetRole, when set, replaces the role of the migrated users so that their access comes
+	// from the policies, e.g. Viewer.
+	TargetRole models.RoleType
+}
+
+// RollbackRoleMigrationCommand is the command for undoing a role migration.
+type RollbackRoleMigrationCommand struct {
+	OrgID       int64
+	ID          int64
+	PerformedBy int64
+}
+
+// RoleMigration is the model for a role migration, its snapshot holds what's needed to roll it back.
+type RoleMigration struct {
+	ID          int64      `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID       int64      `json:"orgId" xorm:"org_id"`
+	PerformedBy int64      `json:"performedBy" xorm:"performed_by"`
+	Snapshot    string     `json:"-"`
+	Created     time.Time  `json:"created"`
+	RolledBack  *time.Time `json:"rolledBack,omitempty" xorm:"rolled_back"`
+
+	Users []RoleMigrationUser `json:"users" xorm:"-"`
+}
+
+// RoleMigrationUser is the change a role migration made to a user.
+type RoleMigrationUser struct {
+	UserID       int64           `json:"userId"`
+	PreviousRole models.RoleType `json:"previousRole"`
+	// AddedPolicyIDs are the policies the migration bound to the user, other mapped policies
+	// were already bound and are left in place on rollback.
+	AddedPolicyIDs []int64 `json:"addedPolicyIds"`
+}
+
+// RevokeAllUserAccessCommand is the command for revoking every access a user holds in an organization.
+type RevokeAllUserAccessCommand struct {
+	OrgID  int64
+	UserID int64
+	// PerformedBy is the user making the change, told in the binding events.
+	PerformedBy int64
+}
+
+// RevokeAllUserAccessResult reports the access that a revocation couldn't remove by itself.
+type RevokeAllUserAccessResult struct {
+	// RemovedUserPolicies is the number of policies that were bound directly to the user.
+	RemovedUserPolicies int64 `json:"removedUserPolicies"`
+	// TeamPolicies are the policies the user still holds through team membership. They
+	// are shared with the other members, so they are flagged for review instead of removed.
+	TeamPolicies []*UserTeamPolicy `json:"teamPolicies"`
+}
+
+// UserTeamPolicy is a policy a user holds through one of their teams.
+type UserTeamPolicy struct {
+	TeamID     int64  `json:"teamId" xorm:"team_id"`
+	TeamName   string `json:"teamName" xorm:"team_name"`
+	PolicyID   int64  `json:"policyId" xorm:"policy_id"`
+	PolicyName string `json:"policyName" xorm:"policy_name"`
+}
+
+// SuspendUserAccessCommand is the command for suspending every access of a user in an organization.
+type SuspendUserAccessCommand struct {
+	OrgID       int64
+	UserID      int64
+	SuspendedBy int64
+	Reason      string
+	// Expires optionally ends the suspension automatically.
+	Expires *time.Time
+}
+
+// ResumeUserAccessCommand is the command for lifting the suspension of a user.
+type ResumeUserAccessCommand struct {
+	OrgID     int64
+	UserID    int64
+	ResumedBy int64
+}
+
+// BreakGlassCommand is the command for a user to elevate themselves to the break-glass policy.
+type BreakGlassCommand struct {
+	OrgID  int64
+	UserID int64
+	// Duration is how long the elevation lasts, the maximum duration when zero.
+	Duration      time.Duration
+	Justification string
+}
+
+// BreakGlassGrant is the model for a break-glass elevation, kept after it expires as an audit trail.
+type BreakGlassGrant struct {
+	ID            int64     `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID         int64     `json:"orgId" xorm:"org_id"`
+	UserID        int64     `json:"userId" xorm:"user_id"`
+	PolicyID      int64     `json:"policyId" xorm:"policy_id"`
+	Justification string    `json:"justification"`
+	Created       time.Time `json:"created"`
+	ExpiresAt     time.Time `json:"expiresAt" xorm:"expires_at"`
+}
+
+// GetBreakGlassGrantsQuery is the query for listing the break-glass elevations of an organization,
+// optionally of a single user.
+type GetBreakGlassGrantsQuery struct {
+	OrgID  int64
+	UserID int64
+	Limit  int
+}
+
+// States of access requests.
+const (
+	AccessRequestPending  = "pending"
+	AccessRequestApproved = "approved"
+	AccessRequestDenied   = "denied"
+)
+
+// AccessRequest is the model for a request of a user to be bound to a policy, until an approver
+// approves or denies it.
+type AccessRequest struct {
+	ID            int64  `json:"id" xorm:"pk autoincr 'id'"`
+	OrgID         int64  `json:"orgId" xorm:"org_id"`
+	UserID        int64  `json:"userId" xorm:"user_id"`
+	PolicyID      int64  `json:"policyId" xorm:"policy_id"`
+	Justification string `json:"justification"`
+	// DurationSeconds is how long the binding lasts once approved, 0 for a permanent binding.
+	DurationSeconds int64  `json:"durationSeconds" xorm:"duration_seconds"`
+	State           string `json:"state"`
+	ReviewedBy      int64  `json:"reviewedBy,omitempty" xorm:"reviewed_by"`
+	ReviewComment   string `json:"reviewComment,omitempty" xorm:"review_comment"`
+
+	Created  time.Time  `json:"created"`
+	Reviewed *time.Time `json:"reviewed,omitempty"`
+
+	// PolicyUID is the uid of the requested policy, approvers need accessrequests:approve on its scope.
+	PolicyUID string `json:"policyUid" xorm:"-"`
+}
+
+// CreateAccessRequestCommand is the command for a user to request being bound to a policy.
+type CreateAccessRequestCommand struct {
+	OrgID         int64
+	UserID        int64
+	PolicyID      int64
+	Justification string
+	// Duration makes the binding temporary once approved.
+	Duration time.Duration
+}
+
+// ReviewAccessRequestCommand is the command for approving or denying an access request.
+type ReviewAccessRequestCommand struct {
+	OrgID      int64
+	ID         int64
+	ReviewedBy int64
+	Approve    bool
+	Comment    string
+}
+
+// GetAccessRequestsQuery is the query for listing the access requests of an organization, optionally
+// of a single user or in a single state.
+type GetAccessRequestsQuery struct {
+	OrgID  int64
+	UserID int64
+	State  string
+}
+
+// GetOrphanedPoliciesQuery is the query for listing the policies of an organization that have no
+// assignments or no permissions.
+type GetOrphanedPoliciesQuery struct {
+	OrgID int64
+	// UnchangedFor only reports the policies that haven't been updated for that long, so that policies
+	// still being set up aren't reported.
+	UnchangedFor time.Duration
+}
+
+// OrphanedPolicy is a policy that grants nothing, along with its assignment and permission counts.
+type OrphanedPolicy struct {
+	Policy `xorm:"extends"`
+	// Assignments counts the unexpired bindings of the policy, and the default policy, external group and
+	// group mapping configurations referencing it.
+	Assignments int64 `json:"assignments" xorm:"assignments"`
+	// Permissions counts the unexpired permissions of the policy.
+	Permissions int64 `json:"permissions" xorm:"permissions"`
+}
+
+// OrphanedPoliciesResult lists the orphaned policies of an organization, with counts for a cleanup UI.
+type OrphanedPoliciesResult struct {
+	Policies           []*OrphanedPolicy `json:"policies"`
+	TotalCount         int               `json:"totalCount"`
+	WithoutAssignments int               `json:"withoutAssignments"`
+	WithoutPermissions int               `json:"withoutPermissions"`
+}
@@ -0,0 +1,120 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"net"
+	"net/http"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/infra/network"
+)
+
+type remoteAddrKey struct{}
+
+// WithRemoteAddr returns a copy of the context carrying the remote address of the request
+// being authorized, so that source network conditions can be evaluated.
+func WithRemoteAddr(ctx context.Context, addr string) context.Context {
+	return context.WithValue(ctx, remoteAddrKey{}, addr)
+}
+
+func remoteAddrFromContext(ctx context.Context) string {
+	addr, _ := ctx.Value(remoteAddrKey{}).(string)
+	return addr
+}
+
+// ClientAddr returns the address of the client that sent the request, for evaluating source network
+// conditions. The X-Forwarded-For header is only trusted for the hops added by the proxies listed in
+// the rbac.trusted_proxies setting, since clients can set it to any value.
+func (ac *RBACService) ClientAddr(r *http.Request) string {
+	ip, err := network.GetIPFromAddress(r.RemoteAddr)
+	if err != nil {
+		return r.RemoteAddr
+	}
+	if !ac.isTrustedProxy(ip) {
+		return ip.String()
+	}
+
+	return ac.forwardedClient(r.Header.Values("X-Forwarded-For"), ip).String()
+}
+
+// forwardedClient returns the client of the X-Forwarded-For header values sent by a trusted proxy,
+// or else the address of the proxy.
+func (ac *RBACService) forwardedClient(headers []string, proxy net.IP) net.IP {
+	var hops []string
+	for _, header := range headers {
+		hops = append(hops, strings.Split(header, ",")...)
+	}
+	// Every proxy appends the address it received the request from, so the client is
+	// the rightmost hop that wasn't added by a trusted proxy.
+	ip := proxy
+	for i := len(hops) - 1; i >= 0; i-- {
+		hop, err := network.GetIPFromAddress(strings.TrimSpace(hops[i]))
+		if err != nil {
+			break
+		}
+		ip = hop
+		if !ac.isTrustedProxy(ip) {
+			break
+		}
+	}
+	return ip
+}
+
+func (ac *RBACService) isTrustedProxy(ip net.IP) bool {
+	for _, ipNet := range ac.trustedProxies {
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+// loadTrustedProxies reads the CIDR ranges of the trusted proxies from the settings.
+func (ac *RBACService) loadTrustedProxies() error {
+	ac.trustedProxies = nil
+	for _, cidr := range strings.Split(ac.Cfg.Raw.Section("rbac").Key("trusted_proxies").String(), ",") {
+		if cidr = strings.TrimSpace(cidr); cidr == "" {
+			continue
+		}
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			return fmt.Errorf("invalid rbac.trusted_proxies %q, expected CIDR notation", cidr)
+		}
+		ac.trustedProxies = append(ac.trustedProxies, ipNet)
+	}
+
+	return nil
+}
+
+// matchSourceNetworks returns true if the address, optionally including a port, belongs to one of the CIDR ranges.
+// Addresses that can't be parsed never match.
+func matchSourceNetworks(cidrs []string, addr string) bool {
+	ip, err := network.GetIPFromAddress(addr)
+	if err != nil {
+		return false
+	}
+
+	for _, cidr := range cidrs {
+		_, ipNet, err := net.ParseCIDR(cidr)
+		if err != nil {
+			continue
+		}
+		if ipNet.Contains(ip) {
+			return true
+		}
+	}
+
+	return false
+}
+
+func validateSourceNetworks(cidrs []string) error {
+	for _, cidr := range cidrs {
+		if _, _, err := net.ParseCIDR(cidr); err != nil {
+			return fmt.Errorf("invalid source network %q, expected CIDR notation", cidr)
+		}
+	}
+
+	return nil
+}
@@ -0,0 +1,524 @@
+package rbac
+
+import (
+	"archive/tar"
+	"compress/gzip"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"sort"
+	"strconv"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+const defaultOPABundleInterval = 5 * time.Minute
+
+// opaBundleRoot is the path of the bundle's data and Rego module, the data is served by OPA
+// under data.grafana.rbac.
+const opaBundleRoot = "grafana/rbac"
+
+// opaRegoModule is the reference Rego module of the OPA bundles. It decides the access checks
+// like evaluatePermissions does with deny overrides: only the permissions of the bound policies
+// with the highest precedence count and a deny among them wins. Conditions aren't evaluated, the
+// permissions with conditions never allow and always deny.
+const opaRegoModule = `package grafana.rbac.authz
+
+# Decides the access checks of Grafana users with the policies and bindings exported by Grafana, e.g.
+# {"orgId": 1, "userId": 2, "action": "dashboards:read", "scope": "dashboards:uid:abc"}. The scope may
+# be omitted for actions without scope.
+
+import data.grafana.rbac.orgs
+
+default allow = false
+
+org := orgs[format_int(input.orgId, 10)]
+
+user := org.users[format_int(input.userId, 10)]
+
+scope := object.get(input, "scope", "")
+
+# Service accounts only get the policies bound to them.
+bound[uid] {
+	uid := user.policies[_]
+}
+
+bound[uid] {
+	not user.serviceAccount
+	uid := org.teams[user.teams[_]].policies[_]
+}
+
+bound[uid] {
+	not user.serviceAccount
+	uid := org.builtinRoles[user.roles[_]][_]
+}
+
+applicable[[uid, permission]] {
+	bound[uid]
+	permission := org.policies[uid].permissions[_]
+	matches(permission.action, input.action)
+	matches(permission.scope, scope)
+}
+
+# Policies without precedence rank below all others.
+rank(uid) = precedence {
+	precedence := org.policies[uid].precedence
+} else = -1e18 {
+	true
+}
+
+highest := max({rank(uid) | applicable[[uid, _]]})
+
+denied {
+	applicable[[uid, permission]]
+	rank(uid) == highest
+	permission.kind == "deny"
+}
+
+allow {
+	applicable[[uid, permission]]
+	rank(uid) == highest
+	permission.kind != "deny"
+	not permission.conditional
+	not denied
+}
+
+# A "*" segment matches one or more trailing segments.
+matches(pattern, value) {
+	not contains(pattern, "*")
+	pattern == value
+}
+
+matches(pattern, value) {
+	prefix := substring(pattern, 0, indexof(pattern, "*"))
+	count(value) > count(prefix)
+	startswith(value, prefix)
+}
+`
+
+// OPABundle is the policies and bindings of organizations exported as the data of an Open Policy
+// Agent bundle, so that other services can decide access checks like Grafana does.
+type OPABundle struct {
+	// Revision is the checksum of the data, it only changes along with the policies and bindings.
+	Revision string    `json:"revision"`
+	Exported time.Time `json:"exported"`
+	Data     OPAData   `json:"data"`
+}
+
+// OPAData holds the organizations of an OPA bundle keyed by id.
+type OPAData struct {
+	Orgs map[string]*OPAOrg `json:"orgs"`
+}
+
+// OPAOrg holds the enabled policies of an organization keyed by uid, along with the users, teams and
+// builtin roles they're bound to. Users and teams are keyed by id.
+type OPAOrg struct {
+	Policies     map[string]*OPAPolicy `json:"policies"`
+	Users        map[string]*OPAUser   `json:"users"`
+	Teams        map[string]*OPATeam   `json:"teams"`
+	BuiltinRoles map[string][]string   `json:"builtinRoles"`
+}
+
+// OPAPolicy holds the unexpired permissions of a policy.
+type OPAPolicy struct {
+	Name        string          `json:"name"`
+	Precedence  *int            `json:"precedence,omitempty"`
+	Permissions []OPAPermission `json:"permissions"`
+}
+
+// OPAPermission is a permission of an OPA bundle. Conditional is set when the permission has
+// conditions, which the reference module doesn't evaluate.
+type OPAPermission struct {
+	Action      string `json:"action"`
+	Scope       string `json:"scope"`
+	Kind        string `json:"kind"`
+	Conditional bool   `json:"conditional,omitempty"`
+}
+
+// OPAUser holds the builtin roles and teams of a user and the uids of the policies bound to the user,
+// including the policy of the user's active suspension.
+type OPAUser struct {
+	Roles          []string `json:"roles"`
+	Teams          []string `json:"teams"`
+	Policies       []string `json:"policies"`
+	ServiceAccount bool     `json:"serviceAccount,omitempty"`
+}
+
+// OPATeam holds the uids of the policies bound to a team.
+type OPATeam struct {
+	Policies []string `json:"policies"`
+}
+
+// opaBundleExporter writes the OPA bundle of every organization to a file on a schedule and whenever
+// permissions change, see loadOPABundleSettings.
+type opaBundleExporter struct {
+	path     string
+	interval time.Duration
+	// changes is signaled by permission changes, pending changes are coalesced into one export.
+	changes chan struct{}
+	// revision is the revision of the last bundle written.
+	revision string
+}
+
+// loadOPABundleSettings writes the OPA bundle of every organization to the file of the
+// rbac.opa_bundle_path setting every rbac.opa_bundle_interval, and whenever permissions change on this
+// instance. The file is only rewritten when the revision changes. Disabled when the path is empty.
+func (ac *RBACService) loadOPABundleSettings() {
+	section := ac.Cfg.Raw.Section("rbac")
+	ac.opaBundle = nil
+	path := section.Key("opa_bundle_path").MustString("")
+	if path == "" {
+		return
+	}
+
+	ac.opaBundle = &opaBundleExporter{
+		path:     path,
+		interval: section.Key("opa_bundle_interval").MustDuration(defaultOPABundleInterval),
+		changes:  make(chan struct{}, 1),
+	}
+}
+
+// changed schedules an export of the OPA bundle, nothing is done when the export is disabled.
+func (e *opaBundleExporter) changed() {
+	if e == nil {
+		return
+	}
+
+	select {
+	case e.changes <- struct{}{}:
+	default:
+	}
+}
+
+// runOPABundleExport writes the OPA bundle at startup, on schedule and on change until ctx is done.
+func (ac *RBACService) runOPABundleExport(ctx context.Context) {
+	e := ac.opaBundle
+	if e == nil {
+		return
+	}
+
+	// The schedule channel stays nil, and never fires, when the bundle is only written on change.
+	var schedule <-chan time.Time
+	if e.interval > 0 {
+		ticker := time.NewTicker(e.interval)
+		defer ticker.Stop()
+		schedule = ticker.C
+	}
+	e.changed()
+	for {
+		select {
+		case <-e.changes:
+		case <-schedule:
+		case <-ctx.Done():
+			return
+		}
+		if ac.IsDegraded() {
+			continue
+		}
+		if err := ac.writeOPABundle(ctx); err != nil {
+			ac.log.Error("Failed to write the OPA bundle", "path", e.path, "error", err)
+		}
+	}
+}
+
+// writeOPABundle exports the OPA bundle of every organization and replaces the bundle file with it,
+// unless its revision didn't change.
+func (ac *RBACService) writeOPABundle(ctx context.Context) error {
+	e := ac.opaBundle
+	bundle, err := ac.ExportOPABundle(ctx, 0)
+	if err != nil {
+		return err
+	}
+	if bundle.Revision == e.revision {
+		return nil
+	}
+
+	// The bundle is written next to the file and renamed, so that OPA never loads a partial bundle.
+	f, err := ioutil.TempFile(filepath.Dir(e.path), filepath.Base(e.path)+".*")
+	if err != nil {
+		return err
+	}
+	defer func() {
+		if err := os.Remove(f.Name()); err != nil && !os.IsNotExist(err) {
+			ac.log.Warn("Failed to remove the temporary OPA bundle", "path", f.Name(), "error", err)
+		}
+	}()
+	if err := bundle.Write(f); err != nil {
+		_ = f.Close()
+		return err
+	}
+	if err := f.Close(); err != nil {
+		return err
+	}
+	if err := os.Rename(f.Name(), e.path); err != nil {
+		return err
+	}
+
+	e.revision = bundle.Revision
+	ac.log.Debug("Wrote the OPA bundle", "path", e.path, "revision", bundle.Revision)
+	return nil
+}
+
+// ExportOPABundle exports the enabled policies of an organization, along with their unexpired
+// permissions and bindings, as the data of an OPA bundle. Every organization is exported when orgID is
+// zero. The reads share a transaction so that the bundle is consistent.
+func (ac *RBACService) ExportOPABundle(ctx context.Context, orgID int64) (*OPABundle, error) {
+	bundle := &OPABundle{Exported: time.Now().UTC(), Data: OPAData{Orgs: map[string]*OPAOrg{}}}
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		return ac.loadOPAData(sess, &bundle.Data, orgID, bundle.Exported)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	data, err := json.Marshal(bundle.Data)
+	if err != nil {
+		return nil, err
+	}
+	sum := sha256.Sum256(data)
+	bundle.Revision = hex.EncodeToString(sum[:])
+
+	return bundle, nil
+}
+
+// loadOPAData loads the policies and bindings of an organization, or of every organization when orgID
+// is zero, into the data of an OPA bundle.
+func (ac *RBACService) loadOPAData(sess *sqlstore.DBSession, data *OPAData, orgID int64, now time.Time) error {
+	orgFilter := func(table string) (string, []interface{}) {
+		if orgID == 0 {
+			return "1 = 1", nil
+		}
+		return table + ".org_id = ?", []interface{}{orgID}
+	}
+	// find runs a query filtered by the org_id column of a table, in the order of its ids.
+	find := func(rows interface{}, q, table string, args ...interface{}) error {
+		filter, filterArgs := orgFilter(table)
+		return sess.SQL(q+" AND "+filter+" ORDER BY "+table+".id", append(args, filterArgs...)...).Find(rows)
+	}
+
+	var suspensions []struct {
+		OrgID    int64 `xorm:"org_id"`
+		UserID   int64 `xorm:"user_id"`
+		PolicyID int64 `xorm:"policy_id"`
+	}
+	if err := find(&suspensions, `SELECT org_id, user_id, policy_id FROM user_suspension
+		WHERE (expires IS NULL OR expires > ?)`, "user_suspension", now); err != nil {
+		return err
+	}
+	// The policy of a suspension applies even when deactivated.
+	suspensionPolicies := make(map[int64]bool, len(suspensions))
+	for _, s := range suspensions {
+		suspensionPolicies[s.PolicyID] = true
+	}
+
+	var policies []*Policy
+	filter, args := orgFilter("policy")
+	if err := sess.Table("policy").Where(filter, args...).Asc("id").Find(&policies); err != nil {
+		return err
+	}
+	uids := make(map[int64]string, len(policies))
+	for _, p := range policies {
+		if !p.Enabled && !suspensionPolicies[p.ID] {
+			continue
+		}
+		uids[p.ID] = p.UID
+		opaOrg(data, p.OrgID).Policies[p.UID] = &OPAPolicy{Name: p.Name, Precedence: p.Precedence, Permissions: []OPAPermission{}}
+	}
+
+	var permissions []struct {
+		Permission `xorm:"extends"`
+		OrgID      int64 `xorm:"org_id"`
+	}
+	filter, args = orgFilter("policy")
+	q := `SELECT permission.*, policy.org_id FROM permission
+		INNER JOIN policy ON permission.policy_id = policy.id
+		WHERE (permission.expires_at IS NULL OR permission.expires_at > ?) AND ` + filter + ` ORDER BY permission.id`
+	if err := sess.SQL(q, append([]interface{}{now}, args...)...).Find(&permissions); err != nil {
+		return err
+	}
+	for _, p := range permissions {
+		uid, ok := uids[p.PolicyID]
+		if !ok {
+			continue
+		}
+		kind := PermissionKindAllow
+		if p.IsDeny() {
+			kind = PermissionKindDeny
+		}
+		policy := data.Orgs[strconv.FormatInt(p.OrgID, 10)].Policies[uid]
+		policy.Permissions = append(policy.Permissions, OPAPermission{
+			Action: p.Action, Scope: p.Scope, Kind: kind, Conditional: len(p.Conditions) > 0,
+		})
+	}
+
+	var orgUsers []struct {
+		OrgID   int64           `xorm:"org_id"`
+		UserID  int64           `xorm:"user_id"`
+		Role    models.RoleType `xorm:"role"`
+		IsAdmin bool            `xorm:"is_admin"`
+	}
+	userTable := ac.SQLStore.Dialect.Quote("user")
+	if err := find(&orgUsers, `SELECT org_user.org_id, org_user.user_id, org_user.role, `+userTable+`.is_admin FROM org_user
+		INNER JOIN `+userTable+` ON `+userTable+`.id = org_user.user_id WHERE 1 = 1`, "org_user"); err != nil {
+		return err
+	}
+	for _, u := range orgUsers {
+		opaUser(data, u.OrgID, u.UserID).Roles = BuiltinRoles(&models.SignedInUser{OrgRole: u.Role, IsGrafanaAdmin: u.IsAdmin})
+	}
+
+	var serviceAccounts []ServiceAccount
+	if err := find(&serviceAccounts, "SELECT * FROM service_account WHERE 1 = 1", "service_account"); err != nil {
+		return err
+	}
+	for _, sa := range serviceAccounts {
+		opaUser(data, sa.OrgID, sa.UserID).ServiceAccount = true
+	}
+
+	var members []struct {
+		OrgID  int64 `xorm:"org_id"`
+		TeamID int64 `xorm:"team_id"`
+		UserID int64 `xorm:"user_id"`
+	}
+	if err := find(&members, "SELECT org_id, team_id, user_id FROM team_member WHERE 1 = 1", "team_member"); err != nil {
+		return err
+	}
+	for _, m := range members {
+		u := opaUser(data, m.OrgID, m.UserID)
+		u.Teams = append(u.Teams, strconv.FormatInt(m.TeamID, 10))
+	}
+
+	var userPolicies []UserPolicy
+	if err := find(&userPolicies, "SELECT * FROM user_policy WHERE (expires_at IS NULL OR expires_at > ?)", "user_policy", now); err != nil {
+		return err
+	}
+	for _, up := range userPolicies {
+		if uid, ok := uids[up.PolicyID]; ok {
+			u := opaUser(data, up.OrgID, up.UserID)
+			u.Policies = append(u.Policies, uid)
+		}
+	}
+	for _, s := range suspensions {
+		if uid, ok := uids[s.PolicyID]; ok {
+			u := opaUser(data, s.OrgID, s.UserID)
+			u.Policies = append(u.Policies, uid)
+		}
+	}
+
+	var teamPolicies []TeamPolicy
+	if err := find(&teamPolicies, "SELECT * FROM team_policy WHERE (expires_at IS NULL OR expires_at > ?)", "team_policy", now); err != nil {
+		return err
+	}
+	for _, tp := range teamPolicies {
+		uid, ok := uids[tp.PolicyID]
+		if !ok {
+			continue
+		}
+		teams := opaOrg(data, tp.OrgID).Teams
+		key := strconv.FormatInt(tp.TeamID, 10)
+		if teams[key] == nil {
+			teams[key] = &OPATeam{}
+		}
+		teams[key].Policies = append(teams[key].Policies, uid)
+	}
+
+	var builtinRolePolicies []struct {
+		OrgID    int64  `xorm:"org_id"`
+		Role     string `xorm:"role"`
+		PolicyID int64  `xorm:"policy_id"`
+	}
+	if err := find(&builtinRolePolicies, "SELECT org_id, role, policy_id FROM builtin_role_policy WHERE 1 = 1", "builtin_role_policy"); err != nil {
+		return err
+	}
+	for _, brp := range builtinRolePolicies {
+		if uid, ok := uids[brp.PolicyID]; ok {
+			roles := opaOrg(data, brp.OrgID).BuiltinRoles
+			roles[brp.Role] = append(roles[brp.Role], uid)
+		}
+	}
+
+	// The policies of a binding are sorted, so that the revision only depends on the bindings.
+	for _, org := range data.Orgs {
+		for _, u := range org.Users {
+			sort.Strings(u.Policies)
+			sort.Strings(u.Teams)
+		}
+		for _, t := range org.Teams {
+			sort.Strings(t.Policies)
+		}
+		for _, policies := range org.BuiltinRoles {
+			sort.Strings(policies)
+		}
+	}
+
+	return nil
+}
+
+func opaOrg(data *OPAData, orgID int64) *OPAOrg {
+	key := strconv.FormatInt(orgID, 10)
+	org, ok := data.Orgs[key]
+	if !ok {
+		org = &OPAOrg{
+			Policies:     map[string]*OPAPolicy{},
+			Users:        map[string]*OPAUser{},
+			Teams:        map[string]*OPATeam{},
+			BuiltinRoles: map[string][]string{},
+		}
+		data.Orgs[key] = org
+	}
+	return org
+}
+
+func opaUser(data *OPAData, orgID, userID int64) *OPAUser {
+	users := opaOrg(data, orgID).Users
+	key := strconv.FormatInt(userID, 10)
+	u, ok := users[key]
+	if !ok {
+		u = &OPAUser{Roles: []string{}, Teams: []string{}, Policies: []string{}}
+		users[key] = u
+	}
+	return u
+}
+
+// Write writes the bundle as a gzipped tarball, with its manifest, data and the reference Rego
+// module, that OPA can load with --bundle or download from a bundle server.
+func (b *OPABundle) Write(w io.Writer) error {
+	manifest, err := json.Marshal(map[string]interface{}{"revision": b.Revision, "roots": []string{opaBundleRoot}})
+	if err != nil {
+		return err
+	}
+	data, err := json.Marshal(b.Data)
+	if err != nil {
+		return err
+	}
+
+	gz := gzip.NewWriter(w)
+	tw := tar.NewWriter(gz)
+	files := []struct {
+		name    string
+		content []byte
+	}{
+		{"/.manifest", manifest},
+		{"/" + opaBundleRoot + "/data.json", data},
+		{"/" + opaBundleRoot + "/authz.rego", []byte(opaRegoModule)},
+	}
+	for _, f := range files {
+		header := &tar.Header{Name: f.name, Mode: 0644, Size: int64(len(f.content)), ModTime: b.Exported}
+		if err := tw.WriteHeader(header); err != nil {
+			return err
+		}
+		if _, err := tw.Write(f.content); err != nil {
+			return err
+		}
+	}
+	if err := tw.Close(); err != nil {
+		return err
+	}
+	return gz.Close()
+}
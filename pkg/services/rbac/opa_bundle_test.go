@@ -0,0 +1,147 @@
+package rbac
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"context"
+	"encoding/json"
+	"io"
+	"io/ioutil"
+	"os"
+	"path/filepath"
+	"strconv"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestOPABundle(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	cmd := &models.CreateUserCommand{Login: "opa-user"}
+	require.NoError(t, sqlstore.CreateUser(context.Background(), cmd))
+	user := cmd.Result
+	userKey := strconv.FormatInt(user.Id, 10)
+	orgKey := strconv.FormatInt(user.OrgId, 10)
+
+	team := createTeam(t, user.OrgId, "opa-team")
+	addTeamMember(t, user.OrgId, team.Id, user.Id)
+
+	direct := createPolicy(t, ac, user.OrgId, "direct", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: user.OrgId, UserID: user.Id, PolicyID: direct.ID}))
+	viaTeam := createPolicy(t, ac, user.OrgId, "via team",
+		CreatePermissionCommand{Action: "folders:read", Scope: "folders:*"},
+		CreatePermissionCommand{Action: "folders:read", Scope: "folders:uid:secret", Kind: PermissionKindDeny})
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: user.OrgId, TeamID: team.Id, PolicyID: viaTeam.ID}))
+	viaRole := createPolicy(t, ac, user.OrgId, "via role", CreatePermissionCommand{Action: "users:read"})
+	require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: user.OrgId, Role: "Viewer", PolicyID: viaRole.ID}))
+	disabled := createPolicy(t, ac, user.OrgId, "disabled", CreatePermissionCommand{Action: "users:write"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: user.OrgId, UserID: user.Id, PolicyID: disabled.ID}))
+	require.NoError(t, ac.SetPolicyEnabled(context.Background(), SetPolicyEnabledCommand{ID: disabled.ID, OrgID: user.OrgId, Enabled: false}))
+
+	bundle, err := ac.ExportOPABundle(context.Background(), user.OrgId)
+	require.NoError(t, err)
+	require.Contains(t, bundle.Data.Orgs, orgKey)
+	org := bundle.Data.Orgs[orgKey]
+
+	t.Run("Only enabled policies should be exported", func(t *testing.T) {
+		uids := make([]string, 0, len(org.Policies))
+		for uid := range org.Policies {
+			uids = append(uids, uid)
+		}
+		assert.ElementsMatch(t, []string{direct.UID, viaTeam.UID, viaRole.UID}, uids)
+		assert.Equal(t, []OPAPermission{
+			{Action: "folders:read", Scope: "folders:*", Kind: PermissionKindAllow},
+			{Action: "folders:read", Scope: "folders:uid:secret", Kind: PermissionKindDeny},
+		}, org.Policies[viaTeam.UID].Permissions)
+	})
+
+	t.Run("Bindings should be exported by kind", func(t *testing.T) {
+		require.Contains(t, org.Users, userKey)
+		u := org.Users[userKey]
+		assert.Equal(t, []string{direct.UID}, u.Policies)
+		assert.Equal(t, []string{strconv.FormatInt(team.Id, 10)}, u.Teams)
+		assert.Contains(t, u.Roles, "Viewer")
+		assert.False(t, u.ServiceAccount)
+		assert.Equal(t, []string{viaTeam.UID}, org.Teams[strconv.FormatInt(team.Id, 10)].Policies)
+		assert.Equal(t, []string{viaRole.UID}, org.BuiltinRoles["Viewer"])
+	})
+
+	t.Run("Revision should only change along with the data", func(t *testing.T) {
+		again, err := ac.ExportOPABundle(context.Background(), user.OrgId)
+		require.NoError(t, err)
+		assert.Equal(t, bundle.Revision, again.Revision)
+
+		require.NoError(t, ac.RemoveUserPolicy(context.Background(), RemoveUserPolicyCommand{OrgID: user.OrgId, UserID: user.Id, PolicyID: direct.ID}))
+		t.Cleanup(func() {
+			require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: user.OrgId, UserID: user.Id, PolicyID: direct.ID}))
+		})
+		changed, err := ac.ExportOPABundle(context.Background(), user.OrgId)
+		require.NoError(t, err)
+		assert.NotEqual(t, bundle.Revision, changed.Revision)
+	})
+
+	t.Run("Bundle should hold the manifest, data and Rego module", func(t *testing.T) {
+		var buf bytes.Buffer
+		require.NoError(t, bundle.Write(&buf))
+
+		files := readOPABundle(t, &buf)
+		assert.Len(t, files, 3)
+		assert.JSONEq(t, `{"revision": "`+bundle.Revision+`", "roots": ["grafana/rbac"]}`, string(files["/.manifest"]))
+		assert.Equal(t, opaRegoModule, string(files["/grafana/rbac/authz.rego"]))
+
+		var data OPAData
+		require.NoError(t, json.Unmarshal(files["/grafana/rbac/data.json"], &data))
+		assert.Equal(t, bundle.Data, data)
+	})
+
+	t.Run("Bundle file should be rewritten when permissions change", func(t *testing.T) {
+		path := filepath.Join(t.TempDir(), "bundle.tar.gz")
+		ac.opaBundle = &opaBundleExporter{path: path, changes: make(chan struct{}, 1)}
+		t.Cleanup(func() { ac.opaBundle = nil })
+
+		require.NoError(t, ac.writeOPABundle(context.Background()))
+		first := ac.opaBundle.revision
+		require.NotEmpty(t, first)
+		f, err := os.Open(path)
+		require.NoError(t, err)
+		files := readOPABundle(t, f)
+		require.NoError(t, f.Close())
+		assert.Contains(t, string(files["/.manifest"]), first)
+
+		createPolicy(t, ac, user.OrgId, "created", CreatePermissionCommand{Action: "teams:read", Scope: "teams:*"})
+		select {
+		case <-ac.opaBundle.changes:
+		default:
+			t.Fatal("the change should have scheduled an export")
+		}
+		require.NoError(t, ac.writeOPABundle(context.Background()))
+		assert.NotEqual(t, first, ac.opaBundle.revision)
+	})
+}
+
+func readOPABundle(t *testing.T, r io.Reader) map[string][]byte {
+	t.Helper()
+
+	gz, err := gzip.NewReader(r)
+	require.NoError(t, err)
+	tr := tar.NewReader(gz)
+	files := map[string][]byte{}
+	for {
+		header, err := tr.Next()
+		if err == io.EOF {
+			break
+		}
+		require.NoError(t, err)
+		content, err := ioutil.ReadAll(tr)
+		require.NoError(t, err)
+		files[header.Name] = content
+	}
+
+	return files
+}
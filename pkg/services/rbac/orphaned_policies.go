@@ -0,0 +1,64 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// GetOrphanedPolicies returns the policies of an organization that have no assignments or no
+// permissions, sorted by name, for admins to prune the ones that are no longer needed. Policies
+// managed by Grafana and the break-glass policy aren't reported, since they're unassigned by design.
+func (ac *RBACService) GetOrphanedPolicies(ctx context.Context, query GetOrphanedPoliciesQuery) (*OrphanedPoliciesResult, error) {
+	result := &OrphanedPoliciesResult{Policies: []*OrphanedPolicy{}}
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		now := time.Now()
+		q := `SELECT policy.*,
+			(SELECT COUNT(*) FROM permission WHERE permission.policy_id = policy.id
+				AND (permission.expires_at IS NULL OR permission.expires_at > ?)) AS permissions,
+			(SELECT COUNT(*) FROM user_policy WHERE user_policy.policy_id = policy.id
+				AND (user_policy.expires_at IS NULL OR user_policy.expires_at > ?))
+			+ (SELECT COUNT(*) FROM team_policy WHERE team_policy.policy_id = policy.id
+				AND (team_policy.expires_at IS NULL OR team_policy.expires_at > ?))
+			+ (SELECT COUNT(*) FROM builtin_role_policy WHERE builtin_role_policy.policy_id = policy.id)
+			+ (SELECT COUNT(*) FROM api_key_policy WHERE api_key_policy.policy_id = policy.id)
+			+ (SELECT COUNT(*) FROM default_policy WHERE default_policy.policy_id = policy.id)
+			+ (SELECT COUNT(*) FROM external_group_policy WHERE external_group_policy.policy_id = policy.id)
+			+ (SELECT COUNT(*) FROM policy_group_mapping WHERE policy_group_mapping.policy_id = policy.id) AS assignments
+			FROM policy
+			WHERE policy.org_id = ? AND policy.uid NOT LIKE ?`
+		args := []interface{}{now, now, now, query.OrgID, managedPolicyUIDPrefix + "%"}
+		if ac.breakGlass.policyUID != "" {
+			q += " AND policy.uid <> ?"
+			args = append(args, ac.breakGlass.policyUID)
+		}
+		if query.UnchangedFor > 0 {
+			q += " AND policy.updated < ?"
+			args = append(args, now.Add(-query.UnchangedFor))
+		}
+		q += " ORDER BY policy.name ASC"
+
+		var policies []*OrphanedPolicy
+		if err := sess.SQL(q, args...).Find(&policies); err != nil {
+			return err
+		}
+		for _, p := range policies {
+			if p.Assignments > 0 && p.Permissions > 0 {
+				continue
+			}
+			if p.Assignments == 0 {
+				result.WithoutAssignments++
+			}
+			if p.Permissions == 0 {
+				result.WithoutPermissions++
+			}
+			result.Policies = append(result.Policies, p)
+		}
+		result.TotalCount = len(result.Policies)
+
+		return nil
+	})
+
+	return result, err
+}
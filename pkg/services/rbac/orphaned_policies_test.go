@@ -0,0 +1,51 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestGetOrphanedPolicies(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	used := createPolicy(t, ac, 1, "used", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 391, PolicyID: used.ID}))
+	unassigned := createPolicy(t, ac, 1, "unassigned", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	empty := createPolicy(t, ac, 1, "empty")
+	team := createTeam(t, 1, "orphans")
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: empty.ID}))
+	createPolicy(t, ac, 1, "nothing")
+	createManagedPolicy(t, ac, 1, "managed-orphan")
+
+	result, err := ac.GetOrphanedPolicies(context.Background(), GetOrphanedPoliciesQuery{OrgID: 1})
+	require.NoError(t, err)
+	names := make([]string, 0, len(result.Policies))
+	for _, p := range result.Policies {
+		names = append(names, p.Name)
+	}
+	assert.Equal(t, []string{"empty", "nothing", "unassigned"}, names)
+	assert.Equal(t, 3, result.TotalCount)
+	assert.Equal(t, 2, result.WithoutAssignments)
+	assert.Equal(t, 2, result.WithoutPermissions)
+	assert.Equal(t, int64(1), result.Policies[0].Assignments)
+	assert.Equal(t, int64(1), result.Policies[2].Permissions)
+
+	t.Run("Recently updated policies shouldn't be reported", func(t *testing.T) {
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Exec("UPDATE policy SET updated = ? WHERE id = ?", time.Now().Add(-48*time.Hour), unassigned.ID)
+			return err
+		})
+		require.NoError(t, err)
+
+		result, err := ac.GetOrphanedPolicies(context.Background(), GetOrphanedPoliciesQuery{OrgID: 1, UnchangedFor: 24 * time.Hour})
+		require.NoError(t, err)
+		require.Len(t, result.Policies, 1)
+		assert.Equal(t, unassigned.ID, result.Policies[0].ID)
+	})
+}
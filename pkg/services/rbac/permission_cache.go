@@ -0,0 +1,233 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// permissionCacheInvalidationKey is the remote cache key of the last invalidation of the permission
+// caches of a HA setup.
+const permissionCacheInvalidationKey = "rbac-permission-cache-invalidation"
+
+// defaultPermissionCacheSyncInterval is how often the permission cache checks for invalidations made
+// by other instances by default.
+const defaultPermissionCacheSyncInterval = 10 * time.Second
+
+// permissionCache caches the permissions of users resolved from the database, for the time set by
+// the rbac.permission_cache_ttl setting. Entries are invalidated by the events published when policies,
+// permissions and bindings change on this instance. The other instances of a HA setup are told with
+// Postgres notifications when the database is Postgres, see listenPermissionCache, and otherwise through
+// the remote cache after which they flush their whole cache, see syncPermissionCache. They see the
+// changes once the entries expire when neither can be reached.
+type permissionCache struct {
+	cache *localcache.CacheService
+	mu    sync.Mutex
+	// generation is increased by every invalidation, so that permissions resolved before an
+	// invalidation aren't cached after it.
+	generation uint64
+	// syncInterval is how often the remote invalidations are checked, zero disables it.
+	syncInterval time.Duration
+	// token is the last invalidation seen in or written to the remote cache.
+	token string
+	// postgresNotify is set when the invalidations are sent with Postgres notifications instead.
+	postgresNotify bool
+	// instanceID tells the notifications of this instance apart.
+	instanceID string
+	// warmUpOnLogin caches the permissions of users when they sign in, see WarmUpUserPermissions.
+	warmUpOnLogin bool
+	// warmUpUsers is how many of the users seen most recently are cached at startup, see warmUpPermissionCache.
+	warmUpUsers int
+}
+
+// loadPermissionCacheSettings reads the lifetime of the cached permissions and the interval of the
+// checks for remote invalidations from the settings, a zero lifetime disables the cache. On Postgres,
+// notifications replace the checks unless rbac.permission_cache_postgres_notify is off.
+func (ac *RBACService) loadPermissionCacheSettings() {
+	section := ac.Cfg.Raw.Section("rbac")
+	ttl := section.Key("permission_cache_ttl").MustDuration(0)
+	if ttl <= 0 {
+		ac.permissionCache = nil
+		return
+	}
+
+	ac.permissionCache = &permissionCache{
+		cache:          localcache.New(ttl, 2*ttl),
+		syncInterval:   section.Key("permission_cache_sync_interval").MustDuration(defaultPermissionCacheSyncInterval),
+		postgresNotify: ac.usesPostgresNotify(),
+		instanceID:     util.GenerateShortUID(),
+		warmUpOnLogin:  section.Key("permission_cache_warm_up_on_login").MustBool(false),
+		warmUpUsers:    section.Key("permission_cache_warm_up_users").MustInt(0),
+	}
+}
+
+func permissionCacheKey(query GetUserPermissionsQuery) string {
+	return fmt.Sprintf("%d:%d:%s", query.OrgID, query.UserID, strings.Join(query.Roles, ","))
+}
+
+// getUserPermissions returns the cached permissions of a user, or resolves and caches them. The
+// permissions are copied, so that callers can resolve their scopes in place.
+func (ac *RBACService) getUserPermissions(ctx context.Context, query GetUserPermissionsQuery) ([]Permission, error) {
+	c := ac.permissionCache
+	if c == nil {
+		return ac.GetUserPermissions(ctx, query)
+	}
+
+	key := permissionCacheKey(query)
+	if cached, found := c.cache.Get(key); found {
+		return append([]Permission(nil), cached.([]Permission)...), nil
+	}
+
+	c.mu.Lock()
+	generation := c.generation
+	c.mu.Unlock()
+
+	permissions, err := ac.GetUserPermissions(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	c.mu.Lock()
+	if generation == c.generation {
+		c.cache.Set(key, append([]Permission(nil), permissions...), 0)
+	}
+	c.mu.Unlock()
+
+	return permissions, nil
+}
+
+// invalidatePermissionCache deletes the cached permissions and denials of a user in an organization,
+// see invalidate, and tells the other instances of a HA setup to flush their permission caches. The
+// OPA bundle is exported again too.
+func (ac *RBACService) invalidatePermissionCache(orgID, userID int64) {
+	ac.opaBundle.changed()
+	ac.denyCache.invalidate(orgID, userID)
+	ac.sessionPermissions.invalidate(orgID, userID)
+	c := ac.permissionCache
+	if c == nil {
+		return
+	}
+
+	c.invalidate(orgID, userID)
+	if c.postgresNotify {
+		ac.notifyPermissionCache(orgID, userID)
+		return
+	}
+	if ac.RemoteCache == nil || c.syncInterval <= 0 {
+		return
+	}
+
+	token := util.GenerateShortUID()
+	if err := ac.RemoteCache.Set(permissionCacheInvalidationKey, token, 0); err != nil {
+		// The other instances see the change once their entries expire.
+		ac.log.Warn("Failed to publish permission cache invalidation", "error", err)
+		return
+	}
+	c.mu.Lock()
+	c.token = token
+	c.mu.Unlock()
+}
+
+// syncPermissionCache flushes the permission cache when another instance invalidated its own since
+// the last check.
+func (ac *RBACService) syncPermissionCache() {
+	c := ac.permissionCache
+	if c == nil || ac.RemoteCache == nil {
+		return
+	}
+
+	var token string
+	value, err := ac.RemoteCache.Get(permissionCacheInvalidationKey)
+	switch {
+	case errors.Is(err, remotecache.ErrCacheItemNotFound):
+	case err != nil:
+		ac.log.Warn("Failed to check permission cache invalidations", "error", err)
+		return
+	default:
+		token, _ = value.(string)
+	}
+
+	c.mu.Lock()
+	changed := token != c.token
+	c.token = token
+	c.mu.Unlock()
+	if changed {
+		ac.log.Debug("Flushing permission cache invalidated by another instance")
+		c.invalidate(0, 0)
+		ac.denyCache.invalidate(0, 0)
+		ac.sessionPermissions.invalidate(0, 0)
+	}
+}
+
+// invalidate deletes the cached permissions of a user in an organization. A zero user invalidates
+// every user of the organization, a zero organization every organization.
+func (c *permissionCache) invalidate(orgID, userID int64) {
+	if c == nil {
+		return
+	}
+
+	c.mu.Lock()
+	defer c.mu.Unlock()
+	c.generation++
+
+	if orgID == 0 {
+		c.cache.Flush()
+		return
+	}
+	prefix := fmt.Sprintf("%d:", orgID)
+	if userID != 0 {
+		prefix = fmt.Sprintf("%d:%d:", orgID, userID)
+	}
+	for key := range c.cache.Items() {
+		if strings.HasPrefix(key, prefix) {
+			c.cache.Delete(key)
+		}
+	}
+}
+
+// invalidateOnPolicyBound invalidates the permissions of the user bound to a policy, or of the whole
+// organization for teams since the cache doesn't know their members.
+func (ac *RBACService) invalidateOnPolicyBound(e *events.PolicyBound) error {
+	ac.invalidatePermissionCache(e.OrgID, e.UserID)
+	return nil
+}
+
+func (ac *RBACService) invalidateOnPolicyUnbound(e *events.PolicyUnbound) error {
+	ac.invalidatePermissionCache(e.OrgID, e.UserID)
+	return nil
+}
+
+func (ac *RBACService) invalidateOnPolicyBindingExpired(e *events.PolicyBindingExpired) error {
+	ac.invalidatePermissionCache(e.OrgID, e.UserID)
+	return nil
+}
+
+func (ac *RBACService) invalidateOnPermissionsChanged(e *events.UserPermissionsChanged) error {
+	ac.invalidatePermissionCache(e.OrgID, e.UserID)
+	return nil
+}
+
+func (ac *RBACService) invalidateOnPolicyChanged(e *events.PolicyChanged) error {
+	ac.invalidatePermissionCache(e.OrgID, 0)
+	return nil
+}
+
+// invalidateOnPermissionExpired invalidates every organization, since the event doesn't tell the
+// organization of the expired permission.
+func (ac *RBACService) invalidateOnPermissionExpired(e *events.PermissionExpired) error {
+	ac.invalidatePermissionCache(0, 0)
+	return nil
+}
+
+func (ac *RBACService) invalidateOnOrgDeleted(e *events.OrgDeleted) error {
+	ac.invalidatePermissionCache(e.OrgID, 0)
+	return nil
+}
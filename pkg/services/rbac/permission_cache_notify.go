@@ -0,0 +1,118 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/lib/pq"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+)
+
+// permissionCacheNotifyChannel is the Postgres channel the invalidations of the permission caches
+// of a HA setup are sent on.
+const permissionCacheNotifyChannel = "rbac_permission_cache"
+
+// permissionCacheListenerPing is how often the connection of the listener is checked, so that it's
+// re-established in time after a failover.
+const permissionCacheListenerPing = 90 * time.Second
+
+// usesPostgresNotify returns true if the invalidations of the permission cache are sent to the other
+// instances with Postgres notifications rather than through the remote cache.
+func (ac *RBACService) usesPostgresNotify() bool {
+	return ac.Cfg.Raw.Section("rbac").Key("permission_cache_postgres_notify").MustBool(true) &&
+		ac.SQLStore != nil && ac.SQLStore.Dialect.DriverName() == migrator.Postgres
+}
+
+// notifyPermissionCache sends the invalidation of the cached permissions of a user in an organization
+// to the other instances. The notification is sent once the change is committed, so unlike the remote
+// cache the other instances only invalidate the entries of the user, or of the organization.
+func (ac *RBACService) notifyPermissionCache(orgID, userID int64) {
+	payload := fmt.Sprintf("%s:%d:%d", ac.permissionCache.instanceID, orgID, userID)
+	err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Exec("SELECT pg_notify(?, ?)", permissionCacheNotifyChannel, payload)
+		return err
+	})
+	if err != nil {
+		// The other instances see the change once their entries expire.
+		ac.log.Warn("Failed to notify permission cache invalidation", "error", err)
+	}
+}
+
+// listenPermissionCache invalidates the permission cache on the notifications of the other instances
+// until the context is done. The whole cache is flushed when the connection is re-established, since
+// the notifications sent in the meantime are lost.
+func (ac *RBACService) listenPermissionCache(ctx context.Context) {
+	listener, err := ac.SQLStore.NewPostgresListener(func(event pq.ListenerEventType, err error) {
+		if err != nil {
+			ac.log.Warn("Permission cache listener connection failed", "error", err)
+		}
+	})
+	if err != nil {
+		ac.log.Error("Failed to create permission cache listener", "error", err)
+		return
+	}
+	defer func() {
+		if err := listener.Close(); err != nil {
+			ac.log.Warn("Failed to close permission cache listener", "error", err)
+		}
+	}()
+	if err := listener.Listen(permissionCacheNotifyChannel); err != nil {
+		ac.log.Error("Failed to listen to permission cache invalidations", "error", err)
+		return
+	}
+
+	ticker := time.NewTicker(permissionCacheListenerPing)
+	defer ticker.Stop()
+	for {
+		select {
+		case n := <-listener.Notify:
+			ac.handlePermissionCacheNotification(n)
+		case <-ticker.C:
+			go func() {
+				if err := listener.Ping(); err != nil {
+					ac.log.Debug("Permission cache listener ping failed", "error", err)
+				}
+			}()
+		case <-ctx.Done():
+			return
+		}
+	}
+}
+
+// handlePermissionCacheNotification invalidates the cached permissions of a notification, nil when
+// the listener reconnected. The notifications of this instance are ignored, it invalidated its own
+// cache already.
+func (ac *RBACService) handlePermissionCacheNotification(n *pq.Notification) {
+	c := ac.permissionCache
+	if n == nil {
+		ac.log.Debug("Flushing permission cache after the listener reconnected")
+		c.invalidate(0, 0)
+		ac.denyCache.invalidate(0, 0)
+		ac.sessionPermissions.invalidate(0, 0)
+		return
+	}
+
+	parts := strings.Split(n.Extra, ":")
+	if len(parts) != 3 {
+		ac.log.Warn("Invalid permission cache notification", "payload", n.Extra)
+		return
+	}
+	if parts[0] == c.instanceID {
+		return
+	}
+	orgID, orgErr := strconv.ParseInt(parts[1], 10, 64)
+	userID, userErr := strconv.ParseInt(parts[2], 10, 64)
+	if orgErr != nil || userErr != nil {
+		ac.log.Warn("Invalid permission cache notification", "payload", n.Extra)
+		return
+	}
+
+	c.invalidate(orgID, userID)
+	ac.denyCache.invalidate(orgID, userID)
+	ac.sessionPermissions.invalidate(orgID, userID)
+}
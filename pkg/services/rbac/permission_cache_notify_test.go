@@ -0,0 +1,57 @@
+package rbac
+
+import (
+	"testing"
+
+	"github.com/lib/pq"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHandlePermissionCacheNotification(t *testing.T) {
+	ac := setupTestEnv(t)
+	_, err := ac.Cfg.Raw.Section("rbac").NewKey("permission_cache_ttl", "1m")
+	require.NoError(t, err)
+	ac.loadPermissionCacheSettings()
+	c := ac.permissionCache
+	assert.False(t, c.postgresNotify, "notifications require Postgres")
+
+	cached := func(orgID, userID int64) bool {
+		_, found := c.cache.Get(permissionCacheKey(GetUserPermissionsQuery{OrgID: orgID, UserID: userID}))
+		return found
+	}
+	fill := func() {
+		for _, key := range []GetUserPermissionsQuery{{OrgID: 1, UserID: 461}, {OrgID: 1, UserID: 462}, {OrgID: 2, UserID: 461}} {
+			c.cache.Set(permissionCacheKey(key), []Permission{}, 0)
+		}
+	}
+
+	t.Run("Notifications of other instances should invalidate the user", func(t *testing.T) {
+		fill()
+		ac.handlePermissionCacheNotification(&pq.Notification{Channel: permissionCacheNotifyChannel, Extra: "other:1:461"})
+		assert.False(t, cached(1, 461))
+		assert.True(t, cached(1, 462))
+		assert.True(t, cached(2, 461))
+	})
+
+	t.Run("Notifications without user should invalidate the organization", func(t *testing.T) {
+		fill()
+		ac.handlePermissionCacheNotification(&pq.Notification{Channel: permissionCacheNotifyChannel, Extra: "other:1:0"})
+		assert.False(t, cached(1, 461))
+		assert.False(t, cached(1, 462))
+		assert.True(t, cached(2, 461))
+	})
+
+	t.Run("Own and invalid notifications should be ignored", func(t *testing.T) {
+		fill()
+		ac.handlePermissionCacheNotification(&pq.Notification{Channel: permissionCacheNotifyChannel, Extra: c.instanceID + ":1:461"})
+		ac.handlePermissionCacheNotification(&pq.Notification{Channel: permissionCacheNotifyChannel, Extra: "other:one:461"})
+		assert.True(t, cached(1, 461))
+	})
+
+	t.Run("Reconnecting should flush the cache", func(t *testing.T) {
+		fill()
+		ac.handlePermissionCacheNotification(nil)
+		assert.False(t, cached(2, 461))
+	})
+}
@@ -0,0 +1,113 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestPermissionCache(t *testing.T) {
+	ac := setupTestEnv(t)
+	_, err := ac.Cfg.Raw.Section("rbac").NewKey("permission_cache_ttl", "1m")
+	require.NoError(t, err)
+	ac.loadPermissionCacheSettings()
+	require.NotNil(t, ac.permissionCache)
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 441, OrgRole: models.ROLE_VIEWER}
+	hasPermission := func(action string) bool {
+		ok, err := ac.HasPermission(context.Background(), user, action, "dashboards:uid:home")
+		require.NoError(t, err)
+		return ok
+	}
+
+	policy := createPolicy(t, ac, 1, "cached", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 441, PolicyID: policy.ID}))
+	require.True(t, hasPermission("dashboards:read"))
+
+	t.Run("Permissions should be cached until invalidated", func(t *testing.T) {
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Exec("DELETE FROM user_policy WHERE user_id = ?", 441)
+			return err
+		})
+		require.NoError(t, err)
+		assert.True(t, hasPermission("dashboards:read"))
+
+		ac.publishPermissionsChanged(1, 441, "test")
+		assert.False(t, hasPermission("dashboards:read"))
+	})
+
+	t.Run("Binding changes should invalidate the cache", func(t *testing.T) {
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 441, PolicyID: policy.ID}))
+		assert.True(t, hasPermission("dashboards:read"))
+
+		team := createTeam(t, 1, "cached team")
+		addTeamMember(t, 1, team.Id, 441)
+		teamPolicy := createPolicy(t, ac, 1, "cached team", CreatePermissionCommand{Action: "dashboards:delete", Scope: "dashboards:*"})
+		require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: teamPolicy.ID}))
+		assert.True(t, hasPermission("dashboards:delete"))
+
+		rolePolicy := createPolicy(t, ac, 1, "cached role")
+		require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: rolePolicy.ID}))
+		_, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: rolePolicy.OrgID, PolicyID: rolePolicy.ID, Action: "dashboards:write", Scope: "dashboards:*"})
+		require.NoError(t, err)
+		assert.True(t, hasPermission("dashboards:write"))
+
+		require.NoError(t, ac.RemoveBuiltinRolePolicy(context.Background(), RemoveBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: rolePolicy.ID}))
+		assert.False(t, hasPermission("dashboards:write"))
+	})
+
+	t.Run("Policy changes should invalidate the cache", func(t *testing.T) {
+		require.NoError(t, ac.SetPolicyEnabled(context.Background(), SetPolicyEnabledCommand{OrgID: 1, ID: policy.ID, Enabled: false}))
+		assert.False(t, hasPermission("dashboards:read"))
+	})
+
+	t.Run("Invalidations should be scoped to the organization", func(t *testing.T) {
+		c := ac.permissionCache
+		c.cache.Set(permissionCacheKey(GetUserPermissionsQuery{OrgID: 2, UserID: 441}), []Permission{}, 0)
+		c.cache.Set(permissionCacheKey(GetUserPermissionsQuery{OrgID: 1, UserID: 442}), []Permission{}, 0)
+
+		c.invalidate(1, 0)
+		_, found := c.cache.Get(permissionCacheKey(GetUserPermissionsQuery{OrgID: 1, UserID: 442}))
+		assert.False(t, found)
+		_, found = c.cache.Get(permissionCacheKey(GetUserPermissionsQuery{OrgID: 2, UserID: 441}))
+		assert.True(t, found)
+	})
+}
+
+func TestPermissionCacheSync(t *testing.T) {
+	ac := setupTestEnv(t)
+	ac.RemoteCache = remotecache.NewFakeStore(t)
+	_, err := ac.Cfg.Raw.Section("rbac").NewKey("permission_cache_ttl", "1m")
+	require.NoError(t, err)
+	ac.loadPermissionCacheSettings()
+	c := ac.permissionCache
+	key := permissionCacheKey(GetUserPermissionsQuery{OrgID: 1, UserID: 451})
+
+	t.Run("Invalidations should be published to the other instances", func(t *testing.T) {
+		ac.invalidatePermissionCache(1, 451)
+		token, err := ac.RemoteCache.Get(permissionCacheInvalidationKey)
+		require.NoError(t, err)
+		assert.Equal(t, c.token, token)
+
+		c.cache.Set(key, []Permission{}, 0)
+		ac.syncPermissionCache()
+		_, found := c.cache.Get(key)
+		assert.True(t, found, "own invalidations shouldn't flush the cache again")
+	})
+
+	t.Run("Invalidations of other instances should flush the cache", func(t *testing.T) {
+		c.cache.Set(key, []Permission{}, 0)
+		require.NoError(t, ac.RemoteCache.Set(permissionCacheInvalidationKey, "other instance", 0))
+
+		ac.syncPermissionCache()
+		_, found := c.cache.Get(key)
+		assert.False(t, found)
+		assert.Equal(t, "other instance", c.token)
+	})
+}
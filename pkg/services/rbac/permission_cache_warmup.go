@@ -0,0 +1,76 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// WarmUpUserPermissions resolves and caches the permissions of a user in an organization, so that the
+// first requests after the user signs in don't wait on the database. It does nothing unless the
+// permission cache and rbac.permission_cache_warm_up_on_login are enabled. Failures are only logged,
+// the permissions are then resolved by the first access check as usual.
+func (ac *RBACService) WarmUpUserPermissions(ctx context.Context, userID, orgID int64) {
+	if c := ac.permissionCache; c == nil || !c.warmUpOnLogin || ac.IsDegraded() {
+		return
+	}
+
+	if err := ac.warmUpUserPermissions(ctx, userID, orgID); err != nil {
+		ac.log.Warn("Failed to warm up the permission cache", "userId", userID, "orgId", orgID, "error", err)
+	}
+}
+
+func (ac *RBACService) warmUpUserPermissions(ctx context.Context, userID, orgID int64) error {
+	query := models.GetSignedInUserQuery{UserId: userID, OrgId: orgID}
+	if err := ac.SQLStore.GetSignedInUserWithCache(&query); err != nil {
+		return err
+	}
+
+	user := query.Result
+	_, err := ac.getUserPermissions(ctx, GetUserPermissionsQuery{
+		OrgID: user.OrgId, UserID: user.UserId, Roles: BuiltinRoles(user),
+	})
+	return err
+}
+
+// warmUpPermissionCache caches the permissions of the users seen most recently, in their current
+// organization, for as many users as rbac.permission_cache_warm_up_users tells. It's run in the
+// background at startup, so that a deploy doesn't slow down the first requests of every user.
+func (ac *RBACService) warmUpPermissionCache(ctx context.Context) {
+	c := ac.permissionCache
+	if c == nil || c.warmUpUsers <= 0 || ac.IsDegraded() {
+		return
+	}
+
+	start := time.Now()
+	var users []struct {
+		ID    int64 `xorm:"id"`
+		OrgID int64 `xorm:"org_id"`
+	}
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := `SELECT id, org_id FROM ` + ac.SQLStore.Dialect.Quote("user") + `
+			WHERE is_disabled = ? AND org_id > 0
+			ORDER BY last_seen_at DESC` + ac.SQLStore.Dialect.Limit(int64(c.warmUpUsers))
+		return sess.SQL(q, false).Find(&users)
+	})
+	if err != nil {
+		ac.log.Warn("Failed to load the users to warm up the permission cache for", "error", err)
+		return
+	}
+
+	warmed := 0
+	for _, u := range users {
+		if ctx.Err() != nil {
+			return
+		}
+		if err := ac.warmUpUserPermissions(ctx, u.ID, u.OrgID); err != nil {
+			ac.log.Debug("Failed to warm up the permission cache", "userId", u.ID, "orgId", u.OrgID, "error", err)
+			continue
+		}
+		warmed++
+	}
+
+	ac.log.Info("Warmed up the permission cache", "users", warmed, "duration", time.Since(start))
+}
@@ -0,0 +1,72 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestPermissionCacheWarmUp(t *testing.T) {
+	ac := setupTestEnv(t)
+	section := ac.Cfg.Raw.Section("rbac")
+	for key, value := range map[string]string{
+		"permission_cache_ttl":              "1m",
+		"permission_cache_warm_up_on_login": "true",
+		"permission_cache_warm_up_users":    "1",
+	} {
+		_, err := section.NewKey(key, value)
+		require.NoError(t, err)
+	}
+	ac.loadPermissionCacheSettings()
+	c := ac.permissionCache
+	require.NotNil(t, c)
+
+	createUser := func(t *testing.T, login string, lastSeen time.Time) *models.User {
+		t.Helper()
+		cmd := &models.CreateUserCommand{Login: login}
+		require.NoError(t, sqlstore.CreateUser(context.Background(), cmd))
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Exec("UPDATE "+ac.SQLStore.Dialect.Quote("user")+" SET last_seen_at = ? WHERE id = ?", lastSeen, cmd.Result.Id)
+			return err
+		})
+		require.NoError(t, err)
+		return &cmd.Result
+	}
+	cached := func(user *models.User) bool {
+		for key := range c.cache.Items() {
+			if strings.HasPrefix(key, fmt.Sprintf("%d:%d:", user.OrgId, user.Id)) {
+				return true
+			}
+		}
+		return false
+	}
+
+	recent := createUser(t, "recent", time.Now())
+	idle := createUser(t, "idle", time.Now().Add(-24*time.Hour))
+
+	t.Run("The most recently active users should be cached at startup", func(t *testing.T) {
+		ac.warmUpPermissionCache(context.Background())
+		assert.True(t, cached(recent))
+		assert.False(t, cached(idle))
+	})
+
+	t.Run("Users should be cached when they sign in", func(t *testing.T) {
+		ac.WarmUpUserPermissions(context.Background(), idle.Id, idle.OrgId)
+		assert.True(t, cached(idle))
+	})
+
+	t.Run("Nothing should be cached when warming up at login is off", func(t *testing.T) {
+		c.invalidate(0, 0)
+		c.warmUpOnLogin = false
+		ac.WarmUpUserPermissions(context.Background(), idle.Id, idle.OrgId)
+		assert.False(t, cached(idle))
+	})
+}
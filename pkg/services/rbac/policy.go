@@ -0,0 +1,553 @@
+package rbac
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// Actions of the policies API. policies:create is unscoped, the scope of the others is policies:* or
+// the scope of a policy, see ScopePolicyUID.
+const (
+	ActionPoliciesRead   = "policies:read"
+	ActionPoliciesCreate = "policies:create"
+	ActionPoliciesWrite  = "policies:write"
+	ActionPoliciesDelete = "policies:delete"
+)
+
+func init() {
+	RegisterActions(
+		ActionDefinition{Action: ActionPoliciesRead, Description: "Read the policies of the current organization and their permissions"},
+		ActionDefinition{Action: ActionPoliciesCreate, Description: "Create policies in the current organization"},
+		ActionDefinition{Action: ActionPoliciesWrite, Description: "Update the policies of the current organization"},
+		ActionDefinition{Action: ActionPoliciesDelete, Description: "Delete the policies of the current organization along with their bindings"},
+	)
+	RegisterScopes(ScopeDefinition{Scope: "policies:uid:<uid>", Description: "A policy identified by its uid"})
+}
+
+// managedPolicyUIDPrefix prefixes the uids of the policies managed by Grafana, such as the suspended
+// policy and the policies of resource permissions. The policy and permission methods refuse to create,
+// change or delete them, Grafana maintains them itself.
+const managedPolicyUIDPrefix = "managed-"
+
+// isManagedPolicy returns true if the uid is the one of a policy managed by Grafana.
+func isManagedPolicy(uid string) bool {
+	return strings.HasPrefix(uid, managedPolicyUIDPrefix)
+}
+
+// GetPolicies returns all policies in an organization.
+func (ac *RBACService) GetPolicies(ctx context.Context, orgID int64) ([]*PolicyDTO, error) {
+	var policies []*PolicyDTO
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		return sess.Table("policy").Where("org_id = ?", orgID).Asc("name").Find(&policies)
+	})
+
+	return policies, err
+}
+
+// GetPolicy returns a single policy, looked up by id or uid, together with its permissions.
+func (ac *RBACService) GetPolicy(ctx context.Context, query GetPolicyQuery) (*PolicyDTO, error) {
+	var policy *PolicyDTO
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		policy, err = getPolicyDTO(sess, query)
+		return err
+	})
+
+	return policy, err
+}
+
+// CreatePolicy adds a policy to an organization.
+func (ac *RBACService) CreatePolicy(ctx context.Context, cmd CreatePolicyCommand) (*Policy, error) {
+	if err := ac.checkSchemaVersion(schemaVersionPolicyEnabled); err != nil {
+		return nil, err
+	}
+	if err := validatePrecedence(cmd.Precedence); err != nil {
+		return nil, err
+	}
+	if isManagedPolicy(cmd.UID) {
+		return nil, ErrManagedPolicy
+	}
+
+	policy := &Policy{
+		OrgID:       cmd.OrgID,
+		UID:         cmd.UID,
+		Name:        cmd.Name,
+		Description: cmd.Description,
+		Precedence:  cmd.Precedence,
+		Enabled:     true,
+		Created:     time.Now(),
+		Updated:     time.Now(),
+	}
+	if policy.UID == "" {
+		uid, err := ac.generatePolicyUID()
+		if err != nil {
+			return nil, err
+		}
+		policy.UID = uid
+	}
+
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := sess.Table("policy").Insert(policy); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrPolicyAlreadyExists
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// UpdatePolicy updates the name, uid, description and precedence of a policy.
+func (ac *RBACService) UpdatePolicy(ctx context.Context, cmd UpdatePolicyCommand) (*PolicyDTO, error) {
+	if err := ac.checkSchemaVersion(schemaVersionPolicyEnabled); err != nil {
+		return nil, err
+	}
+	if err := validatePrecedence(cmd.Precedence); err != nil {
+		return nil, err
+	}
+	if isManagedPolicy(cmd.UID) {
+		return nil, ErrManagedPolicy
+	}
+
+	var policy *PolicyDTO
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		existing, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.ID})
+		if err != nil {
+			return err
+		}
+		if isManagedPolicy(existing.UID) {
+			return ErrManagedPolicy
+		}
+
+		existing.Name = cmd.Name
+		existing.Description = cmd.Description
+		existing.Precedence = cmd.Precedence
+		if cmd.UID != "" {
+			existing.UID = cmd.UID
+		}
+		existing.Updated = time.Now()
+
+		if _, err := sess.Table("policy").ID(existing.ID).AllCols().Update(existing); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrPolicyAlreadyExists
+			}
+			return err
+		}
+
+		policy, err = getPolicyDTO(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.ID})
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.publishPolicyChanged(cmd.OrgID, cmd.ID, PolicyChangedUpdated)
+
+	return policy, nil
+}
+
+// SetPolicyEnabled activates or deactivates a policy. The permissions of a deactivated policy are
+// ignored by evaluation but the policy and its bindings are kept, e.g. to find out whether a policy
+// is still needed before deleting it.
+func (ac *RBACService) SetPolicyEnabled(ctx context.Context, cmd SetPolicyEnabledCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionPolicyEnabled); err != nil {
+		return err
+	}
+
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		policy, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.ID})
+		if err != nil {
+			return err
+		}
+		if isManagedPolicy(policy.UID) {
+			return ErrManagedPolicy
+		}
+
+		policy.Enabled = cmd.Enabled
+		policy.Updated = time.Now()
+		_, err = sess.Table("policy").ID(policy.ID).Cols("enabled", "updated").Update(policy)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishPolicyChanged(cmd.OrgID, cmd.ID, PolicyChangedUpdated)
+
+	return nil
+}
+
+// DeletePolicy removes a policy together with its permissions and team bindings. Managed policies
+// can't be deleted, e.g. the suspended policy, which the user_suspension rows rely on.
+func (ac *RBACService) DeletePolicy(ctx context.Context, cmd DeletePolicyCommand) error {
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		policy, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.ID})
+		if err != nil {
+			return err
+		}
+		if isManagedPolicy(policy.UID) {
+			return ErrManagedPolicy
+		}
+
+		if _, err := sess.Exec("DELETE FROM permission WHERE policy_id = ?", policy.ID); err != nil {
+			return err
+		}
+		if _, err := sess.Exec("DELETE FROM team_policy WHERE policy_id = ?", policy.ID); err != nil {
+			return err
+		}
+		if _, err := sess.Exec("DELETE FROM user_policy WHERE policy_id = ?", policy.ID); err != nil {
+			return err
+		}
+		if _, err := sess.Exec("DELETE FROM builtin_role_policy WHERE policy_id = ?", policy.ID); err != nil {
+			return err
+		}
+		if _, err := sess.Exec("DELETE FROM api_key_policy WHERE policy_id = ?", policy.ID); err != nil {
+			return err
+		}
+		if ac.schemaVersion >= schemaVersionDefaultPolicy {
+			if _, err := sess.Exec("DELETE FROM default_policy WHERE policy_id = ?", policy.ID); err != nil {
+				return err
+			}
+		}
+		if ac.schemaVersion >= schemaVersionUserPolicySync {
+			if _, err := sess.Exec("DELETE FROM user_policy_sync WHERE policy_id = ?", policy.ID); err != nil {
+				return err
+			}
+		}
+		if ac.schemaVersion >= schemaVersionExternalGroups {
+			if _, err := sess.Exec("DELETE FROM external_group_policy WHERE policy_id = ?", policy.ID); err != nil {
+				return err
+			}
+		}
+		if ac.schemaVersion >= schemaVersionPolicyGroupMapping {
+			if _, err := sess.Exec("DELETE FROM policy_group_mapping WHERE policy_id = ?", policy.ID); err != nil {
+				return err
+			}
+		}
+		if ac.schemaVersion >= schemaVersionAccessRequest {
+			if _, err := sess.Exec("DELETE FROM access_request WHERE policy_id = ?", policy.ID); err != nil {
+				return err
+			}
+		}
+		if _, err := sess.Exec("DELETE FROM policy WHERE id = ?", policy.ID); err != nil {
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishPolicyChanged(cmd.OrgID, cmd.ID, PolicyChangedDeleted)
+
+	return nil
+}
+
+// GetPolicyPermissions returns the permissions of a policy, optionally filtered by action prefix and resource type.
+func (ac *RBACService) GetPolicyPermissions(ctx context.Context, query GetPolicyPermissionsQuery) ([]Permission, error) {
+	var permissions []Permission
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: query.OrgID, PolicyID: query.PolicyID}); err != nil {
+			return err
+		}
+
+		permissions = make([]Permission, 0)
+		q := sess.Table("permission").Where("policy_id = ?", query.PolicyID)
+		if query.ActionPrefix != "" {
+			q = q.And("action "+ac.SQLStore.Dialect.LikeStr()+" ?", query.ActionPrefix+"%")
+		}
+		if query.ResourceType != "" {
+			// Permissions written by Grafana versions predating the scope_prefix column have no prefix.
+			like := ac.SQLStore.Dialect.LikeStr()
+			q = q.And("(scope_prefix "+like+" ? OR (scope_prefix IS NULL AND scope "+like+" ?))",
+				query.ResourceType+segmentSeparator+"%", query.ResourceType+segmentSeparator+"%")
+		}
+		return q.Asc("id").Find(&permissions)
+	})
+
+	return permissions, err
+}
+
+// GetScopePermissions returns the permissions of an organization whose scope has the kind, attribute
+// and identifier of the query. Wildcard scopes only match a query for the wildcard itself.
+func (ac *RBACService) GetScopePermissions(ctx context.Context, query GetScopePermissionsQuery) ([]Permission, error) {
+	permissions := make([]Permission, 0)
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := sess.Table("permission").
+			Join("INNER", "policy", "permission.policy_id = policy.id").
+			Where("policy.org_id = ?", query.OrgID)
+
+		segments := []string{query.Kind}
+		filter := "permission.scope_kind = ?"
+		args := []interface{}{query.Kind}
+		if query.Attribute != "" {
+			segments = append(segments, query.Attribute)
+			filter += " AND permission.scope_attribute = ?"
+			args = append(args, query.Attribute)
+			if query.Identifier != "" {
+				segments = append(segments, query.Identifier)
+				filter += " AND permission.scope_identifier = ?"
+				args = append(args, query.Identifier)
+			}
+		}
+
+		// Permissions written by Grafana versions predating the scope segment columns have no segments.
+		scope := strings.Join(segments, segmentSeparator)
+		if len(segments) < 3 {
+			scope += segmentSeparator + "%"
+		}
+		filter = "((" + filter + ") OR (permission.scope_kind IS NULL AND permission.scope " + ac.SQLStore.Dialect.LikeStr() + " ?))"
+		args = append(args, scope)
+
+		return q.And(filter, args...).Asc("permission.id").Select("permission.*").Find(&permissions)
+	})
+
+	return permissions, err
+}
+
+// CreatePermission adds a permission to a policy of the organization.
+func (ac *RBACService) CreatePermission(ctx context.Context, cmd CreatePermissionCommand) (*Permission, error) {
+	if err := ac.checkSchemaVersion(schemaVersionPermissionScopeSegments); err != nil {
+		return nil, err
+	}
+	if err := validatePermission(cmd.Action, cmd.Scope); err != nil {
+		return nil, err
+	}
+	if err := validateRegisteredAction(cmd.Action); err != nil {
+		return nil, err
+	}
+	if err := validateConditions(cmd.Conditions); err != nil {
+		return nil, err
+	}
+	if cmd.ExpiresAt != nil && !cmd.ExpiresAt.After(time.Now()) {
+		return nil, ErrPermissionExpiryInPast
+	}
+	kind, err := normalizePermissionKind(cmd.Kind)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := normalizeScope(cmd.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	permission := &Permission{
+		PolicyID:   cmd.PolicyID,
+		Action:     cmd.Action,
+		Kind:       kind,
+		Conditions: cmd.Conditions,
+		ExpiresAt:  cmd.ExpiresAt,
+		Created:    time.Now(),
+		Updated:    time.Now(),
+	}
+	permission.setScope(scope)
+
+	err = ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getUnmanagedPolicy(sess, cmd.OrgID, cmd.PolicyID); err != nil {
+			return err
+		}
+		if err := ac.checkPermissionLimit(sess, cmd.PolicyID, 1); err != nil {
+			return err
+		}
+		if _, err := sess.Table("permission").Insert(permission); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrPermissionAlreadyExists
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.publishPolicyChanged(cmd.OrgID, cmd.PolicyID, PolicyChangedPermissions)
+
+	return permission, nil
+}
+
+// UpdatePermission updates the action, scope, kind and conditions of a permission of a policy of the organization.
+func (ac *RBACService) UpdatePermission(ctx context.Context, cmd UpdatePermissionCommand) (*Permission, error) {
+	if err := ac.checkSchemaVersion(schemaVersionPermissionScopeSegments); err != nil {
+		return nil, err
+	}
+	if err := validatePermission(cmd.Action, cmd.Scope); err != nil {
+		return nil, err
+	}
+	if err := validateRegisteredAction(cmd.Action); err != nil {
+		return nil, err
+	}
+	if err := validateConditions(cmd.Conditions); err != nil {
+		return nil, err
+	}
+	if cmd.ExpiresAt != nil && !cmd.ExpiresAt.After(time.Now()) {
+		return nil, ErrPermissionExpiryInPast
+	}
+	kind, err := normalizePermissionKind(cmd.Kind)
+	if err != nil {
+		return nil, err
+	}
+	scope, err := normalizeScope(cmd.Scope)
+	if err != nil {
+		return nil, err
+	}
+
+	var permission Permission
+	err = ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		has, err := sess.Table("permission").ID(cmd.ID).Get(&permission)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return ErrPermissionNotFound
+		}
+		if _, err := getUnmanagedPolicy(sess, cmd.OrgID, permission.PolicyID); err != nil {
+			return err
+		}
+
+		permission.Action = cmd.Action
+		permission.setScope(scope)
+		permission.Kind = kind
+		permission.Conditions = cmd.Conditions
+		permission.ExpiresAt = cmd.ExpiresAt
+		permission.Updated = time.Now()
+
+		if _, err := sess.Table("permission").ID(permission.ID).AllCols().Update(&permission); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrPermissionAlreadyExists
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.publishPolicyChanged(cmd.OrgID, permission.PolicyID, PolicyChangedPermissions)
+
+	return &permission, nil
+}
+
+// DeletePermission removes a permission of a policy of the organization.
+func (ac *RBACService) DeletePermission(ctx context.Context, cmd DeletePermissionCommand) error {
+	var permission Permission
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		has, err := sess.Table("permission").ID(cmd.ID).Get(&permission)
+		if err != nil {
+			return err
+		}
+		if !has {
+			return ErrPermissionNotFound
+		}
+		if _, err := getUnmanagedPolicy(sess, cmd.OrgID, permission.PolicyID); err != nil {
+			return err
+		}
+
+		_, err = sess.Exec("DELETE FROM permission WHERE id = ?", cmd.ID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishPolicyChanged(cmd.OrgID, permission.PolicyID, PolicyChangedPermissions)
+
+	return nil
+}
+
+// normalizePermissionKind validates a permission kind, defaulting to allow.
+func normalizePermissionKind(kind string) (string, error) {
+	switch kind {
+	case "", PermissionKindAllow:
+		return PermissionKindAllow, nil
+	case PermissionKindDeny:
+		return PermissionKindDeny, nil
+	default:
+		return "", ErrInvalidPermissionKind
+	}
+}
+
+func getPolicy(sess *sqlstore.DBSession, query GetPolicyQuery) (*Policy, error) {
+	policy := &Policy{}
+	q := sess.Table("policy").Where("org_id = ?", query.OrgID)
+	if query.UID != "" {
+		q = q.Where("uid = ?", query.UID)
+	} else {
+		q = q.Where("id = ?", query.PolicyID)
+	}
+
+	has, err := q.Get(policy)
+	if err != nil {
+		return nil, err
+	}
+	if !has {
+		return nil, ErrPolicyNotFound
+	}
+
+	return policy, nil
+}
+
+func getPolicyDTO(sess *sqlstore.DBSession, query GetPolicyQuery) (*PolicyDTO, error) {
+	policy, err := getPolicy(sess, query)
+	if err != nil {
+		return nil, err
+	}
+
+	permissions, err := getPolicyPermissions(sess, policy.ID)
+	if err != nil {
+		return nil, err
+	}
+
+	return &PolicyDTO{
+		ID:          policy.ID,
+		OrgID:       policy.OrgID,
+		UID:         policy.UID,
+		Name:        policy.Name,
+		Description: policy.Description,
+		Precedence:  policy.Precedence,
+		Enabled:     policy.Enabled,
+		Permissions: permissions,
+		Created:     policy.Created,
+		Updated:     policy.Updated,
+	}, nil
+}
+
+// getUnmanagedPolicy returns a policy of the organization whose permissions may be changed, i.e.
+// that isn't managed by Grafana.
+func getUnmanagedPolicy(sess *sqlstore.DBSession, orgID, policyID int64) (*Policy, error) {
+	policy, err := getPolicy(sess, GetPolicyQuery{OrgID: orgID, PolicyID: policyID})
+	if err != nil {
+		return nil, err
+	}
+	if isManagedPolicy(policy.UID) {
+		return nil, ErrManagedPolicy
+	}
+
+	return policy, nil
+}
+
+func getPolicyPermissions(sess *sqlstore.DBSession, policyID int64) ([]Permission, error) {
+	permissions := make([]Permission, 0)
+	err := sess.Table("permission").Where("policy_id = ?", policyID).Asc("id").Find(&permissions)
+
+	return permissions, err
+}
+
+// validatePrecedence returns ErrInvalidPrecedence if the precedence is reserved for managed policies.
+func validatePrecedence(precedence *int) error {
+	if precedence != nil && *precedence >= suspendedPolicyPrecedence {
+		return ErrInvalidPrecedence
+	}
+
+	return nil
+}
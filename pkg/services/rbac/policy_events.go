@@ -0,0 +1,35 @@
+package rbac
+
+import (
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+)
+
+// Reasons of the PolicyChanged events published by the RBAC service.
+const (
+	PolicyChangedUpdated             = "updated"
+	PolicyChangedDeleted             = "deleted"
+	PolicyChangedPermissions         = "permissions"
+	PolicyChangedResourcePermissions = "resourcePermissions"
+	PolicyChangedBuiltinRoleBound    = "builtinRoleBound"
+	PolicyChangedBuiltinRoleUnbound  = "builtinRoleUnbound"
+	PolicyChangedAPIKeyAttached      = "apiKeyAttached"
+	PolicyChangedAPIKeyDetached      = "apiKeyDetached"
+	PolicyChangedStateRestored       = "stateRestored"
+)
+
+// publishPolicyChanged publishes that the permissions granted by a policy changed, once the change
+// is committed. Failures are logged since the policy changed regardless.
+func (ac *RBACService) publishPolicyChanged(orgID, policyID int64, reason string) {
+	e := &events.PolicyChanged{
+		Timestamp: time.Now(),
+		OrgID:     orgID,
+		PolicyID:  policyID,
+		Reason:    reason,
+	}
+	if err := bus.Publish(e); err != nil {
+		ac.log.Error("Failed to publish policy changed event", "orgId", orgID, "policyId", policyID, "reason", reason, "error", err)
+	}
+}
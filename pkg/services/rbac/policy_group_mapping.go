@@ -0,0 +1,92 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// GetPolicyGroupMappings returns the external group mappings of an organization, ordered by group.
+func (ac *RBACService) GetPolicyGroupMappings(ctx context.Context, query GetPolicyGroupMappingsQuery) ([]*PolicyGroupMapping, error) {
+	mappings := make([]*PolicyGroupMapping, 0)
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := sess.Table("policy_group_mapping").Where("org_id = ?", query.OrgID)
+		if query.PolicyID != 0 {
+			q = q.And("policy_id = ?", query.PolicyID)
+		}
+		return q.Asc("group_id", "policy_id").Find(&mappings)
+	})
+
+	return mappings, err
+}
+
+// AddPolicyGroupMapping maps a policy to an external group, e.g. an LDAP group DN or an OAuth group
+// id. The policy is bound to the users of the group the next time they log in, and unbound from
+// those who left it.
+func (ac *RBACService) AddPolicyGroupMapping(ctx context.Context, cmd AddPolicyGroupMappingCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionPolicyGroupMapping); err != nil {
+		return err
+	}
+	if err := validateExternalGroupID(cmd.GroupID); err != nil {
+		return err
+	}
+
+	return ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID}); err != nil {
+			return err
+		}
+
+		mapping := &PolicyGroupMapping{
+			OrgID:    cmd.OrgID,
+			PolicyID: cmd.PolicyID,
+			GroupID:  cmd.GroupID,
+			Created:  time.Now(),
+		}
+		if _, err := sess.Table("policy_group_mapping").Insert(mapping); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrPolicyGroupMappingAlreadyExists
+			}
+			return err
+		}
+
+		return nil
+	})
+}
+
+// RemovePolicyGroupMapping removes the mapping of a policy to an external group. The users of the
+// group keep the policy until they log in again.
+func (ac *RBACService) RemovePolicyGroupMapping(ctx context.Context, cmd RemovePolicyGroupMappingCommand) error {
+	return ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		result, err := sess.Exec("DELETE FROM policy_group_mapping WHERE org_id = ? AND policy_id = ? AND group_id = ?",
+			cmd.OrgID, cmd.PolicyID, cmd.GroupID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return ErrPolicyGroupMappingNotFound
+		}
+
+		return nil
+	})
+}
+
+// groupMappedPolicies returns the ids of the policies mapped to any of the groups, by organization.
+func (ac *RBACService) groupMappedPolicies(sess *sqlstore.DBSession, groups []string) (map[int64][]int64, error) {
+	policyIDs := map[int64][]int64{}
+	if len(groups) == 0 || ac.schemaVersion < schemaVersionPolicyGroupMapping {
+		return policyIDs, nil
+	}
+
+	var mappings []PolicyGroupMapping
+	if err := sess.Table("policy_group_mapping").In("group_id", groups).Find(&mappings); err != nil {
+		return nil, err
+	}
+	for _, m := range mappings {
+		policyIDs[m.OrgID] = append(policyIDs[m.OrgID], m.PolicyID)
+	}
+
+	return policyIDs, nil
+}
@@ -0,0 +1,71 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestPolicyGroupMappings(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	const userID = 341
+	const editorsDN = "cn=editors,ou=groups,dc=grafana,dc=org"
+	editor := createPolicy(t, ac, 1, "mapped editor", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+	viewer := createPolicy(t, ac, 1, "mapped viewer", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddPolicyGroupMapping(context.Background(), AddPolicyGroupMappingCommand{OrgID: 1, PolicyID: editor.ID, GroupID: editorsDN}))
+	require.NoError(t, ac.AddPolicyGroupMapping(context.Background(), AddPolicyGroupMappingCommand{OrgID: 1, PolicyID: viewer.ID, GroupID: editorsDN}))
+	require.NoError(t, ac.AddPolicyGroupMapping(context.Background(), AddPolicyGroupMappingCommand{OrgID: 1, PolicyID: viewer.ID, GroupID: "viewers"}))
+
+	login := func(groups []string, policyUIDs map[int64][]string) {
+		t.Helper()
+		require.NoError(t, bus.Dispatch(&models.SyncUserPoliciesCommand{UserId: userID, AuthModule: models.AuthModuleLDAP, PolicyUIDs: policyUIDs, Groups: groups}))
+	}
+	userPolicyNames := func() []string {
+		t.Helper()
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: userID})
+		require.NoError(t, err)
+		names := make([]string, 0, len(policies))
+		for _, p := range policies {
+			names = append(names, p.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	login([]string{editorsDN, "unmapped"}, nil)
+	assert.Equal(t, []string{"mapped editor", "mapped viewer"}, userPolicyNames())
+
+	t.Run("Mappings should be listed by group", func(t *testing.T) {
+		mappings, err := ac.GetPolicyGroupMappings(context.Background(), GetPolicyGroupMappingsQuery{OrgID: 1, PolicyID: viewer.ID})
+		require.NoError(t, err)
+		require.Len(t, mappings, 2)
+		assert.Equal(t, editorsDN, mappings[0].GroupID)
+		assert.Equal(t, "viewers", mappings[1].GroupID)
+	})
+
+	t.Run("Mapping a policy twice should fail", func(t *testing.T) {
+		err := ac.AddPolicyGroupMapping(context.Background(), AddPolicyGroupMappingCommand{OrgID: 1, PolicyID: editor.ID, GroupID: editorsDN})
+		require.ErrorIs(t, err, ErrPolicyGroupMappingAlreadyExists)
+	})
+
+	t.Run("Policies of groups the user left should be unbound at login", func(t *testing.T) {
+		login([]string{"viewers"}, nil)
+		assert.Equal(t, []string{"mapped viewer"}, userPolicyNames())
+	})
+
+	t.Run("Removed mappings should be unbound at login", func(t *testing.T) {
+		require.NoError(t, ac.RemovePolicyGroupMapping(context.Background(), RemovePolicyGroupMappingCommand{OrgID: 1, PolicyID: viewer.ID, GroupID: "viewers"}))
+		login([]string{"viewers"}, map[int64][]string{1: {editor.UID}})
+		assert.Equal(t, []string{"mapped editor"}, userPolicyNames())
+
+		err := ac.RemovePolicyGroupMapping(context.Background(), RemovePolicyGroupMappingCommand{OrgID: 1, PolicyID: viewer.ID, GroupID: "viewers"})
+		require.ErrorIs(t, err, ErrPolicyGroupMappingNotFound)
+	})
+}
@@ -0,0 +1,325 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestPolicies(t *testing.T) {
+	t.Run("Creating a policy should make it retrievable with its permissions", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "viewer-plus", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+
+		dto, err := ac.GetPolicy(context.Background(), GetPolicyQuery{OrgID: 1, PolicyID: policy.ID})
+		require.NoError(t, err)
+		assert.Equal(t, "viewer-plus", dto.Name)
+		require.Len(t, dto.Permissions, 1)
+		assert.Equal(t, "dashboards:read", dto.Permissions[0].Action)
+
+		byUID, err := ac.GetPolicy(context.Background(), GetPolicyQuery{OrgID: 1, UID: policy.UID})
+		require.NoError(t, err)
+		assert.Equal(t, policy.ID, byUID.ID)
+	})
+
+	t.Run("Creating a policy with a taken name should fail", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		createPolicy(t, ac, 1, "editor")
+		_, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "editor"})
+		require.ErrorIs(t, err, ErrPolicyAlreadyExists)
+	})
+
+	t.Run("Policies should not be visible from other organizations", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "editor")
+		_, err := ac.GetPolicy(context.Background(), GetPolicyQuery{OrgID: 2, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+
+		policies, err := ac.GetPolicies(context.Background(), 2)
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+
+	t.Run("Updating a policy should change its name and description", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "editor")
+		updated, err := ac.UpdatePolicy(context.Background(), UpdatePolicyCommand{ID: policy.ID, OrgID: 1, Name: "writer", Description: "can write"})
+		require.NoError(t, err)
+		assert.Equal(t, "writer", updated.Name)
+		assert.Equal(t, "can write", updated.Description)
+		assert.Equal(t, policy.UID, updated.UID)
+	})
+
+	t.Run("Deleting a policy should remove its permissions and team bindings", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "editor", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+		require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: 1, PolicyID: policy.ID}))
+
+		require.NoError(t, ac.DeletePolicy(context.Background(), DeletePolicyCommand{ID: policy.ID, OrgID: 1}))
+
+		_, err := ac.GetPolicy(context.Background(), GetPolicyQuery{OrgID: 1, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+
+		policies, err := ac.GetTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: 1, TeamID: 1})
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+}
+
+func TestSetPolicyEnabled(t *testing.T) {
+	ac := setupTestEnv(t)
+	user := &models.SignedInUser{OrgId: 1, UserId: 211}
+
+	policy := createPolicy(t, ac, 1, "legacy", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 211, PolicyID: policy.ID}))
+	require.True(t, policy.Enabled)
+
+	require.NoError(t, ac.SetPolicyEnabled(context.Background(), SetPolicyEnabledCommand{OrgID: 1, ID: policy.ID, Enabled: false}))
+
+	ok, err := ac.evaluate(context.Background(), user, accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:abc"})
+	require.NoError(t, err)
+	assert.False(t, ok)
+
+	policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: 211})
+	require.NoError(t, err)
+	require.Len(t, policies, 1)
+	assert.False(t, policies[0].Enabled)
+
+	require.NoError(t, ac.SetPolicyEnabled(context.Background(), SetPolicyEnabledCommand{OrgID: 1, ID: policy.ID, Enabled: true}))
+	ok, err = ac.evaluate(context.Background(), user, accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:abc"})
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	err = ac.SetPolicyEnabled(context.Background(), SetPolicyEnabledCommand{OrgID: 2, ID: policy.ID})
+	require.ErrorIs(t, err, ErrPolicyNotFound)
+}
+
+func TestPermissions(t *testing.T) {
+	t.Run("Permissions can be updated and deleted", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "editor")
+		permission, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "dashboards:read", Scope: "dashboards:*"})
+		require.NoError(t, err)
+
+		_, err = ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "dashboards:read", Scope: "dashboards:*"})
+		require.ErrorIs(t, err, ErrPermissionAlreadyExists)
+
+		updated, err := ac.UpdatePermission(context.Background(), UpdatePermissionCommand{OrgID: 1, ID: permission.ID, Action: "dashboards:write", Scope: "dashboards:*"})
+		require.NoError(t, err)
+		assert.Equal(t, "dashboards:write", updated.Action)
+
+		permissions, err := ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID})
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "dashboards:write", permissions[0].Action)
+
+		require.NoError(t, ac.DeletePermission(context.Background(), DeletePermissionCommand{OrgID: 1, ID: permission.ID}))
+		err = ac.DeletePermission(context.Background(), DeletePermissionCommand{OrgID: 1, ID: permission.ID})
+		require.ErrorIs(t, err, ErrPermissionNotFound)
+	})
+
+	t.Run("Permissions of missing policies or policies of other organizations should be refused", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "editor", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+		permissions, err := ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID})
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+
+		_, err = ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: 1, PolicyID: policy.ID + 100, Action: "dashboards:write", Scope: "dashboards:*"})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+		_, err = ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: 2, PolicyID: policy.ID, Action: "dashboards:write", Scope: "dashboards:*"})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+		_, err = ac.UpdatePermission(context.Background(), UpdatePermissionCommand{OrgID: 2, ID: permissions[0].ID, Action: "dashboards:write", Scope: "dashboards:*"})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+		err = ac.DeletePermission(context.Background(), DeletePermissionCommand{OrgID: 2, ID: permissions[0].ID})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+
+		permissions, err = ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID})
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "dashboards:read", permissions[0].Action)
+	})
+
+	t.Run("Permissions can be filtered by action prefix and resource type", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "auditor",
+			CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"},
+			CreatePermissionCommand{Action: "dashboards:read", Scope: "folders:id:1"},
+			CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:id:1"},
+			CreatePermissionCommand{Action: "datasources:query", Scope: "dashboards:uid:abc"},
+		)
+
+		permissions, err := ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID, ActionPrefix: "dashboards:"})
+		require.NoError(t, err)
+		assert.Len(t, permissions, 2)
+
+		permissions, err = ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID, ResourceType: "dashboards"})
+		require.NoError(t, err)
+		require.Len(t, permissions, 2)
+		assert.Equal(t, "dashboards:*", permissions[0].Scope)
+		assert.Equal(t, "dashboards:uid:abc", permissions[1].Scope)
+
+		permissions, err = ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID, ActionPrefix: "dashboards:", ResourceType: "folders"})
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "folders:id:1", permissions[0].Scope)
+	})
+}
+
+func TestManagedPolicies(t *testing.T) {
+	ac := setupTestEnv(t)
+	managed := createManagedPolicy(t, ac, 1, "managed-resource")
+	permission, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: managed.OrgID, PolicyID: managed.ID, Action: "dashboards:read", Scope: "dashboards:*"})
+	require.ErrorIs(t, err, ErrManagedPolicy)
+	require.Nil(t, permission)
+	permissionID := insertManagedPermission(t, ac, managed.ID, "dashboards:read", "dashboards:*")
+
+	t.Run("Policies shouldn't be created with a managed uid", func(t *testing.T) {
+		_, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, UID: "managed-fake", Name: "fake"})
+		require.ErrorIs(t, err, ErrManagedPolicy)
+		assert.Equal(t, ErrorKindValidation, ErrorKindOf(err))
+	})
+
+	t.Run("Policies shouldn't be renamed to a managed uid", func(t *testing.T) {
+		policy := createPolicy(t, ac, 1, "renamed")
+		_, err := ac.UpdatePolicy(context.Background(), UpdatePolicyCommand{ID: policy.ID, OrgID: 1, UID: "managed-fake", Name: "renamed"})
+		require.ErrorIs(t, err, ErrManagedPolicy)
+	})
+
+	t.Run("Managed policies shouldn't be changed or deleted", func(t *testing.T) {
+		_, err := ac.UpdatePolicy(context.Background(), UpdatePolicyCommand{ID: managed.ID, OrgID: 1, Name: "hijacked"})
+		require.ErrorIs(t, err, ErrManagedPolicy)
+		err = ac.SetPolicyEnabled(context.Background(), SetPolicyEnabledCommand{ID: managed.ID, OrgID: 1, Enabled: false})
+		require.ErrorIs(t, err, ErrManagedPolicy)
+		err = ac.DeletePolicy(context.Background(), DeletePolicyCommand{ID: managed.ID, OrgID: 1})
+		require.ErrorIs(t, err, ErrManagedPolicy)
+
+		_, err = ac.UpdatePermission(context.Background(), UpdatePermissionCommand{OrgID: 1, ID: permissionID, Action: "dashboards:write", Scope: "dashboards:*"})
+		require.ErrorIs(t, err, ErrManagedPolicy)
+		err = ac.DeletePermission(context.Background(), DeletePermissionCommand{OrgID: 1, ID: permissionID})
+		require.ErrorIs(t, err, ErrManagedPolicy)
+
+		dto, err := ac.GetPolicy(context.Background(), GetPolicyQuery{OrgID: 1, PolicyID: managed.ID})
+		require.NoError(t, err)
+		assert.Equal(t, managed.Name, dto.Name)
+		assert.True(t, dto.Enabled)
+		require.Len(t, dto.Permissions, 1)
+		assert.Equal(t, "dashboards:read", dto.Permissions[0].Action)
+	})
+}
+
+func TestTeamPolicies(t *testing.T) {
+	t.Run("Binding a policy to a team should grant its permissions to team members", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		team := createTeam(t, 1, "platform")
+		addTeamMember(t, 1, team.Id, 42)
+
+		policy := createPolicy(t, ac, 1, "editor", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+		require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+		err := ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrTeamPolicyAlreadyAdded)
+
+		policies, err := ac.GetTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: 1, TeamID: team.Id})
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+		assert.Equal(t, policy.ID, policies[0].ID)
+
+		permissions, err := ac.GetUserPermissions(context.Background(), GetUserPermissionsQuery{OrgID: 1, UserID: 42})
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "dashboards:write", permissions[0].Action)
+
+		require.NoError(t, ac.RemoveTeamPolicy(context.Background(), RemoveTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+		err = ac.RemoveTeamPolicy(context.Background(), RemoveTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrTeamPolicyNotFound)
+
+		permissions, err = ac.GetUserPermissions(context.Background(), GetUserPermissionsQuery{OrgID: 1, UserID: 42})
+		require.NoError(t, err)
+		assert.Empty(t, permissions)
+	})
+
+	t.Run("Binding a policy from another organization should fail", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 2, "editor")
+		err := ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: 1, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+	})
+}
+
+func createPolicy(t *testing.T, ac *RBACService, orgID int64, name string, permissions ...CreatePermissionCommand) *Policy {
+	t.Helper()
+
+	policy, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: orgID, Name: name})
+	require.NoError(t, err)
+
+	for _, cmd := range permissions {
+		cmd.OrgID = orgID
+		cmd.PolicyID = policy.ID
+		_, err := ac.CreatePermission(context.Background(), cmd)
+		require.NoError(t, err)
+	}
+
+	return policy
+}
+
+func createTeam(t *testing.T, orgID int64, name string) models.Team {
+	t.Helper()
+
+	cmd := &models.CreateTeamCommand{OrgId: orgID, Name: name}
+	require.NoError(t, sqlstore.CreateTeam(cmd))
+
+	return cmd.Result
+}
+
+func addTeamMember(t *testing.T, orgID, teamID, userID int64) {
+	t.Helper()
+
+	require.NoError(t, sqlstore.AddTeamMember(&models.AddTeamMemberCommand{OrgId: orgID, TeamId: teamID, UserId: userID}))
+}
+
+// createManagedPolicy inserts a policy managed by Grafana, which CreatePolicy refuses to create.
+func createManagedPolicy(t *testing.T, ac *RBACService, orgID int64, uid string) *Policy {
+	t.Helper()
+
+	policy := &Policy{OrgID: orgID, UID: uid, Name: "managed:" + uid, Enabled: true, Created: time.Now(), Updated: time.Now()}
+	err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Table("policy").Insert(policy)
+		return err
+	})
+	require.NoError(t, err)
+
+	return policy
+}
+
+// insertManagedPermission inserts a permission into a policy managed by Grafana, which CreatePermission
+// refuses to do.
+func insertManagedPermission(t *testing.T, ac *RBACService, policyID int64, action, scope string) int64 {
+	t.Helper()
+
+	permission := &Permission{PolicyID: policyID, Action: action, Kind: PermissionKindAllow, Created: time.Now(), Updated: time.Now()}
+	permission.setScope(scope)
+	err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Table("permission").Insert(permission)
+		return err
+	})
+	require.NoError(t, err)
+
+	return permission.ID
+}
@@ -0,0 +1,233 @@
+package rbac
+
+import (
+	"context"
+	"net"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	"github.com/grafana/grafana/pkg/infra/serverlock"
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/search"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+// requiredTables are the tables that must exist for the RBAC service to be used. They're every table
+// created by addRBACMigrations, which TestRequiredTables checks.
+var requiredTables = []string{
+	"policy", "permission", "team_policy", "user_suspension", "user_policy", "builtin_role_policy",
+	"service_account", "api_key_policy", "default_policy", "role_migration", "user_policy_sync",
+	"binding_lifetime", "user_policy_expiry_notice", "external_group_policy", "external_group_member",
+	"policy_group_mapping", "break_glass_grant", "access_request", "enforcement_mode",
+}
+
+// RBACService is the service implementing role based access control.
+type RBACService struct {
+	Cfg               *setting.Cfg                  `inject:""`
+	SQLStore          *sqlstore.SQLStore            `inject:""`
+	ServerLockService *serverlock.ServerLockService `inject:""`
+	RemoteCache       *remotecache.RemoteCache      `inject:""`
+	SearchService     *search.SearchService         `inject:""`
+	log               log.Logger
+
+	// degraded is set during Init when the feature toggle is on but the RBAC
+	// tables are missing from the database.
+	degraded bool
+	// schemaVersion is the RBAC schema version of the database, loaded during Init.
+	schemaVersion int
+
+	scopeResolvers      scopeResolvers
+	decisionMiddlewares decisionMiddlewares
+	// uidGenerators generate the uids of the policies created without one, see RegisterUIDGenerator.
+	uidGenerators uidGenerators
+	// maxPermissionsPerPolicy and warnPermissionsPerPolicy limit the size of policies, zero disables them.
+	maxPermissionsPerPolicy  int
+	warnPermissionsPerPolicy int
+	// provisionTeamFolders creates a folder editable by each new team, see CreateTeamWithFolder.
+	provisionTeamFolders bool
+	// trustedProxies are the networks of the proxies whose X-Forwarded-For hops are trusted.
+	trustedProxies []*net.IPNet
+	// folderDashboards caches the dashboards of each folder, see expandFolderScopes.
+	folderDashboards *localcache.CacheService
+	// taggedDashboards caches the dashboards with each tag, see expandTagScopes.
+	taggedDashboards *localcache.CacheService
+	// denials keeps the recent denied access checks, see SearchDenials. Nil when disabled.
+	denials *denialLog
+	// decisions logs the denials and a sample of the allowed access checks, nil when disabled.
+	decisions *decisionLog
+	// authorizationServer are the settings of the gRPC authorization service, see runAuthorizationServer.
+	authorizationServer authorizationServerSettings
+	// extAuthz are the settings of the ext_authz adapter, see AuthorizeExtAuthz. Nil when disabled.
+	extAuthz *extAuthzSettings
+	// externalPDP decides the access checks deferred to an external policy decision point, nil when disabled.
+	externalPDP *externalPDP
+	// opaBundle writes the OPA bundle of the policies and bindings, nil when disabled.
+	opaBundle *opaBundleExporter
+	// compatResourceTypes are the resource types in compat mode, see ResourceMode.
+	compatResourceTypes map[string]bool
+	// shadowResourceTypes are the resource types in shadow mode, see ResourceMode.
+	shadowResourceTypes map[string]bool
+	// bindingExpiryNotice is how long before expiring user policy bindings are notified, zero disables it.
+	bindingExpiryNotice time.Duration
+	// breakGlass are the settings of the break-glass elevations, see BreakGlass.
+	breakGlass breakGlassSettings
+	// permissionCache caches the permissions of users, nil when disabled.
+	permissionCache *permissionCache
+	// denyCache caches the denied access checks, nil when disabled.
+	denyCache *denyCache
+	// sessionPermissions signs the permissions of users into their login session, nil when disabled.
+	sessionPermissions *sessionPermissions
+	// combiningAlgorithms are the combining algorithms of the actions, see loadCombiningAlgorithms.
+	combiningAlgorithms *combiningAlgorithms
+	// enforcementModes caches the enforcement modes of the actions, see SetEnforcementMode.
+	enforcementModes *enforcementModes
+}
+
+func init() {
+	registry.RegisterService(&RBACService{})
+}
+
+// NewStandaloneService returns an RBAC service for the commands run against the database outside of a
+// Grafana server, e.g. by the CLI. It isn't initialized, so it's meant for the methods that check the
+// schema version themselves such as RepairBindings and RestoreState.
+func NewStandaloneService(cfg *setting.Cfg, sqlStore *sqlstore.SQLStore) *RBACService {
+	return &RBACService{Cfg: cfg, SQLStore: sqlStore, log: log.New("rbac")}
+}
+
+// Init initializes the RBAC service.
+func (ac *RBACService) Init() error {
+	ac.log = log.New("rbac")
+
+	if !ac.isFeatureEnabled() {
+		return nil
+	}
+
+	ac.registerDefaultScopeResolvers()
+	ac.registerDefaultUIDGenerators()
+	ac.loadDecisionMiddlewaresOrder()
+	ac.loadPermissionLimits()
+	ac.loadTeamFolderSettings()
+	ac.loadDenialLogSettings()
+	ac.loadDecisionLogSettings()
+	ac.loadBindingLifetimeSettings()
+	ac.loadUIDGeneratorSettings()
+	ac.loadBreakGlassSettings()
+	ac.loadPermissionCacheSettings()
+	ac.loadDenyCacheSettings()
+	ac.loadSessionPermissionsSettings()
+	ac.loadOPABundleSettings()
+	if err := ac.loadTrustedProxies(); err != nil {
+		return err
+	}
+	if err := ac.loadResourceModes(); err != nil {
+		return err
+	}
+	if err := ac.loadCombiningAlgorithms(); err != nil {
+		return err
+	}
+	if err := ac.loadAuthorizationServerSettings(); err != nil {
+		return err
+	}
+	if err := ac.loadExtAuthzSettings(); err != nil {
+		return err
+	}
+	if err := ac.loadExternalPDPSettings(); err != nil {
+		return err
+	}
+	ac.enforcementModes = &enforcementModes{}
+	ac.folderDashboards = newDashboardsCache()
+	ac.taggedDashboards = newDashboardsCache()
+
+	missing, err := ac.missingTables(context.Background(), requiredTables)
+	if err != nil {
+		return err
+	}
+	if len(missing) > 0 {
+		ac.degraded = true
+		ac.log.Warn("RBAC is enabled but its database tables are missing, enforcement is disabled until migrations have run",
+			"missing", strings.Join(missing, ","))
+	}
+
+	if err := ac.loadSchemaVersion(context.Background()); err != nil {
+		return err
+	}
+	if ac.schemaVersion < supportedSchemaVersion {
+		ac.log.Warn("RBAC database schema is outdated, writes requiring a newer schema will be refused",
+			"schemaVersion", ac.schemaVersion, "supportedSchemaVersion", supportedSchemaVersion)
+	}
+
+	bus.AddEventListener(ac.onOrgUserAdded)
+	bus.AddEventListener(ac.onTeamMemberAdded)
+	bus.AddEventListener(ac.onTeamMemberRemoved)
+	bus.AddEventListener(ac.onUserDeleted)
+	bus.AddEventListener(ac.onTeamDeleted)
+	bus.AddEventListener(ac.onOrgDeleted)
+	bus.AddEventListener(ac.invalidateOnPolicyBound)
+	bus.AddEventListener(ac.invalidateOnPolicyUnbound)
+	bus.AddEventListener(ac.invalidateOnPolicyBindingExpired)
+	bus.AddEventListener(ac.invalidateOnPermissionsChanged)
+	bus.AddEventListener(ac.invalidateOnPolicyChanged)
+	bus.AddEventListener(ac.invalidateOnPermissionExpired)
+	bus.AddEventListener(ac.invalidateOnOrgDeleted)
+	bus.AddHandlerCtx("rbac", ac.SyncUserPolicies)
+	if ac.SearchService != nil {
+		ac.SearchService.RegisterPermissionFilter(ac.searchPermissionFilter)
+	}
+
+	return nil
+}
+
+// IsEnabled returns true if RBAC is enabled and its database tables are available.
+// Enforcement points should fall back to legacy access control when it returns false.
+func (ac *RBACService) IsEnabled() bool {
+	return ac.isFeatureEnabled() && !ac.degraded
+}
+
+// IsDegraded returns true if RBAC is enabled but had to be disabled because its
+// database tables are missing.
+func (ac *RBACService) IsDegraded() bool {
+	return ac.degraded
+}
+
+func (ac *RBACService) isFeatureEnabled() bool {
+	if ac.Cfg == nil {
+		return false
+	}
+
+	return ac.Cfg.IsRBACEnabled()
+}
+
+// missingTables returns the subset of tables that don't exist in the database.
+func (ac *RBACService) missingTables(ctx context.Context, tables []string) ([]string, error) {
+	var missing []string
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		for _, table := range tables {
+			exists, err := sess.IsTableExist(table)
+			if err != nil {
+				return err
+			}
+			if !exists {
+				missing = append(missing, table)
+			}
+		}
+		return nil
+	})
+
+	return missing, err
+}
+
+// AddMigration defines database migrations.
+// If RBAC is not enabled does nothing.
+func (ac *RBACService) AddMigration(mg *migrator.Migrator) {
+	if !ac.isFeatureEnabled() {
+		return
+	}
+
+	addRBACMigrations(mg)
+}
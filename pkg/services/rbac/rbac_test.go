@@ -0,0 +1,114 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"xorm.io/xorm"
+
+	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestRBACService_Init(t *testing.T) {
+	t.Run("When the feature toggle is off, RBAC should not be enabled", func(t *testing.T) {
+		ac := &RBACService{Cfg: setting.NewCfg()}
+		require.NoError(t, ac.Init())
+
+		assert.False(t, ac.IsEnabled())
+		assert.False(t, ac.IsDegraded())
+	})
+
+	t.Run("When the feature toggle is on and the tables exist, RBAC should be enabled", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		assert.True(t, ac.IsEnabled())
+		assert.False(t, ac.IsDegraded())
+	})
+
+	t.Run("When tables are missing, they should be reported", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		missing, err := ac.missingTables(context.Background(), []string{"policy", "does_not_exist"})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"does_not_exist"}, missing)
+	})
+
+	t.Run("When the service is degraded, RBAC should not be enabled", func(t *testing.T) {
+		ac := setupTestEnv(t)
+		ac.degraded = true
+
+		assert.False(t, ac.IsEnabled())
+		assert.True(t, ac.IsDegraded())
+	})
+}
+
+func TestRequiredTables(t *testing.T) {
+	// Runs the RBAC migrations alone against an empty database to list the tables they create.
+	engine, err := xorm.NewEngine(migrator.SQLite, "file::memory:")
+	require.NoError(t, err)
+	t.Cleanup(func() { _ = engine.Close() })
+	require.NoError(t, engine.Sync2(new(migrator.MigrationLog)))
+	mg := migrator.NewMigrator(engine)
+	addRBACMigrations(mg)
+	require.NoError(t, mg.Start())
+
+	metas, err := engine.DBMetas()
+	require.NoError(t, err)
+	var tables []string
+	for _, table := range metas {
+		if table.Name != "migration_log" {
+			tables = append(tables, table.Name)
+		}
+	}
+	assert.ElementsMatch(t, tables, requiredTables)
+}
+
+func overrideRBACInRegistry(cfg *setting.Cfg) *RBACService {
+	ac := &RBACService{
+		SQLStore: nil,
+		Cfg:      cfg,
+	}
+
+	overrideServiceFunc := func(d registry.Descriptor) (*registry.Descriptor, bool) {
+		if d.Name != "RBACService" {
+			return nil, false
+		}
+
+		descriptor := registry.Descriptor{
+			Name:         "RBACService",
+			Instance:     ac,
+			InitPriority: 0,
+		}
+
+		return &descriptor, true
+	}
+
+	registry.RegisterOverride(overrideServiceFunc)
+
+	return ac
+}
+
+// setupTestEnv returns an initialized RBACService with the feature toggle enabled and
+// the RBAC tables migrated.
+func setupTestEnv(t testing.TB) *RBACService {
+	t.Helper()
+	t.Cleanup(registry.ClearOverrides)
+
+	cfg := setting.NewCfg()
+	// Everything in this service is behind the feature toggle "rbac"
+	cfg.FeatureToggles = map[string]bool{"rbac": true}
+	// Because the RBACService is behind a feature toggle, we need to override the service in the registry
+	// with a Cfg that contains the feature toggle so migrations are run properly
+	ac := overrideRBACInRegistry(cfg)
+
+	// We need to assign SQLStore after the override and migrations are done
+	ac.SQLStore = sqlstore.InitTestDB(t)
+	require.NoError(t, ac.Init())
+
+	return ac
+}
@@ -0,0 +1,108 @@
+package rbac
+
+import (
+	"sort"
+	"sync"
+)
+
+// ScopeDefinition describes a scope format that permissions can be granted on, e.g. "dashboards:uid:<uid>".
+type ScopeDefinition struct {
+	Scope       string `json:"scope"`
+	Description string `json:"description"`
+}
+
+// FixedPermission is a permission of a fixed policy.
+type FixedPermission struct {
+	Action string `json:"action"`
+	Scope  string `json:"scope"`
+	Kind   string `json:"kind"`
+}
+
+// FixedPolicyDefinition describes a policy whose contents are defined by Grafana rather than by
+// administrators, such as the deny-all policy bound to suspended users.
+type FixedPolicyDefinition struct {
+	UID         string            `json:"uid"`
+	Name        string            `json:"name"`
+	Description string            `json:"description"`
+	Permissions []FixedPermission `json:"permissions"`
+}
+
+// Reference lists the registered actions, scopes and fixed policies, it's the source of the
+// generated RBAC documentation and of the UI's help panel.
+type Reference struct {
+	Actions       []ActionDefinition      `json:"actions"`
+	Scopes        []ScopeDefinition       `json:"scopes"`
+	FixedPolicies []FixedPolicyDefinition `json:"fixedPolicies"`
+}
+
+var (
+	referenceMu   sync.RWMutex
+	scopes        = map[string]ScopeDefinition{}
+	fixedPolicies = map[string]FixedPolicyDefinition{}
+)
+
+func init() {
+	RegisterScopes(
+		ScopeDefinition{Scope: "dashboards:uid:<uid>", Description: "A dashboard identified by its uid"},
+		ScopeDefinition{Scope: "dashboards:tag:<tag>", Description: "The dashboards tagged with the tag"},
+		ScopeDefinition{Scope: "folders:id:<id>", Description: "A folder identified by its id, including its dashboards"},
+		ScopeDefinition{Scope: "folders:uid:<uid>", Description: "A folder identified by its uid, including its dashboards"},
+		ScopeDefinition{Scope: "datasources:id:<id>", Description: "A datasource identified by its id"},
+		ScopeDefinition{Scope: "datasources:name:<name>", Description: "A datasource identified by its name"},
+		ScopeDefinition{Scope: "users:id:<id>", Description: "A user identified by its id"},
+		ScopeDefinition{Scope: "teams:id:<id>", Description: "A team identified by its id"},
+		ScopeDefinition{Scope: "orgs:id:<id>", Description: "An organization identified by its id"},
+		ScopeDefinition{Scope: "alerts:namespace:<namespace>", Description: "The alerts of a namespace"},
+		ScopeDefinition{Scope: ScopeUsersSelf, Description: "The signed in user"},
+		ScopeDefinition{Scope: ScopeOrgCurrent, Description: "The signed in user's current organization"},
+	)
+}
+
+// RegisterScopes adds scope formats to the reference. Services should register the scopes of
+// their resources in an init function, next to their actions.
+func RegisterScopes(defs ...ScopeDefinition) {
+	referenceMu.Lock()
+	defer referenceMu.Unlock()
+
+	for _, def := range defs {
+		scopes[def.Scope] = def
+	}
+}
+
+// RegisterFixedPolicies adds fixed policies to the reference. Registering the same uid twice
+// replaces its definition.
+func RegisterFixedPolicies(defs ...FixedPolicyDefinition) {
+	referenceMu.Lock()
+	defer referenceMu.Unlock()
+
+	for _, def := range defs {
+		fixedPolicies[def.UID] = def
+	}
+}
+
+// GetReference returns the registered actions, scopes and fixed policies, sorted by name.
+func GetReference() Reference {
+	actionsMu.RLock()
+	ref := Reference{Actions: make([]ActionDefinition, 0, len(actions))}
+	for _, def := range actions {
+		ref.Actions = append(ref.Actions, def)
+	}
+	actionsMu.RUnlock()
+
+	referenceMu.RLock()
+	ref.Scopes = make([]ScopeDefinition, 0, len(scopes))
+	for _, def := range scopes {
+		ref.Scopes = append(ref.Scopes, def)
+	}
+	ref.FixedPolicies = make([]FixedPolicyDefinition, 0, len(fixedPolicies))
+	for _, def := range fixedPolicies {
+		ref.FixedPolicies = append(ref.FixedPolicies, def)
+	}
+	referenceMu.RUnlock()
+
+	sort.Slice(ref.Actions, func(i, j int) bool { return ref.Actions[i].Action < ref.Actions[j].Action })
+	sort.Slice(ref.Scopes, func(i, j int) bool { return ref.Scopes[i].Scope < ref.Scopes[j].Scope })
+	sort.Slice(ref.FixedPolicies, func(i, j int) bool { return ref.FixedPolicies[i].Name < ref.FixedPolicies[j].Name })
+
+	return ref
+}
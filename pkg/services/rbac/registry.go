@@ -0,0 +1,118 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"sort"
+	"strings"
+	"sync"
+)
+
+// ErrUnknownAction is an error for when a permission's action matches no registered action.
+var ErrUnknownAction = errors.New("unknown action")
+
+// ActionDefinition describes an action that can be granted by permissions.
+type ActionDefinition struct {
+	Action      string `json:"action"`
+	Description string `json:"description"`
+}
+
+var (
+	actionsMu sync.RWMutex
+	actions   = map[string]ActionDefinition{}
+)
+
+// Core actions of resources that aren't managed by a dedicated service.
+const (
+	ActionDashboardsRead   = "dashboards:read"
+	ActionDashboardsCreate = "dashboards:create"
+	ActionDashboardsWrite  = "dashboards:write"
+	ActionDashboardsDelete = "dashboards:delete"
+
+	ActionDatasourcesRead   = "datasources:read"
+	ActionDatasourcesCreate = "datasources:create"
+	ActionDatasourcesWrite  = "datasources:write"
+	ActionDatasourcesDelete = "datasources:delete"
+
+	ActionUsersRead   = "users:read"
+	ActionUsersCreate = "users:create"
+	ActionUsersWrite  = "users:write"
+	ActionUsersDelete = "users:delete"
+
+	ActionTeamsRead   = "teams:read"
+	ActionTeamsCreate = "teams:create"
+	ActionTeamsWrite  = "teams:write"
+	ActionTeamsDelete = "teams:delete"
+)
+
+func init() {
+	RegisterActions(
+		ActionDefinition{Action: ActionDashboardsRead, Description: "Read dashboards"},
+		ActionDefinition{Action: ActionDashboardsCreate, Description: "Create dashboards"},
+		ActionDefinition{Action: ActionDashboardsWrite, Description: "Update dashboards"},
+		ActionDefinition{Action: ActionDashboardsDelete, Description: "Delete dashboards"},
+		ActionDefinition{Action: ActionDatasourcesRead, Description: "Read datasources"},
+		ActionDefinition{Action: ActionDatasourcesCreate, Description: "Create datasources"},
+		ActionDefinition{Action: ActionDatasourcesWrite, Description: "Update datasources"},
+		ActionDefinition{Action: ActionDatasourcesDelete, Description: "Delete datasources"},
+		ActionDefinition{Action: ActionUsersRead, Description: "Read users"},
+		ActionDefinition{Action: ActionUsersCreate, Description: "Create users"},
+		ActionDefinition{Action: ActionUsersWrite, Description: "Update users"},
+		ActionDefinition{Action: ActionUsersDelete, Description: "Delete users"},
+		ActionDefinition{Action: ActionTeamsRead, Description: "Read teams"},
+		ActionDefinition{Action: ActionTeamsCreate, Description: "Create teams"},
+		ActionDefinition{Action: ActionTeamsWrite, Description: "Update teams and their members"},
+		ActionDefinition{Action: ActionTeamsDelete, Description: "Delete teams"},
+	)
+}
+
+// RegisterActions adds actions to the registry of valid actions. Services should
+// register the actions they check in an init function. Registering the same action
+// twice replaces its definition.
+func RegisterActions(defs ...ActionDefinition) {
+	actionsMu.Lock()
+	defer actionsMu.Unlock()
+
+	for _, def := range defs {
+		actions[def.Action] = def
+	}
+}
+
+// GetActionsQuery filters the registered actions by prefix, e.g. "dashboards:".
+type GetActionsQuery struct {
+	Prefix string
+}
+
+// GetActions returns the registered actions sorted by name.
+func (ac *RBACService) GetActions(ctx context.Context, query GetActionsQuery) []ActionDefinition {
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+
+	result := make([]ActionDefinition, 0, len(actions))
+	for _, def := range actions {
+		if strings.HasPrefix(def.Action, query.Prefix) {
+			result = append(result, def)
+		}
+	}
+	sort.Slice(result, func(i, j int) bool { return result[i].Action < result[j].Action })
+
+	return result
+}
+
+// validateRegisteredAction checks that the action, or the wildcard pattern, matches at least one registered action.
+func validateRegisteredAction(action string) error {
+	actionsMu.RLock()
+	defer actionsMu.RUnlock()
+
+	if _, ok := actions[action]; ok {
+		return nil
+	}
+	for registered := range actions {
+		if matchPattern(action, registered) {
+			return nil
+		}
+	}
+
+	return fmt.Errorf("%w %q", ErrUnknownAction, action)
+}
@@ -0,0 +1,70 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidateRegisteredAction(t *testing.T) {
+	tests := []struct {
+		action string
+		valid  bool
+	}{
+		{action: "dashboards:read", valid: true},
+		{action: "dashboards:*", valid: true},
+		{action: "*", valid: true},
+		{action: "dashbords:read", valid: false},
+		{action: "dashboards:readd", valid: false},
+		{action: "unknown:*", valid: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.action, func(t *testing.T) {
+			err := validateRegisteredAction(tc.action)
+			if tc.valid {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, ErrUnknownAction)
+		})
+	}
+}
+
+func TestGetActions(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	defs := ac.GetActions(context.Background(), GetActionsQuery{Prefix: "silences:"})
+	require.Len(t, defs, 2)
+	assert.Equal(t, ActionSilencesCreate, defs[0].Action)
+	assert.Equal(t, ActionSilencesRead, defs[1].Action)
+
+	all := ac.GetActions(context.Background(), GetActionsQuery{})
+	assert.Greater(t, len(all), len(defs))
+}
+
+func TestCreatePermission_UnknownAction(t *testing.T) {
+	ac := setupTestEnv(t)
+	policy := createPolicy(t, ac, 1, "editor")
+
+	_, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "dashbords:read", Scope: "dashboards:*"})
+	require.ErrorIs(t, err, ErrUnknownAction)
+
+	permission, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, err)
+
+	_, err = ac.UpdatePermission(context.Background(), UpdatePermissionCommand{OrgID: 1, ID: permission.ID, Action: "dashboards:reed", Scope: "dashboards:*"})
+	require.ErrorIs(t, err, ErrUnknownAction)
+}
+
+func TestGetReference(t *testing.T) {
+	RegisterScopes(ScopeDefinition{Scope: "test-reference:id:<id>", Description: "A test resource"})
+
+	ref := GetReference()
+	assert.Contains(t, ref.Scopes, ScopeDefinition{Scope: "test-reference:id:<id>", Description: "A test resource"})
+	assert.Contains(t, ref.FixedPolicies, suspendedPolicy)
+	assert.True(t, sort.SliceIsSorted(ref.Actions, func(i, j int) bool { return ref.Actions[i].Action < ref.Actions[j].Action }))
+}
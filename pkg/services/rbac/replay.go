@@ -0,0 +1,250 @@
+package rbac
+
+import (
+	"bufio"
+	"context"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// ErrInvalidDecisionLog is an error for when a decision log entry can't be parsed.
+var ErrInvalidDecisionLog = errors.New("invalid decision log")
+
+// DecisionLogEntry is an access check captured for replay, decision logs hold one JSON entry per line.
+type DecisionLogEntry struct {
+	OrgID          int64  `json:"orgId"`
+	UserID         int64  `json:"userId"`
+	Role           string `json:"role"`
+	IsGrafanaAdmin bool   `json:"isGrafanaAdmin"`
+	Action         string `json:"action"`
+	Scope          string `json:"scope"`
+	// Allowed is the captured decision, entries without one are only replayed against a draft.
+	Allowed *bool `json:"allowed,omitempty"`
+}
+
+// ReplayDecisionLogCommand is the command for replaying a decision log.
+type ReplayDecisionLogCommand struct {
+	Entries []DecisionLogEntry
+	// Draft replaces the permissions of the policies with the same uid. When empty, the entries'
+	// captured decisions are compared with the current policies.
+	Draft []PolicyDTO
+}
+
+// DecisionChange is a replayed access check whose decision changed.
+type DecisionChange struct {
+	Entry  DecisionLogEntry `json:"entry"`
+	Before bool             `json:"before"`
+	After  bool             `json:"after"`
+}
+
+// ReplayReport summarizes the decision changes of a replay.
+type ReplayReport struct {
+	Total   int              `json:"total"`
+	Changes []DecisionChange `json:"changes"`
+	// Granted and Revoked count the changes from denied to allowed and from allowed to denied.
+	Granted int `json:"granted"`
+	Revoked int `json:"revoked"`
+}
+
+// ChangedPercent returns the percentage of access checks whose decision changed.
+func (r ReplayReport) ChangedPercent() float64 {
+	return percent(len(r.Changes), r.Total)
+}
+
+// GrantedPercent returns the percentage of access checks changed from denied to allowed.
+func (r ReplayReport) GrantedPercent() float64 {
+	return percent(r.Granted, r.Total)
+}
+
+// RevokedPercent returns the percentage of access checks changed from allowed to denied.
+func (r ReplayReport) RevokedPercent() float64 {
+	return percent(r.Revoked, r.Total)
+}
+
+func percent(n, total int) float64 {
+	if total == 0 {
+		return 0
+	}
+	return 100 * float64(n) / float64(total)
+}
+
+// ReadDecisionLog parses a decision log, blank lines are skipped.
+func ReadDecisionLog(r io.Reader) ([]DecisionLogEntry, error) {
+	var entries []DecisionLogEntry
+	scanner := bufio.NewScanner(r)
+	for line := 1; scanner.Scan(); line++ {
+		text := strings.TrimSpace(scanner.Text())
+		if text == "" {
+			continue
+		}
+		var entry DecisionLogEntry
+		if err := json.Unmarshal([]byte(text), &entry); err != nil {
+			return nil, fmt.Errorf("%w entry on line %d: %s", ErrInvalidDecisionLog, line, err)
+		}
+		entries = append(entries, entry)
+	}
+
+	return entries, scanner.Err()
+}
+
+// ReplayDecisionLog evaluates the entries of a decision log against the current policies, or
+// against a draft of them, and reports the decisions that changed. Only policies are replayed,
+// decision middlewares and conditions on request attributes that aren't captured don't apply.
+func (ac *RBACService) ReplayDecisionLog(ctx context.Context, cmd ReplayDecisionLogCommand) (*ReplayReport, error) {
+	draft := make(map[string]PolicyDTO, len(cmd.Draft))
+	for _, policy := range cmd.Draft {
+		draft[policy.UID] = policy
+	}
+
+	type replayedUser struct {
+		user    *models.SignedInUser
+		current []Permission
+		draft   []Permission
+	}
+	users := map[string]*replayedUser{}
+
+	report := &ReplayReport{Changes: []DecisionChange{}}
+	for _, entry := range cmd.Entries {
+		key := fmt.Sprintf("%d-%d-%s-%t", entry.OrgID, entry.UserID, entry.Role, entry.IsGrafanaAdmin)
+		u, ok := users[key]
+		if !ok {
+			var err error
+			u = &replayedUser{user: &models.SignedInUser{
+				OrgId: entry.OrgID, UserId: entry.UserID, OrgRole: models.RoleType(entry.Role), IsGrafanaAdmin: entry.IsGrafanaAdmin,
+			}}
+			if u.current, u.draft, err = ac.getReplayPermissions(ctx, u.user, draft); err != nil {
+				return nil, err
+			}
+			users[key] = u
+		}
+
+		env := ac.environment(ctx, u.user)
+		evaluator := Perm(entry.Action, entry.Scope)
+		before := evaluator.Evaluate(u.current, env)
+		after := before
+		if len(draft) > 0 {
+			after = evaluator.Evaluate(u.draft, env)
+		} else if entry.Allowed != nil {
+			before = *entry.Allowed
+		}
+
+		report.Total++
+		if before == after {
+			continue
+		}
+		report.Changes = append(report.Changes, DecisionChange{Entry: entry, Before: before, After: after})
+		if after {
+			report.Granted++
+		} else {
+			report.Revoked++
+		}
+	}
+
+	return report, nil
+}
+
+// getReplayPermissions returns the resolved permissions of the user under the current policies
+// and with the permissions of the user's policies found in the draft replaced.
+func (ac *RBACService) getReplayPermissions(ctx context.Context, user *models.SignedInUser, draft map[string]PolicyDTO) ([]Permission, []Permission, error) {
+	query := GetUserPermissionsQuery{OrgID: user.OrgId, UserID: user.UserId, Roles: BuiltinRoles(user)}
+	permissions, err := ac.GetUserPermissions(ctx, query)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	var drafted []Permission
+	if len(draft) > 0 {
+		policies, err := ac.getUserBoundPolicies(ctx, query)
+		if err != nil {
+			return nil, nil, err
+		}
+
+		replaced := map[int64]bool{}
+		now := time.Now()
+		for _, policy := range policies {
+			draftPolicy, ok := draft[policy.UID]
+			if !ok {
+				continue
+			}
+			replaced[policy.ID] = true
+			for _, p := range draftPolicy.Permissions {
+				if p.ExpiresAt != nil && !p.ExpiresAt.After(now) {
+					continue
+				}
+				p.PolicyID = policy.ID
+				p.Precedence = draftPolicy.Precedence
+				drafted = append(drafted, p)
+			}
+		}
+		for _, p := range permissions {
+			if !replaced[p.PolicyID] {
+				drafted = append(drafted, p)
+			}
+		}
+
+		if drafted, err = ac.resolvePermissions(ctx, user, drafted); err != nil {
+			return nil, nil, err
+		}
+	}
+
+	current, err := ac.resolvePermissions(ctx, user, permissions)
+	if err != nil {
+		return nil, nil, err
+	}
+
+	return current, drafted, nil
+}
+
+// getUserBoundPolicies returns the policies bound to the user, to the user's teams and to the user's
+// builtin roles, or only those bound to the user for service accounts.
+func (ac *RBACService) getUserBoundPolicies(ctx context.Context, query GetUserPermissionsQuery) ([]Policy, error) {
+	var policies []Policy
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		serviceAccount, err := isServiceAccount(sess, query.OrgID, query.UserID)
+		if err != nil {
+			return err
+		}
+
+		q := `SELECT policy.* FROM policy
+			INNER JOIN user_policy ON policy.id = user_policy.policy_id
+			WHERE user_policy.org_id = ? AND user_policy.user_id = ?
+			AND (user_policy.expires_at IS NULL OR user_policy.expires_at > ?)`
+		now := time.Now()
+		args := []interface{}{query.OrgID, query.UserID, now}
+		if serviceAccount {
+			return sess.SQL(q, args...).Find(&policies)
+		}
+
+		q += `
+			UNION
+			SELECT policy.* FROM policy
+			INNER JOIN team_policy ON policy.id = team_policy.policy_id
+			INNER JOIN team_member ON team_policy.team_id = team_member.team_id
+			WHERE team_policy.org_id = ? AND team_member.user_id = ?
+			AND (team_policy.expires_at IS NULL OR team_policy.expires_at > ?)`
+		args = append(args, query.OrgID, query.UserID, now)
+
+		if len(query.Roles) > 0 {
+			q += `
+			UNION
+			SELECT policy.* FROM policy
+			INNER JOIN builtin_role_policy ON policy.id = builtin_role_policy.policy_id
+			WHERE builtin_role_policy.org_id = ? AND builtin_role_policy.role IN (?` + strings.Repeat(",?", len(query.Roles)-1) + `)`
+			args = append(args, query.OrgID)
+			for _, role := range query.Roles {
+				args = append(args, role)
+			}
+		}
+
+		return sess.SQL(q, args...).Find(&policies)
+	})
+
+	return policies, err
+}
@@ -0,0 +1,72 @@
+package rbac
+
+import (
+	"context"
+	"strings"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestReadDecisionLog(t *testing.T) {
+	entries, err := ReadDecisionLog(strings.NewReader(`{"orgId":1,"userId":181,"role":"Viewer","action":"dashboards:read","scope":"dashboards:uid:abc","allowed":true}
+
+{"orgId":1,"userId":182,"action":"teams:read","scope":"teams:id:1"}
+`))
+	require.NoError(t, err)
+	require.Len(t, entries, 2)
+	assert.Equal(t, "Viewer", entries[0].Role)
+	require.NotNil(t, entries[0].Allowed)
+	assert.True(t, *entries[0].Allowed)
+	assert.Nil(t, entries[1].Allowed)
+
+	_, err = ReadDecisionLog(strings.NewReader("{\n"))
+	require.Error(t, err)
+	assert.Contains(t, err.Error(), "line 1")
+}
+
+func TestReplayDecisionLog(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "dashboards", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 181, PolicyID: policy.ID}))
+
+	allowed, denied := true, false
+	entries := []DecisionLogEntry{
+		{OrgID: 1, UserID: 181, Action: "dashboards:read", Scope: "dashboards:uid:abc", Allowed: &allowed},
+		{OrgID: 1, UserID: 181, Action: "dashboards:read", Scope: "dashboards:uid:def", Allowed: &denied},
+		{OrgID: 1, UserID: 181, Action: "dashboards:write", Scope: "dashboards:uid:abc", Allowed: &denied},
+		{OrgID: 1, UserID: 182, Action: "dashboards:read", Scope: "dashboards:uid:abc", Allowed: &denied},
+	}
+
+	t.Run("Replaying against the current policies should report the changes since the capture", func(t *testing.T) {
+		report, err := ac.ReplayDecisionLog(context.Background(), ReplayDecisionLogCommand{Entries: entries})
+		require.NoError(t, err)
+		assert.Equal(t, 4, report.Total)
+		require.Len(t, report.Changes, 1)
+		assert.Equal(t, "dashboards:uid:def", report.Changes[0].Entry.Scope)
+		assert.Equal(t, 1, report.Granted)
+		assert.Equal(t, 25.0, report.ChangedPercent())
+	})
+
+	t.Run("Replaying against a draft should report the changes it introduces", func(t *testing.T) {
+		draft := []PolicyDTO{{UID: policy.UID, Permissions: []Permission{
+			{Action: "dashboards:read", Scope: "dashboards:uid:def"},
+			{Action: "dashboards:write", Scope: "dashboards:uid:abc"},
+		}}}
+		report, err := ac.ReplayDecisionLog(context.Background(), ReplayDecisionLogCommand{Entries: entries, Draft: draft})
+		require.NoError(t, err)
+		assert.Equal(t, 4, report.Total)
+		assert.Equal(t, 1, report.Granted)
+		assert.Equal(t, 1, report.Revoked)
+		assert.Equal(t, 50.0, report.ChangedPercent())
+		assert.Equal(t, 25.0, report.RevokedPercent())
+
+		// The draft doesn't change what the stored policies grant.
+		ok, err := ac.evaluate(context.Background(), &models.SignedInUser{OrgId: 1, UserId: 181}, accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:abc"})
+		require.NoError(t, err)
+		assert.True(t, ok)
+	})
+}
@@ -0,0 +1,99 @@
+package rbac
+
+import (
+	"fmt"
+	"strings"
+)
+
+// Resource modes tell what happens when none of a user's permissions matches an access check
+// on a resource type.
+const (
+	// ResourceModeStrict denies the access, it's the mode of every resource type by default.
+	ResourceModeStrict = "strict"
+	// ResourceModeCompat defers to the legacy organization role based access control, so that
+	// resource types can be moved to RBAC one at a time.
+	ResourceModeCompat = "compat"
+	// ResourceModeShadow lets the legacy organization role based access control decide every access
+	// check and compares its decisions with RBAC's, so that gaps in the policies can be found before
+	// enforcing them, see CompareDecision.
+	ResourceModeShadow = "shadow"
+)
+
+// loadResourceModes reads the mode of each resource type from the rbac.resource_modes section,
+// e.g. datasources = compat.
+func (ac *RBACService) loadResourceModes() error {
+	ac.compatResourceTypes = map[string]bool{}
+	ac.shadowResourceTypes = map[string]bool{}
+	for _, key := range ac.Cfg.Raw.Section("rbac.resource_modes").Keys() {
+		switch mode := strings.TrimSpace(key.String()); mode {
+		case ResourceModeStrict:
+		case ResourceModeCompat:
+			ac.compatResourceTypes[key.Name()] = true
+		case ResourceModeShadow:
+			ac.shadowResourceTypes[key.Name()] = true
+		default:
+			return fmt.Errorf("invalid rbac.resource_modes %s mode %q, expected %s, %s or %s", key.Name(), mode,
+				ResourceModeStrict, ResourceModeCompat, ResourceModeShadow)
+		}
+	}
+
+	return nil
+}
+
+// ResourceMode returns the mode of a resource type, e.g. dashboards.
+func (ac *RBACService) ResourceMode(resourceType string) string {
+	if ac.compatResourceTypes[resourceType] {
+		return ResourceModeCompat
+	}
+	if ac.shadowResourceTypes[resourceType] {
+		return ResourceModeShadow
+	}
+	return ResourceModeStrict
+}
+
+// resourceType returns the resource type of an action, the part before the first colon.
+func resourceType(action string) string {
+	return strings.SplitN(action, ":", 2)[0]
+}
+
+// fallsBackToLegacy returns true if a denied access check should defer to legacy access control:
+// every action it involves is on a resource type in compat mode and none of the permissions
+// matches any of its actions and scopes, conditions aside.
+func (ac *RBACService) fallsBackToLegacy(evaluator Evaluator, permissions []Permission) bool {
+	required := evaluatorPermissions(evaluator)
+	if len(required) == 0 || len(ac.compatResourceTypes) == 0 {
+		return false
+	}
+
+	for _, r := range required {
+		if !ac.compatResourceTypes[resourceType(r.Action)] {
+			return false
+		}
+	}
+	for _, p := range permissions {
+		for _, r := range required {
+			if matchPattern(p.Action, r.Action) && matchPattern(p.Scope, r.Scope) {
+				return false
+			}
+		}
+	}
+
+	return true
+}
+
+// isShadowed returns true if legacy access control decides the access check because every action
+// it involves is on a resource type in shadow mode.
+func (ac *RBACService) isShadowed(evaluator Evaluator) bool {
+	required := evaluatorPermissions(evaluator)
+	if len(required) == 0 || len(ac.shadowResourceTypes) == 0 {
+		return false
+	}
+
+	for _, r := range required {
+		if !ac.shadowResourceTypes[resourceType(r.Action)] {
+			return false
+		}
+	}
+
+	return true
+}
@@ -0,0 +1,59 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+)
+
+func TestDecide_ResourceModes(t *testing.T) {
+	ac := setupTestEnv(t)
+	_, err := ac.Cfg.Raw.Section("rbac.resource_modes").NewKey("datasources", ResourceModeCompat)
+	require.NoError(t, err)
+	_, err = ac.Cfg.Raw.Section("rbac.resource_modes").NewKey("dashboards", ResourceModeStrict)
+	require.NoError(t, err)
+	require.NoError(t, ac.loadResourceModes())
+	assert.Equal(t, ResourceModeCompat, ac.ResourceMode("datasources"))
+	assert.Equal(t, ResourceModeStrict, ac.ResourceMode("teams"))
+
+	team := createTeam(t, 1, "analysts")
+	addTeamMember(t, 1, team.Id, 281)
+	policy := createPolicy(t, ac, 1, "prometheus",
+		CreatePermissionCommand{Action: ActionDatasourcesQuery, Scope: "datasources:uid:prom", Kind: PermissionKindDeny},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+	user := &models.SignedInUser{OrgId: 1, UserId: 281}
+
+	testCases := []struct {
+		desc             string
+		evaluator        Evaluator
+		expectedFallback bool
+	}{
+		{"Unmatched access check on a compat resource type should fall back", Perm(ActionDatasourcesRead, "datasources:uid:loki"), true},
+		{"Denial of a compat resource type should not fall back", Perm(ActionDatasourcesQuery, "datasources:uid:prom"), false},
+		{"Unmatched access check on a strict resource type should not fall back", Perm(ActionDashboardsRead, "dashboards:uid:abc"), false},
+		{"Access check involving a strict resource type should not fall back",
+			All(Perm(ActionDatasourcesRead, "datasources:uid:loki"), Perm(ActionDashboardsRead, "dashboards:uid:abc")), false},
+	}
+	for _, tc := range testCases {
+		t.Run(tc.desc, func(t *testing.T) {
+			decision, err := ac.Decide(context.Background(), user, tc.evaluator)
+			require.NoError(t, err)
+			assert.False(t, decision.Allowed)
+			assert.Equal(t, tc.expectedFallback, decision.LegacyFallback)
+		})
+	}
+
+	t.Run("Invalid modes should be rejected", func(t *testing.T) {
+		cfg := setting.NewCfg()
+		_, err := cfg.Raw.Section("rbac.resource_modes").NewKey("datasources", "lenient")
+		require.NoError(t, err)
+		ac := &RBACService{Cfg: cfg}
+		require.Error(t, ac.loadResourceModes())
+	})
+}
@@ -0,0 +1,145 @@
+package rbac
+
+import (
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// SetResourcePermission grants a team or a user a set of actions on a single resource. The grants are
+// kept in a managed policy per resource and assignee, created, updated and deleted as the actions
+// change, so that sharing a resource doesn't require crafting policies by hand.
+func (ac *RBACService) SetResourcePermission(ctx context.Context, cmd SetResourcePermissionCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionBindingExpiry); err != nil {
+		return err
+	}
+
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		return ac.setResourcePermission(sess, cmd)
+	})
+	if err != nil {
+		return err
+	}
+
+	// The managed policy may have been created or deleted, its id isn't known here.
+	ac.publishPolicyChanged(cmd.OrgID, 0, PolicyChangedResourcePermissions)
+
+	return nil
+}
+
+// setResourcePermission is SetResourcePermission within an existing transaction.
+func (ac *RBACService) setResourcePermission(sess *sqlstore.DBSession, cmd SetResourcePermissionCommand) error {
+	if (cmd.TeamID > 0) == (cmd.UserID > 0) {
+		return ErrInvalidAssignee
+	}
+	scope, err := normalizeScope(cmd.Scope)
+	if err != nil {
+		return err
+	}
+	for _, action := range cmd.Actions {
+		if err := validatePermission(action, scope); err != nil {
+			return err
+		}
+		if err := validateRegisteredAction(action); err != nil {
+			return err
+		}
+	}
+
+	assignee := fmt.Sprintf("teams:%d", cmd.TeamID)
+	if cmd.UserID > 0 {
+		assignee = fmt.Sprintf("users:%d", cmd.UserID)
+	}
+	uid := managedResourcePolicyUID(assignee, scope)
+	policy, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, UID: uid})
+	if err != nil && !errors.Is(err, ErrPolicyNotFound) {
+		return err
+	}
+
+	if policy != nil {
+		if _, err := sess.Exec("DELETE FROM permission WHERE policy_id = ?", policy.ID); err != nil {
+			return err
+		}
+		if len(cmd.Actions) == 0 {
+			if _, err := sess.Exec("DELETE FROM team_policy WHERE policy_id = ?", policy.ID); err != nil {
+				return err
+			}
+			if _, err := sess.Exec("DELETE FROM user_policy WHERE policy_id = ?", policy.ID); err != nil {
+				return err
+			}
+			_, err := sess.Exec("DELETE FROM policy WHERE id = ?", policy.ID)
+			return err
+		}
+	} else {
+		if len(cmd.Actions) == 0 {
+			return nil
+		}
+		if policy, err = ac.createManagedResourcePolicy(sess, cmd, uid, assignee, scope); err != nil {
+			return err
+		}
+	}
+
+	if err := ac.checkPermissionLimit(sess, policy.ID, len(cmd.Actions)); err != nil {
+		return err
+	}
+
+	now := time.Now()
+	for _, action := range cmd.Actions {
+		permission := &Permission{
+			PolicyID: policy.ID,
+			Action:   action,
+			Created:  now,
+			Updated:  now,
+		}
+		permission.setScope(scope)
+		if _, err := sess.Table("permission").Insert(permission); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				continue
+			}
+			return err
+		}
+	}
+
+	ac.log.Debug("Set resource permission", "orgId", cmd.OrgID, "scope", scope, "assignee", assignee, "actions", cmd.Actions)
+
+	return nil
+}
+
+// createManagedResourcePolicy creates the managed policy of a resource and assignee and binds it to the assignee.
+func (ac *RBACService) createManagedResourcePolicy(sess *sqlstore.DBSession, cmd SetResourcePermissionCommand, uid, assignee, scope string) (*Policy, error) {
+	now := time.Now()
+	policy := &Policy{
+		OrgID:       cmd.OrgID,
+		UID:         uid,
+		Name:        fmt.Sprintf("managed:%s:%s", assignee, scope),
+		Description: "Permissions on a single resource. Managed by Grafana.",
+		Enabled:     true,
+		Created:     now,
+		Updated:     now,
+	}
+	if _, err := sess.Table("policy").Insert(policy); err != nil {
+		return nil, err
+	}
+
+	if cmd.UserID > 0 {
+		return policy, ac.addUserPolicy(sess, &UserPolicy{OrgID: cmd.OrgID, PolicyID: policy.ID, UserID: cmd.UserID})
+	}
+
+	teamPolicy := &TeamPolicy{OrgID: cmd.OrgID, PolicyID: policy.ID, TeamID: cmd.TeamID, Created: now}
+	if _, err := sess.Table("team_policy").Insert(teamPolicy); err != nil {
+		return nil, err
+	}
+
+	return policy, nil
+}
+
+// managedResourcePolicyUID derives the uid of the managed policy of a resource and assignee. Scopes
+// can be longer than uids, so the uid is a digest of them.
+func managedResourcePolicyUID(assignee, scope string) string {
+	sum := sha256.Sum256([]byte(assignee + "/" + scope))
+	return managedPolicyUIDPrefix + hex.EncodeToString(sum[:16])
+}
@@ -0,0 +1,71 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestSetResourcePermission(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "payments")
+	addTeamMember(t, 1, team.Id, 121)
+	user := &models.SignedInUser{OrgId: 1, UserId: 121}
+	check := func(evaluator Evaluator) bool {
+		ok, err := ac.Evaluate(context.Background(), user, evaluator)
+		require.NoError(t, err)
+		return ok
+	}
+	set := func(actions ...string) {
+		require.NoError(t, ac.SetResourcePermission(context.Background(), SetResourcePermissionCommand{
+			OrgID: 1, Scope: "dashboards:uid:payments", TeamID: team.Id, Actions: actions,
+		}))
+	}
+
+	set(ActionDashboardsRead)
+	assert.True(t, check(Perm(ActionDashboardsRead, "dashboards:uid:payments")))
+	assert.False(t, check(Perm(ActionDashboardsWrite, "dashboards:uid:payments")))
+	assert.False(t, check(Perm(ActionDashboardsRead, "dashboards:uid:other")))
+
+	set(ActionDashboardsRead, ActionDashboardsWrite)
+	assert.True(t, check(Perm(ActionDashboardsWrite, "dashboards:uid:payments")))
+
+	policies, err := ac.GetTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: 1, TeamID: team.Id})
+	require.NoError(t, err)
+	require.Len(t, policies, 1, "the managed policy should be reused")
+
+	set()
+	assert.False(t, check(Perm(ActionDashboardsRead, "dashboards:uid:payments")))
+	policies, err = ac.GetTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: 1, TeamID: team.Id})
+	require.NoError(t, err)
+	assert.Empty(t, policies)
+
+	t.Run("Resource permissions can be assigned to a user", func(t *testing.T) {
+		err := ac.SetResourcePermission(context.Background(), SetResourcePermissionCommand{
+			OrgID: 1, Scope: "dashboards:uid:payments", UserID: 122, Actions: []string{ActionDashboardsRead},
+		})
+		require.NoError(t, err)
+
+		ok, err := ac.Evaluate(context.Background(), &models.SignedInUser{OrgId: 1, UserId: 122}, Perm(ActionDashboardsRead, "dashboards:uid:payments"))
+		require.NoError(t, err)
+		assert.True(t, ok)
+		assert.False(t, check(Perm(ActionDashboardsRead, "dashboards:uid:payments")), "the team shouldn't get the user's grants")
+	})
+
+	t.Run("Resource permissions need either a team or a user", func(t *testing.T) {
+		err := ac.SetResourcePermission(context.Background(), SetResourcePermissionCommand{
+			OrgID: 1, Scope: "dashboards:uid:payments", Actions: []string{ActionDashboardsRead},
+		})
+		require.ErrorIs(t, err, ErrInvalidAssignee)
+
+		err = ac.SetResourcePermission(context.Background(), SetResourcePermissionCommand{
+			OrgID: 1, Scope: "dashboards:uid:payments", TeamID: team.Id, UserID: 122, Actions: []string{ActionDashboardsRead},
+		})
+		require.ErrorIs(t, err, ErrInvalidAssignee)
+	})
+}
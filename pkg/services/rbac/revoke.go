@@ -0,0 +1,49 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// RevokeAllUserAccess revokes the access of a user in an organization in one operation,
+// for offboarding. The policies bound directly to the user are unbound. Policies bound to the
+// user's teams aren't touched since other members depend on them, they are returned in the
+// result so that the user can be removed from the teams. The cached permissions of the user are
+// invalidated even when no policy was bound directly, so that the revocation takes effect at once.
+func (ac *RBACService) RevokeAllUserAccess(ctx context.Context, cmd RevokeAllUserAccessCommand) (*RevokeAllUserAccessResult, error) {
+	result := &RevokeAllUserAccessResult{TeamPolicies: make([]*UserTeamPolicy, 0)}
+	var removed []UserPolicy
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if err := sess.Table("user_policy").Where("org_id = ? AND user_id = ?", cmd.OrgID, cmd.UserID).Find(&removed); err != nil {
+			return err
+		}
+		if _, err := sess.Exec("DELETE FROM user_policy WHERE org_id = ? AND user_id = ?", cmd.OrgID, cmd.UserID); err != nil {
+			return err
+		}
+		result.RemovedUserPolicies = int64(len(removed))
+
+		q := `SELECT team.id AS team_id, team.name AS team_name, policy.id AS policy_id, policy.name AS policy_name
+			FROM team_policy
+			INNER JOIN team ON team.id = team_policy.team_id
+			INNER JOIN team_member ON team_member.team_id = team_policy.team_id
+			INNER JOIN policy ON policy.id = team_policy.policy_id
+			WHERE team_policy.org_id = ? AND team_member.user_id = ?
+			ORDER BY team.name ASC, policy.name ASC`
+		return sess.SQL(q, cmd.OrgID, cmd.UserID).Find(&result.TeamPolicies)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.log.Info("Revoked user access", "orgId", cmd.OrgID, "userId", cmd.UserID,
+		"removedUserPolicies", result.RemovedUserPolicies, "flaggedTeamPolicies", len(result.TeamPolicies))
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonAccessRevoked)
+	for _, up := range removed {
+		changes.userUnbound(up.OrgID, up.PolicyID, up.UserID)
+	}
+	ac.publishBindingChanges(changes)
+	ac.publishPermissionsChanged(cmd.OrgID, cmd.UserID, PermissionsChangedAccessRevoked)
+
+	return result, nil
+}
@@ -0,0 +1,47 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+)
+
+func TestRevokeAllUserAccess(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	var revoked []int64
+	bus.AddEventListener(func(e *events.UserPermissionsChanged) error {
+		if e.Reason == PermissionsChangedAccessRevoked {
+			revoked = append(revoked, e.UserID)
+		}
+		return nil
+	})
+
+	team := createTeam(t, 1, "leavers")
+	addTeamMember(t, 1, team.Id, 41)
+	policy := createPolicy(t, ac, 1, "viewer", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+	direct := createPolicy(t, ac, 1, "contractor", CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 41, PolicyID: direct.ID}))
+
+	result, err := ac.RevokeAllUserAccess(context.Background(), RevokeAllUserAccessCommand{OrgID: 1, UserID: 41})
+	require.NoError(t, err)
+	assert.Equal(t, int64(1), result.RemovedUserPolicies)
+	policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: 41})
+	require.NoError(t, err)
+	assert.Empty(t, policies)
+	require.Len(t, result.TeamPolicies, 1)
+	assert.Equal(t, &UserTeamPolicy{TeamID: team.Id, TeamName: "leavers", PolicyID: policy.ID, PolicyName: "viewer"}, result.TeamPolicies[0])
+
+	result, err = ac.RevokeAllUserAccess(context.Background(), RevokeAllUserAccessCommand{OrgID: 1, UserID: 42})
+	require.NoError(t, err)
+	assert.Empty(t, result.TeamPolicies)
+
+	// The permissions are invalidated even when no policy was bound directly.
+	assert.Equal(t, []int64{41, 42}, revoked)
+}
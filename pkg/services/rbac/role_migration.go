@@ -0,0 +1,200 @@
+package rbac
+
+import (
+	"context"
+	"encoding/json"
+	"fmt"
+	"sort"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// MigrateOrgRoles binds the policies mapped to each organization role to the users holding it and,
+// when a target role is set, replaces their role, in a single transaction. The previous roles and
+// the added bindings are saved as a snapshot before anything is changed, so that the migration can
+// be undone with RollbackRoleMigration.
+func (ac *RBACService) MigrateOrgRoles(ctx context.Context, cmd MigrateOrgRolesCommand) (*RoleMigration, error) {
+	if err := ac.checkSchemaVersion(schemaVersionRoleMigration); err != nil {
+		return nil, err
+	}
+	if cmd.TargetRole != "" && !cmd.TargetRole.IsValid() {
+		return nil, fmt.Errorf("invalid target role %q", cmd.TargetRole)
+	}
+
+	migration := &RoleMigration{OrgID: cmd.OrgID, PerformedBy: cmd.PerformedBy, Created: time.Now(), Users: []RoleMigrationUser{}}
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonRoleMigration)
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		roles := make([]models.RoleType, 0, len(cmd.Policies))
+		for role, policyIDs := range cmd.Policies {
+			if !role.IsValid() {
+				return fmt.Errorf("invalid organization role %q", role)
+			}
+			for _, policyID := range policyIDs {
+				if _, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: policyID}); err != nil {
+					return err
+				}
+			}
+			roles = append(roles, role)
+		}
+		sort.Slice(roles, func(i, j int) bool { return roles[i] < roles[j] })
+
+		for _, role := range roles {
+			users, err := ac.planRoleMigration(sess, cmd.OrgID, role, cmd.Policies[role])
+			if err != nil {
+				return err
+			}
+			migration.Users = append(migration.Users, users...)
+		}
+
+		snapshot, err := json.Marshal(migration.Users)
+		if err != nil {
+			return err
+		}
+		migration.Snapshot = string(snapshot)
+		if _, err := sess.Table("role_migration").Insert(migration); err != nil {
+			return err
+		}
+
+		expiresAt, err := ac.userPolicyExpiry(sess, cmd.OrgID, nil)
+		if err != nil {
+			return err
+		}
+		for _, user := range migration.Users {
+			for _, policyID := range user.AddedPolicyIDs {
+				if err := ac.addUserPolicy(sess, &UserPolicy{
+					OrgID: cmd.OrgID, PolicyID: policyID, UserID: user.UserID, ExpiresAt: expiresAt, GrantedBy: cmd.PerformedBy,
+				}); err != nil {
+					return err
+				}
+				changes.userBound(cmd.OrgID, policyID, user.UserID)
+			}
+			if cmd.TargetRole != "" && cmd.TargetRole != user.PreviousRole {
+				if err := setOrgUserRole(sess, cmd.OrgID, user.UserID, cmd.TargetRole); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.log.Info("Migrated organization roles to policies", "orgId", cmd.OrgID, "migrationId", migration.ID,
+		"performedBy", cmd.PerformedBy, "users", len(migration.Users), "targetRole", cmd.TargetRole)
+	ac.publishRoleMigration(migration, cmd.PerformedBy, false)
+	ac.publishBindingChanges(changes)
+
+	return migration, nil
+}
+
+// planRoleMigration returns the users holding the role along with the mapped policies they aren't bound to yet.
+func (ac *RBACService) planRoleMigration(sess *sqlstore.DBSession, orgID int64, role models.RoleType, policyIDs []int64) ([]RoleMigrationUser, error) {
+	var userIDs []int64
+	if err := sess.Table("org_user").Where("org_id = ? AND role = ?", orgID, role).Asc("user_id").Cols("user_id").Find(&userIDs); err != nil {
+		return nil, err
+	}
+
+	users := make([]RoleMigrationUser, 0, len(userIDs))
+	for _, userID := range userIDs {
+		var bound []int64
+		if err := sess.Table("user_policy").Where("org_id = ? AND user_id = ?", orgID, userID).Cols("policy_id").Find(&bound); err != nil {
+			return nil, err
+		}
+		isBound := make(map[int64]bool, len(bound))
+		for _, policyID := range bound {
+			isBound[policyID] = true
+		}
+
+		user := RoleMigrationUser{UserID: userID, PreviousRole: role, AddedPolicyIDs: []int64{}}
+		for _, policyID := range policyIDs {
+			if !isBound[policyID] {
+				isBound[policyID] = true
+				user.AddedPolicyIDs = append(user.AddedPolicyIDs, policyID)
+			}
+		}
+		users = append(users, user)
+	}
+
+	return users, nil
+}
+
+// RollbackRoleMigration restores the roles the users of a role migration held before it and unbinds
+// the policies it bound to them. Changes made to the users since are overwritten.
+func (ac *RBACService) RollbackRoleMigration(ctx context.Context, cmd RollbackRoleMigrationCommand) (*RoleMigration, error) {
+	migration := &RoleMigration{}
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonRoleMigrationRollback)
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		exists, err := sess.Table("role_migration").Where("org_id = ? AND id = ?", cmd.OrgID, cmd.ID).Get(migration)
+		if err != nil {
+			return err
+		}
+		if !exists {
+			return ErrRoleMigrationNotFound
+		}
+		if migration.RolledBack != nil {
+			return ErrRoleMigrationRolledBack
+		}
+		if err := json.Unmarshal([]byte(migration.Snapshot), &migration.Users); err != nil {
+			return fmt.Errorf("invalid snapshot of role migration %d: %w", migration.ID, err)
+		}
+
+		for _, user := range migration.Users {
+			for _, policyID := range user.AddedPolicyIDs {
+				result, err := sess.Exec("DELETE FROM user_policy WHERE org_id = ? AND user_id = ? AND policy_id = ?",
+					cmd.OrgID, user.UserID, policyID)
+				if err != nil {
+					return err
+				}
+				// The binding may have been removed since.
+				if rowsAffected, err := result.RowsAffected(); err != nil {
+					return err
+				} else if rowsAffected > 0 {
+					changes.userUnbound(cmd.OrgID, policyID, user.UserID)
+				}
+			}
+			if err := setOrgUserRole(sess, cmd.OrgID, user.UserID, user.PreviousRole); err != nil {
+				return err
+			}
+		}
+
+		now := time.Now()
+		migration.RolledBack = &now
+		_, err = sess.Table("role_migration").ID(migration.ID).Cols("rolled_back").Update(migration)
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.log.Info("Rolled back organization role migration", "orgId", cmd.OrgID, "migrationId", migration.ID,
+		"performedBy", cmd.PerformedBy, "users", len(migration.Users))
+	ac.publishRoleMigration(migration, cmd.PerformedBy, true)
+	ac.publishBindingChanges(changes)
+
+	return migration, nil
+}
+
+// setOrgUserRole sets the organization role of a user, users who left the organization since are skipped.
+func setOrgUserRole(sess *sqlstore.DBSession, orgID, userID int64, role models.RoleType) error {
+	_, err := sess.Exec("UPDATE org_user SET role = ?, updated = ? WHERE org_id = ? AND user_id = ?", role, time.Now(), orgID, userID)
+	return err
+}
+
+func (ac *RBACService) publishRoleMigration(migration *RoleMigration, performedBy int64, rolledBack bool) {
+	e := &events.OrgRolesMigrated{
+		Timestamp:   time.Now(),
+		OrgID:       migration.OrgID,
+		MigrationID: migration.ID,
+		PerformedBy: performedBy,
+		Users:       len(migration.Users),
+		RolledBack:  rolledBack,
+	}
+	if err := bus.Publish(e); err != nil {
+		ac.log.Error("Failed to publish role migration event", "migrationId", migration.ID, "error", err)
+	}
+}
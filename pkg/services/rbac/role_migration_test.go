@@ -0,0 +1,107 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestMigrateOrgRoles(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	owner := &models.CreateUserCommand{Login: "owner", SkipOrgSetup: true}
+	require.NoError(t, sqlstore.CreateUser(context.Background(), owner))
+	org, err := ac.SQLStore.CreateOrgWithMember("migrating", owner.Result.Id)
+	require.NoError(t, err)
+
+	addMember := func(login string, role models.RoleType) int64 {
+		user := &models.CreateUserCommand{Login: login, SkipOrgSetup: true}
+		require.NoError(t, sqlstore.CreateUser(context.Background(), user))
+		require.NoError(t, sqlstore.AddOrgUser(&models.AddOrgUserCommand{OrgId: org.Id, UserId: user.Result.Id, Role: role}))
+		return user.Result.Id
+	}
+	editor := addMember("editor", models.ROLE_EDITOR)
+	viewer := addMember("viewer", models.ROLE_VIEWER)
+
+	editing := createPolicy(t, ac, org.Id, "editing", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+	reading := createPolicy(t, ac, org.Id, "reading", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: org.Id, UserID: editor, PolicyID: reading.ID}))
+
+	var published []*events.OrgRolesMigrated
+	bus.AddEventListener(func(e *events.OrgRolesMigrated) error {
+		published = append(published, e)
+		return nil
+	})
+
+	orgRole := func(userID int64) models.RoleType {
+		var role models.RoleType
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Table("org_user").Where("org_id = ? AND user_id = ?", org.Id, userID).Cols("role").Get(&role)
+			return err
+		})
+		require.NoError(t, err)
+		return role
+	}
+	userPolicyIDs := func(userID int64) []int64 {
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: org.Id, UserID: userID})
+		require.NoError(t, err)
+		ids := make([]int64, 0, len(policies))
+		for _, p := range policies {
+			ids = append(ids, p.ID)
+		}
+		return ids
+	}
+
+	t.Run("Unknown policies should abort the migration", func(t *testing.T) {
+		_, err := ac.MigrateOrgRoles(context.Background(), MigrateOrgRolesCommand{
+			OrgID: org.Id, Policies: map[models.RoleType][]int64{models.ROLE_EDITOR: {editing.ID, 0}},
+		})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+		assert.Equal(t, []int64{reading.ID}, userPolicyIDs(editor))
+	})
+
+	migration, err := ac.MigrateOrgRoles(context.Background(), MigrateOrgRolesCommand{
+		OrgID:       org.Id,
+		PerformedBy: owner.Result.Id,
+		Policies: map[models.RoleType][]int64{
+			models.ROLE_EDITOR: {editing.ID, reading.ID},
+			models.ROLE_VIEWER: {reading.ID},
+		},
+		TargetRole: models.ROLE_VIEWER,
+	})
+	require.NoError(t, err)
+	assert.Equal(t, []RoleMigrationUser{
+		{UserID: editor, PreviousRole: models.ROLE_EDITOR, AddedPolicyIDs: []int64{editing.ID}},
+		{UserID: viewer, PreviousRole: models.ROLE_VIEWER, AddedPolicyIDs: []int64{reading.ID}},
+	}, migration.Users)
+	assert.Equal(t, models.ROLE_VIEWER, orgRole(editor))
+	assert.Equal(t, models.ROLE_ADMIN, orgRole(owner.Result.Id), "unmapped roles should be left as they are")
+	assert.Equal(t, []int64{editing.ID, reading.ID}, userPolicyIDs(editor))
+	assert.Equal(t, []int64{reading.ID}, userPolicyIDs(viewer))
+
+	t.Run("Rolling back should restore roles and keep the bindings made before the migration", func(t *testing.T) {
+		_, err := ac.RollbackRoleMigration(context.Background(), RollbackRoleMigrationCommand{OrgID: org.Id, ID: migration.ID, PerformedBy: owner.Result.Id})
+		require.NoError(t, err)
+
+		assert.Equal(t, models.ROLE_EDITOR, orgRole(editor))
+		assert.Equal(t, []int64{reading.ID}, userPolicyIDs(editor))
+		assert.Empty(t, userPolicyIDs(viewer))
+
+		_, err = ac.RollbackRoleMigration(context.Background(), RollbackRoleMigrationCommand{OrgID: org.Id, ID: migration.ID})
+		require.ErrorIs(t, err, ErrRoleMigrationRolledBack)
+		_, err = ac.RollbackRoleMigration(context.Background(), RollbackRoleMigrationCommand{OrgID: org.Id + 1, ID: migration.ID})
+		require.ErrorIs(t, err, ErrRoleMigrationNotFound)
+	})
+
+	require.Len(t, published, 2)
+	assert.False(t, published[0].RolledBack)
+	assert.True(t, published[1].RolledBack)
+	assert.Equal(t, 2, published[1].Users)
+}
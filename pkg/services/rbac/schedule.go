@@ -0,0 +1,148 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+	"time"
+
+	"github.com/robfig/cron/v3"
+)
+
+const timeOfDayLayout = "15:04"
+
+// Schedule restricts a permission to recurring time windows. A window is either given
+// by a cron expression marking its start and a duration, or by a start and end time of
+// day, optionally limited to some days of the week. An end before the start spans midnight.
+type Schedule struct {
+	Cron     string `json:"cron,omitempty"`
+	Duration string `json:"duration,omitempty"`
+
+	Start string   `json:"start,omitempty"`
+	End   string   `json:"end,omitempty"`
+	Days  []string `json:"days,omitempty"`
+
+	// Timezone is the IANA name of the timezone the schedule is expressed in, defaults to UTC.
+	Timezone string `json:"timezone,omitempty"`
+}
+
+var weekdays = map[string]time.Weekday{
+	"sun": time.Sunday,
+	"mon": time.Monday,
+	"tue": time.Tuesday,
+	"wed": time.Wednesday,
+	"thu": time.Thursday,
+	"fri": time.Friday,
+	"sat": time.Saturday,
+}
+
+// validate checks that the schedule is well formed.
+func (s *Schedule) validate() error {
+	if _, err := s.location(); err != nil {
+		return err
+	}
+
+	if s.Cron != "" {
+		if s.Start != "" || s.End != "" || len(s.Days) > 0 {
+			return errors.New("cron schedules can't have start, end or days")
+		}
+		if _, err := cron.ParseStandard(s.Cron); err != nil {
+			return fmt.Errorf("invalid cron expression %q: %w", s.Cron, err)
+		}
+		d, err := time.ParseDuration(s.Duration)
+		if err != nil || d <= 0 {
+			return fmt.Errorf("cron schedules need a positive duration, got %q", s.Duration)
+		}
+		return nil
+	}
+
+	if _, err := time.Parse(timeOfDayLayout, s.Start); err != nil {
+		return fmt.Errorf("invalid start time %q, expected HH:MM", s.Start)
+	}
+	if _, err := time.Parse(timeOfDayLayout, s.End); err != nil {
+		return fmt.Errorf("invalid end time %q, expected HH:MM", s.End)
+	}
+	for _, day := range s.Days {
+		if _, ok := weekdays[strings.ToLower(day)]; !ok {
+			return fmt.Errorf("invalid day %q", day)
+		}
+	}
+
+	return nil
+}
+
+// isActive returns true if t falls within one of the schedule's windows.
+// Schedules that can't be parsed are never active.
+func (s *Schedule) isActive(t time.Time) bool {
+	loc, err := s.location()
+	if err != nil {
+		return false
+	}
+	t = t.In(loc)
+
+	if s.Cron != "" {
+		schedule, err := cron.ParseStandard(s.Cron)
+		if err != nil {
+			return false
+		}
+		d, err := time.ParseDuration(s.Duration)
+		if err != nil {
+			return false
+		}
+		// The window is active if it started less than its duration ago.
+		return !schedule.Next(t.Add(-d)).After(t)
+	}
+
+	start, err := time.Parse(timeOfDayLayout, s.Start)
+	if err != nil {
+		return false
+	}
+	end, err := time.Parse(timeOfDayLayout, s.End)
+	if err != nil {
+		return false
+	}
+
+	minutes := t.Hour()*60 + t.Minute()
+	startMinutes := start.Hour()*60 + start.Minute()
+	endMinutes := end.Hour()*60 + end.Minute()
+
+	day := t.Weekday()
+	var inWindow bool
+	if startMinutes <= endMinutes {
+		inWindow = minutes >= startMinutes && minutes < endMinutes
+	} else {
+		// The window spans midnight, the part after midnight belongs to the previous day.
+		inWindow = minutes >= startMinutes || minutes < endMinutes
+		if minutes < endMinutes {
+			day = (day + 6) % 7
+		}
+	}
+
+	return inWindow && s.onDay(day)
+}
+
+func (s *Schedule) onDay(day time.Weekday) bool {
+	if len(s.Days) == 0 {
+		return true
+	}
+	for _, d := range s.Days {
+		if weekdays[strings.ToLower(d)] == day {
+			return true
+		}
+	}
+
+	return false
+}
+
+func (s *Schedule) location() (*time.Location, error) {
+	if s.Timezone == "" {
+		return time.UTC, nil
+	}
+
+	loc, err := time.LoadLocation(s.Timezone)
+	if err != nil {
+		return nil, fmt.Errorf("invalid timezone %q", s.Timezone)
+	}
+
+	return loc, nil
+}
@@ -0,0 +1,78 @@
+package rbac
+
+import (
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSchedule(t *testing.T) {
+	at := func(value string) time.Time {
+		t.Helper()
+		parsed, err := time.Parse(time.RFC3339, value)
+		require.NoError(t, err)
+		return parsed
+	}
+
+	t.Run("Business hours", func(t *testing.T) {
+		s := &Schedule{Start: "09:00", End: "17:00", Days: []string{"mon", "tue", "wed", "thu", "fri"}, Timezone: "Europe/Stockholm"}
+		require.NoError(t, s.validate())
+
+		// 2021-03-01 is a Monday, Stockholm is UTC+1 in March.
+		assert.True(t, s.isActive(at("2021-03-01T08:00:00Z")))
+		assert.False(t, s.isActive(at("2021-03-01T07:59:00Z")))
+		assert.False(t, s.isActive(at("2021-03-01T16:00:00Z")))
+		assert.False(t, s.isActive(at("2021-03-06T10:00:00Z")))
+	})
+
+	t.Run("Window spanning midnight", func(t *testing.T) {
+		s := &Schedule{Start: "22:00", End: "06:00", Days: []string{"fri"}}
+		require.NoError(t, s.validate())
+
+		assert.True(t, s.isActive(at("2021-03-05T23:00:00Z")))
+		// Early Saturday belongs to Friday's window.
+		assert.True(t, s.isActive(at("2021-03-06T05:00:00Z")))
+		assert.False(t, s.isActive(at("2021-03-05T05:00:00Z")))
+	})
+
+	t.Run("Cron window", func(t *testing.T) {
+		// Weekly on-call rotation window starting Mondays at 09:00 for 12 hours.
+		s := &Schedule{Cron: "0 9 * * 1", Duration: "12h"}
+		require.NoError(t, s.validate())
+
+		assert.True(t, s.isActive(at("2021-03-01T09:00:00Z")))
+		assert.True(t, s.isActive(at("2021-03-01T20:59:00Z")))
+		assert.False(t, s.isActive(at("2021-03-01T21:00:00Z")))
+		assert.False(t, s.isActive(at("2021-03-02T09:30:00Z")))
+	})
+
+	t.Run("Invalid schedules", func(t *testing.T) {
+		for _, s := range []*Schedule{
+			{Start: "9am", End: "17:00"},
+			{Start: "09:00", End: "17:00", Days: []string{"someday"}},
+			{Start: "09:00", End: "17:00", Timezone: "Mars/Olympus"},
+			{Cron: "not a cron", Duration: "1h"},
+			{Cron: "0 9 * * 1"},
+		} {
+			assert.Error(t, s.validate())
+		}
+	})
+}
+
+func TestEvaluatePermissions_Schedule(t *testing.T) {
+	permissions := []Permission{{
+		Action:     "users:write",
+		Scope:      "users:*",
+		Conditions: []Condition{{Schedule: &Schedule{Start: "09:00", End: "17:00"}}},
+	}}
+
+	inside := accessRequest{Action: "users:write", Scope: "users:id:1", Time: time.Date(2021, 3, 1, 10, 0, 0, 0, time.UTC)}
+	outside := accessRequest{Action: "users:write", Scope: "users:id:1", Time: time.Date(2021, 3, 1, 18, 0, 0, 0, time.UTC)}
+	assert.True(t, evaluatePermissions(permissions, inside))
+	assert.False(t, evaluatePermissions(permissions, outside))
+
+	err := validateConditions([]Condition{{Attribute: AttributeUserLogin, Values: []string{"admin"}, Schedule: &Schedule{Start: "09:00", End: "17:00"}}})
+	require.ErrorIs(t, err, ErrInvalidCondition)
+}
@@ -0,0 +1,102 @@
+package rbac
+
+import (
+	"fmt"
+	"regexp"
+	"strconv"
+	"strings"
+)
+
+// Scopes are stored in the canonical form "<resource>:<attribute>:<value>", e.g. "datasources:id:1",
+// or as a wildcard pattern such as "datasources:*". Resources are lowercase and plural.
+var resourceNameRegexp = regexp.MustCompile(`^[a-z][a-z0-9._-]*$`)
+
+// resourceAliases maps singular resource names to their canonical plural form.
+var resourceAliases = map[string]string{
+	"dashboard":  "dashboards",
+	"datasource": "datasources",
+	"folder":     "folders",
+	"team":       "teams",
+	"user":       "users",
+}
+
+// ScopeError is an error for when a scope is malformed, it matches ErrInvalidScope.
+type ScopeError struct {
+	Scope  string
+	Reason string
+}
+
+func (e *ScopeError) Error() string {
+	return fmt.Sprintf("%s %q: %s", ErrInvalidScope, e.Scope, e.Reason)
+}
+
+// Is makes errors.Is(err, ErrInvalidScope) true for scope errors.
+func (e *ScopeError) Is(target error) bool {
+	return target == ErrInvalidScope
+}
+
+// normalizeScope validates a scope and returns its canonical form:
+// - singular and mixed case resource names become lowercase and plural, "Datasource:id:1" becomes "datasources:id:1",
+// - a numeric value without attribute is an id, "datasources:1" becomes "datasources:id:1",
+// - repeated trailing wildcards collapse into one, "dashboards:*:*" becomes "dashboards:*".
+func normalizeScope(scope string) (string, error) {
+	if scope == "" || scope == wildcard || isScopeKeyword(scope) {
+		return scope, nil
+	}
+	if err := validatePattern(scope); err != nil {
+		return "", &ScopeError{Scope: scope, Reason: err.Error()}
+	}
+
+	segments := strings.Split(scope, segmentSeparator)
+
+	resource := strings.ToLower(segments[0])
+	if alias, ok := resourceAliases[resource]; ok {
+		resource = alias
+	}
+	if resource == wildcard || !resourceNameRegexp.MatchString(resource) {
+		return "", &ScopeError{Scope: scope, Reason: "scope must start with a resource name"}
+	}
+	segments[0] = resource
+
+	for len(segments) > 2 && segments[len(segments)-1] == wildcard && segments[len(segments)-2] == wildcard {
+		segments = segments[:len(segments)-1]
+	}
+
+	switch {
+	case len(segments) == 1:
+		return "", &ScopeError{Scope: scope, Reason: "scope must be <resource>:<attribute>:<value> or a wildcard"}
+	case len(segments) == 2 && segments[1] != wildcard:
+		if _, err := strconv.ParseInt(segments[1], 10, 64); err != nil {
+			return "", &ScopeError{Scope: scope, Reason: "scope must be <resource>:<attribute>:<value> or a wildcard"}
+		}
+		segments = []string{resource, "id", segments[1]}
+	}
+
+	return strings.Join(segments, segmentSeparator), nil
+}
+
+// splitScope returns the resource kind, attribute and identifier of a scope, e.g. "dashboards", "uid"
+// and "abc" for "dashboards:uid:abc". Missing segments are empty.
+func splitScope(scope string) (kind, attribute, identifier string) {
+	segments := strings.SplitN(scope, segmentSeparator, 3)
+	for len(segments) < 3 {
+		segments = append(segments, "")
+	}
+	return segments[0], segments[1], segments[2]
+}
+
+// setScope sets the scope of the permission together with the columns derived from it.
+func (p *Permission) setScope(scope string) {
+	p.Scope = scope
+	p.ScopePrefix = scopePrefix(scope)
+	p.ScopeKind, p.ScopeAttribute, p.ScopeIdentifier = splitScope(scope)
+}
+
+// scopePrefix returns the first two segments of a scope, e.g. "dashboards:uid" for "dashboards:uid:abc".
+func scopePrefix(scope string) string {
+	segments := strings.SplitN(scope, segmentSeparator, 3)
+	if len(segments) > 2 {
+		segments = segments[:2]
+	}
+	return strings.Join(segments, segmentSeparator)
+}
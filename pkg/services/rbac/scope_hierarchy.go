@@ -0,0 +1,107 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// dashboardsCacheTTL bounds how long a dashboard moved or retagged by another Grafana instance keeps
+// the permissions of its previous folder or tags. Changes on this instance invalidate the cache right away.
+const dashboardsCacheTTL = time.Minute
+
+// ScopeFolderUID returns the scope of a folder identified by its uid.
+func ScopeFolderUID(uid string) string {
+	return "folders:uid:" + uid
+}
+
+func newDashboardsCache() *localcache.CacheService {
+	return localcache.New(dashboardsCacheTTL, 2*dashboardsCacheTTL)
+}
+
+// InvalidateScopeHierarchy forgets which dashboards each folder contains and which dashboards
+// have each tag, along with the cached denials. It must be called whenever dashboards are created,
+// moved, retagged or deleted.
+func (ac *RBACService) InvalidateScopeHierarchy() {
+	ac.denyCache.invalidate(0, 0)
+	if ac.folderDashboards != nil {
+		ac.folderDashboards.Flush()
+	}
+	if ac.taggedDashboards != nil {
+		ac.taggedDashboards.Flush()
+	}
+}
+
+// expandFolderScopes adds, for each permission scoped to a folder, the same permission scoped
+// to every dashboard in the folder, so that folder permissions cover the folder's dashboards.
+// Permissions on every folder cover every dashboard.
+func (ac *RBACService) expandFolderScopes(ctx context.Context, orgID int64, permissions []Permission) ([]Permission, error) {
+	expanded := permissions
+	for _, p := range permissions {
+		if !strings.HasPrefix(p.Scope, "folders:") {
+			continue
+		}
+
+		if strings.HasSuffix(p.Scope, wildcard) {
+			p.Scope = "dashboards:*"
+			expanded = append(expanded, p)
+			continue
+		}
+
+		uids, err := ac.getFolderDashboardUIDs(ctx, orgID, p.Scope)
+		if err != nil {
+			return nil, err
+		}
+		for _, uid := range uids {
+			p.Scope = ScopeDashboardUID(uid)
+			expanded = append(expanded, p)
+		}
+	}
+
+	return expanded, nil
+}
+
+// getFolderDashboardUIDs returns the uids of the dashboards in the folder identified by the scope,
+// either "folders:id:<id>" or "folders:uid:<uid>". Unknown folders contain no dashboards.
+func (ac *RBACService) getFolderDashboardUIDs(ctx context.Context, orgID int64, scope string) ([]string, error) {
+	key := fmt.Sprintf("%d-%s", orgID, scope)
+	if ac.folderDashboards != nil {
+		if cached, found := ac.folderDashboards.Get(key); found {
+			return cached.([]string), nil
+		}
+	}
+
+	var uids []string
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		isFolder := ac.SQLStore.Dialect.BooleanStr(false)
+		switch {
+		case strings.HasPrefix(scope, "folders:id:"):
+			folderID, err := strconv.ParseInt(strings.TrimPrefix(scope, "folders:id:"), 10, 64)
+			if err != nil {
+				return nil
+			}
+			q := "SELECT uid FROM dashboard WHERE org_id = ? AND folder_id = ? AND is_folder = " + isFolder
+			return sess.SQL(q, orgID, folderID).Find(&uids)
+		case strings.HasPrefix(scope, "folders:uid:"):
+			q := `SELECT dashboard.uid FROM dashboard
+				INNER JOIN dashboard AS folder ON folder.id = dashboard.folder_id
+				WHERE dashboard.org_id = ? AND folder.uid = ? AND dashboard.is_folder = ` + isFolder
+			return sess.SQL(q, orgID, strings.TrimPrefix(scope, "folders:uid:")).Find(&uids)
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if ac.folderDashboards != nil {
+		ac.folderDashboards.Set(key, uids, 0)
+	}
+
+	return uids, nil
+}
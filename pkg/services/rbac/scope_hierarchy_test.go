@@ -0,0 +1,92 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func saveDashboard(t *testing.T, title string, folderID int64, isFolder bool) *models.Dashboard {
+	t.Helper()
+
+	cmd := &models.SaveDashboardCommand{
+		OrgId:     1,
+		FolderId:  folderID,
+		IsFolder:  isFolder,
+		Overwrite: true,
+		Dashboard: simplejson.NewFromAny(map[string]interface{}{
+			"title": title,
+		}),
+	}
+	require.NoError(t, sqlstore.SaveDashboard(cmd))
+
+	return cmd.Result
+}
+
+func TestFolderScopesCoverDashboards(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	folder := saveDashboard(t, "production", 0, true)
+	inFolder := saveDashboard(t, "api latency", folder.Id, false)
+	outside := saveDashboard(t, "sandbox", 0, false)
+
+	team := createTeam(t, 1, "sre")
+	addTeamMember(t, 1, team.Id, 61)
+	policy := createPolicy(t, ac, 1, "production editor",
+		CreatePermissionCommand{Action: ActionDashboardsWrite, Scope: ScopeFolderUID(folder.Uid)},
+		CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "folders:*"},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 61}
+	check := func(action string, dash *models.Dashboard) bool {
+		ok, err := ac.Evaluate(context.Background(), user, Perm(action, ScopeDashboardUID(dash.Uid)))
+		require.NoError(t, err)
+		return ok
+	}
+
+	assert.True(t, check(ActionDashboardsWrite, inFolder))
+	assert.False(t, check(ActionDashboardsWrite, outside))
+	assert.True(t, check(ActionDashboardsRead, outside))
+
+	t.Run("Moving a dashboard should apply its new folder's permissions once the hierarchy is invalidated", func(t *testing.T) {
+		cmd := &models.SaveDashboardCommand{
+			OrgId:     1,
+			FolderId:  folder.Id,
+			Overwrite: true,
+			Dashboard: simplejson.NewFromAny(map[string]interface{}{
+				"id":    outside.Id,
+				"uid":   outside.Uid,
+				"title": outside.Title,
+			}),
+		}
+		require.NoError(t, sqlstore.SaveDashboard(cmd))
+		moved := cmd.Result
+
+		assert.False(t, check(ActionDashboardsWrite, moved), "the folder contents should be cached")
+
+		ac.InvalidateScopeHierarchy()
+		assert.True(t, check(ActionDashboardsWrite, moved))
+	})
+}
+
+func TestGetFolderDashboardUIDs(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	folder := saveDashboard(t, "folder", 0, true)
+	dash := saveDashboard(t, "dash", folder.Id, false)
+
+	uids, err := ac.getFolderDashboardUIDs(context.Background(), 1, ScopeFolderID(folder.Id))
+	require.NoError(t, err)
+	assert.Equal(t, []string{dash.Uid}, uids)
+
+	uids, err = ac.getFolderDashboardUIDs(context.Background(), 1, ScopeFolderUID("unknown"))
+	require.NoError(t, err)
+	assert.Empty(t, uids)
+}
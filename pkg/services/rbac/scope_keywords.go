@@ -0,0 +1,38 @@
+package rbac
+
+import (
+	"strconv"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// Scope keywords are stored as is and expanded when evaluating, based on the signed in user, so
+// that a single policy can for instance grant every user the right to edit their own profile.
+const (
+	ScopeUsersSelf  = "users:self"
+	ScopeOrgCurrent = "orgs:current"
+)
+
+var scopeKeywords = map[string]func(user *models.SignedInUser) string{
+	ScopeUsersSelf:  func(user *models.SignedInUser) string { return ScopeUserID(user.UserId) },
+	ScopeOrgCurrent: func(user *models.SignedInUser) string { return ScopeOrgID(user.OrgId) },
+}
+
+// ScopeUserID returns the scope of a user identified by its id.
+func ScopeUserID(id int64) string {
+	return "users:id:" + strconv.FormatInt(id, 10)
+}
+
+// isScopeKeyword returns true if the scope is a keyword expanded during evaluation.
+func isScopeKeyword(scope string) bool {
+	_, ok := scopeKeywords[scope]
+	return ok
+}
+
+// expandScopeKeyword returns the scope a keyword stands for in the user's context, other scopes are returned unchanged.
+func expandScopeKeyword(user *models.SignedInUser, scope string) string {
+	if expand, ok := scopeKeywords[scope]; ok {
+		return expand(user)
+	}
+	return scope
+}
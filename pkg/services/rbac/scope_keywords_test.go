@@ -0,0 +1,40 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestScopeKeywords(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "everyone")
+	addTeamMember(t, 1, team.Id, 91)
+	addTeamMember(t, 1, team.Id, 92)
+	policy := createPolicy(t, ac, 1, "self service",
+		CreatePermissionCommand{Action: ActionUsersWrite, Scope: ScopeUsersSelf},
+		CreatePermissionCommand{Action: ActionTeamsRead, Scope: ScopeOrgCurrent},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	permissions, err := ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID})
+	require.NoError(t, err)
+	assert.Equal(t, ScopeUsersSelf, permissions[0].Scope, "keywords should be stored as is")
+
+	check := func(userID int64, evaluator Evaluator) bool {
+		ok, err := ac.Evaluate(context.Background(), &models.SignedInUser{OrgId: 1, UserId: userID}, evaluator)
+		require.NoError(t, err)
+		return ok
+	}
+
+	assert.True(t, check(91, Perm(ActionUsersWrite, ScopeUserID(91))))
+	assert.False(t, check(91, Perm(ActionUsersWrite, ScopeUserID(92))))
+	assert.True(t, check(92, Perm(ActionUsersWrite, ScopeUserID(92))))
+	assert.True(t, check(91, Perm(ActionTeamsRead, ScopeOrgID(1))))
+	assert.False(t, check(91, Perm(ActionTeamsRead, ScopeOrgID(2))))
+}
@@ -0,0 +1,136 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/infra/localcache"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// scopeResolutionCacheTTL bounds how long a renamed or deleted resource keeps matching its old scope.
+const scopeResolutionCacheTTL = 30 * time.Second
+
+// ScopeAttributeResolver translates a scope identifying a resource by one of its attributes,
+// e.g. "datasources:name:prom", into the scope identifying it by id, e.g. "datasources:id:4".
+type ScopeAttributeResolver interface {
+	Resolve(ctx context.Context, orgID int64, scope string) (string, error)
+}
+
+// ScopeAttributeResolverFunc adapts a function to a ScopeAttributeResolver.
+type ScopeAttributeResolverFunc func(ctx context.Context, orgID int64, scope string) (string, error)
+
+// Resolve calls f(ctx, orgID, scope).
+func (f ScopeAttributeResolverFunc) Resolve(ctx context.Context, orgID int64, scope string) (string, error) {
+	return f(ctx, orgID, scope)
+}
+
+// scopeResolvers holds the registered resolvers keyed by the scope prefix they translate, e.g. "datasources:name:".
+// The zero value is ready to use so that services can register resolvers before the RBACService is initialized.
+type scopeResolvers struct {
+	mu        sync.RWMutex
+	resolvers map[string]ScopeAttributeResolver
+	cache     *localcache.CacheService
+}
+
+// RegisterScopeAttributeResolver registers a resolver for the scopes starting with the prefix,
+// which is made of a resource and an attribute, e.g. "datasources:name:". Resolved scopes are
+// cached for a short while.
+func (ac *RBACService) RegisterScopeAttributeResolver(prefix string, resolver ScopeAttributeResolver) {
+	ac.scopeResolvers.mu.Lock()
+	defer ac.scopeResolvers.mu.Unlock()
+
+	if ac.scopeResolvers.resolvers == nil {
+		ac.scopeResolvers.resolvers = map[string]ScopeAttributeResolver{}
+		ac.scopeResolvers.cache = localcache.New(scopeResolutionCacheTTL, 2*scopeResolutionCacheTTL)
+	}
+	ac.scopeResolvers.resolvers[prefix] = resolver
+}
+
+// resolveScope returns the scope translated by the matching resolver. Scopes without a resolver,
+// wildcard scopes and scopes that fail to resolve are returned unchanged.
+func (ac *RBACService) resolveScope(ctx context.Context, orgID int64, scope string) string {
+	if scope == "" || strings.Contains(scope, wildcard) {
+		return scope
+	}
+
+	ac.scopeResolvers.mu.RLock()
+	resolver, ok := ac.scopeResolvers.resolvers[scopePrefix(scope)+segmentSeparator]
+	cache := ac.scopeResolvers.cache
+	ac.scopeResolvers.mu.RUnlock()
+	if !ok {
+		return scope
+	}
+
+	key := fmt.Sprintf("%d-%s", orgID, scope)
+	if resolved, found := cache.Get(key); found {
+		return resolved.(string)
+	}
+
+	resolved, err := resolver.Resolve(ctx, orgID, scope)
+	if err != nil {
+		ac.log.Debug("Failed to resolve scope", "scope", scope, "orgId", orgID, "err", err)
+		return scope
+	}
+	cache.Set(key, resolved, 0)
+
+	return resolved
+}
+
+// resolveUserPermissions returns the permissions of a user with their scopes resolved, ready for evaluation.
+func (ac *RBACService) resolveUserPermissions(ctx context.Context, user *models.SignedInUser) ([]Permission, error) {
+	if permissions, ok := sessionPermissionsFromContext(ctx).permissionsFor(user); ok {
+		return ac.resolvePermissions(ctx, user, permissions)
+	}
+
+	start := time.Now()
+	permissions, err := ac.getUserPermissions(ctx, GetUserPermissionsQuery{
+		OrgID: user.OrgId, UserID: user.UserId, Roles: BuiltinRoles(user),
+	})
+	decisionTimingsFromContext(ctx).since(PhaseDBResolution, start)
+	if err != nil {
+		return nil, err
+	}
+
+	return ac.resolvePermissions(ctx, user, permissions)
+}
+
+// resolvePermissions expands the scope keywords, resolves the attribute scopes and expands the folder
+// and tag scopes of the user's permissions.
+func (ac *RBACService) resolvePermissions(ctx context.Context, user *models.SignedInUser, permissions []Permission) ([]Permission, error) {
+	defer decisionTimingsFromContext(ctx).since(PhaseCacheLookup, time.Now())
+
+	for i := range permissions {
+		scope := expandScopeKeyword(user, permissions[i].Scope)
+		permissions[i].Scope = ac.resolveScope(ctx, user.OrgId, scope)
+	}
+
+	permissions, err := ac.expandFolderScopes(ctx, user.OrgId, permissions)
+	if err != nil {
+		return nil, err
+	}
+
+	return ac.expandTagScopes(ctx, user.OrgId, permissions)
+}
+
+// registerDefaultScopeResolvers registers the resolvers for resources owned by core Grafana.
+func (ac *RBACService) registerDefaultScopeResolvers() {
+	ac.RegisterScopeAttributeResolver("datasources:name:", ScopeAttributeResolverFunc(func(ctx context.Context, orgID int64, scope string) (string, error) {
+		query := models.GetDataSourceQuery{Name: strings.TrimPrefix(scope, "datasources:name:"), OrgId: orgID}
+		if err := bus.Dispatch(&query); err != nil {
+			return "", err
+		}
+		return ScopeDatasourceID(query.Result.Id), nil
+	}))
+	ac.RegisterScopeAttributeResolver("datasources:uid:", ScopeAttributeResolverFunc(func(ctx context.Context, orgID int64, scope string) (string, error) {
+		query := models.GetDataSourceQuery{Uid: strings.TrimPrefix(scope, "datasources:uid:"), OrgId: orgID}
+		if err := bus.Dispatch(&query); err != nil {
+			return "", err
+		}
+		return ScopeDatasourceID(query.Result.Id), nil
+	}))
+}
@@ -0,0 +1,55 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestResolveScope(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	calls := 0
+	ac.RegisterScopeAttributeResolver("teams:name:", ScopeAttributeResolverFunc(func(ctx context.Context, orgID int64, scope string) (string, error) {
+		calls++
+		if scope == "teams:name:missing" {
+			return "", errors.New("team not found")
+		}
+		return ScopeTeamID(7), nil
+	}))
+
+	assert.Equal(t, "teams:id:7", ac.resolveScope(context.Background(), 1, "teams:name:ops"))
+	assert.Equal(t, "teams:id:7", ac.resolveScope(context.Background(), 1, "teams:name:ops"))
+	assert.Equal(t, 1, calls, "resolved scopes should be cached")
+
+	assert.Equal(t, "teams:name:missing", ac.resolveScope(context.Background(), 1, "teams:name:missing"))
+	assert.Equal(t, "teams:name:*", ac.resolveScope(context.Background(), 1, "teams:name:*"))
+	assert.Equal(t, "users:login:admin", ac.resolveScope(context.Background(), 1, "users:login:admin"))
+}
+
+func TestEvaluate_ResolvesDatasourceNames(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	addDs := models.AddDataSourceCommand{OrgId: 1, Name: "prom", Type: "prometheus", Access: models.DS_ACCESS_PROXY}
+	require.NoError(t, bus.Dispatch(&addDs))
+
+	team := createTeam(t, 1, "observability")
+	addTeamMember(t, 1, team.Id, 51)
+	policy := createPolicy(t, ac, 1, "prom querier", CreatePermissionCommand{Action: ActionDatasourcesQuery, Scope: "datasources:name:prom"})
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 51}
+	ok, err := ac.Evaluate(context.Background(), user, Perm(ActionDatasourcesQuery, ScopeDatasourceID(addDs.Result.Id)))
+	require.NoError(t, err)
+	assert.True(t, ok)
+
+	ok, err = ac.Evaluate(context.Background(), user, Perm(ActionDatasourcesQuery, ScopeDatasourceID(addDs.Result.Id+1)))
+	require.NoError(t, err)
+	assert.False(t, ok)
+}
@@ -0,0 +1,65 @@
+package rbac
+
+import (
+	"context"
+	"fmt"
+	"strings"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// ScopeDashboardTag returns the scope of the dashboards tagged with the tag.
+func ScopeDashboardTag(tag string) string {
+	return "dashboards:tag:" + tag
+}
+
+// expandTagScopes adds, for each permission scoped to a dashboard tag, the same permission scoped
+// to every dashboard with the tag, so that access follows tagging conventions. Tags are matched
+// exactly, wildcards in tag scopes aren't expanded.
+func (ac *RBACService) expandTagScopes(ctx context.Context, orgID int64, permissions []Permission) ([]Permission, error) {
+	expanded := permissions
+	for _, p := range permissions {
+		if !strings.HasPrefix(p.Scope, "dashboards:tag:") || strings.Contains(p.Scope, wildcard) {
+			continue
+		}
+
+		uids, err := ac.getTaggedDashboardUIDs(ctx, orgID, strings.TrimPrefix(p.Scope, "dashboards:tag:"))
+		if err != nil {
+			return nil, err
+		}
+		for _, uid := range uids {
+			p.Scope = ScopeDashboardUID(uid)
+			expanded = append(expanded, p)
+		}
+	}
+
+	return expanded, nil
+}
+
+// getTaggedDashboardUIDs returns the uids of the dashboards of the organization tagged with the tag.
+func (ac *RBACService) getTaggedDashboardUIDs(ctx context.Context, orgID int64, tag string) ([]string, error) {
+	key := fmt.Sprintf("%d-%s", orgID, tag)
+	if ac.taggedDashboards != nil {
+		if cached, found := ac.taggedDashboards.Get(key); found {
+			return cached.([]string), nil
+		}
+	}
+
+	var uids []string
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := `SELECT dashboard.uid FROM dashboard
+			INNER JOIN dashboard_tag ON dashboard_tag.dashboard_id = dashboard.id
+			WHERE dashboard.org_id = ? AND dashboard_tag.term = ? AND dashboard.is_folder = ` +
+			ac.SQLStore.Dialect.BooleanStr(false)
+		return sess.SQL(q, orgID, tag).Find(&uids)
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	if ac.taggedDashboards != nil {
+		ac.taggedDashboards.Set(key, uids, 0)
+	}
+
+	return uids, nil
+}
@@ -0,0 +1,68 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/components/simplejson"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func saveTaggedDashboard(t *testing.T, id int64, uid, title string, tags ...string) *models.Dashboard {
+	t.Helper()
+
+	tagList := make([]interface{}, 0, len(tags))
+	for _, tag := range tags {
+		tagList = append(tagList, tag)
+	}
+	data := map[string]interface{}{"title": title, "tags": tagList}
+	if id != 0 {
+		data["id"] = id
+		data["uid"] = uid
+	}
+	cmd := &models.SaveDashboardCommand{OrgId: 1, Overwrite: true, Dashboard: simplejson.NewFromAny(data)}
+	require.NoError(t, sqlstore.SaveDashboard(cmd))
+
+	return cmd.Result
+}
+
+func TestTagScopesCoverTaggedDashboards(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	payments := saveTaggedDashboard(t, 0, "", "checkout", "team-payments", "slo")
+	other := saveTaggedDashboard(t, 0, "", "search", "team-search")
+
+	policy := createPolicy(t, ac, 1, "payments editor",
+		CreatePermissionCommand{Action: ActionDashboardsWrite, Scope: ScopeDashboardTag("team-payments")},
+	)
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 231, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 231}
+	check := func(dash *models.Dashboard) bool {
+		ok, err := ac.Evaluate(context.Background(), user, Perm(ActionDashboardsWrite, ScopeDashboardUID(dash.Uid)))
+		require.NoError(t, err)
+		return ok
+	}
+
+	assert.True(t, check(payments))
+	assert.False(t, check(other))
+
+	t.Run("Retagging a dashboard should apply once the hierarchy is invalidated", func(t *testing.T) {
+		retagged := saveTaggedDashboard(t, other.Id, other.Uid, other.Title, "team-payments")
+
+		assert.False(t, check(retagged), "the tagged dashboards should be cached")
+
+		ac.InvalidateScopeHierarchy()
+		assert.True(t, check(retagged))
+	})
+
+	t.Run("Tags of another organization should not match", func(t *testing.T) {
+		uids, err := ac.getTaggedDashboardUIDs(context.Background(), 2, "team-payments")
+		require.NoError(t, err)
+		assert.Empty(t, uids)
+	})
+}
@@ -0,0 +1,159 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestNormalizeScope(t *testing.T) {
+	tests := []struct {
+		scope    string
+		expected string
+		invalid  bool
+	}{
+		{scope: "", expected: ""},
+		{scope: "*", expected: "*"},
+		{scope: "datasources:id:1", expected: "datasources:id:1"},
+		{scope: "datasources:1", expected: "datasources:id:1"},
+		{scope: "datasource:id:1", expected: "datasources:id:1"},
+		{scope: "Datasource:1", expected: "datasources:id:1"},
+		{scope: "dashboards:*:*", expected: "dashboards:*"},
+		{scope: "dashboards:uid:*:*", expected: "dashboards:uid:*"},
+		{scope: "alerts:namespace:prod", expected: "alerts:namespace:prod"},
+		{scope: "datasources", invalid: true},
+		{scope: "datasources:prometheus", invalid: true},
+		{scope: "*:id:1", invalid: true},
+		{scope: "data sources:id:1", invalid: true},
+		{scope: "dashboards::1", invalid: true},
+		{scope: "dashboards:*:1", invalid: true},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.scope, func(t *testing.T) {
+			normalized, err := normalizeScope(tc.scope)
+			if tc.invalid {
+				require.ErrorIs(t, err, ErrInvalidScope)
+				var scopeErr *ScopeError
+				require.True(t, errors.As(err, &scopeErr))
+				assert.Equal(t, tc.scope, scopeErr.Scope)
+				return
+			}
+			require.NoError(t, err)
+			assert.Equal(t, tc.expected, normalized)
+		})
+	}
+}
+
+func TestCreatePermission_NormalizesScope(t *testing.T) {
+	ac := setupTestEnv(t)
+	policy := createPolicy(t, ac, 1, "datasource reader")
+
+	permission, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "datasources:read", Scope: "datasource:1"})
+	require.NoError(t, err)
+	assert.Equal(t, "datasources:id:1", permission.Scope)
+
+	permission, err = ac.UpdatePermission(context.Background(), UpdatePermissionCommand{OrgID: 1, ID: permission.ID, Action: "datasources:read", Scope: "datasources:*:*"})
+	require.NoError(t, err)
+	assert.Equal(t, "datasources:*", permission.Scope)
+
+	_, err = ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "datasources:read", Scope: "datasources:prometheus"})
+	require.ErrorIs(t, err, ErrInvalidScope)
+}
+
+func TestScopePrefix(t *testing.T) {
+	assert.Equal(t, "dashboards:uid", scopePrefix("dashboards:uid:abc"))
+	assert.Equal(t, "alerts:namespace", scopePrefix("alerts:namespace:a:b"))
+	assert.Equal(t, "dashboards:*", scopePrefix("dashboards:*"))
+	assert.Equal(t, "*", scopePrefix("*"))
+	assert.Equal(t, "", scopePrefix(""))
+}
+
+func TestGetPolicyPermissions_ResourceTypeWithoutScopePrefix(t *testing.T) {
+	ac := setupTestEnv(t)
+	policy := createPolicy(t, ac, 1, "legacy",
+		CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:uid:abc"},
+		CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:id:1"},
+	)
+
+	permissions, err := ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID})
+	require.NoError(t, err)
+	require.Len(t, permissions, 2)
+	assert.Equal(t, "dashboards:uid", permissions[0].ScopePrefix)
+
+	// Simulate a permission written by a Grafana version predating the scope_prefix column.
+	err = ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Exec("UPDATE permission SET scope_prefix = NULL WHERE id = ?", permissions[0].ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	permissions, err = ac.GetPolicyPermissions(context.Background(), GetPolicyPermissionsQuery{OrgID: 1, PolicyID: policy.ID, ResourceType: "dashboards"})
+	require.NoError(t, err)
+	require.Len(t, permissions, 1)
+	assert.Equal(t, "dashboards:uid:abc", permissions[0].Scope)
+}
+
+func TestSplitScope(t *testing.T) {
+	tests := []struct {
+		scope                       string
+		kind, attribute, identifier string
+	}{
+		{scope: "dashboards:uid:abc", kind: "dashboards", attribute: "uid", identifier: "abc"},
+		{scope: "alerts:namespace:a:b", kind: "alerts", attribute: "namespace", identifier: "a:b"},
+		{scope: "dashboards:*", kind: "dashboards", attribute: "*"},
+		{scope: "*", kind: "*"},
+		{scope: ""},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.scope, func(t *testing.T) {
+			kind, attribute, identifier := splitScope(tc.scope)
+			assert.Equal(t, tc.kind, kind)
+			assert.Equal(t, tc.attribute, attribute)
+			assert.Equal(t, tc.identifier, identifier)
+		})
+	}
+}
+
+func TestGetScopePermissions(t *testing.T) {
+	ac := setupTestEnv(t)
+	createPolicy(t, ac, 1, "datasource 4 reader",
+		CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:id:4"},
+		CreatePermissionCommand{Action: "datasources:query", Scope: "datasources:id:4"},
+		CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:id:40"},
+	)
+	legacy := createPolicy(t, ac, 1, "legacy datasource 4 writer",
+		CreatePermissionCommand{Action: "datasources:write", Scope: "datasources:id:4"},
+	)
+	createPolicy(t, ac, 1, "dashboards reader", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:id:4"})
+	createPolicy(t, ac, 2, "other org", CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:id:4"})
+
+	// Simulate a permission written by a Grafana version predating the scope segment columns.
+	err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Exec("UPDATE permission SET scope_kind = NULL, scope_attribute = NULL, scope_identifier = NULL WHERE policy_id = ?", legacy.ID)
+		return err
+	})
+	require.NoError(t, err)
+
+	actions := func(query GetScopePermissionsQuery) []string {
+		permissions, err := ac.GetScopePermissions(context.Background(), query)
+		require.NoError(t, err)
+		actions := make([]string, 0, len(permissions))
+		for _, p := range permissions {
+			actions = append(actions, p.Action+" "+p.Scope)
+		}
+		return actions
+	}
+
+	assert.Equal(t, []string{"datasources:read datasources:id:4", "datasources:query datasources:id:4", "datasources:write datasources:id:4"},
+		actions(GetScopePermissionsQuery{OrgID: 1, Kind: "datasources", Attribute: "id", Identifier: "4"}))
+	assert.Len(t, actions(GetScopePermissionsQuery{OrgID: 1, Kind: "datasources", Attribute: "id"}), 4)
+	assert.Len(t, actions(GetScopePermissionsQuery{OrgID: 1, Kind: "datasources"}), 4)
+	assert.Empty(t, actions(GetScopePermissionsQuery{OrgID: 1, Kind: "datasources", Attribute: "uid"}))
+}
@@ -0,0 +1,53 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore/searchstore"
+)
+
+// searchActions are the actions a search for dashboards and folders with a permission level requires,
+// the first on dashboards and the second on folders.
+var searchActions = map[models.PermissionType][2]string{
+	models.PERMISSION_VIEW: {ActionDashboardsRead, ActionFoldersRead},
+	models.PERMISSION_EDIT: {ActionDashboardsWrite, ActionFoldersWrite},
+}
+
+// searchFilter is the searchstore filter of an SQLFilter.
+type searchFilter struct {
+	filter SQLFilter
+}
+
+func (f searchFilter) Where() (string, []interface{}) {
+	return f.filter.Where, f.filter.Args
+}
+
+// searchPermissionFilter restricts the searches for dashboards and folders to those the user is granted
+// the actions of the permission level on, see searchActions. The dashboard ACL still decides when RBAC
+// is degraded, when dashboards aren't in strict mode, since legacy access control has a say then, and
+// for the permission levels without actions.
+func (ac *RBACService) searchPermissionFilter(ctx context.Context, user *models.SignedInUser, permission models.PermissionType) (searchstore.FilterWhere, error) {
+	actions, ok := searchActions[permission]
+	if !ok || !ac.IsEnabled() || ac.ResourceMode("dashboards") != ResourceModeStrict {
+		return nil, nil
+	}
+
+	dashboards, err := ac.Filter(ctx, user, actions[0], "dashboards:uid:", "dashboard.uid")
+	if err != nil {
+		return nil, err
+	}
+	// Folders are identified by their id too, which is compared as text so that wildcards apply to it.
+	folders, err := ac.filter(ctx, user, actions[1],
+		scopeColumn{prefix: "folders:uid:", column: "dashboard.uid"},
+		scopeColumn{prefix: "folders:id:", column: ac.textColumn("dashboard.id")})
+	if err != nil {
+		return nil, err
+	}
+
+	dialect := ac.SQLStore.Dialect
+	return searchFilter{filter: sqlOr(
+		sqlAnd(SQLFilter{Where: "dashboard.is_folder = " + dialect.BooleanStr(false)}, dashboards),
+		sqlAnd(SQLFilter{Where: "dashboard.is_folder = " + dialect.BooleanStr(true)}, folders),
+	)}, nil
+}
@@ -0,0 +1,70 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/search"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestSearchPermissionFilter(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	folder := saveDashboard(t, "production", 0, true)
+	saveDashboard(t, "api latency", folder.Id, false)
+	staging := saveDashboard(t, "staging", 0, true)
+	saveDashboard(t, "sandbox", 0, false)
+
+	policy := createPolicy(t, ac, 1, "production viewer",
+		CreatePermissionCommand{Action: ActionDashboardsRead, Scope: ScopeFolderUID(folder.Uid)},
+		CreatePermissionCommand{Action: ActionFoldersRead, Scope: ScopeFolderUID(folder.Uid)})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 498, PolicyID: policy.ID}))
+	user := &models.SignedInUser{OrgId: 1, UserId: 498, OrgRole: models.ROLE_VIEWER}
+
+	titles := func(t *testing.T, user *models.SignedInUser, permission models.PermissionType) []string {
+		t.Helper()
+		filter, err := ac.searchPermissionFilter(context.Background(), user, permission)
+		require.NoError(t, err)
+		require.NotNil(t, filter)
+
+		query := &search.FindPersistedDashboardsQuery{SignedInUser: user, Permission: permission, PermissionFilter: filter}
+		require.NoError(t, sqlstore.SearchDashboards(query))
+		var titles []string
+		for _, hit := range query.Result {
+			titles = append(titles, hit.Title)
+		}
+		return titles
+	}
+
+	t.Run("Searches should return the dashboards and folders the user may read", func(t *testing.T) {
+		assert.ElementsMatch(t, []string{"production", "api latency"}, titles(t, user, models.PERMISSION_VIEW))
+	})
+
+	t.Run("Searches for editable dashboards should require write permissions", func(t *testing.T) {
+		assert.Empty(t, titles(t, user, models.PERMISSION_EDIT))
+	})
+
+	t.Run("Folders should be matched by id too", func(t *testing.T) {
+		policy := createPolicy(t, ac, 1, "staging viewer",
+			CreatePermissionCommand{Action: ActionFoldersRead, Scope: ScopeFolderID(staging.Id)})
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 499, PolicyID: policy.ID}))
+		assert.Equal(t, []string{"staging"}, titles(t, &models.SignedInUser{OrgId: 1, UserId: 499, OrgRole: models.ROLE_VIEWER}, models.PERMISSION_VIEW))
+
+		policy = createPolicy(t, ac, 1, "every folder but production",
+			CreatePermissionCommand{Action: ActionFoldersRead, Scope: "folders:*"},
+			CreatePermissionCommand{Action: ActionFoldersRead, Scope: ScopeFolderUID(folder.Uid), Kind: PermissionKindDeny})
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 500, PolicyID: policy.ID}))
+		assert.Equal(t, []string{"staging"}, titles(t, &models.SignedInUser{OrgId: 1, UserId: 500, OrgRole: models.ROLE_VIEWER}, models.PERMISSION_VIEW))
+	})
+
+	t.Run("The dashboard ACL should decide the permission levels without actions", func(t *testing.T) {
+		filter, err := ac.searchPermissionFilter(context.Background(), user, models.PERMISSION_ADMIN)
+		require.NoError(t, err)
+		assert.Nil(t, filter)
+	})
+}
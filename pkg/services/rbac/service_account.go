@@ -0,0 +1,133 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// CreateServiceAccount turns a user into a service account, a machine identity whose permissions
+// come solely from the policies bound to it with AddServiceAccountPolicy. The policies of its
+// role and teams no longer apply.
+func (ac *RBACService) CreateServiceAccount(ctx context.Context, cmd CreateServiceAccountCommand) (*ServiceAccount, error) {
+	if err := ac.checkSchemaVersion(schemaVersionServiceAccount); err != nil {
+		return nil, err
+	}
+
+	serviceAccount := &ServiceAccount{
+		OrgID:   cmd.OrgID,
+		UserID:  cmd.UserID,
+		Created: time.Now(),
+	}
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := sess.Table("service_account").Insert(serviceAccount); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrServiceAccountAlreadyExists
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return serviceAccount, nil
+}
+
+// DeleteServiceAccount turns a service account back into a regular user and unbinds its policies.
+func (ac *RBACService) DeleteServiceAccount(ctx context.Context, cmd DeleteServiceAccountCommand) error {
+	var removed []UserPolicy
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		result, err := sess.Exec("DELETE FROM service_account WHERE org_id = ? AND user_id = ?", cmd.OrgID, cmd.UserID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return ErrServiceAccountNotFound
+		}
+
+		if err := sess.Table("user_policy").Where("org_id = ? AND user_id = ?", cmd.OrgID, cmd.UserID).Find(&removed); err != nil {
+			return err
+		}
+		_, err = sess.Exec("DELETE FROM user_policy WHERE org_id = ? AND user_id = ?", cmd.OrgID, cmd.UserID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonServiceAccountDeleted)
+	for _, up := range removed {
+		changes.userUnbound(up.OrgID, up.PolicyID, up.UserID)
+	}
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// GetServiceAccountPolicies returns the policies bound to a service account.
+func (ac *RBACService) GetServiceAccountPolicies(ctx context.Context, query GetServiceAccountPoliciesQuery) ([]*PolicyDTO, error) {
+	return ac.GetUserPolicies(ctx, GetUserPoliciesQuery{OrgID: query.OrgID, UserID: query.UserID})
+}
+
+// AddServiceAccountPolicy binds a policy to a service account.
+func (ac *RBACService) AddServiceAccountPolicy(ctx context.Context, cmd AddServiceAccountPolicyCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionBindingExpiry); err != nil {
+		return err
+	}
+
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if err := requireServiceAccount(sess, cmd.OrgID, cmd.UserID); err != nil {
+			return err
+		}
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID}); err != nil {
+			return err
+		}
+
+		return ac.addUserPolicy(sess, &UserPolicy{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID, UserID: cmd.UserID, GrantedBy: cmd.PerformedBy})
+	})
+	if err != nil {
+		return err
+	}
+
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonManual)
+	changes.userBound(cmd.OrgID, cmd.PolicyID, cmd.UserID)
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// RemoveServiceAccountPolicy unbinds a policy from a service account.
+func (ac *RBACService) RemoveServiceAccountPolicy(ctx context.Context, cmd RemoveServiceAccountPolicyCommand) error {
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		return requireServiceAccount(sess, cmd.OrgID, cmd.UserID)
+	})
+	if err != nil {
+		return err
+	}
+
+	return ac.RemoveUserPolicy(ctx, RemoveUserPolicyCommand{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID, UserID: cmd.UserID,
+		PerformedBy: cmd.PerformedBy})
+}
+
+// isServiceAccount returns true if the user is a service account of the organization.
+func isServiceAccount(sess *sqlstore.DBSession, orgID, userID int64) (bool, error) {
+	return sess.Table("service_account").Where("org_id = ? AND user_id = ?", orgID, userID).Exist()
+}
+
+// requireServiceAccount returns ErrServiceAccountNotFound if the user isn't a service account of the organization.
+func requireServiceAccount(sess *sqlstore.DBSession, orgID, userID int64) error {
+	ok, err := isServiceAccount(sess, orgID, userID)
+	if err != nil {
+		return err
+	}
+	if !ok {
+		return ErrServiceAccountNotFound
+	}
+
+	return nil
+}
@@ -0,0 +1,73 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestServiceAccounts(t *testing.T) {
+	t.Run("A service account should only get the permissions of the policies bound to it", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		team := createTeam(t, 1, "robots")
+		addTeamMember(t, 1, team.Id, 191)
+		teamPolicy := createPolicy(t, ac, 1, "team", CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:*"})
+		require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: teamPolicy.ID}))
+		rolePolicy := createPolicy(t, ac, 1, "admins", CreatePermissionCommand{Action: "users:write", Scope: "users:*"})
+		require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Admin", PolicyID: rolePolicy.ID}))
+		policy := createPolicy(t, ac, 1, "deployer", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:uid:abc"})
+
+		user := &models.SignedInUser{OrgId: 1, UserId: 191, OrgRole: models.ROLE_ADMIN}
+		ok, err := ac.evaluate(context.Background(), user, accessRequest{Action: "datasources:read", Scope: "datasources:id:1"})
+		require.NoError(t, err)
+		require.True(t, ok)
+
+		err = ac.AddServiceAccountPolicy(context.Background(), AddServiceAccountPolicyCommand{OrgID: 1, UserID: 191, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrServiceAccountNotFound)
+
+		_, err = ac.CreateServiceAccount(context.Background(), CreateServiceAccountCommand{OrgID: 1, UserID: 191})
+		require.NoError(t, err)
+		_, err = ac.CreateServiceAccount(context.Background(), CreateServiceAccountCommand{OrgID: 1, UserID: 191})
+		require.ErrorIs(t, err, ErrServiceAccountAlreadyExists)
+		require.NoError(t, ac.AddServiceAccountPolicy(context.Background(), AddServiceAccountPolicyCommand{OrgID: 1, UserID: 191, PolicyID: policy.ID}))
+
+		permissions, err := ac.GetUserPermissions(context.Background(), GetUserPermissionsQuery{OrgID: 1, UserID: 191, Roles: BuiltinRoles(user)})
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "dashboards:write", permissions[0].Action)
+
+		policies, err := ac.GetServiceAccountPolicies(context.Background(), GetServiceAccountPoliciesQuery{OrgID: 1, UserID: 191})
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+		assert.Equal(t, policy.ID, policies[0].ID)
+
+		require.NoError(t, ac.RemoveServiceAccountPolicy(context.Background(), RemoveServiceAccountPolicyCommand{OrgID: 1, UserID: 191, PolicyID: policy.ID}))
+		permissions, err = ac.GetUserPermissions(context.Background(), GetUserPermissionsQuery{OrgID: 1, UserID: 191, Roles: BuiltinRoles(user)})
+		require.NoError(t, err)
+		assert.Empty(t, permissions)
+	})
+
+	t.Run("Deleting a service account should restore the policies of its role and teams", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		rolePolicy := createPolicy(t, ac, 1, "viewers", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+		require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: rolePolicy.ID}))
+		policy := createPolicy(t, ac, 1, "deployer", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+		_, err := ac.CreateServiceAccount(context.Background(), CreateServiceAccountCommand{OrgID: 1, UserID: 192})
+		require.NoError(t, err)
+		require.NoError(t, ac.AddServiceAccountPolicy(context.Background(), AddServiceAccountPolicyCommand{OrgID: 1, UserID: 192, PolicyID: policy.ID}))
+
+		require.NoError(t, ac.DeleteServiceAccount(context.Background(), DeleteServiceAccountCommand{OrgID: 1, UserID: 192}))
+		err = ac.DeleteServiceAccount(context.Background(), DeleteServiceAccountCommand{OrgID: 1, UserID: 192})
+		require.ErrorIs(t, err, ErrServiceAccountNotFound)
+
+		permissions, err := ac.GetUserPermissions(context.Background(), GetUserPermissionsQuery{OrgID: 1, UserID: 192, Roles: []string{"Viewer"}})
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "dashboards:read", permissions[0].Action)
+	})
+}
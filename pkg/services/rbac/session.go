@@ -0,0 +1,98 @@
+package rbac
+
+import (
+	"context"
+	"strconv"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/network"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// Attributes describing how the request being authorized was authenticated.
+const (
+	// AttributeSessionType is one of the SessionType values.
+	AttributeSessionType = "session.type"
+	// AttributeDeviceTrusted is "true" when a trusted proxy vouched for the client device, see the
+	// rbac.device_trust_header setting, and "false" otherwise.
+	AttributeDeviceTrusted = "device.trusted"
+)
+
+// Values of the session.type attribute.
+const (
+	SessionTypeInteractive = "interactive"
+	SessionTypeAPIKey      = "api_key"
+	SessionTypeRender      = "render"
+	SessionTypeAnonymous   = "anonymous"
+	// SessionTypeOther covers the other authentication methods, e.g. basic auth and auth proxy.
+	SessionTypeOther = "other"
+)
+
+type requestAttributesKey struct{}
+
+// withRequestAttributes returns a copy of the context carrying attributes of the request being authorized.
+func withRequestAttributes(ctx context.Context, attrs Attributes) context.Context {
+	return context.WithValue(ctx, requestAttributesKey{}, attrs)
+}
+
+func requestAttributesFromContext(ctx context.Context) Attributes {
+	attrs, _ := ctx.Value(requestAttributesKey{}).(Attributes)
+	return attrs
+}
+
+// RequestContext returns the context of the request carrying its client address, its session type and
+// device trust attributes and, for requests authenticated by a login session, when the user logged in.
+// A decision cache, the permission claim of the login session and the request ID are attached to the
+// request the first time, shared by all of its access checks.
+func (ac *RBACService) RequestContext(c *models.ReqContext) context.Context {
+	if decisionCacheFromContext(c.Req.Context()) == nil {
+		ctx := ac.withSessionPermissions(WithDecisionCache(c.Req.Context()), c)
+		ctx = withRequestID(ctx, c)
+		c.Req.Request = c.Req.WithContext(ctx)
+	}
+
+	ctx := WithRemoteAddr(c.Req.Context(), ac.ClientAddr(c.Req.Request))
+	ctx = withRequestAttributes(ctx, ac.sessionAttributes(c))
+	if c.UserToken != nil {
+		ctx = WithAuthTime(ctx, time.Unix(c.UserToken.CreatedAt, 0))
+	}
+
+	return ctx
+}
+
+// sessionAttributes returns the session type and device trust attributes of the request.
+func (ac *RBACService) sessionAttributes(c *models.ReqContext) Attributes {
+	sessionType := SessionTypeOther
+	switch {
+	case c.IsRenderCall:
+		sessionType = SessionTypeRender
+	case c.ApiKeyId > 0:
+		sessionType = SessionTypeAPIKey
+	case c.UserToken != nil:
+		sessionType = SessionTypeInteractive
+	case c.IsAnonymous:
+		sessionType = SessionTypeAnonymous
+	}
+
+	return Attributes{
+		AttributeSessionType:   {sessionType},
+		AttributeDeviceTrusted: {strconv.FormatBool(ac.isTrustedDevice(c))},
+	}
+}
+
+// isTrustedDevice returns true if a trusted proxy set the device trust header to true. The header is
+// ignored on requests that don't come through a trusted proxy, since clients can set it to any value.
+func (ac *RBACService) isTrustedDevice(c *models.ReqContext) bool {
+	header := ac.Cfg.Raw.Section("rbac").Key("device_trust_header").String()
+	if header == "" {
+		return false
+	}
+	ip, err := network.GetIPFromAddress(c.Req.RemoteAddr)
+	if err != nil || !ac.isTrustedProxy(ip) {
+		return false
+	}
+
+	trusted, err := strconv.ParseBool(strings.TrimSpace(c.Req.Header.Get(header)))
+	return err == nil && trusted
+}
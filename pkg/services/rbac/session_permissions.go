@@ -0,0 +1,351 @@
+package rbac
+
+import (
+	"context"
+	"crypto/hmac"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	"github.com/grafana/grafana/pkg/middleware/cookies"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/setting"
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// SessionPermissionsCookieName is the cookie carrying the signed permissions of a login session.
+const SessionPermissionsCookieName = "grafana_rbac_permissions"
+
+// maxSessionPermissionsSize is the largest claim written to the cookie, browsers drop cookies over
+// 4KB. Users with more permissions are resolved as usual.
+const maxSessionPermissionsSize = 3800
+
+// sessionPermissionsInstanceKey prefixes the remote cache keys the running instances issuing claims
+// are registered under.
+const sessionPermissionsInstanceKey = "rbac-session-permissions-instance-"
+
+// sessionPermissionsInstanceInterval is how often an instance registers again, the registration
+// expires after sessionPermissionsInstanceTTL so that the instances that crashed are forgotten.
+const sessionPermissionsInstanceInterval = time.Minute
+const sessionPermissionsInstanceTTL = 3 * sessionPermissionsInstanceInterval
+
+var errInvalidSessionPermissions = errors.New("invalid session permissions")
+
+// sessionPermissions signs the permissions of users into their login session for the time set by
+// the rbac.session_permissions_ttl setting, so that the access checks of their requests neither query
+// the database nor the permission cache. A claim is bound to the session token, the organization and
+// the built-in roles of the user, and to a revision increased by the invalidations of the user's
+// permissions. Revisions are kept in memory, so a claim is only used by the instance that issued it,
+// and the changes made on other instances of a HA setup are seen when they sync their permission
+// cache, or once the claim expires. The claims of instances that aren't running anymore, such as the
+// instance before a restart, are reissued.
+type sessionPermissions struct {
+	ttl        time.Duration
+	instanceID string
+
+	mu         sync.Mutex
+	generation uint64
+	orgs       map[int64]uint64
+	users      map[string]uint64
+	// instances holds when the other instances were last seen registered.
+	instances map[string]time.Time
+}
+
+// sessionPermissionsClaim is the payload of the cookie, with short field names to keep it small.
+type sessionPermissionsClaim struct {
+	Instance    string              `json:"i"`
+	Revision    string              `json:"r"`
+	TokenID     int64               `json:"t"`
+	OrgID       int64               `json:"o"`
+	UserID      int64               `json:"u"`
+	Roles       []string            `json:"b"`
+	Expires     int64               `json:"x"`
+	Permissions []sessionPermission `json:"p"`
+}
+
+// sessionPermission holds what evaluating a permission needs.
+type sessionPermission struct {
+	ID         int64       `json:"n,omitempty"`
+	Action     string      `json:"a"`
+	Scope      string      `json:"s,omitempty"`
+	Kind       string      `json:"k,omitempty"`
+	Conditions []Condition `json:"c,omitempty"`
+	ExpiresAt  *time.Time  `json:"e,omitempty"`
+	Precedence *int        `json:"p,omitempty"`
+}
+
+type sessionPermissionsKey struct{}
+
+// loadSessionPermissionsSettings reads the lifetime of the session permission claims, a zero lifetime
+// disables them.
+func (ac *RBACService) loadSessionPermissionsSettings() {
+	ttl := ac.Cfg.Raw.Section("rbac").Key("session_permissions_ttl").MustDuration(0)
+	if ttl <= 0 {
+		ac.sessionPermissions = nil
+		return
+	}
+
+	ac.sessionPermissions = &sessionPermissions{
+		ttl:        ttl,
+		instanceID: util.GenerateShortUID(),
+		orgs:       map[int64]uint64{},
+		users:      map[string]uint64{},
+		instances:  map[string]time.Time{},
+	}
+}
+
+// registerSessionPermissionsInstance registers the instance in the remote cache as running, so that
+// the other instances of a HA setup leave its claims to it.
+func (ac *RBACService) registerSessionPermissionsInstance() {
+	if ac.sessionPermissions == nil || ac.RemoteCache == nil {
+		return
+	}
+
+	key := sessionPermissionsInstanceKey + ac.sessionPermissions.instanceID
+	if err := ac.RemoteCache.Set(key, true, sessionPermissionsInstanceTTL); err != nil {
+		ac.log.Warn("Failed to register session permissions instance", "error", err)
+	}
+}
+
+// unregisterSessionPermissionsInstance removes the registration of the instance when it shuts down,
+// so that its claims are reissued right away.
+func (ac *RBACService) unregisterSessionPermissionsInstance() {
+	if ac.sessionPermissions == nil || ac.RemoteCache == nil {
+		return
+	}
+
+	if err := ac.RemoteCache.Delete(sessionPermissionsInstanceKey + ac.sessionPermissions.instanceID); err != nil {
+		ac.log.Warn("Failed to unregister session permissions instance", "error", err)
+	}
+}
+
+// runningSessionPermissionsInstance returns true if another instance is registered as running. Without
+// a remote cache no other instance is known. An instance seen registered is checked again after
+// sessionPermissionsInstanceInterval.
+func (ac *RBACService) runningSessionPermissionsInstance(instanceID string) bool {
+	s := ac.sessionPermissions
+	if ac.RemoteCache == nil {
+		return false
+	}
+
+	s.mu.Lock()
+	seen, ok := s.instances[instanceID]
+	s.mu.Unlock()
+	if ok && time.Since(seen) < sessionPermissionsInstanceInterval {
+		return true
+	}
+
+	_, err := ac.RemoteCache.Get(sessionPermissionsInstanceKey + instanceID)
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	if err != nil {
+		if !errors.Is(err, remotecache.ErrCacheItemNotFound) {
+			ac.log.Warn("Failed to check session permissions instance", "error", err)
+		}
+		delete(s.instances, instanceID)
+		return false
+	}
+	s.instances[instanceID] = time.Now()
+	return true
+}
+
+// revision returns the current revision of the permissions of a user in an organization.
+func (s *sessionPermissions) revision(orgID, userID int64) string {
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	return fmt.Sprintf("%d.%d.%d", s.generation, s.orgs[orgID], s.users[fmt.Sprintf("%d:%d", orgID, userID)])
+}
+
+// invalidate increases the revision of a user in an organization, so that their claims stop being
+// used. A zero user invalidates every user of the organization, a zero organization every organization.
+func (s *sessionPermissions) invalidate(orgID, userID int64) {
+	if s == nil {
+		return
+	}
+
+	s.mu.Lock()
+	defer s.mu.Unlock()
+	switch {
+	case orgID == 0:
+		s.generation++
+	case userID == 0:
+		s.orgs[orgID]++
+	default:
+		s.users[fmt.Sprintf("%d:%d", orgID, userID)]++
+	}
+}
+
+func (s *sessionPermissions) sign(payload string) string {
+	mac := hmac.New(sha256.New, []byte(setting.SecretKey))
+	mac.Write([]byte(payload))
+	return base64.RawURLEncoding.EncodeToString(mac.Sum(nil))
+}
+
+func (s *sessionPermissions) encode(claim *sessionPermissionsClaim) (string, error) {
+	data, err := json.Marshal(claim)
+	if err != nil {
+		return "", err
+	}
+
+	payload := base64.RawURLEncoding.EncodeToString(data)
+	return payload + "." + s.sign(payload), nil
+}
+
+// decode verifies the signature of a cookie value and returns its claim.
+func (s *sessionPermissions) decode(value string) (*sessionPermissionsClaim, error) {
+	parts := strings.Split(value, ".")
+	if len(parts) != 2 || !hmac.Equal([]byte(parts[1]), []byte(s.sign(parts[0]))) {
+		return nil, errInvalidSessionPermissions
+	}
+
+	data, err := base64.RawURLEncoding.DecodeString(parts[0])
+	if err != nil {
+		return nil, errInvalidSessionPermissions
+	}
+	var claim sessionPermissionsClaim
+	if err := json.Unmarshal(data, &claim); err != nil {
+		return nil, errInvalidSessionPermissions
+	}
+
+	return &claim, nil
+}
+
+// valid returns true if a claim can be used for the session and the user of a request.
+func (s *sessionPermissions) valid(claim *sessionPermissionsClaim, tokenID int64, user *models.SignedInUser) bool {
+	return claim.Instance == s.instanceID && claim.TokenID == tokenID && time.Now().Unix() < claim.Expires &&
+		claim.OrgID == user.OrgId && claim.UserID == user.UserId &&
+		strings.Join(claim.Roles, ",") == strings.Join(BuiltinRoles(user), ",") &&
+		claim.Revision == s.revision(user.OrgId, user.UserId)
+}
+
+// permissionsFor returns the permissions of a claim when it's the claim of a user, without the
+// permissions that expired since it was issued.
+func (claim *sessionPermissionsClaim) permissionsFor(user *models.SignedInUser) ([]Permission, bool) {
+	if claim == nil || claim.OrgID != user.OrgId || claim.UserID != user.UserId ||
+		strings.Join(claim.Roles, ",") != strings.Join(BuiltinRoles(user), ",") {
+		return nil, false
+	}
+
+	now := time.Now()
+	permissions := make([]Permission, 0, len(claim.Permissions))
+	for _, p := range claim.Permissions {
+		if p.ExpiresAt != nil && !p.ExpiresAt.After(now) {
+			continue
+		}
+		permissions = append(permissions, Permission{
+			ID: p.ID, Action: p.Action, Scope: p.Scope, Kind: p.Kind, Conditions: p.Conditions,
+			ExpiresAt: p.ExpiresAt, Precedence: p.Precedence,
+		})
+	}
+
+	return permissions, true
+}
+
+func sessionPermissionsFromContext(ctx context.Context) *sessionPermissionsClaim {
+	claim, _ := ctx.Value(sessionPermissionsKey{}).(*sessionPermissionsClaim)
+	return claim
+}
+
+// IssueSessionPermissions signs the permissions of a user into the login session of a request, when
+// session permission claims are enabled. It's called when the user signs in, when their signed in user
+// isn't on the request yet. Failures are only logged, the permissions are then resolved as usual.
+func (ac *RBACService) IssueSessionPermissions(c *models.ReqContext, userID, orgID int64) {
+	if ac.sessionPermissions == nil || c.UserToken == nil || ac.IsDegraded() {
+		return
+	}
+
+	query := models.GetSignedInUserQuery{UserId: userID, OrgId: orgID}
+	if err := ac.SQLStore.GetSignedInUserWithCache(&query); err != nil {
+		ac.log.Warn("Failed to issue session permissions", "userId", userID, "orgId", orgID, "error", err)
+		return
+	}
+
+	if _, err := ac.issueSessionPermissions(c.Req.Context(), c, query.Result); err != nil {
+		ac.log.Warn("Failed to issue session permissions", "userId", userID, "orgId", orgID, "error", err)
+	}
+}
+
+// withSessionPermissions returns a copy of the context carrying the permission claim of the login
+// session of a request. A missing, expired or outdated claim is reissued, except for the claims issued
+// by other running instances, which are left to them.
+func (ac *RBACService) withSessionPermissions(ctx context.Context, c *models.ReqContext) context.Context {
+	s := ac.sessionPermissions
+	if s == nil || c.UserToken == nil || c.SignedInUser == nil || c.UserId == 0 || ac.IsDegraded() {
+		return ctx
+	}
+
+	if value := c.GetCookie(SessionPermissionsCookieName); value != "" {
+		claim, err := s.decode(value)
+		if err == nil && s.valid(claim, c.UserToken.Id, c.SignedInUser) {
+			return context.WithValue(ctx, sessionPermissionsKey{}, claim)
+		}
+		if err == nil && claim.Instance != s.instanceID && claim.TokenID == c.UserToken.Id &&
+			time.Now().Unix() < claim.Expires && ac.runningSessionPermissionsInstance(claim.Instance) {
+			return ctx
+		}
+	}
+
+	claim, err := ac.issueSessionPermissions(ctx, c, c.SignedInUser)
+	if err != nil {
+		ac.log.Warn("Failed to issue session permissions", "userId", c.UserId, "orgId", c.OrgId, "error", err)
+		return ctx
+	}
+	if claim == nil {
+		return ctx
+	}
+
+	return context.WithValue(ctx, sessionPermissionsKey{}, claim)
+}
+
+// issueSessionPermissions resolves the permissions of a user and writes their claim to the session
+// cookie. It returns nil when the claim doesn't fit in a cookie.
+func (ac *RBACService) issueSessionPermissions(ctx context.Context, c *models.ReqContext, user *models.SignedInUser) (*sessionPermissionsClaim, error) {
+	s := ac.sessionPermissions
+	// The revision is read first, so that an invalidation while the permissions are resolved makes
+	// the claim outdated.
+	revision := s.revision(user.OrgId, user.UserId)
+	roles := BuiltinRoles(user)
+	permissions, err := ac.getUserPermissions(ctx, GetUserPermissionsQuery{OrgID: user.OrgId, UserID: user.UserId, Roles: roles})
+	if err != nil {
+		return nil, err
+	}
+
+	claim := &sessionPermissionsClaim{
+		Instance:    s.instanceID,
+		Revision:    revision,
+		TokenID:     c.UserToken.Id,
+		OrgID:       user.OrgId,
+		UserID:      user.UserId,
+		Roles:       roles,
+		Expires:     time.Now().Add(s.ttl).Unix(),
+		Permissions: make([]sessionPermission, 0, len(permissions)),
+	}
+	for _, p := range permissions {
+		claim.Permissions = append(claim.Permissions, sessionPermission{
+			ID: p.ID, Action: p.Action, Scope: p.Scope, Kind: p.Kind, Conditions: p.Conditions,
+			ExpiresAt: p.ExpiresAt, Precedence: p.Precedence,
+		})
+	}
+
+	value, err := s.encode(claim)
+	if err != nil {
+		return nil, err
+	}
+	if len(value) > maxSessionPermissionsSize {
+		ac.log.Debug("Session permissions don't fit in a cookie", "userId", user.UserId, "orgId", user.OrgId,
+			"permissions", len(permissions))
+		if c.GetCookie(SessionPermissionsCookieName) != "" {
+			cookies.DeleteCookie(c.Resp, SessionPermissionsCookieName, nil)
+		}
+		return nil, nil
+	}
+
+	cookies.WriteCookie(c.Resp, SessionPermissionsCookieName, value, int(s.ttl.Seconds()), nil)
+	return claim, nil
+}
@@ -0,0 +1,122 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	macaron "gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/infra/remotecache"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestSessionPermissions(t *testing.T) {
+	ac := setupTestEnv(t)
+	// The fake store resets the test database, so it's created first and only used by the subtest
+	// checking the instances that issued the claims.
+	remoteCache := remotecache.NewFakeStore(t)
+	_, err := ac.Cfg.Raw.Section("rbac").NewKey("session_permissions_ttl", "5m")
+	require.NoError(t, err)
+	ac.loadSessionPermissionsSettings()
+	require.NotNil(t, ac.sessionPermissions)
+
+	policy := createPolicy(t, ac, 1, "session", CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 494, PolicyID: policy.ID}))
+
+	// request authorizes a request of the session with a permission cookie, returning the cookie the
+	// response sets, if any.
+	request := func(t *testing.T, tokenID int64, cookie string) (bool, string) {
+		t.Helper()
+		req, err := http.NewRequest("GET", "/api/dashboards/uid/home", nil)
+		require.NoError(t, err)
+		if cookie != "" {
+			req.AddCookie(&http.Cookie{Name: SessionPermissionsCookieName, Value: cookie})
+		}
+		rec := httptest.NewRecorder()
+		c := &models.ReqContext{
+			Context:      &macaron.Context{Req: macaron.Request{Request: req}, Resp: macaron.NewResponseWriter("GET", rec)},
+			SignedInUser: &models.SignedInUser{OrgId: 1, UserId: 494},
+			UserToken:    &models.UserToken{Id: tokenID, UserId: 494},
+		}
+
+		ok, err := ac.HasPermission(ac.RequestContext(c), c.SignedInUser, ActionDashboardsRead, "dashboards:uid:home")
+		require.NoError(t, err)
+		for _, issued := range (&http.Response{Header: rec.Header()}).Cookies() {
+			if issued.Name == SessionPermissionsCookieName {
+				return ok, issued.Value
+			}
+		}
+		return ok, ""
+	}
+	unbind := func(t *testing.T) {
+		t.Helper()
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Exec("DELETE FROM user_policy WHERE user_id = ?", 494)
+			return err
+		})
+		require.NoError(t, err)
+	}
+
+	ok, cookie := request(t, 1, "")
+	require.True(t, ok)
+	require.NotEmpty(t, cookie)
+
+	t.Run("Permissions should be read from the session until they change", func(t *testing.T) {
+		unbind(t)
+		ok, reissued := request(t, 1, cookie)
+		assert.True(t, ok)
+		assert.Empty(t, reissued)
+
+		ac.publishPermissionsChanged(1, 494, "test")
+		ok, reissued = request(t, 1, cookie)
+		assert.False(t, ok)
+		assert.NotEmpty(t, reissued)
+	})
+
+	t.Run("Claims of other sessions and tampered claims should be ignored", func(t *testing.T) {
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 494, PolicyID: policy.ID}))
+		_, cookie := request(t, 1, "")
+		unbind(t)
+
+		ok, reissued := request(t, 2, cookie)
+		assert.False(t, ok)
+		assert.NotEmpty(t, reissued)
+
+		ok, _ = request(t, 1, cookie[:len(cookie)-2]+"xx")
+		assert.False(t, ok)
+	})
+	t.Run("Claims of instances that aren't running should be reissued", func(t *testing.T) {
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 494, PolicyID: policy.ID}))
+		ok, cookie := request(t, 1, "")
+		require.True(t, ok)
+		previous := ac.sessionPermissions.instanceID
+		t.Cleanup(func() {
+			ac.sessionPermissions.instanceID = previous
+			ac.RemoteCache = nil
+		})
+
+		// Restarted without a remote cache, no other instance is known.
+		ac.sessionPermissions.instanceID = "restarted"
+		ok, reissued := request(t, 1, cookie)
+		assert.True(t, ok)
+		assert.NotEmpty(t, reissued)
+
+		ac.RemoteCache = remoteCache
+		ok, reissued = request(t, 1, cookie)
+		assert.True(t, ok)
+		assert.NotEmpty(t, reissued)
+
+		// The claims of a running instance are left to it.
+		ac.sessionPermissions.instanceID = previous
+		ac.registerSessionPermissionsInstance()
+		ac.sessionPermissions.instanceID = "other"
+		ok, reissued = request(t, 1, cookie)
+		assert.True(t, ok)
+		assert.Empty(t, reissued)
+	})
+}
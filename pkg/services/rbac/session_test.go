@@ -0,0 +1,67 @@
+package rbac
+
+import (
+	"context"
+	"net/http"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	macaron "gopkg.in/macaron.v1"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestSessionConditions(t *testing.T) {
+	ac := setupTestEnv(t)
+	_, err := ac.Cfg.Raw.Section("rbac").NewKey("trusted_proxies", "10.1.0.0/16")
+	require.NoError(t, err)
+	_, err = ac.Cfg.Raw.Section("rbac").NewKey("device_trust_header", "X-Device-Trusted")
+	require.NoError(t, err)
+	require.NoError(t, ac.loadTrustedProxies())
+
+	team := createTeam(t, 1, "editors")
+	addTeamMember(t, 1, team.Id, 151)
+	policy := createPolicy(t, ac, 1, "editor",
+		CreatePermissionCommand{Action: ActionDashboardsRead, Scope: "dashboards:*"},
+		CreatePermissionCommand{Action: ActionDashboardsWrite, Scope: "dashboards:*", Conditions: []Condition{
+			{Attribute: AttributeSessionType, Values: []string{SessionTypeInteractive}},
+			{Attribute: AttributeDeviceTrusted, Values: []string{"true"}},
+		}},
+	)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	reqContext := func(remoteAddr string, deviceTrusted string, apiKey bool) *models.ReqContext {
+		req, err := http.NewRequest("GET", "/api/dashboards/uid/abc", nil)
+		require.NoError(t, err)
+		req.RemoteAddr = remoteAddr
+		if deviceTrusted != "" {
+			req.Header.Set("X-Device-Trusted", deviceTrusted)
+		}
+		c := &models.ReqContext{
+			Context:      &macaron.Context{Req: macaron.Request{Request: req}},
+			SignedInUser: &models.SignedInUser{OrgId: 1, UserId: 151},
+		}
+		if apiKey {
+			c.ApiKeyId = 3
+		} else {
+			c.UserToken = &models.UserToken{UserId: 151}
+		}
+		return c
+	}
+	canWrite := func(c *models.ReqContext) bool {
+		ok, err := ac.Evaluate(ac.RequestContext(c), c.SignedInUser, Perm(ActionDashboardsWrite, "dashboards:uid:abc"))
+		require.NoError(t, err)
+		return ok
+	}
+
+	assert.True(t, canWrite(reqContext("10.1.0.2:5000", "true", false)))
+	assert.False(t, canWrite(reqContext("10.1.0.2:5000", "false", false)))
+	assert.False(t, canWrite(reqContext("10.1.0.2:5000", "true", true)), "API keys should stay read-only")
+	assert.False(t, canWrite(reqContext("203.0.113.9:5000", "true", false)), "untrusted clients can't vouch for their device")
+
+	c := reqContext("203.0.113.9:5000", "", true)
+	ok, err := ac.Evaluate(ac.RequestContext(c), c.SignedInUser, Perm(ActionDashboardsRead, "dashboards:uid:abc"))
+	require.NoError(t, err)
+	assert.True(t, ok)
+}
@@ -0,0 +1,99 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+	"strings"
+
+	"github.com/prometheus/client_golang/prometheus"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+// Results of the comparisons of shadow mode, see CompareDecision.
+const (
+	// ShadowResultMatch is the result of the access checks RBAC and legacy access control agree on.
+	ShadowResultMatch = "match"
+	// ShadowResultLegacyOnly is the result of the access checks only legacy access control allows,
+	// those that would be denied once RBAC is enforced.
+	ShadowResultLegacyOnly = "legacy_only"
+	// ShadowResultRBACOnly is the result of the access checks only RBAC allows.
+	ShadowResultRBACOnly = "rbac_only"
+)
+
+var shadowDecisions = prometheus.NewCounterVec(prometheus.CounterOpts{
+	Namespace: "grafana",
+	Subsystem: "rbac",
+	Name:      "shadow_decisions_total",
+	Help:      "Access checks on resource types in shadow mode by resource type and comparison with legacy access control",
+}, []string{"resource_type", "result"})
+
+func init() {
+	prometheus.MustRegister(shadowDecisions)
+}
+
+// CompareDecision compares the RBAC decision of an access check on resource types in shadow mode
+// with the decision of legacy access control, which is returned. Mismatches are logged with the
+// user, the requirement and both decisions, and every comparison is counted by the
+// grafana_rbac_shadow_decisions_total metric. Decisions of other modes are returned unchanged.
+func (ac *RBACService) CompareDecision(user *models.SignedInUser, evaluator Evaluator, decision *Decision, legacyAllowed bool) bool {
+	if !decision.Shadow {
+		return decision.Allowed
+	}
+
+	result := ShadowResultMatch
+	switch {
+	case legacyAllowed && !decision.Allowed:
+		result = ShadowResultLegacyOnly
+	case !legacyAllowed && decision.Allowed:
+		result = ShadowResultRBACOnly
+	}
+	resourceTypes := evaluatorResourceTypes(evaluator)
+	shadowDecisions.WithLabelValues(resourceTypes, result).Inc()
+
+	if result != ShadowResultMatch {
+		logCtx := []interface{}{"userId", user.UserId, "orgId", user.OrgId, "orgRole", user.OrgRole,
+			"apiKeyId", user.ApiKeyId, "evaluator", evaluator.String(), "resourceTypes", resourceTypes,
+			"legacyAllowed", legacyAllowed, "rbacAllowed", decision.Allowed, "result", result}
+		for k, v := range decision.Annotations {
+			logCtx = append(logCtx, k, v)
+		}
+		ac.log.Warn("Shadow access decision mismatch", logCtx...)
+	}
+
+	return legacyAllowed
+}
+
+// CompareLegacyDecision decides an access check with RBAC and compares it with the decision of legacy
+// access control, e.g. an organization role check, see CompareDecision. It lets the checks that
+// don't go through the Authorize middleware run in shadow mode. The legacy decision is returned,
+// RBAC failures are logged.
+func (ac *RBACService) CompareLegacyDecision(ctx context.Context, user *models.SignedInUser, evaluator Evaluator, legacyAllowed bool) bool {
+	if !ac.IsEnabled() || !ac.isShadowed(evaluator) {
+		return legacyAllowed
+	}
+
+	decision, err := ac.Decide(ctx, user, evaluator)
+	if err != nil {
+		ac.log.Warn("Failed to decide shadow access check", "evaluator", evaluator.String(), "error", err)
+		return legacyAllowed
+	}
+
+	return ac.CompareDecision(user, evaluator, decision, legacyAllowed)
+}
+
+// evaluatorResourceTypes returns the sorted resource types of the actions of an evaluator, comma
+// separated.
+func evaluatorResourceTypes(evaluator Evaluator) string {
+	seen := map[string]bool{}
+	var types []string
+	for _, p := range evaluatorPermissions(evaluator) {
+		if t := resourceType(p.Action); !seen[t] {
+			seen[t] = true
+			types = append(types, t)
+		}
+	}
+	sort.Strings(types)
+
+	return strings.Join(types, ",")
+}
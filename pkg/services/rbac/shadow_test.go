@@ -0,0 +1,65 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/prometheus/client_golang/prometheus/testutil"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestShadowMode(t *testing.T) {
+	ac := setupTestEnv(t)
+	_, err := ac.Cfg.Raw.Section("rbac.resource_modes").NewKey("teams", ResourceModeShadow)
+	require.NoError(t, err)
+	require.NoError(t, ac.loadResourceModes())
+	assert.Equal(t, ResourceModeShadow, ac.ResourceMode("teams"))
+
+	policy := createPolicy(t, ac, 1, "team reader", CreatePermissionCommand{Action: ActionTeamsRead, Scope: "teams:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 491, PolicyID: policy.ID}))
+	user := &models.SignedInUser{OrgId: 1, UserId: 491, OrgRole: models.ROLE_VIEWER}
+
+	count := func(result string) float64 {
+		return testutil.ToFloat64(shadowDecisions.WithLabelValues("teams", result))
+	}
+
+	t.Run("Access checks on shadowed resource types should be flagged", func(t *testing.T) {
+		decision, err := ac.Decide(context.Background(), user, Perm(ActionTeamsWrite, ScopeTeamID(1)))
+		require.NoError(t, err)
+		assert.True(t, decision.Shadow)
+		assert.False(t, decision.Allowed)
+
+		decision, err = ac.Decide(context.Background(), user, All(Perm(ActionTeamsRead, ScopeTeamID(1)), Perm(ActionDashboardsRead, "dashboards:uid:a")))
+		require.NoError(t, err)
+		assert.False(t, decision.Shadow)
+	})
+
+	t.Run("The legacy decision should be returned and mismatches counted", func(t *testing.T) {
+		matches, legacyOnly, rbacOnly := count(ShadowResultMatch), count(ShadowResultLegacyOnly), count(ShadowResultRBACOnly)
+
+		assert.True(t, ac.CompareLegacyDecision(context.Background(), user, Perm(ActionTeamsRead, ScopeTeamID(1)), true))
+		assert.True(t, ac.CompareLegacyDecision(context.Background(), user, Perm(ActionTeamsWrite, ScopeTeamID(1)), true))
+		assert.False(t, ac.CompareLegacyDecision(context.Background(), user, Perm(ActionTeamsRead, ScopeTeamID(2)), false))
+
+		assert.Equal(t, matches+1, count(ShadowResultMatch))
+		assert.Equal(t, legacyOnly+1, count(ShadowResultLegacyOnly))
+		assert.Equal(t, rbacOnly+1, count(ShadowResultRBACOnly))
+	})
+
+	t.Run("Decisions of other modes should be returned unchanged", func(t *testing.T) {
+		evaluator := Perm(ActionDashboardsRead, "dashboards:uid:a")
+		decision, err := ac.Decide(context.Background(), user, evaluator)
+		require.NoError(t, err)
+		assert.False(t, ac.CompareDecision(user, evaluator, decision, true))
+		assert.True(t, ac.CompareLegacyDecision(context.Background(), user, evaluator, true))
+	})
+
+	t.Run("Shadowed denials should not be recorded", func(t *testing.T) {
+		_, err := ac.Decide(context.Background(), user, Perm(ActionTeamsDelete, ScopeTeamID(1)))
+		require.NoError(t, err)
+		assert.Empty(t, ac.SearchDenials(SearchDenialsQuery{OrgID: 1, UserID: 491, Action: ActionTeamsDelete}))
+	})
+}
@@ -0,0 +1,147 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// SimulateAccess evaluates an access check of a user against the current policies and against an
+// overlay of changes to them, without persisting anything. The added permissions are validated like
+// CreatePermission does. As in ReplayDecisionLog, decision middlewares don't apply.
+func (ac *RBACService) SimulateAccess(ctx context.Context, cmd SimulateAccessCommand) (*SimulationResult, error) {
+	added := make([]Permission, 0, len(cmd.Changes.AddPermissions))
+	for _, sp := range cmd.Changes.AddPermissions {
+		p, err := simulatedPermission(sp)
+		if err != nil {
+			return nil, err
+		}
+		added = append(added, p)
+	}
+
+	user := cmd.User
+	query := GetUserPermissionsQuery{OrgID: user.OrgId, UserID: user.UserId, Roles: BuiltinRoles(user)}
+	current, err := ac.GetUserPermissions(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+	held, err := ac.getUserBoundPolicies(ctx, query)
+	if err != nil {
+		return nil, err
+	}
+
+	removed := map[int64]bool{}
+	for _, id := range cmd.Changes.RemovePermissions {
+		removed[id] = true
+	}
+	unbound := map[int64]bool{}
+	for _, id := range cmd.Changes.UnbindPolicies {
+		unbound[id] = true
+	}
+	// precedences are the precedences of the enabled policies the user holds after the changes.
+	precedences := map[int64]*int{}
+	for _, policy := range held {
+		if policy.Enabled && !unbound[policy.ID] {
+			precedences[policy.ID] = policy.Precedence
+		}
+	}
+
+	var simulated []Permission
+	for _, p := range current {
+		if !removed[p.ID] && !unbound[p.PolicyID] {
+			simulated = append(simulated, p)
+		}
+		if _, ok := precedences[p.PolicyID]; !ok && !unbound[p.PolicyID] {
+			// The suspended policy applies whether it's enabled or not.
+			precedences[p.PolicyID] = p.Precedence
+		}
+	}
+
+	err = ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		now := time.Now()
+		for _, id := range cmd.Changes.BindPolicies {
+			if _, ok := precedences[id]; ok || unbound[id] {
+				continue
+			}
+			policy, err := getPolicy(sess, GetPolicyQuery{OrgID: user.OrgId, PolicyID: id})
+			if err != nil {
+				return err
+			}
+			if !policy.Enabled {
+				continue
+			}
+			precedences[id] = policy.Precedence
+
+			permissions, err := getPolicyPermissions(sess, id)
+			if err != nil {
+				return err
+			}
+			for _, p := range permissions {
+				if removed[p.ID] || (p.ExpiresAt != nil && !p.ExpiresAt.After(now)) {
+					continue
+				}
+				p.Precedence = policy.Precedence
+				simulated = append(simulated, p)
+			}
+		}
+		for _, p := range added {
+			if _, err := getPolicy(sess, GetPolicyQuery{OrgID: user.OrgId, PolicyID: p.PolicyID}); err != nil {
+				return err
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	for _, p := range added {
+		if precedence, ok := precedences[p.PolicyID]; ok {
+			p.Precedence = precedence
+			simulated = append(simulated, p)
+		}
+	}
+
+	if current, err = ac.resolvePermissions(ctx, user, current); err != nil {
+		return nil, err
+	}
+	if simulated, err = ac.resolvePermissions(ctx, user, simulated); err != nil {
+		return nil, err
+	}
+
+	env := ac.environment(ctx, user)
+	evaluator := Perm(cmd.Action, cmd.Scope)
+	result := &SimulationResult{
+		Before: evaluator.Evaluate(current, env),
+		After:  evaluator.Evaluate(simulated, env),
+	}
+	result.Changed = result.Before != result.After
+
+	return result, nil
+}
+
+// simulatedPermission validates a simulated permission like CreatePermission does.
+func simulatedPermission(sp SimulatedPermission) (Permission, error) {
+	if err := validatePermission(sp.Action, sp.Scope); err != nil {
+		return Permission{}, err
+	}
+	if err := validateRegisteredAction(sp.Action); err != nil {
+		return Permission{}, err
+	}
+	if err := validateConditions(sp.Conditions); err != nil {
+		return Permission{}, err
+	}
+	kind, err := normalizePermissionKind(sp.Kind)
+	if err != nil {
+		return Permission{}, err
+	}
+	scope, err := normalizeScope(sp.Scope)
+	if err != nil {
+		return Permission{}, err
+	}
+
+	p := Permission{PolicyID: sp.PolicyID, Action: sp.Action, Kind: kind, Conditions: sp.Conditions}
+	p.setScope(scope)
+	return p, nil
+}
@@ -0,0 +1,121 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestSimulateAccess(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	reader := createPolicy(t, ac, 1, "dashboard reader", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 481, PolicyID: reader.ID}))
+	writer := createPolicy(t, ac, 1, "dashboard writer", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+	other := createPolicy(t, ac, 2, "other org", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+
+	readerPermissions, err := ac.GetPolicy(context.Background(), GetPolicyQuery{OrgID: 1, PolicyID: reader.ID})
+	require.NoError(t, err)
+	require.Len(t, readerPermissions.Permissions, 1)
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 481, OrgRole: models.ROLE_VIEWER}
+
+	t.Run("Adding a permission to a bound policy should allow the check", func(t *testing.T) {
+		result, err := ac.SimulateAccess(context.Background(), SimulateAccessCommand{
+			User: user, Action: "dashboards:write", Scope: "dashboards:uid:home",
+			Changes: SimulatedChanges{AddPermissions: []SimulatedPermission{
+				{PolicyID: reader.ID, Action: "dashboards:write", Scope: "dashboards:uid:home"},
+			}},
+		})
+		require.NoError(t, err)
+		assert.False(t, result.Before)
+		assert.True(t, result.After)
+		assert.True(t, result.Changed)
+
+		// Nothing is persisted.
+		allowed, err := ac.HasPermission(context.Background(), user, "dashboards:write", "dashboards:uid:home")
+		require.NoError(t, err)
+		assert.False(t, allowed)
+	})
+
+	t.Run("Adding a permission to a policy the user doesn't hold should not change the check", func(t *testing.T) {
+		result, err := ac.SimulateAccess(context.Background(), SimulateAccessCommand{
+			User: user, Action: "dashboards:delete", Scope: "dashboards:uid:home",
+			Changes: SimulatedChanges{AddPermissions: []SimulatedPermission{
+				{PolicyID: writer.ID, Action: "dashboards:delete", Scope: "dashboards:*"},
+			}},
+		})
+		require.NoError(t, err)
+		assert.False(t, result.After)
+		assert.False(t, result.Changed)
+	})
+
+	t.Run("A deny added to a bound policy should deny the check", func(t *testing.T) {
+		result, err := ac.SimulateAccess(context.Background(), SimulateAccessCommand{
+			User: user, Action: "dashboards:read", Scope: "dashboards:uid:secret",
+			Changes: SimulatedChanges{AddPermissions: []SimulatedPermission{
+				{PolicyID: reader.ID, Action: "dashboards:read", Scope: "dashboards:uid:secret", Kind: PermissionKindDeny},
+			}},
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Before)
+		assert.False(t, result.After)
+	})
+
+	t.Run("Binding and unbinding policies should change the check", func(t *testing.T) {
+		result, err := ac.SimulateAccess(context.Background(), SimulateAccessCommand{
+			User: user, Action: "dashboards:write", Scope: "dashboards:uid:home",
+			Changes: SimulatedChanges{BindPolicies: []int64{writer.ID}},
+		})
+		require.NoError(t, err)
+		assert.False(t, result.Before)
+		assert.True(t, result.After)
+
+		result, err = ac.SimulateAccess(context.Background(), SimulateAccessCommand{
+			User: user, Action: "dashboards:read", Scope: "dashboards:uid:home",
+			Changes: SimulatedChanges{UnbindPolicies: []int64{reader.ID}},
+		})
+		require.NoError(t, err)
+		assert.True(t, result.Before)
+		assert.False(t, result.After)
+	})
+
+	t.Run("Removing a permission should deny the check", func(t *testing.T) {
+		result, err := ac.SimulateAccess(context.Background(), SimulateAccessCommand{
+			User: user, Action: "dashboards:read", Scope: "dashboards:uid:home",
+			Changes: SimulatedChanges{RemovePermissions: []int64{readerPermissions.Permissions[0].ID}},
+		})
+		require.NoError(t, err)
+		assert.False(t, result.After)
+	})
+
+	t.Run("Policies of other organizations should not be found", func(t *testing.T) {
+		_, err := ac.SimulateAccess(context.Background(), SimulateAccessCommand{
+			User: user, Action: "dashboards:write", Scope: "dashboards:uid:home",
+			Changes: SimulatedChanges{BindPolicies: []int64{other.ID}},
+		})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+
+		_, err = ac.SimulateAccess(context.Background(), SimulateAccessCommand{
+			User: user, Action: "dashboards:write", Scope: "dashboards:uid:home",
+			Changes: SimulatedChanges{AddPermissions: []SimulatedPermission{
+				{PolicyID: other.ID, Action: "dashboards:write", Scope: "dashboards:*"},
+			}},
+		})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+	})
+
+	t.Run("Invalid permissions should be rejected", func(t *testing.T) {
+		_, err := ac.SimulateAccess(context.Background(), SimulateAccessCommand{
+			User: user, Action: "dashboards:write", Scope: "dashboards:uid:home",
+			Changes: SimulatedChanges{AddPermissions: []SimulatedPermission{
+				{PolicyID: reader.ID, Action: "dashboards:unknown", Scope: "dashboards:*"},
+			}},
+		})
+		require.Error(t, err)
+	})
+}
@@ -0,0 +1,287 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+)
+
+// stateTables are the tables holding the RBAC state along with the schema version adding them,
+// in the order they're restored in.
+var stateTables = []struct {
+	name    string
+	version int
+}{
+	{"policy", schemaVersionInitial},
+	{"permission", schemaVersionInitial},
+	{"team_policy", schemaVersionInitial},
+	{"user_suspension", schemaVersionUserSuspension},
+	{"user_policy", schemaVersionUserPolicy},
+	{"builtin_role_policy", schemaVersionBuiltinRolePolicy},
+	{"service_account", schemaVersionServiceAccount},
+	{"api_key_policy", schemaVersionAPIKeyPolicy},
+	{"default_policy", schemaVersionDefaultPolicy},
+	{"role_migration", schemaVersionRoleMigration},
+	{"user_policy_sync", schemaVersionUserPolicySync},
+	{"binding_lifetime", schemaVersionBindingLifetime},
+	{"user_policy_expiry_notice", schemaVersionBindingLifetime},
+	{"external_group_policy", schemaVersionExternalGroups},
+	{"external_group_member", schemaVersionExternalGroups},
+	{"policy_group_mapping", schemaVersionPolicyGroupMapping},
+	{"break_glass_grant", schemaVersionBreakGlass},
+	{"access_request", schemaVersionAccessRequest},
+	{"enforcement_mode", schemaVersionEnforcementMode},
+}
+
+// ErrInvalidStateExport is an error for when an RBAC state export can't be restored or verified,
+// e.g. because it was altered or was taken at another schema version.
+var ErrInvalidStateExport = errors.New("invalid RBAC state export")
+
+// ErrStateMismatch is an error for when the RBAC state of a database doesn't match an export.
+var ErrStateMismatch = errors.New("RBAC state doesn't match the export")
+
+// StateExport is a point-in-time export of the RBAC state of every organization: the policies,
+// their permissions and every kind of binding, for restoring onto a standby instance.
+type StateExport struct {
+	Exported time.Time `json:"exported"`
+	// SchemaVersion is the RBAC schema version of the source, the export can only be restored
+	// onto a database at the same version.
+	SchemaVersion int           `json:"schemaVersion"`
+	Tables        []TableExport `json:"tables"`
+}
+
+// TableExport holds the rows of an RBAC table, ordered by id, and their checksum.
+type TableExport struct {
+	Name     string                   `json:"name"`
+	Checksum string                   `json:"checksum"`
+	Rows     []map[string]interface{} `json:"rows"`
+}
+
+// StateVerification compares the RBAC state of a database with an export, table by table.
+type StateVerification struct {
+	SchemaVersion int                 `json:"schemaVersion"`
+	Tables        []TableVerification `json:"tables"`
+}
+
+// TableVerification compares the checksum of an RBAC table with the checksum in an export.
+type TableVerification struct {
+	Name     string `json:"name"`
+	Expected string `json:"expected"`
+	Actual   string `json:"actual"`
+	Rows     int    `json:"rows"`
+}
+
+// Matches returns true if the table has the same rows as in the export.
+func (v TableVerification) Matches() bool {
+	return v.Expected == v.Actual
+}
+
+// Matches returns true if every table has the same rows as in the export.
+func (v *StateVerification) Matches() bool {
+	for _, t := range v.Tables {
+		if !t.Matches() {
+			return false
+		}
+	}
+	return true
+}
+
+// ExportState exports the RBAC state within a single transaction, so that the export is consistent
+// even while the source keeps being written to.
+func (ac *RBACService) ExportState(ctx context.Context) (*StateExport, error) {
+	export := &StateExport{Exported: time.Now().UTC()}
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if ac.SQLStore.Dialect.DriverName() == migrator.Postgres {
+			// Every query of the transaction then sees the same snapshot, MySQL does by default and
+			// SQLite transactions are serializable.
+			if _, err := sess.Exec("SET TRANSACTION ISOLATION LEVEL REPEATABLE READ"); err != nil {
+				return err
+			}
+		}
+
+		applied, err := appliedMigrations(sess)
+		if err != nil {
+			return err
+		}
+		export.SchemaVersion = currentSchemaVersion(applied)
+
+		for _, t := range stateTables {
+			if t.version > export.SchemaVersion {
+				continue
+			}
+			rows, err := stateTableRows(sess, t.name)
+			if err != nil {
+				return err
+			}
+			checksum, err := stateChecksum(rows)
+			if err != nil {
+				return err
+			}
+			export.Tables = append(export.Tables, TableExport{Name: t.name, Checksum: checksum, Rows: rows})
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return export, nil
+}
+
+// ReadStateExport reads an export written as JSON and checks that its rows match their checksums.
+func ReadStateExport(r io.Reader) (*StateExport, error) {
+	decoder := json.NewDecoder(r)
+	// Ids and timestamps in nanoseconds don't fit in a float64.
+	decoder.UseNumber()
+
+	export := &StateExport{}
+	if err := decoder.Decode(export); err != nil {
+		return nil, fmt.Errorf("%w: %s", ErrInvalidStateExport, err)
+	}
+	for _, t := range export.Tables {
+		checksum, err := stateChecksum(t.Rows)
+		if err != nil {
+			return nil, err
+		}
+		if checksum != t.Checksum {
+			return nil, fmt.Errorf("%w: rows of table %s don't match their checksum", ErrInvalidStateExport, t.Name)
+		}
+	}
+
+	return export, nil
+}
+
+// VerifyState compares the RBAC state of the database with an export checksum by checksum, e.g. after
+// restoring the export onto a standby instance.
+func (ac *RBACService) VerifyState(ctx context.Context, export *StateExport) (*StateVerification, error) {
+	current, err := ac.ExportState(ctx)
+	if err != nil {
+		return nil, err
+	}
+
+	verification := &StateVerification{SchemaVersion: current.SchemaVersion}
+	actual := make(map[string]TableExport, len(current.Tables))
+	for _, t := range current.Tables {
+		actual[t.Name] = t
+	}
+	for _, t := range export.Tables {
+		verification.Tables = append(verification.Tables, TableVerification{
+			Name:     t.Name,
+			Expected: t.Checksum,
+			Actual:   actual[t.Name].Checksum,
+			Rows:     len(t.Rows),
+		})
+	}
+
+	return verification, nil
+}
+
+// RestoreState replaces the RBAC state of the database with an export, in a single transaction.
+// The database must be at the schema version of the export.
+func (ac *RBACService) RestoreState(ctx context.Context, export *StateExport) error {
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		applied, err := appliedMigrations(sess)
+		if err != nil {
+			return err
+		}
+		if version := currentSchemaVersion(applied); version != export.SchemaVersion {
+			return fmt.Errorf("%w: it was taken at schema version %d but the database is at version %d",
+				ErrInvalidStateExport, export.SchemaVersion, version)
+		}
+
+		tables := make(map[string]TableExport, len(export.Tables))
+		for _, t := range export.Tables {
+			tables[t.Name] = t
+		}
+		for i := len(stateTables) - 1; i >= 0; i-- {
+			if stateTables[i].version > export.SchemaVersion {
+				continue
+			}
+			if _, err := sess.Exec("DELETE FROM " + stateTables[i].name); err != nil {
+				return err
+			}
+		}
+		for _, t := range stateTables {
+			if t.version > export.SchemaVersion {
+				continue
+			}
+			for _, row := range tables[t.name].Rows {
+				if _, err := sess.Table(t.name).Insert(row); err != nil {
+					return err
+				}
+			}
+			if ac.SQLStore.Dialect.DriverName() == migrator.Postgres {
+				// The ids were restored as they were, the sequence has to catch up.
+				q := fmt.Sprintf("SELECT setval('%s_id_seq', (SELECT max(id) FROM %s))", t.name, t.name)
+				if _, err := sess.Exec(q); err != nil {
+					return err
+				}
+			}
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishPolicyChanged(0, 0, PolicyChangedStateRestored)
+
+	return nil
+}
+
+// stateTableRows returns the rows of a table ordered by id, with the column values normalized so
+// that the checksum of a table doesn't depend on how the driver returns them.
+func stateTableRows(sess *sqlstore.DBSession, table string) ([]map[string]interface{}, error) {
+	rows, err := sess.QueryInterface("SELECT * FROM " + table + " ORDER BY id")
+	if err != nil {
+		return nil, err
+	}
+
+	for _, row := range rows {
+		for column, value := range row {
+			switch v := value.(type) {
+			case []byte:
+				row[column] = string(v)
+			case time.Time:
+				row[column] = v.UTC().Format(time.RFC3339Nano)
+			case bool:
+				// SQLite and MySQL store booleans as integers.
+				if v {
+					row[column] = 1
+				} else {
+					row[column] = 0
+				}
+			}
+		}
+	}
+	if rows == nil {
+		rows = []map[string]interface{}{}
+	}
+
+	return rows, nil
+}
+
+// stateChecksum returns the SHA-256 checksum of rows encoded as JSON, whose object keys are sorted.
+func stateChecksum(rows []map[string]interface{}) (string, error) {
+	var buf bytes.Buffer
+	for _, row := range rows {
+		data, err := json.Marshal(row)
+		if err != nil {
+			return "", err
+		}
+		buf.Write(data)
+		buf.WriteByte('\n')
+	}
+
+	sum := sha256.Sum256(buf.Bytes())
+	return hex.EncodeToString(sum[:]), nil
+}
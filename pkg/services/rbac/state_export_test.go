@@ -0,0 +1,82 @@
+package rbac
+
+import (
+	"bytes"
+	"context"
+	"encoding/json"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestStateExport(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	const userID = 351
+	policy := createPolicy(t, ac, 1, "exported", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: userID, PolicyID: policy.ID}))
+	require.NoError(t, ac.AddDefaultPolicy(context.Background(), AddDefaultPolicyCommand{OrgID: 1, PolicyID: policy.ID}))
+
+	export, err := ac.ExportState(context.Background())
+	require.NoError(t, err)
+	assert.Equal(t, supportedSchemaVersion, export.SchemaVersion)
+	require.Len(t, export.Tables, len(stateTables))
+
+	var buf bytes.Buffer
+	require.NoError(t, json.NewEncoder(&buf).Encode(export))
+	data := buf.String()
+	export, err = ReadStateExport(strings.NewReader(data))
+	require.NoError(t, err)
+
+	verification, err := ac.VerifyState(context.Background(), export)
+	require.NoError(t, err)
+	assert.True(t, verification.Matches())
+
+	t.Run("Changes since the export should be told apart table by table", func(t *testing.T) {
+		require.NoError(t, ac.RemoveUserPolicy(context.Background(), RemoveUserPolicyCommand{OrgID: 1, UserID: userID, PolicyID: policy.ID}))
+		createPolicy(t, ac, 1, "created after export")
+
+		verification, err := ac.VerifyState(context.Background(), export)
+		require.NoError(t, err)
+		assert.False(t, verification.Matches())
+		mismatched := map[string]bool{}
+		for _, table := range verification.Tables {
+			if !table.Matches() {
+				mismatched[table.Name] = true
+			}
+		}
+		assert.Equal(t, map[string]bool{"policy": true, "user_policy": true}, mismatched)
+	})
+
+	t.Run("Restoring the export should bring the state back", func(t *testing.T) {
+		require.NoError(t, ac.RestoreState(context.Background(), export))
+
+		verification, err := ac.VerifyState(context.Background(), export)
+		require.NoError(t, err)
+		assert.True(t, verification.Matches())
+
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: userID})
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+		assert.Equal(t, "exported", policies[0].Name)
+
+		// Ids keep being allocated after the restored ones.
+		created := createPolicy(t, ac, 1, "created after restore")
+		assert.Greater(t, created.ID, policy.ID)
+	})
+
+	t.Run("Altered exports should be rejected", func(t *testing.T) {
+		altered := strings.Replace(data, `"name":"exported"`, `"name":"altered"`, 1)
+		require.NotEqual(t, data, altered)
+		_, err := ReadStateExport(strings.NewReader(altered))
+		require.ErrorIs(t, err, ErrInvalidStateExport)
+	})
+
+	t.Run("Exports of another schema version shouldn't be restored", func(t *testing.T) {
+		other := *export
+		other.SchemaVersion--
+		require.ErrorIs(t, ac.RestoreState(context.Background(), &other), ErrInvalidStateExport)
+	})
+}
@@ -0,0 +1,219 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"math"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// suspendedPolicy is the managed deny-all policy bound to suspended users. It's created in each
+// organization on first use. A lone wildcard doesn't match the empty scope of unscoped actions,
+// so both are denied.
+var suspendedPolicy = FixedPolicyDefinition{
+	UID:         managedPolicyUIDPrefix + "suspended",
+	Name:        "managed:suspended",
+	Description: "Denies every action to suspended users. Managed by Grafana.",
+	Permissions: []FixedPermission{
+		{Action: wildcard, Scope: wildcard, Kind: PermissionKindDeny},
+		{Action: wildcard, Scope: "", Kind: PermissionKindDeny},
+	},
+}
+
+// suspendedPolicyPrecedence ranks the suspended policy above every other policy so that
+// no allow permission overrides a suspension.
+const suspendedPolicyPrecedence = math.MaxInt32
+
+func init() {
+	RegisterFixedPolicies(suspendedPolicy)
+}
+
+// SuspendUserAccess binds the managed deny-all policy to a user, denying every action whatever
+// the other policies grant, without deleting the account or its bindings. The suspension lasts
+// until ResumeUserAccess is called or, when set, until it expires.
+func (ac *RBACService) SuspendUserAccess(ctx context.Context, cmd SuspendUserAccessCommand) (*UserSuspension, error) {
+	if err := ac.checkSchemaVersion(schemaVersionPolicyEnabled); err != nil {
+		return nil, err
+	}
+
+	suspension := &UserSuspension{
+		OrgID:       cmd.OrgID,
+		UserID:      cmd.UserID,
+		SuspendedBy: cmd.SuspendedBy,
+		Reason:      cmd.Reason,
+		Created:     time.Now(),
+		Expires:     cmd.Expires,
+	}
+
+	var repaired bool
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var policyID int64
+		var err error
+		policyID, repaired, err = getOrCreateSuspendedPolicy(sess, cmd.OrgID)
+		if err != nil {
+			return err
+		}
+		suspension.PolicyID = policyID
+
+		// An expired suspension is replaced rather than reported as a conflict.
+		if _, err := sess.Exec("DELETE FROM user_suspension WHERE org_id = ? AND user_id = ? AND expires <= ?",
+			cmd.OrgID, cmd.UserID, time.Now()); err != nil {
+			return err
+		}
+
+		if _, err := sess.Table("user_suspension").Insert(suspension); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrUserAlreadySuspended
+			}
+			return err
+		}
+		return nil
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.log.Info("Suspended user access", "orgId", cmd.OrgID, "userId", cmd.UserID, "suspendedBy", cmd.SuspendedBy,
+		"reason", cmd.Reason, "expires", cmd.Expires)
+	if repaired {
+		ac.log.Warn("Repaired the suspended policy", "orgId", cmd.OrgID, "policyId", suspension.PolicyID)
+		ac.publishPolicyChanged(cmd.OrgID, suspension.PolicyID, PolicyChangedPermissions)
+	}
+	ac.publishPermissionsChanged(cmd.OrgID, cmd.UserID, PermissionsChangedSuspended)
+
+	return suspension, nil
+}
+
+// ResumeUserAccess lifts the suspension of a user.
+func (ac *RBACService) ResumeUserAccess(ctx context.Context, cmd ResumeUserAccessCommand) error {
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		result, err := sess.Exec("DELETE FROM user_suspension WHERE org_id = ? AND user_id = ?", cmd.OrgID, cmd.UserID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return ErrUserNotSuspended
+		}
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.log.Info("Resumed user access", "orgId", cmd.OrgID, "userId", cmd.UserID, "resumedBy", cmd.ResumedBy)
+	ac.publishPermissionsChanged(cmd.OrgID, cmd.UserID, PermissionsChangedResumed)
+
+	return nil
+}
+
+// getOrCreateSuspendedPolicy returns the id of the organization's deny-all policy, creating it if needed.
+// An existing policy is repaired, so that a suspension denies every action whatever happened to it,
+// repaired telling whether it had to be.
+func getOrCreateSuspendedPolicy(sess *sqlstore.DBSession, orgID int64) (policyID int64, repaired bool, err error) {
+	now := time.Now()
+	precedence := suspendedPolicyPrecedence
+	policy, err := getPolicy(sess, GetPolicyQuery{OrgID: orgID, UID: suspendedPolicy.UID})
+	switch {
+	case err == nil:
+		repaired, err := repairSuspendedPolicy(sess, policy, now)
+		if err != nil {
+			return 0, false, err
+		}
+		return policy.ID, repaired, nil
+	case !errors.Is(err, ErrPolicyNotFound):
+		return 0, false, err
+	}
+
+	policy = &Policy{
+		OrgID:       orgID,
+		UID:         suspendedPolicy.UID,
+		Name:        suspendedPolicy.Name,
+		Description: suspendedPolicy.Description,
+		Precedence:  &precedence,
+		Enabled:     true,
+		Created:     now,
+		Updated:     now,
+	}
+	if _, err := sess.Table("policy").Insert(policy); err != nil {
+		return 0, false, err
+	}
+
+	for _, p := range suspendedPolicy.Permissions {
+		if err := insertSuspendedPermission(sess, policy.ID, p, now); err != nil {
+			return 0, false, err
+		}
+	}
+
+	return policy.ID, false, nil
+}
+
+// repairSuspendedPolicy restores the precedence, the enabled flag and the permissions of the deny-all
+// policy, returning true if any of them had changed. Permissions other than its deny-all ones, or with
+// conditions or an expiry, are deleted.
+func repairSuspendedPolicy(sess *sqlstore.DBSession, policy *Policy, now time.Time) (bool, error) {
+	repaired := false
+	if policy.Precedence == nil || *policy.Precedence != suspendedPolicyPrecedence || !policy.Enabled {
+		repaired = true
+		precedence := suspendedPolicyPrecedence
+		policy.Precedence = &precedence
+		policy.Enabled = true
+		policy.Updated = now
+		if _, err := sess.Table("policy").ID(policy.ID).Cols("precedence", "enabled", "updated").Update(policy); err != nil {
+			return false, err
+		}
+	}
+
+	permissions, err := getPolicyPermissions(sess, policy.ID)
+	if err != nil {
+		return false, err
+	}
+	found := map[FixedPermission]bool{}
+	for _, p := range permissions {
+		fixed := FixedPermission{Action: p.Action, Scope: p.Scope, Kind: p.Kind}
+		if len(p.Conditions) == 0 && p.ExpiresAt == nil && !found[fixed] && isSuspendedPermission(fixed) {
+			found[fixed] = true
+			continue
+		}
+		repaired = true
+		if _, err := sess.Exec("DELETE FROM permission WHERE id = ?", p.ID); err != nil {
+			return false, err
+		}
+	}
+	for _, p := range suspendedPolicy.Permissions {
+		if found[p] {
+			continue
+		}
+		repaired = true
+		if err := insertSuspendedPermission(sess, policy.ID, p, now); err != nil {
+			return false, err
+		}
+	}
+
+	return repaired, nil
+}
+
+func isSuspendedPermission(permission FixedPermission) bool {
+	for _, p := range suspendedPolicy.Permissions {
+		if p == permission {
+			return true
+		}
+	}
+	return false
+}
+
+func insertSuspendedPermission(sess *sqlstore.DBSession, policyID int64, p FixedPermission, now time.Time) error {
+	permission := &Permission{
+		PolicyID: policyID,
+		Action:   p.Action,
+		Kind:     p.Kind,
+		Created:  now,
+		Updated:  now,
+	}
+	permission.setScope(p.Scope)
+	_, err := sess.Table("permission").Insert(permission)
+	return err
+}
@@ -0,0 +1,103 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestSuspendUserAccess(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "admins")
+	addTeamMember(t, 1, team.Id, 71)
+	policy := createPolicy(t, ac, 1, "admin", CreatePermissionCommand{Action: "*", Scope: "*"})
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 71}
+	check := func(evaluator Evaluator) bool {
+		ok, err := ac.Evaluate(context.Background(), user, evaluator)
+		require.NoError(t, err)
+		return ok
+	}
+	require.True(t, check(Perm(ActionUsersDelete, "users:id:1")))
+
+	suspension, err := ac.SuspendUserAccess(context.Background(), SuspendUserAccessCommand{OrgID: 1, UserID: 71, SuspendedBy: 1, Reason: "compromised credentials"})
+	require.NoError(t, err)
+	assert.Equal(t, "compromised credentials", suspension.Reason)
+
+	assert.False(t, check(Perm(ActionUsersDelete, "users:id:1")))
+	assert.False(t, check(Perm(ActionUsersCreate, "")))
+
+	_, err = ac.SuspendUserAccess(context.Background(), SuspendUserAccessCommand{OrgID: 1, UserID: 71, SuspendedBy: 1})
+	require.ErrorIs(t, err, ErrUserAlreadySuspended)
+
+	require.NoError(t, ac.ResumeUserAccess(context.Background(), ResumeUserAccessCommand{OrgID: 1, UserID: 71, ResumedBy: 1}))
+	assert.True(t, check(Perm(ActionUsersDelete, "users:id:1")))
+
+	err = ac.ResumeUserAccess(context.Background(), ResumeUserAccessCommand{OrgID: 1, UserID: 71, ResumedBy: 1})
+	require.ErrorIs(t, err, ErrUserNotSuspended)
+
+	t.Run("Expired suspensions should be ignored and replaceable", func(t *testing.T) {
+		expired := time.Now().Add(-time.Minute)
+		_, err := ac.SuspendUserAccess(context.Background(), SuspendUserAccessCommand{OrgID: 1, UserID: 71, SuspendedBy: 1, Expires: &expired})
+		require.NoError(t, err)
+		assert.True(t, check(Perm(ActionUsersDelete, "users:id:1")))
+
+		expires := time.Now().Add(time.Hour)
+		_, err = ac.SuspendUserAccess(context.Background(), SuspendUserAccessCommand{OrgID: 1, UserID: 71, SuspendedBy: 1, Expires: &expires})
+		require.NoError(t, err)
+		assert.False(t, check(Perm(ActionUsersDelete, "users:id:1")))
+	})
+}
+
+func TestSuspendedPolicy(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "admin", CreatePermissionCommand{Action: "*", Scope: "*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 72, PolicyID: policy.ID}))
+	user := &models.SignedInUser{OrgId: 1, UserId: 72}
+
+	// A tampered suspended policy: disabled, without precedence and granting everything.
+	tampered := createManagedPolicy(t, ac, 1, suspendedPolicy.UID)
+	insertManagedPermission(t, ac, tampered.ID, "*", "*")
+	require.NoError(t, ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		_, err := sess.Exec("UPDATE policy SET enabled = ? WHERE id = ?", false, tampered.ID)
+		return err
+	}))
+
+	t.Run("Suspending a user should repair the suspended policy", func(t *testing.T) {
+		_, err := ac.SuspendUserAccess(context.Background(), SuspendUserAccessCommand{OrgID: 1, UserID: 72, SuspendedBy: 1})
+		require.NoError(t, err)
+
+		ok, err := ac.Evaluate(context.Background(), user, Perm(ActionUsersDelete, "users:id:1"))
+		require.NoError(t, err)
+		assert.False(t, ok)
+
+		dto, err := ac.GetPolicy(context.Background(), GetPolicyQuery{OrgID: 1, PolicyID: tampered.ID})
+		require.NoError(t, err)
+		assert.True(t, dto.Enabled)
+		require.NotNil(t, dto.Precedence)
+		assert.Equal(t, suspendedPolicyPrecedence, *dto.Precedence)
+		permissions := make([]FixedPermission, 0, len(dto.Permissions))
+		for _, p := range dto.Permissions {
+			permissions = append(permissions, FixedPermission{Action: p.Action, Scope: p.Scope, Kind: p.Kind})
+		}
+		assert.ElementsMatch(t, suspendedPolicy.Permissions, permissions)
+	})
+
+	t.Run("The suspended policy shouldn't be deleted", func(t *testing.T) {
+		err := ac.DeletePolicy(context.Background(), DeletePolicyCommand{OrgID: 1, ID: tampered.ID})
+		require.ErrorIs(t, err, ErrManagedPolicy)
+
+		ok, err := ac.Evaluate(context.Background(), user, Perm(ActionUsersDelete, "users:id:1"))
+		require.NoError(t, err)
+		assert.False(t, ok)
+	})
+}
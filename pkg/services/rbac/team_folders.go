@@ -0,0 +1,71 @@
+package rbac
+
+import (
+	"context"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// teamFolderActions are granted to a team on its provisioned folder, covering the folder's dashboards.
+var teamFolderActions = []string{ActionDashboardsRead, ActionDashboardsCreate, ActionDashboardsWrite, ActionDashboardsDelete}
+
+func (ac *RBACService) loadTeamFolderSettings() {
+	ac.provisionTeamFolders = ac.Cfg.Raw.Section("rbac").Key("provision_team_folders").MustBool(false)
+}
+
+// ProvisionsTeamFolders returns true if new teams should be created with CreateTeamWithFolder.
+func (ac *RBACService) ProvisionsTeamFolders() bool {
+	return ac.IsEnabled() && ac.provisionTeamFolders
+}
+
+// CreateTeamWithFolder creates a team, a folder named after it and a managed policy granting the team
+// edit rights on the folder, in a single transaction. Nothing is created if any step fails, e.g. when
+// the team name is taken or a dashboard of the General folder has the same name.
+func (ac *RBACService) CreateTeamWithFolder(ctx context.Context, cmd CreateTeamWithFolderCommand) (*TeamWithFolder, error) {
+	if err := ac.checkSchemaVersion(schemaVersionBindingExpiry); err != nil {
+		return nil, err
+	}
+
+	result := &TeamWithFolder{}
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		// The team and the folder are created by the SQL store within this transaction.
+		ctx := context.WithValue(ctx, sqlstore.ContextSessionKey{}, sess)
+
+		teamCmd := &models.CreateTeamCommand{OrgId: cmd.OrgID, Name: cmd.Name, Email: cmd.Email}
+		if err := sqlstore.CreateTeamCtx(ctx, teamCmd); err != nil {
+			return err
+		}
+		result.Team = teamCmd.Result
+
+		folder := models.NewDashboardFolder(cmd.Name)
+		folderCmd := &models.SaveDashboardCommand{
+			OrgId:     cmd.OrgID,
+			UserId:    cmd.UserID,
+			IsFolder:  true,
+			Dashboard: folder.Data,
+		}
+		if err := sqlstore.SaveDashboardCtx(ctx, folderCmd); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return models.ErrFolderSameNameExists
+			}
+			return err
+		}
+		result.Folder = folderCmd.Result
+
+		return ac.setResourcePermission(sess, SetResourcePermissionCommand{
+			OrgID:   cmd.OrgID,
+			Scope:   ScopeFolderUID(result.Folder.Uid),
+			TeamID:  result.Team.Id,
+			Actions: teamFolderActions,
+		})
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	ac.log.Info("Created team with folder", "orgId", cmd.OrgID, "teamId", result.Team.Id, "folderUid", result.Folder.Uid,
+		"createdBy", cmd.UserID)
+
+	return result, nil
+}
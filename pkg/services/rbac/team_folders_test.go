@@ -0,0 +1,49 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestCreateTeamWithFolder(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	result, err := ac.CreateTeamWithFolder(context.Background(), CreateTeamWithFolderCommand{OrgID: 1, Name: "payments", UserID: 241})
+	require.NoError(t, err)
+	assert.Equal(t, "payments", result.Team.Name)
+	assert.Equal(t, "payments", result.Folder.Title)
+	assert.True(t, result.Folder.IsFolder)
+
+	addTeamMember(t, 1, result.Team.Id, 241)
+	inFolder := saveDashboard(t, "checkout", result.Folder.Id, false)
+	outside := saveDashboard(t, "search", 0, false)
+
+	user := &models.SignedInUser{OrgId: 1, UserId: 241}
+	check := func(dash *models.Dashboard) bool {
+		ok, err := ac.Evaluate(context.Background(), user, Perm(ActionDashboardsWrite, ScopeDashboardUID(dash.Uid)))
+		require.NoError(t, err)
+		return ok
+	}
+	assert.True(t, check(inFolder))
+	assert.False(t, check(outside))
+
+	t.Run("Taken team name should create nothing", func(t *testing.T) {
+		_, err := ac.CreateTeamWithFolder(context.Background(), CreateTeamWithFolderCommand{OrgID: 1, Name: "payments"})
+		require.ErrorIs(t, err, models.ErrTeamNameTaken)
+	})
+
+	t.Run("Taken folder name should roll back the team", func(t *testing.T) {
+		_, err := ac.CreateTeamWithFolder(context.Background(), CreateTeamWithFolderCommand{OrgID: 1, Name: "search"})
+		require.ErrorIs(t, err, models.ErrFolderSameNameExists)
+
+		query := &models.SearchTeamsQuery{OrgId: 1, Name: "search"}
+		require.NoError(t, sqlstore.SearchTeams(query))
+		assert.Empty(t, query.Result.Teams)
+	})
+}
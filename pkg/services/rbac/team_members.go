@@ -0,0 +1,70 @@
+package rbac
+
+import (
+	"context"
+	"time"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// Reasons of the UserPermissionsChanged events published by the RBAC service.
+const (
+	PermissionsChangedTeamMemberAdded   = "teamMemberAdded"
+	PermissionsChangedTeamMemberRemoved = "teamMemberRemoved"
+	PermissionsChangedSuspended         = "suspended"
+	PermissionsChangedResumed           = "resumed"
+	PermissionsChangedAccessRevoked     = "accessRevoked"
+)
+
+// onTeamMemberAdded tells that the permissions of a user added to a team changed when policies are
+// bound to the team, whether the membership was added by hand or by team sync.
+func (ac *RBACService) onTeamMemberAdded(e *events.TeamMemberAdded) error {
+	ac.teamMembershipChanged(e.OrgID, e.TeamID, e.UserID, PermissionsChangedTeamMemberAdded)
+	return nil
+}
+
+// onTeamMemberRemoved tells that the permissions of a user removed from a team changed when policies
+// are bound to the team.
+func (ac *RBACService) onTeamMemberRemoved(e *events.TeamMemberRemoved) error {
+	ac.teamMembershipChanged(e.OrgID, e.TeamID, e.UserID, PermissionsChangedTeamMemberRemoved)
+	return nil
+}
+
+func (ac *RBACService) teamMembershipChanged(orgID, teamID, userID int64, reason string) {
+	if !ac.IsEnabled() {
+		return
+	}
+
+	var bound bool
+	err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+		var err error
+		bound, err = sess.Table("team_policy").Where("org_id = ? AND team_id = ?", orgID, teamID).Exist()
+		return err
+	})
+	if err != nil {
+		// The membership changed regardless, and other listeners shouldn't be skipped.
+		ac.log.Error("Failed to look up team policies", "orgId", orgID, "teamId", teamID, "error", err)
+		return
+	}
+	if !bound {
+		return
+	}
+
+	ac.publishPermissionsChanged(orgID, userID, reason)
+}
+
+// publishPermissionsChanged publishes that the permissions of a user changed, so that downstream
+// caches of their permissions can be invalidated.
+func (ac *RBACService) publishPermissionsChanged(orgID, userID int64, reason string) {
+	e := &events.UserPermissionsChanged{
+		Timestamp: time.Now(),
+		OrgID:     orgID,
+		UserID:    userID,
+		Reason:    reason,
+	}
+	if err := bus.Publish(e); err != nil {
+		ac.log.Error("Failed to publish permissions changed event", "orgId", orgID, "userId", userID, "error", err)
+	}
+}
@@ -0,0 +1,51 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestTeamMembershipChanges(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	var changed []events.UserPermissionsChanged
+	bus.AddEventListener(func(e *events.UserPermissionsChanged) error {
+		changed = append(changed, *e)
+		return nil
+	})
+	changes := func(userID int64) []string {
+		t.Helper()
+		var reasons []string
+		for _, e := range changed {
+			if e.UserID == userID {
+				assert.Equal(t, int64(1), e.OrgID)
+				reasons = append(reasons, e.Reason)
+			}
+		}
+		return reasons
+	}
+
+	synced := createTeam(t, 1, "synced editors")
+	plain := createTeam(t, 1, "no policies")
+	policy := createPolicy(t, ac, 1, "synced team editor", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: synced.Id, PolicyID: policy.ID}))
+
+	require.NoError(t, sqlstore.AddTeamMember(&models.AddTeamMemberCommand{OrgId: 1, TeamId: synced.Id, UserId: 331, External: true}))
+	require.NoError(t, sqlstore.RemoveTeamMember(&models.RemoveTeamMemberCommand{OrgId: 1, TeamId: synced.Id, UserId: 331}))
+	assert.Contains(t, changes(331), PermissionsChangedTeamMemberAdded)
+	assert.Contains(t, changes(331), PermissionsChangedTeamMemberRemoved)
+
+	t.Run("Teams without policies shouldn't change permissions", func(t *testing.T) {
+		addTeamMember(t, 1, plain.Id, 332)
+		require.NoError(t, sqlstore.RemoveTeamMember(&models.RemoveTeamMemberCommand{OrgId: 1, TeamId: plain.Id, UserId: 332}))
+		assert.Empty(t, changes(332))
+	})
+}
@@ -0,0 +1,377 @@
+package rbac
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// GetTeamPolicies returns the policies bound to a team, ordered by name.
+func (ac *RBACService) GetTeamPolicies(ctx context.Context, query GetTeamPoliciesQuery) ([]*PolicyDTO, error) {
+	var policies []*PolicyDTO
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		policies, err = ac.getTeamPolicies(sess, query)
+		return err
+	})
+
+	return policies, err
+}
+
+// SearchTeamPolicies returns the policies bound to a team, ordered by name, a page at a time.
+func (ac *RBACService) SearchTeamPolicies(ctx context.Context, query GetTeamPoliciesQuery) (*PoliciesResult, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+
+	result := &PoliciesResult{Page: query.Page, PerPage: query.Limit}
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		if result.Policies, err = ac.getTeamPolicies(sess, query); err != nil {
+			return err
+		}
+
+		result.TotalCount, err = sess.Table("team_policy").
+			Where("team_policy.org_id = ? AND team_policy.team_id = ?", query.OrgID, query.TeamID).
+			Count()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (ac *RBACService) getTeamPolicies(sess *sqlstore.DBSession, query GetTeamPoliciesQuery) ([]*PolicyDTO, error) {
+	q := `SELECT policy.* FROM policy
+		INNER JOIN team_policy ON policy.id = team_policy.policy_id
+		WHERE team_policy.org_id = ? AND team_policy.team_id = ?
+		ORDER BY policy.name ASC, policy.id ASC`
+	if query.Limit > 0 {
+		if query.Page < 1 {
+			query.Page = 1
+		}
+		q += ac.SQLStore.Dialect.LimitOffset(int64(query.Limit), int64(query.Limit*(query.Page-1)))
+	}
+
+	policies := make([]*PolicyDTO, 0)
+	err := sess.SQL(q, query.OrgID, query.TeamID).Find(&policies)
+	return policies, err
+}
+
+// GetPolicyTeams returns the teams a policy is bound to, ordered by name, a page at a time.
+func (ac *RBACService) GetPolicyTeams(ctx context.Context, query GetPolicyTeamsQuery) (*PolicyTeamsResult, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+
+	result := &PolicyTeamsResult{Teams: []*PolicyTeam{}, Page: query.Page, PerPage: query.Limit}
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: query.OrgID, PolicyID: query.PolicyID}); err != nil {
+			return err
+		}
+
+		q := `SELECT team.id AS team_id, team.name AS team_name, team_policy.expires_at,
+			team_policy.granted_by, team_policy.reason, team_policy.created
+			FROM team_policy
+			INNER JOIN team ON team.id = team_policy.team_id
+			WHERE team_policy.org_id = ? AND team_policy.policy_id = ?
+			ORDER BY team.name ASC`
+		if query.Limit > 0 {
+			q += ac.SQLStore.Dialect.LimitOffset(int64(query.Limit), int64(query.Limit*(query.Page-1)))
+		}
+		if err := sess.SQL(q, query.OrgID, query.PolicyID).Find(&result.Teams); err != nil {
+			return err
+		}
+
+		count, err := sess.Table("team_policy").
+			Join("INNER", "team", "team.id = team_policy.team_id").
+			Where("team_policy.org_id = ? AND team_policy.policy_id = ?", query.OrgID, query.PolicyID).
+			Count()
+		result.TotalCount = count
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+// AddTeamPolicy binds a policy to a team, until it expires when an expiry is set.
+func (ac *RBACService) AddTeamPolicy(ctx context.Context, cmd AddTeamPolicyCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionBindingGrant); err != nil {
+		return err
+	}
+	if cmd.ExpiresAt != nil && !cmd.ExpiresAt.After(time.Now()) {
+		return ErrBindingExpiryInPast
+	}
+
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID}); err != nil {
+			return err
+		}
+
+		teamPolicy := &TeamPolicy{
+			OrgID:     cmd.OrgID,
+			PolicyID:  cmd.PolicyID,
+			TeamID:    cmd.TeamID,
+			ExpiresAt: cmd.ExpiresAt,
+			GrantedBy: cmd.PerformedBy,
+			Reason:    strings.TrimSpace(cmd.Reason),
+			Created:   time.Now(),
+		}
+		if _, err := sess.Table("team_policy").Insert(teamPolicy); err != nil {
+			if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+				return ErrTeamPolicyAlreadyAdded
+			}
+			return err
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonManual)
+	changes.teamBound(cmd.OrgID, cmd.PolicyID, cmd.TeamID)
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// RemoveTeamPolicy unbinds a policy from a team.
+func (ac *RBACService) RemoveTeamPolicy(ctx context.Context, cmd RemoveTeamPolicyCommand) error {
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := "DELETE FROM team_policy WHERE org_id = ? AND team_id = ? AND policy_id = ?"
+		result, err := sess.Exec(q, cmd.OrgID, cmd.TeamID, cmd.PolicyID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return ErrTeamPolicyNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonManual)
+	changes.teamUnbound(cmd.OrgID, cmd.PolicyID, cmd.TeamID)
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// RemoveAllTeamPolicies unbinds every policy from a team in a single statement. It must be called
+// when a team is deleted so that its bindings aren't orphaned.
+func (ac *RBACService) RemoveAllTeamPolicies(ctx context.Context, cmd RemoveAllTeamPoliciesCommand) error {
+	var removed []TeamPolicy
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if err := sess.Table("team_policy").Where("org_id = ? AND team_id = ?", cmd.OrgID, cmd.TeamID).Find(&removed); err != nil {
+			return err
+		}
+		_, err := sess.Exec("DELETE FROM team_policy WHERE org_id = ? AND team_id = ?", cmd.OrgID, cmd.TeamID)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.log.Debug("Removed all team policies", "orgId", cmd.OrgID, "teamId", cmd.TeamID, "count", len(removed))
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonTeamDeleted)
+	for _, tp := range removed {
+		changes.teamUnbound(tp.OrgID, tp.PolicyID, tp.TeamID)
+	}
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// SetTeamPolicies reconciles the policies bound to a team with the given set in a single
+// transaction: missing policies are bound and the others are unbound. Bindings that are kept,
+// including their expiry, are left untouched.
+func (ac *RBACService) SetTeamPolicies(ctx context.Context, cmd SetTeamPoliciesCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionBindingGrant); err != nil {
+		return err
+	}
+
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonManual)
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var current []TeamPolicy
+		if err := sess.Table("team_policy").Where("org_id = ? AND team_id = ?", cmd.OrgID, cmd.TeamID).Find(&current); err != nil {
+			return err
+		}
+
+		wanted := make(map[int64]bool, len(cmd.PolicyIDs))
+		for _, policyID := range cmd.PolicyIDs {
+			wanted[policyID] = true
+		}
+
+		for _, tp := range current {
+			if wanted[tp.PolicyID] {
+				delete(wanted, tp.PolicyID)
+				continue
+			}
+			if _, err := sess.Exec("DELETE FROM team_policy WHERE id = ?", tp.ID); err != nil {
+				return err
+			}
+			changes.teamUnbound(cmd.OrgID, tp.PolicyID, cmd.TeamID)
+		}
+
+		now := time.Now()
+		for _, policyID := range cmd.PolicyIDs {
+			if !wanted[policyID] {
+				continue
+			}
+			delete(wanted, policyID)
+
+			if _, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: policyID}); err != nil {
+				return err
+			}
+			teamPolicy := &TeamPolicy{
+				OrgID:     cmd.OrgID,
+				PolicyID:  policyID,
+				TeamID:    cmd.TeamID,
+				GrantedBy: cmd.PerformedBy,
+				Created:   now,
+			}
+			if _, err := sess.Table("team_policy").Insert(teamPolicy); err != nil {
+				return err
+			}
+			changes.teamBound(cmd.OrgID, policyID, cmd.TeamID)
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// GetUserPermissions returns the unexpired permissions granted to a user by the unexpired bindings of
+// policies to the user, to the user's teams and to the user's builtin roles, along with the denials of
+// the user's active suspension. Service accounts only get the permissions of the policies bound to them.
+// Deactivated policies are ignored, except for the suspended policy which always applies.
+//
+// The permissions are resolved with a single statement, the UNION of the binding types deduplicates
+// the permissions of a policy bound more than once.
+func (ac *RBACService) GetUserPermissions(ctx context.Context, query GetUserPermissionsQuery) ([]Permission, error) {
+	permissions := make([]Permission, 0)
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q, args := userPermissionsQuery(query, time.Now(), false, false)
+		var err error
+		permissions, err = findPermissionsWithPrecedence(sess, q, args...)
+		return err
+	})
+
+	return permissions, err
+}
+
+// userPermissionsQuery returns the statement resolving the permissions of a user at a time and its
+// arguments. The team and builtin role bindings don't apply to service accounts. With bindings, the
+// uid and name of the policy and the binding granting each permission are selected too, see ExplainAccess.
+// With asOf, the permissions, bindings and team memberships created after the time are left out, see
+// GetUserPermissionsAt.
+func userPermissionsQuery(query GetUserPermissionsQuery, now time.Time, withBindings, asOf bool) (string, []interface{}) {
+	notServiceAccount := "NOT EXISTS (SELECT 1 FROM service_account WHERE service_account.org_id = ? AND service_account.user_id = ?)"
+	columns := func(binding, teamID, role string) string {
+		if !withBindings {
+			return "permission.*, policy.precedence"
+		}
+		return "permission.*, policy.precedence, policy.uid AS policy_uid, policy.name AS policy_name, '" + binding +
+			"' AS binding, " + teamID + " AS team_id, " + role + " AS role"
+	}
+	var args []interface{}
+	createdBefore := func(tables ...string) string {
+		if !asOf {
+			return ""
+		}
+		var cond string
+		for _, table := range append(tables, "permission") {
+			cond += " AND " + table + ".created <= ?"
+			args = append(args, now)
+		}
+		return cond
+	}
+
+	q := `SELECT ` + columns(AssignmentKindUser, "0", "''") + ` FROM permission
+		INNER JOIN policy ON permission.policy_id = policy.id
+		INNER JOIN user_policy ON permission.policy_id = user_policy.policy_id
+		WHERE user_policy.org_id = ? AND user_policy.user_id = ? AND policy.enabled = ?
+		AND (user_policy.expires_at IS NULL OR user_policy.expires_at > ?)
+		AND (permission.expires_at IS NULL OR permission.expires_at > ?)`
+	args = append(args, query.OrgID, query.UserID, true, now, now)
+	q += createdBefore("user_policy") + `
+		UNION
+		SELECT ` + columns(AssignmentKindSuspension, "0", "''") + ` FROM permission
+		INNER JOIN policy ON permission.policy_id = policy.id
+		INNER JOIN user_suspension ON permission.policy_id = user_suspension.policy_id
+		WHERE user_suspension.org_id = ? AND user_suspension.user_id = ?
+		AND (user_suspension.expires IS NULL OR user_suspension.expires > ?)`
+	args = append(args, query.OrgID, query.UserID, now)
+	q += createdBefore("user_suspension") + `
+		UNION
+		SELECT ` + columns(AssignmentKindTeam, "team_policy.team_id", "''") + ` FROM permission
+		INNER JOIN policy ON permission.policy_id = policy.id
+		INNER JOIN team_policy ON permission.policy_id = team_policy.policy_id
+		INNER JOIN team_member ON team_policy.team_id = team_member.team_id
+		WHERE team_policy.org_id = ? AND team_member.user_id = ? AND policy.enabled = ?
+		AND (team_policy.expires_at IS NULL OR team_policy.expires_at > ?)
+		AND (permission.expires_at IS NULL OR permission.expires_at > ?)
+		AND ` + notServiceAccount
+	args = append(args, query.OrgID, query.UserID, true, now, now, query.OrgID, query.UserID)
+	q += createdBefore("team_policy", "team_member")
+
+	if len(query.Roles) > 0 {
+		q += `
+		UNION
+		SELECT ` + columns(AssignmentKindBuiltinRole, "0", "builtin_role_policy.role") + ` FROM permission
+		INNER JOIN policy ON permission.policy_id = policy.id
+		INNER JOIN builtin_role_policy ON permission.policy_id = builtin_role_policy.policy_id
+		WHERE builtin_role_policy.org_id = ? AND builtin_role_policy.role IN (?` + strings.Repeat(",?", len(query.Roles)-1) + `)
+		AND policy.enabled = ? AND (permission.expires_at IS NULL OR permission.expires_at > ?)
+		AND ` + notServiceAccount
+		args = append(args, query.OrgID)
+		for _, role := range query.Roles {
+			args = append(args, role)
+		}
+		args = append(args, true, now, query.OrgID, query.UserID)
+		q += createdBefore("builtin_role_policy")
+	}
+
+	return q, args
+}
+
+// findPermissionsWithPrecedence runs a query selecting permission.* and policy.precedence and
+// returns the permissions with the precedence of their policy.
+func findPermissionsWithPrecedence(sess *sqlstore.DBSession, q string, args ...interface{}) ([]Permission, error) {
+	var rows []struct {
+		Permission `xorm:"extends"`
+		Precedence *int `xorm:"precedence"`
+	}
+	if err := sess.SQL(q, args...).Find(&rows); err != nil {
+		return nil, err
+	}
+
+	permissions := make([]Permission, 0, len(rows))
+	for _, row := range rows {
+		p := row.Permission
+		p.Precedence = row.Precedence
+		permissions = append(permissions, p)
+	}
+
+	return permissions, nil
+}
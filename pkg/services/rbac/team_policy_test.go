@@ -0,0 +1,187 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+func TestSetTeamPolicies(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "new hires")
+	viewer := createPolicy(t, ac, 1, "viewer", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	editor := createPolicy(t, ac, 1, "editor", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+	datasources := createPolicy(t, ac, 1, "datasources", CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:*"})
+
+	expiresAt := time.Now().Add(time.Hour).Truncate(time.Second)
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: viewer.ID, ExpiresAt: &expiresAt}))
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: editor.ID}))
+
+	policyNames := func() []string {
+		policies, err := ac.GetTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: 1, TeamID: team.Id})
+		require.NoError(t, err)
+		names := make([]string, 0, len(policies))
+		for _, p := range policies {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	err := ac.SetTeamPolicies(context.Background(), SetTeamPoliciesCommand{OrgID: 1, TeamID: team.Id, PolicyIDs: []int64{viewer.ID, datasources.ID, datasources.ID}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"datasources", "viewer"}, policyNames())
+
+	t.Run("Kept bindings should keep their expiry", func(t *testing.T) {
+		var kept TeamPolicy
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			has, err := sess.Table("team_policy").Where("team_id = ? AND policy_id = ?", team.Id, viewer.ID).Get(&kept)
+			require.True(t, has)
+			return err
+		})
+		require.NoError(t, err)
+		require.NotNil(t, kept.ExpiresAt)
+		assert.True(t, expiresAt.Equal(*kept.ExpiresAt))
+	})
+
+	t.Run("Unknown policy should roll back the whole set", func(t *testing.T) {
+		err := ac.SetTeamPolicies(context.Background(), SetTeamPoliciesCommand{OrgID: 1, TeamID: team.Id, PolicyIDs: []int64{editor.ID, 9999}})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+		assert.Equal(t, []string{"datasources", "viewer"}, policyNames())
+	})
+
+	t.Run("Policy of another organization should be rejected", func(t *testing.T) {
+		other := createPolicy(t, ac, 2, "other org")
+		err := ac.SetTeamPolicies(context.Background(), SetTeamPoliciesCommand{OrgID: 1, TeamID: team.Id, PolicyIDs: []int64{other.ID}})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+	})
+
+	require.NoError(t, ac.SetTeamPolicies(context.Background(), SetTeamPoliciesCommand{OrgID: 1, TeamID: team.Id}))
+	assert.Empty(t, policyNames())
+}
+
+func TestRemoveAllTeamPolicies(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "dissolved")
+	other := createTeam(t, 1, "kept")
+	viewer := createPolicy(t, ac, 1, "viewer", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	editor := createPolicy(t, ac, 1, "editor", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+	require.NoError(t, ac.SetTeamPolicies(context.Background(), SetTeamPoliciesCommand{OrgID: 1, TeamID: team.Id, PolicyIDs: []int64{viewer.ID, editor.ID}}))
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: other.Id, PolicyID: viewer.ID}))
+
+	require.NoError(t, ac.RemoveAllTeamPolicies(context.Background(), RemoveAllTeamPoliciesCommand{OrgID: 1, TeamID: team.Id}))
+
+	policies, err := ac.GetTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: 1, TeamID: team.Id})
+	require.NoError(t, err)
+	assert.Empty(t, policies)
+	policies, err = ac.GetTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: 1, TeamID: other.Id})
+	require.NoError(t, err)
+	assert.Len(t, policies, 1)
+
+	t.Run("Team without policies should not fail", func(t *testing.T) {
+		require.NoError(t, ac.RemoveAllTeamPolicies(context.Background(), RemoveAllTeamPoliciesCommand{OrgID: 1, TeamID: team.Id}))
+	})
+}
+
+func TestSearchTeamPolicies(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "paged")
+	for _, name := range []string{"delta", "alpha", "charlie", "bravo"} {
+		policy := createPolicy(t, ac, 1, name)
+		require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+	}
+	createPolicy(t, ac, 1, "unbound")
+
+	result, err := ac.SearchTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: 1, TeamID: team.Id, Page: 2, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.TotalCount)
+	assert.Equal(t, 2, result.Page)
+	assert.Equal(t, 3, result.PerPage)
+	require.Len(t, result.Policies, 1)
+	assert.Equal(t, "delta", result.Policies[0].Name)
+
+	policies, err := ac.GetTeamPolicies(context.Background(), GetTeamPoliciesQuery{OrgID: 1, TeamID: team.Id, Limit: 2})
+	require.NoError(t, err)
+	require.Len(t, policies, 2)
+	assert.Equal(t, "alpha", policies[0].Name)
+	assert.Equal(t, "bravo", policies[1].Name)
+}
+
+func TestGetPolicyTeams(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "shared", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	for _, name := range []string{"delta", "alpha", "charlie", "bravo"} {
+		team := createTeam(t, 1, name)
+		require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: policy.ID}))
+	}
+	createTeam(t, 1, "unbound")
+
+	teamNames := func(result *PolicyTeamsResult) []string {
+		names := make([]string, 0, len(result.Teams))
+		for _, team := range result.Teams {
+			names = append(names, team.TeamName)
+		}
+		return names
+	}
+
+	result, err := ac.GetPolicyTeams(context.Background(), GetPolicyTeamsQuery{OrgID: 1, PolicyID: policy.ID})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.TotalCount)
+	assert.Equal(t, []string{"alpha", "bravo", "charlie", "delta"}, teamNames(result))
+
+	result, err = ac.GetPolicyTeams(context.Background(), GetPolicyTeamsQuery{OrgID: 1, PolicyID: policy.ID, Page: 2, Limit: 3})
+	require.NoError(t, err)
+	assert.Equal(t, int64(4), result.TotalCount)
+	assert.Equal(t, 2, result.Page)
+	assert.Equal(t, 3, result.PerPage)
+	assert.Equal(t, []string{"delta"}, teamNames(result))
+
+	t.Run("Policy of another organization should not be found", func(t *testing.T) {
+		_, err := ac.GetPolicyTeams(context.Background(), GetPolicyTeamsQuery{OrgID: 2, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+	})
+}
+
+func TestGetUserPermissions(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "everything bound")
+	addTeamMember(t, 1, team.Id, 421)
+	shared := createPolicy(t, ac, 1, "shared", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	teamOnly := createPolicy(t, ac, 1, "team only", CreatePermissionCommand{Action: "dashboards:write", Scope: "dashboards:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 421, PolicyID: shared.ID}))
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: shared.ID}))
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: teamOnly.ID}))
+	require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: shared.ID}))
+
+	t.Run("A policy bound more than once should grant its permissions once", func(t *testing.T) {
+		permissions, err := ac.GetUserPermissions(context.Background(), GetUserPermissionsQuery{OrgID: 1, UserID: 421, Roles: []string{"Viewer"}})
+		require.NoError(t, err)
+		actions := make([]string, 0, len(permissions))
+		for _, p := range permissions {
+			actions = append(actions, p.Action)
+		}
+		assert.ElementsMatch(t, []string{"dashboards:read", "dashboards:write"}, actions)
+	})
+
+	t.Run("Service accounts should only get the permissions of the policies bound to them", func(t *testing.T) {
+		addTeamMember(t, 1, team.Id, 422)
+		err := ac.SQLStore.WithDbSession(context.Background(), func(sess *sqlstore.DBSession) error {
+			_, err := sess.Table("service_account").Insert(&ServiceAccount{OrgID: 1, UserID: 422, Created: time.Now()})
+			return err
+		})
+		require.NoError(t, err)
+
+		permissions, err := ac.GetUserPermissions(context.Background(), GetUserPermissionsQuery{OrgID: 1, UserID: 422, Roles: []string{"Viewer"}})
+		require.NoError(t, err)
+		assert.Empty(t, permissions)
+	})
+}
@@ -0,0 +1,134 @@
+package rbac
+
+import (
+	"crypto/rand"
+	"encoding/binary"
+	"fmt"
+	"math/big"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/grafana/grafana/pkg/util"
+)
+
+// UIDGenerator generates the uid of a policy created without one.
+type UIDGenerator func() (string, error)
+
+// Names of the builtin policy uid generators, one of them or of the registered ones is selected with
+// the rbac.policy_uid_generator setting.
+const (
+	// UIDGeneratorShortID generates short random uids, it's the default.
+	UIDGeneratorShortID = "shortid"
+	// UIDGeneratorULID generates ULIDs, sortable by creation time down to the millisecond.
+	UIDGeneratorULID = "ulid"
+	// UIDGeneratorKSUID generates KSUIDs, sortable by creation time down to the second.
+	UIDGeneratorKSUID = "ksuid"
+	// UIDGeneratorExternal doesn't generate uids, every policy must be created with one, e.g. when
+	// policies are replicated from another region.
+	UIDGeneratorExternal = "external"
+)
+
+type uidGenerators struct {
+	mu         sync.RWMutex
+	generators map[string]UIDGenerator
+	// name is the configured generator.
+	name string
+}
+
+// RegisterUIDGenerator adds a policy uid generator that can be selected with the rbac.policy_uid_generator
+// setting. Registering a name again replaces the generator.
+func (ac *RBACService) RegisterUIDGenerator(name string, generator UIDGenerator) {
+	ac.uidGenerators.mu.Lock()
+	defer ac.uidGenerators.mu.Unlock()
+
+	if ac.uidGenerators.generators == nil {
+		ac.uidGenerators.generators = map[string]UIDGenerator{}
+	}
+	ac.uidGenerators.generators[name] = generator
+}
+
+func (ac *RBACService) registerDefaultUIDGenerators() {
+	ac.RegisterUIDGenerator(UIDGeneratorShortID, func() (string, error) { return util.GenerateShortUID(), nil })
+	ac.RegisterUIDGenerator(UIDGeneratorULID, generateULID)
+	ac.RegisterUIDGenerator(UIDGeneratorKSUID, generateKSUID)
+}
+
+// loadUIDGeneratorSettings reads the policy uid generator from the rbac.policy_uid_generator setting.
+func (ac *RBACService) loadUIDGeneratorSettings() {
+	name := strings.TrimSpace(ac.Cfg.Raw.Section("rbac").Key("policy_uid_generator").MustString(UIDGeneratorShortID))
+
+	ac.uidGenerators.mu.Lock()
+	defer ac.uidGenerators.mu.Unlock()
+	ac.uidGenerators.name = name
+}
+
+// generatePolicyUID returns a uid for a policy created without one, using the configured generator.
+func (ac *RBACService) generatePolicyUID() (string, error) {
+	ac.uidGenerators.mu.RLock()
+	name := ac.uidGenerators.name
+	if name == "" {
+		name = UIDGeneratorShortID
+	}
+	generator, ok := ac.uidGenerators.generators[name]
+	ac.uidGenerators.mu.RUnlock()
+
+	if name == UIDGeneratorExternal {
+		return "", ErrPolicyUIDRequired
+	}
+	if !ok {
+		return "", fmt.Errorf("unknown policy uid generator %q", name)
+	}
+	return generator()
+}
+
+// crockfordBase32 is the alphabet of ULIDs.
+const crockfordBase32 = "0123456789ABCDEFGHJKMNPQRSTVWXYZ"
+
+// generateULID returns a ULID, a 48 bits millisecond timestamp followed by 80 random bits in
+// 26 Crockford base32 characters.
+func generateULID() (string, error) {
+	id := make([]byte, 16)
+	ms := uint64(time.Now().UnixNano() / int64(time.Millisecond))
+	for i := 5; i >= 0; i-- {
+		id[i] = byte(ms)
+		ms >>= 8
+	}
+	if _, err := rand.Read(id[6:]); err != nil {
+		return "", err
+	}
+
+	return encodeUID(id, crockfordBase32, 26), nil
+}
+
+// ksuidEpoch is the start of KSUID timestamps, in seconds since the Unix epoch.
+const ksuidEpoch = 1400000000
+
+const base62 = "0123456789ABCDEFGHIJKLMNOPQRSTUVWXYZabcdefghijklmnopqrstuvwxyz"
+
+// generateKSUID returns a KSUID, a 32 bits second timestamp followed by 128 random bits in
+// 27 base62 characters.
+func generateKSUID() (string, error) {
+	id := make([]byte, 20)
+	binary.BigEndian.PutUint32(id, uint32(time.Now().Unix()-ksuidEpoch))
+	if _, err := rand.Read(id[4:]); err != nil {
+		return "", err
+	}
+
+	return encodeUID(id, base62, 27), nil
+}
+
+// encodeUID encodes the big-endian number id with the alphabet, left padded to length characters
+// so that the encoded uids sort like the numbers.
+func encodeUID(id []byte, alphabet string, length int) string {
+	n := new(big.Int).SetBytes(id)
+	base := big.NewInt(int64(len(alphabet)))
+	digit := new(big.Int)
+	encoded := make([]byte, length)
+	for i := length - 1; i >= 0; i-- {
+		n.DivMod(n, base, digit)
+		encoded[i] = alphabet[digit.Int64()]
+	}
+
+	return string(encoded)
+}
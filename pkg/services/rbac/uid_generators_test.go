@@ -0,0 +1,83 @@
+package rbac
+
+import (
+	"context"
+	"regexp"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPolicyUIDGenerators(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	useGenerator := func(t *testing.T, name string) {
+		_, err := ac.Cfg.Raw.Section("rbac").NewKey("policy_uid_generator", name)
+		require.NoError(t, err)
+		ac.loadUIDGeneratorSettings()
+	}
+	t.Cleanup(func() { useGenerator(t, UIDGeneratorShortID) })
+
+	t.Run("ULIDs should be sortable by creation time", func(t *testing.T) {
+		useGenerator(t, UIDGeneratorULID)
+
+		first, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "ulid first"})
+		require.NoError(t, err)
+		time.Sleep(2 * time.Millisecond)
+		second, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "ulid second"})
+		require.NoError(t, err)
+
+		assert.Regexp(t, regexp.MustCompile(`^[0-9A-HJKMNP-TV-Z]{26}$`), first.UID)
+		assert.Less(t, first.UID, second.UID)
+	})
+
+	t.Run("KSUIDs should be generated", func(t *testing.T) {
+		useGenerator(t, UIDGeneratorKSUID)
+
+		policy, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "ksuid"})
+		require.NoError(t, err)
+		assert.Regexp(t, regexp.MustCompile(`^[0-9A-Za-z]{27}$`), policy.UID)
+	})
+
+	t.Run("External uids should be required", func(t *testing.T) {
+		useGenerator(t, UIDGeneratorExternal)
+
+		_, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "external"})
+		require.ErrorIs(t, err, ErrPolicyUIDRequired)
+
+		policy, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "external", UID: "eu-west-1-admins"})
+		require.NoError(t, err)
+		assert.Equal(t, "eu-west-1-admins", policy.UID)
+	})
+
+	t.Run("Registered generators should be selectable", func(t *testing.T) {
+		ac.RegisterUIDGenerator("region", func() (string, error) { return "us-east-1-policy", nil })
+		useGenerator(t, "region")
+
+		policy, err := ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "regional"})
+		require.NoError(t, err)
+		assert.Equal(t, "us-east-1-policy", policy.UID)
+
+		useGenerator(t, "unknown")
+		_, err = ac.CreatePolicy(context.Background(), CreatePolicyCommand{OrgID: 1, Name: "unknown"})
+		require.Error(t, err)
+	})
+}
+
+func TestEncodeUID(t *testing.T) {
+	assert.Equal(t, "00000000000000000000000000", encodeUID(make([]byte, 16), crockfordBase32, 26))
+	assert.Equal(t, "7ZZZZZZZZZZZZZZZZZZZZZZZZZ", encodeUID([]byte{0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff, 0xff}, crockfordBase32, 26))
+	// The largest KSUID, see https://github.com/segmentio/ksuid.
+	max := make([]byte, 20)
+	for i := range max {
+		max[i] = 0xff
+	}
+	assert.Equal(t, "aWgEPTl1tmebfsQzFP4bxwgy80V", encodeUID(max, base62, 27))
+
+	// Padding keeps the uids sorted like the numbers they encode, e.g. KSUIDs by timestamp.
+	earlier, later := make([]byte, 20), make([]byte, 20)
+	earlier[3], later[2] = 0xff, 0x01
+	assert.Less(t, encodeUID(earlier, base62, 27), encodeUID(later, base62, 27))
+}
@@ -0,0 +1,194 @@
+package rbac
+
+import (
+	"context"
+	"strings"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// GetUserPolicies returns the policies bound directly to a user, ordered by name.
+func (ac *RBACService) GetUserPolicies(ctx context.Context, query GetUserPoliciesQuery) ([]*PolicyDTO, error) {
+	var policies []*PolicyDTO
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		policies, err = ac.getUserPolicies(sess, query)
+		return err
+	})
+
+	return policies, err
+}
+
+// SearchUserPolicies returns the policies bound directly to a user, ordered by name, a page at a time.
+func (ac *RBACService) SearchUserPolicies(ctx context.Context, query GetUserPoliciesQuery) (*PoliciesResult, error) {
+	if query.Page < 1 {
+		query.Page = 1
+	}
+
+	result := &PoliciesResult{Page: query.Page, PerPage: query.Limit}
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		if result.Policies, err = ac.getUserPolicies(sess, query); err != nil {
+			return err
+		}
+
+		result.TotalCount, err = sess.Table("user_policy").
+			Where("user_policy.org_id = ? AND user_policy.user_id = ?", query.OrgID, query.UserID).
+			Count()
+		return err
+	})
+	if err != nil {
+		return nil, err
+	}
+
+	return result, nil
+}
+
+func (ac *RBACService) getUserPolicies(sess *sqlstore.DBSession, query GetUserPoliciesQuery) ([]*PolicyDTO, error) {
+	q := `SELECT policy.* FROM policy
+		INNER JOIN user_policy ON policy.id = user_policy.policy_id
+		WHERE user_policy.org_id = ? AND user_policy.user_id = ?
+		ORDER BY policy.name ASC, policy.id ASC`
+	if query.Limit > 0 {
+		if query.Page < 1 {
+			query.Page = 1
+		}
+		q += ac.SQLStore.Dialect.LimitOffset(int64(query.Limit), int64(query.Limit*(query.Page-1)))
+	}
+
+	policies := make([]*PolicyDTO, 0)
+	err := sess.SQL(q, query.OrgID, query.UserID).Find(&policies)
+	return policies, err
+}
+
+// GetPoliciesForUser returns the enabled policies that apply to a user, through unexpired bindings
+// to the user, to the user's teams and to the user's builtin roles, each policy once. As in
+// GetUserPermissions, service accounts only get the policies bound to them.
+func (ac *RBACService) GetPoliciesForUser(ctx context.Context, query GetPoliciesForUserQuery) ([]*PolicyDTO, error) {
+	var policies []*PolicyDTO
+	err := ac.SQLStore.WithDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		serviceAccount, err := isServiceAccount(sess, query.OrgID, query.UserID)
+		if err != nil {
+			return err
+		}
+
+		q := `SELECT policy.* FROM policy
+			INNER JOIN user_policy ON policy.id = user_policy.policy_id
+			WHERE user_policy.org_id = ? AND user_policy.user_id = ? AND policy.enabled = ?
+			AND (user_policy.expires_at IS NULL OR user_policy.expires_at > ?)`
+		now := time.Now()
+		args := []interface{}{query.OrgID, query.UserID, true, now}
+
+		if !serviceAccount {
+			q += `
+			UNION
+			SELECT policy.* FROM policy
+			INNER JOIN team_policy ON policy.id = team_policy.policy_id
+			INNER JOIN team_member ON team_policy.team_id = team_member.team_id
+			WHERE team_policy.org_id = ? AND team_member.user_id = ? AND policy.enabled = ?
+			AND (team_policy.expires_at IS NULL OR team_policy.expires_at > ?)`
+			args = append(args, query.OrgID, query.UserID, true, now)
+
+			if len(query.Roles) > 0 {
+				q += `
+			UNION
+			SELECT policy.* FROM policy
+			INNER JOIN builtin_role_policy ON policy.id = builtin_role_policy.policy_id
+			WHERE builtin_role_policy.org_id = ? AND builtin_role_policy.role IN (?` + strings.Repeat(",?", len(query.Roles)-1) + `)
+			AND policy.enabled = ?`
+				args = append(args, query.OrgID)
+				for _, role := range query.Roles {
+					args = append(args, role)
+				}
+				args = append(args, true)
+			}
+		}
+
+		q += `
+			ORDER BY name ASC`
+		return sess.SQL(q, args...).Find(&policies)
+	})
+
+	return policies, err
+}
+
+// AddUserPolicy binds a policy to a user, until it expires when an expiry is set. In organizations
+// with a binding lifetime, the binding expires at the end of it at the latest, see SetBindingLifetime.
+func (ac *RBACService) AddUserPolicy(ctx context.Context, cmd AddUserPolicyCommand) error {
+	if err := ac.checkSchemaVersion(schemaVersionBindingGrant); err != nil {
+		return err
+	}
+	if cmd.ExpiresAt != nil && !cmd.ExpiresAt.After(time.Now()) {
+		return ErrBindingExpiryInPast
+	}
+
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		if _, err := getPolicy(sess, GetPolicyQuery{OrgID: cmd.OrgID, PolicyID: cmd.PolicyID}); err != nil {
+			return err
+		}
+
+		expiresAt, err := ac.userPolicyExpiry(sess, cmd.OrgID, cmd.ExpiresAt)
+		if err != nil {
+			return err
+		}
+
+		return ac.addUserPolicy(sess, &UserPolicy{
+			OrgID:     cmd.OrgID,
+			PolicyID:  cmd.PolicyID,
+			UserID:    cmd.UserID,
+			ExpiresAt: expiresAt,
+			GrantedBy: cmd.PerformedBy,
+			Reason:    strings.TrimSpace(cmd.Reason),
+		})
+	})
+	if err != nil {
+		return err
+	}
+
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonManual)
+	changes.userBound(cmd.OrgID, cmd.PolicyID, cmd.UserID)
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
+
+// addUserPolicy inserts a user policy binding, created now.
+func (ac *RBACService) addUserPolicy(sess *sqlstore.DBSession, userPolicy *UserPolicy) error {
+	userPolicy.Created = time.Now()
+	if _, err := sess.Table("user_policy").Insert(userPolicy); err != nil {
+		if ac.SQLStore.Dialect.IsUniqueConstraintViolation(err) {
+			return ErrUserPolicyAlreadyAdded
+		}
+		return err
+	}
+
+	return nil
+}
+
+// RemoveUserPolicy unbinds a policy from a user.
+func (ac *RBACService) RemoveUserPolicy(ctx context.Context, cmd RemoveUserPolicyCommand) error {
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		q := "DELETE FROM user_policy WHERE org_id = ? AND user_id = ? AND policy_id = ?"
+		result, err := sess.Exec(q, cmd.OrgID, cmd.UserID, cmd.PolicyID)
+		if err != nil {
+			return err
+		}
+		if rowsAffected, err := result.RowsAffected(); err != nil {
+			return err
+		} else if rowsAffected == 0 {
+			return ErrUserPolicyNotFound
+		}
+
+		return nil
+	})
+	if err != nil {
+		return err
+	}
+
+	changes := newBindingChanges(cmd.PerformedBy, BindingReasonManual)
+	changes.userUnbound(cmd.OrgID, cmd.PolicyID, cmd.UserID)
+	ac.publishBindingChanges(changes)
+
+	return nil
+}
@@ -0,0 +1,118 @@
+package rbac
+
+import (
+	"context"
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana/pkg/models"
+	"github.com/grafana/grafana/pkg/services/sqlstore"
+)
+
+// SyncUserPolicies binds the policies an auth module maps to a user, e.g. through LDAP group policy
+// mappings, along with the policies mapped to the user's external groups in the policy_group_mapping
+// table, and unbinds the ones it bound before that aren't mapped anymore, in every organization.
+// Policies bound to the user by other means are left as they are. Unknown policy uids are skipped.
+func (ac *RBACService) SyncUserPolicies(ctx context.Context, cmd *models.SyncUserPoliciesCommand) error {
+	if !ac.IsEnabled() || ac.schemaVersion < schemaVersionUserPolicySync {
+		return nil
+	}
+
+	var added, removed int
+	changes := newBindingChanges(0, BindingReasonSync)
+	err := ac.SQLStore.WithTransactionalDbSession(ctx, func(sess *sqlstore.DBSession) error {
+		var err error
+		added, removed, err = ac.syncUserPolicies(sess, cmd, changes)
+		return err
+	})
+	if err != nil {
+		return err
+	}
+
+	if added > 0 || removed > 0 {
+		ac.log.Info("Synced user policies", "authModule", cmd.AuthModule, "userId", cmd.UserId, "added", added, "removed", removed)
+	}
+	ac.publishBindingChanges(changes)
+	return nil
+}
+
+// syncUserPolicies reconciles the policies synced by the auth module of the command within a
+// session and returns the number of bindings it added and removed, the changes are collected for
+// their events.
+func (ac *RBACService) syncUserPolicies(sess *sqlstore.DBSession, cmd *models.SyncUserPoliciesCommand, changes *bindingChanges) (added, removed int, err error) {
+	desired := map[int64]map[int64]bool{}
+	for orgID, policyUIDs := range cmd.PolicyUIDs {
+		desired[orgID] = map[int64]bool{}
+		for _, uid := range policyUIDs {
+			policy, err := getPolicy(sess, GetPolicyQuery{OrgID: orgID, UID: uid})
+			if errors.Is(err, ErrPolicyNotFound) {
+				ac.log.Warn("Skipping unknown synced policy", "authModule", cmd.AuthModule, "orgId", orgID, "policyUid", uid)
+				continue
+			}
+			if err != nil {
+				return 0, 0, err
+			}
+			desired[orgID][policy.ID] = true
+		}
+	}
+	mapped, err := ac.groupMappedPolicies(sess, cmd.Groups)
+	if err != nil {
+		return 0, 0, err
+	}
+	for orgID, policyIDs := range mapped {
+		if desired[orgID] == nil {
+			desired[orgID] = map[int64]bool{}
+		}
+		for _, policyID := range policyIDs {
+			desired[orgID][policyID] = true
+		}
+	}
+
+	var synced []UserPolicySync
+	if err := sess.Table("user_policy_sync").Where("user_id = ? AND auth_module = ?", cmd.UserId, cmd.AuthModule).Find(&synced); err != nil {
+		return 0, 0, err
+	}
+	isSynced := map[int64]map[int64]bool{}
+	for _, s := range synced {
+		if desired[s.OrgID][s.PolicyID] {
+			if isSynced[s.OrgID] == nil {
+				isSynced[s.OrgID] = map[int64]bool{}
+			}
+			isSynced[s.OrgID][s.PolicyID] = true
+			continue
+		}
+		if _, err := sess.Exec("DELETE FROM user_policy WHERE org_id = ? AND user_id = ? AND policy_id = ?", s.OrgID, s.UserID, s.PolicyID); err != nil {
+			return 0, 0, err
+		}
+		if _, err := sess.Exec("DELETE FROM user_policy_sync WHERE id = ?", s.ID); err != nil {
+			return 0, 0, err
+		}
+		changes.userUnbound(s.OrgID, s.PolicyID, s.UserID)
+		removed++
+	}
+
+	for orgID, policyIDs := range desired {
+		for policyID := range policyIDs {
+			bound, err := sess.Table("user_policy").Where("org_id = ? AND user_id = ? AND policy_id = ?", orgID, cmd.UserId, policyID).Exist()
+			if err != nil {
+				return 0, 0, err
+			}
+			if bound {
+				// Bindings made by other means stay untracked so that the sync never removes them
+				continue
+			}
+			if err := ac.addUserPolicy(sess, &UserPolicy{OrgID: orgID, PolicyID: policyID, UserID: cmd.UserId}); err != nil {
+				return 0, 0, err
+			}
+			if !isSynced[orgID][policyID] {
+				s := &UserPolicySync{OrgID: orgID, UserID: cmd.UserId, PolicyID: policyID, AuthModule: cmd.AuthModule, Created: time.Now()}
+				if _, err := sess.Table("user_policy_sync").Insert(s); err != nil {
+					return 0, 0, err
+				}
+			}
+			changes.userBound(orgID, policyID, cmd.UserId)
+			added++
+		}
+	}
+	return added, removed, nil
+}
@@ -0,0 +1,58 @@
+package rbac
+
+import (
+	"context"
+	"sort"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+
+	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/models"
+)
+
+func TestSyncUserPolicies(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	const userID = 291
+	admin := createPolicy(t, ac, 1, "admin-tools", CreatePermissionCommand{Action: "users:write", Scope: "users:*"})
+	baseline := createPolicy(t, ac, 1, "baseline", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	manual := createPolicy(t, ac, 1, "manual", CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:*"})
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: userID, PolicyID: manual.ID}))
+
+	sync := func(policyUIDs map[int64][]string) {
+		t.Helper()
+		require.NoError(t, bus.Dispatch(&models.SyncUserPoliciesCommand{UserId: userID, AuthModule: models.AuthModuleLDAP, PolicyUIDs: policyUIDs}))
+	}
+	userPolicyNames := func() []string {
+		t.Helper()
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: userID})
+		require.NoError(t, err)
+		names := make([]string, 0, len(policies))
+		for _, p := range policies {
+			names = append(names, p.Name)
+		}
+		sort.Strings(names)
+		return names
+	}
+
+	sync(map[int64][]string{1: {admin.UID, baseline.UID, manual.UID, "unknown"}})
+	assert.Equal(t, []string{"admin-tools", "baseline", "manual"}, userPolicyNames())
+
+	t.Run("Policies no longer mapped should be unbound unless bound by other means", func(t *testing.T) {
+		sync(map[int64][]string{1: {baseline.UID}})
+		assert.Equal(t, []string{"baseline", "manual"}, userPolicyNames())
+	})
+
+	t.Run("Synced policies unbound by hand should be bound again", func(t *testing.T) {
+		require.NoError(t, ac.RemoveUserPolicy(context.Background(), RemoveUserPolicyCommand{OrgID: 1, UserID: userID, PolicyID: baseline.ID}))
+		sync(map[int64][]string{1: {baseline.UID}})
+		assert.Equal(t, []string{"baseline", "manual"}, userPolicyNames())
+	})
+
+	t.Run("Organizations that aren't mapped anymore should be cleaned up", func(t *testing.T) {
+		sync(map[int64][]string{})
+		assert.Equal(t, []string{"manual"}, userPolicyNames())
+	})
+}
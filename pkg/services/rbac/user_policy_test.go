@@ -0,0 +1,122 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestUserPolicies(t *testing.T) {
+	t.Run("Binding a policy to a user should grant its permissions to the user", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "contractor", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:uid:abc"})
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 161, PolicyID: policy.ID}))
+
+		err := ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 161, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrUserPolicyAlreadyAdded)
+
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: 161})
+		require.NoError(t, err)
+		require.Len(t, policies, 1)
+		assert.Equal(t, policy.ID, policies[0].ID)
+
+		permissions, err := ac.GetUserPermissions(context.Background(), GetUserPermissionsQuery{OrgID: 1, UserID: 161})
+		require.NoError(t, err)
+		require.Len(t, permissions, 1)
+		assert.Equal(t, "dashboards:read", permissions[0].Action)
+
+		require.NoError(t, ac.RemoveUserPolicy(context.Background(), RemoveUserPolicyCommand{OrgID: 1, UserID: 161, PolicyID: policy.ID}))
+		err = ac.RemoveUserPolicy(context.Background(), RemoveUserPolicyCommand{OrgID: 1, UserID: 161, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrUserPolicyNotFound)
+
+		permissions, err = ac.GetUserPermissions(context.Background(), GetUserPermissionsQuery{OrgID: 1, UserID: 161})
+		require.NoError(t, err)
+		assert.Empty(t, permissions)
+	})
+
+	t.Run("Binding a policy from another organization should fail", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 2, "contractor")
+		err := ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 161, PolicyID: policy.ID})
+		require.ErrorIs(t, err, ErrPolicyNotFound)
+	})
+
+	t.Run("Deleting a policy should remove its user bindings", func(t *testing.T) {
+		ac := setupTestEnv(t)
+
+		policy := createPolicy(t, ac, 1, "contractor", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 161, PolicyID: policy.ID}))
+		require.NoError(t, ac.DeletePolicy(context.Background(), DeletePolicyCommand{OrgID: 1, ID: policy.ID}))
+
+		policies, err := ac.GetUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: 161})
+		require.NoError(t, err)
+		assert.Empty(t, policies)
+	})
+}
+
+func TestSearchUserPolicies(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	const userID = 392
+	for _, name := range []string{"delta", "alpha", "charlie"} {
+		policy := createPolicy(t, ac, 1, name)
+		require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: userID, PolicyID: policy.ID}))
+	}
+
+	result, err := ac.SearchUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: userID, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.TotalCount)
+	assert.Equal(t, 1, result.Page)
+	require.Len(t, result.Policies, 2)
+	assert.Equal(t, "alpha", result.Policies[0].Name)
+	assert.Equal(t, "charlie", result.Policies[1].Name)
+
+	result, err = ac.SearchUserPolicies(context.Background(), GetUserPoliciesQuery{OrgID: 1, UserID: userID, Page: 3, Limit: 2})
+	require.NoError(t, err)
+	assert.Equal(t, int64(3), result.TotalCount)
+	assert.Empty(t, result.Policies)
+}
+
+func TestGetPoliciesForUser(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	team := createTeam(t, 1, "operators")
+	addTeamMember(t, 1, team.Id, 271)
+	other := createTeam(t, 1, "others")
+	addTeamMember(t, 1, other.Id, 272)
+
+	shared := createPolicy(t, ac, 1, "shared", CreatePermissionCommand{Action: "dashboards:read", Scope: "dashboards:*"})
+	direct := createPolicy(t, ac, 1, "direct", CreatePermissionCommand{Action: "datasources:read", Scope: "datasources:*"})
+	viewer := createPolicy(t, ac, 1, "viewer", CreatePermissionCommand{Action: "teams:read", Scope: "teams:*"})
+	unrelated := createPolicy(t, ac, 1, "unrelated", CreatePermissionCommand{Action: "users:read", Scope: "users:*"})
+
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 271, PolicyID: shared.ID}))
+	require.NoError(t, ac.AddUserPolicy(context.Background(), AddUserPolicyCommand{OrgID: 1, UserID: 271, PolicyID: direct.ID}))
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: team.Id, PolicyID: shared.ID}))
+	require.NoError(t, ac.AddTeamPolicy(context.Background(), AddTeamPolicyCommand{OrgID: 1, TeamID: other.Id, PolicyID: unrelated.ID}))
+	require.NoError(t, ac.AddBuiltinRolePolicy(context.Background(), AddBuiltinRolePolicyCommand{OrgID: 1, Role: "Viewer", PolicyID: viewer.ID}))
+
+	policyNames := func(policies []*PolicyDTO) []string {
+		names := make([]string, 0, len(policies))
+		for _, p := range policies {
+			names = append(names, p.Name)
+		}
+		return names
+	}
+
+	policies, err := ac.GetPoliciesForUser(context.Background(), GetPoliciesForUserQuery{OrgID: 1, UserID: 271, Roles: []string{"Viewer"}})
+	require.NoError(t, err)
+	assert.Equal(t, []string{"direct", "shared", "viewer"}, policyNames(policies))
+
+	t.Run("Deactivated policies should not apply", func(t *testing.T) {
+		require.NoError(t, ac.SetPolicyEnabled(context.Background(), SetPolicyEnabledCommand{ID: direct.ID, OrgID: 1, Enabled: false}))
+
+		policies, err := ac.GetPoliciesForUser(context.Background(), GetPoliciesForUserQuery{OrgID: 1, UserID: 271})
+		require.NoError(t, err)
+		assert.Equal(t, []string{"shared"}, policyNames(policies))
+	})
+}
@@ -0,0 +1,73 @@
+package rbac
+
+import (
+	"errors"
+	"fmt"
+	"strings"
+)
+
+// Actions and scopes are made of segments separated by ":", e.g. "dashboards:read"
+// or "datasources:id:4". A segment consisting of a single "*" is a wildcard that
+// matches one or more segments. Wildcards may only be used as trailing segments,
+// so "datasources:*" and "dashboards:*:*" are valid while "dashboards:*:read" and
+// "dash*" are not. A lone "*" matches anything.
+const (
+	wildcard         = "*"
+	segmentSeparator = ":"
+)
+
+var (
+	// ErrInvalidAction is an error for when a permission's action is malformed.
+	ErrInvalidAction = errors.New("invalid action")
+	// ErrInvalidScope is an error for when a permission's scope is malformed.
+	ErrInvalidScope = errors.New("invalid scope")
+)
+
+// validatePermission checks that the action and scope of a permission are well formed.
+func validatePermission(action, scope string) error {
+	if action == "" {
+		return fmt.Errorf("%w: action is required", ErrInvalidAction)
+	}
+	if err := validatePattern(action); err != nil {
+		return fmt.Errorf("%w %q: %s", ErrInvalidAction, action, err)
+	}
+	if scope == "" {
+		return nil
+	}
+	if err := validatePattern(scope); err != nil {
+		return &ScopeError{Scope: scope, Reason: err.Error()}
+	}
+
+	return nil
+}
+
+// validatePattern checks that a pattern has no empty segments and that wildcards
+// are only used as whole, trailing segments.
+func validatePattern(pattern string) error {
+	seenWildcard := false
+	for _, segment := range strings.Split(pattern, segmentSeparator) {
+		switch {
+		case segment == "":
+			return errors.New("empty segment")
+		case segment == wildcard:
+			seenWildcard = true
+		case strings.Contains(segment, wildcard):
+			return errors.New("wildcard must be a whole segment")
+		case seenWildcard:
+			return errors.New("wildcard must only be followed by wildcards")
+		}
+	}
+
+	return nil
+}
+
+// matchPattern returns true if value is matched by pattern, taking wildcards into account.
+func matchPattern(pattern, value string) bool {
+	idx := strings.Index(pattern, wildcard)
+	if idx == -1 {
+		return pattern == value
+	}
+
+	prefix := pattern[:idx]
+	return len(value) > len(prefix) && strings.HasPrefix(value, prefix)
+}
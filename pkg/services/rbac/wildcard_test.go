@@ -0,0 +1,85 @@
+package rbac
+
+import (
+	"context"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestValidatePermission(t *testing.T) {
+	tests := []struct {
+		desc   string
+		action string
+		scope  string
+		err    error
+	}{
+		{desc: "plain action and scope", action: "datasources:read", scope: "datasources:id:1"},
+		{desc: "trailing wildcard", action: "datasources:*", scope: "datasources:*"},
+		{desc: "multiple trailing wildcards", action: "dashboards:read", scope: "dashboards:*:*"},
+		{desc: "lone wildcard", action: "*", scope: "*"},
+		{desc: "no scope", action: "users:create"},
+		{desc: "missing action", action: "", scope: "users:*", err: ErrInvalidAction},
+		{desc: "partial wildcard in action", action: "dash*:read", err: ErrInvalidAction},
+		{desc: "wildcard followed by segment", action: "dashboards:read", scope: "dashboards:*:1", err: ErrInvalidScope},
+		{desc: "empty segment", action: "dashboards:read", scope: "dashboards::1", err: ErrInvalidScope},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.desc, func(t *testing.T) {
+			err := validatePermission(tc.action, tc.scope)
+			if tc.err == nil {
+				require.NoError(t, err)
+				return
+			}
+			require.ErrorIs(t, err, tc.err)
+		})
+	}
+}
+
+func TestMatchPattern(t *testing.T) {
+	tests := []struct {
+		pattern string
+		value   string
+		match   bool
+	}{
+		{pattern: "datasources:read", value: "datasources:read", match: true},
+		{pattern: "datasources:read", value: "datasources:write", match: false},
+		{pattern: "datasources:*", value: "datasources:read", match: true},
+		{pattern: "datasources:*", value: "datasources:id:4", match: true},
+		{pattern: "datasources:*", value: "datasources", match: false},
+		{pattern: "datasources:*", value: "dashboards:read", match: false},
+		{pattern: "dashboards:*:*", value: "dashboards:uid:abc", match: true},
+		{pattern: "dashboards:*:*", value: "datasources:uid:abc", match: false},
+		{pattern: "*", value: "users:id:1", match: true},
+		{pattern: "", value: "", match: true},
+		{pattern: "", value: "users:id:1", match: false},
+	}
+
+	for _, tc := range tests {
+		t.Run(tc.pattern+" "+tc.value, func(t *testing.T) {
+			assert.Equal(t, tc.match, matchPattern(tc.pattern, tc.value))
+		})
+	}
+}
+
+func TestEvaluatePermissions(t *testing.T) {
+	permissions := []Permission{
+		{Action: "datasources:*", Scope: "datasources:id:4"},
+		{Action: "dashboards:read", Scope: "dashboards:*"},
+	}
+
+	assert.True(t, evaluatePermissions(permissions, accessRequest{Action: "datasources:query", Scope: "datasources:id:4"}))
+	assert.False(t, evaluatePermissions(permissions, accessRequest{Action: "datasources:query", Scope: "datasources:id:5"}))
+	assert.True(t, evaluatePermissions(permissions, accessRequest{Action: "dashboards:read", Scope: "dashboards:uid:abc"}))
+	assert.False(t, evaluatePermissions(permissions, accessRequest{Action: "dashboards:write", Scope: "dashboards:uid:abc"}))
+}
+
+func TestCreatePermission_InvalidWildcard(t *testing.T) {
+	ac := setupTestEnv(t)
+
+	policy := createPolicy(t, ac, 1, "editor")
+	_, err := ac.CreatePermission(context.Background(), CreatePermissionCommand{OrgID: policy.OrgID, PolicyID: policy.ID, Action: "dashboards:read", Scope: "dashboards:*:1"})
+	require.ErrorIs(t, err, ErrInvalidScope)
+}
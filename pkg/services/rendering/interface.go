@@ -17,7 +17,9 @@ type Opts struct {
 	Timeout           time.Duration
 	OrgId             int64
 	UserId            int64
+	ApiKeyId          int64
 	OrgRole           models.RoleType
+	IsGrafanaAdmin    bool
 	Path              string
 	Encoding          string
 	Timezone          string
@@ -18,6 +18,7 @@ import (
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/plugins"
 	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/rbac"
 	"github.com/grafana/grafana/pkg/setting"
 	"github.com/grafana/grafana/pkg/util"
 )
@@ -38,6 +39,10 @@ type RenderUser struct {
 	OrgID   int64
 	UserID  int64
 	OrgRole string
+	// Permissions are the RBAC permissions of the user resolved when the render key was issued, the
+	// render calls are authorized with them when Delegated is set. Delegated is unset when RBAC is disabled.
+	Permissions []rbac.Permission
+	Delegated   bool
 }
 
 type RenderingService struct {
@@ -49,6 +54,7 @@ type RenderingService struct {
 
 	Cfg                *setting.Cfg             `inject:""`
 	RemoteCacheService *remotecache.RemoteCache `inject:""`
+	RBACService        *rbac.RBACService        `inject:""`
 }
 
 func (rs *RenderingService) Init() error {
@@ -172,7 +178,7 @@ func (rs *RenderingService) render(ctx context.Context, opts Opts) (*RenderResul
 	if math.IsInf(opts.DeviceScaleFactor, 0) || math.IsNaN(opts.DeviceScaleFactor) || opts.DeviceScaleFactor <= 0 {
 		opts.DeviceScaleFactor = 1
 	}
-	renderKey, err := rs.generateAndStoreRenderKey(opts.OrgId, opts.UserId, opts.OrgRole)
+	renderKey, err := rs.generateAndStoreRenderKey(ctx, opts)
 	if err != nil {
 		return nil, err
 	}
@@ -246,17 +252,32 @@ func (rs *RenderingService) getURL(path string) string {
 	return fmt.Sprintf("%s://%s:%s%s/%s&render=1", protocol, rs.domain, setting.HttpPort, subPath, path)
 }
 
-func (rs *RenderingService) generateAndStoreRenderKey(orgId, userId int64, orgRole models.RoleType) (string, error) {
+func (rs *RenderingService) generateAndStoreRenderKey(ctx context.Context, opts Opts) (string, error) {
 	key, err := util.GetRandomString(32)
 	if err != nil {
 		return "", err
 	}
 
-	err = rs.RemoteCacheService.Set(fmt.Sprintf(renderKeyPrefix, key), &RenderUser{
-		OrgID:   orgId,
-		UserID:  userId,
-		OrgRole: string(orgRole),
-	}, 5*time.Minute)
+	renderUser := &RenderUser{
+		OrgID:   opts.OrgId,
+		UserID:  opts.UserId,
+		OrgRole: string(opts.OrgRole),
+	}
+	if rs.RBACService != nil && rs.RBACService.IsEnabled() {
+		renderUser.Permissions, err = rs.RBACService.DelegatedPermissions(ctx, &models.SignedInUser{
+			OrgId:          opts.OrgId,
+			UserId:         opts.UserId,
+			ApiKeyId:       opts.ApiKeyId,
+			OrgRole:        opts.OrgRole,
+			IsGrafanaAdmin: opts.IsGrafanaAdmin,
+		})
+		if err != nil {
+			return "", err
+		}
+		renderUser.Delegated = true
+	}
+
+	err = rs.RemoteCacheService.Set(fmt.Sprintf(renderKeyPrefix, key), renderUser, 5*time.Minute)
 	if err != nil {
 		return "", err
 	}
@@ -1,6 +1,7 @@
 package search
 
 import (
+	"context"
 	"sort"
 
 	"github.com/grafana/grafana/pkg/setting"
@@ -8,6 +9,7 @@ import (
 	"github.com/grafana/grafana/pkg/bus"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/registry"
+	"github.com/grafana/grafana/pkg/services/sqlstore/searchstore"
 )
 
 func init() {
@@ -45,19 +47,27 @@ type FindPersistedDashboardsQuery struct {
 	Permission   models.PermissionType
 
 	Filters []interface{}
+	// PermissionFilter restricts the results to the dashboards and folders the user is granted the
+	// permission on instead of the dashboard ACL, see RegisterPermissionFilter.
+	PermissionFilter searchstore.FilterWhere
 
 	Result HitList
 }
 
+// PermissionFilterFunc returns the filter restricting a search to the dashboards and folders the
+// user is granted the permission on, or nil to restrict it through the dashboard ACL.
+type PermissionFilterFunc func(ctx context.Context, user *models.SignedInUser, permission models.PermissionType) (searchstore.FilterWhere, error)
+
 type SearchService struct {
 	Bus bus.Bus      `inject:""`
 	Cfg *setting.Cfg `inject:""`
 
-	sortOptions map[string]SortOption
+	sortOptions      map[string]SortOption
+	permissionFilter PermissionFilterFunc
 }
 
 func (s *SearchService) Init() error {
-	s.Bus.AddHandler(s.searchHandler)
+	s.Bus.AddHandlerCtx(s.searchHandler)
 	s.sortOptions = map[string]SortOption{
 		sortAlphaAsc.Name:  sortAlphaAsc,
 		sortAlphaDesc.Name: sortAlphaDesc,
@@ -66,7 +76,13 @@ func (s *SearchService) Init() error {
 	return nil
 }
 
-func (s *SearchService) searchHandler(query *Query) error {
+// RegisterPermissionFilter allows for another service, such as RBAC, to
+// decide which dashboards and folders the searches return.
+func (s *SearchService) RegisterPermissionFilter(filter PermissionFilterFunc) {
+	s.permissionFilter = filter
+}
+
+func (s *SearchService) searchHandler(ctx context.Context, query *Query) error {
 	dashboardQuery := FindPersistedDashboardsQuery{
 		Title:        query.Title,
 		SignedInUser: query.SignedInUser,
@@ -86,6 +102,14 @@ func (s *SearchService) searchHandler(query *Query) error {
 		}
 	}
 
+	if s.permissionFilter != nil {
+		filter, err := s.permissionFilter(ctx, query.SignedInUser, query.Permission)
+		if err != nil {
+			return err
+		}
+		dashboardQuery.PermissionFilter = filter
+	}
+
 	if err := bus.Dispatch(&dashboardQuery); err != nil {
 		return err
 	}
@@ -1,6 +1,7 @@
 package search
 
 import (
+	"context"
 	"testing"
 
 	"github.com/grafana/grafana/pkg/bus"
@@ -40,7 +41,7 @@ func TestSearch_SortedResults(t *testing.T) {
 		},
 	}
 
-	err := svc.searchHandler(query)
+	err := svc.searchHandler(context.Background(), query)
 	require.Nil(t, err)
 
 	// Assert results are sorted.
@@ -1,6 +1,7 @@
 package sqlstore
 
 import (
+	"context"
 	"strings"
 	"time"
 
@@ -50,6 +51,13 @@ func SaveDashboard(cmd *models.SaveDashboardCommand) error {
 	})
 }
 
+// SaveDashboardCtx saves a dashboard using the transaction carried by the context, see SQLStore.InTransaction.
+func SaveDashboardCtx(ctx context.Context, cmd *models.SaveDashboardCommand) error {
+	return withDbSession(ctx, func(sess *DBSession) error {
+		return saveDashboard(sess, cmd)
+	})
+}
+
 func saveDashboard(sess *DBSession, cmd *models.SaveDashboardCommand) error {
 	dash := cmd.GetDashboardModel()
 
@@ -216,14 +224,17 @@ type DashboardSearchProjection struct {
 }
 
 func findDashboards(query *search.FindPersistedDashboardsQuery) ([]DashboardSearchProjection, error) {
-	filters := []interface{}{
-		permissions.DashboardPermissionFilter{
+	var filters []interface{}
+	if query.PermissionFilter != nil {
+		filters = append(filters, query.PermissionFilter)
+	} else {
+		filters = append(filters, permissions.DashboardPermissionFilter{
 			OrgRole:         query.SignedInUser.OrgRole,
 			OrgId:           query.SignedInUser.OrgId,
 			Dialect:         dialect,
 			UserId:          query.SignedInUser.UserId,
 			PermissionLevel: query.Permission,
-		},
+		})
 	}
 
 	filters = append(filters, query.Filters...)
@@ -247,6 +247,10 @@ func DeleteOrg(cmd *models.DeleteOrgCommand) error {
 			}
 		}
 
+		sess.publishAfterCommit(&events.OrgDeleted{
+			Timestamp: time.Now(),
+			OrgID:     cmd.Id,
+		})
 		return nil
 	})
 }
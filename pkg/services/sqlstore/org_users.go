@@ -6,6 +6,7 @@ import (
 	"time"
 
 	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
 	"github.com/grafana/grafana/pkg/models"
 	"github.com/grafana/grafana/pkg/util"
 )
@@ -52,6 +53,13 @@ func AddOrgUser(cmd *models.AddOrgUserCommand) error {
 			return err
 		}
 
+		sess.publishAfterCommit(&events.OrgUserAdded{
+			Timestamp: entity.Created,
+			OrgID:     entity.OrgId,
+			UserID:    entity.UserId,
+			Role:      string(entity.Role),
+		})
+
 		var userOrgs []*models.UserOrgDTO
 		sess.Table("org_user")
 		sess.Join("INNER", "org", "org_user.org_id=org.id")
@@ -0,0 +1,28 @@
+package sqlstore
+
+import (
+	"errors"
+	"time"
+
+	"github.com/grafana/grafana/pkg/services/sqlstore/migrator"
+	"github.com/lib/pq"
+)
+
+// ErrNotPostgres is returned by NewPostgresListener when the database isn't Postgres.
+var ErrNotPostgres = errors.New("database isn't postgres")
+
+// NewPostgresListener returns a listener of the notifications sent to the Postgres database with
+// NOTIFY, on its own connection that is re-established when lost. The callback, which may be nil,
+// is called on connection events, see pq.NewListener.
+func (ss *SQLStore) NewPostgresListener(eventCallback pq.EventCallbackType) (*pq.Listener, error) {
+	if ss.Dialect.DriverName() != migrator.Postgres {
+		return nil, ErrNotPostgres
+	}
+
+	cnnstr, err := ss.buildConnectionString()
+	if err != nil {
+		return nil, err
+	}
+
+	return pq.NewListener(cnnstr, 10*time.Second, time.Minute, eventCallback), nil
+}
@@ -2,11 +2,13 @@ package sqlstore
 
 import (
 	"bytes"
+	"context"
 	"fmt"
 	"strings"
 	"time"
 
 	"github.com/grafana/grafana/pkg/bus"
+	"github.com/grafana/grafana/pkg/events"
 	"github.com/grafana/grafana/pkg/models"
 )
 
@@ -77,26 +79,37 @@ func getTeamSelectSQLBase(filteredUsers []string) string {
 
 func CreateTeam(cmd *models.CreateTeamCommand) error {
 	return inTransaction(func(sess *DBSession) error {
-		if isNameTaken, err := isTeamNameTaken(cmd.OrgId, cmd.Name, 0, sess); err != nil {
-			return err
-		} else if isNameTaken {
-			return models.ErrTeamNameTaken
-		}
+		return createTeam(sess, cmd)
+	})
+}
 
-		team := models.Team{
-			Name:    cmd.Name,
-			Email:   cmd.Email,
-			OrgId:   cmd.OrgId,
-			Created: time.Now(),
-			Updated: time.Now(),
-		}
+// CreateTeamCtx creates a team using the transaction carried by the context, see SQLStore.InTransaction.
+func CreateTeamCtx(ctx context.Context, cmd *models.CreateTeamCommand) error {
+	return withDbSession(ctx, func(sess *DBSession) error {
+		return createTeam(sess, cmd)
+	})
+}
 
-		_, err := sess.Insert(&team)
+func createTeam(sess *DBSession, cmd *models.CreateTeamCommand) error {
+	if isNameTaken, err := isTeamNameTaken(cmd.OrgId, cmd.Name, 0, sess); err != nil {
+		return err
+	} else if isNameTaken {
+		return models.ErrTeamNameTaken
+	}
 
-		cmd.Result = team
+	team := models.Team{
+		Name:    cmd.Name,
+		Email:   cmd.Email,
+		OrgId:   cmd.OrgId,
+		Created: time.Now(),
+		Updated: time.Now(),
+	}
 
-		return err
-	})
+	_, err := sess.Insert(&team)
+
+	cmd.Result = team
+
+	return err
 }
 
 func UpdateTeam(cmd *models.UpdateTeamCommand) error {
@@ -148,6 +161,12 @@ func DeleteTeam(cmd *models.DeleteTeamCommand) error {
 				return err
 			}
 		}
+
+		sess.publishAfterCommit(&events.TeamDeleted{
+			Timestamp: time.Now(),
+			OrgID:     cmd.OrgId,
+			TeamID:    cmd.Id,
+		})
 		return nil
 	})
 }
@@ -305,8 +324,18 @@ func AddTeamMember(cmd *models.AddTeamMemberCommand) error {
 			Permission: cmd.Permission,
 		}
 
-		_, err := sess.Insert(&entity)
-		return err
+		if _, err := sess.Insert(&entity); err != nil {
+			return err
+		}
+
+		sess.publishAfterCommit(&events.TeamMemberAdded{
+			Timestamp: entity.Created,
+			OrgID:     cmd.OrgId,
+			TeamID:    cmd.TeamId,
+			UserID:    cmd.UserId,
+			External:  cmd.External,
+		})
+		return nil
 	})
 }
 
@@ -365,17 +394,24 @@ func RemoveTeamMember(cmd *models.RemoveTeamMemberCommand) error {
 			}
 		}
 
-		var rawSQL = "DELETE FROM team_member WHERE org_id=? and team_id=? and user_id=?"
-		res, err := sess.Exec(rawSQL, cmd.OrgId, cmd.TeamId, cmd.UserId)
+		member, err := getTeamMember(sess, cmd.OrgId, cmd.TeamId, cmd.UserId)
 		if err != nil {
 			return err
 		}
-		rows, err := res.RowsAffected()
-		if rows == 0 {
-			return models.ErrTeamMemberNotFound
+
+		var rawSQL = "DELETE FROM team_member WHERE org_id=? and team_id=? and user_id=?"
+		if _, err := sess.Exec(rawSQL, cmd.OrgId, cmd.TeamId, cmd.UserId); err != nil {
+			return err
 		}
 
-		return err
+		sess.publishAfterCommit(&events.TeamMemberRemoved{
+			Timestamp: time.Now(),
+			OrgID:     cmd.OrgId,
+			TeamID:    cmd.TeamId,
+			UserID:    cmd.UserId,
+			External:  member.External,
+		})
+		return nil
 	})
 }
 
@@ -181,6 +181,13 @@ func (ss *SQLStore) createUser(ctx context.Context, args userCreationArgs, skipO
 			if _, err = sess.Insert(&orgUser); err != nil {
 				return err
 			}
+
+			sess.publishAfterCommit(&events.OrgUserAdded{
+				Timestamp: orgUser.Created,
+				OrgID:     orgUser.OrgId,
+				UserID:    orgUser.UserId,
+				Role:      string(orgUser.Role),
+			})
 		}
 
 		return nil
@@ -278,6 +285,13 @@ func CreateUser(ctx context.Context, cmd *models.CreateUserCommand) error {
 			if _, err = sess.Insert(&orgUser); err != nil {
 				return err
 			}
+
+			sess.publishAfterCommit(&events.OrgUserAdded{
+				Timestamp: orgUser.Created,
+				OrgID:     orgUser.OrgId,
+				UserID:    orgUser.UserId,
+				Role:      string(orgUser.Role),
+			})
 		}
 
 		return nil
@@ -723,6 +737,10 @@ func deleteUserInTransaction(sess *DBSession, cmd *models.DeleteUserCommand) err
 		}
 	}
 
+	sess.publishAfterCommit(&events.UserDeleted{
+		Timestamp: time.Now(),
+		UserID:    cmd.UserId,
+	})
 	return nil
 }
 
@@ -20,6 +20,7 @@ import (
 
 	"github.com/grafana/grafana/pkg/components/gtime"
 	"github.com/grafana/grafana/pkg/infra/log"
+	"github.com/grafana/grafana/pkg/login/policymapping"
 	"github.com/grafana/grafana/pkg/util"
 )
 
@@ -286,6 +287,8 @@ type Cfg struct {
 	// SAML Auth
 	SAMLEnabled             bool
 	SAMLSingleLogoutEnabled bool
+	// SAMLPolicyMappings map SAML assertion attribute values to the RBAC policies synced at login.
+	SAMLPolicyMappings []policymapping.Mapping
 
 	// Dataproxy
 	SendUserHeader bool
@@ -369,6 +372,11 @@ func (cfg Cfg) IsPanelLibraryEnabled() bool {
 	return cfg.FeatureToggles["panelLibrary"]
 }
 
+// IsRBACEnabled returns whether the role based access control feature is enabled.
+func (cfg Cfg) IsRBACEnabled() bool {
+	return cfg.FeatureToggles["rbac"]
+}
+
 type CommandLineArgs struct {
 	Config   string
 	HomePath string
@@ -1104,6 +1112,10 @@ func readAuthSettings(iniFile *ini.File, cfg *Cfg) (err error) {
 	// SAML auth
 	cfg.SAMLEnabled = iniFile.Section("auth.saml").Key("enabled").MustBool(false)
 	cfg.SAMLSingleLogoutEnabled = iniFile.Section("auth.saml").Key("single_logout").MustBool(false)
+	cfg.SAMLPolicyMappings, err = policymapping.Parse(iniFile.Section("auth.saml").Key("policy_mappings").String())
+	if err != nil {
+		return fmt.Errorf("invalid auth.saml policy_mappings: %w", err)
+	}
 
 	// anonymous access
 	AnonymousEnabled = iniFile.Section("auth.anonymous").Key("enabled").MustBool(false)
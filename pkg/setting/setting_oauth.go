@@ -1,5 +1,7 @@
 package setting
 
+import "github.com/grafana/grafana/pkg/login/policymapping"
+
 type OAuthInfo struct {
 	ClientId, ClientSecret string
 	Scopes                 []string
@@ -17,6 +19,7 @@ type OAuthInfo struct {
 	TlsClientKey           string
 	TlsClientCa            string
 	TlsSkipVerify          bool
+	PolicyMappings         []policymapping.Mapping
 }
 
 type OAuther struct {
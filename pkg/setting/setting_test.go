@@ -14,6 +14,8 @@ import (
 
 	"gopkg.in/ini.v1"
 
+	"github.com/grafana/grafana/pkg/login/policymapping"
+
 	. "github.com/smartystreets/goconvey/convey"
 )
 
@@ -389,3 +391,21 @@ func TestAuthDurationSettings(t *testing.T) {
 	require.NoError(t, err)
 	require.Equal(t, maxLifetimeDurationTest, cfg.LoginMaxLifetime)
 }
+
+func TestSAMLPolicyMappingSettings(t *testing.T) {
+	f := ini.Empty()
+	cfg := NewCfg()
+	sec, err := f.NewSection("auth.saml")
+	require.NoError(t, err)
+	_, err = sec.NewKey("policy_mappings", "Group:dba=datasource-admins, Group:*=baseline@2")
+	require.NoError(t, err)
+	require.NoError(t, readAuthSettings(f, cfg))
+	require.Equal(t, []policymapping.Mapping{
+		{Attribute: "Group", Value: "dba", PolicyUID: "datasource-admins"},
+		{Attribute: "Group", Value: "*", PolicyUID: "baseline", OrgID: 2},
+	}, cfg.SAMLPolicyMappings)
+
+	_, err = sec.NewKey("policy_mappings", "Group:dba")
+	require.NoError(t, err)
+	require.Error(t, readAuthSettings(f, cfg))
+}